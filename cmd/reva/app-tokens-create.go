@@ -40,6 +40,9 @@ type appTokenCreateOpts struct {
 	Label      string
 	Path       stringSlice
 	Share      stringSlice
+	Subtree    stringSlice
+	API        stringSlice
+	IP         stringSlice
 	Unlimited  bool
 }
 
@@ -61,15 +64,18 @@ func appTokensCreateCommand() *command {
 	cmd.Description = func() string { return "create a new application tokens" }
 	cmd.Usage = func() string { return "Usage: token-create" }
 
-	var path, share stringSlice
+	var path, share, subtree, api, ip stringSlice
 	label := cmd.String("label", "", "set a label")
 	expiration := cmd.String("expiration", "", "set expiration time (format <yyyy-mm-dd>)")
 	cmd.Var(&path, "path", "create a token for a file (format path:[r|w]). It is possible specify this flag multiple times")
 	cmd.Var(&share, "share", "create a token for a share (format shareid:[r|w]). It is possible specify this flag multiple times")
+	cmd.Var(&subtree, "subtree", "create a token for a subtree, including the resources accessed by id (format path:[r|w]). It is possible specify this flag multiple times")
+	cmd.Var(&api, "api", "restrict the token to an API (webdav, ocs or graph). It is possible specify this flag multiple times")
+	cmd.Var(&ip, "ip", "restrict the token to a client network in CIDR notation. It is possible specify this flag multiple times")
 	unlimited := cmd.Bool("all", false, "create a token with an unlimited scope")
 
 	cmd.ResetFlags = func() {
-		path, share, label, expiration, unlimited = nil, nil, nil, nil, nil
+		path, share, subtree, api, ip, label, expiration, unlimited = nil, nil, nil, nil, nil, nil, nil, nil
 	}
 
 	cmd.Action = func(w ...io.Writer) error {
@@ -78,6 +84,9 @@ func appTokensCreateCommand() *command {
 			Label:      *label,
 			Path:       path,
 			Share:      share,
+			Subtree:    subtree,
+			API:        api,
+			IP:         ip,
 			Unlimited:  *unlimited,
 		}
 
@@ -135,12 +144,35 @@ func appTokensCreateCommand() *command {
 }
 
 func getScope(ctx context.Context, client gateway.GatewayAPIClient, opts *appTokenCreateOpts) (map[string]*authpb.Scope, error) {
+	restricted := len(opts.API) != 0 || len(opts.IP) != 0
 	if opts.Unlimited {
+		if restricted {
+			return scope.AddAppTokenScope(&scope.AppToken{APIs: opts.API, AllowedIPs: opts.IP}, authpb.Role_ROLE_EDITOR, nil)
+		}
 		return scope.AddOwnerScope(nil)
 	}
 
 	var scopes map[string]*authpb.Scope
 	var err error
+	if len(opts.Subtree) != 0 {
+		for _, entry := range opts.Subtree {
+			// subtree = /home/a/b:[r|w]
+			i := strings.LastIndex(entry, ":")
+			if i < 0 {
+				return nil, errtypes.BadRequest("subtree must be in the format path:[r|w]")
+			}
+			role, err := parsePermission(entry[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			t := &scope.AppToken{Path: entry[:i], APIs: opts.API, AllowedIPs: opts.IP}
+			scopes, err = scope.AddAppTokenScope(t, role, scopes)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	if len(opts.Share) != 0 {
 		// TODO(gmgigi96): verify format
 		for _, entry := range opts.Share {
@@ -227,8 +259,12 @@ func parsePermission(perm string) (authpb.Role, error) {
 }
 
 func checkOpts(opts *appTokenCreateOpts) error {
-	if len(opts.Share) == 0 && len(opts.Path) == 0 && !opts.Unlimited {
+	if len(opts.Share) == 0 && len(opts.Path) == 0 && len(opts.Subtree) == 0 && !opts.Unlimited {
 		return errtypes.BadRequest("specify a token scope")
 	}
+	// path and share scopes would grant access regardless of the restrictions
+	if (len(opts.API) != 0 || len(opts.IP) != 0) && (len(opts.Share) != 0 || len(opts.Path) != 0) {
+		return errtypes.BadRequest("api and ip restrictions can only be used with subtree or all")
+	}
 	return nil
 }
@@ -37,7 +37,8 @@ const (
 )
 
 type config struct {
-	Host string `json:"host"`
+	Host string     `json:"host"`
+	OIDC *oidcLogin `json:"oidc,omitempty"`
 }
 
 func getConfigFile() string {
@@ -71,6 +72,23 @@ func writeConfig(c *config) error {
 	return os.WriteFile(getConfigFile(), data, 0600)
 }
 
+// setHost stores the address of the gateway in the config file, keeping
+// the rest of the config, e.g. the OIDC login.
+func setHost(host string) (*config, error) {
+	c, err := readConfig()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		c = &config{}
+	}
+	c.Host = host
+	if err := writeConfig(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 func getTokenFile() string {
 	if tokenFile != "" {
 		return tokenFile
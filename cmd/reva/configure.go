@@ -36,10 +36,11 @@ var configureCommand = func() *command {
 			return err
 		}
 
-		conf = &config{Host: text}
-		if err := writeConfig(conf); err != nil {
+		c, err := setHost(text)
+		if err != nil {
 			return err
 		}
+		conf = c
 		fmt.Println("config saved at ", getConfigFile())
 		return nil
 	}
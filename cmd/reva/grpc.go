@@ -36,6 +36,9 @@ import (
 
 func getAuthContext() context.Context {
 	ctx := context.Background()
+	if err := refreshOIDCLogin(ctx); err != nil {
+		log.Println(err)
+	}
 	// read token from file
 	t, err := readToken()
 	if err != nil {
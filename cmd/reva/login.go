@@ -26,26 +26,43 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	registry "github.com/cs3org/go-cs3apis/cs3/auth/registry/v1beta1"
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/auth/devicecode"
+	"github.com/cs3org/reva/v3/pkg/auth/totp"
 )
 
 var loginCommand = func() *command {
 	cmd := newCommand("login")
 	cmd.Description = func() string { return "login into the reva server" }
-	cmd.Usage = func() string { return "Usage: login <type>" }
+	cmd.Usage = func() string { return "Usage: login <type> or login -device -issuer <url> -client-id <id> [<type>]" }
 	listFlag := cmd.Bool("list", false, "list available login methods")
 	usernameOpt := cmd.String("username", "", "provide the username")
 	passwordOpt := cmd.String("password", "", "provide the password")
 	apiKeyOpt := cmd.String("api-key", "", "secret for the machine auth")
+	otpOpt := cmd.String("otp", "", "one-time code or recovery code, for accounts enrolled in two-factor authentication")
+	deviceFlag := cmd.Bool("device", false, "log in through the OIDC device authorization flow, for SSO accounts")
+	issuerOpt := cmd.String("issuer", "", "the OIDC issuer, for the device login")
+	clientIDOpt := cmd.String("client-id", "", "the OIDC client id, for the device login")
+	clientSecretOpt := cmd.String("client-secret", "", "the OIDC client secret, for the device login with confidential clients")
+	scopesOpt := cmd.String("scopes", "", "comma separated OIDC scopes to request, for the device login")
+	insecureOIDCFlag := cmd.Bool("insecure-oidc", false, "skip the certificate checks of the OIDC issuer")
 
 	cmd.ResetFlags = func() {
 		*listFlag = false
 		*usernameOpt = ""
 		*passwordOpt = ""
 		*apiKeyOpt = ""
+		*otpOpt = ""
+		*deviceFlag = false
+		*issuerOpt = ""
+		*clientIDOpt = ""
+		*clientSecretOpt = ""
+		*scopesOpt = ""
+		*insecureOIDCFlag = false
 	}
 
 	cmd.Action = func(w ...io.Writer) error {
@@ -82,6 +99,30 @@ var loginCommand = func() *command {
 			return nil
 		}
 
+		if *deviceFlag {
+			if cmd.NArg() > 1 {
+				return errors.New("Invalid arguments: " + cmd.Usage())
+			}
+			authType := "oidc"
+			if cmd.NArg() == 1 {
+				authType = cmd.Args()[0]
+			}
+			c := &devicecode.Config{
+				Issuer:       *issuerOpt,
+				ClientID:     *clientIDOpt,
+				ClientSecret: *clientSecretOpt,
+				Insecure:     *insecureOIDCFlag,
+			}
+			if *scopesOpt != "" {
+				c.Scopes = strings.Split(*scopesOpt, ",")
+			}
+			if err := deviceLogin(context.Background(), authType, c); err != nil {
+				return err
+			}
+			fmt.Println("OK")
+			return nil
+		}
+
 		if cmd.NArg() != 1 {
 			return errors.New("Invalid arguments: " + cmd.Usage())
 		}
@@ -122,7 +163,7 @@ var loginCommand = func() *command {
 		req := &gateway.AuthenticateRequest{
 			Type:         authType,
 			ClientId:     username,
-			ClientSecret: password,
+			ClientSecret: totp.JoinSecret(password, *otpOpt),
 		}
 
 		ctx := context.Background()
@@ -136,6 +177,13 @@ var loginCommand = func() *command {
 		}
 
 		writeToken(res.Token)
+		if conf.OIDC != nil {
+			// a previous device login must not take over this session
+			conf.OIDC = nil
+			if err := writeConfig(conf); err != nil {
+				return err
+			}
+		}
 		fmt.Println("OK")
 		return nil
 	}
@@ -72,6 +72,7 @@ var (
 		ocmShareGetReceivedCommand(),
 		openInAppCommand(),
 		preferencesCommand(),
+		totpCommand(),
 		publicShareCreateCommand(),
 		publicShareListCommand(),
 		publicShareRemoveCommand(),
@@ -85,6 +86,15 @@ var (
 		shareUpdateCommand(),
 		shareListReceivedCommand(),
 		shareUpdateReceivedCommand(),
+		shareTransferCommand(),
+		shareReportCommand(),
+		projectRequestCommand(),
+		projectListCommand(),
+		projectApproveCommand(),
+		projectRejectCommand(),
+		projectArchiveCommand(),
+		projectRestoreCommand(),
+		projectHistoryCommand(),
 		transferGetStatusCommand(),
 		transferCancelCommand(),
 		transferListCommand(),
@@ -123,11 +133,12 @@ func init() {
 
 func main() {
 	if host != "" {
-		conf = &config{host}
-		if err := writeConfig(conf); err != nil {
+		c, err := setHost(host)
+		if err != nil {
 			fmt.Println("error writing to config file")
 			os.Exit(1)
 		}
+		conf = c
 	}
 
 	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecuredatagateway}}
@@ -0,0 +1,116 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/auth/devicecode"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+// oidcLogin is the state of a login done with the device authorization flow,
+// kept in the config file to obtain new reva tokens without user interaction.
+type oidcLogin struct {
+	AuthType string             `json:"auth_type"`
+	Config   *devicecode.Config `json:"config"`
+	Token    *devicecode.Token  `json:"token"`
+}
+
+func deviceLogin(ctx context.Context, authType string, c *devicecode.Config) error {
+	tkn, err := devicecode.Login(ctx, c, func(uri, uriComplete, code string) {
+		if uriComplete != "" {
+			fmt.Printf("To log in, open %s\n", uriComplete)
+			fmt.Printf("or open %s and enter the code %s\n", uri, code)
+		} else {
+			fmt.Printf("To log in, open %s and enter the code %s\n", uri, code)
+		}
+		fmt.Println("Waiting for the login to be approved...")
+	})
+	if err != nil {
+		return err
+	}
+
+	login := &oidcLogin{AuthType: authType, Config: c, Token: tkn}
+	if err := authenticateOIDC(ctx, login); err != nil {
+		return err
+	}
+
+	conf.OIDC = login
+	return writeConfig(conf)
+}
+
+// refreshOIDCLogin obtains a new reva token when the current one expired and
+// the user logged in with the device authorization flow, refreshing the OIDC
+// token first if needed.
+func refreshOIDCLogin(ctx context.Context) error {
+	if conf == nil || conf.OIDC == nil || conf.OIDC.Token == nil {
+		return nil
+	}
+	if t, err := readToken(); err == nil && !revaTokenExpired(t) {
+		return nil
+	}
+
+	login := conf.OIDC
+	if !login.Token.Valid() {
+		tkn, err := devicecode.Refresh(ctx, login.Config, login.Token)
+		if err != nil {
+			return errors.Wrap(err, "the OIDC login expired, please log in again")
+		}
+		login.Token = tkn
+		if err := writeConfig(conf); err != nil {
+			return err
+		}
+	}
+	return authenticateOIDC(ctx, login)
+}
+
+func authenticateOIDC(ctx context.Context, login *oidcLogin) error {
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Authenticate(ctx, &gateway.AuthenticateRequest{
+		Type:         login.AuthType,
+		ClientSecret: login.Token.Credential(),
+	})
+	if err != nil {
+		return err
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		return formatError(res.Status)
+	}
+
+	writeToken(res.Token)
+	return nil
+}
+
+func revaTokenExpired(token string) bool {
+	var claims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err != nil {
+		return true
+	}
+	return claims.ExpiresAt != nil && time.Now().After(claims.ExpiresAt.Time)
+}
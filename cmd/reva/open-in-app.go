@@ -37,7 +37,7 @@ func openInAppCommand() *command {
 		return "Usage: open-in-app [-flags] [-viewmode view|read|write] [-app appname] <path>"
 	}
 	viewMode := cmd.String("viewmode", "view", "the view permissions, defaults to view")
-	app := cmd.String("app", "", "the application if the default is to be overridden for the file's mimetype")
+	app := cmd.String("app", "", "the application if the default of the user, of their groups or of the system is to be overridden for the file's mimetype")
 	insecureFlag := cmd.Bool("insecure", false, "disables grpc transport security")
 	skipVerifyFlag := cmd.Bool("skip-verify", false, "whether to skip verifying remote reva's certificate chain and host name")
 
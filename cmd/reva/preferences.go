@@ -21,26 +21,35 @@ package main
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	preferences "github.com/cs3org/go-cs3apis/cs3/preferences/v1beta1"
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	prefs "github.com/cs3org/reva/v3/pkg/preferences"
 	"github.com/pkg/errors"
 )
 
 var preferencesCommand = func() *command {
 	cmd := newCommand("preferences")
-	cmd.Description = func() string { return "set and get user preferences" }
-	cmd.Usage = func() string { return "Usage: preferences set <key> <value> or preferences get <key>" }
+	cmd.Description = func() string { return "set, get, list and delete user preferences" }
+	cmd.Usage = func() string {
+		return `Usage: preferences <subcommand> [args]
+  set <key> <ns> <value>
+  get <key> <ns>
+  delete <key> <ns>
+  list [ns]
+  get-many <ns> <key>...
+  set-many <ns> <key=value>...`
+	}
 
 	cmd.Action = func(w ...io.Writer) error {
 
-		if cmd.NArg() < 2 {
+		if cmd.NArg() < 1 {
 			return errors.New("Invalid arguments: " + cmd.Usage())
 		}
 
 		subcommand := cmd.Args()[0]
-		key := cmd.Args()[1]
-		ns := cmd.Args()[2]
+		args := cmd.Args()[1:]
 
 		client, err := getClient()
 		if err != nil {
@@ -51,16 +60,15 @@ var preferencesCommand = func() *command {
 
 		switch subcommand {
 		case "set":
-			if cmd.NArg() < 4 {
+			if len(args) < 3 {
 				return errors.New("Invalid arguments: " + cmd.Usage())
 			}
-			value := cmd.Args()[3]
 			req := &preferences.SetKeyRequest{
 				Key: &preferences.PreferenceKey{
-					Namespace: ns,
-					Key:       key,
+					Namespace: args[1],
+					Key:       args[0],
 				},
-				Val: value,
+				Val: args[2],
 			}
 
 			res, err := client.SetKey(ctx, req)
@@ -73,10 +81,13 @@ var preferencesCommand = func() *command {
 			}
 
 		case "get":
+			if len(args) < 2 {
+				return errors.New("Invalid arguments: " + cmd.Usage())
+			}
 			req := &preferences.GetKeyRequest{
 				Key: &preferences.PreferenceKey{
-					Namespace: ns,
-					Key:       key,
+					Namespace: args[1],
+					Key:       args[0],
 				},
 			}
 
@@ -91,6 +102,98 @@ var preferencesCommand = func() *command {
 
 			fmt.Println(res.Val)
 
+		case "delete":
+			if len(args) < 2 {
+				return errors.New("Invalid arguments: " + cmd.Usage())
+			}
+
+			res, err := client.SetKey(ctx, prefs.NewDeleteKeyRequest(args[0], args[1]))
+			if err != nil {
+				return err
+			}
+
+			if res.Status.Code != rpc.Code_CODE_OK {
+				return formatError(res.Status)
+			}
+
+		case "list":
+			var ns string
+			if len(args) > 0 {
+				ns = args[0]
+			}
+
+			res, err := client.GetKey(ctx, prefs.NewListKeysRequest(ns))
+			if err != nil {
+				return err
+			}
+
+			if res.Status.Code != rpc.Code_CODE_OK {
+				return formatError(res.Status)
+			}
+
+			entries, err := prefs.ListedEntries(res)
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				fmt.Printf("%s\t%s\t%s\n", e.Namespace, e.Key, e.Value)
+			}
+
+		case "get-many":
+			if len(args) < 2 {
+				return errors.New("Invalid arguments: " + cmd.Usage())
+			}
+
+			req, err := prefs.NewGetKeysRequest(args[1:], args[0])
+			if err != nil {
+				return err
+			}
+			res, err := client.GetKey(ctx, req)
+			if err != nil {
+				return err
+			}
+
+			if res.Status.Code != rpc.Code_CODE_OK {
+				return formatError(res.Status)
+			}
+
+			values, err := prefs.ReturnedValues(res)
+			if err != nil {
+				return err
+			}
+			for _, k := range args[1:] {
+				if v, ok := values[k]; ok {
+					fmt.Printf("%s=%s\n", k, v)
+				}
+			}
+
+		case "set-many":
+			if len(args) < 2 {
+				return errors.New("Invalid arguments: " + cmd.Usage())
+			}
+
+			values := make(map[string]string, len(args)-1)
+			for _, kv := range args[1:] {
+				k, v, ok := strings.Cut(kv, "=")
+				if !ok || k == "" {
+					return errors.New("Invalid key=value pair: " + kv)
+				}
+				values[k] = v
+			}
+
+			req, err := prefs.NewSetKeysRequest(values, args[0])
+			if err != nil {
+				return err
+			}
+			res, err := client.SetKey(ctx, req)
+			if err != nil {
+				return err
+			}
+
+			if res.Status.Code != rpc.Code_CODE_OK {
+				return formatError(res.Status)
+			}
+
 		default:
 			return errors.New("Invalid arguments: " + cmd.Usage())
 		}
@@ -0,0 +1,82 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/projects"
+	"github.com/pkg/errors"
+)
+
+func projectApproveCommand() *command {
+	return projectActionCommand("project-approve", projects.ActionApprove, "approve the request of a project and provision it")
+}
+
+func projectRejectCommand() *command {
+	return projectActionCommand("project-reject", projects.ActionReject, "reject the request of a project")
+}
+
+func projectArchiveCommand() *command {
+	return projectActionCommand("project-archive", projects.ActionArchive, "move the data of a project to the archive")
+}
+
+func projectRestoreCommand() *command {
+	return projectActionCommand("project-restore", projects.ActionRestore, "move the data of an archived project back")
+}
+
+// projectActionCommand returns the command taking the given step of the
+// lifecycle of a project.
+func projectActionCommand(name string, action projects.Action, description string) *command {
+	cmd := newCommand(name)
+	cmd.Description = func() string { return description }
+	cmd.Usage = func() string { return "Usage: " + name + " [-flags] <name>" }
+	comment := cmd.String("comment", "", "reason of the change, recorded in the history of the project")
+
+	cmd.ResetFlags = func() {
+		*comment = ""
+	}
+
+	cmd.Action = func(w ...io.Writer) error {
+		if cmd.NArg() < 1 {
+			return errors.New("Invalid arguments: " + cmd.Usage())
+		}
+		project := cmd.Args()[0]
+
+		ctx := getAuthContext()
+		client, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		res, err := client.UpdateStorageSpace(ctx, projects.NewActionRequest(project, action, *comment))
+		if err != nil {
+			return err
+		}
+		if res.Status.Code != rpc.Code_CODE_OK {
+			return formatError(res.Status)
+		}
+
+		fmt.Printf("Project %s is now %s\n", project, projects.Status(res.StorageSpace))
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,84 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package main
+
+import (
+	"io"
+	"os"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/projects"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/pkg/errors"
+)
+
+func projectHistoryCommand() *command {
+	cmd := newCommand("project-history")
+	cmd.Description = func() string { return "print the lifecycle transitions of a project" }
+	cmd.Usage = func() string { return "Usage: project-history <name>" }
+
+	cmd.Action = func(w ...io.Writer) error {
+		if cmd.NArg() < 1 {
+			return errors.New("Invalid arguments: " + cmd.Usage())
+		}
+		name := cmd.Args()[0]
+
+		ctx := getAuthContext()
+		client, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		res, err := client.ListStorageSpaces(ctx, projects.NewListAllRequest(""))
+		if err != nil {
+			return err
+		}
+		if res.Status.Code != rpc.Code_CODE_OK {
+			return formatError(res.Status)
+		}
+
+		var transitions []*projects.Transition
+		var found bool
+		for _, s := range res.StorageSpaces {
+			if s.Name != name {
+				continue
+			}
+			found = true
+			// archived projects may have been recreated with the same name
+			ts, err := projects.Transitions(s)
+			if err != nil {
+				return err
+			}
+			transitions = append(transitions, ts...)
+		}
+		if !found {
+			return errors.New("project " + name + " not found")
+		}
+
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{"Time", "From", "To", "Actor", "Comment", "Run"})
+		for _, tr := range transitions {
+			t.AppendRow(table.Row{tr.Time.Format("2006-01-02 15:04:05"), tr.From, tr.To, tr.Actor, tr.Comment, tr.RunID})
+		}
+		t.Render()
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,85 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package main
+
+import (
+	"encoding/gob"
+	"io"
+	"os"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/projects"
+	"github.com/jedib0t/go-pretty/table"
+)
+
+func projectListCommand() *command {
+	cmd := newCommand("project-list")
+	cmd.Description = func() string { return "list all the projects, for the administrators" }
+	cmd.Usage = func() string { return "Usage: project-list [-flags]" }
+	status := cmd.String("status", "active", "status of the projects to list, or all")
+
+	cmd.ResetFlags = func() {
+		*status = "active"
+	}
+
+	cmd.Action = func(w ...io.Writer) error {
+		ctx := getAuthContext()
+		client, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		st := projects.ProjectStatus(*status)
+		if *status == "all" {
+			st = ""
+		}
+		res, err := client.ListStorageSpaces(ctx, projects.NewListAllRequest(st))
+		if err != nil {
+			return err
+		}
+		if res.Status.Code != rpc.Code_CODE_OK {
+			return formatError(res.Status)
+		}
+
+		if len(w) == 0 {
+			t := table.NewWriter()
+			t.SetOutputMirror(os.Stdout)
+			t.AppendHeader(table.Row{"Name", "Status", "Owner", "Path", "Used", "Quota", "Requested"})
+			for _, s := range res.StorageSpaces {
+				var used, quota, requested string
+				if s.Quota != nil {
+					used = formatBytes(float64(s.Quota.QuotaMaxBytes - s.Quota.RemainingBytes))
+					quota = formatBytes(float64(s.Quota.QuotaMaxBytes))
+				}
+				if q, ok := projects.RequestedQuota(s); ok {
+					requested = formatBytes(float64(q))
+				}
+				t.AppendRow(table.Row{s.Name, projects.Status(s), s.Owner.GetId().GetOpaqueId(), s.RootInfo.GetPath(), used, quota, requested})
+			}
+			t.Render()
+		} else {
+			enc := gob.NewEncoder(w[0])
+			if err := enc.Encode(res.StorageSpaces); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return cmd
+}
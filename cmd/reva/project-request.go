@@ -0,0 +1,66 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/projects"
+	"github.com/pkg/errors"
+)
+
+func projectRequestCommand() *command {
+	cmd := newCommand("project-request")
+	cmd.Description = func() string { return "request a new project, to be approved by the administrators" }
+	cmd.Usage = func() string { return "Usage: project-request [-flags] <name>" }
+	description := cmd.String("description", "", "description of the project")
+	quota := cmd.Uint64("quota", 0, "requested quota in bytes")
+	comment := cmd.String("comment", "", "use-case of the project, for the administrators")
+
+	cmd.ResetFlags = func() {
+		*description, *quota, *comment = "", 0, ""
+	}
+
+	cmd.Action = func(w ...io.Writer) error {
+		if cmd.NArg() < 1 {
+			return errors.New("Invalid arguments: " + cmd.Usage())
+		}
+		name := cmd.Args()[0]
+
+		ctx := getAuthContext()
+		client, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		res, err := client.CreateStorageSpace(ctx, projects.NewProjectRequest(name, *description, *quota, *comment))
+		if err != nil {
+			return err
+		}
+		if res.Status.Code != rpc.Code_CODE_OK {
+			return formatError(res.Status)
+		}
+
+		fmt.Printf("Requested project %s, pending the approval of the administrators\n", name)
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,103 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/sharereport"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/pkg/errors"
+)
+
+func shareReportCommand() *command {
+	cmd := newCommand("share-report")
+	cmd.Description = func() string { return "report who has access to a folder and its subtree" }
+	cmd.Usage = func() string { return "Usage: share-report [-flags] <path>" }
+	format := cmd.String("format", "table", "output format: table, json or csv")
+	access := cmd.Bool("access", false, "print the effective access per grantee instead of the list of shares")
+	noExpand := cmd.Bool("no-expand-groups", false, "do not resolve the members of shared groups")
+
+	cmd.ResetFlags = func() {
+		*format, *access, *noExpand = "table", false, false
+	}
+
+	cmd.Action = func(w ...io.Writer) error {
+		if cmd.NArg() < 1 {
+			return errors.New("Invalid arguments: " + cmd.Usage())
+		}
+
+		ctx := getAuthContext()
+		client, err := getClient()
+		if err != nil {
+			return err
+		}
+
+		report, err := sharereport.Fetch(ctx, client, &provider.Reference{Path: cmd.Args()[0]}, sharereport.Options{
+			ExpandGroups: !*noExpand,
+		})
+		if err != nil {
+			return err
+		}
+
+		out := io.Writer(os.Stdout)
+		if len(w) > 0 {
+			out = w[0]
+		}
+
+		switch *format {
+		case "json":
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		case "csv":
+			if *access {
+				return report.WriteAccessCSV(out)
+			}
+			return report.WriteCSV(out)
+		case "table":
+			t := table.NewWriter()
+			t.SetOutputMirror(out)
+			if *access {
+				t.AppendHeader(table.Row{"Path", "Principal", "Type", "Level", "Via"})
+				for _, a := range report.Access {
+					t.AppendRow(table.Row{a.Path, a.Principal, a.Type, a.Level, strings.Join(a.Via, ",")})
+				}
+			} else {
+				t.AppendHeader(table.Row{"Path", "Share", "Type", "Grantee", "Role", "Expiration", "Inherited", "Members"})
+				for _, e := range report.Entries {
+					var exp string
+					if e.Expiration != nil {
+						exp = e.Expiration.Format("2006-01-02")
+					}
+					t.AppendRow(table.Row{e.Path, e.ShareID, e.Type, e.Grantee, e.Role, exp, e.Inherited, strings.Join(e.Members, ",")})
+				}
+			}
+			t.Render()
+			return nil
+		default:
+			return errors.New("unsupported format " + *format + ", use table, json or csv")
+		}
+	}
+	return cmd
+}
@@ -0,0 +1,227 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/v3/internal/http/services/jobs"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/rjobs"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/pkg/errors"
+)
+
+// ownershipTransferJob is the name of the job run by the sql share manager.
+const ownershipTransferJob = "share.ownership-transfer"
+
+// transferReport is the result of the ownership transfer job.
+type transferReport struct {
+	DryRun    bool `json:"dry_run"`
+	Shares    int  `json:"shares"`
+	Links     int  `json:"links"`
+	OCMShares int  `json:"ocm_shares"`
+	Projects  int  `json:"projects"`
+	Failures  []struct {
+		Kind   string `json:"kind"`
+		ID     string `json:"id"`
+		Path   string `json:"path"`
+		Reason string `json:"reason"`
+	} `json:"failures"`
+}
+
+func shareTransferCommand() *command {
+	cmd := newCommand("share-transfer")
+	cmd.Description = func() string {
+		return "transfer the shares, public links, OCM shares and projects of a user to a successor"
+	}
+	cmd.Usage = func() string { return "Usage: share-transfer [-flags] <username> <successor_username>" }
+	endpoint := cmd.String("endpoint", "", "URL of the jobs HTTP service, e.g. https://reva.example.org/jobs")
+	sourcePath := cmd.String("source-path", "", "path the data of the user was moved from")
+	targetPath := cmd.String("target-path", "", "path the data of the user was moved to, in the storage of the successor")
+	dryRun := cmd.Bool("dry-run", false, "only report what would be transferred")
+	wait := cmd.Bool("wait", true, "wait for the transfer to complete and print its report")
+
+	cmd.ResetFlags = func() {
+		*endpoint, *sourcePath, *targetPath, *dryRun, *wait = "", "", "", false, true
+	}
+
+	cmd.Action = func(w ...io.Writer) error {
+		if cmd.NArg() != 2 || *endpoint == "" {
+			return errors.New("Invalid arguments: " + cmd.Usage())
+		}
+
+		client, err := getClient()
+		if err != nil {
+			return err
+		}
+		ctx := getAuthContext()
+
+		user, err := getUserID(ctx, client, cmd.Args()[0])
+		if err != nil {
+			return err
+		}
+		successor, err := getUserID(ctx, client, cmd.Args()[1])
+		if err != nil {
+			return err
+		}
+
+		token, err := readToken()
+		if err != nil {
+			return err
+		}
+		jc := &jobsClient{endpoint: strings.TrimSuffix(*endpoint, "/"), token: token}
+
+		run, err := jc.enqueue(ctx, &jobs.EnqueueRequest{
+			Job: ownershipTransferJob,
+			Params: rjobs.Params{
+				"user":        user,
+				"successor":   successor,
+				"source_path": *sourcePath,
+				"target_path": *targetPath,
+				"dry_run":     *dryRun,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("transfer enqueued as run %s\n", run.ID)
+		if !*wait {
+			return nil
+		}
+
+		attempt := 0
+		for run.State != rjobs.StateSucceeded && run.State != rjobs.StateCancelled {
+			if run.State == rjobs.StateFailed && run.Attempt != attempt {
+				attempt = run.Attempt
+				fmt.Printf("attempt %d failed, it will be retried: %s\n", run.Attempt, run.LastError)
+			}
+			time.Sleep(2 * time.Second)
+			if run, err = jc.status(ctx, run.ID); err != nil {
+				return err
+			}
+		}
+		if run.State == rjobs.StateCancelled {
+			return errors.New("the transfer was cancelled")
+		}
+
+		// the result is the report as stored by the runner, we go through
+		// its JSON representation to get it back
+		data, err := json.Marshal(run.Result)
+		if err != nil {
+			return err
+		}
+		var report transferReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			return err
+		}
+		printTransferReport(&report)
+		return nil
+	}
+	return cmd
+}
+
+func getUserID(ctx context.Context, client gateway.GatewayAPIClient, username string) (string, error) {
+	res, err := client.GetUserByClaim(ctx, &userpb.GetUserByClaimRequest{
+		Claim:                  "username",
+		Value:                  username,
+		SkipFetchingUserGroups: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		return "", formatError(res.Status)
+	}
+	return res.User.Id.OpaqueId, nil
+}
+
+func printTransferReport(r *transferReport) {
+	if r.DryRun {
+		fmt.Println("dry run, nothing was changed")
+	}
+	fmt.Printf("shares: %d, public links: %d, OCM shares: %d, projects: %d transferred\n", r.Shares, r.Links, r.OCMShares, r.Projects)
+	if len(r.Failures) == 0 {
+		return
+	}
+
+	fmt.Printf("%d items could not be transferred:\n", len(r.Failures))
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Kind", "ID", "Path", "Reason"})
+	for _, f := range r.Failures {
+		t.AppendRow(table.Row{f.Kind, f.ID, f.Path, f.Reason})
+	}
+	t.Render()
+}
+
+// jobsClient talks to the jobs HTTP service.
+type jobsClient struct {
+	endpoint string
+	token    string
+}
+
+func (c *jobsClient) enqueue(ctx context.Context, req *jobs.EnqueueRequest) (*jobs.Run, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, http.MethodPost, "/runs", body)
+}
+
+func (c *jobsClient) status(ctx context.Context, id string) (*jobs.Run, error) {
+	return c.do(ctx, http.MethodGet, "/runs/"+id, nil)
+}
+
+func (c *jobsClient) do(ctx context.Context, method, path string, body []byte) (*jobs.Run, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set(appctx.TokenHeader, c.token)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpRes, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpRes.Body.Close()
+
+	if httpRes.StatusCode != http.StatusOK && httpRes.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(httpRes.Body)
+		return nil, errors.Errorf("jobs service: %s: %s", httpRes.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var run jobs.Run
+	if err := json.NewDecoder(httpRes.Body).Decode(&run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
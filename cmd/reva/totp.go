@@ -0,0 +1,164 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/pkg/errors"
+)
+
+func totpCommand() *command {
+	cmd := newCommand("totp")
+	cmd.Description = func() string { return "manage the two-factor authentication of the current user" }
+	cmd.Usage = func() string { return "Usage: totp -endpoint <url> enroll|disable|recovery-codes" }
+	endpoint := cmd.String("endpoint", "", "URL of the totp HTTP service, e.g. https://reva.example.org/totp")
+
+	cmd.ResetFlags = func() {
+		*endpoint = ""
+	}
+
+	cmd.Action = func(w ...io.Writer) error {
+		if cmd.NArg() != 1 || *endpoint == "" {
+			return errors.New("Invalid arguments: " + cmd.Usage())
+		}
+
+		token, err := readToken()
+		if err != nil {
+			return err
+		}
+		tc := &totpClient{endpoint: strings.TrimSuffix(*endpoint, "/"), token: token}
+		ctx := context.Background()
+
+		switch cmd.Args()[0] {
+		case "enroll":
+			return totpEnroll(ctx, tc)
+		case "disable":
+			code, err := readTOTPCode()
+			if err != nil {
+				return err
+			}
+			if err := tc.do(ctx, "/disable", code, nil); err != nil {
+				return err
+			}
+			fmt.Println("two-factor authentication disabled")
+		case "recovery-codes":
+			code, err := readTOTPCode()
+			if err != nil {
+				return err
+			}
+			var res totpRecoveryCodes
+			if err := tc.do(ctx, "/recovery-codes", code, &res); err != nil {
+				return err
+			}
+			printRecoveryCodes(res.RecoveryCodes)
+		default:
+			return errors.New("Invalid arguments: " + cmd.Usage())
+		}
+		return nil
+	}
+	return cmd
+}
+
+type totpEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type totpRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func totpEnroll(ctx context.Context, tc *totpClient) error {
+	var enrollment totpEnrollment
+	if err := tc.do(ctx, "/enroll", "", &enrollment); err != nil {
+		return err
+	}
+
+	fmt.Println("Add the following account to your authenticator app:")
+	fmt.Println(enrollment.URI)
+	fmt.Printf("secret: %s\n", enrollment.Secret)
+
+	code, err := readTOTPCode()
+	if err != nil {
+		return err
+	}
+	var res totpRecoveryCodes
+	if err := tc.do(ctx, "/confirm", code, &res); err != nil {
+		return err
+	}
+	fmt.Println("two-factor authentication enabled")
+	printRecoveryCodes(res.RecoveryCodes)
+	return nil
+}
+
+func readTOTPCode() (string, error) {
+	fmt.Print("code: ")
+	return read(bufio.NewReader(os.Stdin))
+}
+
+func printRecoveryCodes(codes []string) {
+	fmt.Println("Store the following recovery codes in a safe place, each of them can be used once:")
+	for _, c := range codes {
+		fmt.Println(c)
+	}
+}
+
+// totpClient talks to the totp HTTP service.
+type totpClient struct {
+	endpoint string
+	token    string
+}
+
+func (c *totpClient) do(ctx context.Context, path, code string, res any) error {
+	form := url.Values{}
+	if code != "" {
+		form.Set("code", code)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set(appctx.TokenHeader, c.token)
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	httpRes, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpRes.Body.Close()
+
+	if httpRes.StatusCode != http.StatusOK && httpRes.StatusCode != http.StatusNoContent {
+		msg, _ := io.ReadAll(httpRes.Body)
+		return errors.Errorf("totp service: %s: %s", httpRes.Status, strings.TrimSpace(string(msg)))
+	}
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(httpRes.Body).Decode(res)
+}
@@ -21,12 +21,14 @@ package main
 import (
 	"encoding/gob"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"time"
 
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	txdriver "github.com/cs3org/reva/v3/pkg/datatx"
 	"github.com/jedib0t/go-pretty/table"
 )
 
@@ -63,10 +65,11 @@ func transferGetStatusCommand() *command {
 		if len(w) == 0 {
 			t := table.NewWriter()
 			t.SetOutputMirror(os.Stdout)
-			t.AppendHeader(table.Row{"ShareId.OpaqueId", "Id.OpaqueId", "Status", "Ctime"})
+			t.AppendHeader(table.Row{"ShareId.OpaqueId", "Id.OpaqueId", "Status", "Ctime", "Bytes", "Files", "Throughput", "ETA"})
 			cTime := time.Unix(int64(getStatusResponse.TxInfo.Ctime.Seconds), int64(getStatusResponse.TxInfo.Ctime.Nanos))
+			bytes, files, throughput, eta := progressColumns(getStatusResponse.TxInfo)
 			t.AppendRows([]table.Row{
-				{getStatusResponse.TxInfo.ShareId.OpaqueId, getStatusResponse.TxInfo.Id.OpaqueId, getStatusResponse.TxInfo.Status, cTime.Format("Mon Jan 2 15:04:05 -0700 MST 2006")},
+				{getStatusResponse.TxInfo.ShareId.OpaqueId, getStatusResponse.TxInfo.Id.OpaqueId, getStatusResponse.TxInfo.Status, cTime.Format("Mon Jan 2 15:04:05 -0700 MST 2006"), bytes, files, throughput, eta},
 			})
 			t.Render()
 		} else {
@@ -80,3 +83,32 @@ func transferGetStatusCommand() *command {
 	}
 	return cmd
 }
+
+// progressColumns formats the progress of a transfer for the tables,
+// leaving the columns empty if the driver did not report it.
+func progressColumns(info *datatx.TxInfo) (bytes, files, throughput, eta string) {
+	p, err := txdriver.GetProgress(info)
+	if err != nil || p == nil {
+		return "", "", "", ""
+	}
+	bytes = fmt.Sprintf("%s / %s", formatBytes(float64(p.BytesTransferred)), formatBytes(float64(p.BytesTotal)))
+	files = fmt.Sprintf("%d / %d", p.FilesTransferred, p.FilesTotal)
+	throughput = formatBytes(p.Throughput) + "/s"
+	if p.ETA > 0 {
+		eta = (time.Duration(p.ETA) * time.Second).String()
+	}
+	return bytes, files, throughput, eta
+}
+
+func formatBytes(b float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	i := 0
+	for b >= 1024 && i < len(units)-1 {
+		b /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", b, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", b, units[i])
+}
@@ -70,11 +70,12 @@ func transferListCommand() *command {
 		if len(w) == 0 {
 			t := table.NewWriter()
 			t.SetOutputMirror(os.Stdout)
-			t.AppendHeader(table.Row{"ShareId.OpaqueId", "Id.OpaqueId"})
+			t.AppendHeader(table.Row{"ShareId.OpaqueId", "Id.OpaqueId", "Status", "Bytes", "Files", "Throughput", "ETA"})
 
 			for _, s := range listTransfersResponse.Transfers {
+				bytes, files, throughput, eta := progressColumns(s)
 				t.AppendRows([]table.Row{
-					{s.ShareId.OpaqueId, s.Id.OpaqueId},
+					{s.ShareId.OpaqueId, s.Id.OpaqueId, s.Status, bytes, files, throughput, eta},
 				})
 			}
 			t.Render()
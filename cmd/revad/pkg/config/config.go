@@ -56,6 +56,7 @@ type Shared struct {
 	DataGateway           string   `default:"http://0.0.0.0:19001/datagateway" key:"datagateway"                        mapstructure:"datagateway"`
 	SkipUserGroupsInToken bool     `key:"skip_user_groups_in_token"            mapstructure:"skip_user_groups_in_token"`
 	BlockedUsers          []string `default:"[]"                               key:"blocked_users"                      mapstructure:"blocked_users"`
+	TrustedProxies        []string `key:"trusted_proxies"                      mapstructure:"trusted_proxies"`
 	Database              `mapstructure:",squash"`
 }
 
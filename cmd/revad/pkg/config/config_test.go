@@ -489,6 +489,7 @@ func TestDump(t *testing.T) {
 			"datagateway":               "",
 			"skip_user_groups_in_token": false,
 			"blocked_users":             []any{},
+			"trusted_proxies":           []any{},
 			"Database": map[string]any{
 				"DBHost":     "",
 				"DBName":     "",
@@ -32,14 +32,18 @@ import (
 	_ "github.com/cs3org/reva/v3/pkg/app/provider/loader"
 	_ "github.com/cs3org/reva/v3/pkg/app/registry/loader"
 	_ "github.com/cs3org/reva/v3/pkg/appauth/manager/loader"
+	_ "github.com/cs3org/reva/v3/pkg/audit/sink/loader"
 	_ "github.com/cs3org/reva/v3/pkg/auth/manager/loader"
 	_ "github.com/cs3org/reva/v3/pkg/auth/registry/loader"
 	_ "github.com/cs3org/reva/v3/pkg/datatx/manager/loader"
 	_ "github.com/cs3org/reva/v3/pkg/group/manager/loader"
 	_ "github.com/cs3org/reva/v3/pkg/labels/loader"
 	_ "github.com/cs3org/reva/v3/pkg/metrics/driver/loader"
+	_ "github.com/cs3org/reva/v3/pkg/notification/digest/loader"
 	_ "github.com/cs3org/reva/v3/pkg/notification/handler/loader"
+	_ "github.com/cs3org/reva/v3/pkg/notification/inbox/loader"
 	_ "github.com/cs3org/reva/v3/pkg/notification/manager/loader"
+	_ "github.com/cs3org/reva/v3/pkg/notification/transport/loader"
 	_ "github.com/cs3org/reva/v3/pkg/ocm/invite/repository/loader"
 	_ "github.com/cs3org/reva/v3/pkg/ocm/provider/authorizer/loader"
 	_ "github.com/cs3org/reva/v3/pkg/ocm/share/repository/loader"
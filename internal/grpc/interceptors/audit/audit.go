@@ -0,0 +1,309 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package audit
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/audit"
+	"github.com/cs3org/reva/v3/pkg/audit/sink/registry"
+	"github.com/cs3org/reva/v3/pkg/auth/scope"
+	"github.com/cs3org/reva/v3/pkg/clientip"
+	"github.com/cs3org/reva/v3/pkg/rgrpc"
+	"github.com/cs3org/reva/v3/pkg/spaces"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPriority = 150
+)
+
+func init() {
+	rgrpc.RegisterUnaryInterceptor("audit", NewUnary)
+}
+
+// actions maps the audited gRPC methods to their actions.
+var actions = map[string]string{
+	"Authenticate": audit.ActionLogin,
+
+	"CreateShare":         audit.ActionShareCreate,
+	"UpdateShare":         audit.ActionShareUpdate,
+	"RemoveShare":         audit.ActionShareRemove,
+	"UpdateReceivedShare": audit.ActionReceivedShareUpdate,
+
+	"CreateOCMShare": audit.ActionOCMShareCreate,
+	"UpdateOCMShare": audit.ActionOCMShareUpdate,
+	"RemoveOCMShare": audit.ActionOCMShareRemove,
+
+	"CreatePublicShare": audit.ActionLinkCreate,
+	"UpdatePublicShare": audit.ActionLinkUpdate,
+	"RemovePublicShare": audit.ActionLinkRemove,
+
+	"AddGrant":    audit.ActionGrantAdd,
+	"UpdateGrant": audit.ActionGrantUpdate,
+	"RemoveGrant": audit.ActionGrantRemove,
+	"DenyGrant":   audit.ActionGrantDeny,
+
+	"SetLock": audit.ActionLockSet,
+	"Unlock":  audit.ActionLockRemove,
+
+	"Delete":             audit.ActionDelete,
+	"RestoreFileVersion": audit.ActionVersionRestore,
+	"RestoreRecycleItem": audit.ActionTrashRestore,
+	"PurgeRecycle":       audit.ActionTrashPurge,
+}
+
+type config struct {
+	Priority int `mapstructure:"priority"`
+	// Sinks maps the names of the sinks the events are written to,
+	// to their configuration.
+	Sinks map[string]map[string]any `mapstructure:"sinks"`
+}
+
+func (c *config) ApplyDefaults() {
+	if c.Priority == 0 {
+		c.Priority = defaultPriority
+	}
+}
+
+// NewUnary returns a new unary interceptor recording the security relevant
+// calls in the audit trail. It is meant to be enabled on the gateway,
+// which all the calls of the users go through.
+func NewUnary(m map[string]any) (grpc.UnaryServerInterceptor, int, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, 0, err
+	}
+	if len(c.Sinks) == 0 {
+		return nil, 0, errors.New("audit: no sink configured")
+	}
+
+	var sinks []audit.Sink
+	for name, sc := range c.Sinks {
+		f, ok := registry.NewFuncs[name]
+		if !ok {
+			return nil, 0, errors.New("audit: unknown sink " + name)
+		}
+		s, err := f(context.Background(), sc)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "audit: error creating sink "+name)
+		}
+		sinks = append(sinks, s)
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		action, ok := actions[path.Base(info.FullMethod)]
+		if !ok {
+			if path.Base(info.FullMethod) != "InitiateFileDownload" || !isPublicLinkAccess(ctx) {
+				return handler(ctx, req)
+			}
+			action = audit.ActionLinkDownload
+		}
+
+		res, err := handler(ctx, req)
+
+		e := newEvent(ctx, action, info.FullMethod, req, res, err)
+		log := appctx.GetLogger(ctx)
+		for _, s := range sinks {
+			if err := s.Write(ctx, e); err != nil {
+				log.Error().Err(err).Str("action", e.Action).Msg("audit: error writing event")
+			}
+		}
+		return res, err
+	}, c.Priority, nil
+}
+
+func newEvent(ctx context.Context, action, method string, req, res any, err error) *audit.Event {
+	e := &audit.Event{
+		Time:    time.Now().UTC(),
+		Action:  action,
+		Outcome: audit.OutcomeSuccess,
+		Method:  method,
+		Details: map[string]string{},
+	}
+
+	if err != nil {
+		e.Outcome = audit.OutcomeFailure
+		e.Status = status.Convert(err).Message()
+	} else if r, ok := res.(interface{ GetStatus() *rpc.Status }); ok && r.GetStatus().GetCode() != rpc.Code_CODE_OK {
+		e.Outcome = audit.OutcomeFailure
+		e.Status = fmt.Sprintf("%s: %s", r.GetStatus().GetCode(), r.GetStatus().GetMessage())
+	}
+
+	u, ok := appctx.ContextGetUser(ctx)
+	if !ok {
+		// the user is only known after a successful login
+		if r, ok := res.(interface{ GetUser() *userpb.User }); ok {
+			u = r.GetUser()
+		}
+	}
+	if u != nil {
+		e.Actor = u.GetId().GetOpaqueId()
+		e.ActorIdp = u.GetId().GetIdp()
+		e.ActorName = u.GetUsername()
+	}
+
+	e.ClientIP = clientip.FromContext(ctx)
+	if ua, ok := appctx.ContextGetUserAgentString(ctx); ok {
+		e.UserAgent = ua
+	}
+
+	describe(ctx, e, req, res)
+	if len(e.Details) == 0 {
+		e.Details = nil
+	}
+	return e
+}
+
+// bearerLoginTypes are the login types whose client id is a secret token,
+// which must not end up in the audit trail.
+var bearerLoginTypes = []string{"publicshares", "ocmshares", "ocmsharecode", "ocmexchangedtoken"}
+
+// describe fills in the target and the details of the event from the request,
+// or from the response for the shares referenced by their token. The tokens
+// of the links and of the OCM shares grant access to them, so only their ids
+// are recorded.
+func describe(ctx context.Context, e *audit.Event, req, res any) {
+	switch r := req.(type) {
+	case interface{ GetClientId() string }:
+		// login
+		var loginType string
+		if t, ok := req.(interface{ GetType() string }); ok {
+			loginType = t.GetType()
+			e.Details["type"] = loginType
+		}
+		if !slices.Contains(bearerLoginTypes, loginType) {
+			e.Target = r.GetClientId()
+		}
+	case interface{ GetRef() *provider.Reference }:
+		e.Target = formatRef(r.GetRef())
+	case interface{ GetResourceInfo() *provider.ResourceInfo }:
+		e.Target = formatResource(r.GetResourceInfo().GetId(), r.GetResourceInfo().GetPath())
+	case interface{ GetResourceId() *provider.ResourceId }:
+		e.Target = formatResource(r.GetResourceId(), "")
+	case interface {
+		GetRef() *collaboration.ShareReference
+	}:
+		e.Target = "share:" + shareRef(r.GetRef().GetId().GetOpaqueId(), formatResource(r.GetRef().GetKey().GetResourceId(), ""))
+	case interface {
+		GetRef() *link.PublicShareReference
+	}:
+		id := r.GetRef().GetId().GetOpaqueId()
+		if s, ok := res.(interface{ GetShare() *link.PublicShare }); ok && id == "" {
+			id = s.GetShare().GetId().GetOpaqueId()
+		}
+		e.Target = "link:" + id
+	case interface{ GetRef() *ocm.ShareReference }:
+		id := r.GetRef().GetId().GetOpaqueId()
+		if s, ok := res.(interface{ GetShare() *ocm.Share }); ok && id == "" {
+			id = s.GetShare().GetId().GetOpaqueId()
+		}
+		e.Target = "ocmshare:" + id
+	case interface {
+		GetShare() *collaboration.ReceivedShare
+	}:
+		e.Target = "share:" + r.GetShare().GetShare().GetId().GetOpaqueId()
+		e.Details["state"] = r.GetShare().GetState().String()
+	}
+
+	// the grantee of shares and grants
+	switch r := req.(type) {
+	case interface{ GetGrant() *provider.Grant }:
+		addGrantee(e, r.GetGrant().GetGrantee())
+		e.Details["permissions"] = r.GetGrant().GetPermissions().String()
+	case interface {
+		GetGrant() *collaboration.ShareGrant
+	}:
+		addGrantee(e, r.GetGrant().GetGrantee())
+	case interface{ GetGrantee() *provider.Grantee }:
+		addGrantee(e, r.GetGrantee())
+	}
+
+	if e.Action == audit.ActionLinkDownload {
+		if shares, err := scope.GetPublicSharesFromScopes(scopes(ctx)); err == nil && len(shares) != 0 {
+			e.Details["link"] = shares[0].GetId().GetOpaqueId()
+		}
+	}
+}
+
+func addGrantee(e *audit.Event, g *provider.Grantee) {
+	switch {
+	case g.GetUserId() != nil:
+		e.Details["grantee"] = g.GetUserId().GetOpaqueId()
+		e.Details["grantee_type"] = "user"
+	case g.GetGroupId() != nil:
+		e.Details["grantee"] = g.GetGroupId().GetOpaqueId()
+		e.Details["grantee_type"] = "group"
+	}
+}
+
+func formatRef(ref *provider.Reference) string {
+	if ref.GetResourceId() == nil {
+		return ref.GetPath()
+	}
+	if ref.GetPath() != "" {
+		// path relative to the resource
+		return spaces.ResourceIdToString(ref.GetResourceId()) + ":" + ref.GetPath()
+	}
+	return spaces.ResourceIdToString(ref.GetResourceId())
+}
+
+func formatResource(id *provider.ResourceId, p string) string {
+	if p != "" || id == nil {
+		return p
+	}
+	return spaces.ResourceIdToString(id)
+}
+
+func shareRef(id, key string) string {
+	if id != "" {
+		return id
+	}
+	return key
+}
+
+func isPublicLinkAccess(ctx context.Context) bool {
+	for k := range scopes(ctx) {
+		if strings.HasPrefix(k, "publicshare:") {
+			return true
+		}
+	}
+	return false
+}
+
+func scopes(ctx context.Context) map[string]*authpb.Scope {
+	s, _ := appctx.ContextGetScopes(ctx)
+	return s
+}
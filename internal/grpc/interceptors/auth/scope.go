@@ -84,6 +84,10 @@ func expandAndVerifyScope(ctx context.Context, req any, tokenScope map[string]*a
 				if err = resolveOCMShare(ctx, ref, tokenScope[k], client, mgr); err == nil {
 					return nil
 				}
+			case strings.HasPrefix(k, "apptoken"):
+				if err = resolveAppToken(ctx, req, ref, tokenScope[k], user, client, mgr); err == nil {
+					return nil
+				}
 			}
 			if err != nil {
 				log.Err(err).Msgf("error resolving reference %s under scope %+v", ref.String(), k)
@@ -279,6 +283,56 @@ func resolveUserShare(ctx context.Context, ref *provider.Reference, scope *authp
 	return checkCacheForNestedResource(ctx, ref, share.ResourceId, client, mgr)
 }
 
+func resolveAppToken(ctx context.Context, req any, ref *provider.Reference, tokenScope *authpb.Scope, user *userpb.User, client gateway.GatewayAPIClient, mgr token.Manager) error {
+	refs := []*provider.Reference{ref}
+	if move, ok := req.(*provider.MoveRequest); ok {
+		refs = append(refs, move.GetDestination())
+	}
+
+	for _, r := range refs {
+		p, err := resolveRefPath(ctx, r, user, client, mgr)
+		if err != nil {
+			return err
+		}
+		if ok, err := scope.AppTokenAllowsPath(ctx, tokenScope, req, p); err != nil || !ok {
+			return errtypes.PermissionDenied("request is not for a resource in the app token subtree")
+		}
+	}
+	return nil
+}
+
+// resolveRefPath returns the absolute path of the referenced resource, as
+// seen by its owner user.
+func resolveRefPath(ctx context.Context, ref *provider.Reference, user *userpb.User, client gateway.GatewayAPIClient, mgr token.Manager) (string, error) {
+	if utils.IsAbsolutePathReference(ref) {
+		return ref.Path, nil
+	}
+
+	key := user.Id.OpaqueId + scopeDelimiter + ref.String()
+	if p, err := scopeExpansionCache.Get(key); err == nil {
+		return p.(string), nil
+	}
+
+	// The token being verified has no access to the reference yet,
+	// so the resource is resolved on behalf of the user.
+	ownerScope, err := scope.AddOwnerScope(map[string]*authpb.Scope{})
+	if err != nil {
+		return "", err
+	}
+	token, err := mgr.MintToken(ctx, user, ownerScope)
+	if err != nil {
+		return "", err
+	}
+	ctx = metadata.AppendToOutgoingContext(context.Background(), appctx.TokenHeader, token)
+
+	info, err := stat(ctx, client, ref)
+	if err != nil {
+		return "", err
+	}
+	_ = scopeExpansionCache.SetWithExpire(key, info.Path, scopeCacheExpiration*time.Second)
+	return info.Path, nil
+}
+
 func checkCacheForNestedResource(ctx context.Context, ref *provider.Reference, resource *provider.ResourceId, client gateway.GatewayAPIClient, mgr token.Manager) error {
 	// Check if this ref is cached
 	key := resourceid.OwnCloudResourceIDWrap(resource) + scopeDelimiter + getRefKey(ref)
@@ -20,9 +20,12 @@ package loader
 
 import (
 	// Load core GRPC services.
+	_ "github.com/cs3org/reva/v3/internal/grpc/interceptors/audit"
 	_ "github.com/cs3org/reva/v3/internal/grpc/interceptors/noshare"
 	_ "github.com/cs3org/reva/v3/internal/grpc/interceptors/notrashbin"
 	_ "github.com/cs3org/reva/v3/internal/grpc/interceptors/noversions"
+	_ "github.com/cs3org/reva/v3/internal/grpc/interceptors/ratelimit"
 	_ "github.com/cs3org/reva/v3/internal/grpc/interceptors/readonly"
+	_ "github.com/cs3org/reva/v3/internal/grpc/interceptors/readonlyprojects"
 	// Add your own service here.
 )
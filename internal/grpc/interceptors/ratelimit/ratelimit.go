@@ -0,0 +1,154 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/clientip"
+	"github.com/cs3org/reva/v3/pkg/ratelimit"
+	"github.com/cs3org/reva/v3/pkg/rgrpc"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPriority = 100
+)
+
+func init() {
+	rgrpc.RegisterUnaryInterceptor("ratelimit", NewUnary)
+}
+
+type config struct {
+	ratelimit.Config `mapstructure:",squash"`
+	Priority         int `mapstructure:"priority"`
+	// AuthMethods are the names of the methods checking credentials,
+	// whose failures lock the client out.
+	AuthMethods []string `mapstructure:"auth_methods"`
+}
+
+func (c *config) ApplyDefaults() {
+	c.Config.ApplyDefaults()
+	if c.Priority == 0 {
+		c.Priority = defaultPriority
+	}
+	if len(c.AuthMethods) == 0 {
+		c.AuthMethods = []string{
+			"Authenticate",
+			"GetPublicShareByToken",
+			"GetOCMShareByToken",
+			"AcceptInvite",
+		}
+	}
+}
+
+// NewUnary returns a new unary interceptor limiting the rate of the calls
+// and locking out the clients repeatedly failing to authenticate.
+func NewUnary(m map[string]any) (grpc.UnaryServerInterceptor, int, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, 0, err
+	}
+
+	limiter, err := ratelimit.New(&c.Config)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		log := appctx.GetLogger(ctx)
+
+		r := &ratelimit.Request{
+			User:  username(ctx, req),
+			IP:    clientip.FromContext(ctx),
+			Route: info.FullMethod,
+		}
+		if clientip.IsTrustedProxy(r.IP) {
+			// the calling service did not forward the address of its
+			// client: do not lock out all the clients behind it
+			r.IP = ""
+		}
+
+		wait, err := limiter.Allow(ctx, r)
+		if err != nil {
+			// do not make the service unavailable if the store is down
+			log.Error().Err(err).Msg("ratelimit: error checking the limits, letting the call through")
+		} else if wait > 0 {
+			secs := strconv.Itoa(int(math.Ceil(wait.Seconds())))
+			log.Info().Str("ip", r.IP).Str("user", r.User).Str("method", r.Route).Dur("retry_after", wait).Msg("ratelimit: too many requests")
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", secs))
+			return nil, status.Errorf(codes.ResourceExhausted, "too many requests, retry after %s seconds", secs)
+		}
+
+		res, err := handler(ctx, req)
+
+		if slices.Contains(c.AuthMethods, path.Base(info.FullMethod)) {
+			var lerr error
+			switch outcome(res, err) {
+			case rpc.Code_CODE_UNAUTHENTICATED, rpc.Code_CODE_PERMISSION_DENIED, rpc.Code_CODE_NOT_FOUND:
+				lerr = limiter.Failed(ctx, r)
+			case rpc.Code_CODE_OK:
+				lerr = limiter.Succeeded(ctx, r)
+			}
+			if lerr != nil {
+				log.Error().Err(lerr).Msg("ratelimit: error recording the authentication outcome")
+			}
+		}
+		return res, err
+	}, c.Priority, nil
+}
+
+// outcome returns the CS3 status code of a call.
+func outcome(res any, err error) rpc.Code {
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unauthenticated:
+			return rpc.Code_CODE_UNAUTHENTICATED
+		case codes.PermissionDenied:
+			return rpc.Code_CODE_PERMISSION_DENIED
+		}
+		return rpc.Code_CODE_INTERNAL
+	}
+	if r, ok := res.(interface{ GetStatus() *rpc.Status }); ok {
+		return r.GetStatus().GetCode()
+	}
+	return rpc.Code_CODE_INTERNAL
+}
+
+// username returns the username of the authenticated user or, for the calls
+// checking credentials, the username the credentials are given for.
+func username(ctx context.Context, req any) string {
+	if u, ok := appctx.ContextGetUser(ctx); ok {
+		return u.GetUsername()
+	}
+	if r, ok := req.(interface{ GetClientId() string }); ok {
+		return r.GetClientId()
+	}
+	return ""
+}
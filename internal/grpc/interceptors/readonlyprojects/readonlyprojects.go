@@ -0,0 +1,315 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package readonlyprojects
+
+import (
+	"context"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	projectssql "github.com/cs3org/reva/v3/pkg/projects/manager/sql"
+	"github.com/cs3org/reva/v3/pkg/rgrpc"
+	rstatus "github.com/cs3org/reva/v3/pkg/rgrpc/status"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/utils"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	defaultPriority = 200
+)
+
+func init() {
+	rgrpc.RegisterUnaryInterceptor("readonlyprojects", NewUnary)
+}
+
+type config struct {
+	Priority int `mapstructure:"priority"`
+	// Catalogue is the configuration of the sql projects catalogue.
+	Catalogue map[string]any `mapstructure:"catalogue"`
+	// RefreshInterval is the number of seconds the list of the
+	// read-only projects is cached for.
+	RefreshInterval int `mapstructure:"refresh_interval"`
+	// ExemptUsers can write to the read-only projects, i.e. the
+	// service account moving their data.
+	ExemptUsers []string `mapstructure:"exempt_users"`
+	// GatewaySvc resolves the references by id to their path.
+	GatewaySvc string `mapstructure:"gatewaysvc"`
+}
+
+func (c *config) ApplyDefaults() {
+	if c.Priority == 0 {
+		c.Priority = defaultPriority
+	}
+	if c.RefreshInterval == 0 {
+		c.RefreshInterval = 30
+	}
+	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
+}
+
+type space struct {
+	id    string
+	paths []string
+}
+
+type checker struct {
+	c        *config
+	projects *projectssql.ProjectsManager
+
+	mu        sync.Mutex
+	spaces    []space
+	refreshed time.Time
+}
+
+// NewUnary returns a new unary interceptor blocking the write requests
+// to the projects being archived or restored.
+func NewUnary(m map[string]any) (grpc.UnaryServerInterceptor, int, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, 0, err
+	}
+
+	catalogue, err := projectssql.Open(c.Catalogue)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "readonlyprojects: error opening the projects catalogue")
+	}
+	ch := &checker{
+		c:        &c,
+		projects: catalogue,
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		refs := writtenRefs(req)
+		if len(refs) == 0 {
+			return handler(ctx, req)
+		}
+		if u, ok := appctx.ContextGetUser(ctx); ok && slices.Contains(c.ExemptUsers, u.Username) {
+			return handler(ctx, req)
+		}
+
+		spaces, err := ch.readOnlySpaces(ctx)
+		unknown := err != nil
+		if unknown {
+			// the projects being moved are not known: deny the writes
+			// to all of them, not to the rest of the storage
+			appctx.GetLogger(ctx).Error().Err(err).Msg("readonlyprojects: error listing the read-only projects")
+		} else if len(spaces) == 0 {
+			return handler(ctx, req)
+		}
+		for _, ref := range refs {
+			readOnly, err := ch.isReadOnly(ctx, ref, spaces, unknown)
+			if err != nil {
+				appctx.GetLogger(ctx).Error().Err(err).Interface("ref", ref).Msg("readonlyprojects: error resolving the reference")
+				return deny(ctx, req), nil
+			}
+			if readOnly {
+				appctx.GetLogger(ctx).Debug().Interface("ref", ref).Msg("readonlyprojects: project is read-only")
+				return deny(ctx, req), nil
+			}
+		}
+		return handler(ctx, req)
+	}, c.Priority, nil
+}
+
+func (ch *checker) readOnlySpaces(ctx context.Context) ([]space, error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if time.Since(ch.refreshed) < time.Duration(ch.c.RefreshInterval)*time.Second {
+		return ch.spaces, nil
+	}
+
+	list, err := ch.projects.ListReadOnlyProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	spaces := make([]space, 0, len(list))
+	for _, p := range list {
+		s := space{id: p.SpaceID}
+		for _, path := range []string{p.Path, p.ArchivePath} {
+			if path != "" {
+				s.paths = append(s.paths, path)
+			}
+		}
+		spaces = append(spaces, s)
+	}
+	ch.spaces = spaces
+	ch.refreshed = time.Now()
+	return spaces, nil
+}
+
+// isReadOnly tells whether the reference points into one of the read-only
+// spaces, or into any project when these are unknown.
+func (ch *checker) isReadOnly(ctx context.Context, ref *provider.Reference, spaces []space, unknown bool) (bool, error) {
+	if ref == nil {
+		return false, nil
+	}
+	if !unknown && ref.GetResourceId().GetSpaceId() != "" {
+		for _, s := range spaces {
+			if ref.ResourceId.SpaceId == s.id {
+				return true, nil
+			}
+		}
+	}
+
+	p, err := ch.refPath(ctx, ref)
+	if err != nil {
+		if _, ok := err.(errtypes.IsNotFound); ok {
+			// left to the storage to fail
+			return false, nil
+		}
+		return false, err
+	}
+	if p == "" {
+		return false, nil
+	}
+	if unknown {
+		return utils.Skip(p, ch.projects.Roots()), nil
+	}
+	for _, s := range spaces {
+		if utils.Skip(p, s.paths) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// refPath returns the path the reference points to, resolving the id of
+// the references relative to a resource.
+func (ch *checker) refPath(ctx context.Context, ref *provider.Reference) (string, error) {
+	if ref.ResourceId == nil {
+		return ref.Path, nil
+	}
+
+	gw, err := pool.GetGatewayServiceClient(pool.Endpoint(ch.c.GatewaySvc))
+	if err != nil {
+		return "", err
+	}
+	if tkn, ok := appctx.ContextGetToken(ctx); ok {
+		ctx = metadata.AppendToOutgoingContext(ctx, appctx.TokenHeader, tkn)
+	}
+	res, err := gw.GetPath(ctx, &provider.GetPathRequest{ResourceId: ref.ResourceId})
+	if err != nil {
+		return "", err
+	}
+	switch res.Status.Code {
+	case rpc.Code_CODE_OK:
+	case rpc.Code_CODE_NOT_FOUND:
+		return "", errtypes.NotFound(ref.ResourceId.OpaqueId)
+	default:
+		return "", errtypes.InternalError(res.Status.Message)
+	}
+	return path.Join(res.Path, ref.Path), nil
+}
+
+// writtenRefs returns the references written to by the request,
+// nil if the request does not write.
+func writtenRefs(req any) []*provider.Reference {
+	switch r := req.(type) {
+	case *provider.CreateContainerRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.TouchFileRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.DeleteRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.InitiateFileUploadRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.MoveRequest:
+		return []*provider.Reference{r.Source, r.Destination}
+	case *provider.AddGrantRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.UpdateGrantRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.RemoveGrantRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.RestoreRecycleItemRequest:
+		return []*provider.Reference{r.Ref, r.RestoreRef}
+	case *provider.PurgeRecycleRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.RestoreFileVersionRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.SetArbitraryMetadataRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.UnsetArbitraryMetadataRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.SetLockRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.RefreshLockRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.UnlockRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.CreateSymlinkRequest:
+		return []*provider.Reference{r.Ref}
+	case *provider.CreateReferenceRequest:
+		return []*provider.Reference{r.Ref}
+	}
+	return nil
+}
+
+func deny(ctx context.Context, req any) any {
+	st := rstatus.NewPermissionDenied(ctx, nil, "permission denied: the project is being archived or restored")
+	switch req.(type) {
+	case *provider.CreateContainerRequest:
+		return &provider.CreateContainerResponse{Status: st}
+	case *provider.TouchFileRequest:
+		return &provider.TouchFileResponse{Status: st}
+	case *provider.DeleteRequest:
+		return &provider.DeleteResponse{Status: st}
+	case *provider.InitiateFileUploadRequest:
+		return &provider.InitiateFileUploadResponse{Status: st}
+	case *provider.MoveRequest:
+		return &provider.MoveResponse{Status: st}
+	case *provider.AddGrantRequest:
+		return &provider.AddGrantResponse{Status: st}
+	case *provider.UpdateGrantRequest:
+		return &provider.UpdateGrantResponse{Status: st}
+	case *provider.RemoveGrantRequest:
+		return &provider.RemoveGrantResponse{Status: st}
+	case *provider.RestoreRecycleItemRequest:
+		return &provider.RestoreRecycleItemResponse{Status: st}
+	case *provider.PurgeRecycleRequest:
+		return &provider.PurgeRecycleResponse{Status: st}
+	case *provider.RestoreFileVersionRequest:
+		return &provider.RestoreFileVersionResponse{Status: st}
+	case *provider.SetArbitraryMetadataRequest:
+		return &provider.SetArbitraryMetadataResponse{Status: st}
+	case *provider.UnsetArbitraryMetadataRequest:
+		return &provider.UnsetArbitraryMetadataResponse{Status: st}
+	case *provider.SetLockRequest:
+		return &provider.SetLockResponse{Status: st}
+	case *provider.RefreshLockRequest:
+		return &provider.RefreshLockResponse{Status: st}
+	case *provider.UnlockRequest:
+		return &provider.UnlockResponse{Status: st}
+	case *provider.CreateSymlinkRequest:
+		return &provider.CreateSymlinkResponse{Status: st}
+	case *provider.CreateReferenceRequest:
+		return &provider.CreateReferenceResponse{Status: st}
+	}
+	return nil
+}
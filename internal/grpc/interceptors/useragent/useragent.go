@@ -22,6 +22,7 @@ import (
 	"context"
 
 	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/clientip"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 )
@@ -30,12 +31,7 @@ import (
 // the useragent to the context.
 func NewUnary() grpc.UnaryServerInterceptor {
 	interceptor := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
-		if md, ok := metadata.FromIncomingContext(ctx); ok {
-			if lst, ok := md[appctx.UserAgentHeader]; ok && len(lst) != 0 {
-				ctx = metadata.AppendToOutgoingContext(ctx, appctx.UserAgentHeader, lst[0])
-			}
-		}
-		return handler(ctx, req)
+		return handler(forwardClientInfo(ctx), req)
 	}
 	return interceptor
 }
@@ -44,18 +40,32 @@ func NewUnary() grpc.UnaryServerInterceptor {
 // that adds the user agent to the context.
 func NewStream() grpc.StreamServerInterceptor {
 	interceptor := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		ctx := ss.Context()
-		if md, ok := metadata.FromIncomingContext(ctx); ok {
-			if lst, ok := md[appctx.UserAgentHeader]; ok && len(lst) != 0 {
-				ctx = metadata.AppendToOutgoingContext(ctx, appctx.UserAgentHeader, lst[0])
-			}
-		}
+		ctx := forwardClientInfo(ss.Context())
 		wrapped := newWrappedServerStream(ctx, ss)
 		return handler(srv, wrapped)
 	}
 	return interceptor
 }
 
+// forwardClientInfo propagates the user agent, the IP address and the HTTP
+// API of the client that originated the request to the outgoing calls. The
+// address and the API forwarded by the caller are only kept if the caller is
+// a trusted proxy.
+func forwardClientInfo(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if lst, ok := md[appctx.UserAgentHeader]; ok && len(lst) != 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, appctx.UserAgentHeader, lst[0])
+		}
+	}
+	if ip := clientip.FromContext(ctx); ip != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, appctx.ClientIPHeader, ip)
+	}
+	if api := clientip.APIFromContext(ctx); api != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, appctx.ClientAPIHeader, api)
+	}
+	return ctx
+}
+
 func newWrappedServerStream(ctx context.Context, ss grpc.ServerStream) *wrappedServerStream {
 	return &wrappedServerStream{ServerStream: ss, newCtx: ctx}
 }
@@ -56,16 +56,19 @@ func init() {
 type service struct {
 	provider app.Provider
 	conf     *config
+	// cancel stops the periodic registration in the app registry.
+	cancel context.CancelFunc
 }
 
 type config struct {
-	Driver              string                    `mapstructure:"driver"`
-	Drivers             map[string]map[string]any `mapstructure:"drivers"`
-	AppProviderURL      string                    `mapstructure:"app_provider_url"`
-	GatewaySvc          string                    `mapstructure:"gatewaysvc"`
-	MimeTypes           []string                  `docs:"nil;A list of mime types supported by this app."                                                              mapstructure:"mime_types"`
-	CustomMimeTypesJSON string                    `docs:"nil;An optional mapping file with the list of supported custom file extensions and corresponding mime types." mapstructure:"custom_mime_types_json"`
-	Language            string                    `mapstructure:"language"`
+	Driver               string                    `mapstructure:"driver"`
+	Drivers              map[string]map[string]any `mapstructure:"drivers"`
+	AppProviderURL       string                    `mapstructure:"app_provider_url"`
+	GatewaySvc           string                    `mapstructure:"gatewaysvc"`
+	MimeTypes            []string                  `docs:"nil;A list of mime types supported by this app."                                                              mapstructure:"mime_types"`
+	CustomMimeTypesJSON  string                    `docs:"nil;An optional mapping file with the list of supported custom file extensions and corresponding mime types." mapstructure:"custom_mime_types_json"`
+	Language             string                    `mapstructure:"language"`
+	RegistrationInterval int                       `docs:"30;Interval in seconds between the registrations of the app provider in the app registry."                    mapstructure:"registration_interval"`
 }
 
 func (c *config) ApplyDefaults() {
@@ -74,6 +77,9 @@ func (c *config) ApplyDefaults() {
 	}
 	c.AppProviderURL = sharedconf.GetGatewaySVC(c.AppProviderURL)
 	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
+	if c.RegistrationInterval == 0 {
+		c.RegistrationInterval = 30
+	}
 }
 
 // New creates a new AppProviderService.
@@ -93,9 +99,11 @@ func New(ctx context.Context, m map[string]any) (rgrpc.Service, error) {
 		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
 	service := &service{
 		conf:     &c,
 		provider: provider,
+		cancel:   cancel,
 	}
 
 	go service.registerProvider(ctx)
@@ -126,7 +134,11 @@ func registerMimeTypes(mappingFile string) error {
 func (s *service) registerProvider(ctx context.Context) {
 	// Give the appregistry service time to come up
 	// TODO(lopresti) we should register the appproviders after all other microservices
-	time.Sleep(3 * time.Second)
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(3 * time.Second):
+	}
 
 	log := appctx.GetLogger(ctx)
 	pInfo, err := s.provider.GetAppProviderInfo(ctx)
@@ -146,6 +158,24 @@ func (s *service) registerProvider(ctx context.Context) {
 		log.Info().Str("appprovider", s.conf.AppProviderURL).Interface("mimetypes", mimeTypes).Msg("appprovider supported mimetypes")
 	}
 
+	// register again periodically, registries expiring the
+	// providers consider the app provider down otherwise
+	ticker := time.NewTicker(time.Duration(s.conf.RegistrationInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		s.addAppProvider(ctx, pInfo)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *service) addAppProvider(ctx context.Context, pInfo *registrypb.ProviderInfo) {
+	log := appctx.GetLogger(ctx)
+
 	client, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
 	if err != nil {
 		log.Error().Err(err).Msgf("error registering app provider: could not get gateway client")
@@ -166,6 +196,7 @@ func (s *service) registerProvider(ctx context.Context) {
 }
 
 func (s *service) Close() error {
+	s.cancel()
 	return nil
 }
 
@@ -19,11 +19,15 @@
 package appregistry
 
 import (
+	"cmp"
 	"context"
+	"slices"
+	"strings"
 
 	registrypb "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/app"
 	"github.com/cs3org/reva/v3/pkg/app/registry/registry"
+	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/cs3org/reva/v3/pkg/errtypes"
 	"github.com/cs3org/reva/v3/pkg/plugin"
 	"github.com/cs3org/reva/v3/pkg/rgrpc"
@@ -43,7 +47,9 @@ func init() {
 }
 
 type svc struct {
-	reg app.Registry
+	reg           app.Registry
+	groupDefaults map[string]map[string]string
+	groupPriority []string
 }
 
 func (s *svc) Close() error {
@@ -59,8 +65,10 @@ func (s *svc) Register(ss *grpc.Server) {
 }
 
 type config struct {
-	Driver  string                    `mapstructure:"driver"`
-	Drivers map[string]map[string]any `mapstructure:"drivers"`
+	Driver        string                       `mapstructure:"driver"`
+	Drivers       map[string]map[string]any    `mapstructure:"drivers"`
+	GroupDefaults map[string]map[string]string `docs:"nil;The default apps of the members of a group, by group and mime type. They take precedence over the default apps of the driver." mapstructure:"group_defaults"`
+	GroupPriority []string                     `docs:"nil;The order in which the group defaults apply to the members of several groups. The groups not listed come next, in alphabetical order." mapstructure:"group_priority"`
 }
 
 func (c *config) ApplyDefaults() {
@@ -82,7 +90,9 @@ func New(ctx context.Context, m map[string]any) (rgrpc.Service, error) {
 	}
 
 	svc := &svc{
-		reg: reg,
+		reg:           reg,
+		groupDefaults: c.GroupDefaults,
+		groupPriority: c.GroupPriority,
 	}
 
 	return svc, nil
@@ -162,6 +172,13 @@ func (s *svc) ListSupportedMimeTypes(ctx context.Context, req *registrypb.ListSu
 }
 
 func (s *svc) GetDefaultAppProviderForMimeType(ctx context.Context, req *registrypb.GetDefaultAppProviderForMimeTypeRequest) (*registrypb.GetDefaultAppProviderForMimeTypeResponse, error) {
+	if provider, ok := s.groupDefaultProvider(ctx, req.MimeType); ok {
+		return &registrypb.GetDefaultAppProviderForMimeTypeResponse{
+			Status:   status.NewOK(ctx),
+			Provider: provider,
+		}, nil
+	}
+
 	provider, err := s.reg.GetDefaultProviderForMimeType(ctx, req.MimeType)
 	if err != nil {
 		return &registrypb.GetDefaultAppProviderForMimeTypeResponse{
@@ -176,6 +193,56 @@ func (s *svc) GetDefaultAppProviderForMimeType(ctx context.Context, req *registr
 	return res, nil
 }
 
+// groupDefaultProvider returns the default app configured for the first
+// group of the user having one for the mime type, as long as it is available.
+// The groups of the user are taken in the configured priority, as the order
+// in which the user groups are listed is not stable.
+func (s *svc) groupDefaultProvider(ctx context.Context, mimeType string) (*registrypb.ProviderInfo, bool) {
+	if len(s.groupDefaults) == 0 {
+		return nil, false
+	}
+	user, ok := appctx.ContextGetUser(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	log := appctx.GetLogger(ctx)
+	for _, g := range s.prioritize(user.Groups) {
+		def, ok := s.groupDefaults[g][mimeType]
+		if !ok {
+			continue
+		}
+		providers, err := s.reg.FindProviders(ctx, mimeType)
+		if err != nil {
+			log.Debug().Err(err).Str("group", g).Msg("default app of the group not available")
+			return nil, false
+		}
+		for _, p := range providers {
+			if p.Name == def || p.Address == def {
+				return p, true
+			}
+		}
+		log.Debug().Str("group", g).Str("app", def).Msg("default app of the group not available")
+	}
+	return nil, false
+}
+
+// prioritize returns the given groups sorted by priority: the groups of the
+// configured priority first, in its order, then the others alphabetically.
+func (s *svc) prioritize(groups []string) []string {
+	rank := func(g string) int {
+		if i := slices.Index(s.groupPriority, g); i >= 0 {
+			return i
+		}
+		return len(s.groupPriority)
+	}
+	sorted := slices.Clone(groups)
+	slices.SortFunc(sorted, func(a, b string) int {
+		return cmp.Or(cmp.Compare(rank(a), rank(b)), strings.Compare(a, b))
+	})
+	return sorted
+}
+
 func (s *svc) SetDefaultAppProviderForMimeType(ctx context.Context, req *registrypb.SetDefaultAppProviderForMimeTypeRequest) (*registrypb.SetDefaultAppProviderForMimeTypeResponse, error) {
 	err := s.reg.SetDefaultProviderForMimeType(ctx, req.MimeType, req.Provider)
 	if err != nil {
@@ -24,9 +24,11 @@ import (
 	"testing"
 
 	registrypb "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	rpcv1beta1 "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	providerv1beta1 "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/app/registry/static"
+	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -323,6 +325,63 @@ func Test_GetAppProviders(t *testing.T) {
 	}
 }
 
+func Test_GetDefaultAppProviderForMimeType(t *testing.T) {
+	rr, err := static.New(context.Background(), map[string]any{
+		"providers": []map[string]any{
+			{"name": "Collabora", "address": "collabora addr", "mimetypes": []string{"application/vnd.oasis.opendocument.text"}},
+			{"name": "OnlyOffice", "address": "onlyoffice addr", "mimetypes": []string{"application/vnd.oasis.opendocument.text"}},
+			{"name": "Text", "address": "text addr", "mimetypes": []string{"application/vnd.oasis.opendocument.text"}},
+		},
+		"mime_types": []map[string]string{
+			{"mime_type": "application/vnd.oasis.opendocument.text", "extension": "odt", "default_app": "Collabora"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not create registry error = %v", err)
+	}
+
+	ss := &svc{
+		reg: rr,
+		groupDefaults: map[string]map[string]string{
+			"physics":   {"application/vnd.oasis.opendocument.text": "OnlyOffice"},
+			"it":        {"application/vnd.oasis.opendocument.text": "Unavailable"},
+			"chemistry": {"application/vnd.oasis.opendocument.text": "Text"},
+			"biology":   {"application/vnd.oasis.opendocument.text": "Text"},
+		},
+		groupPriority: []string{"chemistry"},
+	}
+
+	tests := []struct {
+		name   string
+		groups []string
+		want   string
+	}{
+		{name: "no user groups", want: "collabora addr"},
+		{name: "group without default", groups: []string{"hr"}, want: "collabora addr"},
+		{name: "group default", groups: []string{"hr", "physics"}, want: "onlyoffice addr"},
+		{name: "unavailable group default", groups: []string{"it", "physics"}, want: "onlyoffice addr"},
+		{name: "only unavailable group default", groups: []string{"it"}, want: "collabora addr"},
+		{name: "prioritized group", groups: []string{"physics", "chemistry"}, want: "text addr"},
+		{name: "prioritized group listed first", groups: []string{"chemistry", "physics"}, want: "text addr"},
+		{name: "groups without priority", groups: []string{"physics", "biology"}, want: "text addr"},
+		{name: "groups without priority reversed", groups: []string{"biology", "physics"}, want: "text addr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := appctx.ContextSetUser(context.Background(), &userpb.User{Username: "einstein", Groups: tt.groups})
+			got, err := ss.GetDefaultAppProviderForMimeType(ctx, &registrypb.GetDefaultAppProviderForMimeTypeRequest{
+				MimeType: "application/vnd.oasis.opendocument.text",
+			})
+			if err != nil {
+				t.Fatalf("GetDefaultAppProviderForMimeType() error = %v", err)
+			}
+			assert.Equal(t, rpcv1beta1.Code_CODE_OK, got.Status.Code)
+			assert.Equal(t, tt.want, got.Provider.Address)
+		})
+	}
+}
+
 func TestNew(t *testing.T) {
 	tests := []struct {
 		name      string
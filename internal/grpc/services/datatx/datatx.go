@@ -133,6 +133,7 @@ func (s *service) CreateTransfer(ctx context.Context, req *datatx.CreateTransfer
 		DestTargetURI: req.DestTargetUri,
 		ShareID:       req.GetShareId().OpaqueId,
 		UserID:        userID,
+		Status:        txInfo.GetStatus(),
 	}
 	log.Debug().Interface("transfer", transfer).Msg("CreateTransfer")
 
@@ -175,6 +176,7 @@ func (s *service) GetTransferStatus(ctx context.Context, req *datatx.GetTransfer
 	}
 
 	txInfo.ShareId = &ocm.ShareId{OpaqueId: transfer.ShareID}
+	s.recordStatus(ctx, transfer, txInfo)
 
 	log.Debug().Interface("txInfo", txInfo).Msg("GetTransferStatus")
 	return &datatx.GetTransferStatusResponse{
@@ -211,6 +213,9 @@ func (s *service) CancelTransfer(ctx context.Context, req *datatx.CancelTransfer
 	}
 
 	txInfo.ShareId = &ocm.ShareId{OpaqueId: transfer.ShareID}
+	if !s.conf.RemoveOnCancel {
+		s.recordStatus(ctx, transfer, txInfo)
+	}
 
 	return &datatx.CancelTransferResponse{
 		Status: status.NewOK(ctx),
@@ -232,10 +237,17 @@ func (s *service) ListTransfers(ctx context.Context, req *datatx.ListTransfersRe
 
 	txInfos := []*datatx.TxInfo{}
 	for _, transfer := range transfers {
-		txInfos = append(txInfos, &datatx.TxInfo{
+		txInfo := &datatx.TxInfo{
 			Id:      &datatx.TxId{OpaqueId: transfer.TxID},
 			ShareId: &ocm.ShareId{OpaqueId: transfer.ShareID},
-		})
+			Status:  transfer.Status,
+		}
+		if err := txdriver.SetProgress(txInfo, transfer.Progress); err != nil {
+			return &datatx.ListTransfersResponse{
+				Status: status.NewInternal(ctx, err, "error listing transfers"),
+			}, err
+		}
+		txInfos = append(txInfos, txInfo)
 	}
 
 	return &datatx.ListTransfersResponse{
@@ -244,6 +256,23 @@ func (s *service) ListTransfers(ctx context.Context, req *datatx.ListTransfersRe
 	}, nil
 }
 
+// recordStatus persists the status and progress reported by the driver,
+// so that they can be listed without querying the driver.
+func (s *service) recordStatus(ctx context.Context, transfer *txdriver.Transfer, txInfo *datatx.TxInfo) {
+	log := appctx.GetLogger(ctx)
+	progress, err := txdriver.GetProgress(txInfo)
+	if err != nil {
+		log.Warn().Err(err).Str("transfer", transfer.TxID).Msg("datatx service: error reading transfer progress")
+	}
+	transfer.Status = txInfo.Status
+	if progress != nil {
+		transfer.Progress = progress
+	}
+	if err := s.storageDriver.StoreTransfer(transfer); err != nil {
+		log.Error().Err(err).Str("transfer", transfer.TxID).Msg("datatx service: error saving transfer status")
+	}
+}
+
 func (s *service) RetryTransfer(ctx context.Context, req *datatx.RetryTransferRequest) (*datatx.RetryTransferResponse, error) {
 	transfer, err := s.storageDriver.GetTransfer(req.TxId.OpaqueId)
 	if err != nil {
@@ -260,6 +289,7 @@ func (s *service) RetryTransfer(ctx context.Context, req *datatx.RetryTransferRe
 	}
 
 	txInfo.ShareId = &ocm.ShareId{OpaqueId: transfer.ShareID}
+	s.recordStatus(ctx, transfer, txInfo)
 
 	return &datatx.RetryTransferResponse{
 		Status: status.NewOK(ctx),
@@ -29,9 +29,11 @@ import (
 	registry "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	preferences "github.com/cs3org/go-cs3apis/cs3/preferences/v1beta1"
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	storageprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/app"
 	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/cs3org/reva/v3/pkg/errtypes"
 	"github.com/cs3org/reva/v3/pkg/rgrpc/status"
@@ -219,9 +221,17 @@ func (s *svc) findAppProvider(ctx context.Context, ri *storageprovider.ResourceI
 	// the behaviour will change from download to open the file with the app.
 	if app == "" {
 		// If app is empty means that we need to rely on "default" behaviour.
-		// We currently do not have user preferences implemented so the only default
-		// we can currently enforce is one configured by the system admins, the
-		// "system default".
+		// The app chosen by the user comes first, then the default of the
+		// registry, which is the one of the groups of the user if configured
+		// or the "system default" set by the system admins.
+		if userApp := s.userDefaultApp(ctx, ri.MimeType); userApp != "" {
+			p, err := s.findAppProviderByName(ctx, c, ri, userApp)
+			if err == nil {
+				return p, nil
+			}
+			appctx.GetLogger(ctx).Warn().Err(err).Str("app", userApp).Msg("default app of the user not available, falling back to the registry default")
+		}
+
 		// If a default is not set we raise an error rather that giving the user the first provider in the list
 		// as the list is built on init time and is not deterministic, giving the user different results on service
 		// reload.
@@ -253,6 +263,27 @@ func (s *svc) findAppProvider(ctx context.Context, ri *storageprovider.ResourceI
 	}
 
 	// app has been forced and is set, we try to get an app provider that can satisfy it
+	return s.findAppProviderByName(ctx, c, ri, app)
+}
+
+// userDefaultApp returns the app the user chose for the mime type, if any.
+func (s *svc) userDefaultApp(ctx context.Context, mimeType string) string {
+	c, err := pool.GetPreferencesClient(pool.Endpoint(s.c.PreferencesEndpoint))
+	if err != nil {
+		appctx.GetLogger(ctx).Warn().Err(err).Msg("gateway: error getting preferences client")
+		return ""
+	}
+
+	res, err := c.GetKey(ctx, &preferences.GetKeyRequest{
+		Key: &preferences.PreferenceKey{Namespace: app.DefaultAppsNamespace, Key: mimeType},
+	})
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+		return ""
+	}
+	return res.Val
+}
+
+func (s *svc) findAppProviderByName(ctx context.Context, c registry.RegistryAPIClient, ri *storageprovider.ResourceInfo, app string) (*registry.ProviderInfo, error) {
 	// Note that we ask for the list of all available providers for a given resource
 	// even though we're only interested into the one set by the "app" parameter.
 	// A better call will be to issue a (to be added) GetAppProviderByName(app) method
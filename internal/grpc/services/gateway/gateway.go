@@ -201,6 +201,8 @@ func (s *svc) UnprotectedEndpoints() []string {
 		"/cs3.gateway.v1beta1.GatewayAPI/GetAuthProvider",
 		"/cs3.gateway.v1beta1.GatewayAPI/ListAuthProviders",
 		"/cs3.gateway.v1beta1.GatewayAPI/CreateOCMIncomingShare",
+		"/cs3.gateway.v1beta1.GatewayAPI/UpdateOCMIncomingShare",
+		"/cs3.gateway.v1beta1.GatewayAPI/DeleteOCMIncomingShare",
 		"/cs3.gateway.v1beta1.GatewayAPI/AcceptInvite",
 		"/cs3.gateway.v1beta1.GatewayAPI/GetAcceptedUser",
 		"/cs3.gateway.v1beta1.GatewayAPI/IsProviderAllowed",
@@ -218,6 +220,8 @@ func (s *svc) UnprotectedEndpoints() []string {
 		"/cs3.auth.registry.v1beta1.RegistryAPI/GetAuthProvider",
 		"/cs3.auth.registry.v1beta1.RegistryAPI/ListAuthProviders",
 		"/cs3.ocm.incoming.v1beta1.OcmIncomingAPI/CreateOCMIncomingShare",
+		"/cs3.ocm.incoming.v1beta1.OcmIncomingAPI/UpdateOCMIncomingShare",
+		"/cs3.ocm.incoming.v1beta1.OcmIncomingAPI/DeleteOCMIncomingShare",
 		"/cs3.ocm.invite.v1beta1.InviteAPI/AcceptInvite",
 		"/cs3.ocm.invite.v1beta1.InviteAPI/GetAcceptedUser",
 		"/cs3.ocm.provider.v1beta1.ProviderAPI/IsProviderAllowed",
@@ -0,0 +1,67 @@
+// Copyright 2018-2026 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"context"
+
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/status"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/sharereport"
+	"github.com/pkg/errors"
+)
+
+// shareReport builds the share report asked for in the opaque of a
+// ListSharesRequest, through the gateway API as the user in ctx.
+func (s *svc) shareReport(ctx context.Context, req *collaboration.ListSharesRequest) (*collaboration.ListSharesResponse, error) {
+	ref, opts, err := sharereport.RequestedReport(req)
+	if err != nil {
+		return &collaboration.ListSharesResponse{
+			Status: status.NewInvalidArg(ctx, err.Error()),
+		}, nil
+	}
+
+	c, err := pool.GetGatewayServiceClient(pool.Endpoint(sharedconf.GetGatewaySVC("")))
+	if err != nil {
+		err = errors.Wrap(err, "gateway: error calling GetGatewayServiceClient")
+		return &collaboration.ListSharesResponse{
+			Status: status.NewInternal(ctx, err, "error getting gateway client"),
+		}, nil
+	}
+
+	report, err := sharereport.Build(ctx, c, ref, opts)
+	if err != nil {
+		return &collaboration.ListSharesResponse{
+			Status: status.NewStatusFromErrType(ctx, "error building share report", err),
+		}, nil
+	}
+	opaque, err := sharereport.ReportOpaque(report)
+	if err != nil {
+		return &collaboration.ListSharesResponse{
+			Status: status.NewInternal(ctx, err, "error encoding share report"),
+		}, nil
+	}
+
+	return &collaboration.ListSharesResponse{
+		Status: status.NewOK(ctx),
+		Opaque: opaque,
+	}, nil
+}
@@ -32,6 +32,7 @@ import (
 	registry "github.com/cs3org/go-cs3apis/cs3/storage/registry/v1beta1"
 	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/auth/scope"
 	"github.com/golang-jwt/jwt/v5"
 
 	"github.com/cs3org/reva/v3/pkg/errtypes"
@@ -50,9 +51,12 @@ type transferClaims struct {
 	jwt.RegisteredClaims
 	Target     string `json:"target"`
 	VersionKey string `json:"version_key,omitempty"`
+	// Link is the ID of the public link the transfer goes through, for the
+	// data gateway to count it against the limits of the link.
+	Link string `json:"link,omitempty"`
 }
 
-func (s *svc) sign(_ context.Context, target, versionKey string) (string, error) {
+func (s *svc) sign(_ context.Context, target, versionKey, link string) (string, error) {
 	// Tus sends a separate request to the datagateway service for every chunk.
 	// For large files, this can take a long time, so we extend the expiration
 	ttl := time.Duration(s.c.TransferExpires) * time.Second
@@ -61,9 +65,12 @@ func (s *svc) sign(_ context.Context, target, versionKey string) (string, error)
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			Audience:  jwt.ClaimStrings{"reva"},
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			// the downloads through a link are counted once per token
+			ID: uuid.New().String(),
 		},
 		Target:     target,
 		VersionKey: versionKey,
+		Link:       link,
 	}
 
 	t := jwt.NewWithClaims(jwt.GetSigningMethod("HS256"), claims)
@@ -144,7 +151,7 @@ func (s *svc) InitiateFileDownload(ctx context.Context, req *provider.InitiateFi
 
 			// TODO(labkode): calculate signature of the whole request? we only sign the URI now. Maybe worth https://tools.ietf.org/html/draft-cavage-http-signatures-11
 			target := u.String()
-			token, err := s.sign(ctx, target, versionKey(req))
+			token, err := s.sign(ctx, target, versionKey(req), publicLinkID(ctx))
 			if err != nil {
 				return &gateway.InitiateFileDownloadResponse{
 					Status: status.NewInternal(ctx, err, "error creating signature for download"),
@@ -174,6 +181,20 @@ func versionKey(req *provider.InitiateFileDownloadRequest) string {
 	return string(val.Value)
 }
 
+// publicLinkID returns the ID of the public link the request in ctx was
+// authenticated with, if any.
+func publicLinkID(ctx context.Context) string {
+	scopes, ok := appctx.ContextGetScopes(ctx)
+	if !ok {
+		return ""
+	}
+	shares, err := scope.GetPublicSharesFromScopes(scopes)
+	if err != nil || len(shares) != 1 {
+		return ""
+	}
+	return shares[0].GetId().GetOpaqueId()
+}
+
 func (s *svc) InitiateFileUpload(ctx context.Context, req *provider.InitiateFileUploadRequest) (*gateway.InitiateFileUploadResponse, error) {
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
@@ -216,7 +237,7 @@ func (s *svc) InitiateFileUpload(ctx context.Context, req *provider.InitiateFile
 
 			// TODO(labkode): calculate signature of the whole request? we only sign the URI now. Maybe worth https://tools.ietf.org/html/draft-cavage-http-signatures-11
 			target := u.String()
-			token, err := s.sign(ctx, target, "")
+			token, err := s.sign(ctx, target, "", "")
 			if err != nil {
 				return &gateway.InitiateFileUploadResponse{
 					Status: status.NewInternal(ctx, err, "error creating signature for upload"),
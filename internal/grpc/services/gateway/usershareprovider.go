@@ -33,6 +33,7 @@ import (
 	"github.com/cs3org/reva/v3/pkg/appctx"
 	revashare "github.com/cs3org/reva/v3/pkg/share"
 	"github.com/cs3org/reva/v3/pkg/sharehierarchy"
+	"github.com/cs3org/reva/v3/pkg/sharereport"
 	"github.com/cs3org/reva/v3/pkg/utils/resourceid"
 
 	"github.com/cs3org/reva/v3/pkg/errtypes"
@@ -250,6 +251,10 @@ func (s *svc) getShare(ctx context.Context, req *collaboration.GetShareRequest)
 
 // TODO(labkode): read GetShare comment.
 func (s *svc) ListShares(ctx context.Context, req *collaboration.ListSharesRequest) (*collaboration.ListSharesResponse, error) {
+	if sharereport.IsReportRequest(req) {
+		return s.shareReport(ctx, req)
+	}
+
 	c, err := pool.GetUserShareProviderClient(pool.Endpoint(s.c.UserShareProviderEndpoint))
 	if err != nil {
 		err = errors.Wrap(err, "gateway: error calling GetUserShareProviderClient")
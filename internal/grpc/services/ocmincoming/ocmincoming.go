@@ -25,10 +25,14 @@ import (
 	"fmt"
 	"time"
 
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	ocmincoming "github.com/cs3org/go-cs3apis/cs3/ocm/incoming/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
 	providerpb "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/internal/http/services/opencloudmesh/ocmd"
+	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/cs3org/reva/v3/pkg/errtypes"
 	"github.com/cs3org/reva/v3/pkg/ocm/share"
 	"github.com/cs3org/reva/v3/pkg/ocm/share/repository/registry"
@@ -101,7 +105,12 @@ func (s *service) Close() error {
 }
 
 func (s *service) UnprotectedEndpoints() []string {
-	return []string{"/cs3.ocm.incoming.v1beta1.OcmIncomingAPI/CreateOCMIncomingShare"}
+	// notifications are authenticated by the secret of the share they refer to
+	return []string{
+		"/cs3.ocm.incoming.v1beta1.OcmIncomingAPI/CreateOCMIncomingShare",
+		"/cs3.ocm.incoming.v1beta1.OcmIncomingAPI/UpdateOCMIncomingShare",
+		"/cs3.ocm.incoming.v1beta1.OcmIncomingAPI/DeleteOCMIncomingShare",
+	}
 }
 
 // CreateOCMIncomingShare is called when a remote OCM request comes into this reva instance.
@@ -147,10 +156,151 @@ func (s *service) CreateOCMIncomingShare(ctx context.Context, req *ocmincoming.C
 	}, nil
 }
 
+// UpdateOCMIncomingShare processes a notification sent by a remote OCM server
+// about a share: either a permission change of a share received by one of our
+// users, or the acceptance or decline of a share created by one of our users.
 func (s *service) UpdateOCMIncomingShare(ctx context.Context, req *ocmincoming.UpdateOCMIncomingShareRequest) (*ocmincoming.UpdateOCMIncomingShareResponse, error) {
-	return nil, errtypes.NotSupported("not implemented")
+	n, sender, err := ocmd.NotificationFromOpaque(req.Opaque)
+	if err != nil {
+		return &ocmincoming.UpdateOCMIncomingShareResponse{
+			Status: status.NewInvalidArg(ctx, err.Error()),
+		}, nil
+	}
+	log := appctx.GetLogger(ctx).With().Str("type", n.NotificationType).Str("provider_id", n.ProviderID).Logger()
+
+	switch n.NotificationType {
+	case ocmd.NotificationShareChangePermission:
+		rs, err := s.getReceivedShare(ctx, n, sender)
+		if err != nil {
+			return &ocmincoming.UpdateOCMIncomingShareResponse{
+				Status: notificationErrorStatus(ctx, err),
+			}, nil
+		}
+		if n.Notification == nil || len(n.Notification.Permissions) == 0 {
+			return &ocmincoming.UpdateOCMIncomingShareResponse{
+				Status: status.NewInvalidArg(ctx, "missing permissions"),
+			}, nil
+		}
+		perms := ocmd.WebDAVSharePermissions(n.Notification.Permissions)
+		if err := s.repo.UpdateReceivedSharePermissions(ctx, rs.Id, perms); err != nil {
+			return &ocmincoming.UpdateOCMIncomingShareResponse{
+				Status: notificationErrorStatus(ctx, err),
+			}, nil
+		}
+		log.Info().Str("share_id", rs.Id.OpaqueId).Strs("permissions", n.Notification.Permissions).Msg("updated permissions of received OCM share")
+
+	case ocmd.NotificationShareAccepted, ocmd.NotificationShareDeclined:
+		sh, err := s.getShare(ctx, n, sender)
+		if err != nil {
+			return &ocmincoming.UpdateOCMIncomingShareResponse{
+				Status: notificationErrorStatus(ctx, err),
+			}, nil
+		}
+		if n.NotificationType == ocmd.NotificationShareAccepted {
+			// shares do not track the state on the recipient side
+			log.Info().Str("share_id", sh.Id.OpaqueId).Msg("OCM share accepted by the remote recipient")
+			break
+		}
+		ref := &ocm.ShareReference{Spec: &ocm.ShareReference_Id{Id: sh.Id}}
+		if err := s.repo.DeleteShare(ctx, &userpb.User{Id: sh.Creator}, ref); err != nil {
+			return &ocmincoming.UpdateOCMIncomingShareResponse{
+				Status: notificationErrorStatus(ctx, err),
+			}, nil
+		}
+		log.Info().Str("share_id", sh.Id.OpaqueId).Msg("deleted OCM share declined by the remote recipient")
+
+	default:
+		return &ocmincoming.UpdateOCMIncomingShareResponse{
+			Status: status.NewInvalidArg(ctx, "notification type not supported: "+n.NotificationType),
+		}, nil
+	}
+
+	return &ocmincoming.UpdateOCMIncomingShareResponse{
+		Status: status.NewOK(ctx),
+	}, nil
 }
 
+// DeleteOCMIncomingShare processes a notification sent by a remote OCM server
+// when it unshares a share received by one of our users.
 func (s *service) DeleteOCMIncomingShare(ctx context.Context, req *ocmincoming.DeleteOCMIncomingShareRequest) (*ocmincoming.DeleteOCMIncomingShareResponse, error) {
-	return nil, errtypes.NotSupported("not implemented")
+	n, sender, err := ocmd.NotificationFromOpaque(req.Opaque)
+	if err != nil {
+		return &ocmincoming.DeleteOCMIncomingShareResponse{
+			Status: status.NewInvalidArg(ctx, err.Error()),
+		}, nil
+	}
+
+	rs, err := s.getReceivedShare(ctx, n, sender)
+	if err != nil {
+		return &ocmincoming.DeleteOCMIncomingShareResponse{
+			Status: notificationErrorStatus(ctx, err),
+		}, nil
+	}
+	if err := s.repo.DeleteReceivedShare(ctx, rs.Id); err != nil {
+		return &ocmincoming.DeleteOCMIncomingShareResponse{
+			Status: notificationErrorStatus(ctx, err),
+		}, nil
+	}
+	appctx.GetLogger(ctx).Info().Str("provider_id", n.ProviderID).Str("share_id", rs.Id.OpaqueId).Msg("deleted received OCM share unshared by the remote owner")
+
+	return &ocmincoming.DeleteOCMIncomingShareResponse{
+		Status: status.NewOK(ctx),
+	}, nil
+}
+
+// getReceivedShare returns the received share a notification refers to,
+// authenticated by its shared secret and by the signature of its provider.
+func (s *service) getReceivedShare(ctx context.Context, n *ocmd.NotificationRequest, sender *ocmd.NotificationSender) (*ocm.ReceivedShare, error) {
+	if n.Notification == nil || n.Notification.SharedSecret == "" {
+		return nil, errtypes.PermissionDenied("missing shared secret")
+	}
+	shares, err := s.repo.ListReceivedSharesByRemoteID(ctx, n.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rs := range shares {
+		if !share.HasSharedSecret(rs, n.Notification.SharedSecret) {
+			continue
+		}
+		if err := sender.Check(rs.Creator.GetIdp(), rs.Owner.GetIdp()); err != nil {
+			return nil, err
+		}
+		return rs, nil
+	}
+	return nil, share.ErrShareNotFound
+}
+
+// getShare returns the share created by one of our users a notification
+// refers to, authenticated by its token and by the signature of the provider
+// of the recipient.
+func (s *service) getShare(ctx context.Context, n *ocmd.NotificationRequest, sender *ocmd.NotificationSender) (*ocm.Share, error) {
+	if n.Notification == nil || n.Notification.SharedSecret == "" {
+		return nil, errtypes.PermissionDenied("missing shared secret")
+	}
+	sh, err := s.repo.GetShare(ctx, nil, &ocm.ShareReference{
+		Spec: &ocm.ShareReference_Token{Token: n.Notification.SharedSecret},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sh.Id.GetOpaqueId() != n.ProviderID {
+		return nil, share.ErrShareNotFound
+	}
+	if err := sender.Check(sh.Grantee.GetUserId().GetIdp()); err != nil {
+		return nil, err
+	}
+	return sh, nil
+}
+
+func notificationErrorStatus(ctx context.Context, err error) *rpc.Status {
+	switch err.(type) {
+	case errtypes.IsNotFound:
+		return status.NewNotFound(ctx, "share not found")
+	case errtypes.IsPermissionDenied:
+		return status.NewPermissionDenied(ctx, err, err.Error())
+	case errtypes.IsBadRequest:
+		return status.NewInvalidArg(ctx, err.Error())
+	default:
+		return status.NewInternal(ctx, err, "error processing notification")
+	}
 }
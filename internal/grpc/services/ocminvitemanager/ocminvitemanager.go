@@ -58,6 +58,8 @@ type config struct {
 	TokenExpiration   string                    `mapstructure:"token_expiration"`
 	OCMClientTimeout  int                       `mapstructure:"ocm_timeout"`
 	OCMClientInsecure bool                      `mapstructure:"ocm_insecure"`
+	OCMSigningKey     string                    `docs:";PEM private key used to sign OCM requests"            mapstructure:"ocm_signing_key"`
+	OCMSigningKeyID   string                    `docs:";The key id published in the OCM discovery"            mapstructure:"ocm_signing_key_id"`
 	GatewaySVC        string                    `mapstructure:"gatewaysvc"                                    validate:"required"`
 	ProviderDomain    string                    `docs:"The same domain registered in the provider authorizer" mapstructure:"provider_domain" validate:"required"`
 
@@ -110,10 +112,17 @@ func New(ctx context.Context, m map[string]any) (rgrpc.Service, error) {
 		return nil, err
 	}
 
+	ocmClient := ocmd.NewClient(time.Duration(c.OCMClientTimeout)*time.Second, c.OCMClientInsecure)
+	if c.OCMSigningKey != "" {
+		if err := ocmClient.EnableSigning(c.OCMSigningKeyID, c.OCMSigningKey); err != nil {
+			return nil, err
+		}
+	}
+
 	service := &service{
 		conf:      &c,
 		repo:      repo,
-		ocmClient: ocmd.NewClient(time.Duration(c.OCMClientTimeout)*time.Second, c.OCMClientInsecure),
+		ocmClient: ocmClient,
 	}
 	return service, nil
 }
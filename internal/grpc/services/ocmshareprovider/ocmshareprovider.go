@@ -30,6 +30,7 @@ import (
 
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
 	providerpb "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
@@ -75,6 +76,8 @@ type config struct {
 	EmbeddedDrivers map[string]map[string]any `mapstructure:"embedded_drivers"`
 	ClientTimeout   int                       `mapstructure:"client_timeout"`
 	ClientInsecure  bool                      `mapstructure:"client_insecure"`
+	SigningKey      string                    `docs:";PEM private key used to sign OCM requests"            mapstructure:"signing_key"`
+	SigningKeyID    string                    `docs:";The key id published in the OCM discovery"            mapstructure:"signing_key_id"`
 	GatewaySVC      string                    `mapstructure:"gatewaysvc"                                    validate:"required"`
 	ProviderDomain  string                    `docs:"The same domain registered in the provider authorizer" mapstructure:"provider_domain" validate:"required"`
 	WebDAVEndpoint  string                    `mapstructure:"webdav_endpoint"                               validate:"required"`
@@ -147,6 +150,11 @@ func New(ctx context.Context, m map[string]any) (rgrpc.Service, error) {
 	walker := walker.NewWalker(gateway)
 
 	ocmcl := ocmd.NewClient(time.Duration(c.ClientTimeout)*time.Second, c.ClientInsecure)
+	if c.SigningKey != "" {
+		if err := ocmcl.EnableSigning(c.SigningKeyID, c.SigningKey); err != nil {
+			return nil, err
+		}
+	}
 	service := &service{
 		conf:        &c,
 		repo:        repo,
@@ -176,7 +184,7 @@ func formatOCMUser(u *userpb.UserId) string {
 }
 
 func getResourceType(info *providerpb.ResourceInfo) string {
-	switch info.Type {
+	switch info.GetType() {
 	case providerpb.ResourceType_RESOURCE_TYPE_FILE:
 		return "file"
 	case providerpb.ResourceType_RESOURCE_TYPE_CONTAINER:
@@ -185,6 +193,30 @@ func getResourceType(info *providerpb.ResourceInfo) string {
 	return "unknown"
 }
 
+// resourceType returns the OCM resource type of the shared resource, as
+// notified to the remote providers.
+func (s *service) resourceType(ctx context.Context, id *providerpb.ResourceId) string {
+	statRes, err := s.gateway.Stat(ctx, &providerpb.StatRequest{
+		Ref: &providerpb.Reference{ResourceId: id},
+	})
+	if err != nil || statRes.Status.Code != rpc.Code_CODE_OK {
+		appctx.GetLogger(ctx).Warn().Err(err).Any("resource_id", id).Msg("error getting the type of a shared resource")
+		return getResourceType(nil)
+	}
+	return getResourceType(statRes.Info)
+}
+
+// receivedResourceType returns the OCM resource type of a received share.
+func receivedResourceType(rs *ocm.ReceivedShare) string {
+	switch rs.SharedResourceType {
+	case ocm.SharedResourceType_SHARE_RESOURCE_TYPE_FILE:
+		return "file"
+	case ocm.SharedResourceType_SHARE_RESOURCE_TYPE_CONTAINER:
+		return "folder"
+	}
+	return "unknown"
+}
+
 func (s *service) webdavURL(share *ocm.Share) string {
 	// the url is expected to be in the form https://ourserver/remote.php/dav/ocm/{ShareId}, see c.WebdavRoot in ocmprovider.go
 	// TODO(lopresti) take the root from http.services.wellknown.ocmprovider's config
@@ -192,17 +224,21 @@ func (s *service) webdavURL(share *ocm.Share) string {
 	return p
 }
 
-func (s *service) getWebdavProtocol(share *ocm.Share, m *ocm.AccessMethod_WebdavOptions) *ocmd.WebDAV {
+// webdavPermissions returns the OCM webdav permissions matching the given ones.
+func webdavPermissions(p *providerpb.ResourcePermissions) []string {
 	var perms []string
-	if m.WebdavOptions.Permissions.InitiateFileDownload {
+	if p.InitiateFileDownload {
 		perms = append(perms, "read")
 	}
-	if m.WebdavOptions.Permissions.InitiateFileUpload {
+	if p.InitiateFileUpload {
 		perms = append(perms, "write")
 	}
+	return perms
+}
 
+func (s *service) getWebdavProtocol(share *ocm.Share, m *ocm.AccessMethod_WebdavOptions) *ocmd.WebDAV {
 	return &ocmd.WebDAV{
-		Permissions:  perms,
+		Permissions:  webdavPermissions(m.WebdavOptions.Permissions),
 		Requirements: m.WebdavOptions.Requirements,
 		URI:          s.webdavURL(share),
 		SharedSecret: share.Token,
@@ -459,9 +495,14 @@ func (s *service) CreateOCMShare(ctx context.Context, req *ocm.CreateOCMShareReq
 }
 
 func (s *service) RemoveOCMShare(ctx context.Context, req *ocm.RemoveOCMShareRequest) (*ocm.RemoveOCMShareResponse, error) {
-	// TODO (gdelmont): notify the remote provider using the /notification ocm endpoint
-	// https://cs3org.github.io/OCM-API/docs.html?branch=develop&repo=OCM-API&user=cs3org#/paths/~1notifications/post
 	user := appctx.ContextMustGetUser(ctx)
+	// the share and the type of its resource are needed to notify the remote
+	// provider once it is removed, errors are reported by the deletion below
+	ocmshare, _ := s.repo.GetShare(ctx, user, req.Ref)
+	var resType string
+	if ocmshare != nil {
+		resType = s.resourceType(ctx, ocmshare.ResourceId)
+	}
 	if err := s.repo.DeleteShare(ctx, user, req.Ref); err != nil {
 		if errors.Is(err, share.ErrShareNotFound) {
 			return &ocm.RemoveOCMShareResponse{
@@ -473,6 +514,17 @@ func (s *service) RemoveOCMShare(ctx context.Context, req *ocm.RemoveOCMShareReq
 		}, nil
 	}
 
+	if ocmshare != nil {
+		s.notify(ctx, ocmshare.Grantee.GetUserId().GetIdp(), &ocmd.NotificationRequest{
+			NotificationType: ocmd.NotificationShareUnshared,
+			ResourceType:     resType,
+			ProviderID:       ocmshare.Id.OpaqueId,
+			Notification: &ocmd.Notification{
+				SharedSecret: ocmshare.Token,
+			},
+		})
+	}
+
 	return &ocm.RemoveOCMShareResponse{
 		Status: status.NewOK(ctx),
 	}, nil
@@ -527,12 +579,22 @@ func (s *service) GetOCMShareByToken(ctx context.Context, req *ocm.GetOCMShareBy
 
 func (s *service) ListOCMShares(ctx context.Context, req *ocm.ListOCMSharesRequest) (*ocm.ListOCMSharesResponse, error) {
 	user := appctx.ContextMustGetUser(ctx)
+	owner := ownerFilter(req.Filters)
+	foreign := owner != nil && !utils.UserEqual(owner, user.Id)
+	if foreign {
+		// the shares of another owner, e.g. on a project space, are
+		// listed to whoever may list the grants of their resources
+		user = &userpb.User{Id: owner}
+	}
 	shares, err := s.repo.ListShares(ctx, user, req.Filters)
 	if err != nil {
 		return &ocm.ListOCMSharesResponse{
 			Status: status.NewInternal(ctx, err, "error listing shares"),
 		}, nil
 	}
+	if foreign {
+		shares = s.withListableGrants(ctx, shares)
+	}
 
 	res := &ocm.ListOCMSharesResponse{
 		Status: status.NewOK(ctx),
@@ -541,6 +603,38 @@ func (s *service) ListOCMShares(ctx context.Context, req *ocm.ListOCMSharesReque
 	return res, nil
 }
 
+// ownerFilter returns the owner the shares are filtered by, if any.
+func ownerFilter(filters []*ocm.ListOCMSharesRequest_Filter) *userpb.UserId {
+	for _, f := range filters {
+		if f.Type == ocm.ListOCMSharesRequest_Filter_TYPE_OWNER {
+			return f.GetOwner()
+		}
+	}
+	return nil
+}
+
+// withListableGrants keeps the shares on the resources the user in ctx is
+// allowed to list the grants of.
+func (s *service) withListableGrants(ctx context.Context, shares []*ocm.Share) []*ocm.Share {
+	allowed := map[string]bool{}
+	var listable []*ocm.Share
+	for _, sh := range shares {
+		key := sh.GetResourceId().GetStorageId() + "!" + sh.GetResourceId().GetOpaqueId()
+		ok, checked := allowed[key]
+		if !checked {
+			statRes, err := s.gateway.Stat(ctx, &providerpb.StatRequest{
+				Ref: &providerpb.Reference{ResourceId: sh.ResourceId},
+			})
+			ok = err == nil && statRes.Status.Code == rpc.Code_CODE_OK && statRes.Info.GetPermissionSet().GetListGrants()
+			allowed[key] = ok
+		}
+		if ok {
+			listable = append(listable, sh)
+		}
+	}
+	return listable
+}
+
 func (s *service) UpdateOCMShare(ctx context.Context, req *ocm.UpdateOCMShareRequest) (*ocm.UpdateOCMShareResponse, error) {
 	user := appctx.ContextMustGetUser(ctx)
 	if len(req.Field) == 0 {
@@ -548,7 +642,7 @@ func (s *service) UpdateOCMShare(ctx context.Context, req *ocm.UpdateOCMShareReq
 			Status: status.NewOK(ctx),
 		}, nil
 	}
-	_, err := s.repo.UpdateShare(ctx, user, req.Ref, req.Field...)
+	updated, err := s.repo.UpdateShare(ctx, user, req.Ref, req.Field...)
 	if err != nil {
 		if errors.Is(err, share.ErrShareNotFound) {
 			return &ocm.UpdateOCMShareResponse{
@@ -560,6 +654,13 @@ func (s *service) UpdateOCMShare(ctx context.Context, req *ocm.UpdateOCMShareReq
 		}, nil
 	}
 
+	for _, f := range req.Field {
+		if f.GetAccessMethods() != nil {
+			s.notifyPermissionChange(ctx, updated)
+			break
+		}
+	}
+
 	res := &ocm.UpdateOCMShareResponse{
 		Status: status.NewOK(ctx),
 	}
@@ -617,6 +718,15 @@ func (s *service) UpdateReceivedOCMShare(ctx context.Context, req *ocm.UpdateRec
 		}, nil
 	}
 
+	if slices.Contains(req.UpdateMask.GetPaths(), "state") {
+		switch req.Share.State {
+		case ocm.ShareState_SHARE_STATE_ACCEPTED:
+			s.notifyReceivedShare(ctx, user, req.Share.Id, ocmd.NotificationShareAccepted)
+		case ocm.ShareState_SHARE_STATE_REJECTED:
+			s.notifyReceivedShare(ctx, user, req.Share.Id, ocmd.NotificationShareDeclined)
+		}
+	}
+
 	res := &ocm.UpdateReceivedOCMShareResponse{
 		Status: status.NewOK(ctx),
 	}
@@ -677,7 +787,9 @@ func (s *service) processEmbeddedShare(ctx context.Context, user *userpb.User, r
 		recvShare.State = ocm.ShareState_SHARE_STATE_ACCEPTED
 		if _, err := s.repo.UpdateReceivedShare(detached, user, recvShare, mask); err != nil {
 			log.Error().Err(err).Msg("error marking received share as accepted after embedded transfer")
+			return
 		}
+		s.notifyReceivedShare(detached, user, recvShare.Id, ocmd.NotificationShareAccepted)
 	}
 
 	if err := s.transferrer.Process(ctx, payload, recvShare.Destination, onComplete); err != nil {
@@ -711,3 +823,78 @@ func (s *service) GetReceivedOCMShare(ctx context.Context, req *ocm.GetReceivedO
 	}
 	return res, nil
 }
+
+// notify sends a notification to the OCM server of the given domain.
+// Notifications are best effort: failures are logged and do not affect
+// the operation that triggered them.
+func (s *service) notify(ctx context.Context, domain string, n *ocmd.NotificationRequest) {
+	log := appctx.GetLogger(ctx).With().Str("domain", domain).Str("type", n.NotificationType).Str("provider_id", n.ProviderID).Logger()
+	res, err := s.gateway.GetInfoByDomain(ctx, &ocmprovider.GetInfoByDomainRequest{Domain: domain})
+	if err != nil {
+		log.Error().Err(err).Msg("error getting remote OCM provider info, notification not sent")
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		log.Error().Str("status", res.Status.Message).Msg("error getting remote OCM provider info, notification not sent")
+		return
+	}
+	endpoint, err := ocmim.GetOCMEndpoint(res.ProviderInfo)
+	if err != nil {
+		log.Error().Err(err).Msg("notification not sent")
+		return
+	}
+	if err := s.client.NewNotification(ctx, endpoint, n); err != nil {
+		log.Error().Err(err).Msg("error sending OCM notification")
+		return
+	}
+	log.Debug().Msg("OCM notification sent")
+}
+
+// notifyPermissionChange notifies the provider of the recipient of a share
+// about the new permissions of its webdav access method.
+func (s *service) notifyPermissionChange(ctx context.Context, ocmshare *ocm.Share) {
+	for _, m := range ocmshare.AccessMethods {
+		if dav := m.GetWebdavOptions(); dav != nil {
+			s.notify(ctx, ocmshare.Grantee.GetUserId().GetIdp(), &ocmd.NotificationRequest{
+				NotificationType: ocmd.NotificationShareChangePermission,
+				ResourceType:     s.resourceType(ctx, ocmshare.ResourceId),
+				ProviderID:       ocmshare.Id.OpaqueId,
+				Notification: &ocmd.Notification{
+					SharedSecret: ocmshare.Token,
+					Permissions:  webdavPermissions(dav.Permissions),
+				},
+			})
+			return
+		}
+	}
+}
+
+// notifyReceivedShare notifies the provider of the sender of a received share
+// that it was accepted or declined by its recipient.
+func (s *service) notifyReceivedShare(ctx context.Context, user *userpb.User, id *ocm.ShareId, notificationType string) {
+	rs, err := s.repo.GetReceivedShare(ctx, user, &ocm.ShareReference{
+		Spec: &ocm.ShareReference_Id{Id: id},
+	})
+	if err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Str("share_id", id.GetOpaqueId()).Msg("error getting received share, notification not sent")
+		return
+	}
+	var secret string
+	for _, p := range rs.Protocols {
+		if dav := p.GetWebdavOptions(); dav != nil {
+			secret = dav.SharedSecret
+			break
+		}
+		if app := p.GetWebappOptions(); app != nil && secret == "" {
+			secret = app.SharedSecret
+		}
+	}
+	s.notify(ctx, rs.Creator.GetIdp(), &ocmd.NotificationRequest{
+		NotificationType: notificationType,
+		ResourceType:     receivedResourceType(rs),
+		ProviderID:       rs.RemoteShareId,
+		Notification: &ocmd.Notification{
+			SharedSecret: secret,
+		},
+	})
+}
@@ -20,6 +20,7 @@ package preferences
 
 import (
 	"context"
+	"slices"
 
 	preferencespb "github.com/cs3org/go-cs3apis/cs3/preferences/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/errtypes"
@@ -45,6 +46,9 @@ func init() {
 type config struct {
 	Driver  string                    `mapstructure:"driver"`
 	Drivers map[string]map[string]any `mapstructure:"drivers"`
+	// ReservedNamespaces are denied to the users on top of the
+	// ones reserved by reva itself, e.g. totp.
+	ReservedNamespaces []string `mapstructure:"reserved_namespaces"`
 }
 
 func (c *config) ApplyDefaults() {
@@ -95,8 +99,32 @@ func (s *service) Register(ss *grpc.Server) {
 	preferencespb.RegisterPreferencesAPIServer(ss, s)
 }
 
+// reserved reports whether the namespace is denied to the users.
+func (s *service) reserved(namespace string) bool {
+	return preferences.IsReserved(namespace) || slices.Contains(s.conf.ReservedNamespaces, namespace)
+}
+
 func (s *service) SetKey(ctx context.Context, req *preferencespb.SetKeyRequest) (*preferencespb.SetKeyResponse, error) {
-	err := s.pm.SetKey(ctx, req.Key.Key, req.Key.Namespace, req.Val)
+	if s.reserved(req.GetKey().GetNamespace()) {
+		return &preferencespb.SetKeyResponse{
+			Status: status.NewPermissionDenied(ctx, nil, "reserved namespace"),
+		}, nil
+	}
+	if preferences.IsDeleteRequest(req) {
+		return s.deleteKey(ctx, req)
+	}
+	values, err := preferences.RequestedValues(req)
+	if err != nil {
+		return &preferencespb.SetKeyResponse{
+			Status: status.NewInvalidArg(ctx, err.Error()),
+		}, nil
+	}
+
+	if values != nil {
+		err = s.pm.SetKeys(ctx, values, req.Key.Namespace)
+	} else {
+		err = s.pm.SetKey(ctx, req.Key.Key, req.Key.Namespace, req.Val)
+	}
 	if err != nil {
 		return &preferencespb.SetKeyResponse{
 			Status: status.NewInternal(ctx, err, "error setting key"),
@@ -108,7 +136,42 @@ func (s *service) SetKey(ctx context.Context, req *preferencespb.SetKeyRequest)
 	}, nil
 }
 
+func (s *service) deleteKey(ctx context.Context, req *preferencespb.SetKeyRequest) (*preferencespb.SetKeyResponse, error) {
+	err := s.pm.DeleteKey(ctx, req.Key.Key, req.Key.Namespace)
+	if err != nil {
+		st := status.NewInternal(ctx, err, "error deleting key")
+		if _, ok := err.(errtypes.IsNotFound); ok {
+			st = status.NewNotFound(ctx, "key not found")
+		}
+		return &preferencespb.SetKeyResponse{
+			Status: st,
+		}, nil
+	}
+
+	return &preferencespb.SetKeyResponse{
+		Status: status.NewOK(ctx),
+	}, nil
+}
+
 func (s *service) GetKey(ctx context.Context, req *preferencespb.GetKeyRequest) (*preferencespb.GetKeyResponse, error) {
+	if s.reserved(req.GetKey().GetNamespace()) {
+		return &preferencespb.GetKeyResponse{
+			Status: status.NewPermissionDenied(ctx, nil, "reserved namespace"),
+		}, nil
+	}
+	if preferences.IsListRequest(req) {
+		return s.listKeys(ctx, req)
+	}
+	keys, err := preferences.RequestedKeys(req)
+	if err != nil {
+		return &preferencespb.GetKeyResponse{
+			Status: status.NewInvalidArg(ctx, err.Error()),
+		}, nil
+	}
+	if keys != nil {
+		return s.getKeys(ctx, keys, req.Key.Namespace)
+	}
+
 	val, err := s.pm.GetKey(ctx, req.Key.Key, req.Key.Namespace)
 	if err != nil {
 		st := status.NewInternal(ctx, err, "error retrieving key")
@@ -125,3 +188,47 @@ func (s *service) GetKey(ctx context.Context, req *preferencespb.GetKeyRequest)
 		Val:    val,
 	}, nil
 }
+
+func (s *service) listKeys(ctx context.Context, req *preferencespb.GetKeyRequest) (*preferencespb.GetKeyResponse, error) {
+	entries, err := s.pm.ListKeys(ctx, req.Key.GetNamespace())
+	if err != nil {
+		return &preferencespb.GetKeyResponse{
+			Status: status.NewInternal(ctx, err, "error listing keys"),
+		}, nil
+	}
+	// listing all the namespaces leaves out the reserved ones
+	entries = slices.DeleteFunc(entries, func(e *preferences.Entry) bool {
+		return s.reserved(e.Namespace)
+	})
+	opaque, err := preferences.EntriesOpaque(entries)
+	if err != nil {
+		return &preferencespb.GetKeyResponse{
+			Status: status.NewInternal(ctx, err, "error encoding keys"),
+		}, nil
+	}
+
+	return &preferencespb.GetKeyResponse{
+		Status: status.NewOK(ctx),
+		Opaque: opaque,
+	}, nil
+}
+
+func (s *service) getKeys(ctx context.Context, keys []string, namespace string) (*preferencespb.GetKeyResponse, error) {
+	values, err := s.pm.GetKeys(ctx, keys, namespace)
+	if err != nil {
+		return &preferencespb.GetKeyResponse{
+			Status: status.NewInternal(ctx, err, "error retrieving keys"),
+		}, nil
+	}
+	opaque, err := preferences.ValuesOpaque(values)
+	if err != nil {
+		return &preferencespb.GetKeyResponse{
+			Status: status.NewInternal(ctx, err, "error encoding keys"),
+		}, nil
+	}
+
+	return &preferencespb.GetKeyResponse{
+		Status: status.NewOK(ctx),
+		Opaque: opaque,
+	}, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package preferences
+
+import (
+	"context"
+	"testing"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	preferencespb "github.com/cs3org/go-cs3apis/cs3/preferences/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/preferences"
+	"github.com/cs3org/reva/v3/pkg/preferences/memory"
+)
+
+func newTestService(t *testing.T, reserved ...string) (*service, context.Context) {
+	pm, err := memory.New(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "einstein"}})
+	if err := pm.SetKey(ctx, "lang", "core", "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.SetKey(ctx, "secret", preferences.TOTPNamespace, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+	return &service{conf: &config{ReservedNamespaces: reserved}, pm: pm}, ctx
+}
+
+func TestListLeavesOutReservedNamespaces(t *testing.T) {
+	s, ctx := newTestService(t)
+
+	res, err := s.GetKey(ctx, preferences.NewListKeysRequest(""))
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+		t.Fatalf("listing failed: %v %v", res.GetStatus(), err)
+	}
+	entries, err := preferences.ListedEntries(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Namespace != "core" {
+		t.Fatalf("expected only the core entry, got %+v", entries)
+	}
+}
+
+func TestReservedNamespacesDenied(t *testing.T) {
+	s, ctx := newTestService(t, "internal")
+	getKeys, err := preferences.NewGetKeysRequest([]string{"secret"}, preferences.TOTPNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setKeys, err := preferences.NewSetKeysRequest(map[string]string{"secret": "x"}, preferences.TOTPNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gets := map[string]*preferencespb.GetKeyRequest{
+		"get":            {Key: &preferencespb.PreferenceKey{Namespace: preferences.TOTPNamespace, Key: "secret"}},
+		"get many":       getKeys,
+		"list":           preferences.NewListKeysRequest(preferences.TOTPNamespace),
+		"get configured": {Key: &preferencespb.PreferenceKey{Namespace: "internal", Key: "any"}},
+	}
+	for name, req := range gets {
+		res, err := s.GetKey(ctx, req)
+		if err != nil || res.Status.Code != rpc.Code_CODE_PERMISSION_DENIED {
+			t.Errorf("%s: expected permission denied, got %v %v", name, res.GetStatus(), err)
+		}
+	}
+
+	sets := map[string]*preferencespb.SetKeyRequest{
+		"set":      {Key: &preferencespb.PreferenceKey{Namespace: preferences.TOTPNamespace, Key: "secret"}, Val: "x"},
+		"set many": setKeys,
+		"delete":   preferences.NewDeleteKeyRequest("secret", preferences.TOTPNamespace),
+	}
+	for name, req := range sets {
+		res, err := s.SetKey(ctx, req)
+		if err != nil || res.Status.Code != rpc.Code_CODE_PERMISSION_DENIED {
+			t.Errorf("%s: expected permission denied, got %v %v", name, res.GetStatus(), err)
+		}
+	}
+
+	if v, err := s.pm.GetKey(ctx, "secret", preferences.TOTPNamespace); err != nil || v != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("expected the reserved key untouched, got %q (%v)", v, err)
+	}
+}
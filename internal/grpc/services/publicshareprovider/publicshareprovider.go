@@ -20,11 +20,17 @@ package publicshareprovider
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"regexp"
+	"strconv"
 
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/cs3org/reva/v3/pkg/errtypes"
 	"github.com/cs3org/reva/v3/pkg/notification"
@@ -34,8 +40,10 @@ import (
 	"github.com/cs3org/reva/v3/pkg/publicshare/manager/registry"
 	"github.com/cs3org/reva/v3/pkg/rgrpc"
 	"github.com/cs3org/reva/v3/pkg/rgrpc/status"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
 	"github.com/cs3org/reva/v3/pkg/utils"
 	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 )
@@ -54,12 +62,16 @@ type config struct {
 	Drivers               map[string]map[string]any `mapstructure:"drivers"`
 	AllowedPathsForShares []string                  `mapstructure:"allowed_paths_for_shares"`
 	Notifications         map[string]any            `mapstructure:"notifications"`
+	// TransferSharedSecret verifies the transfer tokens presented by the data
+	// gateway to count the downloads through a link.
+	TransferSharedSecret string `mapstructure:"transfer_shared_secret"`
 }
 
 func (c *config) ApplyDefaults() {
 	if c.Driver == "" {
 		c.Driver = "json"
 	}
+	c.TransferSharedSecret = sharedconf.GetJWTSecret(c.TransferSharedSecret)
 }
 
 type service struct {
@@ -152,18 +164,44 @@ func (s *service) CreatePublicShare(ctx context.Context, req *link.CreatePublicS
 		}, nil
 	}
 
+	restrictions, st := s.requestedRestrictions(ctx, req.Opaque)
+	if st != nil {
+		return &link.CreatePublicShareResponse{
+			Status: st,
+		}, nil
+	}
+	fileDrop, st := s.requestedFileDrop(ctx, req.Opaque)
+	if st != nil {
+		return &link.CreatePublicShareResponse{
+			Status: st,
+		}, nil
+	}
+
 	u, ok := appctx.ContextGetUser(ctx)
 	if !ok {
 		log.Error().Msg("error getting user from context")
 	}
 
 	share, err := s.sm.CreatePublicShare(ctx, u, req.ResourceInfo, req.Grant, req.Description, req.Internal, req.NotifyUploads, req.NotifyUploadsExtraRecipients)
+	if err == nil && (restrictions != nil || fileDrop != nil) {
+		ref := &link.PublicShareReference{Spec: &link.PublicShareReference_Id{Id: share.Id}}
+		if share, err = s.setLinkSettings(ctx, u, ref, restrictions, fileDrop); err != nil {
+			// do not leave behind a link without the requested settings
+			if rerr := s.sm.RevokePublicShare(ctx, u, ref); rerr != nil {
+				log.Error().Err(rerr).Msg("error revoking public share after failing to set its restrictions")
+			}
+		}
+	}
 	switch err.(type) {
 	case nil:
 		return &link.CreatePublicShareResponse{
 			Status: status.NewOK(ctx),
 			Share:  share,
 		}, nil
+	case errtypes.BadRequest:
+		return &link.CreatePublicShareResponse{
+			Status: status.NewInvalidArg(ctx, err.Error()),
+		}, nil
 	case errtypes.NotFound:
 		return &link.CreatePublicShareResponse{
 			Status: status.NewNotFound(ctx, "resource does not exist"),
@@ -207,6 +245,10 @@ func (s *service) GetPublicShareByToken(ctx context.Context, req *link.GetPublic
 	log := appctx.GetLogger(ctx)
 	log.Debug().Msg("getting public share by token")
 
+	if e, ok := req.Opaque.GetMap()[publicshare.CountDownloadOpaqueKey]; ok {
+		return s.countDownload(ctx, string(e.Value))
+	}
+
 	// there are 2 passes here, and the second request has no password
 	found, err := s.sm.GetPublicShareByToken(ctx, req.GetToken(), req.GetAuthentication(), req.GetSign())
 	switch v := err.(type) {
@@ -239,6 +281,16 @@ func (s *service) GetPublicShare(ctx context.Context, req *link.GetPublicShareRe
 		log.Error().Msg("error getting user from context")
 	}
 
+	if e, ok := req.Opaque.GetMap()[publicshare.CountUploadOpaqueKey]; ok {
+		size, err := strconv.ParseUint(string(e.Value), 10, 64)
+		if err != nil {
+			return &link.GetPublicShareResponse{
+				Status: status.NewInvalidArg(ctx, "invalid upload size"),
+			}, nil
+		}
+		return s.countUpload(ctx, req.Ref, size)
+	}
+
 	found, err := s.sm.GetPublicShare(ctx, u, req.Ref, req.GetSign())
 	switch err.(type) {
 	case nil:
@@ -283,6 +335,175 @@ func (s *service) ListPublicShares(ctx context.Context, req *link.ListPublicShar
 	return res, nil
 }
 
+// requestedRestrictions reads the link restrictions requested in an opaque.
+// It returns a status in case they are invalid or not supported by the driver.
+func (s *service) requestedRestrictions(ctx context.Context, o *types.Opaque) (*publicshare.Restrictions, *rpc.Status) {
+	r, err := publicshare.GetRestrictions(o)
+	if err != nil {
+		return nil, status.NewInvalidArg(ctx, err.Error())
+	}
+	if r == nil {
+		return nil, nil
+	}
+	if err := r.Validate(); err != nil {
+		return nil, status.NewInvalidArg(ctx, err.Error())
+	}
+	if _, ok := s.sm.(publicshare.RestrictionsManager); !ok {
+		return nil, status.NewUnimplemented(ctx, nil, "link restrictions are not supported by the "+s.conf.Driver+" driver")
+	}
+	return r, nil
+}
+
+// requestedFileDrop reads the file-drop settings requested in an opaque.
+// It returns a status in case they are invalid or not supported by the driver.
+func (s *service) requestedFileDrop(ctx context.Context, o *types.Opaque) (*publicshare.FileDrop, *rpc.Status) {
+	fd, err := publicshare.GetFileDrop(o)
+	if err != nil {
+		return nil, status.NewInvalidArg(ctx, err.Error())
+	}
+	if fd == nil {
+		return nil, nil
+	}
+	if err := fd.Validate(); err != nil {
+		return nil, status.NewInvalidArg(ctx, err.Error())
+	}
+	if _, ok := s.sm.(publicshare.FileDropManager); !ok {
+		return nil, status.NewUnimplemented(ctx, nil, "file-drop links are not supported by the "+s.conf.Driver+" driver")
+	}
+	return fd, nil
+}
+
+// setLinkSettings applies the requested restrictions and file-drop settings to a link.
+func (s *service) setLinkSettings(ctx context.Context, u *userpb.User, ref *link.PublicShareReference, r *publicshare.Restrictions, fd *publicshare.FileDrop) (*link.PublicShare, error) {
+	var share *link.PublicShare
+	var err error
+	if r != nil {
+		if share, err = s.sm.(publicshare.RestrictionsManager).SetRestrictions(ctx, u, ref, r); err != nil {
+			return nil, err
+		}
+	}
+	if fd != nil {
+		if share, err = s.sm.(publicshare.FileDropManager).SetFileDrop(ctx, u, ref, fd); err != nil {
+			return nil, err
+		}
+	}
+	return share, nil
+}
+
+// countUpload records an upload through a file-drop link.
+func (s *service) countUpload(ctx context.Context, ref *link.PublicShareReference, size uint64) (*link.GetPublicShareResponse, error) {
+	fm, ok := s.sm.(publicshare.FileDropManager)
+	if !ok {
+		return &link.GetPublicShareResponse{
+			Status: status.NewUnimplemented(ctx, nil, "file-drop links are not supported by the "+s.conf.Driver+" driver"),
+		}, nil
+	}
+
+	share, err := fm.CountUpload(ctx, ref, size)
+	switch err.(type) {
+	case nil:
+		return &link.GetPublicShareResponse{
+			Status: status.NewOK(ctx),
+			Share:  share,
+		}, nil
+	case errtypes.InsufficientStorage:
+		return &link.GetPublicShareResponse{
+			Status: status.NewInsufficientStorage(ctx, err, err.Error()),
+		}, nil
+	case errtypes.NotFound:
+		return &link.GetPublicShareResponse{
+			Status: status.NewNotFound(ctx, "share not found"),
+		}, nil
+	default:
+		return &link.GetPublicShareResponse{
+			Status: status.NewInternal(ctx, err, "error counting upload"),
+		}, nil
+	}
+}
+
+// transferClaims are the claims of the transfer tokens signed by the gateway
+// for the data gateway.
+type transferClaims struct {
+	jwt.RegisteredClaims
+	Link string `json:"link,omitempty"`
+}
+
+// countDownload records a download through a link limited in downloads. The
+// data gateway proves the download with its transfer token, which is signed
+// by the gateway and names the link the download goes through, so that no
+// other caller can count against the limit of a link. A transfer token is
+// counted once, the manager recording it with the counter, so that the
+// requests resuming a download or fetching it in ranges do not count it again
+// on any replica, and the clients outside of the networks the link is
+// restricted to are refused.
+func (s *service) countDownload(ctx context.Context, token string) (*link.GetPublicShareByTokenResponse, error) {
+	var claims transferClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return []byte(s.conf.TransferSharedSecret), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+	if err != nil || claims.Link == "" {
+		return &link.GetPublicShareByTokenResponse{
+			Status: status.NewPermissionDenied(ctx, err, "invalid transfer token"),
+		}, nil
+	}
+
+	rm, ok := s.sm.(publicshare.RestrictionsManager)
+	if !ok {
+		return &link.GetPublicShareByTokenResponse{
+			Status: status.NewUnimplemented(ctx, nil, "link restrictions are not supported by the "+s.conf.Driver+" driver"),
+		}, nil
+	}
+
+	ref := &link.PublicShareReference{
+		Spec: &link.PublicShareReference_Id{Id: &link.PublicShareId{OpaqueId: claims.Link}},
+	}
+	share, err := s.sm.GetPublicShare(ctx, nil, ref, false)
+	if err != nil {
+		return countDownloadResponse(ctx, err), nil
+	}
+	restrictions, err := publicshare.GetRestrictions(share.GetOpaque())
+	if err != nil {
+		return countDownloadResponse(ctx, err), nil
+	}
+	if !restrictions.AllowsClient(ctx) {
+		return &link.GetPublicShareByTokenResponse{
+			Status: status.NewPermissionDenied(ctx, nil, "the link cannot be used from this network"),
+		}, nil
+	}
+
+	id := claims.ID
+	if id == "" {
+		sum := sha256.Sum256([]byte(token))
+		id = hex.EncodeToString(sum[:])
+	}
+	// the share is not returned, the caller only proved a download
+	if _, err := rm.CountDownload(ctx, ref, id, claims.ExpiresAt.Time); err != nil {
+		return countDownloadResponse(ctx, err), nil
+	}
+	return countDownloadResponse(ctx, nil), nil
+}
+
+func countDownloadResponse(ctx context.Context, err error) *link.GetPublicShareByTokenResponse {
+	switch err.(type) {
+	case nil:
+		return &link.GetPublicShareByTokenResponse{
+			Status: status.NewOK(ctx),
+		}
+	case errtypes.PermissionDenied:
+		return &link.GetPublicShareByTokenResponse{
+			Status: status.NewPermissionDenied(ctx, err, "download limit reached"),
+		}
+	case errtypes.NotFound:
+		return &link.GetPublicShareByTokenResponse{
+			Status: status.NewNotFound(ctx, "share not found"),
+		}
+	default:
+		return &link.GetPublicShareByTokenResponse{
+			Status: status.NewInternal(ctx, err, "error counting download"),
+		}
+	}
+}
+
 func (s *service) UpdatePublicShare(ctx context.Context, req *link.UpdatePublicShareRequest) (*link.UpdatePublicShareResponse, error) {
 	log := appctx.GetLogger(ctx)
 	log.Info().Str("publicshareprovider", "update").Msg("update public share")
@@ -299,6 +520,34 @@ func (s *service) UpdatePublicShare(ctx context.Context, req *link.UpdatePublicS
 		}, nil
 	}
 
+	restrictions, st := s.requestedRestrictions(ctx, req.Opaque)
+	if st != nil {
+		return &link.UpdatePublicShareResponse{
+			Status: st,
+		}, nil
+	}
+	fileDrop, st := s.requestedFileDrop(ctx, req.Opaque)
+	if st != nil {
+		return &link.UpdatePublicShareResponse{
+			Status: st,
+		}, nil
+	}
+	if restrictions != nil || fileDrop != nil {
+		// restrictions and file-drop settings can be updated alone, or along with one of the standard updates
+		updated, err := s.setLinkSettings(ctx, u, req.Ref, restrictions, fileDrop)
+		if err != nil {
+			return &link.UpdatePublicShareResponse{
+				Status: status.NewStatusFromErrType(ctx, "error updating the link settings", err),
+			}, nil
+		}
+		if req.Update == nil {
+			return &link.UpdatePublicShareResponse{
+				Status: status.NewOK(ctx),
+				Share:  updated,
+			}, nil
+		}
+	}
+
 	if s.notificationHelper != nil {
 		if req.Update.Type == link.UpdatePublicShareRequest_Update_TYPE_NOTIFYUPLOADS {
 			if req.Update.NotifyUploads {
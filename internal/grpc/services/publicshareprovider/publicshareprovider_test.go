@@ -0,0 +1,181 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package publicshareprovider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/publicshare"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/metadata"
+)
+
+const testSecret = "transfer secret"
+
+// fakeManager counts the downloads of a single link, once per transfer.
+type fakeManager struct {
+	publicshare.Manager
+	share     *link.PublicShare
+	max       int
+	downloads int
+	counted   map[string]bool
+}
+
+func (m *fakeManager) GetPublicShare(_ context.Context, _ *userpb.User, ref *link.PublicShareReference, _ bool) (*link.PublicShare, error) {
+	if ref.GetId().GetOpaqueId() != m.share.GetId().GetOpaqueId() {
+		return nil, errtypes.NotFound(ref.GetId().GetOpaqueId())
+	}
+	return m.share, nil
+}
+
+func (m *fakeManager) CountDownload(ctx context.Context, ref *link.PublicShareReference, transferID string, _ time.Time) (*link.PublicShare, error) {
+	if _, err := m.GetPublicShare(ctx, nil, ref, false); err != nil {
+		return nil, err
+	}
+	if m.counted[transferID] {
+		return m.share, nil
+	}
+	if m.max > 0 && m.downloads >= m.max {
+		return nil, errtypes.PermissionDenied("download limit reached")
+	}
+	m.downloads++
+	m.counted[transferID] = true
+	return m.share, nil
+}
+
+func (m *fakeManager) SetRestrictions(context.Context, *userpb.User, *link.PublicShareReference, *publicshare.Restrictions) (*link.PublicShare, error) {
+	return m.share, nil
+}
+
+func newTestService(t *testing.T, r *publicshare.Restrictions) (*service, *fakeManager) {
+	opaque, err := publicshare.SetRestrictions(nil, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &fakeManager{share: &link.PublicShare{Id: &link.PublicShareId{OpaqueId: "1"}, Opaque: opaque}, max: r.MaxDownloads, counted: map[string]bool{}}
+	return newReplica(m), m
+}
+
+// newReplica returns another instance of the service sharing the manager.
+func newReplica(m *fakeManager) *service {
+	return &service{conf: &config{TransferSharedSecret: testSecret}, sm: m}
+}
+
+func transferToken(t *testing.T, linkID, secret string) string {
+	claims := transferClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			ID:        uuid.New().String(),
+		},
+		Link: linkID,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func countDownload(t *testing.T, s *service, ctx context.Context, token string) rpc.Code {
+	t.Helper()
+	res, err := s.countDownload(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.Status.Code
+}
+
+func TestCountDownloadOncePerToken(t *testing.T) {
+	s, m := newTestService(t, &publicshare.Restrictions{MaxDownloads: 2})
+	ctx := context.Background()
+
+	first := transferToken(t, "1", testSecret)
+	for range 3 {
+		// the requests resuming the download or fetching ranges of it
+		if code := countDownload(t, s, ctx, first); code != rpc.Code_CODE_OK {
+			t.Fatalf("expected the download to be allowed, got %s", code)
+		}
+	}
+	if m.downloads != 1 {
+		t.Fatalf("expected the transfer token to be counted once, got %d", m.downloads)
+	}
+	// the same transfer served by another replica
+	if code := countDownload(t, newReplica(m), ctx, first); code != rpc.Code_CODE_OK {
+		t.Fatalf("expected the download to be allowed on another replica, got %s", code)
+	}
+	if m.downloads != 1 {
+		t.Fatalf("expected the transfer token to be counted once across the replicas, got %d", m.downloads)
+	}
+
+	if code := countDownload(t, s, ctx, transferToken(t, "1", testSecret)); code != rpc.Code_CODE_OK {
+		t.Fatalf("expected the second download to be allowed, got %s", code)
+	}
+	if code := countDownload(t, s, ctx, transferToken(t, "1", testSecret)); code != rpc.Code_CODE_PERMISSION_DENIED {
+		t.Fatalf("expected the third download to be refused, got %s", code)
+	}
+	if m.downloads != 2 {
+		t.Fatalf("expected 2 downloads, got %d", m.downloads)
+	}
+}
+
+func TestCountDownloadRequiresSignedToken(t *testing.T) {
+	s, m := newTestService(t, &publicshare.Restrictions{MaxDownloads: 2})
+	ctx := context.Background()
+
+	for _, token := range []string{
+		"not a token",
+		transferToken(t, "1", "another secret"),
+		transferToken(t, "", testSecret),
+	} {
+		if code := countDownload(t, s, ctx, token); code != rpc.Code_CODE_PERMISSION_DENIED {
+			t.Errorf("expected the token to be refused, got %s", code)
+		}
+	}
+	if code := countDownload(t, s, ctx, transferToken(t, "2", testSecret)); code != rpc.Code_CODE_NOT_FOUND {
+		t.Errorf("expected an unknown link not to be found, got %s", code)
+	}
+	if m.downloads != 0 {
+		t.Fatalf("expected no download to be counted, got %d", m.downloads)
+	}
+}
+
+func TestCountDownloadAllowedNetworks(t *testing.T) {
+	s, m := newTestService(t, &publicshare.Restrictions{AllowedNetworks: []string{"192.0.2.0/24"}})
+	from := func(ip string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{appctx.ClientIPHeader: ip}))
+	}
+
+	if code := countDownload(t, s, from("198.51.100.1"), transferToken(t, "1", testSecret)); code != rpc.Code_CODE_PERMISSION_DENIED {
+		t.Fatalf("expected a client outside of the allowed networks to be refused, got %s", code)
+	}
+	if code := countDownload(t, s, from("192.0.2.10"), transferToken(t, "1", testSecret)); code != rpc.Code_CODE_OK {
+		t.Fatalf("expected a client in the allowed networks to be allowed, got %s", code)
+	}
+	if m.downloads != 1 {
+		t.Fatalf("expected 1 download, got %d", m.downloads)
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package publicstorageprovider
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/clientip"
+	"github.com/cs3org/reva/v3/pkg/publicshare"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/status"
+	"github.com/cs3org/reva/v3/pkg/utils"
+)
+
+const (
+	// maxAlternativeNames bounds the attempts to find a free name for a dropped file.
+	maxAlternativeNames = 100
+	// maxSessionFolderAttempts bounds the attempts to create a new session folder.
+	maxSessionFolderAttempts = 5
+	// sessionSuffixLength is the length of the random suffix of a session folder.
+	sessionSuffixLength = 6
+)
+
+// initiateFileDrop initiates an upload through a file-drop link. The file is
+// placed in the root of the shared folder, or in the subfolder of its upload
+// session, under a name that does not conflict with the existing files, and
+// is accounted against the quotas of the link.
+func (s *service) initiateFileDrop(ctx context.Context, req *provider.InitiateFileUploadRequest, fd *publicshare.FileDrop) (*provider.InitiateFileUploadResponse, error) {
+	tkn, relativePath, err := s.unwrap(ctx, req.Ref)
+	if err != nil {
+		return nil, err
+	}
+	ls, shareInfo, st, err := s.resolveToken(ctx, tkn)
+	switch {
+	case err != nil:
+		return nil, err
+	case st != nil:
+		return &provider.InitiateFileUploadResponse{
+			Status: st,
+		}, nil
+	case shareInfo.Type != provider.ResourceType_RESOURCE_TYPE_CONTAINER:
+		return &provider.InitiateFileUploadResponse{
+			Status: status.NewInvalidArg(ctx, "file-drop links must point to a folder"),
+		}, nil
+	}
+
+	name := path.Base(relativePath)
+	if name == "." || name == "/" {
+		return &provider.InitiateFileUploadResponse{
+			Status: status.NewInvalidArg(ctx, "missing file name"),
+		}, nil
+	}
+
+	size, known := uploadLength(req.Opaque)
+	if !known && fd.LimitsSize() {
+		return &provider.InitiateFileUploadResponse{
+			Status: status.NewInvalidArg(ctx, "the size of the upload must be given"),
+		}, nil
+	}
+	if err := fd.Allows(size); err != nil {
+		return &provider.InitiateFileUploadResponse{
+			Status: status.NewInsufficientStorage(ctx, err, err.Error()),
+		}, nil
+	}
+
+	metadata := uploaderMetadata(req.Opaque)
+	dir := shareInfo.Path
+	if fd.SubfolderPerSession {
+		if dir, st, err = s.sessionFolder(ctx, tkn, shareInfo.Path, req.Opaque, metadata); err != nil {
+			return nil, err
+		}
+		if st != nil {
+			return &provider.InitiateFileUploadResponse{
+				Status: st,
+			}, nil
+		}
+	}
+
+	// the upload is accounted before anything is created, as the completion
+	// of the transfer is not visible here: failed transfers are accounted as well
+	if fd.HasQuota() {
+		st, err := s.countUpload(ctx, ls, size)
+		switch {
+		case err != nil:
+			return nil, err
+		case st != nil:
+			return &provider.InitiateFileUploadResponse{
+				Status: st,
+			}, nil
+		}
+	}
+
+	for n := 1; n <= maxAlternativeNames; n++ {
+		target := &provider.Reference{Path: path.Join(dir, publicshare.AlternativeName(name, n))}
+		uRes, st, err := s.initiateFileDropAt(ctx, req, target, metadata)
+		switch {
+		case err != nil:
+			return nil, err
+		case st != nil && st.Code == rpc.Code_CODE_ALREADY_EXISTS:
+			continue
+		case st != nil:
+			return &provider.InitiateFileUploadResponse{
+				Status: st,
+			}, nil
+		}
+
+		res := &provider.InitiateFileUploadResponse{
+			Status:    uRes.Status,
+			Protocols: exposeUploadProtocols(uRes.Protocols),
+		}
+		res.Opaque = &typesv1beta1.Opaque{
+			Map: map[string]*typesv1beta1.OpaqueEntry{
+				publicshare.UploadPathOpaqueKey: {
+					Decoder: "plain",
+					Value:   []byte(path.Join(s.mountPath, tkn, strings.TrimPrefix(target.Path, shareInfo.Path))),
+				},
+			},
+		}
+		return res, nil
+	}
+
+	return &provider.InitiateFileUploadResponse{
+		Status: status.NewAlreadyExists(ctx, nil, "no free name left for "+name),
+	}, nil
+}
+
+// initiateFileDropAt initiates the upload of a dropped file at the given
+// reference, returning a status with CODE_ALREADY_EXISTS if it is taken.
+func (s *service) initiateFileDropAt(ctx context.Context, req *provider.InitiateFileUploadRequest, ref *provider.Reference, metadata map[string]string) (*provider.InitiateFileUploadResponse, *rpc.Status, error) {
+	if st, err := s.createDroppedFile(ctx, ref); err != nil || st != nil {
+		return nil, st, err
+	}
+
+	if len(metadata) > 0 {
+		mRes, err := s.gateway.SetArbitraryMetadata(ctx, &provider.SetArbitraryMetadataRequest{
+			Ref:               ref,
+			ArbitraryMetadata: &provider.ArbitraryMetadata{Metadata: metadata},
+		})
+		switch {
+		case err != nil:
+			return nil, nil, err
+		case mRes.Status.Code != rpc.Code_CODE_OK:
+			appctx.GetLogger(ctx).Warn().Str("path", ref.Path).Interface("status", mRes.Status).Msg("error recording the uploader of a dropped file")
+		}
+	}
+
+	uRes, err := s.gateway.InitiateFileUpload(ctx, &provider.InitiateFileUploadRequest{
+		Ref:    ref,
+		Opaque: req.Opaque,
+	})
+	switch {
+	case err != nil:
+		return nil, nil, err
+	case uRes.Status.Code != rpc.Code_CODE_OK:
+		return nil, uRes.Status, nil
+	}
+	return uRes, nil, nil
+}
+
+// createDroppedFile creates the dropped file empty, so that the concurrent
+// uploads find its name taken, and the upload then writes its content. It
+// returns a status with CODE_ALREADY_EXISTS if the name is taken. The check
+// and the creation are serialized, as the storage has no exclusive creation.
+func (s *service) createDroppedFile(ctx context.Context, ref *provider.Reference) (*rpc.Status, error) {
+	s.dropsMu.Lock()
+	defer s.dropsMu.Unlock()
+
+	sRes, err := s.gateway.Stat(ctx, &provider.StatRequest{Ref: ref})
+	switch {
+	case err != nil:
+		return nil, err
+	case sRes.Status.Code == rpc.Code_CODE_OK:
+		return status.NewAlreadyExists(ctx, nil, ref.Path), nil
+	case sRes.Status.Code != rpc.Code_CODE_NOT_FOUND:
+		return sRes.Status, nil
+	}
+
+	tRes, err := s.gateway.TouchFile(ctx, &provider.TouchFileRequest{Ref: ref})
+	switch {
+	case err != nil:
+		return nil, err
+	case tRes.Status.Code != rpc.Code_CODE_OK:
+		return tRes.Status, nil
+	}
+	return nil, nil
+}
+
+// sessionFolder returns the subfolder of the upload session of the request,
+// creating it the first time. The session is the one given by the client, or
+// else the client address: uploads from the same session within the session
+// TTL land in the same subfolder.
+func (s *service) sessionFolder(ctx context.Context, tkn, root string, o *typesv1beta1.Opaque, metadata map[string]string) (string, *rpc.Status, error) {
+	session := opaqueValue(o, publicshare.UploadSessionOpaqueKey)
+	if session == "" {
+		session = clientip.FromContext(ctx)
+	}
+	key := tkn + "!" + session
+
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	if session != "" {
+		if dir, err := s.sessions.Get(key); err == nil {
+			return dir.(string), nil, nil
+		}
+	}
+
+	var uploader string
+	if name := metadata[publicshare.UploaderNameMetadataKey]; name != "" {
+		uploader = " " + strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	}
+
+	// the folder is created anew for the session, never reusing the folder of
+	// another session started at the same time by an uploader with the same name
+	var dir string
+	var ref *provider.Reference
+	for attempt := 1; ; attempt++ {
+		folder := time.Now().UTC().Format("2006-01-02 15.04.05") + " " + utils.RandString(sessionSuffixLength) + uploader
+		dir = path.Join(root, folder)
+		ref = &provider.Reference{Path: dir}
+		cRes, err := s.gateway.CreateContainer(ctx, &provider.CreateContainerRequest{Ref: ref})
+		if err != nil {
+			return "", nil, err
+		}
+		if cRes.Status.Code == rpc.Code_CODE_OK {
+			break
+		}
+		if cRes.Status.Code != rpc.Code_CODE_ALREADY_EXISTS || attempt == maxSessionFolderAttempts {
+			return "", cRes.Status, nil
+		}
+	}
+	if len(metadata) > 0 {
+		mRes, err := s.gateway.SetArbitraryMetadata(ctx, &provider.SetArbitraryMetadataRequest{
+			Ref:               ref,
+			ArbitraryMetadata: &provider.ArbitraryMetadata{Metadata: metadata},
+		})
+		if err != nil || mRes.Status.Code != rpc.Code_CODE_OK {
+			appctx.GetLogger(ctx).Warn().Err(err).Str("path", dir).Msg("error recording the uploader of a file-drop session")
+		}
+	}
+
+	if session != "" {
+		_ = s.sessions.Set(key, dir)
+	}
+	return dir, nil, nil
+}
+
+// countUpload records an upload through a file-drop link with quotas,
+// failing if the link has no quota left.
+func (s *service) countUpload(ctx context.Context, ls *link.PublicShare, size uint64) (*rpc.Status, error) {
+	res, err := s.gateway.GetPublicShare(ctx, &link.GetPublicShareRequest{
+		Opaque: &typesv1beta1.Opaque{
+			Map: map[string]*typesv1beta1.OpaqueEntry{
+				publicshare.CountUploadOpaqueKey: {Decoder: "plain", Value: []byte(strconv.FormatUint(size, 10))},
+			},
+		},
+		Ref: &link.PublicShareReference{
+			Spec: &link.PublicShareReference_Token{
+				Token: ls.Token,
+			},
+		},
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case res.Status.Code != rpc.Code_CODE_OK:
+		return res.Status, nil
+	}
+	return nil, nil
+}
+
+// uploadLength returns the size of an upload as given by the client, and
+// whether it was given.
+func uploadLength(o *typesv1beta1.Opaque) (uint64, bool) {
+	size, err := strconv.ParseUint(opaqueValue(o, "Upload-Length"), 10, 64)
+	return size, err == nil
+}
+
+// uploaderMetadata returns the arbitrary metadata recording the uploader,
+// as given by the client.
+func uploaderMetadata(o *typesv1beta1.Opaque) map[string]string {
+	metadata := map[string]string{}
+	if name := strings.TrimSpace(opaqueValue(o, publicshare.UploaderNameOpaqueKey)); name != "" {
+		metadata[publicshare.UploaderNameMetadataKey] = name
+	}
+	if email := strings.TrimSpace(opaqueValue(o, publicshare.UploaderEmailOpaqueKey)); email != "" {
+		metadata[publicshare.UploaderEmailMetadataKey] = email
+	}
+	return metadata
+}
+
+// opaqueValue returns the value of a plain opaque entry, empty if missing.
+func opaqueValue(o *typesv1beta1.Opaque, key string) string {
+	if e, ok := o.GetMap()[key]; ok {
+		return string(e.Value)
+	}
+	return ""
+}
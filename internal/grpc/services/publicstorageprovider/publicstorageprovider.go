@@ -23,7 +23,10 @@ import (
 	"encoding/json"
 	"path"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/ReneKroon/ttlcache/v2"
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
@@ -31,6 +34,7 @@ import (
 	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/publicshare"
 	"github.com/cs3org/reva/v3/pkg/rgrpc"
 	"github.com/cs3org/reva/v3/pkg/rgrpc/status"
 	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
@@ -51,6 +55,9 @@ type config struct {
 	MountPath   string `mapstructure:"mount_path"`
 	MountID     string `mapstructure:"mount_id"`
 	GatewayAddr string `mapstructure:"gatewaysvc"`
+	// FileDropSessionTTL is the time in seconds an upload session through
+	// a file-drop link keeps its subfolder after its last upload.
+	FileDropSessionTTL int `mapstructure:"file_drop_session_ttl"`
 }
 
 func (c *config) ApplyDefaults() {
@@ -59,6 +66,9 @@ func (c *config) ApplyDefaults() {
 	if c.MountPath == "" {
 		c.MountPath = "/public"
 	}
+	if c.FileDropSessionTTL == 0 {
+		c.FileDropSessionTTL = 3600
+	}
 }
 
 type service struct {
@@ -66,10 +76,16 @@ type service struct {
 	mountPath string
 	mountID   string
 	gateway   gateway.GatewayAPIClient
+
+	sessions   *ttlcache.Cache
+	sessionsMu sync.Mutex
+	// dropsMu serializes the creation of the dropped files, as the storage
+	// has no exclusive creation of files.
+	dropsMu sync.Mutex
 }
 
 func (s *service) Close() error {
-	return nil
+	return s.sessions.Close()
 }
 
 func (s *service) UnprotectedEndpoints() []string {
@@ -95,11 +111,15 @@ func New(ctx context.Context, m map[string]any) (rgrpc.Service, error) {
 		return nil, err
 	}
 
+	sessions := ttlcache.NewCache()
+	_ = sessions.SetTTL(time.Duration(c.FileDropSessionTTL) * time.Second)
+
 	service := &service{
 		conf:      &c,
 		mountPath: mountPath,
 		mountID:   mountID,
 		gateway:   gateway,
+		sessions:  sessions,
 	}
 
 	return service, nil
@@ -271,6 +291,15 @@ func (s *service) InitiateFileUpload(ctx context.Context, req *provider.Initiate
 			Status: status.NewPermissionDenied(ctx, nil, "share does not grant InitiateFileUpload permission"),
 		}, nil
 	}
+
+	fileDrop, err := publicshare.GetFileDrop(ls.GetOpaque())
+	if err != nil {
+		return nil, err
+	}
+	if fileDrop.IsEnabled() {
+		return s.initiateFileDrop(ctx, req, fileDrop)
+	}
+
 	uReq := &provider.InitiateFileUploadRequest{
 		Ref:    cs3Ref,
 		Opaque: req.Opaque,
@@ -289,28 +318,33 @@ func (s *service) InitiateFileUpload(ctx context.Context, req *provider.Initiate
 		}, nil
 	}
 
-	protocols := make([]*provider.FileUploadProtocol, len(uRes.Protocols))
-	for p := range uRes.Protocols {
-		if !strings.HasSuffix(uRes.Protocols[p].UploadEndpoint, "/") {
-			uRes.Protocols[p].UploadEndpoint += "/"
+	res := &provider.InitiateFileUploadResponse{
+		Status:    uRes.Status,
+		Protocols: exposeUploadProtocols(uRes.Protocols),
+	}
+
+	return res, nil
+}
+
+// exposeUploadProtocols returns the upload protocols given by the gateway,
+// with their upload endpoints exposed to the clients.
+func exposeUploadProtocols(uploadProtocols []*gateway.FileUploadProtocol) []*provider.FileUploadProtocol {
+	protocols := make([]*provider.FileUploadProtocol, 0, len(uploadProtocols))
+	for _, p := range uploadProtocols {
+		if !strings.HasSuffix(p.UploadEndpoint, "/") {
+			p.UploadEndpoint += "/"
 		}
-		uRes.Protocols[p].UploadEndpoint += uRes.Protocols[p].Token
+		p.UploadEndpoint += p.Token
 
 		protocols = append(protocols, &provider.FileUploadProtocol{
-			Opaque:             uRes.Protocols[p].Opaque,
-			Protocol:           uRes.Protocols[p].Protocol,
-			UploadEndpoint:     uRes.Protocols[p].UploadEndpoint,
-			AvailableChecksums: uRes.Protocols[p].AvailableChecksums,
+			Opaque:             p.Opaque,
+			Protocol:           p.Protocol,
+			UploadEndpoint:     p.UploadEndpoint,
+			AvailableChecksums: p.AvailableChecksums,
 			Expose:             true, // the gateway already has encoded the upload endpoint
 		})
 	}
-
-	res := &provider.InitiateFileUploadResponse{
-		Status:    uRes.Status,
-		Protocols: protocols,
-	}
-
-	return res, nil
+	return protocols
 }
 
 func (s *service) GetPath(ctx context.Context, req *provider.GetPathRequest) (*provider.GetPathResponse, error) {
@@ -774,6 +808,14 @@ func (s *service) resolveToken(ctx context.Context, token string) (*link.PublicS
 		return nil, nil, publicShareResponse.Status, nil
 	}
 
+	restrictions, err := publicshare.GetRestrictions(publicShareResponse.GetShare().GetOpaque())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !restrictions.AllowsClient(ctx) {
+		return nil, nil, status.NewPermissionDenied(ctx, nil, "the link cannot be used from this network"), nil
+	}
+
 	sRes, err := s.gateway.Stat(ctx, &provider.StatRequest{
 		Ref: &provider.Reference{
 			ResourceId: publicShareResponse.GetShare().GetResourceId(),
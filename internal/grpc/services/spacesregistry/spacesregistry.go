@@ -144,8 +144,9 @@ func getSpacesDriver(ctx context.Context, driver string, cfg map[string]map[stri
 }
 
 func (s *service) CreateStorageSpace(ctx context.Context, req *provider.CreateStorageSpaceRequest) (*provider.CreateStorageSpaceResponse, error) {
-	// The creation of a space requires a provisioning and approval workflow, which for now is implemented externally
-	return nil, errors.New("not supportedd")
+	// The creation of a project is a request, approved by the administrators
+	// before the project is provisioned
+	return s.projects.CreateStorageSpace(ctx, req)
 }
 
 func countTypeFilters(filters []*provider.ListStorageSpacesRequest_Filter) (count int) {
@@ -162,6 +163,16 @@ func (s *service) ListStorageSpaces(ctx context.Context, req *provider.ListStora
 	filters := req.Filters
 	log := appctx.GetLogger(ctx)
 
+	// The administrators list all the projects with the usage collected in
+	// the catalogue, there is no need to decorate them
+	if projects.IsListAllRequest(req) {
+		res, err := s.projects.ListStorageSpaces(ctx, req, projects.ListAllStatus(req))
+		if err != nil {
+			return &provider.ListStorageSpacesResponse{Status: status.NewInternal(ctx, err, err.Error())}, nil
+		}
+		return res, nil
+	}
+
 	sp := []*provider.StorageSpace{}
 	// List all spaces, or look for a specific space
 	// -> we go over all the types
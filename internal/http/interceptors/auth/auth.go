@@ -36,6 +36,7 @@ import (
 	tokenregistry "github.com/cs3org/reva/v3/internal/http/interceptors/auth/token/registry"
 	tokenwriterregistry "github.com/cs3org/reva/v3/internal/http/interceptors/auth/tokenwriter/registry"
 	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/clientip"
 
 	"github.com/cs3org/reva/v3/pkg/auth"
 	"github.com/cs3org/reva/v3/pkg/auth/scope"
@@ -207,8 +208,13 @@ func New(m map[string]any, unprotected []string) (global.Middleware, error) {
 func authenticateUser(w http.ResponseWriter, r *http.Request, conf *config, signedURLStrategies []auth.SignedURLStrategy, tokenStrategies []auth.TokenStrategy, tokenManager token.Manager, tokenWriter auth.TokenWriter, credChain map[string]auth.CredentialStrategy, isUnprotectedEndpoint bool) (context.Context, error) {
 	ctx := r.Context()
 	log := appctx.GetLogger(ctx)
-	// Add the request user-agent to the ctx
-	ctx = metadata.NewIncomingContext(ctx, metadata.New(map[string]string{appctx.UserAgentHeader: r.UserAgent()}))
+	// Add the request user-agent, client IP and API to the ctx
+	ip := clientip.FromRequest(r)
+	ctx = metadata.NewIncomingContext(ctx, metadata.New(map[string]string{
+		appctx.UserAgentHeader: r.UserAgent(),
+		appctx.ClientIPHeader:  ip,
+		appctx.ClientAPIHeader: r.URL.Path,
+	}))
 
 	client, err := pool.GetGatewayServiceClient(pool.Endpoint(conf.GatewaySvc))
 	if err != nil {
@@ -249,7 +255,7 @@ func authenticateUser(w http.ResponseWriter, r *http.Request, conf *config, sign
 	for _, tokenStrategy := range tokenStrategies {
 		token := tokenStrategy.GetToken(r)
 		if token != "" {
-			if user, scopes, ok := isTokenValid(r, tokenManager, token); ok {
+			if user, scopes, ok := isTokenValid(ctx, r, tokenManager, token); ok {
 				if err := insertGroupsInUser(ctx, userGroupsCache, client, user); err != nil {
 					logError(isUnprotectedEndpoint, log, err, "got an error retrieving groups for user "+user.Username, http.StatusInternalServerError, w)
 					return nil, err
@@ -300,7 +306,9 @@ func authenticateUser(w http.ResponseWriter, r *http.Request, conf *config, sign
 
 	log.Debug().Msgf("AuthenticateRequest: type: %s, client_id: %s against %s", req.Type, req.ClientId, conf.GatewaySvc)
 
-	res, err := client.Authenticate(ctx, req)
+	// the auth managers may restrict the clients allowed to authenticate,
+	// as for public links limited to some networks
+	res, err := client.Authenticate(metadata.AppendToOutgoingContext(ctx, appctx.ClientIPHeader, ip), req)
 	if err != nil {
 		logError(isUnprotectedEndpoint, log, err, "error calling Authenticate", http.StatusUnauthorized, w)
 		return nil, err
@@ -354,6 +362,8 @@ func ctxWithUserInfo(ctx context.Context, r *http.Request, user *userpb.User, to
 	ctx = appctx.ContextSetScopes(ctx, scopes)
 	ctx = metadata.AppendToOutgoingContext(ctx, appctx.TokenHeader, token)
 	ctx = metadata.AppendToOutgoingContext(ctx, appctx.UserAgentHeader, r.UserAgent())
+	ctx = metadata.AppendToOutgoingContext(ctx, appctx.ClientIPHeader, clientip.FromRequest(r))
+	ctx = metadata.AppendToOutgoingContext(ctx, appctx.ClientAPIHeader, r.URL.Path)
 
 	return ctx
 }
@@ -376,9 +386,7 @@ func insertGroupsInUser(ctx context.Context, userGroupsCache gcache.Cache, clien
 	return nil
 }
 
-func isTokenValid(r *http.Request, tokenManager token.Manager, token string) (*userpb.User, map[string]*authpb.Scope, bool) {
-	ctx := r.Context()
-
+func isTokenValid(ctx context.Context, r *http.Request, tokenManager token.Manager, token string) (*userpb.User, map[string]*authpb.Scope, bool) {
 	u, tokenScope, err := tokenManager.DismantleToken(ctx, token)
 	if err != nil {
 		return nil, nil, false
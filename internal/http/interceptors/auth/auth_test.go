@@ -67,6 +67,9 @@ func TestCtxWithUserInfoStoresScopes(t *testing.T) {
 	if got := md.Get(appctx.UserAgentHeader); len(got) != 1 || got[0] != "dav-client" {
 		t.Fatalf("outgoing %s metadata = %v, want [dav-client]", appctx.UserAgentHeader, got)
 	}
+	if got := md.Get(appctx.ClientIPHeader); len(got) != 1 || got[0] != "192.0.2.1" {
+		t.Fatalf("outgoing %s metadata = %v, want [192.0.2.1]", appctx.ClientIPHeader, got)
+	}
 }
 
 func TestIsTokenValidReturnsScopes(t *testing.T) {
@@ -90,7 +93,7 @@ func TestIsTokenValidReturnsScopes(t *testing.T) {
 	}
 
 	req := httptest.NewRequest(http.MethodGet, "https://example.org/remote.php/dav/ocm/share123", nil)
-	gotUser, gotScopes, ok := isTokenValid(req, tokenManager, token)
+	gotUser, gotScopes, ok := isTokenValid(req.Context(), req, tokenManager, token)
 	if !ok {
 		t.Fatal("isTokenValid() returned false")
 	}
@@ -24,18 +24,39 @@ import (
 
 	"github.com/cs3org/reva/v3/internal/http/interceptors/auth/credential/registry"
 	"github.com/cs3org/reva/v3/pkg/auth"
+	"github.com/cs3org/reva/v3/pkg/auth/totp"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
 )
 
 func init() {
 	registry.Register("basic", New)
 }
 
-type strategy struct{}
+type config struct {
+	// OTPHeader is the request header carrying the one-time code of users
+	// enrolled in two-factor authentication. Disabled if empty.
+	OTPHeader string `mapstructure:"otp_header"`
+}
+
+func parseConfig(m map[string]any) (*config, error) {
+	var c config
+	err := mapstructure.Decode(m, &c)
+	return &c, err
+}
+
+type strategy struct {
+	c *config
+}
 
 // New returns a new auth strategy that checks for basic auth.
 // See https://tools.ietf.org/html/rfc7617
 func New(m map[string]any) (auth.CredentialStrategy, error) {
-	return &strategy{}, nil
+	c, err := parseConfig(m)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing config")
+	}
+	return &strategy{c: c}, nil
 }
 
 func (s *strategy) GetCredentials(w http.ResponseWriter, r *http.Request) (*auth.Credentials, error) {
@@ -43,6 +64,10 @@ func (s *strategy) GetCredentials(w http.ResponseWriter, r *http.Request) (*auth
 	if !ok {
 		return nil, fmt.Errorf("no basic auth provided")
 	}
+	if s.c.OTPHeader != "" {
+		// the one-time code is verified by the totp auth manager
+		secret = totp.JoinSecret(secret, r.Header.Get(s.c.OTPHeader))
+	}
 	return &auth.Credentials{Type: "basic", ClientID: id, ClientSecret: secret}, nil
 }
 
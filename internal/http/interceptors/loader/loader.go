@@ -22,5 +22,6 @@ import (
 	// Load core HTTP middlewares.
 	_ "github.com/cs3org/reva/v3/internal/http/interceptors/cors"
 	_ "github.com/cs3org/reva/v3/internal/http/interceptors/plugins"
+	_ "github.com/cs3org/reva/v3/internal/http/interceptors/ratelimit"
 	// Add your own middleware.
 )
@@ -0,0 +1,151 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/clientip"
+	"github.com/cs3org/reva/v3/pkg/ratelimit"
+	"github.com/cs3org/reva/v3/pkg/rhttp/global"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+)
+
+const (
+	// defaultPriority is higher than the one of the cors middleware,
+	// so that the throttled responses still carry the CORS headers.
+	defaultPriority = 300
+)
+
+func init() {
+	global.RegisterMiddleware("ratelimit", New)
+}
+
+type config struct {
+	ratelimit.Config `mapstructure:",squash"`
+	Priority         int `mapstructure:"priority"`
+}
+
+func (c *config) ApplyDefaults() {
+	c.Config.ApplyDefaults()
+	if c.Priority == 0 {
+		c.Priority = defaultPriority
+	}
+}
+
+// New returns a new HTTP middleware limiting the rate of the requests
+// and locking out the clients repeatedly failing to authenticate.
+func New(m map[string]any) (global.Middleware, int, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, 0, err
+	}
+
+	limiter, err := ratelimit.New(&c.Config)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return func(h http.Handler) http.Handler {
+		return handler(limiter, h)
+	}, c.Priority, nil
+}
+
+func handler(limiter *ratelimit.Limiter, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := appctx.GetLogger(ctx)
+
+		req := &ratelimit.Request{IP: clientip.FromRequest(r), Route: r.URL.Path}
+		// the middleware runs before the authentication, so only
+		// the user of basic auth requests is known at this point
+		if u, _, ok := r.BasicAuth(); ok {
+			req.User = u
+		}
+
+		wait, err := limiter.Allow(ctx, req)
+		if err != nil {
+			// do not make the service unavailable if the store is down
+			log.Error().Err(err).Msg("ratelimit: error checking the limits, letting the request through")
+		} else if wait > 0 {
+			log.Info().Str("ip", req.IP).Str("user", req.User).Str("path", req.Route).Dur("retry_after", wait).Msg("ratelimit: too many requests")
+			w.Header().Set("Retry-After", retryAfter(wait))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r)
+
+		switch {
+		case isFailedAuth(r, sw.status):
+			err = limiter.Failed(ctx, req)
+		case sw.status < http.StatusBadRequest && r.Header.Get("Authorization") != "":
+			err = limiter.Succeeded(ctx, req)
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("ratelimit: error recording the authentication outcome")
+		}
+	})
+}
+
+// isFailedAuth reports whether the response denotes wrong credentials. An
+// expired reva token is not the sign of someone guessing credentials.
+func isFailedAuth(r *http.Request, status int) bool {
+	return status == http.StatusUnauthorized && r.Header.Get(appctx.TokenHeader) == ""
+}
+
+// retryAfter formats the wait as the number of seconds of a Retry-After header.
+func retryAfter(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to reach the wrapped writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,91 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cs3org/reva/v3/pkg/ratelimit"
+)
+
+func newTestHandler(t *testing.T, c *ratelimit.Config, status int) http.Handler {
+	t.Helper()
+	c.ApplyDefaults()
+	limiter, err := ratelimit.New(c)
+	if err != nil {
+		t.Fatalf("error creating the limiter: %v", err)
+	}
+	return handler(limiter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+}
+
+func TestTooManyRequests(t *testing.T) {
+	h := newTestHandler(t, &ratelimit.Config{
+		Rules: []*ratelimit.Rule{{By: []string{"ip"}, Rate: 0.5, Burst: 1}},
+	}, http.StatusOK)
+
+	r := httptest.NewRequest(http.MethodGet, "/remote.php/dav/files/einstein", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, expected %d", w.Code, http.StatusOK)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d, expected %d", w.Code, http.StatusTooManyRequests)
+	}
+	if ra := w.Header().Get("Retry-After"); ra != "2" {
+		t.Errorf("got Retry-After %q, expected 2", ra)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	h := newTestHandler(t, &ratelimit.Config{LockoutThreshold: 2}, http.StatusUnauthorized)
+
+	r := httptest.NewRequest(http.MethodGet, "/remote.php/dav/public-files/token", nil)
+	r.SetBasicAuth("public", "wrong")
+	r.Header.Set("X-Forwarded-For", "192.0.2.1, 10.0.0.1")
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: got status %d, expected %d", i, w.Code, http.StatusUnauthorized)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("got status %d and Retry-After %q, expected the client to be locked out for 30s", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	other := httptest.NewRequest(http.MethodGet, "/remote.php/dav/public-files/token", nil)
+	other.Header.Set("X-Forwarded-For", "192.0.2.2")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, other)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d, expected other clients not to be locked out", w.Code)
+	}
+}
@@ -100,6 +100,9 @@ func (s *svc) routerInit() error {
 	s.router.Post("/new", s.handleNew)
 	s.router.Post("/open", s.handleOpen)
 	s.router.Post("/notify", s.handleNotify)
+	s.router.Get("/defaults", s.handleListDefaults)
+	s.router.Post("/defaults", s.handleSetDefault)
+	s.router.Delete("/defaults", s.handleResetDefaults)
 	return nil
 }
 
@@ -0,0 +1,180 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package appprovider
+
+import (
+	"encoding/json"
+	"net/http"
+
+	appregistry "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	preferencespb "github.com/cs3org/go-cs3apis/cs3/preferences/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/app"
+	"github.com/cs3org/reva/v3/pkg/preferences"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/status"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+)
+
+// handleListDefaults returns the default apps chosen by the user, by mime type.
+func (s *svc) handleListDefaults(w http.ResponseWriter, r *http.Request) {
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		writeError(w, r, appErrorServerError, "error getting grpc gateway client", err)
+		return
+	}
+
+	defaults, err := listDefaults(r, client)
+	if err != nil {
+		writeError(w, r, appErrorServerError, "error listing the default apps", err)
+		return
+	}
+
+	js, err := json.Marshal(map[string]any{"defaults": defaults})
+	if err != nil {
+		writeError(w, r, appErrorServerError, "error marshalling JSON response", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(js); err != nil {
+		writeError(w, r, appErrorServerError, "error writing JSON response", err)
+		return
+	}
+}
+
+// handleSetDefault sets the app the user wants to open a mime type with by default.
+func (s *svc) handleSetDefault(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		writeError(w, r, appErrorServerError, "error getting grpc gateway client", err)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeError(w, r, appErrorInvalidParameter, "parameters could not be parsed", nil)
+		return
+	}
+	mimeType, appName := r.Form.Get("mime_type"), r.Form.Get("app_name")
+	if mimeType == "" || appName == "" {
+		writeError(w, r, appErrorInvalidParameter, "missing mime type or app name", nil)
+		return
+	}
+
+	// only an app able to open the mime type can be its default
+	provRes, err := client.GetAppProviders(ctx, &appregistry.GetAppProvidersRequest{
+		ResourceInfo: &provider.ResourceInfo{MimeType: mimeType},
+	})
+	if err != nil {
+		writeError(w, r, appErrorServerError, "error getting the app providers", err)
+		return
+	}
+	found := false
+	for _, p := range provRes.Providers {
+		if p.Name == appName {
+			found = true
+			break
+		}
+	}
+	if provRes.Status.Code != rpc.Code_CODE_OK || !found {
+		writeError(w, r, appErrorInvalidParameter, "the app can not open the mime type", nil)
+		return
+	}
+
+	res, err := client.SetKey(ctx, &preferencespb.SetKeyRequest{
+		Key: &preferencespb.PreferenceKey{Namespace: app.DefaultAppsNamespace, Key: mimeType},
+		Val: appName,
+	})
+	if err != nil {
+		writeError(w, r, appErrorServerError, "error setting the default app", err)
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		writeError(w, r, appErrorServerError, "error setting the default app", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleResetDefaults removes the default app chosen by the user for
+// a mime type, or for all the mime types if none is given.
+func (s *svc) handleResetDefaults(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		writeError(w, r, appErrorServerError, "error getting grpc gateway client", err)
+		return
+	}
+
+	var mimeTypes []string
+	if mimeType := r.URL.Query().Get("mime_type"); mimeType != "" {
+		mimeTypes = []string{mimeType}
+	} else {
+		defaults, err := listDefaults(r, client)
+		if err != nil {
+			writeError(w, r, appErrorServerError, "error listing the default apps", err)
+			return
+		}
+		for m := range defaults {
+			mimeTypes = append(mimeTypes, m)
+		}
+	}
+
+	for _, m := range mimeTypes {
+		res, err := client.SetKey(ctx, preferences.NewDeleteKeyRequest(m, app.DefaultAppsNamespace))
+		if err != nil {
+			writeError(w, r, appErrorServerError, "error resetting the default app", err)
+			return
+		}
+		switch res.Status.Code {
+		case rpc.Code_CODE_OK:
+		case rpc.Code_CODE_NOT_FOUND:
+			writeError(w, r, appErrorNotFound, "no default app set for "+m, nil)
+			return
+		default:
+			writeError(w, r, appErrorServerError, "error resetting the default app", nil)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func listDefaults(r *http.Request, client gateway.GatewayAPIClient) (map[string]string, error) {
+	res, err := client.GetKey(r.Context(), preferences.NewListKeysRequest(app.DefaultAppsNamespace))
+	if err != nil {
+		return nil, err
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		return nil, status.NewErrorFromCode(res.Status.Code, "appprovider")
+	}
+	entries, err := preferences.ListedEntries(res)
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := make(map[string]string, len(entries))
+	for _, e := range entries {
+		defaults[e.Key] = e.Value
+	}
+	return defaults, nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/audit"
+	"github.com/cs3org/reva/v3/pkg/audit/sink/registry"
+	"github.com/cs3org/reva/v3/pkg/rhttp/global"
+	"github.com/cs3org/reva/v3/pkg/utils"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+func init() {
+	global.Register("audit", New)
+}
+
+// Config holds the config options of the audit HTTP service.
+type Config struct {
+	Prefix string `mapstructure:"prefix"`
+	// Driver is the audit sink the events are queried from,
+	// which has to be configured in the audit interceptor as well.
+	Driver  string                    `mapstructure:"driver"`
+	Drivers map[string]map[string]any `mapstructure:"drivers"`
+	// Admins and AdminGroups are the users allowed to query the audit trail.
+	Admins      []string `mapstructure:"admins"`
+	AdminGroups []string `mapstructure:"admin_groups"`
+}
+
+func (c *Config) ApplyDefaults() {
+	if c.Prefix == "" {
+		c.Prefix = "audit"
+	}
+	if c.Driver == "" {
+		c.Driver = "sql"
+	}
+}
+
+type svc struct {
+	conf    *Config
+	router  *chi.Mux
+	sink    audit.Sink
+	querier audit.Querier
+}
+
+// New returns a new audit service, allowing the administrators
+// to query the audit trail.
+func New(ctx context.Context, m map[string]any) (global.Service, error) {
+	var c Config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	f, ok := registry.NewFuncs[c.Driver]
+	if !ok {
+		return nil, errors.New("audit: unknown driver " + c.Driver)
+	}
+	sink, err := f(ctx, c.Drivers[c.Driver])
+	if err != nil {
+		return nil, err
+	}
+	querier, ok := sink.(audit.Querier)
+	if !ok {
+		return nil, errors.New("audit: driver " + c.Driver + " cannot be queried")
+	}
+
+	s := &svc{
+		conf:    &c,
+		router:  chi.NewRouter(),
+		sink:    sink,
+		querier: querier,
+	}
+	s.router.Get("/", s.handleQuery)
+	return s, nil
+}
+
+// Close performs cleanup.
+func (s *svc) Close() error {
+	return s.sink.Close()
+}
+
+func (s *svc) Prefix() string {
+	return s.conf.Prefix
+}
+
+func (s *svc) Unprotected() []string {
+	return []string{}
+}
+
+func (s *svc) Handler() http.Handler {
+	return s.router
+}
+
+func (s *svc) isAdmin(ctx context.Context) bool {
+	u, ok := appctx.ContextGetUser(ctx)
+	return ok && utils.IsAdmin(u, s.conf.Admins, s.conf.AdminGroups)
+}
+
+func (s *svc) handleQuery(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	if !s.isAdmin(ctx) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := s.querier.Query(ctx, f)
+	if err != nil {
+		log.Error().Err(err).Msg("audit: error querying events")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []*audit.Event{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		log.Error().Err(err).Msg("audit: error writing response")
+	}
+}
+
+func parseFilter(r *http.Request) (*audit.Filter, error) {
+	q := r.URL.Query()
+	f := &audit.Filter{
+		Actor:   q.Get("actor"),
+		Action:  q.Get("action"),
+		Target:  q.Get("target"),
+		Outcome: audit.Outcome(q.Get("outcome")),
+		Limit:   defaultLimit,
+	}
+
+	if f.Outcome != "" && f.Outcome != audit.OutcomeSuccess && f.Outcome != audit.OutcomeFailure {
+		return nil, errors.New("invalid outcome " + string(f.Outcome))
+	}
+
+	var err error
+	if from := q.Get("from"); from != "" {
+		if f.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return nil, errors.Wrap(err, "invalid from time")
+		}
+	}
+	if to := q.Get("to"); to != "" {
+		if f.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return nil, errors.Wrap(err, "invalid to time")
+		}
+	}
+	if limit := q.Get("limit"); limit != "" {
+		if f.Limit, err = strconv.Atoi(limit); err != nil || f.Limit <= 0 {
+			return nil, errors.New("invalid limit " + limit)
+		}
+		f.Limit = min(f.Limit, maxLimit)
+	}
+	return f, nil
+}
@@ -28,14 +28,21 @@ import (
 	"strconv"
 	"time"
 
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/clientip"
 	"github.com/cs3org/reva/v3/pkg/errtypes"
 	"github.com/cs3org/reva/v3/pkg/httpclient"
+	"github.com/cs3org/reva/v3/pkg/publicshare"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/v3/pkg/rhttp/global"
 	"github.com/cs3org/reva/v3/pkg/sharedconf"
 	"github.com/cs3org/reva/v3/pkg/utils/cfg"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
@@ -54,12 +61,14 @@ type transferClaims struct {
 	jwt.RegisteredClaims
 	Target     string `json:"target"`
 	VersionKey string `json:"version_key,omitempty"`
+	Link       string `json:"link,omitempty"`
 }
 type config struct {
 	Prefix               string `mapstructure:"prefix"`
 	TransferSharedSecret string `mapstructure:"transfer_shared_secret"                                  validate:"required"`
 	Timeout              int64  `mapstructure:"timeout"`
 	Insecure             bool   `docs:"false;Whether to skip certificate checks when sending requests." mapstructure:"insecure"`
+	GatewaySvc           string `mapstructure:"gatewaysvc"`
 }
 
 func (c *config) ApplyDefaults() {
@@ -68,6 +77,7 @@ func (c *config) ApplyDefaults() {
 	}
 
 	c.TransferSharedSecret = sharedconf.GetJWTSecret(c.TransferSharedSecret)
+	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
 }
 
 type svc struct {
@@ -235,6 +245,13 @@ func (s *svc) doGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if claims.Link != "" {
+		if code := s.countDownload(ctx, r); code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+	}
+
 	log.Debug().Str("target", claims.Target).Msg("sending request to internal data server")
 
 	httpClient := s.client
@@ -288,6 +305,46 @@ func (s *svc) doGet(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// countDownload records a download through a public link against its limit,
+// presenting the transfer token as the proof of the download, and checks that
+// the client may use the link. The download is counted once per transfer
+// token, whatever the ranges requested. It returns the HTTP status to answer
+// with.
+func (s *svc) countDownload(ctx context.Context, r *http.Request) int {
+	log := appctx.GetLogger(ctx)
+	token := r.Header.Get(TokenTransportHeader)
+	// the data gateway is not behind the auth middleware, which
+	// otherwise forwards the client address to the gRPC services
+	ctx = metadata.AppendToOutgoingContext(ctx, appctx.ClientIPHeader, clientip.FromRequest(r))
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		log.Error().Err(err).Msg("error getting grpc gateway client")
+		return http.StatusInternalServerError
+	}
+	res, err := client.GetPublicShareByToken(ctx, &link.GetPublicShareByTokenRequest{
+		Opaque: &types.Opaque{
+			Map: map[string]*types.OpaqueEntry{
+				publicshare.CountDownloadOpaqueKey: {Decoder: "plain", Value: []byte(token)},
+			},
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("error counting download")
+		return http.StatusInternalServerError
+	}
+	switch res.Status.Code {
+	case rpc.Code_CODE_OK:
+		return http.StatusOK
+	case rpc.Code_CODE_PERMISSION_DENIED:
+		return http.StatusForbidden
+	case rpc.Code_CODE_NOT_FOUND:
+		return http.StatusNotFound
+	default:
+		log.Error().Str("status", res.Status.Message).Msg("error counting download")
+		return http.StatusInternalServerError
+	}
+}
+
 func (s *svc) doPut(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := appctx.GetLogger(ctx)
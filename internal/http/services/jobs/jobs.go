@@ -0,0 +1,259 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package jobs exposes the on-demand background jobs over HTTP, allowing the
+// administrators to enqueue them, e.g. from the reva CLI, and the users to
+// follow the runs created on their behalf.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/rhttp/global"
+	"github.com/cs3org/reva/v3/pkg/rjobs"
+	"github.com/cs3org/reva/v3/pkg/utils"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/go-chi/chi/v5"
+)
+
+func init() {
+	global.Register("jobs", New)
+}
+
+// Config holds the config options of the jobs HTTP service.
+type Config struct {
+	Prefix string `mapstructure:"prefix"`
+	// Admins and AdminGroups are the users allowed to enqueue jobs
+	// and to follow the runs of the other users.
+	Admins      []string `mapstructure:"admins"`
+	AdminGroups []string `mapstructure:"admin_groups"`
+}
+
+func (c *Config) ApplyDefaults() {
+	if c.Prefix == "" {
+		c.Prefix = "jobs"
+	}
+}
+
+type svc struct {
+	conf   *Config
+	router *chi.Mux
+}
+
+// New returns a new jobs service. The runs are handled by the runner of
+// the jobs serverless service, which must run in the same process.
+func New(ctx context.Context, m map[string]any) (global.Service, error) {
+	var c Config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	s := &svc{
+		conf:   &c,
+		router: chi.NewRouter(),
+	}
+	s.router.Post("/runs", s.handleEnqueue)
+	s.router.Get("/runs", s.handleList)
+	s.router.Get("/runs/{id}", s.handleStatus)
+	s.router.Delete("/runs/{id}", s.handleCancel)
+	return s, nil
+}
+
+// Close performs cleanup.
+func (s *svc) Close() error {
+	return nil
+}
+
+func (s *svc) Prefix() string {
+	return s.conf.Prefix
+}
+
+func (s *svc) Unprotected() []string {
+	return []string{}
+}
+
+func (s *svc) Handler() http.Handler {
+	return s.router
+}
+
+func (s *svc) isAdmin(ctx context.Context) bool {
+	u, ok := appctx.ContextGetUser(ctx)
+	return ok && utils.IsAdmin(u, s.conf.Admins, s.conf.AdminGroups)
+}
+
+// EnqueueRequest is the body of a request enqueueing a job.
+type EnqueueRequest struct {
+	Job    string       `json:"job"`
+	Params rjobs.Params `json:"params"`
+}
+
+// Run is the representation of a run returned by the service.
+type Run struct {
+	ID         string       `json:"id"`
+	Job        string       `json:"job"`
+	State      rjobs.State  `json:"state"`
+	Attempt    int          `json:"attempt"`
+	Owner      string       `json:"owner"`
+	EnqueuedAt time.Time    `json:"enqueued_at"`
+	StartedAt  *time.Time   `json:"started_at,omitempty"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	LastError  string       `json:"last_error,omitempty"`
+	Result     rjobs.Params `json:"result,omitempty"`
+}
+
+func newRun(st rjobs.Status) *Run {
+	return &Run{
+		ID:         string(st.RunID),
+		Job:        st.Job,
+		State:      st.State,
+		Attempt:    st.Attempt,
+		Owner:      st.Owner,
+		EnqueuedAt: st.EnqueuedAt,
+		StartedAt:  st.StartedAt,
+		FinishedAt: st.FinishedAt,
+		LastError:  st.LastError,
+		Result:     st.Result,
+	}
+}
+
+func (s *svc) handleEnqueue(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	if !s.isAdmin(ctx) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	runner := rjobs.Default()
+	if runner == nil {
+		http.Error(w, "the jobs service is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req EnqueueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Job == "" {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	u := appctx.ContextMustGetUser(ctx)
+	id, err := runner.Enqueue(ctx, req.Job, req.Params, rjobs.WithOwner(u.Username))
+	if err != nil {
+		log.Error().Err(err).Str("job", req.Job).Msg("jobs: error enqueueing job")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Info().Str("job", req.Job).Str("run", string(id)).Msg("jobs: job enqueued")
+
+	st, err := runner.Status(ctx, id)
+	if err != nil {
+		st = rjobs.Status{RunID: id, Job: req.Job, State: rjobs.StateQueued, Owner: u.Username}
+	}
+	writeJSON(ctx, w, http.StatusCreated, newRun(st))
+}
+
+func (s *svc) handleList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	runner := rjobs.Default()
+	if runner == nil {
+		http.Error(w, "the jobs service is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	u := appctx.ContextMustGetUser(ctx)
+	list, err := runner.ListByOwner(ctx, u.Username, rjobs.ListFilter{Job: r.URL.Query().Get("job")})
+	if err != nil {
+		log.Error().Err(err).Msg("jobs: error listing runs")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	runs := make([]*Run, 0, len(list))
+	for _, st := range list {
+		runs = append(runs, newRun(st))
+	}
+	writeJSON(ctx, w, http.StatusOK, runs)
+}
+
+// getRun returns the status of the run in the request, provided the user
+// owns it or is an administrator.
+func (s *svc) getRun(w http.ResponseWriter, r *http.Request) (*rjobs.Runner, *rjobs.Status, bool) {
+	ctx := r.Context()
+
+	runner := rjobs.Default()
+	if runner == nil {
+		http.Error(w, "the jobs service is not enabled", http.StatusServiceUnavailable)
+		return nil, nil, false
+	}
+
+	st, err := runner.Status(ctx, rjobs.RunID(chi.URLParam(r, "id")))
+	if err != nil {
+		if _, ok := err.(errtypes.IsNotFound); ok {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			appctx.GetLogger(ctx).Error().Err(err).Msg("jobs: error getting run")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return nil, nil, false
+	}
+
+	u := appctx.ContextMustGetUser(ctx)
+	if st.Owner != u.Username && !s.isAdmin(ctx) {
+		// do not disclose the runs of the other users
+		w.WriteHeader(http.StatusNotFound)
+		return nil, nil, false
+	}
+	return runner, &st, true
+}
+
+func (s *svc) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if _, st, ok := s.getRun(w, r); ok {
+		writeJSON(r.Context(), w, http.StatusOK, newRun(*st))
+	}
+}
+
+func (s *svc) handleCancel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	runner, st, ok := s.getRun(w, r)
+	if !ok {
+		return
+	}
+
+	cancelled, err := runner.Cancel(ctx, st.RunID)
+	if err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Str("run", string(st.RunID)).Msg("jobs: error cancelling run")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(ctx, w, http.StatusOK, newRun(cancelled))
+}
+
+func writeJSON(ctx context.Context, w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Msg("jobs: error writing response")
+	}
+}
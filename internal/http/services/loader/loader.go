@@ -22,10 +22,12 @@ import (
 	// Load core HTTP services.
 	_ "github.com/cs3org/reva/v3/internal/http/services/appprovider"
 	_ "github.com/cs3org/reva/v3/internal/http/services/archiver"
+	_ "github.com/cs3org/reva/v3/internal/http/services/audit"
 	_ "github.com/cs3org/reva/v3/internal/http/services/datagateway"
 	_ "github.com/cs3org/reva/v3/internal/http/services/dataprovider"
 	_ "github.com/cs3org/reva/v3/internal/http/services/experimental/overleaf"
 	_ "github.com/cs3org/reva/v3/internal/http/services/helloworld"
+	_ "github.com/cs3org/reva/v3/internal/http/services/jobs"
 	_ "github.com/cs3org/reva/v3/internal/http/services/metrics"
 	_ "github.com/cs3org/reva/v3/internal/http/services/opencloudmesh/ocmd"
 	_ "github.com/cs3org/reva/v3/internal/http/services/owncloud/ocapi"
@@ -38,6 +40,9 @@ import (
 	_ "github.com/cs3org/reva/v3/internal/http/services/preferences"
 	_ "github.com/cs3org/reva/v3/internal/http/services/prometheus"
 	_ "github.com/cs3org/reva/v3/internal/http/services/sciencemesh"
+	_ "github.com/cs3org/reva/v3/internal/http/services/thumbnails"
+	_ "github.com/cs3org/reva/v3/internal/http/services/totp"
 	_ "github.com/cs3org/reva/v3/internal/http/services/wellknown"
+	_ "github.com/cs3org/reva/v3/internal/http/services/wopi"
 	// Add your own service here.
 )
@@ -21,6 +21,7 @@ package ocmd
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -33,6 +34,7 @@ import (
 	"github.com/cs3org/reva/v3/internal/http/services/wellknown"
 	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/ocm/httpsig"
 	"github.com/pkg/errors"
 )
 
@@ -55,6 +57,7 @@ var ErrInvalidParameters = errors.New("invalid parameters")
 // OCMClient is the client for an OCM provider.
 type OCMClient struct {
 	client *http.Client
+	signer *httpsig.Signer
 }
 
 // newOCMTransport returns the HTTP transport used for outbound OCM requests.
@@ -83,6 +86,58 @@ func NewClient(timeout time.Duration, insecure bool) *OCMClient {
 	}
 }
 
+// EnableSigning makes the client sign its requests (RFC 9421) with the PEM
+// private key found in keyFile. keyID must match the key identifier this
+// server publishes in its OCM discovery document.
+func (c *OCMClient) EnableSigning(keyID, keyFile string) error {
+	if keyID == "" {
+		return errtypes.BadRequest("ocm: a signing key id is required to sign requests")
+	}
+	signer, err := httpsig.NewSignerFromFile(keyID, keyFile)
+	if err != nil {
+		return err
+	}
+	c.signer = signer
+	return nil
+}
+
+// newRequest creates an outgoing request with the given body, signed when
+// signing is enabled.
+func (c *OCMClient) newRequest(ctx context.Context, method, url, contentType string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request")
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.signer != nil {
+		if err := c.signer.Sign(req, body); err != nil {
+			return nil, errors.Wrap(err, "error signing request")
+		}
+	}
+	return req, nil
+}
+
+// ResolveKey returns the public key identified by keyID, as published in
+// the OCM discovery document of the server the key id points to. Only https
+// key ids are resolved.
+func (c *OCMClient) ResolveKey(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	u, err := url.Parse(keyID)
+	if err != nil || u.Host == "" {
+		return nil, errtypes.BadRequest("ocm: invalid key id " + keyID)
+	}
+	if u.Scheme != "https" {
+		return nil, errtypes.BadRequest("ocm: key id " + keyID + " is not served over https")
+	}
+	disco, err := c.Discover(ctx, "https://"+u.Host)
+	if err != nil {
+		return nil, err
+	}
+	if disco.PublicKey == nil || disco.PublicKey.KeyID != keyID {
+		return nil, errtypes.NotFound("ocm: key " + keyID + " is not published by " + u.Host)
+	}
+	return httpsig.ParsePublicKeyPEM(disco.PublicKey.PublicKeyPem)
+}
+
 // Discover returns a number of properties used to discover the capabilities offered by a remote cloud storage.
 // https://cs3org.github.io/OCM-API/docs.html?branch=develop&repo=OCM-API&user=cs3org#/paths/~1.well-known~1ocm/get
 func (c *OCMClient) Discover(ctx context.Context, endpoint string) (*wellknown.OcmDiscoveryData, error) {
@@ -163,11 +218,10 @@ func (c *OCMClient) NewShare(ctx context.Context, endpoint string, r *NewShareRe
 
 	log := appctx.GetLogger(ctx)
 	log.Info().Str("url", url).Str("payload", string(body)).Msg("Sending OCM share")
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	req, err := c.newRequest(ctx, http.MethodPost, url, "application/json", body)
 	if err != nil {
-		return nil, errors.Wrap(err, "error creating request")
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -217,11 +271,10 @@ func (c *OCMClient) InviteAccepted(ctx context.Context, endpoint string, r *Invi
 
 	log := appctx.GetLogger(ctx)
 	log.Info().Str("url", url).Str("payload", string(body)).Msg("Sending OCM invite-accepted")
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	req, err := c.newRequest(ctx, http.MethodPost, url, "application/json", body)
 	if err != nil {
-		return nil, errors.Wrap(err, "error creating request")
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -261,10 +314,47 @@ func (c *OCMClient) parseInviteAcceptedResponse(r *http.Response) (*RemoteUser,
 	return nil, errtypes.InternalError(string(body))
 }
 
-// NewNotification sends a notification to the remote end. Not implemented for now.
+// NewNotification sends a notification about a share to the remote end.
 // https://cs3org.github.io/OCM-API/docs.html?branch=develop&repo=OCM-API&user=cs3org#/paths/~1notifications/post
-func (c *OCMClient) NewNotification(ctx context.Context, endpoint string, r *InviteAcceptedRequest) (*RemoteUser, error) {
-	return nil, errtypes.NotSupported("not implemented")
+func (c *OCMClient) NewNotification(ctx context.Context, endpoint string, r *NotificationRequest) error {
+	url, err := url.JoinPath(endpoint, "notifications")
+	if err != nil {
+		return err
+	}
+	body, err := r.toJSON()
+	if err != nil {
+		return err
+	}
+
+	log := appctx.GetLogger(ctx)
+	log.Info().Str("url", url).Str("type", r.NotificationType).Str("providerId", r.ProviderID).Msg("Sending OCM notification")
+	req, err := c.newRequest(ctx, http.MethodPost, url, "application/json", body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error sending request")
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	case http.StatusBadRequest:
+		return ErrInvalidParameters
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrServiceNotTrusted
+	case http.StatusNotFound:
+		return errtypes.NotFound("remote share " + r.ProviderID)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "error decoding response body")
+	}
+	return errtypes.InternalError(string(respBody))
 }
 
 // ExchangeToken performs an OAuth2 authorization_code exchange against the
@@ -277,11 +367,10 @@ func (c *OCMClient) ExchangeToken(ctx context.Context, tokenEndpoint, code, clie
 		values.Set("client_id", clientID)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenEndpoint, strings.NewReader(values.Encode()))
+	req, err := c.newRequest(ctx, http.MethodPost, tokenEndpoint, "application/x-www-form-urlencoded", []byte(values.Encode()))
 	if err != nil {
 		return "", 0, errors.Wrap(err, "error creating token exchange request")
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -38,6 +38,7 @@ import (
 
 type invitesHandler struct {
 	gatewayClient gateway.GatewayAPIClient
+	verifier      *signatureVerifier
 }
 
 func (h *invitesHandler) init(c *config) error {
@@ -72,6 +73,11 @@ func (h *invitesHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.verifier.checkSender(ctx, req.RecipientProvider); err != nil {
+		reqres.WriteError(w, r, reqres.APIErrorUnauthenticated, "request signature does not match the recipient provider", err)
+		return
+	}
+
 	clientIP, err := utils.GetClientIP(r)
 	if err != nil {
 		reqres.WriteError(w, r, reqres.APIErrorServerError, fmt.Sprintf("error retrieving client IP from request: %s", r.RemoteAddr), err)
@@ -19,21 +19,31 @@
 package ocmd
 
 import (
-	"io"
+	"encoding/json"
 	"mime"
 	"net/http"
 
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	ocmincoming "github.com/cs3org/go-cs3apis/cs3/ocm/incoming/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 
 	"github.com/cs3org/reva/v3/internal/http/services/reqres"
 	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
 	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+	"github.com/pkg/errors"
 )
 
-// var validate = validator.New()
+// Keys of the opaque entries carrying a notification to the ocmincoming service.
+const (
+	notificationOpaqueKey = "notification"
+	senderOpaqueKey       = "sender"
+)
 
 type notifHandler struct {
 	gatewayClient gateway.GatewayAPIClient
+	verifier      *signatureVerifier
 }
 
 func (h *notifHandler) init(c *config) error {
@@ -45,62 +55,133 @@ func (h *notifHandler) init(c *config) error {
 	return nil
 }
 
-// type notificationRequest struct {
-//	NotificationType string `json:"notificationType" validate:"required"`
-//	ResourceType     string `json:"resourceType" validate:"required"`
-//	ProviderId       string `json:"providerId" validate:"required"`
-//	Notification 	 ...	`json:"notification"`
-//}
-
-// Example of payload from Nextcloud:
-// {
-//   "notificationType": <one of "SHARE_ACCEPTED", "SHARE_DECLINED", "REQUEST_RESHARE", "SHARE_UNSHARED", "RESHARE_UNDO", "RESHARE_CHANGE_PERMISSION">,
-//   "resourceType" : "file",
-//   "providerId" : <shareId>,
-//   "notification" : {
-//  	"sharedSecret" : <token>,
-//  	"message" : "human-readable message",
-//  	"shareWith" : <user>,
-// 	"senderId" : <user>,
-//  	"shareType" : <type>
-//   }
-// }
-
 // Notifications dispatches any notifications received from remote OCM sites
 // according to the specifications at:
 // https://cs3org.github.io/OCM-API/docs.html?branch=v1.1.0&repo=OCM-API&user=cs3org#/paths/~1notifications/post
+//
+// Notifications about shares received by our users (SHARE_UNSHARED,
+// SHARE_CHANGE_PERMISSION) and about shares created by our users
+// (SHARE_ACCEPTED, SHARE_DECLINED) are handed over to the ocmincoming
+// service, which authenticates them with the secret of the share and checks
+// their signature against the provider of the share.
 func (h *notifHandler) Notifications(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := appctx.GetLogger(ctx)
 	req, err := getNotification(r)
+	// Log whitelist metadata only; notifications carry the shared secret of the share.
+	logEvent := log.Info().Str("remote", r.RemoteAddr).Err(err)
+	if req != nil {
+		logEvent = logEvent.Str("type", req.NotificationType).Str("provider_id", req.ProviderID)
+	}
+	logEvent.Msg("OCM /notifications request received")
 	if err != nil {
 		reqres.WriteError(w, r, reqres.APIErrorInvalidParameter, err.Error(), nil)
 		return
 	}
 
-	// TODO(lopresti) this is all to be implemented. For now we just log what we got
-	log.Debug().Msgf("Received OCM notification: %+v", req)
+	// notifications do not identify their sender before the share they refer
+	// to is known, thus the ocmincoming service checks the sender once it
+	// has resolved the share
+	opaque, err := NotificationOpaque(req, h.verifier.notificationSender(ctx))
+	if err != nil {
+		reqres.WriteError(w, r, reqres.APIErrorServerError, "error encoding notification", err)
+		return
+	}
+
+	var st *rpc.Status
+	switch req.NotificationType {
+	case NotificationShareUnshared:
+		res, err := h.gatewayClient.DeleteOCMIncomingShare(ctx, &ocmincoming.DeleteOCMIncomingShareRequest{Opaque: opaque})
+		if err != nil {
+			reqres.WriteError(w, r, reqres.APIErrorServerError, "error sending a grpc DeleteOCMIncomingShare request", err)
+			return
+		}
+		st = res.Status
+	case NotificationShareChangePermission, NotificationShareAccepted, NotificationShareDeclined:
+		res, err := h.gatewayClient.UpdateOCMIncomingShare(ctx, &ocmincoming.UpdateOCMIncomingShareRequest{Opaque: opaque})
+		if err != nil {
+			reqres.WriteError(w, r, reqres.APIErrorServerError, "error sending a grpc UpdateOCMIncomingShare request", err)
+			return
+		}
+		st = res.Status
+	default:
+		// other notifications are informational for now
+		log.Debug().Str("type", req.NotificationType).Msg("ignoring OCM notification")
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	switch st.Code {
+	case rpc.Code_CODE_OK:
+	case rpc.Code_CODE_NOT_FOUND:
+		reqres.WriteError(w, r, reqres.APIErrorNotFound, "share not found", nil)
+		return
+	case rpc.Code_CODE_PERMISSION_DENIED, rpc.Code_CODE_UNAUTHENTICATED:
+		reqres.WriteError(w, r, reqres.APIErrorUntrustedService, st.Message, nil)
+		return
+	case rpc.Code_CODE_INVALID_ARGUMENT:
+		reqres.WriteError(w, r, reqres.APIErrorInvalidParameter, st.Message, nil)
+		return
+	default:
+		reqres.WriteError(w, r, reqres.APIErrorServerError, "error processing notification", errors.New(st.Message))
+		return
+	}
 
 	// this is to please Nextcloud
 	w.WriteHeader(http.StatusCreated)
 }
 
-func getNotification(r *http.Request) (string, error) { // (*notificationRequest, error)
-	// var req notificationRequest
+func getNotification(r *http.Request) (*NotificationRequest, error) {
+	var req NotificationRequest
 	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
-	if err == nil && contentType == "application/json" {
-		bytes, _ := io.ReadAll(r.Body)
-		return string(bytes), nil
-		// if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		//	return nil, err
-		//}
-		// } else {
-		//	return nil, errors.New("body request not recognised")
+	if err != nil || contentType != "application/json" {
+		return nil, errors.New("malformed OCM /notifications request payload")
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.Wrap(err, "malformed OCM /notifications request")
+	}
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// NotificationOpaque encodes a notification received from the given
+// sender, to be handed over to the ocmincoming service.
+func NotificationOpaque(n *NotificationRequest, sender *NotificationSender) (*types.Opaque, error) {
+	value, err := json.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	from, err := json.Marshal(sender)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Opaque{
+		Map: map[string]*types.OpaqueEntry{
+			notificationOpaqueKey: {Decoder: "json", Value: value},
+			senderOpaqueKey:       {Decoder: "json", Value: from},
+		},
+	}, nil
+}
+
+// NotificationFromOpaque decodes a notification encoded with NotificationOpaque,
+// returning it along with its sender. A notification without sender is only
+// accepted if signed, as if sent to a strict OCM service.
+func NotificationFromOpaque(o *types.Opaque) (*NotificationRequest, *NotificationSender, error) {
+	entry, ok := o.GetMap()[notificationOpaqueKey]
+	if !ok || entry.Decoder != "json" {
+		return nil, nil, errtypes.BadRequest("ocm: missing notification")
+	}
+	var n NotificationRequest
+	if err := json.Unmarshal(entry.Value, &n); err != nil {
+		return nil, nil, errtypes.BadRequest("ocm: malformed notification")
+	}
+	sender := &NotificationSender{Mode: SignatureModeStrict}
+	if e, ok := o.GetMap()[senderOpaqueKey]; ok {
+		if err := json.Unmarshal(e.Value, sender); err != nil {
+			return nil, nil, errtypes.BadRequest("ocm: malformed notification sender")
+		}
 	}
-	return "", nil
-	// validate the request
-	// if err := validate.Struct(req); err != nil {
-	//	return nil, err
-	//}
-	// return &req, nil
+	return &n, sender, nil
 }
@@ -0,0 +1,136 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ocmd
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetNotification(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			body: `{"notificationType":"SHARE_UNSHARED","resourceType":"file","providerId":"42","notification":{"sharedSecret":"secret"}}`,
+		},
+		{
+			name:    "missing provider id",
+			body:    `{"notificationType":"SHARE_UNSHARED","resourceType":"file"}`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			body:    `{"notificationType":`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/notifications", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			n, err := getNotification(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("getNotification() = %v, want an error", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getNotification() unexpected error: %v", err)
+			}
+			if n.ProviderID != "42" || n.Notification.SharedSecret != "secret" {
+				t.Fatalf("getNotification() = %+v", n)
+			}
+		})
+	}
+}
+
+func TestNotificationOpaqueRoundTrip(t *testing.T) {
+	n := &NotificationRequest{
+		NotificationType: NotificationShareChangePermission,
+		ResourceType:     "file",
+		ProviderID:       "42",
+		Notification: &Notification{
+			SharedSecret: "secret",
+			Permissions:  []string{"read", "write"},
+		},
+	}
+	o, err := NotificationOpaque(n, &NotificationSender{Signer: "cernbox.cern.ch", Mode: SignatureModePermissive})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, sender, err := NotificationFromOpaque(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender.Signer != "cernbox.cern.ch" || sender.Mode != SignatureModePermissive {
+		t.Fatalf("sender = %+v", sender)
+	}
+	if got.ProviderID != n.ProviderID || len(got.Notification.Permissions) != 2 {
+		t.Fatalf("NotificationFromOpaque() = %+v, want %+v", got, n)
+	}
+
+	if _, _, err := NotificationFromOpaque(nil); err == nil {
+		t.Fatal("expected an error for a missing notification")
+	}
+}
+
+func TestNotificationSenderCheck(t *testing.T) {
+	signed := &NotificationSender{Signer: "cernbox.cern.ch", Mode: SignatureModePermissive}
+	if err := signed.Check("https://CERNBox.cern.ch", ""); err != nil {
+		t.Fatalf("signer must match its normalized domain: %v", err)
+	}
+	if err := signed.Check("evil.example.org"); err == nil {
+		t.Fatal("signer must not match another domain")
+	}
+	if err := signed.Check(""); err == nil {
+		t.Fatal("signer must not match an unknown domain")
+	}
+
+	unsigned := &NotificationSender{
+		Mode:  SignatureModePermissive,
+		Modes: map[string]string{"cernbox.cern.ch": SignatureModeStrict},
+	}
+	if err := unsigned.Check("example.org"); err != nil {
+		t.Fatalf("expected an unsigned notification from a permissive provider to be accepted, got %v", err)
+	}
+	if err := unsigned.Check("https://cernbox.cern.ch/"); err == nil {
+		t.Fatal("expected an unsigned notification from a strict provider to be rejected")
+	}
+
+	o, err := NotificationOpaque(&NotificationRequest{ProviderID: "42"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(o.Map, senderOpaqueKey)
+	_, sender, err := NotificationFromOpaque(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Check("example.org"); err == nil {
+		t.Fatal("expected an unsigned notification without sender to be rejected")
+	}
+}
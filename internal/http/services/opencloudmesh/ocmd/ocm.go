@@ -46,6 +46,21 @@ type config struct {
 	// provider domain. A match activates auto-registration of the share's remote users
 	// for all OCM share types (embedded shares are always auto-registered).
 	AutoAcceptProviders []string `mapstructure:"auto_accept_providers"`
+	// SignatureMode is how incoming requests are authenticated with HTTP message
+	// signatures: "permissive" verifies signed requests and accepts unsigned ones,
+	// "strict" rejects unsigned requests. Requests with an invalid signature are
+	// always rejected.
+	SignatureMode string `mapstructure:"signature_mode"`
+	// SignatureModes overrides SignatureMode for the given provider domains.
+	SignatureModes map[string]string `mapstructure:"signature_modes"`
+	// KeyCacheTTL is how long, in seconds, the discovered keys of remote providers are cached.
+	KeyCacheTTL int `mapstructure:"key_cache_ttl"`
+	// KeyFailureCacheTTL is how long, in seconds, the failures to resolve a key are cached.
+	KeyFailureCacheTTL int `mapstructure:"key_failure_cache_ttl"`
+	// KeyResolutionsPerMinute bounds the resolutions of keys not found in the caches.
+	KeyResolutionsPerMinute int  `mapstructure:"key_resolutions_per_minute"`
+	ClientTimeout           int  `mapstructure:"client_timeout"`
+	ClientInsecure          bool `mapstructure:"client_insecure"`
 }
 
 func (c *config) ApplyDefaults() {
@@ -56,11 +71,27 @@ func (c *config) ApplyDefaults() {
 	if c.TokenManager == "" {
 		c.TokenManager = "jwt"
 	}
+	if c.SignatureMode == "" {
+		c.SignatureMode = SignatureModePermissive
+	}
+	if c.KeyCacheTTL == 0 {
+		c.KeyCacheTTL = 300
+	}
+	if c.KeyFailureCacheTTL == 0 {
+		c.KeyFailureCacheTTL = 60
+	}
+	if c.KeyResolutionsPerMinute == 0 {
+		c.KeyResolutionsPerMinute = 60
+	}
+	if c.ClientTimeout == 0 {
+		c.ClientTimeout = 10
+	}
 }
 
 type svc struct {
-	Conf   *config
-	router chi.Router
+	Conf     *config
+	router   chi.Router
+	verifier *signatureVerifier
 }
 
 // New returns a new ocmd object, that implements
@@ -99,20 +130,32 @@ func (s *svc) routerInit() error {
 		return err
 	}
 
+	verifier, err := newSignatureVerifier(s.Conf, sharesHandler.gatewayClient)
+	if err != nil {
+		return err
+	}
+	s.verifier = verifier
+	sharesHandler.verifier = verifier
+	invitesHandler.verifier = verifier
+	notifHandler.verifier = verifier
+
 	tokenHandler := new(tokenHandler)
 	if err := tokenHandler.init(s.Conf); err != nil {
 		return err
 	}
 
-	s.router.Post(sharesPath, sharesHandler.CreateShare)
-	s.router.Post(inviteAcceptedPath, invitesHandler.AcceptInvite)
-	s.router.Post(notificationsPath, notifHandler.Notifications)
+	s.router.With(verifier.middleware).Post(sharesPath, sharesHandler.CreateShare)
+	s.router.With(verifier.middleware).Post(inviteAcceptedPath, invitesHandler.AcceptInvite)
+	s.router.With(verifier.middleware).Post(notificationsPath, notifHandler.Notifications)
 	s.router.Post(tokenPath, tokenHandler.ExchangeToken)
 	return nil
 }
 
 // Close performs cleanup.
 func (s *svc) Close() error {
+	if s.verifier != nil {
+		return s.verifier.close()
+	}
 	return nil
 }
 
@@ -59,6 +59,7 @@ type sharesHandler struct {
 	exposeRecipientDisplayName bool
 	machineSecret              string
 	autoAcceptProviders        []*regexp.Regexp
+	verifier                   *signatureVerifier
 }
 
 func (h *sharesHandler) init(c *config) error {
@@ -137,8 +138,12 @@ func (h *sharesHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.verifier.checkSender(ctx, sender.Idp); err != nil {
+		reqres.WriteError(w, r, reqres.APIErrorUnauthenticated, "request signature does not match the sender", err)
+		return
+	}
+
 	// extract the client IP (or the proxied one) from the request and validate it against the allowed providers
-	// TODO(lopresti) this should rather be replaced with signed requests as per more recent OCM specifications
 	senderIP, err := utils.GetClientIP(r)
 	if err != nil {
 		reqres.WriteError(w, r, reqres.APIErrorServerError, fmt.Sprintf("error retrieving client IP from request: %s", r.RemoteAddr), err)
@@ -0,0 +1,293 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ocmd
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ReneKroon/ttlcache/v2"
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/v3/internal/http/services/reqres"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/ocm/httpsig"
+	"github.com/pkg/errors"
+)
+
+// Signature verification modes of incoming OCM requests.
+const (
+	// SignatureModePermissive verifies signed requests and accepts unsigned ones.
+	SignatureModePermissive = "permissive"
+	// SignatureModeStrict rejects unsigned requests.
+	SignatureModeStrict = "strict"
+)
+
+type signerCtxKey struct{}
+
+// signatureVerifier authenticates incoming OCM requests with HTTP message
+// signatures (RFC 9421), resolving the keys of the senders through their
+// OCM discovery document. Keys are only resolved over https from the
+// providers listed by the provider authorizer, the failed resolutions are
+// cached and the resolutions of unknown keys are rate limited, so that
+// forged key ids cannot make the server issue requests at will.
+type signatureVerifier struct {
+	mode     string
+	modes    map[string]string
+	gateway  gateway.GatewayAPIClient
+	client   *OCMClient
+	keys     *ttlcache.Cache
+	failures *ttlcache.Cache
+	misses   *missLimiter
+	replays  *httpsig.ReplayCache
+}
+
+func newSignatureVerifier(c *config, gw gateway.GatewayAPIClient) (*signatureVerifier, error) {
+	for domain, mode := range c.SignatureModes {
+		if mode != SignatureModePermissive && mode != SignatureModeStrict {
+			return nil, errtypes.BadRequest("ocm: invalid signature mode " + mode + " for " + domain)
+		}
+	}
+	if c.SignatureMode != SignatureModePermissive && c.SignatureMode != SignatureModeStrict {
+		return nil, errtypes.BadRequest("ocm: invalid signature mode " + c.SignatureMode)
+	}
+
+	keys := ttlcache.NewCache()
+	_ = keys.SetTTL(time.Duration(c.KeyCacheTTL) * time.Second)
+	failures := ttlcache.NewCache()
+	_ = failures.SetTTL(time.Duration(c.KeyFailureCacheTTL) * time.Second)
+	return &signatureVerifier{
+		mode:     c.SignatureMode,
+		modes:    c.SignatureModes,
+		gateway:  gw,
+		client:   NewClient(time.Duration(c.ClientTimeout)*time.Second, c.ClientInsecure),
+		keys:     keys,
+		failures: failures,
+		misses:   &missLimiter{max: c.KeyResolutionsPerMinute, window: time.Minute},
+		replays:  httpsig.NewReplayCache(),
+	}, nil
+}
+
+func (v *signatureVerifier) close() error {
+	_ = v.replays.Close()
+	_ = v.failures.Close()
+	return v.keys.Close()
+}
+
+// modeFor returns the verification mode that applies to the given provider.
+func (v *signatureVerifier) modeFor(domain string) string {
+	return signatureModeFor(v.mode, v.modes, domain)
+}
+
+// signatureModeFor returns the mode among the given ones that applies to
+// the given provider, falling back to the default mode.
+func signatureModeFor(mode string, modes map[string]string, domain string) string {
+	if m, ok := modes[normalizeDomain(domain)]; ok {
+		return m
+	}
+	return mode
+}
+
+func (v *signatureVerifier) resolveKey(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	if key, err := v.keys.Get(keyID); err == nil {
+		return key.(crypto.PublicKey), nil
+	}
+	if failure, err := v.failures.Get(keyID); err == nil {
+		return nil, failure.(error)
+	}
+	if !v.misses.allow() {
+		return nil, errtypes.PermissionDenied("ocm: too many resolutions of unknown keys, not resolving " + keyID)
+	}
+
+	key, err := v.fetchKey(ctx, keyID)
+	if err != nil {
+		_ = v.failures.Set(keyID, err)
+		return nil, err
+	}
+	_ = v.keys.Set(keyID, key)
+	return key, nil
+}
+
+// fetchKey resolves a key through the discovery document of the server the
+// key id points to, which must be served over https by an authorized provider.
+func (v *signatureVerifier) fetchKey(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	u, err := url.Parse(keyID)
+	if err != nil || u.Host == "" {
+		return nil, errtypes.BadRequest("ocm: invalid key id " + keyID)
+	}
+	if u.Scheme != "https" {
+		return nil, errtypes.BadRequest("ocm: key id " + keyID + " is not served over https")
+	}
+
+	host := strings.ToLower(u.Host)
+	res, err := v.gateway.GetInfoByDomain(ctx, &ocmprovider.GetInfoByDomainRequest{Domain: host})
+	if err != nil {
+		return nil, err
+	}
+	// the authorizer may match domains loosely, the provider must be the host itself
+	if res.Status.Code != rpc.Code_CODE_OK || normalizeDomain(res.ProviderInfo.GetDomain()) != host {
+		return nil, errtypes.PermissionDenied("ocm: key " + keyID + " is not served by an authorized provider")
+	}
+	return v.client.ResolveKey(ctx, keyID)
+}
+
+// missLimiter bounds the number of events in a fixed time window.
+type missLimiter struct {
+	mu     sync.Mutex
+	max    int
+	window time.Duration
+	start  time.Time
+	count  int
+}
+
+func (l *missLimiter) allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Sub(l.start) >= l.window {
+		l.start, l.count = now, 0
+	}
+	if l.count >= l.max {
+		return false
+	}
+	l.count++
+	return true
+}
+
+// middleware verifies the signature of the request, if any, and records
+// the host of the signer in the request context. Requests with an invalid
+// or replayed signature are always rejected, whatever the mode.
+func (v *signatureVerifier) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := appctx.GetLogger(r.Context())
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			reqres.WriteError(w, r, reqres.APIErrorInvalidParameter, "error reading request body", err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		keyID, err := v.replays.Verify(r, body, v.resolveKey, 0)
+		if errors.Is(err, httpsig.ErrNotSigned) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("remote", r.RemoteAddr).Msg("OCM request with invalid signature")
+			reqres.WriteError(w, r, reqres.APIErrorUnauthenticated, "invalid request signature", err)
+			return
+		}
+
+		u, _ := url.Parse(keyID)
+		ctx := context.WithValue(r.Context(), signerCtxKey{}, strings.ToLower(u.Host))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// checkSender checks that a request on behalf of the given provider domain
+// was signed by that provider, or that unsigned requests are accepted from
+// it. An empty domain means the sender is not known from the payload.
+func (v *signatureVerifier) checkSender(ctx context.Context, domain string) error {
+	if v == nil {
+		return nil
+	}
+	signer, signed := signerFromContext(ctx)
+	if !signed {
+		if v.modeFor(domain) == SignatureModeStrict {
+			return errtypes.PermissionDenied("ocm: unsigned request from " + domain)
+		}
+		return nil
+	}
+	if domain != "" && signer != normalizeDomain(domain) {
+		return errtypes.PermissionDenied("ocm: request signed by " + signer + " on behalf of " + domain)
+	}
+	return nil
+}
+
+// signerFromContext returns the host that signed the request, if any.
+func signerFromContext(ctx context.Context) (string, bool) {
+	signer, ok := ctx.Value(signerCtxKey{}).(string)
+	return signer, ok
+}
+
+// notificationSender returns the sender of a notification, to be checked
+// once the share the notification refers to is known.
+func (v *signatureVerifier) notificationSender(ctx context.Context) *NotificationSender {
+	signer, _ := signerFromContext(ctx)
+	if v == nil {
+		return &NotificationSender{Signer: signer, Mode: SignatureModePermissive}
+	}
+	return &NotificationSender{Signer: signer, Mode: v.mode, Modes: v.modes}
+}
+
+// NotificationSender is the sender of a notification: the host that signed
+// it, if any, along with the signature modes of the OCM service, as the
+// provider on behalf of which a notification is sent is only known from the
+// share it refers to.
+type NotificationSender struct {
+	Signer string            `json:"signer,omitempty"`
+	Mode   string            `json:"mode"`
+	Modes  map[string]string `json:"modes,omitempty"`
+}
+
+// Check checks that the notification was signed by one of the given provider
+// domains or, if unsigned, that unsigned requests are accepted from all of
+// them. Empty domains are ignored.
+func (s *NotificationSender) Check(domains ...string) error {
+	var known []string
+	for _, d := range domains {
+		if d != "" {
+			known = append(known, d)
+		}
+	}
+
+	if s.Signer == "" {
+		if len(known) == 0 {
+			known = []string{""}
+		}
+		for _, d := range known {
+			if signatureModeFor(s.Mode, s.Modes, d) != SignatureModePermissive {
+				return errtypes.PermissionDenied("ocm: unsigned notification from " + d)
+			}
+		}
+		return nil
+	}
+	for _, d := range known {
+		if s.Signer == normalizeDomain(d) {
+			return nil
+		}
+	}
+	return errtypes.PermissionDenied("ocm: notification signed by " + s.Signer + " for a share of another provider")
+}
+
+// normalizeDomain returns the host part of a provider domain.
+func normalizeDomain(domain string) string {
+	host, _, _ := strings.Cut(TrimOCMScheme(domain), "/")
+	return strings.ToLower(host)
+}
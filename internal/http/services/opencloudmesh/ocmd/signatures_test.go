@@ -0,0 +1,297 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ocmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/v3/internal/http/services/wellknown"
+	"google.golang.org/grpc"
+)
+
+// providersMockGW is a provider authorizer listing the given domains.
+type providersMockGW struct {
+	gateway.GatewayAPIClient
+	domains []string
+	lookups atomic.Int32
+}
+
+func (m *providersMockGW) GetInfoByDomain(_ context.Context, req *ocmprovider.GetInfoByDomainRequest, _ ...grpc.CallOption) (*ocmprovider.GetInfoByDomainResponse, error) {
+	m.lookups.Add(1)
+	for _, d := range m.domains {
+		// match loosely, as the json authorizer does
+		if strings.Contains(d, req.Domain) {
+			return &ocmprovider.GetInfoByDomainResponse{
+				Status:       &rpc.Status{Code: rpc.Code_CODE_OK},
+				ProviderInfo: &ocmprovider.ProviderInfo{Domain: d},
+			}, nil
+		}
+	}
+	return &ocmprovider.GetInfoByDomainResponse{Status: &rpc.Status{Code: rpc.Code_CODE_NOT_FOUND}}, nil
+}
+
+func testVerifierConfig(mode string) *config {
+	return &config{
+		SignatureMode:           mode,
+		KeyCacheTTL:             60,
+		KeyFailureCacheTTL:      60,
+		KeyResolutionsPerMinute: 10,
+		ClientTimeout:           10,
+		ClientInsecure:          true,
+	}
+}
+
+// newSigningPeer starts a fake OCM server publishing a signing key and
+// returns a client signing with that key.
+func newSigningPeer(t *testing.T) (*httptest.Server, *OCMClient) {
+	t.Helper()
+	srv, client, _ := newCountingSigningPeer(t)
+	return srv, client
+}
+
+// newCountingSigningPeer is newSigningPeer also returning the number of
+// discovery requests served.
+func newCountingSigningPeer(t *testing.T) (*httptest.Server, *OCMClient, *atomic.Int32) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	keyFile := filepath.Join(t.TempDir(), "ocm.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pubDer, _ := x509.MarshalPKIXPublicKey(key.Public())
+	pubPem := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDer}))
+
+	var srv *httptest.Server
+	discoveries := new(atomic.Int32)
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		discoveries.Add(1)
+		_ = json.NewEncoder(w).Encode(&wellknown.OcmDiscoveryData{
+			Enabled:   true,
+			Endpoint:  srv.URL + "/ocm",
+			PublicKey: &wellknown.PublicKey{KeyID: srv.URL + "/ocm#signature", PublicKeyPem: pubPem},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewClient(0, false)
+	if err := client.EnableSigning(srv.URL+"/ocm#signature", keyFile); err != nil {
+		t.Fatal(err)
+	}
+	return srv, client, discoveries
+}
+
+// incoming turns an outgoing request into the one received by the server.
+func incoming(out *http.Request, body string) *http.Request {
+	in := httptest.NewRequest(out.Method, out.URL.RequestURI(), strings.NewReader(body))
+	in.Host = out.URL.Host
+	in.Header = out.Header.Clone()
+	return in
+}
+
+func TestSignatureVerification(t *testing.T) {
+	srv, client := newSigningPeer(t)
+	peer, _ := url.Parse(srv.URL)
+	body := `{"shareWith":"einstein"}`
+
+	tests := []struct {
+		name     string
+		mode     string
+		sign     bool
+		tamper   bool
+		domain   string
+		wantCode int
+	}{
+		{name: "signed", mode: SignatureModeStrict, sign: true, domain: peer.Host, wantCode: http.StatusOK},
+		{name: "unsigned permissive", mode: SignatureModePermissive, domain: peer.Host, wantCode: http.StatusOK},
+		{name: "unsigned strict", mode: SignatureModeStrict, domain: peer.Host, wantCode: http.StatusForbidden},
+		{name: "tampered", mode: SignatureModePermissive, sign: true, tamper: true, domain: peer.Host, wantCode: http.StatusUnauthorized},
+		{name: "signed for another sender", mode: SignatureModePermissive, sign: true, domain: "cernbox.cern.ch", wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := newSignatureVerifier(testVerifierConfig(tt.mode), &providersMockGW{domains: []string{peer.Host}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, _ := http.NewRequest(http.MethodPost, "http://localhost/ocm/shares", nil)
+			if tt.sign {
+				if out, err = client.newRequest(context.Background(), http.MethodPost, "http://localhost/ocm/shares", "application/json", []byte(body)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			sent := body
+			if tt.tamper {
+				sent = `{"shareWith":"marie"}`
+			}
+
+			rr := httptest.NewRecorder()
+			v.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := v.checkSender(r.Context(), tt.domain); err != nil {
+					w.WriteHeader(http.StatusForbidden)
+				}
+			})).ServeHTTP(rr, incoming(out, sent))
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rr.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSignatureReplay(t *testing.T) {
+	srv, client := newSigningPeer(t)
+	peer, _ := url.Parse(srv.URL)
+	body := `{"shareWith":"einstein"}`
+	v, err := newSignatureVerifier(testVerifierConfig(SignatureModeStrict), &providersMockGW{domains: []string{peer.Host}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.close()
+
+	out, err := client.newRequest(context.Background(), http.MethodPost, "http://localhost/ocm/shares", "application/json", []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := v.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, incoming(out, body))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusOK)
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, incoming(out, body))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed request status = %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestSignatureModeOverrides(t *testing.T) {
+	v, err := newSignatureVerifier(&config{
+		SignatureMode:  SignatureModePermissive,
+		SignatureModes: map[string]string{"cernbox.cern.ch": SignatureModeStrict},
+	}, &providersMockGW{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.checkSender(context.Background(), "https://cernbox.cern.ch/"); err == nil {
+		t.Fatal("expected unsigned request from a strict provider to be rejected")
+	}
+	if err := v.checkSender(context.Background(), "example.org"); err != nil {
+		t.Fatalf("expected unsigned request to be accepted, got %v", err)
+	}
+
+	if _, err := newSignatureVerifier(&config{SignatureMode: "lenient"}, &providersMockGW{}); err == nil {
+		t.Fatal("expected an invalid mode to be rejected")
+	}
+}
+
+func TestKeyResolutionRestrictions(t *testing.T) {
+	srv, client, discoveries := newCountingSigningPeer(t)
+	peer, _ := url.Parse(srv.URL)
+	body := `{"shareWith":"einstein"}`
+	send := func(v *signatureVerifier) int {
+		out, err := client.newRequest(context.Background(), http.MethodPost, "http://localhost/ocm/shares", "application/json", []byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		v.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, incoming(out, body))
+		return rr.Code
+	}
+
+	t.Run("unauthorized provider", func(t *testing.T) {
+		// the authorizer only lists a provider whose domain contains the host
+		gw := &providersMockGW{domains: []string{"ocm." + peer.Host}}
+		v, err := newSignatureVerifier(testVerifierConfig(SignatureModeStrict), gw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer v.close()
+
+		before := discoveries.Load()
+		for range 2 {
+			if code := send(v); code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", code, http.StatusUnauthorized)
+			}
+		}
+		if discoveries.Load() != before {
+			t.Fatal("expected no discovery request to an unauthorized provider")
+		}
+		if gw.lookups.Load() != 1 {
+			t.Fatalf("expected the failed resolution to be cached, got %d lookups", gw.lookups.Load())
+		}
+	})
+
+	t.Run("plain http", func(t *testing.T) {
+		gw := &providersMockGW{domains: []string{peer.Host}}
+		v, err := newSignatureVerifier(testVerifierConfig(SignatureModeStrict), gw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer v.close()
+
+		if _, err := v.resolveKey(context.Background(), "http://"+peer.Host+"/ocm#signature"); err == nil {
+			t.Fatal("expected a key id without https to be refused")
+		}
+		if gw.lookups.Load() != 0 {
+			t.Fatal("expected no lookup of a key id without https")
+		}
+	})
+
+	t.Run("rate limited misses", func(t *testing.T) {
+		gw := &providersMockGW{}
+		c := testVerifierConfig(SignatureModeStrict)
+		c.KeyResolutionsPerMinute = 2
+		v, err := newSignatureVerifier(c, gw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer v.close()
+
+		for _, host := range []string{"a.example.org", "b.example.org", "c.example.org"} {
+			_, _ = v.resolveKey(context.Background(), "https://"+host+"/ocm#signature")
+		}
+		if gw.lookups.Load() != 2 {
+			t.Fatalf("expected 2 lookups within the limit, got %d", gw.lookups.Load())
+		}
+	})
+}
@@ -98,6 +98,35 @@ type NewShareResponse struct {
 	RecipientDisplayName string `json:"recipientDisplayName"`
 }
 
+// Notification types, as defined by the OCM specifications.
+const (
+	NotificationShareAccepted         = "SHARE_ACCEPTED"
+	NotificationShareDeclined         = "SHARE_DECLINED"
+	NotificationShareUnshared         = "SHARE_UNSHARED"
+	NotificationShareChangePermission = "SHARE_CHANGE_PERMISSION"
+)
+
+// NotificationRequest contains the payload of an OCM /notifications request.
+// https://cs3org.github.io/OCM-API/docs.html?branch=develop&repo=OCM-API&user=cs3org#/paths/~1notifications/post
+type NotificationRequest struct {
+	NotificationType string        `json:"notificationType" validate:"required"`
+	ResourceType     string        `json:"resourceType"     validate:"required"`
+	ProviderID       string        `json:"providerId"       validate:"required"` // identifier of the share at the provider that created it
+	Notification     *Notification `json:"notification,omitempty"`
+}
+
+// Notification contains the details of an OCM notification.
+type Notification struct {
+	SharedSecret string `json:"sharedSecret,omitempty"` // the secret of the share, authenticating the notification
+	Message      string `json:"message,omitempty"`
+	// Permissions are the new webdav permissions, for SHARE_CHANGE_PERMISSION notifications.
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+func (r *NotificationRequest) toJSON() ([]byte, error) {
+	return json.Marshal(&r)
+}
+
 // Protocols is the list of OCM protocols.
 type Protocols []Protocol
 
@@ -146,12 +175,13 @@ type WebDAV struct {
 	URI          string   `json:"uri"          validate:"required"`
 }
 
-// ToOCMProtocol convert the protocol to a ocm Protocol struct.
-func (w *WebDAV) ToOCMProtocol() *ocm.Protocol {
+// WebDAVSharePermissions converts the permissions of an OCM webdav protocol
+// to CS3 share permissions.
+func WebDAVSharePermissions(permissions []string) *ocm.SharePermissions {
 	perms := &ocm.SharePermissions{
 		Permissions: &providerv1beta1.ResourcePermissions{},
 	}
-	for _, p := range w.Permissions {
+	for _, p := range permissions {
 		switch p {
 		case "read":
 			perms.Permissions.GetPath = true
@@ -164,6 +194,12 @@ func (w *WebDAV) ToOCMProtocol() *ocm.Protocol {
 			perms.Reshare = true
 		}
 	}
+	return perms
+}
+
+// ToOCMProtocol convert the protocol to a ocm Protocol struct.
+func (w *WebDAV) ToOCMProtocol() *ocm.Protocol {
+	perms := WebDAVSharePermissions(w.Permissions)
 	accTypes := []ocm.AccessType{}
 	for _, at := range w.AccessTypes {
 		switch at {
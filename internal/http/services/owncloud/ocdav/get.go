@@ -29,12 +29,14 @@ import (
 	"strings"
 	"time"
 
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	"github.com/cs3org/reva/v3/internal/grpc/services/storageprovider"
 	"github.com/cs3org/reva/v3/internal/http/services/datagateway"
 	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/cs3org/reva/v3/pkg/spaces"
+	"github.com/cs3org/reva/v3/pkg/thumbnails"
 	"github.com/cs3org/reva/v3/pkg/utils"
 	"github.com/rs/zerolog"
 )
@@ -73,6 +75,11 @@ func (s *svc) handleGet(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if r.URL.Query().Get("preview") == "1" {
+		s.handlePreview(ctx, w, r, client, sRes.Info, log)
+		return
+	}
+
 	dReq := &provider.InitiateFileDownloadRequest{Ref: ref}
 	dRes, err := client.InitiateFileDownload(ctx, dReq)
 	if err != nil {
@@ -155,3 +162,25 @@ func (s *svc) handleGet(ctx context.Context, w http.ResponseWriter, r *http.Requ
 	}
 	// TODO we need to send the If-Match etag in the GET to the datagateway to prevent race conditions between stating and reading the file
 }
+
+// handlePreview serves the thumbnail of a file, for the clients
+// asking for ?preview=1 with the x, y, a and format parameters.
+func (s *svc) handlePreview(ctx context.Context, w http.ResponseWriter, r *http.Request, client gateway.GatewayAPIClient, info *provider.ResourceInfo, log zerolog.Logger) {
+	if s.thumbnails == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	req, err := thumbnails.ParseRequest(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	t, err := s.thumbnails.Get(ctx, client, info, req)
+	if err != nil {
+		log.Debug().Err(err).Msg("no preview for the file")
+		w.WriteHeader(thumbnails.HTTPStatus(err))
+		return
+	}
+	thumbnails.Write(w, r, t)
+}
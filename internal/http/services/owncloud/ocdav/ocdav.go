@@ -44,6 +44,7 @@ import (
 	"github.com/cs3org/reva/v3/pkg/rhttp/global"
 	"github.com/cs3org/reva/v3/pkg/rhttp/router"
 	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/thumbnails"
 	"github.com/cs3org/reva/v3/pkg/utils/cfg"
 	"github.com/pkg/errors"
 )
@@ -125,6 +126,7 @@ type Config struct {
 	PublicLinkDownload           *ConfigPublicLinkDownload `mapstructure:"publiclink_download"`
 	DisabledOpenInAppPaths       []string                  `mapstructure:"disabled_open_in_app_paths"`
 	Notifications                map[string]any            `docs:"nil; settings for the notification helper" mapstructure:"notifications"`
+	Thumbnails                   map[string]any            `docs:"nil;Settings of the thumbnails served with the preview parameter, previews are disabled if not set." mapstructure:"thumbnails"`
 	MyOfficeFilesAllowedProjects []string                  `mapstructure:"my_office_files_projects"`
 }
 
@@ -149,6 +151,8 @@ type svc struct {
 	client               *httpclient.Client
 	// Can be nil if notifications are not set up
 	notificationHelper *notificationhelper.NotificationHelper
+	// Can be nil if previews are not enabled
+	thumbnails *thumbnails.Manager
 }
 
 // New returns a new ocdav.
@@ -183,6 +187,11 @@ func New(ctx context.Context, m map[string]any) (global.Service, error) {
 		}
 		s.notificationHelper = nh
 	}
+	if c.Thumbnails != nil {
+		if s.thumbnails, err = thumbnails.New(c.Thumbnails); err != nil {
+			return nil, err
+		}
+	}
 
 	// initialize handlers and set default cigs
 	if err := s.webDavHandler.init(c.WebdavNamespace, true); err != nil {
@@ -202,6 +211,9 @@ func (s *svc) Close() error {
 	if s.notificationHelper != nil {
 		s.notificationHelper.Stop()
 	}
+	if s.thumbnails != nil {
+		return s.thumbnails.Close()
+	}
 	return nil
 }
 
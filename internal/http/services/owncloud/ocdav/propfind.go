@@ -942,6 +942,12 @@ func (s *svc) mdToPropResponse(ctx context.Context, pf *propfindXML, md *provide
 					} else {
 						propstatNotFound.Prop = append(propstatNotFound.Prop, s.newProp("oc:privatelink", ""))
 					}
+				case "has-preview": // both
+					hasPreview := "0"
+					if s.thumbnails != nil && s.thumbnails.HasThumbnail(md) {
+						hasPreview = "1"
+					}
+					propstatOK.Prop = append(propstatOK.Prop, s.newProp("oc:has-preview", hasPreview))
 				case "dDC": // desktop
 					fallthrough
 				case "data-fingerprint": // desktop
@@ -36,6 +36,7 @@ import (
 	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/cs3org/reva/v3/pkg/errtypes"
 	"github.com/cs3org/reva/v3/pkg/notification/trigger"
+	"github.com/cs3org/reva/v3/pkg/publicshare"
 	"github.com/cs3org/reva/v3/pkg/spaces"
 	"github.com/cs3org/reva/v3/pkg/storage/utils/chunking"
 	"github.com/cs3org/reva/v3/pkg/user"
@@ -233,6 +234,8 @@ func (s *svc) handlePut(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		}
 	}
 
+	addFileDropOpaque(r, opaqueMap)
+
 	uReq := &provider.InitiateFileUploadRequest{
 		Ref:    ref,
 		Opaque: &typespb.Opaque{Map: opaqueMap},
@@ -276,6 +279,12 @@ func (s *svc) handlePut(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if p, ok := fileDropPath(uRes.Opaque); ok {
+		// the upload went through a file-drop link, which placed it in a new file
+		sReq = &provider.StatRequest{Ref: &provider.Reference{Path: p}}
+		info = nil
+	}
+
 	var ep, token string
 	for _, p := range uRes.Protocols {
 		if p.Protocol == "simple" {
@@ -443,6 +452,32 @@ func (s *svc) handlePut(ctx context.Context, w http.ResponseWriter, r *http.Requ
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// addFileDropOpaque forwards the details the uploader optionally gives
+// when uploading through a file-drop link.
+func addFileDropOpaque(r *http.Request, opaqueMap map[string]*typespb.OpaqueEntry) {
+	for header, key := range map[string]string{
+		HeaderUploaderName:  publicshare.UploaderNameOpaqueKey,
+		HeaderUploaderEmail: publicshare.UploaderEmailOpaqueKey,
+		HeaderUploadSession: publicshare.UploadSessionOpaqueKey,
+	} {
+		if v := r.Header.Get(header); v != "" {
+			opaqueMap[key] = &typespb.OpaqueEntry{
+				Decoder: "plain",
+				Value:   []byte(v),
+			}
+		}
+	}
+}
+
+// fileDropPath returns the path a file-drop link placed an upload at, if any.
+func fileDropPath(o *typespb.Opaque) (string, bool) {
+	e, ok := o.GetMap()[publicshare.UploadPathOpaqueKey]
+	if !ok {
+		return "", false
+	}
+	return string(e.Value), true
+}
+
 func userInCtxHasUploaderRole(ctx context.Context) bool {
 	u, ok := appctx.ContextGetUser(ctx)
 	if !ok {
@@ -86,7 +86,7 @@ func (s *svc) handleSpacesTusPost(w http.ResponseWriter, r *http.Request, spaceI
 }
 
 func (s *svc) handleTusPost(ctx context.Context, w http.ResponseWriter, r *http.Request, meta map[string]string, ref *provider.Reference, log zerolog.Logger) {
-	w.Header().Add(HeaderAccessControlAllowHeaders, strings.Join([]string{HeaderTusResumable, HeaderUploadLength, HeaderUploadMetadata, HeaderIfMatch}, ", "))
+	w.Header().Add(HeaderAccessControlAllowHeaders, strings.Join([]string{HeaderTusResumable, HeaderUploadLength, HeaderUploadMetadata, HeaderIfMatch, HeaderUploaderName, HeaderUploaderEmail, HeaderUploadSession}, ", "))
 	w.Header().Add(HeaderAccessControlExposeHeaders, strings.Join([]string{HeaderTusResumable, HeaderLocation}, ", "))
 	w.Header().Set(HeaderTusExtension, "creation,creation-with-upload,checksum,expiration")
 
@@ -166,6 +166,8 @@ func (s *svc) handleTusPost(ctx context.Context, w http.ResponseWriter, r *http.
 		}
 	}
 
+	addFileDropOpaque(r, opaqueMap)
+
 	// initiateUpload
 	uReq := &provider.InitiateFileUploadRequest{
 		Ref: ref,
@@ -190,6 +192,11 @@ func (s *svc) handleTusPost(ctx context.Context, w http.ResponseWriter, r *http.
 		return
 	}
 
+	if p, ok := fileDropPath(uRes.Opaque); ok {
+		// the upload went through a file-drop link, which placed it in a new file
+		sReq = &provider.StatRequest{Ref: &provider.Reference{Path: p}}
+	}
+
 	var ep, token string
 	for _, p := range uRes.Protocols {
 		if p.Protocol == "tus" {
@@ -80,6 +80,9 @@ const (
 	HeaderLockID               = "X-Lock-Id"
 	HeaderLockHolder           = "X-Lock-Holder"
 	HeaderDisableVersioning    = "X-Disable-Versioning"
+	HeaderUploaderName         = "X-Uploader-Name"
+	HeaderUploaderEmail        = "X-Uploader-Email"
+	HeaderUploadSession        = "X-Upload-Session"
 )
 
 // WebDavHandler implements a dav endpoint.
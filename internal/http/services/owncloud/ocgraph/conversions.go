@@ -20,6 +20,7 @@ import (
 	ocmconversions "github.com/cs3org/reva/v3/pkg/ocm/conversions"
 	"github.com/cs3org/reva/v3/pkg/permissions"
 	"github.com/cs3org/reva/v3/pkg/spaces"
+	"github.com/cs3org/reva/v3/pkg/thumbnails"
 	"github.com/cs3org/reva/v3/pkg/utils"
 	libregraph "github.com/owncloud/libre-graph-api-go"
 	"github.com/pkg/errors"
@@ -397,6 +398,7 @@ func (s *svc) cs3ReceivedShareToDriveItem(ctx context.Context, rsi *gateway.Rece
 		d.File = &libregraph.OpenGraphFile{
 			MimeType: &rsi.ResourceInfo.MimeType,
 		}
+		d.Thumbnails = s.thumbnailSets(rsi.ResourceInfo)
 	}
 
 	return d, nil
@@ -444,6 +446,7 @@ func (s *svc) cs3ShareToDriveItem(ctx context.Context, info *provider.ResourceIn
 		d.File = &libregraph.OpenGraphFile{
 			MimeType: &info.MimeType,
 		}
+		d.Thumbnails = s.thumbnailSets(info)
 	}
 
 	return d, nil
@@ -672,3 +675,27 @@ func (s *svc) convertShareToSpace(rsi *gateway.ReceivedShareResourceInfo) *libre
 
 	return drive
 }
+
+// thumbnailSets returns the thumbnails of a file served by the thumbnails
+// service, in the sizes of the Graph API.
+func (s *svc) thumbnailSets(info *provider.ResourceInfo) []libregraph.ThumbnailSet {
+	if s.c.ThumbnailsURL == "" || !thumbnails.Supported(info.MimeType) {
+		return nil
+	}
+
+	base, err := url.JoinPath(s.c.ThumbnailsURL, spaces.EncodeToStringifiedResourceID(info.Id))
+	if err != nil {
+		return nil
+	}
+	thumbnail := func(size int) *libregraph.Thumbnail {
+		return &libregraph.Thumbnail{
+			Url: libregraph.PtrString(fmt.Sprintf("%s?x=%d&y=%d&a=1", base, size, size)),
+		}
+	}
+	return []libregraph.ThumbnailSet{{
+		Id:     libregraph.PtrString("0"),
+		Small:  thumbnail(96),
+		Medium: thumbnail(176),
+		Large:  thumbnail(800),
+	}}
+}
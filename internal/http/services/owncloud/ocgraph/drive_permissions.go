@@ -174,9 +174,8 @@ func (s *svc) updateDrivePermissions(w http.ResponseWriter, r *http.Request) {
 	}
 
 	permission := &libregraph.Permission{}
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(permission); err != nil {
+	settings, err := decodeLinkRequest(r.Body, permission)
+	if err != nil {
 		log.Error().Err(err).Interface("Body", r.Body).Msg("failed unmarshalling request body")
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("Failed unmarshalling request body"))
@@ -187,9 +186,14 @@ func (s *svc) updateDrivePermissions(w http.ResponseWriter, r *http.Request) {
 
 	switch genericShare.shareType {
 	case ShareTypeShare, ShareTypeOCMShare:
+		if settings.requested() {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Link settings can only be set on links"))
+			return
+		}
 		s.updateSharePermissions(ctx, w, *genericShare, permission, resourceID, r.Header.Get("Force") == "true")
 	default:
-		s.updateLinkPermissions(ctx, w, genericShare.link, permission, resourceID)
+		s.updateLinkPermissions(ctx, w, genericShare.link, permission, settings, resourceID)
 	}
 }
 
@@ -240,7 +244,7 @@ func (s *svc) deleteDrivePermissions(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *svc) updateLinkPermissions(ctx context.Context, w http.ResponseWriter, link *linkv1beta1.PublicShare, permission *libregraph.Permission, resourceId *provider.ResourceId) {
+func (s *svc) updateLinkPermissions(ctx context.Context, w http.ResponseWriter, link *linkv1beta1.PublicShare, permission *libregraph.Permission, settings *linkSettings, resourceId *provider.ResourceId) {
 	log := appctx.GetLogger(ctx)
 
 	gw, err := s.getClient()
@@ -261,15 +265,26 @@ func (s *svc) updateLinkPermissions(ctx context.Context, w http.ResponseWriter,
 		return
 	}
 
-	updates, err := s.getLinkUpdates(ctx, link, permission, statRes.Info.Type)
+	settingsOpaque, err := settings.opaque(link.GetOpaque())
 	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	updates, err := s.getLinkUpdates(ctx, link, permission, statRes.Info.Type)
+	if _, ok := err.(errtypes.BadRequest); ok {
+		handleBadRequest(ctx, err, w)
+		return
+	}
+	if err != nil && settingsOpaque == nil {
 		log.Error().Err(err).Msg("nothing provided to update")
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("Nothing provided to udpate"))
 		return
 	}
 
-	uRes := &linkv1beta1.UpdatePublicShareResponse{}
+	uRes := &linkv1beta1.UpdatePublicShareResponse{Share: link}
 	lgPerm := &libregraph.Permission{}
 
 	for _, update := range updates {
@@ -302,7 +317,38 @@ func (s *svc) updateLinkPermissions(ctx context.Context, w http.ResponseWriter,
 			return
 		}
 	}
-	_ = json.NewEncoder(w).Encode(lgPerm)
+
+	// link settings are not part of the CS3 update types and travel in the opaque
+	if settingsOpaque != nil {
+		uRes, err = gw.UpdatePublicShare(ctx, &linkv1beta1.UpdatePublicShareRequest{
+			Opaque: settingsOpaque,
+			Ref: &linkv1beta1.PublicShareReference{
+				Spec: &linkv1beta1.PublicShareReference_Id{
+					Id: link.Id,
+				},
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("error updating link settings")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if uRes.Status.Code != rpcv1beta1.Code_CODE_OK {
+			handleRpcStatus(ctx, uRes.Status, "ocgraph: failed to update link settings", w)
+			return
+		}
+		lgPerm, err = s.shareToLibregraphPerm(ctx, &GenericShare{
+			shareType: ShareTypeLink,
+			ID:        uRes.GetShare().GetId().GetOpaqueId(),
+			link:      uRes.GetShare(),
+		})
+		if err != nil || lgPerm == nil {
+			log.Error().Err(err).Any("link", uRes.GetShare()).Any("lgPerm", lgPerm).Msg("error converting updated link to permissions")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	_ = encodeLinkPermission(w, lgPerm, uRes.GetShare())
 }
 
 func (s *svc) updateSharePermissions(ctx context.Context, w http.ResponseWriter, genericShare GenericShare, lgPerm *libregraph.Permission, resourceId *provider.ResourceId, force bool) {
@@ -330,7 +376,7 @@ func (s *svc) updateSharePermissions(ctx context.Context, w http.ResponseWriter,
 	case ShareTypeOCMShare:
 		OCMShareRequest, err := s.getOCMShareUpdateRequest(ctx, lgPerm, statRes.Info.Type, genericShare.ocmshare.Id.OpaqueId)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			handleBadRequest(ctx, err, w)
 			return
 		}
 
@@ -366,7 +412,7 @@ func (s *svc) updateSharePermissions(ctx context.Context, w http.ResponseWriter,
 
 		update, err := s.getShareUpdate(ctx, lgPerm, statRes.Info.Type)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			handleBadRequest(ctx, err, w)
 			return
 		}
 
@@ -853,10 +899,15 @@ func (s *svc) getLinkUpdates(ctx context.Context, link *linkv1beta1.PublicShare,
 				finalExpiration.Set(&maxExpiration)
 			}
 		}
+		// as for new links, the expiration policy applies
+		exp, err := applyExpirationPolicy(s.c.LinkExpiration, finalExpiration.Get())
+		if err != nil {
+			return nil, err
+		}
 		updates = append(updates, &linkv1beta1.UpdatePublicShareRequest_Update{
 			Type: linkv1beta1.UpdatePublicShareRequest_Update_TYPE_EXPIRATION,
 			Grant: &linkv1beta1.Grant{
-				Expiration: nullableTimeToCs3Timestamp(finalExpiration),
+				Expiration: exp,
 			},
 		})
 	}
@@ -953,9 +1004,13 @@ func (s *svc) getLinkUpdates(ctx context.Context, link *linkv1beta1.PublicShare,
 
 func (s *svc) getShareUpdate(ctx context.Context, permission *libregraph.Permission, resourceType provider.ResourceType) (*collaborationv1beta1.UpdateShareRequest_UpdateField, error) {
 	if permission.ExpirationDateTime.IsSet() {
+		exp, err := applyExpirationPolicy(s.c.ShareExpiration, permission.ExpirationDateTime.Get())
+		if err != nil {
+			return nil, err
+		}
 		return &collaborationv1beta1.UpdateShareRequest_UpdateField{
 			Field: &collaborationv1beta1.UpdateShareRequest_UpdateField_Expiration{
-				Expiration: nullableTimeToCs3Timestamp(permission.ExpirationDateTime),
+				Expiration: exp,
 			},
 		}, nil
 	}
@@ -998,9 +1053,13 @@ func (s *svc) getOCMShareUpdateRequest(ctx context.Context, permission *libregra
 		},
 	}
 	if permission.ExpirationDateTime.IsSet() {
+		exp, err := applyExpirationPolicy(s.c.ShareExpiration, permission.ExpirationDateTime.Get())
+		if err != nil {
+			return nil, err
+		}
 		shareRequest.Field = append(shareRequest.Field, &ocm.UpdateOCMShareRequest_UpdateField{
 			Field: &ocm.UpdateOCMShareRequest_UpdateField_Expiration{
-				Expiration: nullableTimeToCs3Timestamp(permission.ExpirationDateTime),
+				Expiration: exp,
 			},
 		})
 	}
@@ -24,6 +24,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"path/filepath"
@@ -37,6 +38,7 @@ import (
 	collaborationv1beta1 "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/projects"
 	"github.com/cs3org/reva/v3/pkg/rhttp/router"
 	"github.com/cs3org/reva/v3/pkg/spaces"
 	"github.com/cs3org/reva/v3/pkg/utils/list"
@@ -109,6 +111,209 @@ func (s *svc) listMySpaces(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// listAllSpaces lists all the project spaces for the administrators, sorted
+// by decreasing usage. The status query parameter selects the projects in
+// the given status, active by default, or in any status if "all".
+func (s *svc) listAllSpaces(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	gw, err := s.getClient()
+	if err != nil {
+		log.Error().Err(err).Msg("error getting grpc client")
+		handleError(ctx, err, w)
+		return
+	}
+
+	status := projects.ProjectStatusActive
+	switch st := r.URL.Query().Get("status"); st {
+	case "":
+	case "all":
+		status = ""
+	default:
+		status = projects.ProjectStatus(st)
+	}
+
+	res, err := gw.ListStorageSpaces(ctx, projects.NewListAllRequest(status))
+	if err != nil {
+		log.Error().Err(err).Msg("error listing all storage spaces")
+		handleError(ctx, err, w)
+		return
+	}
+	if res.Status.Code != rpcv1beta1.Code_CODE_OK {
+		handleRpcStatus(ctx, res.Status, "ocgraph: Failed to list all storage spaces", w)
+		return
+	}
+
+	me := appctx.ContextMustGetUser(ctx)
+	drives := make([]map[string]any, 0, len(res.StorageSpaces))
+	for _, space := range res.StorageSpaces {
+		drive, err := s.projectDrive(ctx, me, space)
+		if err != nil {
+			log.Error().Err(err).Msg("error converting project space")
+			handleError(ctx, err, w)
+			return
+		}
+		drives = append(drives, drive)
+	}
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"value": drives,
+	}); err != nil {
+		log.Error().Err(err).Msg("error marshalling spaces as json")
+		handleError(ctx, err, w)
+		return
+	}
+}
+
+// projectDrive converts a project space to a drive, annotated with the
+// status of the project, the pending quota request and its transitions.
+func (s *svc) projectDrive(ctx context.Context, user *userpb.User, space *provider.StorageSpace) (map[string]any, error) {
+	drive, err := s.cs3StorageSpaceToDrive(ctx, user, space).ToMap()
+	if err != nil {
+		return nil, err
+	}
+	if st := projects.Status(space); st != "" {
+		drive["@libre.graph.project.status"] = st
+	}
+	if q, ok := projects.RequestedQuota(space); ok {
+		drive["@libre.graph.project.requestedQuota"] = q
+	}
+	ts, err := projects.Transitions(space)
+	if err != nil {
+		return nil, err
+	}
+	if len(ts) > 0 {
+		drive["@libre.graph.project.transitions"] = ts
+	}
+	return drive, nil
+}
+
+// createSpace records the request of a new project space, to be approved
+// by the administrators. The use-case of the project can be given in the
+// @libre.graph.project.comment annotation.
+func (s *svc) createSpace(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Error().Err(err).Msg("failed reading request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	drive := &libregraph.Drive{}
+	if err := json.Unmarshal(body, drive); err != nil {
+		log.Error().Err(err).Msg("failed unmarshalling request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var annotations struct {
+		Comment string `json:"@libre.graph.project.comment"`
+	}
+	if err := json.Unmarshal(body, &annotations); err != nil {
+		log.Error().Err(err).Msg("failed unmarshalling request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if drive.Name == "" {
+		handleBadRequest(ctx, errors.New("creating a space requires the space name"), w)
+		return
+	}
+	var quota uint64
+	if drive.Quota != nil && drive.Quota.Total != nil {
+		if *drive.Quota.Total <= 0 {
+			handleBadRequest(ctx, errors.New("the quota of a space must be positive"), w)
+			return
+		}
+		quota = uint64(*drive.Quota.Total)
+	}
+
+	gw, err := s.getClient()
+	if err != nil {
+		log.Error().Err(err).Msg("error getting grpc client")
+		handleError(ctx, err, w)
+		return
+	}
+
+	res, err := gw.CreateStorageSpace(ctx, projects.NewProjectRequest(drive.Name, drive.GetDescription(), quota, annotations.Comment))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to call gateway CreateStorageSpace")
+		handleError(ctx, err, w)
+		return
+	}
+	if res.Status.Code != rpcv1beta1.Code_CODE_OK {
+		handleRpcStatus(ctx, res.Status, "ocgraph: Failed to request the space", w)
+		return
+	}
+
+	created, err := s.projectDrive(ctx, appctx.ContextMustGetUser(ctx), res.StorageSpace)
+	if err != nil {
+		log.Error().Err(err).Msg("error converting project space")
+		handleError(ctx, err, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(created)
+}
+
+type lifecycleRequest struct {
+	Name    string          `json:"name"`
+	Action  projects.Action `json:"action"`
+	Comment string          `json:"comment"`
+}
+
+// spaceLifecycle moves a project space along its lifecycle: the
+// administrators approve or reject the requested projects, archive the
+// active ones and restore the archived ones.
+func (s *svc) spaceLifecycle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	req := &lifecycleRequest{}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(req); err != nil {
+		log.Error().Err(err).Msg("failed unmarshalling request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" || req.Action == "" {
+		handleBadRequest(ctx, errors.New("a lifecycle request requires the space name and the action"), w)
+		return
+	}
+
+	gw, err := s.getClient()
+	if err != nil {
+		log.Error().Err(err).Msg("error getting grpc client")
+		handleError(ctx, err, w)
+		return
+	}
+
+	res, err := gw.UpdateStorageSpace(ctx, projects.NewActionRequest(req.Name, req.Action, req.Comment))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to call gateway UpdateStorageSpace")
+		handleError(ctx, err, w)
+		return
+	}
+	if res.Status.Code != rpcv1beta1.Code_CODE_OK {
+		handleRpcStatus(ctx, res.Status, fmt.Sprintf("ocgraph: Failed to %s the space", req.Action), w)
+		return
+	}
+
+	drive, err := s.projectDrive(ctx, appctx.ContextMustGetUser(ctx), res.StorageSpace)
+	if err != nil {
+		log.Error().Err(err).Msg("error converting project space")
+		handleError(ctx, err, w)
+		return
+	}
+	if req.Action != projects.ActionReject {
+		// the other steps are completed in the background
+		w.WriteHeader(http.StatusAccepted)
+	}
+	_ = json.NewEncoder(w).Encode(drive)
+}
+
 func isMountpointRequest(request *godata.GoDataRequest) bool {
 	if request.Query.Filter == nil {
 		return false
@@ -224,8 +429,11 @@ func (s *svc) cs3StorageSpaceToDrive(ctx context.Context, user *userpb.User, spa
 
 	if space.SpaceType != "personal" {
 		drive.Root = &libregraph.DriveItem{
-			Id:          libregraph.PtrString(space.Id.OpaqueId),
-			Permissions: cs3PermissionsToLibreGraph(user, space.RootInfo.PermissionSet),
+			Id: libregraph.PtrString(space.Id.OpaqueId),
+		}
+		// The administrators list the projects they have no access to
+		if space.RootInfo.PermissionSet != nil {
+			drive.Root.Permissions = cs3PermissionsToLibreGraph(user, space.RootInfo.PermissionSet)
 		}
 	}
 
@@ -436,6 +644,15 @@ func (s *svc) patchSpace(w http.ResponseWriter, r *http.Request) {
 				Description: *update.Description,
 			},
 		}
+	} else if update.Quota != nil && update.Quota.Total != nil {
+		// Assigned by the administrators, requested by the admins of the space
+		if *update.Quota.Total <= 0 {
+			handleBadRequest(ctx, errors.New("the quota of a space must be positive"), w)
+			return
+		}
+		updateRequest.StorageSpace.Quota = &provider.Quota{
+			QuotaMaxBytes: uint64(*update.Quota.Total),
+		}
 	} else {
 		handleBadRequest(ctx, errors.New("Unsupported update type"), w)
 		return
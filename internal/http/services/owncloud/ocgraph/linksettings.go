@@ -0,0 +1,227 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ocgraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/publicshare"
+	libregraph "github.com/owncloud/libre-graph-api-go"
+	"github.com/pkg/errors"
+)
+
+// The link restrictions and file-drop settings are not part of the libregraph
+// models, so they are given and returned as libregraph annotations.
+const (
+	maxDownloadsProperty        = "@libre.graph.maxDownloads"
+	downloadsProperty           = "@libre.graph.downloads"
+	revokeWhenExhaustedProperty = "@libre.graph.revokeWhenExhausted"
+	allowedNetworksProperty     = "@libre.graph.allowedNetworks"
+	fileDropProperty            = "@libre.graph.fileDrop"
+	fileDropSubfoldersProperty  = "@libre.graph.fileDropSubfolders"
+	maxFileSizeProperty         = "@libre.graph.maxFileSize"
+	maxUploadsProperty          = "@libre.graph.maxUploads"
+	maxUploadBytesProperty      = "@libre.graph.maxUploadBytes"
+	uploadsProperty             = "@libre.graph.uploads"
+	uploadedBytesProperty       = "@libre.graph.uploadedBytes"
+)
+
+var linkSettingsProperties = []string{
+	maxDownloadsProperty,
+	revokeWhenExhaustedProperty,
+	allowedNetworksProperty,
+	fileDropProperty,
+	fileDropSubfoldersProperty,
+	maxFileSizeProperty,
+	maxUploadsProperty,
+	maxUploadBytesProperty,
+}
+
+type linkSettings struct {
+	MaxDownloads        *int      `json:"@libre.graph.maxDownloads,omitempty"`
+	RevokeWhenExhausted *bool     `json:"@libre.graph.revokeWhenExhausted,omitempty"`
+	AllowedNetworks     *[]string `json:"@libre.graph.allowedNetworks,omitempty"`
+	FileDrop            *bool     `json:"@libre.graph.fileDrop,omitempty"`
+	FileDropSubfolders  *bool     `json:"@libre.graph.fileDropSubfolders,omitempty"`
+	MaxFileSize         *uint64   `json:"@libre.graph.maxFileSize,omitempty"`
+	MaxUploads          *int      `json:"@libre.graph.maxUploads,omitempty"`
+	MaxUploadBytes      *uint64   `json:"@libre.graph.maxUploadBytes,omitempty"`
+}
+
+// decodeLinkRequest decodes a link request body into v, after taking out
+// the link settings, which are returned separately.
+func decodeLinkRequest(body io.Reader, v any) (*linkSettings, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	settings := map[string]json.RawMessage{}
+	for _, k := range linkSettingsProperties {
+		if f, ok := fields[k]; ok {
+			settings[k] = f
+			delete(fields, k)
+		}
+	}
+
+	ls := &linkSettings{}
+	if len(settings) > 0 {
+		data, _ := json.Marshal(settings)
+		if err := json.Unmarshal(data, ls); err != nil {
+			return nil, errors.Wrap(err, "invalid link settings")
+		}
+	}
+
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return nil, err
+	}
+	return ls, nil
+}
+
+func (ls *linkSettings) restrictionsRequested() bool {
+	return ls != nil && (ls.MaxDownloads != nil || ls.RevokeWhenExhausted != nil || ls.AllowedNetworks != nil)
+}
+
+func (ls *linkSettings) fileDropRequested() bool {
+	return ls != nil && (ls.FileDrop != nil || ls.FileDropSubfolders != nil || ls.MaxFileSize != nil || ls.MaxUploads != nil || ls.MaxUploadBytes != nil)
+}
+
+// requested tells whether any link setting was given.
+func (ls *linkSettings) requested() bool {
+	return ls.restrictionsRequested() || ls.fileDropRequested()
+}
+
+// opaque returns the opaque requesting the given settings, applied on top of
+// the current ones found in the opaque of the link, or nil if none was given.
+func (ls *linkSettings) opaque(current *types.Opaque) (*types.Opaque, error) {
+	var o *types.Opaque
+
+	if ls.restrictionsRequested() {
+		r := &publicshare.Restrictions{}
+		if c, err := publicshare.GetRestrictions(current); err != nil {
+			return nil, err
+		} else if c != nil {
+			r = c
+		}
+		if ls.MaxDownloads != nil {
+			r.MaxDownloads = *ls.MaxDownloads
+		}
+		if ls.RevokeWhenExhausted != nil {
+			r.RevokeWhenExhausted = *ls.RevokeWhenExhausted
+		}
+		if ls.AllowedNetworks != nil {
+			r.AllowedNetworks = *ls.AllowedNetworks
+		}
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+		var err error
+		if o, err = publicshare.SetRestrictions(o, r); err != nil {
+			return nil, err
+		}
+	}
+
+	if ls.fileDropRequested() {
+		fd := &publicshare.FileDrop{}
+		if c, err := publicshare.GetFileDrop(current); err != nil {
+			return nil, err
+		} else if c != nil {
+			fd = c
+		}
+		if ls.FileDrop != nil {
+			fd.Enabled = *ls.FileDrop
+		}
+		if ls.FileDropSubfolders != nil {
+			fd.SubfolderPerSession = *ls.FileDropSubfolders
+		}
+		if ls.MaxFileSize != nil {
+			fd.MaxFileSize = *ls.MaxFileSize
+		}
+		if ls.MaxUploads != nil {
+			fd.MaxUploads = *ls.MaxUploads
+		}
+		if ls.MaxUploadBytes != nil {
+			fd.MaxBytes = *ls.MaxUploadBytes
+		}
+		if err := fd.Validate(); err != nil {
+			return nil, err
+		}
+		var err error
+		if o, err = publicshare.SetFileDrop(o, fd); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+// encodeLinkPermission encodes a link permission, annotated
+// with the restrictions and file-drop settings of the link.
+func encodeLinkPermission(w io.Writer, perm *libregraph.Permission, l *link.PublicShare) error {
+	r, _ := publicshare.GetRestrictions(l.GetOpaque())
+	fd, _ := publicshare.GetFileDrop(l.GetOpaque())
+	if r == nil && fd == nil {
+		return json.NewEncoder(w).Encode(perm)
+	}
+
+	m, err := perm.ToMap()
+	if err != nil {
+		return err
+	}
+	if r != nil {
+		if r.MaxDownloads > 0 {
+			m[maxDownloadsProperty] = r.MaxDownloads
+			m[revokeWhenExhaustedProperty] = r.RevokeWhenExhausted
+		}
+		m[downloadsProperty] = r.Downloads
+		if len(r.AllowedNetworks) > 0 {
+			m[allowedNetworksProperty] = r.AllowedNetworks
+		}
+	}
+	if fd.IsEnabled() {
+		m[fileDropProperty] = true
+		m[fileDropSubfoldersProperty] = fd.SubfolderPerSession
+		if fd.MaxFileSize > 0 {
+			m[maxFileSizeProperty] = fd.MaxFileSize
+		}
+		if fd.MaxUploads > 0 {
+			m[maxUploadsProperty] = fd.MaxUploads
+		}
+		if fd.MaxBytes > 0 {
+			m[maxUploadBytesProperty] = fd.MaxBytes
+		}
+		m[uploadsProperty] = fd.Uploads
+		m[uploadedBytesProperty] = fd.UploadedBytes
+	}
+	return json.NewEncoder(w).Encode(m)
+}
@@ -28,6 +28,7 @@ import (
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/v3/pkg/rhttp/global"
+	"github.com/cs3org/reva/v3/pkg/share/expiration"
 	"github.com/cs3org/reva/v3/pkg/sharedconf"
 	"github.com/cs3org/reva/v3/pkg/utils/cfg"
 	"github.com/go-chi/chi/v5"
@@ -45,6 +46,12 @@ type config struct {
 	BaseURL                    string `mapstructure:"base_url"    validate:"required"`
 	PubRWLinkMaxExpiration     int64  `mapstructure:"pub_rw_link_max_expiration"`
 	PubRWLinkDefaultExpiration int64  `mapstructure:"pub_rw_link_default_expiration"`
+	// Expiration policies enforced when creating shares and public links
+	ShareExpiration expiration.Policy `mapstructure:"share_expiration"`
+	LinkExpiration  expiration.Policy `mapstructure:"link_expiration"`
+	// ThumbnailsURL is the public URL of the thumbnails service,
+	// the drive items have no thumbnails if not set
+	ThumbnailsURL string `mapstructure:"thumbnails_url"`
 }
 
 func (c *config) ApplyDefaults() {
@@ -99,8 +106,11 @@ func (s *svc) initRouter() {
 			r.Patch("/", s.patchMe)
 		})
 		r.Route("/drives", func(r chi.Router) {
+			r.Get("/", s.listAllSpaces)
+			r.Post("/", s.createSpace)
 			r.Get("/{space-id}", s.getSpace)
 			r.Patch("/{space-id}", s.patchSpace)
+			r.Post("/{space-id}/lifecycle", s.spaceLifecycle)
 		})
 		r.Route("/users", func(r chi.Router) {
 			r.Get("/", s.listUsers)
@@ -127,6 +137,7 @@ func (s *svc) initRouter() {
 				r.Patch("/", s.updateReceivedShare)
 				r.Post("/invite", s.share)
 				r.Post("/createLink", s.createLink)
+				r.Get("/shareReport", s.getShareReport)
 				r.Route("/permissions", func(r chi.Router) {
 					r.Get("/", s.getDrivePermissions)
 					r.Patch("/{share-id}", s.updateDrivePermissions)
@@ -0,0 +1,93 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ocgraph
+
+import (
+	"encoding/json"
+	"net/http"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/sharereport"
+	"github.com/pkg/errors"
+)
+
+// getShareReport returns who has access to an item and its subtree, through
+// user and group shares, public links and OCM shares. The report is encoded as
+// JSON, or as CSV when $format=csv; with table=access the CSV contains the
+// effective access table rather than the list of grants.
+func (s *svc) getShareReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	resourceID, err := s.parseResourceID(r)
+	if err != nil {
+		handleBadRequest(ctx, err, w)
+		return
+	}
+
+	gw, err := s.getClient()
+	if err != nil {
+		log.Error().Err(err).Msg("error getting grpc client")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	report, err := sharereport.Build(ctx, gw, &provider.Reference{ResourceId: resourceID}, sharereport.Options{
+		ExpandGroups: r.URL.Query().Get("expandGroups") != "false",
+	})
+	if err != nil {
+		var (
+			notFound errtypes.IsNotFound
+			denied   errtypes.IsPermissionDenied
+			badReq   errtypes.IsBadRequest
+		)
+		switch {
+		case errors.As(err, &notFound):
+			handleCustomError(ctx, err, http.StatusNotFound, w)
+		case errors.As(err, &denied):
+			handleCustomError(ctx, err, http.StatusForbidden, w)
+		case errors.As(err, &badReq):
+			handleBadRequest(ctx, err, w)
+		default:
+			handleCustomError(ctx, err, http.StatusInternalServerError, w)
+		}
+		return
+	}
+
+	switch r.URL.Query().Get("$format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="share-report.csv"`)
+		if r.URL.Query().Get("table") == "access" {
+			err = report.WriteAccessCSV(w)
+		} else {
+			err = report.WriteCSV(w)
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("error writing share report")
+		}
+	default:
+		handleBadRequest(ctx, errtypes.BadRequest("unsupported $format, use json or csv"), w)
+	}
+}
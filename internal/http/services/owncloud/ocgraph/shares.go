@@ -27,6 +27,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	grouppb "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
@@ -46,6 +47,7 @@ import (
 	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/cs3org/reva/v3/pkg/ocm/share"
 	"github.com/cs3org/reva/v3/pkg/permissions"
+	"github.com/cs3org/reva/v3/pkg/share/expiration"
 	"github.com/cs3org/reva/v3/pkg/sharehierarchy"
 	"github.com/cs3org/reva/v3/pkg/spaces"
 	"github.com/cs3org/reva/v3/pkg/utils"
@@ -290,12 +292,11 @@ func (s *svc) share(w http.ResponseWriter, r *http.Request) {
 	}
 	requestedPerms := PermissionsToCS3ResourcePermissions(role.RolePermissions)
 
-	// Then we also set an expiry, if needed
-	var exp *types.Timestamp
-	if invite.ExpirationDateTime != nil {
-		exp = &types.Timestamp{
-			Seconds: uint64(invite.ExpirationDateTime.Unix()),
-		}
+	// Then we also set an expiry, if needed, as allowed by the expiration policy
+	exp, err := applyExpirationPolicy(s.c.ShareExpiration, invite.ExpirationDateTime)
+	if err != nil {
+		handleBadRequest(ctx, err, w)
+		return
 	}
 
 	// Check that the user has share permissions
@@ -423,20 +424,23 @@ func (s *svc) createLink(w http.ResponseWriter, r *http.Request) {
 
 	// Now we decode the request body
 	linkRequest := &libregraph.DriveItemCreateLink{}
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
-	if err = dec.Decode(linkRequest); err != nil {
+	settings, err := decodeLinkRequest(r.Body, linkRequest)
+	if err != nil {
 		log.Error().Err(err).Interface("Body", r.Body).Msg("failed unmarshalling request body")
 		handleBadRequest(ctx, err, w)
 		return
 	}
+	settingsOpaque, err := settings.opaque(nil)
+	if err != nil {
+		handleBadRequest(ctx, err, w)
+		return
+	}
 
-	// Then we also set an expiry, if needed
-	var exp *types.Timestamp
-	if linkRequest.ExpirationDateTime != nil {
-		exp = &types.Timestamp{
-			Seconds: uint64(linkRequest.ExpirationDateTime.Unix()),
-		}
+	// Then we also set an expiry, if needed, as allowed by the expiration policy
+	exp, err := applyExpirationPolicy(s.c.LinkExpiration, linkRequest.ExpirationDateTime)
+	if err != nil {
+		handleBadRequest(ctx, err, w)
+		return
 	}
 
 	// And we set a password, if needed
@@ -466,6 +470,7 @@ func (s *svc) createLink(w http.ResponseWriter, r *http.Request) {
 				Permissions: LinkTypeToPermissions(*linkRequest.Type, statRes.Info.Type),
 			},
 		},
+		Opaque: settingsOpaque,
 	}
 
 	resp, err := gw.CreatePublicShare(ctx, req)
@@ -488,7 +493,7 @@ func (s *svc) createLink(w http.ResponseWriter, r *http.Request) {
 		handleError(ctx, err, w)
 		return
 	}
-	_ = json.NewEncoder(w).Encode(lgPerm)
+	_ = encodeLinkPermission(w, lgPerm, resp.GetShare())
 }
 
 func encodeSpaceIDForShareJail(res *provider.ResourceInfo) string {
@@ -861,3 +866,13 @@ func (s *svc) updateReceivedOCMShare(ctx context.Context, w http.ResponseWriter,
 		return
 	}
 }
+
+// applyExpirationPolicy applies the expiration policy to the requested
+// expiration of a new or updated share, which may be nil.
+func applyExpirationPolicy(p expiration.Policy, requested *time.Time) (*types.Timestamp, error) {
+	exp, err := p.Apply(time.Now(), requested)
+	if err != nil || exp == nil {
+		return nil, err
+	}
+	return &types.Timestamp{Seconds: uint64(exp.Unix())}, nil
+}
@@ -20,6 +20,7 @@ package config
 
 import (
 	"github.com/cs3org/reva/v3/internal/http/services/owncloud/ocs/data"
+	"github.com/cs3org/reva/v3/pkg/share/expiration"
 	"github.com/cs3org/reva/v3/pkg/sharedconf"
 )
 
@@ -47,10 +48,16 @@ type Config struct {
 	ListOCMShares            bool                      `mapstructure:"list_ocm_shares"`
 	Notifications            map[string]any            `mapstructure:"notifications"`
 	EnableSpaces             bool                      `mapstructure:"enable_spaces"`
+	// Driver of the in-app notification inbox, the notifications endpoints are disabled if empty
+	NotificationsInboxDriver  string                    `mapstructure:"notifications_inbox_driver"`
+	NotificationsInboxDrivers map[string]map[string]any `mapstructure:"notifications_inbox_drivers"`
 	// Secret used for the generation of per-user signing keys for signed URLs
 	SigningKeySecret           string `mapstructure:"signing_key_secret"`
 	PubRWLinkMaxExpiration     int64  `mapstructure:"pub_rw_link_max_expiration"`
 	PubRWLinkDefaultExpiration int64  `mapstructure:"pub_rw_link_default_expiration"`
+	// Expiration policies enforced when creating user and group shares, and public links
+	ShareExpiration expiration.Policy `mapstructure:"share_expiration"`
+	LinkExpiration  expiration.Policy `mapstructure:"link_expiration"`
 }
 
 // Init sets sane defaults.
@@ -155,6 +155,27 @@ type ShareData struct {
 	NotifyUploads bool `json:"notify_uploads" xml:"notify_uploads"`
 	// Additional recipients for the file upload to public share notification
 	NotifyUploadsExtraRecipients string `json:"notify_uploads_extra_recipients" xml:"notify_uploads_extra_recipients"`
+	// Maximum number of downloads through the public share, 0 meaning unlimited
+	MaxDownloads int `json:"max_downloads,omitempty" xml:"max_downloads,omitempty"`
+	// Number of downloads done so far through the public share
+	Downloads int `json:"downloads,omitempty" xml:"downloads,omitempty"`
+	// Whether the public share is removed once its downloads are exhausted
+	RevokeWhenExhausted bool `json:"revoke_when_exhausted,omitempty" xml:"revoke_when_exhausted,omitempty"`
+	// Networks the public share can be used from, in CIDR notation
+	AllowedNetworks []string `json:"allowed_networks,omitempty" xml:"allowed_networks>element,omitempty"`
+	// Whether the public share is a file drop
+	FileDrop bool `json:"file_drop,omitempty" xml:"file_drop,omitempty"`
+	// Whether the uploads of each session go to their own subfolder
+	FileDropSubfolders bool `json:"file_drop_subfolders,omitempty" xml:"file_drop_subfolders,omitempty"`
+	// Maximum size of a single upload through the file drop, 0 meaning unlimited
+	MaxFileSize uint64 `json:"max_file_size,omitempty" xml:"max_file_size,omitempty"`
+	// Maximum number of uploads through the file drop, 0 meaning unlimited
+	MaxUploads int `json:"max_uploads,omitempty" xml:"max_uploads,omitempty"`
+	// Maximum total size of the uploads through the file drop, 0 meaning unlimited
+	MaxUploadBytes uint64 `json:"max_upload_bytes,omitempty" xml:"max_upload_bytes,omitempty"`
+	// Number and total size of the uploads done so far through the file drop
+	Uploads       int    `json:"uploads,omitempty" xml:"uploads,omitempty"`
+	UploadedBytes uint64 `json:"uploaded_bytes,omitempty" xml:"uploaded_bytes,omitempty"`
 }
 
 // ShareeData holds share recipient search results.
@@ -244,6 +265,21 @@ func PublicShare2ShareData(share *link.PublicShare, r *http.Request, publicURL s
 	if share.Ctime != nil {
 		sd.STime = share.Ctime.Seconds // TODO CS3 api birth time = btime
 	}
+	if r, _ := publicshare.GetRestrictions(share.Opaque); r != nil {
+		sd.MaxDownloads = r.MaxDownloads
+		sd.Downloads = r.Downloads
+		sd.RevokeWhenExhausted = r.RevokeWhenExhausted
+		sd.AllowedNetworks = r.AllowedNetworks
+	}
+	if fd, _ := publicshare.GetFileDrop(share.Opaque); fd != nil {
+		sd.FileDrop = fd.Enabled
+		sd.FileDropSubfolders = fd.SubfolderPerSession
+		sd.MaxFileSize = fd.MaxFileSize
+		sd.MaxUploads = fd.MaxUploads
+		sd.MaxUploadBytes = fd.MaxBytes
+		sd.Uploads = fd.Uploads
+		sd.UploadedBytes = fd.UploadedBytes
+	}
 
 	// hide password
 	if share.PasswordProtected {
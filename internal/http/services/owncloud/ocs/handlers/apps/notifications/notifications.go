@@ -0,0 +1,208 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package notifications implements the ownCloud notifications API,
+// serving the in-app notification inbox of the users.
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/v3/internal/http/services/owncloud/ocs/config"
+	"github.com/cs3org/reva/v3/internal/http/services/owncloud/ocs/response"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/notification/inbox"
+	"github.com/cs3org/reva/v3/pkg/notification/inbox/registry"
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+)
+
+// Handler implements the notifications endpoints.
+type Handler struct {
+	inbox inbox.Manager
+}
+
+// Notification is the representation of a notification of the inbox in the OCS API.
+type Notification struct {
+	ID         uint     `json:"notification_id" xml:"notification_id"`
+	App        string   `json:"app"             xml:"app"`
+	User       string   `json:"user"            xml:"user"`
+	Datetime   string   `json:"datetime"        xml:"datetime"`
+	ObjectType string   `json:"object_type"     xml:"object_type"`
+	ObjectID   string   `json:"object_id"       xml:"object_id"`
+	Subject    string   `json:"subject"         xml:"subject"`
+	Message    string   `json:"message"         xml:"message"`
+	Link       string   `json:"link"            xml:"link"`
+	Actions    []string `json:"actions"         xml:"actions"`
+	Read       bool     `json:"read"            xml:"read"`
+}
+
+// Init initializes this and any contained handlers.
+func (h *Handler) Init(ctx context.Context, c *config.Config) error {
+	f, ok := registry.NewFuncs[c.NotificationsInboxDriver]
+	if !ok {
+		return errtypes.NotFound(fmt.Sprintf("notifications inbox driver %s not found", c.NotificationsInboxDriver))
+	}
+	mgr, err := f(ctx, c.NotificationsInboxDrivers[c.NotificationsInboxDriver])
+	if err != nil {
+		return errors.Wrap(err, "error initializing the notifications inbox")
+	}
+	h.inbox = mgr
+	return nil
+}
+
+// ListNotifications lists the notifications of the current user.
+// The unread query parameter limits the result to the unread notifications.
+func (h *Handler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	u, ok := appctx.ContextGetUser(ctx)
+	if !ok {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "missing user in context", errors.New("missing user in context"))
+		return
+	}
+
+	unreadOnly, _ := strconv.ParseBool(r.URL.Query().Get("unread"))
+	list, err := h.inbox.List(ctx, recipients(u), unreadOnly)
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error listing notifications", err)
+		return
+	}
+
+	notifications := make([]*Notification, 0, len(list))
+	for _, n := range list {
+		notifications = append(notifications, convert(u, n))
+	}
+	response.WriteOCSSuccess(w, r, notifications)
+}
+
+// GetNotification returns a notification of the current user.
+func (h *Handler) GetNotification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	u, id, ok := parseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := h.inbox.Get(ctx, recipients(u), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response.WriteOCSSuccess(w, r, convert(u, n))
+}
+
+// MarkNotificationRead marks a notification of the current user as read.
+func (h *Handler) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	u, id, ok := parseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.inbox.MarkRead(ctx, recipients(u), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response.WriteOCSSuccess(w, r, nil)
+}
+
+// DeleteNotification deletes a notification of the current user.
+func (h *Handler) DeleteNotification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	u, id, ok := parseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.inbox.Delete(ctx, recipients(u), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response.WriteOCSSuccess(w, r, nil)
+}
+
+// DeleteAllNotifications deletes all the notifications of the current user.
+func (h *Handler) DeleteAllNotifications(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	u, ok := appctx.ContextGetUser(ctx)
+	if !ok {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "missing user in context", errors.New("missing user in context"))
+		return
+	}
+
+	if err := h.inbox.DeleteAll(ctx, recipients(u)); err != nil {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error deleting notifications", err)
+		return
+	}
+	response.WriteOCSSuccess(w, r, nil)
+}
+
+func parseRequest(w http.ResponseWriter, r *http.Request) (*userpb.User, uint, bool) {
+	u, ok := appctx.ContextGetUser(r.Context())
+	if !ok {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "missing user in context", errors.New("missing user in context"))
+		return nil, 0, false
+	}
+
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, "invalid notification id", err)
+		return nil, 0, false
+	}
+	return u, uint(id), true
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var notFound errtypes.NotFound
+	if errors.As(err, &notFound) {
+		response.WriteOCSError(w, r, response.MetaNotFound.StatusCode, "notification not found", nil)
+		return
+	}
+	response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error accessing the notification", err)
+}
+
+// recipients returns the identities the notifications of a user are addressed to.
+func recipients(u *userpb.User) []string {
+	r := []string{u.Username}
+	if u.Mail != "" {
+		r = append(r, u.Mail)
+	}
+	return r
+}
+
+func convert(u *userpb.User, n *inbox.Notification) *Notification {
+	return &Notification{
+		ID:         n.ID,
+		App:        n.App,
+		User:       u.Username,
+		Datetime:   n.CreatedAt.UTC().Format(time.RFC3339),
+		ObjectType: n.ObjectType,
+		ObjectID:   n.ObjectID,
+		Subject:    n.Subject,
+		Message:    n.Message,
+		Link:       n.Link,
+		Actions:    []string{},
+		Read:       n.Read,
+	}
+}
@@ -51,6 +51,12 @@ func (h *Handler) createGroupShare(w http.ResponseWriter, r *http.Request, statI
 	}
 	sharees := strings.Split(shareWith, ",")
 
+	exp, err := h.requestedShareExpiration(r, h.shareExpiration)
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, err.Error(), err)
+		return
+	}
+
 	shares := make([]*conversions.ShareData, 0)
 
 	for _, sharee := range sharees {
@@ -86,6 +92,7 @@ func (h *Handler) createGroupShare(w http.ResponseWriter, r *http.Request, statI
 				Permissions: &collaboration.SharePermissions{
 					Permissions: role.CS3ResourcePermissions(),
 				},
+				Expiration: exp,
 			},
 		}
 
@@ -98,7 +105,7 @@ func (h *Handler) createGroupShare(w http.ResponseWriter, r *http.Request, statI
 			if notify {
 				granter, ok := appctx.ContextGetUser(ctx)
 				if ok {
-					h.SendShareNotification(newShare.ID, granter, groupRes.Group, statInfo)
+					h.SendShareNotification(ctx, newShare.ID, granter, groupRes.Group, statInfo)
 				}
 			}
 		} else {
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -133,6 +134,29 @@ func (h *Handler) createPublicLinkShare(w http.ResponseWriter, r *http.Request,
 		NotifyUploadsExtraRecipients: notifyUploadsExtraRecipients,
 	}
 
+	restrictions, found, err := restrictionsFromRequest(r, nil)
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, err.Error(), err)
+		return
+	}
+	if found {
+		if req.Opaque, err = publicshare.SetRestrictions(req.Opaque, restrictions); err != nil {
+			response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error encoding link restrictions", err)
+			return
+		}
+	}
+	fileDrop, found, err := fileDropFromRequest(r, nil)
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, err.Error(), err)
+		return
+	}
+	if found {
+		if req.Opaque, err = publicshare.SetFileDrop(req.Opaque, fileDrop); err != nil {
+			response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error encoding file drop settings", err)
+			return
+		}
+	}
+
 	endOfDay := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 23, 59, 59, 0, time.Now().Location())
 	maxExpiration := uint64(h.pubRWLinkMaxExpiration.Seconds())
 	defaultExpiration := uint64(h.pubRWLinkDefaultExpiration.Seconds())
@@ -161,6 +185,11 @@ func (h *Handler) createPublicLinkShare(w http.ResponseWriter, r *http.Request,
 		}
 		req.Grant.Expiration = expireTime
 	}
+	req.Grant.Expiration, err = applyExpirationPolicy(h.linkExpiration, req.Grant.Expiration)
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, err.Error(), err)
+		return
+	}
 
 	// set displayname and password protected as arbitrary metadata
 	req.ResourceInfo.ArbitraryMetadata = &provider.ArbitraryMetadata{
@@ -425,6 +454,12 @@ func (h *Handler) updatePublicShare(w http.ResponseWriter, r *http.Request, shar
 				return
 			}
 		}
+		// as for new links, the expiration policy applies
+		newExpiration, err = applyExpirationPolicy(h.linkExpiration, newExpiration)
+		if err != nil {
+			response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, err.Error(), err)
+			return
+		}
 
 		beforeExpiration, _ := json.Marshal(before.Share.Expiration)
 		afterExpiration, _ := json.Marshal(newExpiration)
@@ -504,6 +539,36 @@ func (h *Handler) updatePublicShare(w http.ResponseWriter, r *http.Request, shar
 		})
 	}
 
+	current, err := publicshare.GetRestrictions(before.Share.GetOpaque())
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error decoding link restrictions", err)
+		return
+	}
+	restrictions, restrictionsFound, err := restrictionsFromRequest(r, current)
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, err.Error(), err)
+		return
+	}
+	if restrictionsFound {
+		updatesFound = true
+		logger.Info().Str("shares", "update").Msgf("link restrictions updated to '%+v'", restrictions)
+	}
+
+	currentFileDrop, err := publicshare.GetFileDrop(before.Share.GetOpaque())
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error decoding file drop settings", err)
+		return
+	}
+	fileDrop, fileDropFound, err := fileDropFromRequest(r, currentFileDrop)
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, err.Error(), err)
+		return
+	}
+	if fileDropFound {
+		updatesFound = true
+		logger.Info().Str("shares", "update").Msgf("file drop settings updated to '%+v'", fileDrop)
+	}
+
 	publicShare := before.Share
 
 	// The update API is atomic and requires a single property update at a time,
@@ -533,6 +598,48 @@ func (h *Handler) updatePublicShare(w http.ResponseWriter, r *http.Request, shar
 		return
 	}
 
+	// restrictions and file-drop settings are not part of the CS3 update types and travel in the opaque
+	if restrictionsFound || fileDropFound {
+		var opaque *types.Opaque
+		if restrictionsFound {
+			if opaque, err = publicshare.SetRestrictions(opaque, restrictions); err != nil {
+				response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error encoding link restrictions", err)
+				return
+			}
+		}
+		if fileDropFound {
+			if opaque, err = publicshare.SetFileDrop(opaque, fileDrop); err != nil {
+				response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error encoding file drop settings", err)
+				return
+			}
+		}
+		uRes, err := gwC.UpdatePublicShare(r.Context(), &link.UpdatePublicShareRequest{
+			Opaque: opaque,
+			Ref: &link.PublicShareReference{
+				Spec: &link.PublicShareReference_Id{
+					Id: &link.PublicShareId{
+						OpaqueId: shareID,
+					},
+				},
+			},
+		})
+		if err != nil {
+			log.Err(err).Str("shareID", shareID).Msg("sending restrictions update to public link provider")
+			response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "Error sending update request to public link provider", err)
+			return
+		}
+		switch uRes.Status.Code {
+		case rpc.Code_CODE_OK:
+			publicShare = uRes.Share
+		case rpc.Code_CODE_INVALID_ARGUMENT:
+			response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, uRes.Status.Message, nil)
+			return
+		default:
+			response.WriteOCSError(w, r, response.MetaServerError.StatusCode, uRes.Status.Message, nil)
+			return
+		}
+	}
+
 	statReq := provider.StatRequest{Ref: &provider.Reference{ResourceId: before.Share.ResourceId}}
 
 	statRes, err := gwC.Stat(r.Context(), &statReq)
@@ -589,6 +696,116 @@ func (h *Handler) removePublicShare(w http.ResponseWriter, r *http.Request, shar
 	response.WriteOCSSuccess(w, r, nil)
 }
 
+// restrictionsFromRequest reads the link restrictions given in the request form,
+// on top of the current ones. It reports whether any restriction was given.
+func restrictionsFromRequest(r *http.Request, current *publicshare.Restrictions) (*publicshare.Restrictions, bool, error) {
+	res := &publicshare.Restrictions{}
+	if current != nil {
+		*res = *current
+	}
+	found := false
+
+	if v, ok := r.Form["maxDownloads"]; ok {
+		found = true
+		res.MaxDownloads = 0
+		if v[0] != "" {
+			n, err := strconv.Atoi(v[0])
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid maxDownloads: %s", v[0])
+			}
+			res.MaxDownloads = n
+		}
+	}
+
+	if v, ok := r.Form["revokeWhenExhausted"]; ok {
+		found = true
+		revoke, err := strconv.ParseBool(v[0])
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid revokeWhenExhausted: %s", v[0])
+		}
+		res.RevokeWhenExhausted = revoke
+	}
+
+	if v, ok := r.Form["allowedNetworks"]; ok {
+		found = true
+		res.AllowedNetworks = nil
+		for _, n := range strings.Split(v[0], ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				res.AllowedNetworks = append(res.AllowedNetworks, n)
+			}
+		}
+	}
+
+	if !found {
+		return nil, false, nil
+	}
+	if err := res.Validate(); err != nil {
+		return nil, false, err
+	}
+	return res, true, nil
+}
+
+// fileDropFromRequest reads the file-drop settings given in the request form,
+// on top of the current ones. It reports whether any setting was given.
+func fileDropFromRequest(r *http.Request, current *publicshare.FileDrop) (*publicshare.FileDrop, bool, error) {
+	res := &publicshare.FileDrop{}
+	if current != nil {
+		*res = *current
+	}
+	found := false
+
+	for key, dst := range map[string]*bool{
+		"fileDrop":           &res.Enabled,
+		"fileDropSubfolders": &res.SubfolderPerSession,
+	} {
+		if v, ok := r.Form[key]; ok {
+			found = true
+			b, err := strconv.ParseBool(v[0])
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid %s: %s", key, v[0])
+			}
+			*dst = b
+		}
+	}
+
+	for key, dst := range map[string]*uint64{
+		"maxFileSize":    &res.MaxFileSize,
+		"maxUploadBytes": &res.MaxBytes,
+	} {
+		if v, ok := r.Form[key]; ok {
+			found = true
+			*dst = 0
+			if v[0] != "" {
+				n, err := strconv.ParseUint(v[0], 10, 64)
+				if err != nil {
+					return nil, false, fmt.Errorf("invalid %s: %s", key, v[0])
+				}
+				*dst = n
+			}
+		}
+	}
+
+	if v, ok := r.Form["maxUploads"]; ok {
+		found = true
+		res.MaxUploads = 0
+		if v[0] != "" {
+			n, err := strconv.Atoi(v[0])
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid maxUploads: %s", v[0])
+			}
+			res.MaxUploads = n
+		}
+	}
+
+	if !found {
+		return nil, false, nil
+	}
+	if err := res.Validate(); err != nil {
+		return nil, false, err
+	}
+	return res, true, nil
+}
+
 func ocPublicPermToCs3(permKey int, h *Handler) (*provider.ResourcePermissions, error) {
 	// TODO refactor this ocPublicPermToRole[permKey] check into a permissions.NewPublicSharePermissions?
 	// not all permissions are possible for public shares
@@ -0,0 +1,90 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package shares
+
+import (
+	"net/http"
+
+	"github.com/cs3org/reva/v3/internal/http/services/owncloud/ocs/response"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/v3/pkg/sharereport"
+	"github.com/pkg/errors"
+)
+
+// ShareReport handles GET requests on /apps/files_sharing/api/v1/shares/report.
+// It lists every grant applying to the given folder and its subtree, together
+// with the resulting effective access. With format=csv the report is returned
+// as a CSV attachment instead of an OCS response; table=access selects the
+// effective access table rather than the list of shares.
+func (h *Handler) ShareReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ref, err := h.extractReference(r)
+	if err != nil || (ref.Path == "" && ref.ResourceId == nil) {
+		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, "missing or invalid path", err)
+		return
+	}
+
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(h.gatewayAddr))
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error getting grpc gateway client", err)
+		return
+	}
+
+	report, err := sharereport.Build(ctx, client, ref, sharereport.Options{
+		ExpandGroups: r.FormValue("expand_groups") != "false",
+	})
+	if err != nil {
+		var (
+			notFound errtypes.IsNotFound
+			denied   errtypes.IsPermissionDenied
+			badReq   errtypes.IsBadRequest
+		)
+		switch {
+		case errors.As(err, &notFound):
+			response.WriteOCSError(w, r, response.MetaNotFound.StatusCode, "resource not found", err)
+		case errors.As(err, &denied):
+			response.WriteOCSError(w, r, response.MetaUnauthorized.StatusCode, "permission denied", err)
+		case errors.As(err, &badReq):
+			response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, err.Error(), err)
+		default:
+			response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error building share report", err)
+		}
+		return
+	}
+
+	switch r.FormValue("format") {
+	case "", "json":
+		response.WriteOCSSuccess(w, r, report)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="share-report.csv"`)
+		if r.FormValue("table") == "access" {
+			err = report.WriteAccessCSV(w)
+		} else {
+			err = report.WriteCSV(w)
+		}
+		if err != nil {
+			h.Log.Error().Err(err).Msg("error writing share report")
+		}
+	default:
+		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, "unsupported format, use json or csv", nil)
+	}
+}
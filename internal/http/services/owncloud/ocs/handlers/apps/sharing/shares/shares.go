@@ -42,11 +42,13 @@ import (
 	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
 	ocmv1beta1 "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/v3/internal/http/services/owncloud/ocdav"
 	"github.com/cs3org/reva/v3/internal/http/services/owncloud/ocs/config"
 	"github.com/cs3org/reva/v3/internal/http/services/owncloud/ocs/conversions"
 	"github.com/cs3org/reva/v3/internal/http/services/owncloud/ocs/response"
 	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
 	"github.com/cs3org/reva/v3/pkg/permissions"
 	"github.com/cs3org/reva/v3/pkg/spaces"
 
@@ -59,6 +61,7 @@ import (
 	"github.com/cs3org/reva/v3/pkg/share/cache"
 	cachereg "github.com/cs3org/reva/v3/pkg/share/cache/registry"
 	warmupreg "github.com/cs3org/reva/v3/pkg/share/cache/warmup/registry"
+	"github.com/cs3org/reva/v3/pkg/share/expiration"
 	"github.com/cs3org/reva/v3/pkg/sharehierarchy"
 	"github.com/cs3org/reva/v3/pkg/utils"
 	"github.com/cs3org/reva/v3/pkg/utils/resourceid"
@@ -70,6 +73,10 @@ import (
 
 const (
 	storageIDPrefix string = "shared::"
+
+	// groupNotificationTimeout bounds the resolution of the members of a
+	// group grantee to notify.
+	groupNotificationTimeout = 10 * time.Minute
 )
 
 // Handler implements the shares part of the ownCloud sharing API.
@@ -91,6 +98,8 @@ type Handler struct {
 	EnableSpaces               bool
 	pubRWLinkMaxExpiration     time.Duration
 	pubRWLinkDefaultExpiration time.Duration
+	shareExpiration            expiration.Policy
+	linkExpiration             expiration.Policy
 }
 
 // we only cache the minimal set of data instead of the full user metadata.
@@ -130,6 +139,8 @@ func (h *Handler) Init(c *config.Config, l *zerolog.Logger) {
 	h.resourceInfoCacheTTL = time.Second * time.Duration(c.ResourceInfoCacheTTL)
 	h.pubRWLinkMaxExpiration = time.Second * time.Duration(c.PubRWLinkMaxExpiration)
 	h.pubRWLinkDefaultExpiration = time.Second * time.Duration(c.PubRWLinkDefaultExpiration)
+	h.shareExpiration = c.ShareExpiration
+	h.linkExpiration = c.LinkExpiration
 	if c.Notifications != nil {
 		nh, err := notificationhelper.New("ocs", c.Notifications, l)
 		// no return value :(
@@ -338,7 +349,7 @@ func (h *Handler) NotifyShare(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		recipient = h.SendShareNotification(opaqueID, granter, granteeRes.User, statInfo)
+		recipient = h.SendShareNotification(ctx, opaqueID, granter, granteeRes.User, statInfo)
 	} else if granteeType == provider.GranteeType_GRANTEE_TYPE_GROUP {
 		granteeID := shareRes.Share.Grantee.GetGroupId().OpaqueId
 		granteeRes, err := c.GetGroupByClaim(ctx, &grouppb.GetGroupByClaimRequest{
@@ -351,7 +362,7 @@ func (h *Handler) NotifyShare(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		recipient = h.SendShareNotification(opaqueID, granter, granteeRes.Group, statInfo)
+		recipient = h.SendShareNotification(ctx, opaqueID, granter, granteeRes.Group, statInfo)
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -363,46 +374,144 @@ func (h *Handler) NotifyShare(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// SendShareNotification sends a notification with information from a Share.
-func (h *Handler) SendShareNotification(opaqueID string, granter *userpb.User, grantee any, statInfo *provider.ResourceInfo) string {
+// SendShareNotification sends a notification with information from a Share,
+// and returns the address of the grantee. The members of a group grantee are
+// resolved in the background and notified one by one, for their notification
+// preferences to apply.
+func (h *Handler) SendShareNotification(ctx context.Context, opaqueID string, granter *userpb.User, grantee any, statInfo *provider.ResourceInfo) string {
 	if h.notificationHelper == nil {
 		return ""
 	}
-	var granteeDisplayName, granteeName, recipient string
-	isGranteeGroup := false
+	data := map[string]any{
+		"granterDisplayName": granter.DisplayName,
+		"granterUserName":    granter.Username,
+		"path":               statInfo.Path,
+		"isFolder":           statInfo.Type == provider.ResourceType_RESOURCE_TYPE_CONTAINER,
+		"base":               filepath.Base(statInfo.Path),
+	}
 
 	if u, ok := grantee.(*userpb.User); ok {
-		granteeDisplayName = u.DisplayName
-		granteeName = u.Username
-		recipient = u.Mail
+		data["granteeDisplayName"] = u.DisplayName
+		data["granteeUserName"] = u.Username
+		data["isGranteeGroup"] = false
+		h.triggerShareNotification(opaqueID, []string{u.Mail}, map[string]string{u.Mail: u.GetId().GetOpaqueId()}, data)
+		return u.Mail
 	} else if g, ok := grantee.(*grouppb.Group); ok {
-		granteeDisplayName = g.DisplayName
-		granteeName = g.GroupName
-		recipient = g.Mail
-		isGranteeGroup = true
-	}
+		data["granteeDisplayName"] = g.DisplayName
+		data["granteeUserName"] = g.GroupName
+		data["isGranteeGroup"] = true
+		// a group may be large: do not hold the request while resolving
+		// its members
+		detached := context.WithoutCancel(ctx)
+		go func() {
+			ctx, cancel := context.WithTimeout(detached, groupNotificationTimeout)
+			defer cancel()
+			recipients, users := h.groupRecipients(ctx, g)
+			h.triggerShareNotification(opaqueID, recipients, users, data)
+		}()
+		return g.Mail
+	}
+	return ""
+}
 
+func (h *Handler) triggerShareNotification(opaqueID string, recipients []string, users map[string]string, data map[string]any) {
+	if len(recipients) == 0 {
+		return
+	}
 	h.notificationHelper.TriggerNotification(&trigger.Trigger{
 		Notification: &notification.Notification{
 			TemplateName: "share-create-mail",
 			Ref:          opaqueID,
-			Recipients:   []string{recipient},
-		},
-		Ref: opaqueID,
-		TemplateData: map[string]any{
-			"granteeDisplayName": granteeDisplayName,
-			"granteeUserName":    granteeName,
-			"granterDisplayName": granter.DisplayName,
-			"granterUserName":    granter.Username,
-			"path":               statInfo.Path,
-			"isFolder":           statInfo.Type == provider.ResourceType_RESOURCE_TYPE_CONTAINER,
-			"isGranteeGroup":     isGranteeGroup,
-			"base":               filepath.Base(statInfo.Path),
+			Recipients:   recipients,
+			Users:        users,
 		},
+		Ref:          opaqueID,
+		TemplateData: data,
 	})
 	h.Log.Debug().Msgf("notification trigger %s created", opaqueID)
+}
+
+// groupRecipients returns the addresses of the members of a group, mapped
+// to their user ids.
+func (h *Handler) groupRecipients(ctx context.Context, g *grouppb.Group) ([]string, map[string]string) {
+	members, err := h.groupMembers(ctx, g)
+	if err != nil {
+		// fall back to the address of the group, with the
+		// default notification preference
+		h.Log.Error().Err(err).Msgf("error resolving the members of group %s, notifying the group", g.GroupName)
+		if g.Mail == "" {
+			return nil, nil
+		}
+		return []string{g.Mail}, map[string]string{}
+	}
+	var recipients []string
+	users := map[string]string{}
+	for _, m := range members {
+		if m.Mail == "" {
+			continue
+		}
+		recipients = append(recipients, m.Mail)
+		users[m.Mail] = m.GetId().GetOpaqueId()
+	}
+	return recipients, users
+}
 
-	return recipient
+// groupMembers returns the users of a group.
+func (h *Handler) groupMembers(ctx context.Context, g *grouppb.Group) ([]*userpb.User, error) {
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(h.gatewayAddr))
+	if err != nil {
+		return nil, err
+	}
+	groupRes, err := client.GetGroup(ctx, &grouppb.GetGroupRequest{GroupId: g.GetId()})
+	switch {
+	case err != nil:
+		return nil, err
+	case groupRes.Status.Code != rpc.Code_CODE_OK:
+		return nil, errtypes.InternalError(groupRes.Status.Message)
+	}
+
+	members := make([]*userpb.User, 0, len(groupRes.Group.Members))
+	for _, id := range groupRes.Group.Members {
+		userRes, err := client.GetUser(ctx, &userpb.GetUserRequest{UserId: id, SkipFetchingUserGroups: true})
+		if err != nil || userRes.Status.Code != rpc.Code_CODE_OK {
+			h.Log.Warn().Err(err).Msgf("error getting member %s of group %s", id.GetOpaqueId(), g.GroupName)
+			continue
+		}
+		members = append(members, userRes.User)
+	}
+	return members, nil
+}
+
+// requestedShareExpiration returns the expiration of a new share, given the
+// optional expireDate form value and the expiration policy.
+func (h *Handler) requestedShareExpiration(r *http.Request, p expiration.Policy) (*types.Timestamp, error) {
+	var requested *types.Timestamp
+	if v := r.FormValue("expireDate"); v != "" {
+		ts, err := conversions.ParseTimestamp(v)
+		if err != nil {
+			return nil, errtypes.BadRequest("invalid datetime format")
+		}
+		requested = ts
+	}
+	return applyExpirationPolicy(p, requested)
+}
+
+// applyExpirationPolicy applies the expiration policy to the requested
+// expiration of a new or updated share, which may be nil.
+func applyExpirationPolicy(p expiration.Policy, requested *types.Timestamp) (*types.Timestamp, error) {
+	if !p.Enabled() {
+		return requested, nil
+	}
+	var t *time.Time
+	if requested != nil {
+		rt := time.Unix(int64(requested.Seconds), 0)
+		t = &rt
+	}
+	exp, err := p.Apply(time.Now(), t)
+	if err != nil || exp == nil {
+		return nil, err
+	}
+	return &types.Timestamp{Seconds: uint64(exp.Unix())}, nil
 }
 
 func (h *Handler) extractPermissions(w http.ResponseWriter, r *http.Request, ri *provider.ResourceInfo, defaultPermissions *permissions.Role) (*permissions.Role, []byte, error) {
@@ -55,6 +55,12 @@ func (h *Handler) createUserShare(w http.ResponseWriter, r *http.Request, statIn
 	}
 	sharees := strings.Split(shareWith, ",")
 
+	exp, err := h.requestedShareExpiration(r, h.shareExpiration)
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, err.Error(), err)
+		return
+	}
+
 	shares := make([]*conversions.ShareData, 0)
 
 	for _, sharee := range sharees {
@@ -91,6 +97,7 @@ func (h *Handler) createUserShare(w http.ResponseWriter, r *http.Request, statIn
 				Permissions: &collaboration.SharePermissions{
 					Permissions: role.CS3ResourcePermissions(),
 				},
+				Expiration: exp,
 			},
 		}
 
@@ -103,7 +110,7 @@ func (h *Handler) createUserShare(w http.ResponseWriter, r *http.Request, statIn
 			if notify {
 				granter, ok := appctx.ContextGetUser(ctx)
 				if ok {
-					h.SendShareNotification(newShare.ID, granter, userRes.User, statInfo)
+					h.SendShareNotification(ctx, newShare.ID, granter, userRes.User, statInfo)
 				}
 			}
 		} else {
@@ -25,6 +25,7 @@ import (
 
 	"github.com/ReneKroon/ttlcache/v2"
 	"github.com/cs3org/reva/v3/internal/http/services/owncloud/ocs/config"
+	"github.com/cs3org/reva/v3/internal/http/services/owncloud/ocs/handlers/apps/notifications"
 	"github.com/cs3org/reva/v3/internal/http/services/owncloud/ocs/handlers/apps/sharing/sharees"
 	"github.com/cs3org/reva/v3/internal/http/services/owncloud/ocs/handlers/apps/sharing/shares"
 	"github.com/cs3org/reva/v3/internal/http/services/owncloud/ocs/handlers/cloud/capabilities"
@@ -62,7 +63,7 @@ func New(ctx context.Context, m map[string]any) (global.Service, error) {
 	}
 
 	log := appctx.GetLogger(ctx)
-	if err := s.routerInit(log); err != nil {
+	if err := s.routerInit(ctx, log); err != nil {
 		return nil, err
 	}
 
@@ -86,7 +87,7 @@ func (s *svc) Unprotected() []string {
 	return []string{"/v1.php/cloud/capabilities", "/v2.php/cloud/capabilities"}
 }
 
-func (s *svc) routerInit(l *zerolog.Logger) error {
+func (s *svc) routerInit(ctx context.Context, l *zerolog.Logger) error {
 	capabilitiesHandler := new(capabilities.Handler)
 	userHandler := new(user.Handler)
 	usersHandler := new(users.Handler)
@@ -100,6 +101,14 @@ func (s *svc) routerInit(l *zerolog.Logger) error {
 	sharesHandler.Init(s.c, l)
 	shareesHandler.Init(s.c)
 
+	var notificationsHandler *notifications.Handler
+	if s.c.NotificationsInboxDriver != "" {
+		notificationsHandler = new(notifications.Handler)
+		if err := notificationsHandler.Init(ctx, s.c); err != nil {
+			return err
+		}
+	}
+
 	s.router.Route("/v{version:(1|2)}.php", func(r chi.Router) {
 		r.Use(response.VersionCtx)
 		r.Route("/apps/files_sharing/api/v1", func(r chi.Router) {
@@ -117,6 +126,7 @@ func (s *svc) routerInit(l *zerolog.Logger) error {
 					r.Get("/", sharesHandler.ListFederatedShares)
 					r.Get("/{shareid}", sharesHandler.GetFederatedShare)
 				})
+				r.Get("/report", sharesHandler.ShareReport)
 				r.Get("/{shareid}", sharesHandler.GetShare)
 				r.Put("/{shareid}", sharesHandler.UpdateShare)
 				r.Get("/{shareid}/notify", sharesHandler.NotifyShare)
@@ -125,6 +135,16 @@ func (s *svc) routerInit(l *zerolog.Logger) error {
 			r.Get("/sharees", shareesHandler.FindSharees)
 		})
 
+		if notificationsHandler != nil {
+			r.Route("/apps/notifications/api/v2/notifications", func(r chi.Router) {
+				r.Get("/", notificationsHandler.ListNotifications)
+				r.Delete("/", notificationsHandler.DeleteAllNotifications)
+				r.Get("/{id}", notificationsHandler.GetNotification)
+				r.Put("/{id}/read", notificationsHandler.MarkNotificationRead)
+				r.Delete("/{id}", notificationsHandler.DeleteNotification)
+			})
+		}
+
 		r.Get("/config", configHandler.GetConfig)
 
 		r.Route("/cloud", func(r chi.Router) {
@@ -22,10 +22,14 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"slices"
 
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	preferences "github.com/cs3org/go-cs3apis/cs3/preferences/v1beta1"
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/appctx"
+	prefs "github.com/cs3org/reva/v3/pkg/preferences"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/status"
 	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/v3/pkg/rhttp/global"
 	"github.com/cs3org/reva/v3/pkg/sharedconf"
@@ -78,6 +82,8 @@ func New(ctx context.Context, m map[string]any) (global.Service, error) {
 func (s *svc) routerInit() error {
 	s.router.Get("/", s.handleGet)
 	s.router.Post("/", s.handlePost)
+	s.router.Post("/bulk", s.handleBulkPost)
+	s.router.Delete("/", s.handleDelete)
 	return nil
 }
 
@@ -106,8 +112,20 @@ func (s *svc) handleGet(w http.ResponseWriter, r *http.Request) {
 
 	key := r.URL.Query().Get("key")
 	ns := r.URL.Query().Get("ns")
+	if denyReserved(w, ns) {
+		return
+	}
+
+	if keys := r.URL.Query()["keys"]; len(keys) > 0 && ns != "" {
+		s.handleGetKeys(w, r, keys, ns)
+		return
+	}
+	if key == "" {
+		s.handleList(w, r, ns)
+		return
+	}
 
-	if key == "" || ns == "" {
+	if ns == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		if _, err := w.Write([]byte("key or namespace query missing")); err != nil {
 			log.Error().Err(err).Msg("error writing to response")
@@ -170,6 +188,9 @@ func (s *svc) handlePost(w http.ResponseWriter, r *http.Request) {
 	key := r.FormValue("key")
 	ns := r.FormValue("ns")
 	val := r.FormValue("value")
+	if denyReserved(w, ns) {
+		return
+	}
 
 	if key == "" || ns == "" || val == "" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -205,3 +226,230 @@ func (s *svc) handlePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// handleList returns all the preferences of the user stored in the
+// given namespace, or in all namespaces if none is given.
+func (s *svc) handleList(w http.ResponseWriter, r *http.Request, ns string) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		log.Error().Err(err).Msg("error getting grpc gateway client")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	entries, err := listKeys(ctx, client, ns)
+	if err != nil {
+		log.Error().Err(err).Msg("error listing keys")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []*prefs.Entry{}
+	}
+
+	writeJSON(w, r, entries)
+}
+
+func (s *svc) handleGetKeys(w http.ResponseWriter, r *http.Request, keys []string, ns string) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		log.Error().Err(err).Msg("error getting grpc gateway client")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	req, err := prefs.NewGetKeysRequest(keys, ns)
+	if err != nil {
+		log.Error().Err(err).Msg("error building request")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	res, err := client.GetKey(ctx, req)
+	if err != nil {
+		log.Error().Err(err).Msg("error retrieving keys")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Error().Interface("status", res.Status).Msg("error retrieving keys")
+		return
+	}
+	values, err := prefs.ReturnedValues(res)
+	if err != nil {
+		log.Error().Err(err).Msg("error decoding keys")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if values == nil {
+		values = map[string]string{}
+	}
+
+	writeJSON(w, r, map[string]any{
+		"namespace": ns,
+		"values":    values,
+	})
+}
+
+type bulkRequest struct {
+	Namespace string            `json:"ns"`
+	Values    map[string]string `json:"values"`
+}
+
+func (s *svc) handleBulkPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	var body bulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Namespace == "" || len(body.Values) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		if _, err := w.Write([]byte("namespace or values missing")); err != nil {
+			log.Error().Err(err).Msg("error writing to response")
+		}
+		return
+	}
+	if denyReserved(w, body.Namespace) {
+		return
+	}
+
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		log.Error().Err(err).Msg("error getting grpc gateway client")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	req, err := prefs.NewSetKeysRequest(body.Values, body.Namespace)
+	if err != nil {
+		log.Error().Err(err).Msg("error building request")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	res, err := client.SetKey(ctx, req)
+	if err != nil {
+		log.Error().Err(err).Msg("error setting keys")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Error().Interface("status", res.Status).Msg("error setting keys")
+		return
+	}
+}
+
+// handleDelete removes a single key when both key and namespace are given,
+// or resets the whole namespace when only the namespace is given.
+func (s *svc) handleDelete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	key := r.URL.Query().Get("key")
+	ns := r.URL.Query().Get("ns")
+
+	if ns == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		if _, err := w.Write([]byte("namespace query missing")); err != nil {
+			log.Error().Err(err).Msg("error writing to response")
+		}
+		return
+	}
+	if denyReserved(w, ns) {
+		return
+	}
+
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		log.Error().Err(err).Msg("error getting grpc gateway client")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	keys := []string{key}
+	if key == "" {
+		entries, err := listKeys(ctx, client, ns)
+		if err != nil {
+			log.Error().Err(err).Msg("error listing keys")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		keys = keys[:0]
+		for _, e := range entries {
+			keys = append(keys, e.Key)
+		}
+	}
+
+	for _, k := range keys {
+		res, err := client.SetKey(ctx, prefs.NewDeleteKeyRequest(k, ns))
+		if err != nil {
+			log.Error().Err(err).Msg("error deleting key")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		switch res.Status.Code {
+		case rpc.Code_CODE_OK:
+		case rpc.Code_CODE_NOT_FOUND:
+			if key != "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Error().Interface("status", res.Status).Msg("error deleting key")
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func listKeys(ctx context.Context, client gateway.GatewayAPIClient, ns string) ([]*prefs.Entry, error) {
+	res, err := client.GetKey(ctx, prefs.NewListKeysRequest(ns))
+	if err != nil {
+		return nil, err
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		return nil, status.NewErrorFromCode(res.Status.Code, "preferences")
+	}
+	entries, err := prefs.ListedEntries(res)
+	if err != nil {
+		return nil, err
+	}
+	// listing all the namespaces leaves out the reserved ones
+	return slices.DeleteFunc(entries, func(e *prefs.Entry) bool {
+		return prefs.IsReserved(e.Namespace)
+	}), nil
+}
+
+// denyReserved refuses the requests on the namespaces reserved to reva,
+// e.g. the one holding the second factor enrollment of the user.
+func denyReserved(w http.ResponseWriter, ns string) bool {
+	if !prefs.IsReserved(ns) {
+		return false
+	}
+	w.WriteHeader(http.StatusForbidden)
+	_, _ = w.Write([]byte("reserved namespace"))
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	log := appctx.GetLogger(r.Context())
+
+	js, err := json.Marshal(v)
+	if err != nil {
+		log.Error().Err(err).Msg("error marshalling response")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(js); err != nil {
+		log.Error().Err(err).Msg("error writing JSON response")
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package thumbnails serves the thumbnails of the files, generated
+// from their content fetched through the gateway.
+package thumbnails
+
+import (
+	"context"
+	"net/http"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/v3/pkg/rhttp/global"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/spaces"
+	"github.com/cs3org/reva/v3/pkg/thumbnails"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/go-chi/chi/v5"
+)
+
+func init() {
+	global.Register("thumbnails", New)
+}
+
+// The configuration of the thumbnails themselves, the cache included,
+// is read from the same map by thumbnails.New.
+type config struct {
+	Prefix     string `docs:"thumbnails;The prefix of the thumbnails endpoints." mapstructure:"prefix"`
+	GatewaySvc string `mapstructure:"gatewaysvc"`
+}
+
+func (c *config) ApplyDefaults() {
+	if c.Prefix == "" {
+		c.Prefix = "thumbnails"
+	}
+	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
+}
+
+type svc struct {
+	conf       *config
+	thumbnails *thumbnails.Manager
+	router     *chi.Mux
+}
+
+// New returns a new thumbnails service.
+func New(ctx context.Context, m map[string]any) (global.Service, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	mgr, err := thumbnails.New(m)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &svc{
+		conf:       &c,
+		thumbnails: mgr,
+		router:     chi.NewRouter(),
+	}
+	s.router.Get("/{resourceid}", s.handleGet)
+	return s, nil
+}
+
+// Close performs cleanup.
+func (s *svc) Close() error {
+	return s.thumbnails.Close()
+}
+
+func (s *svc) Prefix() string {
+	return s.conf.Prefix
+}
+
+func (s *svc) Unprotected() []string {
+	return nil
+}
+
+func (s *svc) Handler() http.Handler {
+	return s.router
+}
+
+// handleGet serves the thumbnail of a file given its resource id, with the
+// size, aspect and format of the thumbnail in the query parameters.
+func (s *svc) handleGet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	id, ok := spaces.ParseResourceID(chi.URLParam(r, "resourceid"))
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	req, err := thumbnails.ParseRequest(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(thumbnails.HTTPStatus(err))
+		return
+	}
+
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		log.Error().Err(err).Msg("thumbnails: error getting gateway client")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res, err := client.Stat(ctx, &provider.StatRequest{Ref: &provider.Reference{ResourceId: id}})
+	switch {
+	case err != nil:
+		log.Error().Err(err).Msg("thumbnails: error sending stat request")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	case res.Status.Code == rpc.Code_CODE_NOT_FOUND:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case res.Status.Code == rpc.Code_CODE_PERMISSION_DENIED:
+		w.WriteHeader(http.StatusForbidden)
+		return
+	case res.Status.Code != rpc.Code_CODE_OK:
+		log.Error().Interface("status", res.Status).Msg("thumbnails: error stating resource")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	t, err := s.thumbnails.Get(ctx, client, res.Info, req)
+	if err != nil {
+		w.WriteHeader(thumbnails.HTTPStatus(err))
+		return
+	}
+	thumbnails.Write(w, r, t)
+}
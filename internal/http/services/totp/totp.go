@@ -0,0 +1,203 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package totp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/auth/totp"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	prefregistry "github.com/cs3org/reva/v3/pkg/preferences/registry"
+	"github.com/cs3org/reva/v3/pkg/rhttp/global"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/go-chi/chi/v5"
+)
+
+func init() {
+	global.Register("totp", New)
+}
+
+// Config holds the config options of the totp HTTP service.
+type Config struct {
+	Prefix string `mapstructure:"prefix"`
+	// Preferences is the driver storing the TOTP enrollment of the users.
+	// It must point to the same storage used by the totp auth manager.
+	Preferences        string                    `mapstructure:"preferences"`
+	PreferencesDrivers map[string]map[string]any `mapstructure:"preferences_drivers"`
+	// EncryptionKey encrypts the shared secrets of the users. It must match
+	// the one of the totp auth manager.
+	EncryptionKey string `mapstructure:"encryption_key"`
+	Skew          int    `mapstructure:"skew"`
+	// Issuer is shown by the authenticator apps next to the account.
+	Issuer string `mapstructure:"issuer"`
+}
+
+func (c *Config) ApplyDefaults() {
+	if c.Prefix == "" {
+		c.Prefix = "totp"
+	}
+	if c.Preferences == "" {
+		c.Preferences = "sql"
+	}
+	if c.Skew == 0 {
+		c.Skew = 1
+	}
+	if c.Issuer == "" {
+		c.Issuer = "reva"
+	}
+}
+
+type svc struct {
+	conf   *Config
+	store  *totp.Store
+	router *chi.Mux
+}
+
+// New returns a new totp service, letting the users manage their second
+// authentication factor.
+func New(ctx context.Context, m map[string]any) (global.Service, error) {
+	var c Config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	f, ok := prefregistry.NewFuncs[c.Preferences]
+	if !ok {
+		return nil, errtypes.NotFound(fmt.Sprintf("totp: driver %s not found for preferences", c.Preferences))
+	}
+	prefs, err := f(ctx, c.PreferencesDrivers[c.Preferences])
+	if err != nil {
+		return nil, err
+	}
+	store, err := totp.NewStore(prefs, c.EncryptionKey, c.Skew)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &svc{
+		conf:   &c,
+		store:  store,
+		router: chi.NewRouter(),
+	}
+	s.router.Post("/enroll", s.handleEnroll)
+	s.router.Post("/confirm", s.handleConfirm)
+	s.router.Post("/disable", s.handleDisable)
+	s.router.Post("/recovery-codes", s.handleRecoveryCodes)
+	return s, nil
+}
+
+// Close performs cleanup.
+func (s *svc) Close() error {
+	return nil
+}
+
+func (s *svc) Prefix() string {
+	return s.conf.Prefix
+}
+
+func (s *svc) Unprotected() []string {
+	return []string{}
+}
+
+func (s *svc) Handler() http.Handler {
+	return s.router
+}
+
+type enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type recoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (s *svc) handleEnroll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	u, ok := appctx.ContextGetUser(ctx)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	secret, err := s.store.StartEnrollment(ctx)
+	if err != nil {
+		writeError(ctx, w, err)
+		return
+	}
+	writeJSON(ctx, w, &enrollment{
+		Secret: secret,
+		URI:    totp.URI(s.conf.Issuer, u.Username, secret),
+	})
+}
+
+func (s *svc) handleConfirm(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	codes, err := s.store.ConfirmEnrollment(ctx, r.FormValue("code"))
+	if err != nil {
+		writeError(ctx, w, err)
+		return
+	}
+	writeJSON(ctx, w, &recoveryCodes{RecoveryCodes: codes})
+}
+
+func (s *svc) handleDisable(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := s.store.Disable(ctx, r.FormValue("code")); err != nil {
+		writeError(ctx, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *svc) handleRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	codes, err := s.store.RegenerateRecoveryCodes(ctx, r.FormValue("code"))
+	if err != nil {
+		writeError(ctx, w, err)
+		return
+	}
+	writeJSON(ctx, w, &recoveryCodes{RecoveryCodes: codes})
+}
+
+func writeJSON(ctx context.Context, w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Msg("totp: error writing response")
+	}
+}
+
+func writeError(ctx context.Context, w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch err.(type) {
+	case errtypes.IsAlreadyExists:
+		code = http.StatusConflict
+	case errtypes.IsNotFound:
+		code = http.StatusNotFound
+	case errtypes.IsInvalidCredentials:
+		code = http.StatusForbidden
+	default:
+		appctx.GetLogger(ctx).Error().Err(err).Msg("totp: error managing second factor")
+	}
+	http.Error(w, err.Error(), code)
+}
@@ -25,6 +25,7 @@ import (
 	"path/filepath"
 
 	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/ocm/httpsig"
 )
 
 const OCMAPIVersion = "1.3.0"
@@ -38,6 +39,8 @@ type OcmProviderConfig struct {
 	EnableWebapp       bool   `docs:"false;Whether web apps are enabled in OCM shares."                                          mapstructure:"enable_webapp"`
 	EnableEmbedded     bool   `docs:"false;Whether embedded shares are enabled in OCM shares."                                   mapstructure:"enable_embedded"`
 	EnableCodeFlow     bool   `docs:"false;Whether code-flow token exchange is enabled in OCM shares."                           mapstructure:"enable_code_flow"`
+	SigningKey         string `docs:";Path to the PEM private key used to sign OCM requests. Its public key is published here."  mapstructure:"signing_key"`
+	SigningKeyID       string `docs:";The identifier of the signing key. Defaults to the OCM endpoint followed by #signature."   mapstructure:"signing_key_id"`
 }
 
 type OcmDiscoveryData struct {
@@ -50,6 +53,14 @@ type OcmDiscoveryData struct {
 	Criteria           []string        `json:"criteria"           xml:"criteria"`
 	InviteAcceptDialog string          `json:"inviteAcceptDialog" xml:"inviteAcceptDialog"`
 	TokenEndPoint      string          `json:"tokenEndPoint,omitempty" xml:"tokenEndPoint,omitempty"`
+	PublicKey          *PublicKey      `json:"publicKey,omitempty"     xml:"publicKey,omitempty"`
+}
+
+// PublicKey is the key other OCM servers use to verify the signatures of
+// the requests sent by this server.
+type PublicKey struct {
+	KeyID        string `json:"keyId"        xml:"keyId"`
+	PublicKeyPem string `json:"publicKeyPem" xml:"publicKeyPem"`
 }
 
 type ResourceTypes struct {
@@ -80,7 +91,7 @@ func (c *OcmProviderConfig) ApplyDefaults() {
 	}
 }
 
-func (h *wkocmHandler) init(c *OcmProviderConfig) {
+func (h *wkocmHandler) init(c *OcmProviderConfig) error {
 	// generates the (static) data structure to be exposed by /.well-known/ocm:
 	// first prepare an empty and disabled payload
 	c.ApplyDefaults()
@@ -98,13 +109,13 @@ func (h *wkocmHandler) init(c *OcmProviderConfig) {
 
 	if c.Endpoint == "" {
 		h.data = d
-		return
+		return nil
 	}
 
 	endpointURL, err := url.Parse(c.Endpoint)
 	if err != nil {
 		h.data = d
-		return
+		return nil
 	}
 
 	// now prepare the enabled one
@@ -154,7 +165,24 @@ func (h *wkocmHandler) init(c *OcmProviderConfig) {
 		d.TokenEndPoint, _ = TokenEndpoint(c.Endpoint, c.OCMPrefix)
 		d.Capabilities = append(d.Capabilities, "exchange-token")
 	}
+	if c.SigningKey != "" {
+		keyID := c.SigningKeyID
+		if keyID == "" {
+			keyID = d.Endpoint + "#signature"
+		}
+		signer, err := httpsig.NewSignerFromFile(keyID, c.SigningKey)
+		if err != nil {
+			return err
+		}
+		pem, err := signer.PublicKeyPEM()
+		if err != nil {
+			return err
+		}
+		d.PublicKey = &PublicKey{KeyID: keyID, PublicKeyPem: pem}
+		d.Capabilities = append(d.Capabilities, "http-sig")
+	}
 	h.data = d
+	return nil
 }
 
 // TokenEndpoint builds the advertised code-flow token endpoint for OCM discovery.
@@ -19,6 +19,12 @@
 package wellknown
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 	"testing"
@@ -95,3 +101,39 @@ func TestInitCapabilitiesDoNotDuplicateExchangeToken(t *testing.T) {
 		t.Errorf("expected exactly 1 exchange-token capability, got %d in %v", count, h.data.Capabilities)
 	}
 }
+
+func TestInitWithSigningKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "ocm.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &wkocmHandler{}
+	if err := h.init(&OcmProviderConfig{
+		Endpoint:   "https://cernbox.cern.ch",
+		SigningKey: keyFile,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if h.data.PublicKey == nil {
+		t.Fatal("expected publicKey to be published")
+	}
+	if h.data.PublicKey.KeyID != "https://cernbox.cern.ch/ocm#signature" {
+		t.Errorf("keyId: got %s", h.data.PublicKey.KeyID)
+	}
+	if !strings.Contains(h.data.PublicKey.PublicKeyPem, "BEGIN PUBLIC KEY") {
+		t.Errorf("unexpected publicKeyPem %s", h.data.PublicKey.PublicKeyPem)
+	}
+	if !slices.Contains(h.data.Capabilities, "http-sig") {
+		t.Errorf("expected http-sig capability, got %v", h.data.Capabilities)
+	}
+}
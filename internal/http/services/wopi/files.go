@@ -0,0 +1,492 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package wopi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/internal/http/services/datagateway"
+	"github.com/cs3org/reva/v3/pkg/app/wopi"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/v3/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// WOPI headers.
+const (
+	headerOverride                = "X-WOPI-Override"
+	headerLock                    = "X-WOPI-Lock"
+	headerOldLock                 = "X-WOPI-OldLock"
+	headerLockFailureReason       = "X-WOPI-LockFailureReason"
+	headerItemVersion             = "X-WOPI-ItemVersion"
+	headerSuggestedTarget         = "X-WOPI-SuggestedTarget"
+	headerRelativeTarget          = "X-WOPI-RelativeTarget"
+	headerOverwriteRelativeTarget = "X-WOPI-OverwriteRelativeTarget"
+	headerValidRelativeTarget     = "X-WOPI-ValidRelativeTarget"
+	headerRequestedName           = "X-WOPI-RequestedName"
+	headerInvalidFileNameError    = "X-WOPI-InvalidFileNameError"
+)
+
+// checkFileInfo is the response of CheckFileInfo, see
+// https://learn.microsoft.com/en-us/microsoft-365/cloud-storage-partner-program/rest/files/checkfileinfo
+type checkFileInfo struct {
+	BaseFileName               string `json:"BaseFileName"`
+	OwnerID                    string `json:"OwnerId"`
+	Size                       uint64 `json:"Size"`
+	Version                    string `json:"Version"`
+	LastModifiedTime           string `json:"LastModifiedTime,omitempty"`
+	UserID                     string `json:"UserId"`
+	UserFriendlyName           string `json:"UserFriendlyName"`
+	UserCanWrite               bool   `json:"UserCanWrite"`
+	UserCanRename              bool   `json:"UserCanRename"`
+	UserCanNotWriteRelative    bool   `json:"UserCanNotWriteRelative"`
+	ReadOnly                   bool   `json:"ReadOnly"`
+	SupportsLocks              bool   `json:"SupportsLocks"`
+	SupportsGetLock            bool   `json:"SupportsGetLock"`
+	SupportsExtendedLockLength bool   `json:"SupportsExtendedLockLength"`
+	SupportsUpdate             bool   `json:"SupportsUpdate"`
+	SupportsRename             bool   `json:"SupportsRename"`
+	BreadcrumbFolderURL        string `json:"BreadcrumbFolderUrl,omitempty"`
+}
+
+func (s *svc) handleCheckFileInfo(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	t := accessTokenFromContext(ctx)
+
+	gw, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	info, st, err := stat(ctx, gw, &provider.Reference{ResourceId: t.FileID})
+	if err != nil || st != nil {
+		writeStatus(w, r, st, err)
+		return
+	}
+
+	canWrite := t.CanWrite() && info.PermissionSet.GetInitiateFileUpload()
+	res := &checkFileInfo{
+		BaseFileName:               path.Base(info.Path),
+		OwnerID:                    info.Owner.GetOpaqueId(),
+		Size:                       info.Size,
+		Version:                    version(info),
+		UserID:                     t.UserID,
+		UserFriendlyName:           t.UserName,
+		UserCanWrite:               canWrite,
+		UserCanRename:              canWrite && info.PermissionSet.GetMove(),
+		UserCanNotWriteRelative:    !canWrite,
+		ReadOnly:                   !canWrite,
+		SupportsLocks:              true,
+		SupportsGetLock:            true,
+		SupportsExtendedLockLength: true,
+		SupportsUpdate:             true,
+		SupportsRename:             true,
+		BreadcrumbFolderURL:        t.FolderURL,
+	}
+	if info.Mtime != nil {
+		res.LastModifiedTime = utils.TSToTime(info.Mtime).UTC().Format(time.RFC3339Nano)
+	}
+	writeJSON(w, r, res)
+}
+
+func (s *svc) handleGetFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	t := accessTokenFromContext(ctx)
+
+	gw, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	ref := &provider.Reference{ResourceId: t.FileID}
+	info, st, err := stat(ctx, gw, ref)
+	if err != nil || st != nil {
+		writeStatus(w, r, st, err)
+		return
+	}
+
+	dRes, err := gw.InitiateFileDownload(ctx, &provider.InitiateFileDownloadRequest{Ref: ref})
+	if err != nil || dRes.Status.Code != rpc.Code_CODE_OK {
+		writeStatus(w, r, dRes.GetStatus(), err)
+		return
+	}
+	var endpoint, token string
+	for _, p := range dRes.Protocols {
+		if p.Protocol == "simple" {
+			endpoint, token = p.DownloadEndpoint, p.Token
+		}
+	}
+	if endpoint == "" {
+		writeError(w, r, errtypes.InternalError("wopi: simple download not supported"))
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	req.Header.Set(datagateway.TokenTransportHeader, token)
+	res, err := s.client.Do(req)
+	if err != nil {
+		writeError(w, r, errors.Wrap(err, "wopi: error downloading file"))
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		writeError(w, r, errtypes.InternalError("wopi: data server responded "+res.Status))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if l := res.Header.Get("Content-Length"); l != "" {
+		w.Header().Set("Content-Length", l)
+	}
+	w.Header().Set(headerItemVersion, version(info))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, res.Body); err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Msg("wopi: error sending file")
+	}
+}
+
+// handlePutFile writes the file, which must be locked with the lock of the
+// request, or be unlocked and empty, as for new documents.
+func (s *svc) handlePutFile(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(headerOverride) != "PUT" {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	ctx := r.Context()
+	lockID := r.Header.Get(headerLock)
+	gw, ref, current, ok := s.lockContext(w, r, true)
+	if !ok {
+		return
+	}
+
+	switch current {
+	case "":
+		info, st, err := stat(ctx, gw, ref)
+		if err != nil || st != nil {
+			writeStatus(w, r, st, err)
+			return
+		}
+		if info.Size != 0 {
+			lockConflict(w, "", "file is not locked")
+			return
+		}
+	case lockID:
+	default:
+		lockConflict(w, current, "lock mismatch")
+		return
+	}
+
+	if st, err := s.upload(ctx, gw, ref, lockID, r.Body, r.ContentLength); err != nil || st != nil {
+		writeStatus(w, r, st, err)
+		return
+	}
+	if info, st, err := stat(ctx, gw, ref); err == nil && st == nil {
+		w.Header().Set(headerItemVersion, version(info))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlePutRelativeFile creates a new file next to the one of the request,
+// with the content of the request, and returns an url to edit it.
+func (s *svc) handlePutRelativeFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	t := accessTokenFromContext(ctx)
+	if !t.CanWrite() {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	suggested, relative := r.Header.Get(headerSuggestedTarget), r.Header.Get(headerRelativeTarget)
+	switch {
+	case suggested != "" && relative != "":
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	case suggested == "" && relative == "":
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	gw, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	info, st, err := stat(ctx, gw, &provider.Reference{ResourceId: t.FileID})
+	if err != nil || st != nil {
+		writeStatus(w, r, st, err)
+		return
+	}
+	dir := path.Dir(info.Path)
+
+	var name string
+	if suggested != "" {
+		// a suggested target starting with a dot is an extension
+		name = suggested
+		if strings.HasPrefix(suggested, ".") {
+			base := path.Base(info.Path)
+			name = strings.TrimSuffix(base, path.Ext(base)) + suggested
+		}
+		if !validName(name) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if name, err = availableName(ctx, gw, dir, name); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	} else {
+		name = relative
+		if !validName(name) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		target := &provider.Reference{Path: path.Join(dir, name)}
+		if _, st, err := stat(ctx, gw, target); err != nil {
+			writeError(w, r, err)
+			return
+		} else if st == nil {
+			// the target exists
+			current, _, _ := getLock(ctx, gw, target)
+			if r.Header.Get(headerOverwriteRelativeTarget) != "true" || current != "" {
+				if valid, err := availableName(ctx, gw, dir, name); err == nil {
+					w.Header().Set(headerValidRelativeTarget, valid)
+				}
+				w.Header().Set(headerLock, current)
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		}
+	}
+
+	target := &provider.Reference{Path: path.Join(dir, name)}
+	if st, err := s.upload(ctx, gw, target, "", r.Body, r.ContentLength); err != nil || st != nil {
+		writeStatus(w, r, st, err)
+		return
+	}
+	newInfo, st, err := stat(ctx, gw, target)
+	if err != nil || st != nil {
+		writeStatus(w, r, st, err)
+		return
+	}
+
+	newToken := *t
+	newToken.FileID = newInfo.Id
+	tkn, err := wopi.NewAccessToken(s.conf.JWTSecret, &newToken, t.ExpiresAt.Time)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, r, map[string]string{
+		"Name": name,
+		"Url":  wopi.FileURL(s.hostURL(r), newInfo.Id) + "?access_token=" + tkn,
+	})
+}
+
+// handleRenameFile renames the file, keeping its extension.
+func (s *svc) handleRenameFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	requested := r.Header.Get(headerRequestedName)
+	gw, ref, current, ok := s.lockContext(w, r, true)
+	if !ok {
+		return
+	}
+	if current != "" && current != r.Header.Get(headerLock) {
+		lockConflict(w, current, "lock mismatch")
+		return
+	}
+
+	info, st, err := stat(ctx, gw, ref)
+	if err != nil || st != nil {
+		writeStatus(w, r, st, err)
+		return
+	}
+	name := requested + path.Ext(info.Path)
+	if requested == "" || !validName(name) {
+		w.Header().Set(headerInvalidFileNameError, "invalid file name")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	res, err := gw.Move(ctx, &provider.MoveRequest{
+		Source:      ref,
+		Destination: &provider.Reference{Path: path.Join(path.Dir(info.Path), name)},
+	})
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+		return
+	case res.Status.Code == rpc.Code_CODE_ALREADY_EXISTS:
+		w.Header().Set(headerInvalidFileNameError, "a file with the same name already exists")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	case res.Status.Code != rpc.Code_CODE_OK:
+		writeStatus(w, r, res.Status, nil)
+		return
+	}
+	writeJSON(w, r, map[string]string{"Name": requested})
+}
+
+// upload writes the content to the referenced file, with the given lock.
+func (s *svc) upload(ctx context.Context, gw gateway.GatewayAPIClient, ref *provider.Reference, lockID string, content io.Reader, length int64) (*rpc.Status, error) {
+	req := &provider.InitiateFileUploadRequest{
+		Ref:    ref,
+		LockId: lockID,
+	}
+	if length >= 0 {
+		req.Opaque = &typespb.Opaque{Map: map[string]*typespb.OpaqueEntry{
+			"Upload-Length": {Decoder: "plain", Value: []byte(strconv.FormatInt(length, 10))},
+		}}
+	}
+	res, err := gw.InitiateFileUpload(ctx, req)
+	switch {
+	case err != nil:
+		return nil, err
+	case res.Status.Code != rpc.Code_CODE_OK:
+		return res.Status, nil
+	}
+	var endpoint, token string
+	for _, p := range res.Protocols {
+		if p.Protocol == "simple" {
+			endpoint, token = p.UploadEndpoint, p.Token
+		}
+	}
+	if endpoint == "" {
+		return nil, errtypes.InternalError("wopi: simple upload not supported")
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, content)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.ContentLength = length
+	httpReq.Header.Set(datagateway.TokenTransportHeader, token)
+	httpRes, err := s.client.Do(httpReq)
+	if err != nil {
+		return nil, errors.Wrap(err, "wopi: error uploading file")
+	}
+	defer httpRes.Body.Close()
+	if httpRes.StatusCode != http.StatusOK && httpRes.StatusCode != http.StatusCreated {
+		return nil, errtypes.InternalError("wopi: data server responded " + httpRes.Status)
+	}
+	return nil, nil
+}
+
+// hostURL returns the public url of the service.
+func (s *svc) hostURL(r *http.Request) string {
+	if s.conf.PublicURL != "" {
+		return s.conf.PublicURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/" + s.conf.Prefix
+}
+
+// stat returns the info of the referenced resource, or the status of the
+// request if it failed.
+func stat(ctx context.Context, gw gateway.GatewayAPIClient, ref *provider.Reference) (*provider.ResourceInfo, *rpc.Status, error) {
+	res, err := gw.Stat(ctx, &provider.StatRequest{Ref: ref})
+	switch {
+	case err != nil:
+		return nil, nil, err
+	case res.Status.Code != rpc.Code_CODE_OK:
+		return nil, res.Status, nil
+	}
+	return res.Info, nil, nil
+}
+
+// availableName returns the given name, or the first one of the form
+// `name (n).ext` that does not exist yet in the directory.
+func availableName(ctx context.Context, gw gateway.GatewayAPIClient, dir, name string) (string, error) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 1; i <= 100; i++ {
+		_, st, err := stat(ctx, gw, &provider.Reference{Path: path.Join(dir, candidate)})
+		switch {
+		case err != nil:
+			return "", err
+		case st != nil && st.Code == rpc.Code_CODE_NOT_FOUND:
+			return candidate, nil
+		case st != nil:
+			return "", errtypes.InternalError(st.Message)
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	return "", errtypes.AlreadyExists(name)
+}
+
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.Contains(name, "/")
+}
+
+// version returns the version of the file as expected by the applications,
+// which changes whenever the file does.
+func version(info *provider.ResourceInfo) string {
+	return strings.Trim(info.Etag, "\"")
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		appctx.GetLogger(r.Context()).Error().Err(err).Msg("wopi: error writing response")
+	}
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	appctx.GetLogger(r.Context()).Error().Err(err).Msg("wopi: error handling request")
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// writeStatus writes the response for a failed gateway request.
+func writeStatus(w http.ResponseWriter, r *http.Request, st *rpc.Status, err error) {
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	appctx.GetLogger(r.Context()).Debug().Interface("status", st).Msg("wopi: gateway request failed")
+	switch st.Code {
+	case rpc.Code_CODE_NOT_FOUND:
+		w.WriteHeader(http.StatusNotFound)
+	case rpc.Code_CODE_PERMISSION_DENIED, rpc.Code_CODE_UNAUTHENTICATED:
+		w.WriteHeader(http.StatusUnauthorized)
+	case rpc.Code_CODE_ALREADY_EXISTS, rpc.Code_CODE_FAILED_PRECONDITION, rpc.Code_CODE_ABORTED:
+		w.WriteHeader(http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package wopi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/app/wopi"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+)
+
+// The WOPI locks are storage locks held by the application, so that any
+// user of an editing session can refresh or release them.
+
+func (s *svc) newLock(t *wopi.AccessToken, lockID string) *provider.Lock {
+	return &provider.Lock{
+		LockId:     lockID,
+		Type:       provider.LockType_LOCK_TYPE_WRITE,
+		AppName:    t.AppName,
+		User:       t.User,
+		Expiration: &typespb.Timestamp{Seconds: uint64(time.Now().Add(time.Duration(s.conf.LockDuration) * time.Second).Unix())},
+	}
+}
+
+// getLock returns the id of the lock on the file, empty if it is not locked.
+func getLock(ctx context.Context, gw gateway.GatewayAPIClient, ref *provider.Reference) (string, *rpc.Status, error) {
+	res, err := gw.GetLock(ctx, &provider.GetLockRequest{Ref: ref})
+	switch {
+	case err != nil:
+		return "", nil, err
+	case res.Status.Code == rpc.Code_CODE_NOT_FOUND:
+		return "", nil, nil
+	case res.Status.Code != rpc.Code_CODE_OK:
+		return "", res.Status, nil
+	}
+	return res.Lock.GetLockId(), nil, nil
+}
+
+// lockContext returns what the lock operations need: the gateway, the
+// reference of the file and the current lock id, or writes the response
+// and returns false. Operations changing the lock need write access.
+func (s *svc) lockContext(w http.ResponseWriter, r *http.Request, write bool) (gateway.GatewayAPIClient, *provider.Reference, string, bool) {
+	ctx := r.Context()
+	t := accessTokenFromContext(ctx)
+	if write && !t.CanWrite() {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, nil, "", false
+	}
+
+	gw, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		writeError(w, r, err)
+		return nil, nil, "", false
+	}
+	ref := &provider.Reference{ResourceId: t.FileID}
+	current, st, err := getLock(ctx, gw, ref)
+	if err != nil || st != nil {
+		writeStatus(w, r, st, err)
+		return nil, nil, "", false
+	}
+	return gw, ref, current, true
+}
+
+// handleLock locks the file, or refreshes the lock if it is already held
+// with the same id.
+func (s *svc) handleLock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lockID := r.Header.Get(headerLock)
+	if lockID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	gw, ref, current, ok := s.lockContext(w, r, true)
+	if !ok {
+		return
+	}
+	t := accessTokenFromContext(ctx)
+
+	switch current {
+	case "":
+		res, err := gw.SetLock(ctx, &provider.SetLockRequest{Ref: ref, Lock: s.newLock(t, lockID)})
+		if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+			s.lockFailed(w, r, gw, ref, res.GetStatus(), err)
+			return
+		}
+	case lockID:
+		res, err := gw.RefreshLock(ctx, &provider.RefreshLockRequest{Ref: ref, Lock: s.newLock(t, lockID), ExistingLockId: lockID})
+		if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+			s.lockFailed(w, r, gw, ref, res.GetStatus(), err)
+			return
+		}
+	default:
+		lockConflict(w, current, "file is locked")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleUnlockAndRelock replaces the lock with the id given in the
+// X-WOPI-OldLock header by a new one.
+func (s *svc) handleUnlockAndRelock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lockID, oldLockID := r.Header.Get(headerLock), r.Header.Get(headerOldLock)
+	if lockID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	gw, ref, current, ok := s.lockContext(w, r, true)
+	if !ok {
+		return
+	}
+	if current != oldLockID {
+		lockConflict(w, current, "lock mismatch")
+		return
+	}
+
+	t := accessTokenFromContext(ctx)
+	res, err := gw.RefreshLock(ctx, &provider.RefreshLockRequest{Ref: ref, Lock: s.newLock(t, lockID), ExistingLockId: oldLockID})
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+		s.lockFailed(w, r, gw, ref, res.GetStatus(), err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *svc) handleGetLock(w http.ResponseWriter, r *http.Request) {
+	_, _, current, ok := s.lockContext(w, r, false)
+	if !ok {
+		return
+	}
+	w.Header().Set(headerLock, current)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *svc) handleRefreshLock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lockID := r.Header.Get(headerLock)
+	gw, ref, current, ok := s.lockContext(w, r, true)
+	if !ok {
+		return
+	}
+	if current == "" || current != lockID {
+		lockConflict(w, current, "lock mismatch")
+		return
+	}
+
+	t := accessTokenFromContext(ctx)
+	res, err := gw.RefreshLock(ctx, &provider.RefreshLockRequest{Ref: ref, Lock: s.newLock(t, lockID), ExistingLockId: lockID})
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+		s.lockFailed(w, r, gw, ref, res.GetStatus(), err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *svc) handleUnlock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lockID := r.Header.Get(headerLock)
+	gw, ref, current, ok := s.lockContext(w, r, true)
+	if !ok {
+		return
+	}
+	if current == "" || current != lockID {
+		lockConflict(w, current, "lock mismatch")
+		return
+	}
+
+	t := accessTokenFromContext(ctx)
+	res, err := gw.Unlock(ctx, &provider.UnlockRequest{Ref: ref, Lock: s.newLock(t, lockID)})
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+		s.lockFailed(w, r, gw, ref, res.GetStatus(), err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// lockFailed reports a failed lock operation. A storage refusing the
+// operation most likely means that the lock changed in the meantime, so
+// the current lock is reported as a conflict.
+func (s *svc) lockFailed(w http.ResponseWriter, r *http.Request, gw gateway.GatewayAPIClient, ref *provider.Reference, st *rpc.Status, err error) {
+	if err != nil || st.Code == rpc.Code_CODE_NOT_FOUND || st.Code == rpc.Code_CODE_PERMISSION_DENIED {
+		writeStatus(w, r, st, err)
+		return
+	}
+	appctx.GetLogger(r.Context()).Info().Interface("status", st).Msg("wopi: lock operation refused")
+	current, _, _ := getLock(r.Context(), gw, ref)
+	lockConflict(w, current, st.Message)
+}
+
+// lockConflict reports that the lock of the file, if any, does not match
+// the one of the request.
+func lockConflict(w http.ResponseWriter, current, reason string) {
+	w.Header().Set(headerLock, current)
+	w.Header().Set(headerLockFailureReason, reason)
+	w.WriteHeader(http.StatusConflict)
+}
@@ -0,0 +1,178 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package wopi implements a WOPI host: the endpoints the office applications
+// call back to read, write and lock the files opened through the wopi app
+// provider, served over the gateway with the reva token of the user.
+package wopi
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/app/wopi"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/httpclient"
+	"github.com/cs3org/reva/v3/pkg/rhttp/global"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/utils"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/cs3org/reva/v3/pkg/utils/resourceid"
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc/metadata"
+)
+
+func init() {
+	global.Register("wopi", New)
+}
+
+type config struct {
+	Prefix       string `docs:"wopi;The prefix of the WOPI endpoints."                                            mapstructure:"prefix"`
+	GatewaySvc   string `mapstructure:"gatewaysvc"`
+	JWTSecret    string `docs:";The secret the access tokens are signed with, shared with the wopi app provider." mapstructure:"jwt_secret"`
+	PublicURL    string `docs:";The public URL of this service, used in the links to new files."                 mapstructure:"public_url"`
+	LockDuration int    `docs:"1800;The duration of the WOPI locks in seconds."                                  mapstructure:"lock_duration"`
+	Insecure     bool   `docs:"false;Whether to skip certificate checks when transferring the files."            mapstructure:"insecure"`
+}
+
+func (c *config) ApplyDefaults() {
+	if c.Prefix == "" {
+		c.Prefix = "wopi"
+	}
+	if c.LockDuration == 0 {
+		// the WOPI locks expire after 30 minutes
+		c.LockDuration = 1800
+	}
+	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
+	c.JWTSecret = sharedconf.GetJWTSecret(c.JWTSecret)
+}
+
+type svc struct {
+	conf   *config
+	client *httpclient.Client
+	router *chi.Mux
+}
+
+// New returns a new WOPI host service.
+func New(ctx context.Context, m map[string]any) (global.Service, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	s := &svc{
+		conf: &c,
+		client: httpclient.New(
+			httpclient.Timeout(time.Duration(60*int64(time.Second))),
+			httpclient.RoundTripper(&http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: c.Insecure}}),
+		),
+		router: chi.NewRouter(),
+	}
+	s.routerInit()
+	return s, nil
+}
+
+func (s *svc) routerInit() {
+	s.router.Route("/files/{fileid}", func(r chi.Router) {
+		r.Use(s.authenticate)
+		r.Get("/", s.handleCheckFileInfo)
+		r.Post("/", s.handleFileOperation)
+		r.Get("/contents", s.handleGetFile)
+		r.Post("/contents", s.handlePutFile)
+	})
+}
+
+// Close performs cleanup.
+func (s *svc) Close() error {
+	return nil
+}
+
+func (s *svc) Prefix() string {
+	return s.conf.Prefix
+}
+
+// Unprotected returns all the paths: the applications authenticate with
+// the access token, not with a reva token.
+func (s *svc) Unprotected() []string {
+	return []string{"/"}
+}
+
+func (s *svc) Handler() http.Handler {
+	return s.router
+}
+
+type ctxKey struct{}
+
+// authenticate verifies the access token of the request, that must grant
+// access to the file of the url, and puts it in the context together with
+// the reva token it carries.
+func (s *svc) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := appctx.GetLogger(r.Context())
+
+		t, err := wopi.ParseAccessToken(s.conf.JWTSecret, r.URL.Query().Get("access_token"))
+		if err != nil {
+			log.Info().Err(err).Msg("wopi: rejected access token")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fileID, err := url.PathUnescape(chi.URLParam(r, "fileid"))
+		if err != nil || !utils.ResourceIDEqual(resourceid.OwnCloudResourceIDUnwrap(fileID), t.FileID) {
+			log.Info().Str("fileid", fileID).Msg("wopi: access token not valid for file")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		ctx := appctx.ContextSetToken(r.Context(), t.RevaToken)
+		ctx = metadata.AppendToOutgoingContext(ctx, appctx.TokenHeader, t.RevaToken)
+		ctx = context.WithValue(ctx, ctxKey{}, t)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func accessTokenFromContext(ctx context.Context) *wopi.AccessToken {
+	return ctx.Value(ctxKey{}).(*wopi.AccessToken)
+}
+
+// handleFileOperation dispatches the operations on a file, that WOPI
+// tells apart by the X-WOPI-Override header.
+func (s *svc) handleFileOperation(w http.ResponseWriter, r *http.Request) {
+	switch r.Header.Get(headerOverride) {
+	case "LOCK":
+		if r.Header.Get(headerOldLock) != "" {
+			s.handleUnlockAndRelock(w, r)
+			return
+		}
+		s.handleLock(w, r)
+	case "GET_LOCK":
+		s.handleGetLock(w, r)
+	case "REFRESH_LOCK":
+		s.handleRefreshLock(w, r)
+	case "UNLOCK":
+		s.handleUnlock(w, r)
+	case "PUT_RELATIVE":
+		s.handlePutRelativeFile(w, r)
+	case "RENAME_FILE":
+		s.handleRenameFile(w, r)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package wopi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	appprovider "github.com/cs3org/go-cs3apis/cs3/app/provider/v1beta1"
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/app/wopi"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+	"google.golang.org/grpc"
+)
+
+const (
+	testSecret  = "secret"
+	testGateway = "wopi-test-gateway"
+)
+
+var testFile = &provider.ResourceId{StorageId: "storage", OpaqueId: "file"}
+
+// lockGateway keeps the lock of a single file.
+type lockGateway struct {
+	gateway.GatewayAPIClient
+	lock *provider.Lock
+	size uint64
+}
+
+func ok() *rpc.Status { return &rpc.Status{Code: rpc.Code_CODE_OK} }
+
+func (g *lockGateway) Stat(_ context.Context, _ *provider.StatRequest, _ ...grpc.CallOption) (*provider.StatResponse, error) {
+	return &provider.StatResponse{Status: ok(), Info: &provider.ResourceInfo{
+		Id:            testFile,
+		Path:          "/home/docs/report.odt",
+		Size:          g.size,
+		Etag:          "\"etag\"",
+		PermissionSet: &provider.ResourcePermissions{InitiateFileUpload: true, Move: true},
+	}}, nil
+}
+
+func (g *lockGateway) GetLock(_ context.Context, _ *provider.GetLockRequest, _ ...grpc.CallOption) (*provider.GetLockResponse, error) {
+	if g.lock == nil {
+		return &provider.GetLockResponse{Status: &rpc.Status{Code: rpc.Code_CODE_NOT_FOUND}}, nil
+	}
+	return &provider.GetLockResponse{Status: ok(), Lock: g.lock}, nil
+}
+
+func (g *lockGateway) SetLock(_ context.Context, req *provider.SetLockRequest, _ ...grpc.CallOption) (*provider.SetLockResponse, error) {
+	g.lock = req.Lock
+	return &provider.SetLockResponse{Status: ok()}, nil
+}
+
+func (g *lockGateway) RefreshLock(_ context.Context, req *provider.RefreshLockRequest, _ ...grpc.CallOption) (*provider.RefreshLockResponse, error) {
+	if g.lock == nil || g.lock.LockId != req.ExistingLockId {
+		return &provider.RefreshLockResponse{Status: &rpc.Status{Code: rpc.Code_CODE_FAILED_PRECONDITION}}, nil
+	}
+	g.lock = req.Lock
+	return &provider.RefreshLockResponse{Status: ok()}, nil
+}
+
+func (g *lockGateway) Unlock(_ context.Context, _ *provider.UnlockRequest, _ ...grpc.CallOption) (*provider.UnlockResponse, error) {
+	g.lock = nil
+	return &provider.UnlockResponse{Status: ok()}, nil
+}
+
+func newTestService(t *testing.T) (*svc, *lockGateway) {
+	t.Helper()
+	gw := &lockGateway{}
+	pool.RegisterGatewayServiceClient(gw, testGateway)
+	s, err := New(context.Background(), map[string]any{
+		"gatewaysvc": testGateway,
+		"jwt_secret": testSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.(*svc), gw
+}
+
+func accessToken(t *testing.T, viewMode appprovider.ViewMode) string {
+	t.Helper()
+	tkn, err := wopi.NewAccessToken(testSecret, &wopi.AccessToken{
+		RevaToken: "reva-token",
+		FileID:    testFile,
+		ViewMode:  viewMode,
+		AppName:   "Collabora",
+	}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tkn
+}
+
+func do(s *svc, fileID, tkn string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/files/"+fileID+"?access_token="+tkn, nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, _ := newTestService(t)
+	tkn := accessToken(t, appprovider.ViewMode_VIEW_MODE_READ_WRITE)
+
+	if w := do(s, "storage!other", tkn, map[string]string{headerOverride: "GET_LOCK"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("token of another file: got %d, want 401", w.Code)
+	}
+	if w := do(s, "storage!file", "garbage", map[string]string{headerOverride: "GET_LOCK"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("invalid token: got %d, want 401", w.Code)
+	}
+	if w := do(s, "storage!file", tkn, map[string]string{headerOverride: "GET_LOCK"}); w.Code != http.StatusOK {
+		t.Errorf("valid token: got %d, want 200", w.Code)
+	}
+}
+
+func TestLocks(t *testing.T) {
+	s, gw := newTestService(t)
+	tkn := accessToken(t, appprovider.ViewMode_VIEW_MODE_READ_WRITE)
+	fileID := "storage!file"
+
+	steps := []struct {
+		name     string
+		headers  map[string]string
+		code     int
+		lockResp string
+		lock     string
+	}{
+		{"lock", map[string]string{headerOverride: "LOCK", headerLock: "L1"}, http.StatusOK, "", "L1"},
+		{"relock with same id", map[string]string{headerOverride: "LOCK", headerLock: "L1"}, http.StatusOK, "", "L1"},
+		{"lock with other id", map[string]string{headerOverride: "LOCK", headerLock: "L2"}, http.StatusConflict, "L1", "L1"},
+		{"get lock", map[string]string{headerOverride: "GET_LOCK"}, http.StatusOK, "L1", "L1"},
+		{"refresh with other id", map[string]string{headerOverride: "REFRESH_LOCK", headerLock: "L2"}, http.StatusConflict, "L1", "L1"},
+		{"refresh", map[string]string{headerOverride: "REFRESH_LOCK", headerLock: "L1"}, http.StatusOK, "", "L1"},
+		{"unlock and relock with wrong old lock", map[string]string{headerOverride: "LOCK", headerLock: "L2", headerOldLock: "L3"}, http.StatusConflict, "L1", "L1"},
+		{"unlock and relock", map[string]string{headerOverride: "LOCK", headerLock: "L2", headerOldLock: "L1"}, http.StatusOK, "", "L2"},
+		{"unlock with other id", map[string]string{headerOverride: "UNLOCK", headerLock: "L1"}, http.StatusConflict, "L2", "L2"},
+		{"unlock", map[string]string{headerOverride: "UNLOCK", headerLock: "L2"}, http.StatusOK, "", ""},
+		{"unlock unlocked file", map[string]string{headerOverride: "UNLOCK", headerLock: "L2"}, http.StatusConflict, "", ""},
+	}
+	for _, step := range steps {
+		w := do(s, fileID, tkn, step.headers)
+		if w.Code != step.code {
+			t.Fatalf("%s: got %d, want %d", step.name, w.Code, step.code)
+		}
+		if got := w.Header().Get(headerLock); got != step.lockResp {
+			t.Errorf("%s: got lock header %q, want %q", step.name, got, step.lockResp)
+		}
+		if got := gw.lock.GetLockId(); got != step.lock {
+			t.Errorf("%s: file locked with %q, want %q", step.name, got, step.lock)
+		}
+	}
+	if w := do(s, fileID, accessToken(t, appprovider.ViewMode_VIEW_MODE_VIEW_ONLY), map[string]string{headerOverride: "LOCK", headerLock: "L1"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("lock with read only token: got %d, want 401", w.Code)
+	}
+}
+
+func TestPutFileLockChecks(t *testing.T) {
+	s, gw := newTestService(t)
+	tkn := accessToken(t, appprovider.ViewMode_VIEW_MODE_READ_WRITE)
+
+	put := func(lockID string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/files/storage!file/contents?access_token="+tkn, nil)
+		r.Header.Set(headerOverride, "PUT")
+		r.Header.Set(headerLock, lockID)
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+		return w
+	}
+
+	// an unlocked, non empty file cannot be written
+	gw.size = 10
+	if w := put("L1"); w.Code != http.StatusConflict || w.Header().Get(headerLock) != "" {
+		t.Errorf("unlocked file: got %d with lock %q, want 409 with no lock", w.Code, w.Header().Get(headerLock))
+	}
+	// nor a file locked with another lock
+	gw.lock = &provider.Lock{LockId: "L2"}
+	if w := put("L1"); w.Code != http.StatusConflict || w.Header().Get(headerLock) != "L2" {
+		t.Errorf("file locked by another: got %d with lock %q, want 409 with L2", w.Code, w.Header().Get(headerLock))
+	}
+}
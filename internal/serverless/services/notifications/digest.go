@@ -0,0 +1,91 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package notifications
+
+import (
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/notification"
+	"github.com/cs3org/reva/v3/pkg/notification/digest"
+	"github.com/cs3org/reva/v3/pkg/notification/preference"
+)
+
+// scheduleDigests sends the digests of the given frequency until the service is closed.
+func (s *svc) scheduleDigests(f preference.Frequency) {
+	for {
+		next := preference.NextDigest(f, time.Now(), s.conf.DigestHour)
+		select {
+		case <-s.quit:
+			return
+		case <-time.After(time.Until(next)):
+			s.sendDigests(f)
+		}
+	}
+}
+
+func (s *svc) sendDigests(f preference.Frequency) {
+	templ, err := s.templates.Get(s.conf.DigestTemplate)
+	if err != nil {
+		s.log.Error().Err(err).Msgf("digest template %s not found, %s digests postponed", s.conf.DigestTemplate, f)
+		return
+	}
+
+	pending, err := s.digests.Take(s.ctx, f)
+	if err != nil {
+		s.log.Error().Err(err).Msgf("reading the %s digests failed", f)
+		return
+	}
+
+	for recipient, items := range pending {
+		n := &notification.Notification{
+			TemplateName: s.conf.DigestTemplate,
+			Template:     *templ,
+			Ref:          s.conf.DigestTemplate,
+			Recipients:   []string{recipient},
+		}
+		if err := n.Send("", digestTemplateData(f, recipient, items)); err != nil {
+			s.log.Error().Err(err).Msgf("sending %s digest to %s failed, postponed", f, recipient)
+			if err := s.digests.Add(s.ctx, f, recipient, items...); err != nil {
+				s.log.Error().Err(err).Msgf("postponing %s digest of %s failed", f, recipient)
+			}
+			continue
+		}
+		s.log.Debug().Msgf("%s digest of %d notifications sent to %s", f, len(items), recipient)
+	}
+}
+
+// digestTemplateData returns the data the digest template is rendered with.
+func digestTemplateData(f preference.Frequency, recipient string, items []*digest.Item) map[string]any {
+	data := make([]map[string]any, 0, len(items))
+	for _, i := range items {
+		data = append(data, map[string]any{
+			"template": i.Template,
+			"ref":      i.Ref,
+			"subject":  i.Subject,
+			"time":     i.Time,
+			"data":     i.Data,
+		})
+	}
+	return map[string]any{
+		"_count":    len(items),
+		"_items":    data,
+		"frequency": string(f),
+		"recipient": recipient,
+	}
+}
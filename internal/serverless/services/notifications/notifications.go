@@ -26,53 +26,84 @@ import (
 
 	"github.com/pkg/errors"
 
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/cs3org/reva/v3/pkg/errtypes"
 	"github.com/cs3org/reva/v3/pkg/notification"
+	"github.com/cs3org/reva/v3/pkg/notification/digest"
+	digestRegistry "github.com/cs3org/reva/v3/pkg/notification/digest/registry"
 	"github.com/cs3org/reva/v3/pkg/notification/handler"
 	handlerRegistry "github.com/cs3org/reva/v3/pkg/notification/handler/registry"
+	"github.com/cs3org/reva/v3/pkg/notification/inbox"
+	inboxRegistry "github.com/cs3org/reva/v3/pkg/notification/inbox/registry"
 	notificationManagerRegistry "github.com/cs3org/reva/v3/pkg/notification/manager/registry"
+	"github.com/cs3org/reva/v3/pkg/notification/preference"
 	"github.com/cs3org/reva/v3/pkg/notification/template"
 	templateRegistry "github.com/cs3org/reva/v3/pkg/notification/template/registry"
+	"github.com/cs3org/reva/v3/pkg/notification/transport"
+	transportRegistry "github.com/cs3org/reva/v3/pkg/notification/transport/registry"
 	"github.com/cs3org/reva/v3/pkg/notification/trigger"
-	"github.com/cs3org/reva/v3/pkg/notification/utils"
+	"github.com/cs3org/reva/v3/pkg/preferences"
+	preferencesRegistry "github.com/cs3org/reva/v3/pkg/preferences/registry"
 	"github.com/cs3org/reva/v3/pkg/rserverless"
 	"github.com/cs3org/reva/v3/pkg/utils/accumulator"
 	"github.com/mitchellh/mapstructure"
-	"github.com/nats-io/nats.go"
 	"github.com/rs/zerolog"
 )
 
 type config struct {
-	NatsAddress      string                    `docs:";The NATS server address."                                  mapstructure:"nats_address"`
-	NatsToken        string                    `docs:";The token to authenticate against the NATS server"         mapstructure:"nats_token"`
-	NatsPrefix       string                    `docs:"reva-notifications;The notifications NATS stream."          mapstructure:"nats_prefix"`
-	HandlerConf      map[string]map[string]any `docs:"nil;Settings for the different notification handlers."      mapstructure:"handlers"`
-	GroupingInterval int                       `docs:"60;Time in seconds to group incoming notification triggers" mapstructure:"grouping_interval"`
-	GroupingMaxSize  int                       `docs:"100;Maximum number of notifications to group"               mapstructure:"grouping_max_size"`
-	StorageDriver    string                    `docs:"mysql;The driver used to store notifications"               mapstructure:"storage_driver"`
+	Transport        string                    `docs:"nats;The transport the notification messages are received through."                                mapstructure:"transport"`
+	Transports       map[string]map[string]any `docs:"nil;Settings for the different transports."                                                        mapstructure:"transports"`
+	NatsAddress      string                    `docs:";The NATS server address, used if the nats transport is not configured."                           mapstructure:"nats_address"`
+	NatsToken        string                    `docs:";The token to authenticate against the NATS server, used if the nats transport is not configured." mapstructure:"nats_token"`
+	NatsPrefix       string                    `docs:"reva-notifications;The notifications NATS stream, used if the nats transport is not configured."   mapstructure:"nats_prefix"`
+	HandlerConf      map[string]map[string]any `docs:"nil;Settings for the different notification handlers."                                             mapstructure:"handlers"`
+	GroupingInterval int                       `docs:"60;Time in seconds to group incoming notification triggers"                                        mapstructure:"grouping_interval"`
+	GroupingMaxSize  int                       `docs:"100;Maximum number of notifications to group"                                                      mapstructure:"grouping_max_size"`
+	StorageDriver    string                    `docs:"mysql;The driver used to store notifications"                                                      mapstructure:"storage_driver"`
 	StorageDrivers   map[string]map[string]any `mapstructure:"storage_drivers"`
+	InboxDriver      string                    `docs:";The driver used to store the in-app notifications of the users. Disabled if empty." mapstructure:"inbox_driver"`
+	InboxDrivers     map[string]map[string]any `mapstructure:"inbox_drivers"`
+	// The notification preferences of the users are read through a preferences driver,
+	// which must share the storage of the preferences service.
+	PreferencesDriver  string                    `docs:";The driver used to read the notification preferences of the users. Disabled if empty." mapstructure:"preferences_driver"`
+	PreferencesDrivers map[string]map[string]any `mapstructure:"preferences_drivers"`
+	DefaultPreference  preference.Preference     `docs:";The notification preference of the users who did not set any." mapstructure:"default_preference"`
+	DigestDriver       string                    `docs:"sql;The driver used to queue the notifications waiting for a digest." mapstructure:"digest_driver"`
+	DigestDrivers      map[string]map[string]any `mapstructure:"digest_drivers"`
+	DigestTemplate     string                    `docs:"digest;The template used to render the digests."                mapstructure:"digest_template"`
+	DigestHour         int                       `docs:"8;The hour of the day at which the daily digests are sent."     mapstructure:"digest_hour"`
 }
 
 func defaultConfig() *config {
 	return &config{
+		Transport:        "nats",
 		NatsPrefix:       "reva-notifications",
 		GroupingInterval: 60,
 		GroupingMaxSize:  100,
 		StorageDriver:    "sql",
+		DefaultPreference: preference.Preference{
+			Channels:  []preference.Channel{preference.ChannelEmail, preference.ChannelInApp},
+			Frequency: preference.FrequencyInstant,
+		},
+		DigestDriver:   "sql",
+		DigestTemplate: "digest",
+		DigestHour:     8,
 	}
 }
 
 type svc struct {
 	ctx          context.Context
-	nc           *nats.Conn
-	js           nats.JetStreamContext
-	kv           nats.KeyValue
+	tr           transport.Transport
 	conf         *config
 	log          *zerolog.Logger
 	handlers     map[string]handler.Handler
 	templates    templateRegistry.Registry
 	nm           notification.Manager
+	inbox        inbox.Manager
+	prefs        preferences.Manager
+	digests      digest.Manager
+	quit         chan struct{}
 	accumulators map[string]*accumulator.Accumulator[trigger.Trigger]
 }
 
@@ -87,6 +118,27 @@ func getNotificationManager(ctx context.Context, c *config) (notification.Manage
 	return nil, errtypes.NotFound(fmt.Sprintf("storage driver %s not found", c.StorageDriver))
 }
 
+func getInboxManager(ctx context.Context, c *config) (inbox.Manager, error) {
+	if f, ok := inboxRegistry.NewFuncs[c.InboxDriver]; ok {
+		return f(ctx, c.InboxDrivers[c.InboxDriver])
+	}
+	return nil, errtypes.NotFound(fmt.Sprintf("inbox driver %s not found", c.InboxDriver))
+}
+
+func getDigestManager(ctx context.Context, c *config) (digest.Manager, error) {
+	if f, ok := digestRegistry.NewFuncs[c.DigestDriver]; ok {
+		return f(ctx, c.DigestDrivers[c.DigestDriver])
+	}
+	return nil, errtypes.NotFound(fmt.Sprintf("digest driver %s not found", c.DigestDriver))
+}
+
+func getPreferencesManager(ctx context.Context, c *config) (preferences.Manager, error) {
+	if f, ok := preferencesRegistry.NewFuncs[c.PreferencesDriver]; ok {
+		return f(ctx, c.PreferencesDrivers[c.PreferencesDriver])
+	}
+	return nil, errtypes.NotFound(fmt.Sprintf("preferences driver %s not found", c.PreferencesDriver))
+}
+
 // New returns a new Notifications service.
 func New(ctx context.Context, m map[string]any) (rserverless.Service, error) {
 	conf := defaultConfig()
@@ -95,6 +147,21 @@ func New(ctx context.Context, m map[string]any) (rserverless.Service, error) {
 		return nil, err
 	}
 
+	if conf.Transports == nil {
+		conf.Transports = map[string]map[string]any{}
+	}
+	if _, ok := conf.Transports["nats"]; !ok {
+		conf.Transports["nats"] = map[string]any{
+			"address": conf.NatsAddress,
+			"token":   conf.NatsToken,
+			"prefix":  conf.NatsPrefix,
+		}
+	}
+
+	if err := conf.DefaultPreference.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid default notification preference")
+	}
+
 	log := appctx.GetLogger(ctx)
 	nm, err := getNotificationManager(ctx, conf)
 	if err != nil {
@@ -102,11 +169,35 @@ func New(ctx context.Context, m map[string]any) (rserverless.Service, error) {
 	}
 	log.Info().Msgf("notification storage %s initialized", conf.StorageDriver)
 
+	digests, err := getDigestManager(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Msgf("notification digests %s initialized", conf.DigestDriver)
+
 	s := &svc{
-		ctx:  ctx,
-		conf: conf,
-		log:  log,
-		nm:   nm,
+		ctx:     ctx,
+		conf:    conf,
+		log:     log,
+		nm:      nm,
+		digests: digests,
+		quit:    make(chan struct{}),
+	}
+
+	if conf.InboxDriver != "" {
+		s.inbox, err = getInboxManager(ctx, conf)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().Msgf("notification inbox %s initialized", conf.InboxDriver)
+	}
+
+	if conf.PreferencesDriver != "" {
+		s.prefs, err = getPreferencesManager(ctx, conf)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().Msgf("notification preferences %s initialized", conf.PreferencesDriver)
 	}
 
 	return s, nil
@@ -118,104 +209,50 @@ func (s *svc) Start() {
 	s.handlers = handlerRegistry.InitHandlers(s.ctx, s.conf.HandlerConf)
 	s.accumulators = make(map[string]*accumulator.Accumulator[trigger.Trigger])
 
-	s.log.Debug().Msgf("connecting to nats server at %s", s.conf.NatsAddress)
+	s.log.Debug().Msgf("connecting to the %s transport", s.conf.Transport)
 	err := s.connect()
 	if err != nil {
-		s.log.Error().Err(err).Msg("connecting to nats failed")
+		s.log.Error().Err(err).Msgf("connecting to the %s transport failed", s.conf.Transport)
 	}
+
+	go s.scheduleDigests(preference.FrequencyHourly)
+	go s.scheduleDigests(preference.FrequencyDaily)
 	s.log.Info().Msg("notifications service ready")
 }
 
 // Close performs cleanup.
 func (s *svc) Close(ctx context.Context) error {
-	return s.nc.Drain()
+	close(s.quit)
+	if s.tr == nil {
+		return nil
+	}
+	return s.tr.Close()
 }
 
 func (s *svc) connect() error {
-	nc, err := utils.ConnectToNats(s.conf.NatsAddress, s.conf.NatsToken, *s.log)
+	tr, err := transportRegistry.New(s.ctx, s.conf.Transport, s.conf.Transports)
 	if err != nil {
 		return err
 	}
-	s.nc = nc
-
-	js, err := nc.JetStream(nats.PublishAsyncMaxPending(256))
-	if err != nil {
-		return errors.Wrap(err, "jetstream initialization failed")
-	}
+	s.tr = tr
 
-	s.js = js
-
-	if err := s.initNatsKV("template", s.handleMsgTemplate); err != nil {
+	if err := s.tr.WatchTemplates(s.handleMsgTemplate); err != nil {
 		return err
 	}
-	if err := s.initNatsStream("notification-register", s.handleMsgRegisterNotification); err != nil {
+	if err := s.tr.Subscribe(transport.SubjectRegister, s.handleMsgRegisterNotification); err != nil {
 		return err
 	}
-	if err := s.initNatsStream("notification-unregister", s.handleMsgUnregisterNotification); err != nil {
+	if err := s.tr.Subscribe(transport.SubjectUnregister, s.handleMsgUnregisterNotification); err != nil {
 		return err
 	}
-	return s.initNatsStream("trigger", s.handleMsgTrigger)
-}
-
-func (s *svc) initNatsKV(name string, handler func(msg []byte)) error {
-	bucketName := fmt.Sprintf("%s-%s", s.conf.NatsPrefix, name)
-	kv, err := s.js.CreateKeyValue(&nats.KeyValueConfig{
-		Bucket: bucketName,
-	})
-	if err != nil {
-		return errors.Wrap(err, "template store creation failed, probably because nats server is unreachable")
-	}
-
-	s.kv = kv
-
-	w, _ := kv.WatchAll()
-
-	go func() {
-		for {
-			msg := <-w.Updates()
-
-			if msg != nil {
-				handler(msg.Value())
-			}
-		}
-	}()
-
-	return nil
-}
-
-func (s *svc) initNatsStream(name string, handler func(msg *nats.Msg)) error {
-	streamName := fmt.Sprintf("%s-%s", s.conf.NatsPrefix, name)
-	consumerName := fmt.Sprintf("%s-consumer-%s", s.conf.NatsPrefix, name)
-	subjectName := fmt.Sprintf("%s.%s", s.conf.NatsPrefix, name)
-	deliverySubjectName := fmt.Sprintf("%s-delivery.%s", s.conf.NatsPrefix, name)
-
-	// Creates a NATS stream with given name if it does not exist already
-	if _, err := s.js.AddStream(&nats.StreamConfig{
-		Name:     streamName,
-		Subjects: []string{subjectName},
-	}); err != nil {
-		return errors.Wrapf(err, "nats %s stream creation failed", name)
-	}
-
-	// Adds a consumer with the given name to the JetStream context
-	if _, err := s.js.AddConsumer(streamName, &nats.ConsumerConfig{
-		Durable:        consumerName,
-		DeliverSubject: deliverySubjectName,
-	}); err != nil {
-		return errors.Wrapf(err, "nats %s consumer creation failed", name)
-	}
-
-	// Subscribes the JetStream context to the consumer we just created
-	_, err := s.js.Subscribe("", func(msg *nats.Msg) { handler(msg) }, nats.Bind(streamName, consumerName))
-	if err != nil {
-		return errors.Wrapf(err, "nats subscription to consumer %s failed", consumerName)
-	}
-
-	return nil
+	return s.tr.Subscribe(transport.SubjectTrigger, s.handleMsgTrigger)
 }
 
-func (s *svc) handleMsgTemplate(msg []byte) {
+func (s *svc) handleMsgTemplate(name string, msg []byte) {
 	if len(msg) == 0 {
+		// the template was deleted from the store
+		s.templates.Delete(name)
+		s.log.Info().Msgf("template %s unregistered", name)
 		return
 	}
 
@@ -228,7 +265,7 @@ func (s *svc) handleMsgTemplate(msg []byte) {
 		// store too.
 		var e *template.FileNotFoundError
 		if errors.As(err, &e) && name != "" {
-			err := s.kv.Purge(name)
+			err := s.tr.DeleteTemplate(name)
 			if err != nil {
 				s.log.Error().Err(err).Msgf("deletion of template %s from store failed", name)
 			}
@@ -239,9 +276,9 @@ func (s *svc) handleMsgTemplate(msg []byte) {
 	}
 }
 
-func (s *svc) handleMsgRegisterNotification(msg *nats.Msg) {
+func (s *svc) handleMsgRegisterNotification(msg []byte) {
 	var data map[string]any
-	err := json.Unmarshal(msg.Data, &data)
+	err := json.Unmarshal(msg, &data)
 	if err != nil {
 		s.log.Error().Err(err).Msg("notification registration unmarshall failed")
 		return
@@ -268,8 +305,8 @@ func (s *svc) handleMsgRegisterNotification(msg *nats.Msg) {
 	}
 }
 
-func (s *svc) handleMsgUnregisterNotification(msg *nats.Msg) {
-	ref := string(msg.Data)
+func (s *svc) handleMsgUnregisterNotification(msg []byte) {
+	ref := string(msg)
 
 	err := s.nm.DeleteNotification(ref)
 	if err != nil {
@@ -301,9 +338,9 @@ func (s *svc) getAccumulatorForTrigger(tr trigger.Trigger) *accumulator.Accumula
 	return a
 }
 
-func (s *svc) handleMsgTrigger(msg *nats.Msg) {
+func (s *svc) handleMsgTrigger(msg []byte) {
 	var data map[string]any
-	err := json.Unmarshal(msg.Data, &data)
+	err := json.Unmarshal(msg, &data)
 	if err != nil {
 		s.log.Error().Err(err).Msg("notification trigger unmarshall failed")
 		return
@@ -399,7 +436,102 @@ func (s *svc) notificationSendCallback(ts []trigger.Trigger) {
 	// destroy old accumulator
 	s.accumulators[tr.Ref] = nil
 
+	s.dispatch(tr)
+}
+
+// dispatch delivers a notification to each of its recipients
+// according to their notification preferences.
+func (s *svc) dispatch(tr trigger.Trigger) {
+	subject, err := tr.Notification.Template.RenderSubject(tr.TemplateData)
+	if err != nil {
+		s.log.Error().Err(err).Msgf("rendering subject of notification %s failed", tr.Ref)
+		return
+	}
+
+	now := time.Now()
+	instant := []string{}
+	for _, recipient := range tr.Notification.Recipients {
+		p := s.getPreference(tr.Notification, recipient)
+
+		if p.Has(preference.ChannelInApp) {
+			s.addToInbox(tr, recipient, subject, now)
+		}
+		if !p.Has(preference.ChannelEmail) {
+			continue
+		}
+		if p.Digest() {
+			err := s.digests.Add(s.ctx, p.Frequency, recipient, &digest.Item{
+				Template: tr.Notification.TemplateName,
+				Ref:      tr.Ref,
+				Subject:  subject,
+				Time:     now,
+				Data:     tr.TemplateData,
+			})
+			if err == nil {
+				continue
+			}
+			// rather send it right away than lose it
+			s.log.Error().Err(err).Msgf("queueing notification %s for the digest of %s failed", tr.Ref, recipient)
+		}
+		instant = append(instant, recipient)
+	}
+
+	if len(instant) == 0 {
+		return
+	}
+
+	n := *tr.Notification
+	n.Recipients = instant
+	tr.Notification = &n
 	if err := tr.Send(); err != nil {
 		s.log.Error().Err(err).Msgf("notification send failed")
 	}
 }
+
+// getPreference returns the notification preference of a recipient for the
+// template of the notification, falling back to the default preference of
+// the recipient and then to the configured default.
+func (s *svc) getPreference(n *notification.Notification, recipient string) preference.Preference {
+	userID, ok := n.Users[recipient]
+	if s.prefs == nil || !ok || userID == "" {
+		return s.conf.DefaultPreference
+	}
+
+	ctx := appctx.ContextSetUser(s.ctx, &userpb.User{Id: &userpb.UserId{OpaqueId: userID}})
+	for _, key := range []string{n.TemplateName, preference.DefaultKey} {
+		v, err := s.prefs.GetKey(ctx, key, preference.Namespace)
+		if err != nil {
+			var notFound errtypes.NotFound
+			if !errors.As(err, &notFound) {
+				s.log.Error().Err(err).Msgf("reading notification preference %s of user %s failed", key, userID)
+			}
+			continue
+		}
+		p, err := preference.Parse(v)
+		if err != nil {
+			s.log.Warn().Err(err).Msgf("invalid notification preference %s of user %s", key, userID)
+			continue
+		}
+		return *p
+	}
+	return s.conf.DefaultPreference
+}
+
+// addToInbox stores the notification in the inbox of a recipient.
+func (s *svc) addToInbox(tr trigger.Trigger, recipient, subject string, t time.Time) {
+	if s.inbox == nil {
+		return
+	}
+
+	n := &inbox.Notification{
+		Recipient:  recipient,
+		App:        "reva",
+		ObjectType: tr.Notification.TemplateName,
+		ObjectID:   tr.Ref,
+		Subject:    subject,
+		CreatedAt:  t,
+	}
+	if err := s.inbox.Add(s.ctx, n); err != nil {
+		s.log.Error().Err(err).Msgf("adding notification %s to the inbox of %s failed", tr.Ref, recipient)
+	}
+}
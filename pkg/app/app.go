@@ -26,6 +26,10 @@ import (
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 )
 
+// DefaultAppsNamespace is the namespace of the preferences holding the
+// default apps chosen by a user, keyed by mime type.
+const DefaultAppsNamespace = "default-apps"
+
 // Registry is the interface that application registries implement
 // for discovering application providers.
 type Registry interface {
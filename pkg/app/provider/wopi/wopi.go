@@ -42,6 +42,7 @@ import (
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/app"
 	"github.com/cs3org/reva/v3/pkg/app/provider/registry"
+	appwopi "github.com/cs3org/reva/v3/pkg/app/wopi"
 	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/cs3org/reva/v3/pkg/auth/scope"
 	"github.com/cs3org/reva/v3/pkg/errtypes"
@@ -78,6 +79,7 @@ type config struct {
 	MimeTypes           []string `docs:"nil;Inherited from the appprovider."                                         mapstructure:"mime_types"`
 	IOPSecret           string   `docs:";The IOP secret used to connect to the wopiserver."                          mapstructure:"iop_secret"`
 	WopiURL             string   `docs:";The wopiserver's URL."                                                      mapstructure:"wopi_url"`
+	WopiHostURL         string   `docs:";The public URL of the built-in WOPI host, replacing the wopiserver if set."  mapstructure:"wopi_host_url"`
 	AppName             string   `docs:";The App user-friendly name."                                                mapstructure:"app_name"`
 	AppIconURI          string   `docs:";A URI to a static asset which represents the app icon."                     mapstructure:"app_icon_uri"`
 	FolderBaseURL       string   `docs:";The base URL to generate links to navigate back to the containing folder."  mapstructure:"folder_base_url"`
@@ -141,18 +143,7 @@ func (p *wopiProvider) GetAppURL(ctx context.Context, resource *provider.Resourc
 	log := appctx.GetLogger(ctx)
 
 	ext := path.Ext(resource.Path)
-	wopiurl, err := url.Parse(p.conf.WopiURL)
-	if err != nil {
-		return nil, "", err
-	}
-	wopiurl.Path, _ = url.JoinPath(wopiurl.Path, "/wopi/iop/openinapp")
-
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, wopiurl.String(), nil)
-	if err != nil {
-		return nil, "", err
-	}
-
-	q := httpReq.URL.Query()
+	q := url.Values{}
 	q.Add("fileid", resource.GetId().OpaqueId)
 	q.Add("endpoint", resource.GetId().StorageId)
 	q.Add("viewmode", viewMode.String())
@@ -268,6 +259,71 @@ func (p *wopiProvider) GetAppURL(ctx context.Context, resource *provider.Resourc
 		q.Add("appinturl", p.conf.AppIntURL)
 	}
 
+	var appFullURL, accessToken, forcedvm string
+	var err error
+	if p.conf.WopiHostURL != "" {
+		appFullURL, accessToken, err = p.builtinHostAppURL(ctx, resource, viewMode, token, q)
+	} else {
+		appFullURL, accessToken, forcedvm, err = p.wopiServerAppURL(ctx, token, q)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	tokenTTL, err := p.getAccessTokenTTL(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if language != "" {
+		url, err := url.Parse(appFullURL)
+		if err != nil {
+			return nil, "", err
+		}
+		urlQuery := url.Query()
+		urlQuery.Set("ui", language)   // OnlyOffice + Office365
+		urlQuery.Set("lang", language) // Collabora
+		urlQuery.Set("rs", language)   // Office365, https://learn.microsoft.com/en-us/microsoft-365/cloud-storage-partner-program/online/discovery#dc_llcc
+		url.RawQuery = urlQuery.Encode()
+		appFullURL = url.String()
+	}
+
+	// Depending on whether an access token is to be passed to the app or not,
+	// we decide whether the request method is POST or GET
+	var formParams map[string]string
+	method := http.MethodGet
+	if accessToken != "" {
+		formParams = map[string]string{
+			"access_token":     accessToken,
+			"access_token_ttl": tokenTTL,
+		}
+		method = http.MethodPost
+	}
+
+	return &appprovider.OpenInAppURL{
+		AppUrl:         appFullURL,
+		Method:         method,
+		FormParameters: formParams,
+		Target:         appprovider.Target_TARGET_IFRAME,
+	}, forcedvm, nil
+}
+
+// wopiServerAppURL asks the wopiserver to open the file, and returns the
+// url of the app, the access token to pass it and the reason of a forced
+// view mode, if any.
+func (p *wopiProvider) wopiServerAppURL(ctx context.Context, token string, q url.Values) (string, string, string, error) {
+	log := appctx.GetLogger(ctx)
+
+	wopiurl, err := url.Parse(p.conf.WopiURL)
+	if err != nil {
+		return "", "", "", err
+	}
+	wopiurl.Path, _ = url.JoinPath(wopiurl.Path, "/wopi/iop/openinapp")
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, wopiurl.String(), nil)
+	if err != nil {
+		return "", "", "", err
+	}
 	httpReq.URL.RawQuery = q.Encode()
 
 	if p.conf.AppAPIKey != "" {
@@ -281,13 +337,13 @@ func (p *wopiProvider) GetAppURL(ctx context.Context, resource *provider.Resourc
 	// Call the WOPI server and parse the response (body will always contain a payload)
 	openRes, err := p.wopiClient.Do(httpReq)
 	if err != nil {
-		return nil, "", errors.Wrap(err, "wopi: error performing open request to wopiserver")
+		return "", "", "", errors.Wrap(err, "wopi: error performing open request to wopiserver")
 	}
 	defer openRes.Body.Close()
 
 	body, err := io.ReadAll(openRes.Body)
 	if err != nil {
-		return nil, "", err
+		return "", "", "", err
 	}
 	if openRes.StatusCode != http.StatusOK {
 		// WOPI returned failure: body contains a user-friendly error message (yet perform a sanity check)
@@ -296,46 +352,22 @@ func (p *wopiProvider) GetAppURL(ctx context.Context, resource *provider.Resourc
 			sbody = string(body)
 		}
 		log.Warn().Str("status", openRes.Status).Str("error", sbody).Msg("wopi: wopiserver returned error")
-		return nil, "", errors.New(sbody)
+		return "", "", "", errors.New(sbody)
 	}
 
 	var result map[string]any
 	err = json.Unmarshal(body, &result)
 	if err != nil {
-		return nil, "", err
-	}
-
-	tokenTTL, err := p.getAccessTokenTTL(ctx)
-	if err != nil {
-		return nil, "", err
+		return "", "", "", err
 	}
 
 	appFullURL := result["app-url"].(string)
 
-	if language != "" {
-		url, err := url.Parse(appFullURL)
-		if err != nil {
-			return nil, "", err
-		}
-		urlQuery := url.Query()
-		urlQuery.Set("ui", language)   // OnlyOffice + Office365
-		urlQuery.Set("lang", language) // Collabora
-		urlQuery.Set("rs", language)   // Office365, https://learn.microsoft.com/en-us/microsoft-365/cloud-storage-partner-program/online/discovery#dc_llcc
-		url.RawQuery = urlQuery.Encode()
-		appFullURL = url.String()
-	}
-
-	// Depending on whether the WOPI server returned any form parameters or not,
-	// we decide whether the request method is POST or GET
-	var formParams map[string]string
-	method := http.MethodGet
+	// the access token is only returned when the app is to be called with POST
+	var accessToken string
 	if form, ok := result["form-parameters"].(map[string]any); ok {
 		if tkn, ok := form["access_token"].(string); ok {
-			formParams = map[string]string{
-				"access_token":     tkn,
-				"access_token_ttl": tokenTTL,
-			}
-			method = http.MethodPost
+			accessToken = tkn
 		}
 	}
 
@@ -344,12 +376,43 @@ func (p *wopiProvider) GetAppURL(ctx context.Context, resource *provider.Resourc
 		forcedvm = fvm
 		log.Info().Str("reason", forcedvm).Msg("wopi: forced viewmode change")
 	}
-	return &appprovider.OpenInAppURL{
-		AppUrl:         appFullURL,
-		Method:         method,
-		FormParameters: formParams,
-		Target:         appprovider.Target_TARGET_IFRAME,
-	}, forcedvm, nil
+	return appFullURL, accessToken, forcedvm, nil
+}
+
+// builtinHostAppURL returns the url of the app, pointing it to the file on
+// the built-in WOPI host, and the access token to pass it.
+func (p *wopiProvider) builtinHostAppURL(ctx context.Context, resource *provider.ResourceInfo, viewMode appprovider.ViewMode, token string, q url.Values) (string, string, error) {
+	appURL := q.Get("appurl")
+	if viewMode != appprovider.ViewMode_VIEW_MODE_READ_WRITE && q.Get("appviewurl") != "" {
+		appURL = q.Get("appviewurl")
+	}
+	u, err := url.Parse(appURL)
+	if err != nil {
+		return "", "", err
+	}
+	uq := u.Query()
+	uq.Set("WOPISrc", appwopi.FileURL(p.conf.WopiHostURL, resource.Id))
+	u.RawQuery = uq.Encode()
+
+	expiration, err := p.getAccessTokenExpiration(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	user := appctx.ContextMustGetUser(ctx)
+	accessToken, err := appwopi.NewAccessToken(p.conf.JWTSecret, &appwopi.AccessToken{
+		RevaToken: token,
+		FileID:    resource.Id,
+		ViewMode:  viewMode,
+		UserID:    q.Get("userid"),
+		UserName:  q.Get("username"),
+		User:      user.Id,
+		AppName:   p.conf.AppName,
+		FolderURL: q.Get("folderurl"),
+	}, expiration)
+	if err != nil {
+		return "", "", err
+	}
+	return u.String(), accessToken, nil
 }
 
 func (p *wopiProvider) GetAppProviderInfo(ctx context.Context) (*appregistry.ProviderInfo, error) {
@@ -403,10 +466,12 @@ func getAppURLs(c *config) (map[string]map[string]string, error) {
 	var appURLs map[string]map[string]string
 
 	if discRes.StatusCode == http.StatusOK {
-		appURLs, err = parseWopiDiscovery(discRes.Body)
+		var mimeTypes map[string]string
+		appURLs, mimeTypes, err = parseWopiDiscovery(discRes.Body)
 		if err != nil {
 			return nil, errors.Wrap(err, "error parsing wopi discovery response")
 		}
+		registerDiscoveredMimeTypes(mimeTypes)
 	} else if discRes.StatusCode == http.StatusNotFound {
 		// this may be a bridge-supported app
 		discReq, err = http.NewRequest(http.MethodGet, c.AppIntURL, nil)
@@ -451,44 +516,65 @@ func getAppURLs(c *config) (map[string]map[string]string, error) {
 }
 
 func (p *wopiProvider) getAccessTokenTTL(ctx context.Context) (string, error) {
+	expiration, err := p.getAccessTokenExpiration(ctx)
+	if err != nil {
+		return "", err
+	}
+	// milliseconds since Jan 1, 1970 UTC as required in https://learn.microsoft.com/en-us/microsoft-365/cloud-storage-partner-program/rest/concepts#the-access_token_ttl-property
+	return strconv.FormatInt(expiration.UnixMicro()/1000, 10), nil
+}
+
+func (p *wopiProvider) getAccessTokenExpiration(ctx context.Context) (time.Time, error) {
 	tkn := appctx.ContextMustGetToken(ctx)
 	token, err := jwt.ParseWithClaims(tkn, &jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
 		return []byte(p.conf.JWTSecret), nil
 	})
 	if err != nil {
-		return "", err
+		return time.Time{}, err
 	}
 
 	if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok && token.Valid {
-		// milliseconds since Jan 1, 1970 UTC as required in https://learn.microsoft.com/en-us/microsoft-365/cloud-storage-partner-program/rest/concepts#the-access_token_ttl-property
-		return strconv.FormatInt(claims.ExpiresAt.UnixMicro()/1000, 10), nil
+		return claims.ExpiresAt.Time, nil
 	}
 
-	return "", errtypes.InvalidCredentials("wopi: invalid token present in ctx")
+	return time.Time{}, errtypes.InvalidCredentials("wopi: invalid token present in ctx")
 }
 
-func parseWopiDiscovery(body io.Reader) (map[string]map[string]string, error) {
+// parseWopiDiscovery returns the app urls of the discovery, by action and
+// file extension, and the mime types it declares for the extensions.
+// Apps may be named after a mime type, as in Collabora, with actions for
+// all the extensions of the mime type or for a given one.
+func parseWopiDiscovery(body io.Reader) (map[string]map[string]string, map[string]string, error) {
 	appURLs := make(map[string]map[string]string)
+	mimeTypes := make(map[string]string)
 
 	doc := etree.NewDocument()
 	if _, err := doc.ReadFrom(body); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	root := doc.SelectElement("wopi-discovery")
 	if root == nil {
-		return nil, errors.New("wopi-discovery response malformed")
+		return nil, nil, errors.New("wopi-discovery response malformed")
 	}
 
 	for _, netzone := range root.SelectElements("net-zone") {
 		if strings.Contains(netzone.SelectAttrValue("name", ""), "external") {
 			for _, app := range netzone.SelectElements("app") {
+				appMime := app.SelectAttrValue("name", "")
+				if !strings.Contains(appMime, "/") {
+					appMime = ""
+				}
 				for _, action := range app.SelectElements("action") {
 					access := action.SelectAttrValue("name", "")
 					if access == "view" || access == "edit" || access == "editnew" || access == "embedview" {
 						ext := action.SelectAttrValue("ext", "")
 						urlString := action.SelectAttrValue("urlsrc", "")
 
-						if ext == "" || urlString == "" {
+						exts := []string{ext}
+						if ext == "" && appMime != "" {
+							exts = mime.GetFileExts(appMime)
+						}
+						if len(exts) == 0 || exts[0] == "" || urlString == "" {
 							continue
 						}
 
@@ -513,13 +599,32 @@ func parseWopiDiscovery(body io.Reader) (map[string]map[string]string, error) {
 						if _, ok := appURLs[access]; !ok {
 							appURLs[access] = make(map[string]string)
 						}
-						appURLs[access]["."+ext] = u.String()
+						for _, e := range exts {
+							// actions for a given extension win over the ones for a mime type
+							if _, ok := appURLs[access]["."+e]; !ok || ext != "" {
+								appURLs[access]["."+e] = u.String()
+							}
+							if appMime != "" {
+								mimeTypes["."+e] = appMime
+							}
+						}
 					}
 				}
 			}
 		}
 	}
-	return appURLs, nil
+	return appURLs, mimeTypes, nil
+}
+
+// registerDiscoveredMimeTypes registers the mime types declared by the
+// discovery for the extensions that have none yet, so that the app is
+// registered for them in the app registry.
+func registerDiscoveredMimeTypes(mimeTypes map[string]string) {
+	for ext, m := range mimeTypes {
+		if mime.Detect(false, ext) == "application/octet-stream" {
+			mime.RegisterMime(ext, m)
+		}
+	}
 }
 
 func getPathForExternalLink(ctx context.Context, scopes map[string]*authpb.Scope, resource *provider.ResourceInfo, pathPrefix string) (string, error) {
@@ -20,6 +20,7 @@ package wopi
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
@@ -28,6 +29,7 @@ import (
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/appctx"
 	authscope "github.com/cs3org/reva/v3/pkg/auth/scope"
+	"github.com/cs3org/reva/v3/pkg/mime"
 	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
 	"google.golang.org/grpc"
 )
@@ -111,3 +113,47 @@ func TestGetPathForExternalLinkFallsBackToToken(t *testing.T) {
 		t.Fatalf("getPathForExternalLink() = %q, want %q", got, ocmLinkURLPrefix+"legacy-token")
 	}
 }
+
+func TestParseWopiDiscovery(t *testing.T) {
+	discovery := `<?xml version="1.0" encoding="utf-8"?>
+<wopi-discovery>
+  <net-zone name="external-http">
+    <app name="application/vnd.oasis.opendocument.text">
+      <action default="true" ext="" name="edit" urlsrc="https://collabora.example.org/browser/dist/cool.html?"/>
+    </app>
+    <app name="application/x-reva-test">
+      <action ext="revatest" name="view" urlsrc="https://collabora.example.org/browser/dist/cool.html?"/>
+    </app>
+    <app name="writer">
+      <action ext="odt" name="view" urlsrc="https://collabora.example.org/browser/dist/view.html?&lt;ui=UI_LLCC&amp;&gt;"/>
+    </app>
+  </net-zone>
+  <net-zone name="internal-http">
+    <app name="Word">
+      <action ext="docx" name="edit" urlsrc="https://internal.example.org/edit"/>
+    </app>
+  </net-zone>
+</wopi-discovery>`
+
+	appURLs, mimeTypes, err := parseWopiDiscovery(strings.NewReader(discovery))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := appURLs["edit"][".odt"]; got != "https://collabora.example.org/browser/dist/cool.html?" {
+		t.Errorf("edit url for .odt = %q", got)
+	}
+	if got := appURLs["view"][".odt"]; got != "https://collabora.example.org/browser/dist/view.html" {
+		t.Errorf("view url for .odt = %q", got)
+	}
+	if _, ok := appURLs["edit"][".docx"]; ok {
+		t.Error("actions of the internal net zone must be ignored")
+	}
+	if got := mimeTypes[".revatest"]; got != "application/x-reva-test" {
+		t.Errorf("mime type of .revatest = %q", got)
+	}
+
+	registerDiscoveredMimeTypes(mimeTypes)
+	if got := mime.Detect(false, "file.revatest"); got != "application/x-reva-test" {
+		t.Errorf("detected mime type of .revatest = %q", got)
+	}
+}
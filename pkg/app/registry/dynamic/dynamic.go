@@ -0,0 +1,325 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package dynamic
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	registrypb "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/app"
+	"github.com/cs3org/reva/v3/pkg/app/registry/registry"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+	orderedmap "github.com/wk8/go-ordered-map"
+)
+
+func init() {
+	registry.Register("dynamic", New)
+}
+
+const defaultPriority = 0
+
+type mimeTypeConfig struct {
+	MimeType      string `mapstructure:"mime_type"`
+	Extension     string `mapstructure:"extension"`
+	Name          string `mapstructure:"name"`
+	Description   string `mapstructure:"description"`
+	Icon          string `mapstructure:"icon"`
+	DefaultApp    string `mapstructure:"default_app"`
+	AllowCreation bool   `mapstructure:"allow_creation"`
+}
+
+type config struct {
+	Providers   []*registrypb.ProviderInfo `docs:"nil;Providers that are always considered healthy and never expire."                        mapstructure:"providers"`
+	MimeTypes   []*mimeTypeConfig          `mapstructure:"mime_types"`
+	ProviderTTL int                        `docs:"90;Seconds after which a provider that did not register again is considered unhealthy." mapstructure:"provider_ttl"`
+	Store       string                     `docs:"memory;Where the registrations are kept: memory, sql or redis."                         mapstructure:"store"`
+	Stores      map[string]map[string]any  `mapstructure:"stores"`
+}
+
+func (c *config) ApplyDefaults() {
+	if c.ProviderTTL == 0 {
+		c.ProviderTTL = 90
+	}
+	if c.Store == "" {
+		c.Store = "memory"
+	}
+}
+
+type manager struct {
+	mimetypes *orderedmap.OrderedMap // map[string]*mimeTypeConfig
+	static    map[string]*registrypb.ProviderInfo
+	store     store
+	ttl       time.Duration
+	now       func() time.Time
+}
+
+// New returns an implementation of the app.Registry interface where the
+// app providers register themselves with a TTL: a provider that does not
+// register again before its registration expires is considered down and
+// is hidden from the registry until it comes back.
+func New(ctx context.Context, m map[string]any) (app.Registry, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	s, err := newStore(ctx, c.Store, c.Stores[c.Store])
+	if err != nil {
+		return nil, err
+	}
+
+	mimetypes := orderedmap.New()
+	for _, mime := range c.MimeTypes {
+		mimetypes.Set(mime.MimeType, mime)
+	}
+
+	static := make(map[string]*registrypb.ProviderInfo, len(c.Providers))
+	for _, p := range c.Providers {
+		if p != nil {
+			static[p.Address] = p
+		}
+	}
+
+	return &manager{
+		mimetypes: mimetypes,
+		static:    static,
+		store:     s,
+		ttl:       time.Duration(c.ProviderTTL) * time.Second,
+		now:       time.Now,
+	}, nil
+}
+
+func getPriority(p *registrypb.ProviderInfo) uint64 {
+	if p.Opaque != nil && len(p.Opaque.Map) != 0 {
+		if priority, ok := p.Opaque.Map["priority"]; ok {
+			if pr, err := strconv.ParseUint(string(priority.GetValue()), 10, 64); err == nil {
+				return pr
+			}
+		}
+	}
+	return defaultPriority
+}
+
+// healthyProviders returns the configured providers together with the
+// registered ones whose registration has not expired yet, sorted by address.
+func (m *manager) healthyProviders(ctx context.Context) ([]*registrypb.ProviderInfo, error) {
+	regs, err := m.store.List(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "dynamic: error listing app providers")
+	}
+
+	providers := make(map[string]*registrypb.ProviderInfo, len(m.static)+len(regs))
+	for addr, p := range m.static {
+		providers[addr] = p
+	}
+	now := m.now()
+	for _, r := range regs {
+		if r.ExpiresAt.After(now) {
+			providers[r.Provider.Address] = r.Provider
+		}
+	}
+
+	res := make([]*registrypb.ProviderInfo, 0, len(providers))
+	for _, p := range providers {
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Address < res[j].Address })
+	return res, nil
+}
+
+// providersForMimeType returns the providers supporting the given mime type,
+// the ones with the highest priority first.
+func providersForMimeType(providers []*registrypb.ProviderInfo, mimeType string) []*registrypb.ProviderInfo {
+	var res []*registrypb.ProviderInfo
+	for _, p := range providers {
+		for _, m := range p.MimeTypes {
+			if m == mimeType {
+				res = append(res, p)
+				break
+			}
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return getPriority(res[i]) > getPriority(res[j]) })
+	return res
+}
+
+// mimeTypes returns the configured mime types followed by the ones
+// only known from the providers.
+func (m *manager) mimeTypes(providers []*registrypb.ProviderInfo) []string {
+	res := make([]string, 0, m.mimetypes.Len())
+	seen := make(map[string]bool)
+	for pair := m.mimetypes.Oldest(); pair != nil; pair = pair.Next() {
+		mime := pair.Key.(string)
+		res = append(res, mime)
+		seen[mime] = true
+	}
+
+	var extra []string
+	for _, p := range providers {
+		for _, mime := range p.MimeTypes {
+			if !seen[mime] {
+				extra = append(extra, mime)
+				seen[mime] = true
+			}
+		}
+	}
+	sort.Strings(extra)
+	return append(res, extra...)
+}
+
+func (m *manager) FindProviders(ctx context.Context, mimeType string) ([]*registrypb.ProviderInfo, error) {
+	providers, err := m.healthyProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// find longest match having at least a healthy provider
+	var match []*registrypb.ProviderInfo
+	var matchLen int
+	for _, prefix := range m.mimeTypes(providers) {
+		if !strings.HasPrefix(mimeType, prefix) || len(prefix) <= matchLen {
+			continue
+		}
+		if found := providersForMimeType(providers, prefix); len(found) != 0 {
+			match, matchLen = found, len(prefix)
+		}
+	}
+
+	if len(match) == 0 {
+		return nil, errtypes.NotFound("application provider not found for mime type " + mimeType)
+	}
+	return match, nil
+}
+
+func (m *manager) AddProvider(ctx context.Context, p *registrypb.ProviderInfo) error {
+	if p.Address == "" {
+		return errtypes.BadRequest("dynamic: app provider address must be set")
+	}
+
+	now := m.now()
+	if err := m.store.Register(ctx, p, now.Add(m.ttl)); err != nil {
+		return errors.Wrap(err, "dynamic: error registering app provider")
+	}
+
+	// deregister the providers that did not show up for a while
+	if err := m.store.Purge(ctx, now); err != nil {
+		appctx.GetLogger(ctx).Warn().Err(err).Msg("dynamic: error purging expired app providers")
+	}
+	return nil
+}
+
+func (m *manager) ListProviders(ctx context.Context) ([]*registrypb.ProviderInfo, error) {
+	return m.healthyProviders(ctx)
+}
+
+func (m *manager) ListSupportedMimeTypes(ctx context.Context) ([]*registrypb.MimeTypeInfo, error) {
+	providers, err := m.healthyProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*registrypb.MimeTypeInfo
+	for _, mimeType := range m.mimeTypes(providers) {
+		apps := providersForMimeType(providers, mimeType)
+		if len(apps) == 0 {
+			// nothing can open it right now
+			continue
+		}
+
+		info := &registrypb.MimeTypeInfo{
+			MimeType:     mimeType,
+			AppProviders: apps,
+		}
+		if v, ok := m.mimetypes.Get(mimeType); ok {
+			mime := v.(*mimeTypeConfig)
+			info.Ext = mime.Extension
+			info.Name = mime.Name
+			info.Description = mime.Description
+			info.Icon = mime.Icon
+			info.AllowCreation = mime.AllowCreation
+		}
+		if info.DefaultApplication, err = m.defaultApp(ctx, mimeType); err != nil {
+			return nil, err
+		}
+		res = append(res, info)
+	}
+	return res, nil
+}
+
+// defaultApp returns the address or the name of the default app for the
+// given mime type, the one set at runtime taking precedence over the configuration.
+func (m *manager) defaultApp(ctx context.Context, mimeType string) (string, error) {
+	def, err := m.store.GetDefault(ctx, mimeType)
+	switch err.(type) {
+	case nil:
+		return def, nil
+	case errtypes.IsNotFound:
+	default:
+		return "", errors.Wrap(err, "dynamic: error getting default app provider")
+	}
+
+	if v, ok := m.mimetypes.Get(mimeType); ok {
+		return v.(*mimeTypeConfig).DefaultApp, nil
+	}
+	return "", nil
+}
+
+func (m *manager) SetDefaultProviderForMimeType(ctx context.Context, mimeType string, p *registrypb.ProviderInfo) error {
+	if err := m.store.SetDefault(ctx, mimeType, p.Address); err != nil {
+		return errors.Wrap(err, "dynamic: error setting default app provider")
+	}
+	return nil
+}
+
+func (m *manager) GetDefaultProviderForMimeType(ctx context.Context, mimeType string) (*registrypb.ProviderInfo, error) {
+	def, err := m.defaultApp(ctx, mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	if def != "" {
+		providers, err := m.healthyProviders(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// default by provider address
+		for _, p := range providers {
+			if p.Address == def {
+				return p, nil
+			}
+		}
+
+		// default by provider name
+		for _, p := range providers {
+			if p.Name == def {
+				return p, nil
+			}
+		}
+	}
+
+	return nil, errtypes.NotFound("default application provider not set for mime type " + mimeType)
+}
@@ -0,0 +1,238 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package dynamic
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	registrypb "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
+	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestManager(t *testing.T, c *clock, conf map[string]any) *manager {
+	t.Helper()
+	r, err := New(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("creating registry: %v", err)
+	}
+	m := r.(*manager)
+	m.now = c.now
+	return m
+}
+
+func provider(name string, priority string, mimeTypes ...string) *registrypb.ProviderInfo {
+	return &registrypb.ProviderInfo{
+		Name:      name,
+		Address:   "ip-" + name,
+		MimeTypes: mimeTypes,
+		Opaque: &typesv1beta1.Opaque{
+			Map: map[string]*typesv1beta1.OpaqueEntry{
+				"priority": {Decoder: "plain", Value: []byte(priority)},
+			},
+		},
+	}
+}
+
+func names(providers []*registrypb.ProviderInfo) []string {
+	res := make([]string, 0, len(providers))
+	for _, p := range providers {
+		res = append(res, p.Name)
+	}
+	return res
+}
+
+func assertProviders(t *testing.T, m *manager, mimeType string, expected ...string) {
+	t.Helper()
+	providers, err := m.FindProviders(context.Background(), mimeType)
+	if len(expected) == 0 {
+		if _, ok := err.(errtypes.IsNotFound); !ok {
+			t.Fatalf("FindProviders(%s) = %v, %v, want not found", mimeType, names(providers), err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("FindProviders(%s): %v", mimeType, err)
+	}
+	if got := names(providers); len(got) != len(expected) || !equal(got, expected) {
+		t.Fatalf("FindProviders(%s) = %v, want %v", mimeType, got, expected)
+	}
+}
+
+func equal(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testExpiry(t *testing.T, conf map[string]any) {
+	ctx := context.Background()
+	c := &clock{t: time.Unix(1700000000, 0)}
+	conf["provider_ttl"] = 60
+	conf["mime_types"] = []map[string]any{
+		{"mime_type": "text/plain", "extension": "txt", "name": "Text file", "allow_creation": true},
+		{"mime_type": "application/pdf", "extension": "pdf"},
+	}
+	m := newTestManager(t, c, conf)
+
+	for _, p := range []*registrypb.ProviderInfo{
+		provider("collabora", "10", "text/plain", "application/vnd.oasis.opendocument.text"),
+		provider("code", "20", "text/plain"),
+	} {
+		if err := m.AddProvider(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertProviders(t, m, "text/plain", "code", "collabora")
+	assertProviders(t, m, "application/vnd.oasis.opendocument.text", "collabora")
+	assertProviders(t, m, "application/pdf")
+
+	mimeTypes, err := m.ListSupportedMimeTypes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mimeTypes) != 2 || mimeTypes[0].MimeType != "text/plain" || mimeTypes[1].MimeType != "application/vnd.oasis.opendocument.text" {
+		t.Fatalf("unexpected mime types %v", mimeTypes)
+	}
+	if !mimeTypes[0].AllowCreation || mimeTypes[0].Ext != "txt" || !equal(names(mimeTypes[0].AppProviders), []string{"code", "collabora"}) {
+		t.Fatalf("unexpected mime type %v", mimeTypes[0])
+	}
+
+	// only collabora keeps registering
+	c.t = c.t.Add(40 * time.Second)
+	if err := m.AddProvider(ctx, provider("collabora", "10", "text/plain", "application/vnd.oasis.opendocument.text")); err != nil {
+		t.Fatal(err)
+	}
+	c.t = c.t.Add(40 * time.Second)
+	assertProviders(t, m, "text/plain", "collabora")
+
+	// nobody is left
+	c.t = c.t.Add(time.Minute)
+	assertProviders(t, m, "text/plain")
+	if providers, err := m.ListProviders(ctx); err != nil || len(providers) != 0 {
+		t.Fatalf("ListProviders = %v, %v, want none", names(providers), err)
+	}
+	if mimeTypes, err := m.ListSupportedMimeTypes(ctx); err != nil || len(mimeTypes) != 0 {
+		t.Fatalf("ListSupportedMimeTypes = %v, %v, want none", mimeTypes, err)
+	}
+
+	// and code comes back
+	if err := m.AddProvider(ctx, provider("code", "20", "text/plain")); err != nil {
+		t.Fatal(err)
+	}
+	assertProviders(t, m, "text/plain", "code")
+}
+
+func TestExpiry(t *testing.T) {
+	testExpiry(t, map[string]any{})
+}
+
+func TestExpirySQL(t *testing.T) {
+	testExpiry(t, map[string]any{
+		"store": "sql",
+		"stores": map[string]any{
+			"sql": map[string]any{
+				"db_engine": "sqlite",
+				"db_name":   filepath.Join(t.TempDir(), "appregistry.db"),
+			},
+		},
+	})
+}
+
+func TestSharedStore(t *testing.T) {
+	ctx := context.Background()
+	c := &clock{t: time.Unix(1700000000, 0)}
+	conf := map[string]any{
+		"store": "sql",
+		"stores": map[string]any{
+			"sql": map[string]any{
+				"db_engine": "sqlite",
+				"db_name":   filepath.Join(t.TempDir(), "appregistry.db"),
+			},
+		},
+	}
+	gw1 := newTestManager(t, c, conf)
+	gw2 := newTestManager(t, c, conf)
+
+	if err := gw1.AddProvider(ctx, provider("code", "20", "text/plain")); err != nil {
+		t.Fatal(err)
+	}
+	assertProviders(t, gw2, "text/plain", "code")
+
+	if err := gw2.SetDefaultProviderForMimeType(ctx, "text/plain", &registrypb.ProviderInfo{Address: "ip-code"}); err != nil {
+		t.Fatal(err)
+	}
+	p, err := gw1.GetDefaultProviderForMimeType(ctx, "text/plain")
+	if err != nil || p.Name != "code" {
+		t.Fatalf("GetDefaultProviderForMimeType = %v, %v, want code", p, err)
+	}
+}
+
+func TestDefaultProvider(t *testing.T) {
+	ctx := context.Background()
+	c := &clock{t: time.Unix(1700000000, 0)}
+	m := newTestManager(t, c, map[string]any{
+		"mime_types": []map[string]any{
+			{"mime_type": "text/plain", "default_app": "collabora"},
+		},
+		"providers": []map[string]any{
+			{"name": "static", "address": "ip-static", "mimetypes": []string{"text/plain"}},
+		},
+	})
+
+	// the configured providers never expire
+	assertProviders(t, m, "text/plain", "static")
+
+	// the default app is down
+	if _, err := m.GetDefaultProviderForMimeType(ctx, "text/plain"); err == nil {
+		t.Fatal("expected an error for an unhealthy default app")
+	}
+
+	if err := m.AddProvider(ctx, provider("collabora", "10", "text/plain")); err != nil {
+		t.Fatal(err)
+	}
+	p, err := m.GetDefaultProviderForMimeType(ctx, "text/plain")
+	if err != nil || p.Name != "collabora" {
+		t.Fatalf("GetDefaultProviderForMimeType = %v, %v, want collabora", p, err)
+	}
+
+	if err := m.SetDefaultProviderForMimeType(ctx, "text/plain", &registrypb.ProviderInfo{Address: "ip-static"}); err != nil {
+		t.Fatal(err)
+	}
+	p, err = m.GetDefaultProviderForMimeType(ctx, "text/plain")
+	if err != nil || p.Name != "static" {
+		t.Fatalf("GetDefaultProviderForMimeType = %v, %v, want static", p, err)
+	}
+
+	c.t = c.t.Add(time.Hour)
+	assertProviders(t, m, "text/plain", "static")
+}
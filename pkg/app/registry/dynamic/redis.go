@@ -0,0 +1,194 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package dynamic
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	registrypb "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/utils"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+type redisConfig struct {
+	RedisAddress  string `mapstructure:"redis_address"`
+	RedisUsername string `mapstructure:"redis_username"`
+	RedisPassword string `mapstructure:"redis_password"`
+	KeyPrefix     string `mapstructure:"key_prefix"`
+}
+
+func (c *redisConfig) ApplyDefaults() {
+	if c.RedisAddress == "" {
+		c.RedisAddress = "localhost:6379"
+	}
+	if c.KeyPrefix == "" {
+		c.KeyPrefix = "reva:appregistry:"
+	}
+}
+
+// redisEntry is the value stored for every provider
+// in the providers hash, keyed by the provider address.
+type redisEntry struct {
+	Provider  json.RawMessage `json:"provider"`
+	ExpiresAt int64           `json:"expires_at"`
+}
+
+type redisStore struct {
+	pool        *redis.Pool
+	providerKey string
+	defaultKey  string
+}
+
+func newRedisStore(m map[string]any) (*redisStore, error) {
+	var c redisConfig
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	pool := &redis.Pool{
+		MaxIdle:     50,
+		MaxActive:   1000,
+		IdleTimeout: 240 * time.Second,
+
+		Dial: func() (redis.Conn, error) {
+			var opts []redis.DialOption
+			if c.RedisUsername != "" {
+				opts = append(opts, redis.DialUsername(c.RedisUsername))
+			}
+			if c.RedisPassword != "" {
+				opts = append(opts, redis.DialPassword(c.RedisPassword))
+			}
+			return redis.Dial("tcp", c.RedisAddress, opts...)
+		},
+
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+
+	return &redisStore{
+		pool:        pool,
+		providerKey: c.KeyPrefix + "providers",
+		defaultKey:  c.KeyPrefix + "defaults",
+	}, nil
+}
+
+func (s *redisStore) Register(ctx context.Context, p *registrypb.ProviderInfo, expiresAt time.Time) error {
+	info, err := utils.MarshalProtoV1ToJSON(p)
+	if err != nil {
+		return err
+	}
+	v, err := json.Marshal(&redisEntry{Provider: info, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return err
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+	_, err = conn.Do("HSET", s.providerKey, p.Address, v)
+	return err
+}
+
+func (s *redisStore) entries() (map[string]*redisEntry, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	vals, err := redis.StringMap(conn.Do("HGETALL", s.providerKey))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]*redisEntry, len(vals))
+	for addr, v := range vals {
+		var e redisEntry
+		if err := json.Unmarshal([]byte(v), &e); err != nil {
+			return nil, errors.Wrap(err, "error decoding app provider "+addr)
+		}
+		res[addr] = &e
+	}
+	return res, nil
+}
+
+func (s *redisStore) List(ctx context.Context) ([]*registration, error) {
+	entries, err := s.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*registration, 0, len(entries))
+	for addr, e := range entries {
+		p := &registrypb.ProviderInfo{}
+		if err := utils.UnmarshalJSONToProtoV1(e.Provider, p); err != nil {
+			return nil, errors.Wrap(err, "error decoding app provider "+addr)
+		}
+		res = append(res, &registration{Provider: p, ExpiresAt: time.Unix(e.ExpiresAt, 0)})
+	}
+	return res, nil
+}
+
+func (s *redisStore) Purge(ctx context.Context, before time.Time) error {
+	entries, err := s.entries()
+	if err != nil {
+		return err
+	}
+
+	// a provider registering again in the meantime is removed as
+	// well, it will be back at its next registration
+	args := []any{s.providerKey}
+	for addr, e := range entries {
+		if e.ExpiresAt < before.Unix() {
+			args = append(args, addr)
+		}
+	}
+	if len(args) == 1 {
+		return nil
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+	_, err = conn.Do("HDEL", args...)
+	return err
+}
+
+func (s *redisStore) SetDefault(ctx context.Context, mimeType, app string) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("HSET", s.defaultKey, mimeType, app)
+	return err
+}
+
+func (s *redisStore) GetDefault(ctx context.Context, mimeType string) (string, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	app, err := redis.String(conn.Do("HGET", s.defaultKey, mimeType))
+	if err != nil {
+		if errors.Is(err, redis.ErrNil) {
+			return "", errtypes.NotFound("default app for mime type " + mimeType)
+		}
+		return "", err
+	}
+	return app, nil
+}
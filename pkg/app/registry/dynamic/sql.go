@@ -0,0 +1,152 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package dynamic
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	registrypb "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
+	revadconfig "github.com/cs3org/reva/v3/cmd/revad/pkg/config"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/utils"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type sqlConfig struct {
+	revadconfig.Database `mapstructure:",squash"`
+}
+
+func (c *sqlConfig) ApplyDefaults() {
+	c.Database = sharedconf.GetDBInfo(c.Database)
+}
+
+// AppProvider is an app provider registration.
+type AppProvider struct {
+	gorm.Model
+	Address   string    `gorm:"size:255;uniqueIndex:i_address"`
+	Info      string    // the JSON encoded ProviderInfo
+	ExpiresAt time.Time `gorm:"index:i_expires_at"`
+}
+
+// DefaultApp is the default app for a mime type.
+type DefaultApp struct {
+	gorm.Model
+	MimeType string `gorm:"size:255;uniqueIndex:i_mime_type"`
+	App      string
+}
+
+type sqlStore struct {
+	db *gorm.DB
+}
+
+func newSQLStore(ctx context.Context, m map[string]any) (*sqlStore, error) {
+	var c sqlConfig
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	var db *gorm.DB
+	var err error
+	switch c.Engine {
+	case "sqlite":
+		db, err = gorm.Open(sqlite.Open(c.DBName), &gorm.Config{})
+	default: // default is mysql
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
+		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to connect to app registry database using engine "+c.Engine)
+	}
+
+	if err := db.AutoMigrate(&AppProvider{}, &DefaultApp{}); err != nil {
+		return nil, errors.Wrap(err, "Failed to migrate app registry schemas")
+	}
+
+	return &sqlStore{db: db}, nil
+}
+
+func (s *sqlStore) Register(ctx context.Context, p *registrypb.ProviderInfo, expiresAt time.Time) error {
+	info, err := utils.MarshalProtoV1ToJSON(p)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"info", "expires_at", "updated_at"}),
+	}).Create(&AppProvider{
+		Address:   p.Address,
+		Info:      string(info),
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+func (s *sqlStore) List(ctx context.Context) ([]*registration, error) {
+	var fetched []AppProvider
+	if err := s.db.WithContext(ctx).Find(&fetched).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]*registration, 0, len(fetched))
+	for _, f := range fetched {
+		p := &registrypb.ProviderInfo{}
+		if err := utils.UnmarshalJSONToProtoV1([]byte(f.Info), p); err != nil {
+			return nil, errors.Wrap(err, "error decoding app provider "+f.Address)
+		}
+		res = append(res, &registration{Provider: p, ExpiresAt: f.ExpiresAt})
+	}
+	return res, nil
+}
+
+func (s *sqlStore) Purge(ctx context.Context, before time.Time) error {
+	// the rows are removed for good, a soft-deleted row would collide
+	// with the unique index when the provider comes back
+	return s.db.WithContext(ctx).Unscoped().
+		Where("expires_at < ?", before).
+		Delete(&AppProvider{}).Error
+}
+
+func (s *sqlStore) SetDefault(ctx context.Context, mimeType, app string) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "mime_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"app", "updated_at"}),
+	}).Create(&DefaultApp{
+		MimeType: mimeType,
+		App:      app,
+	}).Error
+}
+
+func (s *sqlStore) GetDefault(ctx context.Context, mimeType string) (string, error) {
+	var d DefaultApp
+	if err := s.db.WithContext(ctx).Where("mime_type = ?", mimeType).First(&d).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errtypes.NotFound("default app for mime type " + mimeType)
+		}
+		return "", err
+	}
+	return d.App, nil
+}
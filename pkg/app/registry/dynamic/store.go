@@ -0,0 +1,118 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package dynamic
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	registrypb "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+)
+
+// registration is an app provider registered until ExpiresAt.
+type registration struct {
+	Provider  *registrypb.ProviderInfo
+	ExpiresAt time.Time
+}
+
+// store keeps the registrations and the default apps set at runtime.
+// Stores shared among several gateways let all of them see the same providers.
+type store interface {
+	// Register adds the provider or refreshes its registration.
+	Register(ctx context.Context, p *registrypb.ProviderInfo, expiresAt time.Time) error
+	// List returns all the registrations, including the expired ones.
+	List(ctx context.Context) ([]*registration, error)
+	// Purge removes the registrations expired before the given time.
+	Purge(ctx context.Context, before time.Time) error
+	// SetDefault sets the default app for a mime type.
+	SetDefault(ctx context.Context, mimeType, app string) error
+	// GetDefault returns the default app for a mime type or a NotFound error.
+	GetDefault(ctx context.Context, mimeType string) (string, error)
+}
+
+func newStore(ctx context.Context, name string, m map[string]any) (store, error) {
+	switch name {
+	case "memory":
+		return newMemoryStore(), nil
+	case "sql":
+		return newSQLStore(ctx, m)
+	case "redis":
+		return newRedisStore(m)
+	}
+	return nil, errtypes.NotFound("dynamic: store not found: " + name)
+}
+
+type memoryStore struct {
+	sync.RWMutex
+	registrations map[string]*registration
+	defaults      map[string]string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		registrations: make(map[string]*registration),
+		defaults:      make(map[string]string),
+	}
+}
+
+func (s *memoryStore) Register(ctx context.Context, p *registrypb.ProviderInfo, expiresAt time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+	s.registrations[p.Address] = &registration{Provider: p, ExpiresAt: expiresAt}
+	return nil
+}
+
+func (s *memoryStore) List(ctx context.Context) ([]*registration, error) {
+	s.RLock()
+	defer s.RUnlock()
+	res := make([]*registration, 0, len(s.registrations))
+	for _, r := range s.registrations {
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func (s *memoryStore) Purge(ctx context.Context, before time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+	for addr, r := range s.registrations {
+		if r.ExpiresAt.Before(before) {
+			delete(s.registrations, addr)
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) SetDefault(ctx context.Context, mimeType, app string) error {
+	s.Lock()
+	defer s.Unlock()
+	s.defaults[mimeType] = app
+	return nil
+}
+
+func (s *memoryStore) GetDefault(ctx context.Context, mimeType string) (string, error) {
+	s.RLock()
+	defer s.RUnlock()
+	if app, ok := s.defaults[mimeType]; ok {
+		return app, nil
+	}
+	return "", errtypes.NotFound("default app for mime type " + mimeType)
+}
@@ -20,6 +20,7 @@ package loader
 
 import (
 	// Load core app registry drivers.
+	_ "github.com/cs3org/reva/v3/pkg/app/registry/dynamic"
 	_ "github.com/cs3org/reva/v3/pkg/app/registry/static"
 	// Add your own here.
 )
@@ -0,0 +1,159 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package wopi holds what the wopi app provider and the built-in WOPI host
+// share: the access tokens handed over to the office applications and the
+// urls of the files.
+package wopi
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"time"
+
+	appprovider "github.com/cs3org/go-cs3apis/cs3/app/provider/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/utils/resourceid"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+const audience = "wopi"
+
+// AccessToken is the content of a WOPI access token: it grants the office
+// application access to one file, on behalf of the user who opened it.
+type AccessToken struct {
+	jwt.RegisteredClaims
+	// RevaToken is the token used towards the gateway. It is encrypted in
+	// the access token, which is only signed and is readable by the
+	// applications.
+	RevaToken string `json:"reva_token"`
+	// FileID is the resource the token grants access to.
+	FileID *provider.ResourceId `json:"file_id"`
+	// ViewMode tells whether the user may write to the file.
+	ViewMode appprovider.ViewMode `json:"view_mode"`
+	// UserID identifies the user towards the application, and User is
+	// the user the locks are taken for.
+	UserID   string         `json:"user_id"`
+	UserName string         `json:"user_name"`
+	User     *userpb.UserId `json:"user"`
+	// AppName is the name of the application, that holds the locks.
+	AppName   string `json:"app_name"`
+	FolderURL string `json:"folder_url,omitempty"`
+}
+
+// CanWrite tells whether the token grants write access to the file.
+func (t *AccessToken) CanWrite() bool {
+	return t.ViewMode == appprovider.ViewMode_VIEW_MODE_READ_WRITE
+}
+
+// NewAccessToken signs the access token with the given secret. The token
+// expires at the given time.
+func NewAccessToken(secret string, t *AccessToken, expiration time.Time) (string, error) {
+	claims := *t
+	sealed, err := sealRevaToken(secret, t.RevaToken)
+	if err != nil {
+		return "", err
+	}
+	claims.RevaToken = sealed
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(expiration),
+		Audience:  jwt.ClaimStrings{audience},
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+	tkn, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		return "", errors.Wrap(err, "wopi: error signing access token")
+	}
+	return tkn, nil
+}
+
+// ParseAccessToken verifies the access token against the given secret and
+// returns its content.
+func ParseAccessToken(secret, tkn string) (*AccessToken, error) {
+	var t AccessToken
+	_, err := jwt.ParseWithClaims(tkn, &t, func(token *jwt.Token) (any, error) {
+		return []byte(secret), nil
+	}, jwt.WithAudience(audience), jwt.WithExpirationRequired(), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, errtypes.InvalidCredentials("wopi: invalid access token: " + err.Error())
+	}
+	if t.FileID == nil || t.RevaToken == "" {
+		return nil, errtypes.InvalidCredentials("wopi: incomplete access token")
+	}
+	if t.RevaToken, err = openRevaToken(secret, t.RevaToken); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// revaTokenCipher returns the cipher encrypting the reva tokens, with a key
+// derived from the secret signing the access tokens.
+func revaTokenCipher(secret string) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, []byte(secret), nil, "reva wopi access token", 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealRevaToken(secret, token string) (string, error) {
+	aead, err := revaTokenCipher(secret)
+	if err != nil {
+		return "", errors.Wrap(err, "wopi: error encrypting reva token")
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(token)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errors.Wrap(err, "wopi: error encrypting reva token")
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(token), nil)), nil
+}
+
+func openRevaToken(secret, sealed string) (string, error) {
+	aead, err := revaTokenCipher(secret)
+	if err != nil {
+		return "", errors.Wrap(err, "wopi: error decrypting reva token")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", errtypes.InvalidCredentials("wopi: malformed access token")
+	}
+	token, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errtypes.InvalidCredentials("wopi: malformed access token")
+	}
+	return string(token), nil
+}
+
+// FileURL returns the WOPI url of a file, the WOPISrc of the applications,
+// given the public url of the WOPI host.
+func FileURL(hostURL string, id *provider.ResourceId) string {
+	return strings.TrimSuffix(hostURL, "/") + "/files/" + url.PathEscape(resourceid.OwnCloudResourceIDWrap(id))
+}
@@ -0,0 +1,91 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package wopi
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	appprovider "github.com/cs3org/go-cs3apis/cs3/app/provider/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAccessToken(t *testing.T) {
+	in := &AccessToken{
+		RevaToken: "reva-token",
+		FileID:    &provider.ResourceId{StorageId: "storage", OpaqueId: "file"},
+		ViewMode:  appprovider.ViewMode_VIEW_MODE_READ_WRITE,
+		UserID:    "einstein@cern.ch",
+		AppName:   "Collabora",
+	}
+	tkn, err := NewAccessToken("secret", in, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ParseAccessToken("secret", tkn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.RevaToken != in.RevaToken || out.FileID.OpaqueId != "file" || out.UserID != in.UserID || !out.CanWrite() {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+
+	// the reva token is not readable by the applications
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tkn, claims); err != nil {
+		t.Fatal(err)
+	}
+	if sealed, _ := claims["reva_token"].(string); sealed == "" || strings.Contains(sealed, in.RevaToken) {
+		t.Errorf("reva token not encrypted: %q", sealed)
+	}
+
+	if _, err := ParseAccessToken("other", tkn); err == nil {
+		t.Error("expected an error with the wrong secret")
+	}
+
+	expired, err := NewAccessToken("secret", in, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseAccessToken("secret", expired); err == nil {
+		t.Error("expected an error with an expired token")
+	}
+
+	// tokens for other audiences, as the reva tokens, are refused
+	revaToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{"reva"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseAccessToken("secret", revaToken); err == nil {
+		t.Error("expected an error with a reva token")
+	}
+}
+
+func TestFileURL(t *testing.T) {
+	got := FileURL("https://reva.example.org/wopi/", &provider.ResourceId{StorageId: "eos/home", OpaqueId: "42"})
+	if want := "https://reva.example.org/wopi/files/eos%2Fhome%2142"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package appctx
+
+// ClientIPHeader is the header used to forward the IP address of the client
+// that originated the request. It is only trusted when set by a trusted
+// proxy, see pkg/clientip.
+const ClientIPHeader = "x-client-ip"
+
+// ClientAPIHeader is the header used to forward the HTTP path the client
+// request came through, for the API restrictions of the scopes to be
+// enforced along the gRPC calls. Like the IP address of the client, it is
+// only trusted when set by a trusted proxy.
+const ClientAPIHeader = "x-client-api"
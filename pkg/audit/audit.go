@@ -0,0 +1,149 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package audit defines the audit trail of the security relevant operations,
+// such as sharing, changing permissions and logging in, and the sinks
+// the audit events are written to.
+package audit
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// The actions recorded in the audit trail.
+const (
+	ActionLogin = "login"
+
+	ActionShareCreate         = "share.create"
+	ActionShareUpdate         = "share.update"
+	ActionShareRemove         = "share.remove"
+	ActionReceivedShareUpdate = "share.received.update"
+
+	ActionOCMShareCreate = "ocm.share.create"
+	ActionOCMShareUpdate = "ocm.share.update"
+	ActionOCMShareRemove = "ocm.share.remove"
+
+	ActionLinkCreate   = "link.create"
+	ActionLinkUpdate   = "link.update"
+	ActionLinkRemove   = "link.remove"
+	ActionLinkDownload = "link.download"
+
+	ActionGrantAdd    = "grant.add"
+	ActionGrantUpdate = "grant.update"
+	ActionGrantRemove = "grant.remove"
+	ActionGrantDeny   = "grant.deny"
+
+	ActionLockSet    = "lock.set"
+	ActionLockRemove = "lock.remove"
+
+	ActionDelete         = "file.delete"
+	ActionVersionRestore = "version.restore"
+	ActionTrashRestore   = "trash.restore"
+	ActionTrashPurge     = "trash.purge"
+)
+
+// Outcome is the result of an audited operation.
+type Outcome string
+
+// The outcomes of an audited operation.
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Event is an entry of the audit trail.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Outcome Outcome   `json:"outcome"`
+	// Status describes the reason of a failure.
+	Status string `json:"status,omitempty"`
+
+	// Actor is the id of the user performing the operation, and ActorIdp
+	// and ActorName the identity provider and the username of the user.
+	Actor     string `json:"actor,omitempty"`
+	ActorIdp  string `json:"actor_idp,omitempty"`
+	ActorName string `json:"actor_name,omitempty"`
+
+	// Target identifies the resource or the share the operation is done on.
+	Target string `json:"target,omitempty"`
+
+	ClientIP  string `json:"client_ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	// Method is the gRPC method that performed the operation.
+	Method string `json:"method,omitempty"`
+
+	// Details holds action specific information, for example the grantee
+	// of a share or the token of a public link.
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Sink is where the audit events are written to.
+type Sink interface {
+	// Write records an event.
+	Write(ctx context.Context, e *Event) error
+	// Close releases the resources of the sink.
+	Close() error
+}
+
+// Querier is implemented by the sinks that can be queried for events.
+type Querier interface {
+	// Query returns the events matching the filter, most recent first.
+	Query(ctx context.Context, f *Filter) ([]*Event, error)
+}
+
+// Filter selects the events returned by a query. Its empty fields
+// match any event.
+type Filter struct {
+	// Actor matches either the id or the username of the actor.
+	Actor string
+	// Action matches the action or, given a prefix such as "share",
+	// all the actions in its group such as "share.create".
+	Action  string
+	Target  string
+	Outcome Outcome
+	From    time.Time
+	To      time.Time
+	// Limit is the maximum number of events returned.
+	Limit int
+}
+
+// Match reports whether the event is selected by the filter.
+func (f *Filter) Match(e *Event) bool {
+	if f.Actor != "" && f.Actor != e.Actor && f.Actor != e.ActorName {
+		return false
+	}
+	if f.Action != "" && f.Action != e.Action && !strings.HasPrefix(e.Action, f.Action+".") {
+		return false
+	}
+	if f.Target != "" && f.Target != e.Target {
+		return false
+	}
+	if f.Outcome != "" && f.Outcome != e.Outcome {
+		return false
+	}
+	if !f.From.IsZero() && e.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !e.Time.Before(f.To) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package audit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilterMatch(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	e := &Event{
+		Time:      now,
+		Action:    ActionShareCreate,
+		Outcome:   OutcomeSuccess,
+		Actor:     "4c510ada-c86b-4815-8820-42cdf82c3d51",
+		ActorName: "einstein",
+		Target:    "/home/einstein/docs",
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected bool
+	}{
+		{"empty", Filter{}, true},
+		{"actor id", Filter{Actor: "4c510ada-c86b-4815-8820-42cdf82c3d51"}, true},
+		{"actor name", Filter{Actor: "einstein"}, true},
+		{"other actor", Filter{Actor: "marie"}, false},
+		{"action", Filter{Action: ActionShareCreate}, true},
+		{"action group", Filter{Action: "share"}, true},
+		{"action prefix of another group", Filter{Action: "sha"}, false},
+		{"other action", Filter{Action: ActionLinkCreate}, false},
+		{"target", Filter{Target: "/home/einstein/docs"}, true},
+		{"outcome", Filter{Outcome: OutcomeFailure}, false},
+		{"in range", Filter{From: now, To: now.Add(time.Hour)}, true},
+		{"before range", Filter{From: now.Add(time.Second)}, false},
+		{"after range", Filter{To: now}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(e); got != tt.expected {
+				t.Errorf("got %t, expected %t", got, tt.expected)
+			}
+		})
+	}
+}
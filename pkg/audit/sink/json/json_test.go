@@ -0,0 +1,75 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package json
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/audit"
+)
+
+func TestWriteAndQuery(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "audit.json")
+	s, err := New(ctx, map[string]any{"file": file})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	defer s.Close()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	events := []*audit.Event{
+		{Time: start, Action: audit.ActionLogin, Outcome: audit.OutcomeFailure, ActorName: "einstein"},
+		{Time: start.Add(time.Minute), Action: audit.ActionLogin, Outcome: audit.OutcomeSuccess, ActorName: "einstein"},
+		{Time: start.Add(2 * time.Minute), Action: audit.ActionShareCreate, Outcome: audit.OutcomeSuccess, ActorName: "einstein", Details: map[string]string{"grantee": "marie"}},
+		{Time: start.Add(3 * time.Minute), Action: audit.ActionLogin, Outcome: audit.OutcomeSuccess, ActorName: "marie"},
+	}
+	for _, e := range events {
+		if err := s.Write(ctx, e); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+
+	q := s.(audit.Querier)
+	res, err := q.Query(ctx, &audit.Filter{Actor: "einstein"})
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	if len(res) != 3 || res[0].Action != audit.ActionShareCreate || res[0].Details["grantee"] != "marie" {
+		t.Fatalf("unexpected events %+v", res)
+	}
+
+	res, _ = q.Query(ctx, &audit.Filter{Action: audit.ActionLogin, Limit: 2})
+	if len(res) != 2 || res[0].ActorName != "marie" || !res[1].Time.Equal(start.Add(time.Minute)) {
+		t.Fatalf("expected the two most recent logins, got %+v", res)
+	}
+
+	// a truncated line is skipped
+	f, _ := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600)
+	_, _ = f.WriteString(`{"time":"2024-05-01T12:10:00Z","act`)
+	f.Close()
+	res, err = q.Query(ctx, &audit.Filter{Outcome: audit.OutcomeFailure})
+	if err != nil || len(res) != 1 {
+		t.Fatalf("got %d events and error %v, expected one failure", len(res), err)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package sql implements an audit sink storing the events in a SQL
+// database, which can be queried by the administrators.
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cs3org/reva/v3/cmd/revad/pkg/config"
+	"github.com/cs3org/reva/v3/pkg/audit"
+	"github.com/cs3org/reva/v3/pkg/audit/sink/registry"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registry.Register("sql", New)
+}
+
+type Config struct {
+	config.Database `mapstructure:",squash"`
+}
+
+func (c *Config) ApplyDefaults() {
+	c.Database = sharedconf.GetDBInfo(c.Database)
+}
+
+type sink struct {
+	db *gorm.DB
+}
+
+// AuditEvent is the database model of an audit event.
+type AuditEvent struct {
+	ID        uint      `gorm:"primarykey"`
+	Time      time.Time `gorm:"index:i_time"`
+	Action    string    `gorm:"size:64;index:i_action"`
+	Outcome   string    `gorm:"size:16"`
+	Status    string
+	Actor     string `gorm:"size:255;index:i_actor"`
+	ActorIdp  string `gorm:"size:255"`
+	ActorName string `gorm:"size:255;index:i_actor_name"`
+	Target    string `gorm:"size:1024"`
+	ClientIP  string `gorm:"size:64"`
+	UserAgent string `gorm:"size:255"`
+	Method    string `gorm:"size:255"`
+	Details   string
+}
+
+// New returns an audit sink storing the events in a SQL database.
+func New(ctx context.Context, m map[string]any) (audit.Sink, error) {
+	var c Config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	var db *gorm.DB
+	var err error
+	switch c.Engine {
+	case "sqlite":
+		db, err = gorm.Open(sqlite.Open(c.DBName), &gorm.Config{})
+	default: // default is mysql
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
+		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "audit: failed to connect to the database using engine "+c.Engine)
+	}
+
+	if err := db.AutoMigrate(&AuditEvent{}); err != nil {
+		return nil, errors.Wrap(err, "audit: failed to migrate the AuditEvent schema")
+	}
+
+	return &sink{db: db}, nil
+}
+
+func (s *sink) Write(ctx context.Context, e *audit.Event) error {
+	var details string
+	if len(e.Details) != 0 {
+		b, err := json.Marshal(e.Details)
+		if err != nil {
+			return err
+		}
+		details = string(b)
+	}
+
+	return s.db.WithContext(ctx).Create(&AuditEvent{
+		Time:      e.Time,
+		Action:    e.Action,
+		Outcome:   string(e.Outcome),
+		Status:    e.Status,
+		Actor:     e.Actor,
+		ActorIdp:  e.ActorIdp,
+		ActorName: e.ActorName,
+		Target:    e.Target,
+		ClientIP:  e.ClientIP,
+		UserAgent: e.UserAgent,
+		Method:    e.Method,
+		Details:   details,
+	}).Error
+}
+
+func (s *sink) Query(ctx context.Context, f *audit.Filter) ([]*audit.Event, error) {
+	query := s.db.WithContext(ctx).Model(&AuditEvent{})
+	if f.Actor != "" {
+		query = query.Where("actor = ? OR actor_name = ?", f.Actor, f.Actor)
+	}
+	if f.Action != "" {
+		query = query.Where("action = ? OR action LIKE ?", f.Action, f.Action+".%")
+	}
+	if f.Target != "" {
+		query = query.Where("target = ?", f.Target)
+	}
+	if f.Outcome != "" {
+		query = query.Where("outcome = ?", string(f.Outcome))
+	}
+	if !f.From.IsZero() {
+		query = query.Where("time >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		query = query.Where("time < ?", f.To)
+	}
+	if f.Limit > 0 {
+		query = query.Limit(f.Limit)
+	}
+
+	var rows []*AuditEvent
+	if err := query.Order("time DESC, id DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	events := make([]*audit.Event, 0, len(rows))
+	for _, r := range rows {
+		e := &audit.Event{
+			Time:      r.Time,
+			Action:    r.Action,
+			Outcome:   audit.Outcome(r.Outcome),
+			Status:    r.Status,
+			Actor:     r.Actor,
+			ActorIdp:  r.ActorIdp,
+			ActorName: r.ActorName,
+			Target:    r.Target,
+			ClientIP:  r.ClientIP,
+			UserAgent: r.UserAgent,
+			Method:    r.Method,
+		}
+		if r.Details != "" {
+			if err := json.Unmarshal([]byte(r.Details), &e.Details); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func (s *sink) Close() error {
+	db, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
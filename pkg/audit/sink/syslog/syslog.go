@@ -0,0 +1,83 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package syslog implements an audit sink sending the events
+// as JSON messages to syslog.
+package syslog
+
+import (
+	"context"
+	"encoding/json"
+	"log/syslog"
+
+	"github.com/cs3org/reva/v3/pkg/audit"
+	"github.com/cs3org/reva/v3/pkg/audit/sink/registry"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.Register("syslog", New)
+}
+
+type config struct {
+	// Network and Address of the syslog server. If empty,
+	// the events are sent to the local syslog daemon.
+	Network string `mapstructure:"network"`
+	Address string `mapstructure:"address"`
+	Tag     string `mapstructure:"tag"`
+}
+
+func (c *config) ApplyDefaults() {
+	if c.Tag == "" {
+		c.Tag = "reva-audit"
+	}
+}
+
+type sink struct {
+	w *syslog.Writer
+}
+
+// New returns an audit sink sending the events to syslog.
+func New(ctx context.Context, m map[string]any) (audit.Sink, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	w, err := syslog.Dial(c.Network, c.Address, syslog.LOG_INFO|syslog.LOG_AUTH, c.Tag)
+	if err != nil {
+		return nil, errors.Wrap(err, "audit: error connecting to syslog")
+	}
+	return &sink{w: w}, nil
+}
+
+func (s *sink) Write(_ context.Context, e *audit.Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if e.Outcome == audit.OutcomeFailure {
+		return s.w.Warning(string(b))
+	}
+	return s.w.Info(string(b))
+}
+
+func (s *sink) Close() error {
+	return s.w.Close()
+}
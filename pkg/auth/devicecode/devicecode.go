@@ -0,0 +1,158 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package devicecode implements the OAuth 2.0 device authorization grant
+// (RFC 8628) against an OIDC issuer, for clients that cannot open a browser
+// such as the reva CLI.
+package devicecode
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// expiryDelta is how long before its expiration a token is considered expired,
+// so that it does not expire while a request is in flight.
+const expiryDelta = 30 * time.Second
+
+// Config holds the OIDC client configuration.
+type Config struct {
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	Insecure     bool     `json:"insecure,omitempty"`
+}
+
+// Token holds the tokens obtained from the issuer.
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	IDToken      string    `json:"id_token,omitempty"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+// Credential returns the token to be verified by the oidc auth manager, which
+// is the ID token if the issuer returned one, or the access token otherwise.
+func (t *Token) Credential() string {
+	if t.IDToken != "" {
+		return t.IDToken
+	}
+	return t.AccessToken
+}
+
+// Valid reports whether the token can still be used.
+func (t *Token) Valid() bool {
+	return t.Credential() != "" && (t.Expiry.IsZero() || time.Now().Add(expiryDelta).Before(t.Expiry))
+}
+
+// Prompt shows the user where and with which code the login has to be approved.
+type Prompt func(verificationURI, verificationURIComplete, userCode string)
+
+func (c *Config) oauth2Config(ctx context.Context) (context.Context, *oauth2.Config, error) {
+	if c.Issuer == "" || c.ClientID == "" {
+		return nil, nil, errors.New("devicecode: issuer and client id are required")
+	}
+
+	if c.Insecure {
+		client := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		}
+		ctx = oidc.ClientContext(ctx, client)
+	}
+
+	provider, err := oidc.NewProvider(ctx, c.Issuer)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "devicecode: error discovering the issuer")
+	}
+
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email", oidc.ScopeOfflineAccess}
+	}
+
+	return ctx, &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       scopes,
+	}, nil
+}
+
+// Login starts a device authorization flow, shows the user code through the
+// prompt and waits for the user to approve the login on another device.
+func Login(ctx context.Context, c *Config, prompt Prompt) (*Token, error) {
+	ctx, conf, err := c.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Endpoint.DeviceAuthURL == "" {
+		return nil, errors.New("devicecode: the issuer does not support the device authorization grant")
+	}
+
+	da, err := conf.DeviceAuth(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "devicecode: error requesting a device code")
+	}
+	prompt(da.VerificationURI, da.VerificationURIComplete, da.UserCode)
+
+	tkn, err := conf.DeviceAccessToken(ctx, da)
+	if err != nil {
+		return nil, errors.Wrap(err, "devicecode: error obtaining the token")
+	}
+	return newToken(tkn, ""), nil
+}
+
+// Refresh obtains a new token using the refresh token of t.
+func Refresh(ctx context.Context, c *Config, t *Token) (*Token, error) {
+	if t.RefreshToken == "" {
+		return nil, errors.New("devicecode: no refresh token available")
+	}
+	ctx, conf, err := c.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tkn, err := conf.TokenSource(ctx, &oauth2.Token{RefreshToken: t.RefreshToken}).Token()
+	if err != nil {
+		return nil, errors.Wrap(err, "devicecode: error refreshing the token")
+	}
+	return newToken(tkn, t.RefreshToken), nil
+}
+
+func newToken(tkn *oauth2.Token, refreshToken string) *Token {
+	t := &Token{
+		AccessToken:  tkn.AccessToken,
+		RefreshToken: tkn.RefreshToken,
+		Expiry:       tkn.Expiry,
+	}
+	if t.RefreshToken == "" {
+		// the issuer may not rotate the refresh token
+		t.RefreshToken = refreshToken
+	}
+	if idToken, ok := tkn.Extra("id_token").(string); ok {
+		t.IDToken = idToken
+	}
+	return t
+}
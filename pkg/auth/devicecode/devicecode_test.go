@@ -0,0 +1,172 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package devicecode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockIssuer is a minimal OIDC issuer supporting the device authorization
+// and the refresh token grants.
+type mockIssuer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	polls   int
+	pending int
+}
+
+func newMockIssuer(t *testing.T, pending int) *mockIssuer {
+	m := &mockIssuer{pending: pending}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"issuer":                        m.URL,
+			"authorization_endpoint":        m.URL + "/auth",
+			"token_endpoint":                m.URL + "/token",
+			"device_authorization_endpoint": m.URL + "/device",
+			"jwks_uri":                      m.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("client_id") != "reva-cli" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_client"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"device_code":      "device-code",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": m.URL + "/verify",
+			"expires_in":       60,
+			"interval":         1,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request"})
+			return
+		}
+		switch r.Form.Get("grant_type") {
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			m.mu.Lock()
+			m.polls++
+			polls := m.polls
+			m.mu.Unlock()
+			if polls <= m.pending {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "authorization_pending"})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{
+				"access_token":  "access-1",
+				"token_type":    "Bearer",
+				"refresh_token": "refresh-1",
+				"id_token":      "id-1",
+				"expires_in":    3600,
+			})
+		case "refresh_token":
+			if r.Form.Get("refresh_token") != "refresh-1" {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{
+				"access_token": "access-2",
+				"token_type":   "Bearer",
+				"id_token":     "id-2",
+				"expires_in":   3600,
+			})
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "unsupported_grant_type"})
+		}
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestLoginAndRefresh(t *testing.T) {
+	issuer := newMockIssuer(t, 1)
+	c := &Config{Issuer: issuer.URL, ClientID: "reva-cli"}
+
+	var userCode string
+	tkn, err := Login(context.Background(), c, func(uri, uriComplete, code string) {
+		userCode = code
+	})
+	if err != nil {
+		t.Fatalf("Login returned error: %v", err)
+	}
+	if userCode != "ABCD-EFGH" {
+		t.Errorf("prompt got user code %q, expected ABCD-EFGH", userCode)
+	}
+	if tkn.Credential() != "id-1" || tkn.RefreshToken != "refresh-1" || !tkn.Valid() {
+		t.Fatalf("unexpected token %+v", tkn)
+	}
+
+	refreshed, err := Refresh(context.Background(), c, tkn)
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	if refreshed.Credential() != "id-2" {
+		t.Errorf("got credential %q, expected id-2", refreshed.Credential())
+	}
+	if refreshed.RefreshToken != "refresh-1" {
+		t.Errorf("expected refresh token to be kept when not rotated, got %q", refreshed.RefreshToken)
+	}
+}
+
+func TestLoginWrongClient(t *testing.T) {
+	issuer := newMockIssuer(t, 0)
+	c := &Config{Issuer: issuer.URL, ClientID: "unknown"}
+
+	if _, err := Login(context.Background(), c, func(string, string, string) {}); err == nil {
+		t.Fatal("expected Login to fail for an unknown client")
+	}
+}
+
+func TestTokenValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    Token
+		expected bool
+	}{
+		{"empty", Token{}, false},
+		{"no expiry", Token{AccessToken: "a"}, true},
+		{"expired", Token{AccessToken: "a", Expiry: time.Now().Add(-time.Minute)}, false},
+		{"about to expire", Token{AccessToken: "a", Expiry: time.Now().Add(time.Second)}, false},
+		{"valid", Token{IDToken: "i", Expiry: time.Now().Add(time.Hour)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.Valid(); got != tt.expected {
+				t.Errorf("got %t, expected %t", got, tt.expected)
+			}
+		})
+	}
+}
@@ -30,5 +30,6 @@ import (
 	_ "github.com/cs3org/reva/v3/pkg/auth/manager/ocmshares"
 	_ "github.com/cs3org/reva/v3/pkg/auth/manager/oidc"
 	_ "github.com/cs3org/reva/v3/pkg/auth/manager/publicshares"
+	_ "github.com/cs3org/reva/v3/pkg/auth/manager/totp"
 	// Add your own here.
 )
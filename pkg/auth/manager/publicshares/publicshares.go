@@ -34,6 +34,7 @@ import (
 	"github.com/cs3org/reva/v3/pkg/auth/manager/registry"
 	"github.com/cs3org/reva/v3/pkg/auth/scope"
 	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/publicshare"
 	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/v3/pkg/sharedconf"
 	"github.com/cs3org/reva/v3/pkg/utils/cfg"
@@ -131,6 +132,14 @@ func (m *manager) Authenticate(ctx context.Context, token, secret string) (*user
 	}
 
 	share := publicShareResponse.GetShare()
+	restrictions, err := publicshare.GetRestrictions(share.GetOpaque())
+	if err != nil {
+		return nil, nil, err
+	}
+	if !restrictions.AllowsClient(ctx) {
+		return nil, nil, errtypes.PermissionDenied("publicshares: the link cannot be used from this network")
+	}
+
 	role := authpb.Role_ROLE_VIEWER
 	roleStr := "viewer"
 	if share.Permissions.Permissions.InitiateFileUpload && !share.Permissions.Permissions.InitiateFileDownload {
@@ -0,0 +1,172 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package totp
+
+import (
+	"context"
+	"fmt"
+
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/auth"
+	"github.com/cs3org/reva/v3/pkg/auth/manager/registry"
+	"github.com/cs3org/reva/v3/pkg/auth/totp"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	prefregistry "github.com/cs3org/reva/v3/pkg/preferences/registry"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.Register("totp", New)
+}
+
+type config struct {
+	// AuthManager is the auth manager verifying the first factor, e.g. ldap or json.
+	AuthManager  string                    `mapstructure:"auth_manager"`
+	AuthManagers map[string]map[string]any `mapstructure:"auth_managers"`
+	// Preferences is the driver used to read the TOTP enrollment of the users.
+	// It must point to the same storage used by the preferences service.
+	Preferences        string                    `mapstructure:"preferences"`
+	PreferencesDrivers map[string]map[string]any `mapstructure:"preferences_drivers"`
+	// EncryptionKey encrypts the shared secrets of the users. It must match
+	// the one of the totp HTTP service.
+	EncryptionKey string `mapstructure:"encryption_key"`
+	// Skew is the number of periods before and after the current one
+	// for which a code is still accepted.
+	Skew int `mapstructure:"skew"`
+	// AppPasswords allows logging in with an application password without a
+	// second factor, so that sync clients keep working for enrolled users.
+	AppPasswords bool   `mapstructure:"app_passwords"`
+	GatewaySvc   string `mapstructure:"gatewaysvc"`
+}
+
+func (c *config) ApplyDefaults() {
+	if c.Preferences == "" {
+		c.Preferences = "sql"
+	}
+	if c.Skew == 0 {
+		c.Skew = 1
+	}
+	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
+}
+
+type manager struct {
+	c       *config
+	authmgr auth.Manager
+	appauth auth.Manager
+	store   *totp.Store
+}
+
+// New returns an auth manager requiring a time-based one-time password, on top
+// of the credentials verified by the wrapped auth manager, for the users that
+// enrolled one.
+func New(ctx context.Context, m map[string]any) (auth.Manager, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, errors.Wrap(err, "totp: error decoding config")
+	}
+
+	if c.AuthManager == "" || c.AuthManager == "totp" {
+		return nil, errtypes.InternalError("totp: a valid auth_manager must be configured")
+	}
+	f, ok := registry.NewFuncs[c.AuthManager]
+	if !ok {
+		return nil, errtypes.NotFound(fmt.Sprintf("totp: driver %s not found for auth manager", c.AuthManager))
+	}
+	authmgr, err := f(ctx, c.AuthManagers[c.AuthManager])
+	if err != nil {
+		return nil, err
+	}
+
+	p, ok := prefregistry.NewFuncs[c.Preferences]
+	if !ok {
+		return nil, errtypes.NotFound(fmt.Sprintf("totp: driver %s not found for preferences", c.Preferences))
+	}
+	prefs, err := p(ctx, c.PreferencesDrivers[c.Preferences])
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := totp.NewStore(prefs, c.EncryptionKey, c.Skew)
+	if err != nil {
+		return nil, err
+	}
+
+	mgr := &manager{
+		c:       &c,
+		authmgr: authmgr,
+		store:   store,
+	}
+
+	if c.AppPasswords {
+		f, ok := registry.NewFuncs["appauth"]
+		if !ok {
+			return nil, errtypes.NotFound("totp: appauth auth manager not found")
+		}
+		mgr.appauth, err = f(ctx, map[string]any{"gatewaysvc": c.GatewaySvc})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return mgr, nil
+}
+
+func (m *manager) Authenticate(ctx context.Context, clientID, clientSecret string) (*user.User, map[string]*authpb.Scope, error) {
+	log := appctx.GetLogger(ctx)
+	secret, code := totp.SplitSecret(clientSecret)
+
+	u, scopes, err := m.authmgr.Authenticate(ctx, clientID, secret)
+	if err != nil {
+		if m.appauth == nil {
+			return nil, nil, err
+		}
+		// app passwords are exempted from the second factor
+		appUser, appScopes, appErr := m.appauth.Authenticate(ctx, clientID, secret)
+		if appErr != nil {
+			return nil, nil, err
+		}
+		log.Debug().Str("user", clientID).Msg("totp: authenticated with app password, skipping second factor")
+		return appUser, appScopes, nil
+	}
+
+	userCtx := appctx.ContextSetUser(ctx, u)
+	enrolled, err := m.store.Enrolled(userCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !enrolled {
+		return u, scopes, nil
+	}
+
+	if code == "" {
+		return nil, nil, errtypes.InvalidCredentials("totp: one-time code required for " + clientID)
+	}
+
+	if err := m.store.Verify(userCtx, code); err != nil {
+		if _, ok := err.(errtypes.InvalidCredentials); ok {
+			return nil, nil, errtypes.InvalidCredentials("totp: invalid one-time code for " + clientID)
+		}
+		return nil, nil, err
+	}
+	return u, scopes, nil
+}
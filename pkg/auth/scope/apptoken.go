@@ -0,0 +1,273 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package scope
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	appprovider "github.com/cs3org/go-cs3apis/cs3/app/provider/v1beta1"
+	applications "github.com/cs3org/go-cs3apis/cs3/auth/applications/v1beta1"
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	registry "github.com/cs3org/go-cs3apis/cs3/storage/registry/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/clientip"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/utils"
+	"github.com/rs/zerolog"
+)
+
+// AppToken holds the restrictions of an application password scope.
+type AppToken struct {
+	// Path restricts the access to the given subtree. If empty, the whole
+	// account is accessible.
+	Path string `json:"path,omitempty"`
+	// APIs restricts the HTTP APIs the token can be used with, see AppTokenAPIs.
+	// If empty, all the APIs are allowed.
+	APIs []string `json:"apis,omitempty"`
+	// AllowedIPs restricts the clients using the token to the given
+	// networks, in CIDR notation. If empty, all the clients are allowed.
+	AllowedIPs []string `json:"allowed_ips,omitempty"`
+}
+
+// AppTokenAPIs maps the APIs an app token can be restricted to,
+// to the HTTP path prefixes serving them.
+var AppTokenAPIs = map[string][]string{
+	"webdav": {"/remote.php/webdav", "/remote.php/dav", "/webdav", "/dav"},
+	"ocs":    {"/ocs"},
+	"graph":  {"/graph"},
+}
+
+// appTokenDataPaths are always allowed, as uploads and downloads
+// initiated through any API go through the data gateway.
+var appTokenDataPaths = []string{"/data", "/dataprovider"}
+
+func apptokenScope(ctx context.Context, scope *authpb.Scope, resource any, logger *zerolog.Logger) (bool, error) {
+	var t AppToken
+	if err := json.Unmarshal(scope.Resource.Value, &t); err != nil {
+		return false, err
+	}
+
+	if !t.allowsClient(ctx) {
+		logger.Debug().Str("scope", "apptokenScope").Msg("client ip not allowed")
+		return false, nil
+	}
+
+	if v, ok := resource.(string); ok {
+		return t.allowsAPI(v), nil
+	}
+	// The gRPC calls must come through one of the allowed APIs, as
+	// forwarded by the HTTP services, and not from a direct gRPC client.
+	if !t.allowsAPI(clientip.APIFromContext(ctx)) {
+		logger.Debug().Str("scope", "apptokenScope").Msg("api not allowed")
+		return false, nil
+	}
+
+	switch v := resource.(type) {
+	// An app token must not be able to mint or manage app tokens, which
+	// could escape its restrictions.
+	case *applications.GenerateAppPasswordRequest, *applications.InvalidateAppPasswordRequest, *applications.ListAppPasswordsRequest:
+		return false, nil
+
+	// Viewer role
+	case *registry.GetStorageProvidersRequest:
+		return t.allowsRef(v.GetRef()), nil
+	case *provider.StatRequest:
+		return t.allowsRef(v.GetRef()), nil
+	case *provider.ListContainerRequest:
+		return t.allowsRef(v.GetRef()), nil
+	case *provider.InitiateFileDownloadRequest:
+		return t.allowsRef(v.GetRef()), nil
+	case *provider.ListFileVersionsRequest:
+		return t.allowsRef(v.GetRef()), nil
+	case *provider.GetLockRequest:
+		return t.allowsRef(v.GetRef()), nil
+	case *appprovider.OpenInAppRequest:
+		return t.allowsRef(&provider.Reference{ResourceId: v.ResourceInfo.Id}), nil
+	case *gateway.OpenInAppRequest:
+		return t.allowsRef(v.GetRef()), nil
+
+	// Editor role
+	case *provider.CreateContainerRequest:
+		return hasRoleEditor(scope) && t.allowsRef(v.GetRef()), nil
+	case *provider.TouchFileRequest:
+		return hasRoleEditor(scope) && t.allowsRef(v.GetRef()), nil
+	case *provider.DeleteRequest:
+		return hasRoleEditor(scope) && t.allowsRef(v.GetRef()), nil
+	case *provider.MoveRequest:
+		return hasRoleEditor(scope) && t.allowsRef(v.GetSource()) && t.allowsRef(v.GetDestination()), nil
+	case *provider.InitiateFileUploadRequest:
+		return hasRoleEditor(scope) && t.allowsRef(v.GetRef()), nil
+	case *provider.SetArbitraryMetadataRequest:
+		return hasRoleEditor(scope) && t.allowsRef(v.GetRef()), nil
+	case *provider.UnsetArbitraryMetadataRequest:
+		return hasRoleEditor(scope) && t.allowsRef(v.GetRef()), nil
+	case *provider.RestoreFileVersionRequest:
+		return hasRoleEditor(scope) && t.allowsRef(v.GetRef()), nil
+	case *provider.SetLockRequest:
+		return hasRoleEditor(scope) && t.allowsRef(v.GetRef()), nil
+	case *provider.RefreshLockRequest:
+		return hasRoleEditor(scope) && t.allowsRef(v.GetRef()), nil
+	case *provider.UnlockRequest:
+		return hasRoleEditor(scope) && t.allowsRef(v.GetRef()), nil
+	}
+
+	// Requests not bound to a storage resource (shares, preferences, ...)
+	// could leak or modify data outside of the subtree.
+	if t.Path != "" {
+		return false, nil
+	}
+	return hasRoleEditor(scope) || isReadRequest(resource), nil
+}
+
+// AppTokenAllowsPath reports whether the app token scope allows the request
+// on the resource at the given absolute path, resolved by the caller from a
+// reference. Writing requires the scope of the subtree to be read-write.
+func AppTokenAllowsPath(ctx context.Context, scope *authpb.Scope, req any, p string) (bool, error) {
+	var t AppToken
+	if err := json.Unmarshal(scope.Resource.Value, &t); err != nil {
+		return false, err
+	}
+	if isWriteRequest(req) && !hasRoleEditor(scope) {
+		return false, nil
+	}
+	return t.allowsClient(ctx) && t.allowsAPI(clientip.APIFromContext(ctx)) && t.allowsPath(p), nil
+}
+
+func (t *AppToken) allowsClient(ctx context.Context) bool {
+	if len(t.AllowedIPs) == 0 {
+		return true
+	}
+	// The client IP is set by the HTTP auth middleware and forwarded along
+	// the gRPC calls by the trusted reva services, or else is the address of
+	// the peer. Without it the restriction cannot be enforced.
+	clientIP := clientip.FromContext(ctx)
+	if clientIP == "" {
+		return false
+	}
+	return utils.IPInNetworks(clientIP, t.AllowedIPs)
+}
+
+func (t *AppToken) allowsAPI(p string) bool {
+	if len(t.APIs) == 0 {
+		return true
+	}
+	for _, prefix := range appTokenDataPaths {
+		if hasPathPrefix(p, prefix) {
+			return true
+		}
+	}
+	for _, api := range t.APIs {
+		for _, prefix := range AppTokenAPIs[api] {
+			if hasPathPrefix(p, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t *AppToken) allowsRef(ref *provider.Reference) bool {
+	if t.Path == "" {
+		return true
+	}
+	if ref.GetResourceId() != nil {
+		// id based references need to be resolved into a path
+		// by the auth interceptor before being checked
+		return false
+	}
+	return t.allowsPath(ref.GetPath())
+}
+
+func (t *AppToken) allowsPath(p string) bool {
+	if t.Path == "" {
+		return true
+	}
+	return hasPathPrefix(path.Clean(p), path.Clean(t.Path))
+}
+
+// hasPathPrefix reports whether p is prefix or lies below it, matching whole
+// path segments so that /dav does not match /davx.
+func hasPathPrefix(p, prefix string) bool {
+	return p == prefix || strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// isWriteRequest reports whether a request modifies a storage resource.
+func isWriteRequest(req any) bool {
+	switch req.(type) {
+	case *provider.CreateContainerRequest, *provider.TouchFileRequest, *provider.DeleteRequest,
+		*provider.MoveRequest, *provider.InitiateFileUploadRequest, *provider.SetArbitraryMetadataRequest,
+		*provider.UnsetArbitraryMetadataRequest, *provider.RestoreFileVersionRequest, *provider.SetLockRequest,
+		*provider.RefreshLockRequest, *provider.UnlockRequest, *provider.CreateSymlinkRequest,
+		*provider.CreateReferenceRequest:
+		return true
+	}
+	return false
+}
+
+// isReadRequest reports whether a request does not modify any state,
+// following the naming convention of the CS3 APIs.
+func isReadRequest(req any) bool {
+	name := fmt.Sprintf("%T", req)
+	name = name[strings.LastIndex(name, ".")+1:]
+	for _, prefix := range []string{"Get", "List", "Find", "Stat", "WhoAmI", "InitiateFileDownload"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddAppTokenScope adds the scope restricting an application password to the
+// given subtree, APIs and client networks. The role is either ROLE_VIEWER for
+// read-only access or ROLE_EDITOR for read-write access.
+func AddAppTokenScope(t *AppToken, role authpb.Role, scopes map[string]*authpb.Scope) (map[string]*authpb.Scope, error) {
+	for _, api := range t.APIs {
+		if _, ok := AppTokenAPIs[api]; !ok {
+			return nil, errtypes.BadRequest("unknown api " + api)
+		}
+	}
+	for _, cidr := range t.AllowedIPs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+			return nil, errtypes.BadRequest("invalid network " + cidr)
+		}
+	}
+
+	val, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	if scopes == nil {
+		scopes = make(map[string]*authpb.Scope)
+	}
+	scopes["apptoken:"+t.Path] = &authpb.Scope{
+		Resource: &types.OpaqueEntry{
+			Decoder: "json",
+			Value:   val,
+		},
+		Role: role,
+	}
+	return scopes, nil
+}
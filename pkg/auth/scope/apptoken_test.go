@@ -0,0 +1,227 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package scope
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	applications "github.com/cs3org/go-cs3apis/cs3/auth/applications/v1beta1"
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func verifyAppToken(ctx context.Context, t *testing.T, token *AppToken, role authpb.Role, resource any) bool {
+	t.Helper()
+	scopes, err := AddAppTokenScope(token, role, nil)
+	if err != nil {
+		t.Fatalf("AddAppTokenScope returned error: %v", err)
+	}
+	log := zerolog.Nop()
+	ok, err := apptokenScope(ctx, scopes["apptoken:"+token.Path], resource, &log)
+	if err != nil {
+		t.Fatalf("apptokenScope returned error: %v", err)
+	}
+	return ok
+}
+
+func TestAppTokenSubtree(t *testing.T) {
+	token := &AppToken{Path: "/home/einstein/photos"}
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		role     authpb.Role
+		req      any
+		expected bool
+	}{
+		{"stat root", authpb.Role_ROLE_VIEWER, &provider.StatRequest{Ref: &provider.Reference{Path: "/home/einstein/photos"}}, true},
+		{"stat child", authpb.Role_ROLE_VIEWER, &provider.StatRequest{Ref: &provider.Reference{Path: "/home/einstein/photos/a.jpg"}}, true},
+		{"stat sibling with same prefix", authpb.Role_ROLE_VIEWER, &provider.StatRequest{Ref: &provider.Reference{Path: "/home/einstein/photos2"}}, false},
+		{"stat outside", authpb.Role_ROLE_VIEWER, &provider.StatRequest{Ref: &provider.Reference{Path: "/home/einstein"}}, false},
+		{"stat by id", authpb.Role_ROLE_VIEWER, &provider.StatRequest{Ref: &provider.Reference{ResourceId: &provider.ResourceId{OpaqueId: "x"}}}, false},
+		{"delete read-only", authpb.Role_ROLE_VIEWER, &provider.DeleteRequest{Ref: &provider.Reference{Path: "/home/einstein/photos/a.jpg"}}, false},
+		{"delete read-write", authpb.Role_ROLE_EDITOR, &provider.DeleteRequest{Ref: &provider.Reference{Path: "/home/einstein/photos/a.jpg"}}, true},
+		{"list shares", authpb.Role_ROLE_EDITOR, &collaboration.ListSharesRequest{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyAppToken(ctx, t, token, tt.role, tt.req); got != tt.expected {
+				t.Errorf("got %t, expected %t", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAppTokenWholeAccount(t *testing.T) {
+	token := &AppToken{}
+	ctx := context.Background()
+
+	if !verifyAppToken(ctx, t, token, authpb.Role_ROLE_VIEWER, &collaboration.ListSharesRequest{}) {
+		t.Error("expected read request to be allowed to read-only token")
+	}
+	if verifyAppToken(ctx, t, token, authpb.Role_ROLE_VIEWER, &collaboration.CreateShareRequest{}) {
+		t.Error("expected write request to be denied to read-only token")
+	}
+	if !verifyAppToken(ctx, t, token, authpb.Role_ROLE_EDITOR, &collaboration.CreateShareRequest{}) {
+		t.Error("expected write request to be allowed to read-write token")
+	}
+}
+
+func TestAppTokenAPIs(t *testing.T) {
+	token := &AppToken{APIs: []string{"webdav"}}
+	ctx := context.Background()
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/remote.php/dav/files/einstein/a.txt", true},
+		{"/remote.php/webdav/a.txt", true},
+		{"/data/sometoken", true},
+		{"/ocs/v2.php/apps/files_sharing/api/v1/shares", false},
+		{"/graph/v1.0/me/drives", false},
+		{"/davx/files", false},
+		{"/database", false},
+	}
+
+	for _, tt := range tests {
+		if got := verifyAppToken(ctx, t, token, authpb.Role_ROLE_EDITOR, tt.path); got != tt.expected {
+			t.Errorf("path %s: got %t, expected %t", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestAppTokenAPIsOverGRPC(t *testing.T) {
+	token := &AppToken{APIs: []string{"webdav"}}
+	req := &provider.StatRequest{Ref: &provider.Reference{Path: "/home/einstein"}}
+	trusted := &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1234}}
+	untrusted := &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 1234}}
+	forward := func(api string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{appctx.ClientAPIHeader: api}))
+	}
+
+	if !verifyAppToken(peer.NewContext(forward("/remote.php/dav/files/einstein"), trusted), t, token, authpb.Role_ROLE_VIEWER, req) {
+		t.Error("expected a call through an allowed api to be allowed")
+	}
+	if verifyAppToken(peer.NewContext(forward("/ocs/v1.php/cloud/user"), trusted), t, token, authpb.Role_ROLE_VIEWER, req) {
+		t.Error("expected a call through another api to be denied")
+	}
+	if verifyAppToken(peer.NewContext(context.Background(), untrusted), t, token, authpb.Role_ROLE_VIEWER, req) {
+		t.Error("expected a direct gRPC call to be denied")
+	}
+	if verifyAppToken(peer.NewContext(forward("/remote.php/dav/files/einstein"), untrusted), t, token, authpb.Role_ROLE_VIEWER, req) {
+		t.Error("expected the api forwarded by an untrusted peer to be ignored")
+	}
+}
+
+func TestAppTokenCannotManageAppTokens(t *testing.T) {
+	ctx := context.Background()
+	for _, req := range []any{
+		&applications.GenerateAppPasswordRequest{},
+		&applications.InvalidateAppPasswordRequest{},
+		&applications.ListAppPasswordsRequest{},
+	} {
+		if verifyAppToken(ctx, t, &AppToken{}, authpb.Role_ROLE_EDITOR, req) {
+			t.Errorf("expected %T to be denied", req)
+		}
+	}
+}
+
+func TestAppTokenAllowsPathRole(t *testing.T) {
+	scopes, err := AddAppTokenScope(&AppToken{Path: "/home/einstein/photos"}, authpb.Role_ROLE_VIEWER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := scopes["apptoken:/home/einstein/photos"]
+	ctx := context.Background()
+
+	if ok, _ := AppTokenAllowsPath(ctx, s, &provider.StatRequest{}, "/home/einstein/photos/a.jpg"); !ok {
+		t.Error("expected a read in the subtree to be allowed")
+	}
+	if ok, _ := AppTokenAllowsPath(ctx, s, &provider.DeleteRequest{}, "/home/einstein/photos/a.jpg"); ok {
+		t.Error("expected a write in a read-only subtree to be denied")
+	}
+}
+
+func TestAppTokenAllowedIPs(t *testing.T) {
+	token := &AppToken{AllowedIPs: []string{"192.0.2.0/24", "2001:db8::1"}}
+	req := &provider.StatRequest{Ref: &provider.Reference{Path: "/home/einstein"}}
+
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"", false},
+		{"192.0.2.10", true},
+		{"198.51.100.1", false},
+		{"2001:db8::1", true},
+		{"not-an-ip", false},
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.ip != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.New(map[string]string{appctx.ClientIPHeader: tt.ip}))
+		}
+		if got := verifyAppToken(ctx, t, token, authpb.Role_ROLE_VIEWER, req); got != tt.expected {
+			t.Errorf("ip %q: got %t, expected %t", tt.ip, got, tt.expected)
+		}
+	}
+}
+
+func TestAppTokenAllowedIPsFromPeer(t *testing.T) {
+	token := &AppToken{AllowedIPs: []string{"192.0.2.0/24"}}
+	req := &provider.StatRequest{Ref: &provider.Reference{Path: "/home/einstein"}}
+	spoofed := metadata.New(map[string]string{appctx.ClientIPHeader: "192.0.2.10"})
+
+	// a direct gRPC caller cannot claim an allowed address
+	ctx := peer.NewContext(metadata.NewIncomingContext(context.Background(), spoofed), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 1234}})
+	if verifyAppToken(ctx, t, token, authpb.Role_ROLE_VIEWER, req) {
+		t.Error("expected the address forwarded by an untrusted peer to be ignored")
+	}
+
+	// the address forwarded by a trusted reva service is used
+	ctx = peer.NewContext(metadata.NewIncomingContext(context.Background(), spoofed), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1234}})
+	if !verifyAppToken(ctx, t, token, authpb.Role_ROLE_VIEWER, req) {
+		t.Error("expected the address forwarded by a trusted peer to be allowed")
+	}
+
+	// without forwarded address, the peer is the client
+	ctx = peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.20"), Port: 1234}})
+	if !verifyAppToken(ctx, t, token, authpb.Role_ROLE_VIEWER, req) {
+		t.Error("expected the address of the peer to be allowed")
+	}
+}
+
+func TestAddAppTokenScopeValidation(t *testing.T) {
+	if _, err := AddAppTokenScope(&AppToken{APIs: []string{"ftp"}}, authpb.Role_ROLE_VIEWER, nil); err == nil {
+		t.Error("expected unknown api to be rejected")
+	}
+	if _, err := AddAppTokenScope(&AppToken{AllowedIPs: []string{"300.0.0.0/8"}}, authpb.Role_ROLE_VIEWER, nil); err == nil {
+		t.Error("expected invalid network to be rejected")
+	}
+}
@@ -38,6 +38,7 @@ var supportedScopes = map[string]Verifier{
 	"receivedshare": receivedShareScope,
 	"lightweight":   lightweightAccountScope,
 	"ocmshare":      ocmShareScope,
+	"apptoken":      apptokenScope,
 }
 
 // VerifyScope is the function to be called when dismantling tokens to check if
@@ -19,6 +19,7 @@
 package scope
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -56,6 +57,23 @@ func FormatScope(scopeType string, scope *authpb.Scope) (string, error) {
 			return "", err
 		}
 		return fmt.Sprintf("path:\"%s\" %s", resInfo.Path, scope.Role.String()), nil
+	case strings.HasPrefix(scopeType, "apptoken"):
+		var t AppToken
+		if err := json.Unmarshal(scope.Resource.Value, &t); err != nil {
+			return "", err
+		}
+		p := t.Path
+		if p == "" {
+			p = "/"
+		}
+		s := fmt.Sprintf("subtree:\"%s\"", p)
+		if len(t.APIs) != 0 {
+			s += fmt.Sprintf(" apis:\"%s\"", strings.Join(t.APIs, ","))
+		}
+		if len(t.AllowedIPs) != 0 {
+			s += fmt.Sprintf(" ips:\"%s\"", strings.Join(t.AllowedIPs, ","))
+		}
+		return fmt.Sprintf("%s %s", s, scope.Role.String()), nil
 	default:
 		return "", errtypes.NotSupported("scope not yet supported")
 	}
@@ -0,0 +1,270 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package totp
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/preferences"
+	"github.com/pkg/errors"
+)
+
+// RecoveryCodes is the number of recovery codes handed out to a user.
+const RecoveryCodes = 10
+
+// Store keeps the TOTP enrollment of the users in a preferences driver.
+// The shared secrets are encrypted, the recovery codes hashed, and the time
+// step of the last accepted code is remembered to reject replayed codes.
+type Store struct {
+	prefs preferences.Manager
+	aead  cipher.AEAD
+	skew  int
+	now   func() time.Time
+}
+
+// NewStore returns a Store on top of the given preferences driver, encrypting
+// the shared secrets with a key derived from encryptionKey.
+func NewStore(prefs preferences.Manager, encryptionKey string, skew int) (*Store, error) {
+	if encryptionKey == "" {
+		return nil, errtypes.InternalError("totp: an encryption key must be configured")
+	}
+	key := sha256.Sum256([]byte(encryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{prefs: prefs, aead: aead, skew: skew, now: time.Now}, nil
+}
+
+func (s *Store) seal(secret string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(s.aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+func (s *Store) open(sealed string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(b) < s.aead.NonceSize() {
+		return "", errtypes.InternalError("totp: malformed secret")
+	}
+	n := s.aead.NonceSize()
+	secret, err := s.aead.Open(nil, b[:n], b[n:], nil)
+	if err != nil {
+		return "", errtypes.InternalError("totp: the secret cannot be decrypted")
+	}
+	return string(secret), nil
+}
+
+func (s *Store) get(ctx context.Context) (map[string]string, error) {
+	v, err := s.prefs.GetKeys(ctx, []string{SecretKey, PendingSecretKey, RecoveryCodesKey, LastStepKey}, Namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "totp: error reading enrollment")
+	}
+	return v, nil
+}
+
+// Enrolled reports whether the user in the context enrolled a second factor.
+func (s *Store) Enrolled(ctx context.Context) (bool, error) {
+	v, err := s.get(ctx)
+	if err != nil {
+		return false, err
+	}
+	return v[SecretKey] != "", nil
+}
+
+// Verify checks a one-time code, or else a recovery code, for the user in the
+// context. An accepted code cannot be used again.
+func (s *Store) Verify(ctx context.Context, code string) error {
+	v, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+	if v[SecretKey] == "" {
+		return errtypes.NotFound("totp: not enrolled")
+	}
+	if err := s.verifyCode(ctx, v, code); err == nil {
+		return nil
+	} else if _, ok := err.(errtypes.InvalidCredentials); !ok {
+		return err
+	}
+
+	remaining, ok := UseRecoveryCode(v[RecoveryCodesKey], code)
+	if !ok {
+		return errtypes.InvalidCredentials("totp: invalid one-time code")
+	}
+	// a concurrent request that used a code first changed the codes
+	swapped, err := s.prefs.CompareAndSwapKey(ctx, RecoveryCodesKey, Namespace, v[RecoveryCodesKey], remaining)
+	if err != nil {
+		return errors.Wrap(err, "totp: error invalidating used recovery code")
+	}
+	if !swapped {
+		return errtypes.InvalidCredentials("totp: invalid one-time code")
+	}
+	return nil
+}
+
+// verifyCode checks a one-time code against the enrolled secret and records
+// its time step. The step is only recorded if no other code was accepted
+// since it was read, so that concurrent requests cannot use the same code.
+func (s *Store) verifyCode(ctx context.Context, v map[string]string, code string) error {
+	secret, err := s.open(v[SecretKey])
+	if err != nil {
+		return err
+	}
+	last := int64(-1)
+	if v[LastStepKey] != "" {
+		if last, err = strconv.ParseInt(v[LastStepKey], 10, 64); err != nil {
+			return errtypes.InternalError("totp: malformed last step")
+		}
+	}
+	step, ok := ValidateStep(secret, code, s.now(), s.skew, last)
+	if !ok {
+		return errtypes.InvalidCredentials("totp: invalid one-time code")
+	}
+	swapped, err := s.prefs.CompareAndSwapKey(ctx, LastStepKey, Namespace, v[LastStepKey], strconv.FormatInt(step, 10))
+	if err != nil {
+		return errors.Wrap(err, "totp: error recording accepted code")
+	}
+	if !swapped {
+		return errtypes.InvalidCredentials("totp: invalid one-time code")
+	}
+	return nil
+}
+
+// StartEnrollment generates a new shared secret for the user in the context,
+// which only becomes effective once confirmed with ConfirmEnrollment. A user
+// already enrolled must disable the second factor first.
+func (s *Store) StartEnrollment(ctx context.Context) (string, error) {
+	v, err := s.get(ctx)
+	if err != nil {
+		return "", err
+	}
+	if v[SecretKey] != "" {
+		return "", errtypes.AlreadyExists("totp: already enrolled")
+	}
+	secret, err := GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := s.seal(secret)
+	if err != nil {
+		return "", err
+	}
+	if err := s.prefs.SetKey(ctx, PendingSecretKey, Namespace, sealed); err != nil {
+		return "", errors.Wrap(err, "totp: error storing pending secret")
+	}
+	return secret, nil
+}
+
+// ConfirmEnrollment enables the second factor started with StartEnrollment
+// once the user proves, with a code, to hold the secret. It returns the
+// recovery codes of the user.
+func (s *Store) ConfirmEnrollment(ctx context.Context, code string) ([]string, error) {
+	v, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if v[SecretKey] != "" {
+		return nil, errtypes.AlreadyExists("totp: already enrolled")
+	}
+	if v[PendingSecretKey] == "" {
+		return nil, errtypes.NotFound("totp: no pending enrollment")
+	}
+	secret, err := s.open(v[PendingSecretKey])
+	if err != nil {
+		return nil, err
+	}
+	step, ok := ValidateStep(secret, code, s.now(), s.skew, -1)
+	if !ok {
+		return nil, errtypes.InvalidCredentials("totp: invalid one-time code")
+	}
+	codes, err := GenerateRecoveryCodes(RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	err = s.prefs.SetKeys(ctx, map[string]string{
+		SecretKey:        v[PendingSecretKey],
+		PendingSecretKey: "",
+		RecoveryCodesKey: EncodeRecoveryCodes(codes),
+		LastStepKey:      strconv.FormatInt(step, 10),
+	}, Namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "totp: error storing enrollment")
+	}
+	return codes, nil
+}
+
+// Disable removes the second factor of the user in the context, which must
+// present a valid current one-time code.
+func (s *Store) Disable(ctx context.Context, code string) error {
+	v, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+	if v[SecretKey] == "" {
+		return errtypes.NotFound("totp: not enrolled")
+	}
+	if err := s.verifyCode(ctx, v, code); err != nil {
+		return err
+	}
+	err = s.prefs.SetKeys(ctx, map[string]string{
+		SecretKey:        "",
+		PendingSecretKey: "",
+		RecoveryCodesKey: "",
+		LastStepKey:      "",
+	}, Namespace)
+	return errors.Wrap(err, "totp: error removing enrollment")
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user in the
+// context, which must present a valid current one-time code.
+func (s *Store) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	v, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if v[SecretKey] == "" {
+		return nil, errtypes.NotFound("totp: not enrolled")
+	}
+	if err := s.verifyCode(ctx, v, code); err != nil {
+		return nil, err
+	}
+	codes, err := GenerateRecoveryCodes(RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.prefs.SetKey(ctx, RecoveryCodesKey, Namespace, EncodeRecoveryCodes(codes)); err != nil {
+		return nil, errors.Wrap(err, "totp: error storing recovery codes")
+	}
+	return codes, nil
+}
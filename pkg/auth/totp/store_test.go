@@ -0,0 +1,187 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package totp
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/preferences/memory"
+)
+
+func newTestStore(t *testing.T, now *time.Time) (*Store, context.Context) {
+	prefs, err := memory.New(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStore(prefs, "encryption key", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return *now }
+	ctx := appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "einstein"}})
+	return s, ctx
+}
+
+func enroll(t *testing.T, s *Store, ctx context.Context, now time.Time) (string, []string) {
+	secret, err := s.StartEnrollment(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := Code(secret, now)
+	codes, err := s.ConfirmEnrollment(ctx, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret, codes
+}
+
+func TestStoreEnrollment(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	s, ctx := newTestStore(t, &now)
+
+	secret, err := s.StartEnrollment(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enrolled, _ := s.Enrolled(ctx); enrolled {
+		t.Fatal("expected the enrollment to wait for the confirmation")
+	}
+	if _, err := s.ConfirmEnrollment(ctx, "000000"); err == nil {
+		t.Fatal("expected a wrong code to be rejected")
+	}
+	code, _ := Code(secret, now)
+	codes, err := s.ConfirmEnrollment(ctx, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodes {
+		t.Fatalf("expected %d recovery codes, got %d", RecoveryCodes, len(codes))
+	}
+	if enrolled, _ := s.Enrolled(ctx); !enrolled {
+		t.Fatal("expected the user to be enrolled")
+	}
+
+	stored, _ := s.prefs.GetKey(ctx, SecretKey, Namespace)
+	if stored == "" || stored == secret {
+		t.Fatalf("expected the secret to be stored encrypted, got %q", stored)
+	}
+	if _, err := s.StartEnrollment(ctx); err == nil {
+		t.Fatal("expected an enrolled user not to be able to replace the secret")
+	}
+}
+
+func TestStoreRejectsReplayedCodes(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	s, ctx := newTestStore(t, &now)
+	secret, _ := enroll(t, s, ctx, now)
+
+	// the code used to confirm the enrollment is spent
+	code, _ := Code(secret, now)
+	if err := s.Verify(ctx, code); err == nil {
+		t.Fatal("expected the confirmation code to be rejected")
+	}
+
+	now = now.Add(Period)
+	code, _ = Code(secret, now)
+	if err := s.Verify(ctx, code); err != nil {
+		t.Fatalf("expected the next code to be accepted: %v", err)
+	}
+	if err := s.Verify(ctx, code); err == nil {
+		t.Fatal("expected a replayed code to be rejected")
+	}
+
+	// nor is an older code within the skew accepted anymore
+	old, _ := Code(secret, now.Add(-Period))
+	if err := s.Verify(ctx, old); err == nil {
+		t.Fatal("expected an older code to be rejected")
+	}
+}
+
+func TestStoreRejectsConcurrentCodes(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	s, ctx := newTestStore(t, &now)
+	secret, _ := enroll(t, s, ctx, now)
+
+	now = now.Add(Period)
+	code, _ := Code(secret, now)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.Verify(ctx, code) == nil {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted != 1 {
+		t.Fatalf("expected the code to be accepted once, got %d", accepted)
+	}
+}
+
+func TestStoreRecoveryCodes(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	s, ctx := newTestStore(t, &now)
+	_, codes := enroll(t, s, ctx, now)
+
+	if err := s.Verify(ctx, codes[0]); err != nil {
+		t.Fatalf("expected the recovery code to be accepted: %v", err)
+	}
+	err := s.Verify(ctx, codes[0])
+	if _, ok := err.(errtypes.InvalidCredentials); !ok {
+		t.Fatalf("expected a used recovery code to be rejected, got %v", err)
+	}
+}
+
+func TestStoreDisableRequiresCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	s, ctx := newTestStore(t, &now)
+	secret, codes := enroll(t, s, ctx, now)
+
+	now = now.Add(Period)
+	if err := s.Disable(ctx, "000000"); err == nil {
+		t.Fatal("expected a wrong code to be rejected")
+	}
+	if err := s.Disable(ctx, codes[0]); err == nil {
+		t.Fatal("expected a recovery code not to disable the second factor")
+	}
+	if _, err := s.RegenerateRecoveryCodes(ctx, ""); err == nil {
+		t.Fatal("expected the recovery codes to require a code")
+	}
+
+	code, _ := Code(secret, now)
+	if err := s.Disable(ctx, code); err != nil {
+		t.Fatal(err)
+	}
+	if enrolled, _ := s.Enrolled(ctx); enrolled {
+		t.Fatal("expected the user not to be enrolled anymore")
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package totp implements time-based one-time passwords (RFC 6238) and the
+// recovery codes used as a second authentication factor.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/preferences"
+)
+
+const (
+	// Namespace is the preferences namespace holding the TOTP enrollment of a
+	// user. It is reserved, so the users cannot reach it through the
+	// preferences APIs.
+	Namespace = preferences.TOTPNamespace
+	// SecretKey is the preferences key storing the encrypted shared secret.
+	SecretKey = "secret"
+	// PendingSecretKey is the preferences key storing the encrypted shared
+	// secret of an enrollment that is yet to be confirmed.
+	PendingSecretKey = "pending_secret"
+	// RecoveryCodesKey is the preferences key storing the hashed recovery codes.
+	RecoveryCodesKey = "recovery_codes"
+	// LastStepKey is the preferences key storing the time step of the last
+	// accepted code, so that a code cannot be used twice.
+	LastStepKey = "last_step"
+
+	// Digits is the number of digits of a generated code.
+	Digits = 6
+	// Period is the time step of a code.
+	Period = 30 * time.Second
+
+	secretSize        = 20
+	recoveryCodeBytes = 5
+
+	// codeSeparator separates the password from the one-time code when both
+	// travel in the same client secret.
+	codeSeparator = "\x00"
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random base32 encoded shared secret.
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(b), nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	return b32.DecodeString(strings.TrimRight(secret, "="))
+}
+
+// Code returns the code for the given secret at time t.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", fmt.Errorf("totp: invalid secret: %w", err)
+	}
+	return hotp(key, uint64(t.Unix()/int64(Period.Seconds()))), nil
+}
+
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for range Digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
+
+// Validate reports whether code is valid for the secret at time t, accepting
+// codes up to skew periods before or after t to tolerate clock drift.
+func Validate(secret, code string, t time.Time, skew int) bool {
+	_, ok := ValidateStep(secret, code, t, skew, -1)
+	return ok
+}
+
+// ValidateStep is like Validate, but only accepts codes of the time steps
+// after the given one and returns the time step the code belongs to. Passing
+// the last accepted step prevents a code from being replayed.
+func ValidateStep(secret, code string, t time.Time, skew int, after int64) (int64, bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	counter := t.Unix() / int64(Period.Seconds())
+	for i := -skew; i <= skew; i++ {
+		c := counter + int64(i)
+		if c < 0 || c <= after {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(c))), []byte(code)) == 1 {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// URI returns the otpauth:// URI that authenticator apps use to enroll the secret.
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(account)
+	if issuer != "" {
+		label = url.PathEscape(issuer) + ":" + label
+	}
+	v := url.Values{}
+	v.Set("secret", secret)
+	if issuer != "" {
+		v.Set("issuer", issuer)
+	}
+	v.Set("digits", fmt.Sprint(Digits))
+	v.Set("period", fmt.Sprint(int(Period.Seconds())))
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// GenerateRecoveryCodes returns n random single-use recovery codes.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for range n {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(b32.EncodeToString(b))
+		codes = append(codes, s[:4]+"-"+s[4:])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the representation of a recovery code that is persisted.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// EncodeRecoveryCodes hashes the given recovery codes and encodes them
+// for storage.
+func EncodeRecoveryCodes(codes []string) string {
+	hashes := make([]string, 0, len(codes))
+	for _, c := range codes {
+		hashes = append(hashes, HashRecoveryCode(c))
+	}
+	return strings.Join(hashes, ",")
+}
+
+// UseRecoveryCode checks code against the stored recovery codes. If the code
+// matches, it returns the stored value with that code removed.
+func UseRecoveryCode(stored, code string) (string, bool) {
+	if stored == "" || code == "" {
+		return stored, false
+	}
+	h := HashRecoveryCode(code)
+	hashes := strings.Split(stored, ",")
+	for i, s := range hashes {
+		if subtle.ConstantTimeCompare([]byte(s), []byte(h)) == 1 {
+			hashes = append(hashes[:i], hashes[i+1:]...)
+			return strings.Join(hashes, ","), true
+		}
+	}
+	return stored, false
+}
+
+// JoinSecret appends a one-time code to a client secret, so that both can be
+// forwarded to the auth provider in a single credential.
+func JoinSecret(secret, code string) string {
+	if code == "" {
+		return secret
+	}
+	return secret + codeSeparator + code
+}
+
+// SplitSecret is the inverse of JoinSecret.
+func SplitSecret(s string) (secret, code string) {
+	if i := strings.LastIndex(s, codeSeparator); i >= 0 {
+		return s[:i], s[i+len(codeSeparator):]
+	}
+	return s, ""
+}
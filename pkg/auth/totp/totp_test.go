@@ -0,0 +1,130 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 seed of the test vectors in RFC 6238, appendix B.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tt.code {
+			t.Errorf("Code at %d: got %s, expected %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, err := Code(rfcSecret, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		code     string
+		skew     int
+		expected bool
+	}{
+		{"same period", now, code, 0, true},
+		{"previous period within skew", now.Add(Period), code, 1, true},
+		{"previous period without skew", now.Add(Period), code, 0, false},
+		{"outside skew", now.Add(3 * Period), code, 1, false},
+		{"wrong code", now, "000000", 1, false},
+		{"wrong length", now, code[:5], 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Validate(rfcSecret, tt.code, tt.at, tt.skew); got != tt.expected {
+				t.Errorf("got %t, expected %t", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := Code(secret, time.Now()); err != nil {
+		t.Fatalf("generated secret is not usable: %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored := EncodeRecoveryCodes(codes)
+
+	stored, ok := UseRecoveryCode(stored, strings.ToUpper(codes[1]))
+	if !ok {
+		t.Fatalf("expected recovery code to be accepted")
+	}
+	if _, ok := UseRecoveryCode(stored, codes[1]); ok {
+		t.Fatalf("expected recovery code to be single use")
+	}
+	if _, ok := UseRecoveryCode(stored, codes[0]); !ok {
+		t.Fatalf("expected other recovery codes to remain valid")
+	}
+}
+
+func TestJoinSecret(t *testing.T) {
+	tests := []struct {
+		secret string
+		code   string
+	}{
+		{"password", "123456"},
+		{"password", ""},
+		{"pass:word", "abcd-efgh"},
+	}
+
+	for _, tt := range tests {
+		secret, code := SplitSecret(JoinSecret(tt.secret, tt.code))
+		if secret != tt.secret || code != tt.code {
+			t.Errorf("got (%q, %q), expected (%q, %q)", secret, code, tt.secret, tt.code)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package clientip resolves the address of the client that originated a
+// request, trusting the addresses forwarded by the proxies and by the reva
+// services only when they come from one of the configured trusted proxies.
+package clientip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/utils"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// loopback are the trusted proxies if none is configured, as the reverse
+// proxies and the reva services often run on the same host.
+var loopback = []string{"127.0.0.0/8", "::1"}
+
+func trustedProxies() []string {
+	if p := sharedconf.GetTrustedProxies(); len(p) > 0 {
+		return p
+	}
+	return loopback
+}
+
+// IsTrustedProxy reports whether ip is the address of a trusted proxy.
+func IsTrustedProxy(ip string) bool {
+	return utils.IPInNetworks(ip, trustedProxies())
+}
+
+// IsFromTrustedProxy reports whether an HTTP request comes straight from a
+// trusted proxy, whose forwarding headers can then be honoured.
+func IsFromTrustedProxy(r *http.Request) bool {
+	return IsTrustedProxy(host(r.RemoteAddr))
+}
+
+// FromRequest returns the address of the client of an HTTP request. The
+// X-Forwarded-For header is only considered if the request comes from a
+// trusted proxy, the client being the right-most address of the chain that
+// is not a trusted proxy.
+func FromRequest(r *http.Request) string {
+	return resolve(host(r.RemoteAddr), r.Header.Values("X-Forwarded-For"), trustedProxies())
+}
+
+// FromContext returns the address of the client that originated a gRPC
+// call. The address forwarded by the reva services in the x-client-ip
+// metadata is only considered if the call comes from a trusted proxy, the
+// address of the peer is returned otherwise.
+func FromContext(ctx context.Context) string {
+	var forwarded string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if lst := md.Get(appctx.ClientIPHeader); len(lst) > 0 {
+			forwarded = lst[0]
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		// not a network call: the metadata was set by the
+		// HTTP services of this process, from FromRequest
+		return forwarded
+	}
+	remote := host(p.Addr.String())
+	if forwarded != "" && IsTrustedProxy(remote) {
+		return forwarded
+	}
+	return remote
+}
+
+// APIFromContext returns the HTTP path of the request that originated a gRPC
+// call, as forwarded by the reva services in the x-client-api metadata. It is
+// empty if the call does not come from a trusted proxy, as for the clients
+// calling the gRPC services directly.
+func APIFromContext(ctx context.Context) string {
+	var forwarded string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if lst := md.Get(appctx.ClientAPIHeader); len(lst) > 0 {
+			forwarded = lst[0]
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil || IsTrustedProxy(host(p.Addr.String())) {
+		return forwarded
+	}
+	return ""
+}
+
+func resolve(remote string, forwarded []string, trusted []string) string {
+	if !utils.IPInNetworks(remote, trusted) {
+		return remote
+	}
+
+	var hops []string
+	for _, h := range forwarded {
+		for _, ip := range strings.Split(h, ",") {
+			hops = append(hops, strings.TrimSpace(ip))
+		}
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			// a malformed chain: the last trusted hop is the best we know
+			break
+		}
+		client = ip.String()
+		if !utils.IPInNetworks(client, trusted) {
+			break
+		}
+	}
+	return client
+}
+
+// host strips the port from an address.
+func host(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		addr = h
+	}
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip.String()
+	}
+	return addr
+}
@@ -0,0 +1,89 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package clientip
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestResolve(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "::1"}
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		expected  string
+	}{
+		{"direct client", "192.0.2.1", nil, "192.0.2.1"},
+		{"spoofed header from an untrusted client", "192.0.2.1", []string{"198.51.100.7"}, "192.0.2.1"},
+		{"trusted proxy", "10.0.0.1", []string{"192.0.2.1"}, "192.0.2.1"},
+		{"spoofed hop before the proxy", "10.0.0.1", []string{"198.51.100.7, 192.0.2.1"}, "192.0.2.1"},
+		{"chain of trusted proxies", "10.0.0.1", []string{"192.0.2.1, 10.0.0.2", "10.0.0.3"}, "192.0.2.1"},
+		{"trusted proxy without header", "10.0.0.1", nil, "10.0.0.1"},
+		{"malformed hop", "10.0.0.1", []string{"not-an-ip, 10.0.0.2"}, "10.0.0.2"},
+		{"only trusted hops", "::1", []string{"10.0.0.2"}, "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolve(tt.remote, tt.forwarded, trusted); got != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	if got := FromRequest(r); got != "192.0.2.1" {
+		t.Fatalf("expected the header of an untrusted client to be ignored, got %q", got)
+	}
+
+	r.RemoteAddr = "127.0.0.1:1234"
+	if got := FromRequest(r); got != "198.51.100.7" {
+		t.Fatalf("expected the address forwarded by the local proxy, got %q", got)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	md := metadata.Pairs(appctx.ClientIPHeader, "198.51.100.7")
+
+	ctx := peer.NewContext(metadata.NewIncomingContext(context.Background(), md), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234}})
+	if got := FromContext(ctx); got != "192.0.2.1" {
+		t.Fatalf("expected the address forwarded by an untrusted peer to be ignored, got %q", got)
+	}
+
+	ctx = peer.NewContext(metadata.NewIncomingContext(context.Background(), md), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1234}})
+	if got := FromContext(ctx); got != "198.51.100.7" {
+		t.Fatalf("expected the address forwarded by a trusted peer, got %q", got)
+	}
+
+	ctx = metadata.NewIncomingContext(context.Background(), md)
+	if got := FromContext(ctx); got != "198.51.100.7" {
+		t.Fatalf("expected the address set by the HTTP services, got %q", got)
+	}
+}
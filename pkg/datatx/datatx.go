@@ -46,6 +46,9 @@ type Transfer struct {
 	DestTargetURI string
 	ShareID       string
 	UserID        *userv1beta1.UserId
+	// Status and Progress are the last ones reported by the driver.
+	Status   datatx.Status
+	Progress *Progress
 }
 
 // Repository the interface that any storage driver should implement.
@@ -20,8 +20,10 @@ package loader
 
 import (
 	// Load datatx drivers.
+	_ "github.com/cs3org/reva/v3/pkg/datatx/manager/native"
 	_ "github.com/cs3org/reva/v3/pkg/datatx/manager/rclone"
 	_ "github.com/cs3org/reva/v3/pkg/datatx/manager/rclone/repository/json"
 	_ "github.com/cs3org/reva/v3/pkg/datatx/repository/json"
+	_ "github.com/cs3org/reva/v3/pkg/datatx/repository/sql"
 	// Add your own here.
 )
@@ -0,0 +1,579 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package native
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	"github.com/cs3org/reva/v3/internal/http/services/datagateway"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/httpclient"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/v3/pkg/rjobs"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/studio-b12/gowebdav"
+	"google.golang.org/grpc/metadata"
+)
+
+// job performs native transfers. Each run copies the files of the source
+// not transferred yet, so a failed or interrupted run resumes after the last
+// completed file.
+type job struct {
+	c      *config
+	store  *store
+	client *httpclient.Client
+	tr     http.RoundTripper
+}
+
+// NewJob returns the job performing native transfers. Its configuration
+// must point to the same transfers file as the datatx driver.
+func NewJob(ctx context.Context, m map[string]any) (rjobs.Job, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+	s, err := newStore(c.File)
+	if err != nil {
+		return nil, err
+	}
+
+	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: c.Insecure}}
+	return &job{
+		c:      &c,
+		store:  s,
+		client: httpclient.New(httpclient.RoundTripper(tr), httpclient.Timeout(time.Duration(c.Timeout)*time.Second)),
+		tr:     tr,
+	}, nil
+}
+
+type jobParams struct {
+	// TransferID is the id of the transfer to perform. Required.
+	TransferID string `mapstructure:"transfer_id"`
+}
+
+// errCancelled is returned when the transfer was cancelled while running.
+var errCancelled = errors.New("native: transfer cancelled")
+
+// Run performs the transfer. Failures are retried by the framework until
+// max_attempts is reached, when the transfer is marked as failed; errors
+// that a new attempt would not fix fail the transfer right away.
+func (j *job) Run(ctx context.Context, p rjobs.Params) (rjobs.Params, error) {
+	var params jobParams
+	if err := mapstructure.Decode(map[string]any(p), &params); err != nil {
+		return nil, errors.Wrap(err, "native: decoding params failed")
+	}
+	if params.TransferID == "" {
+		return nil, errors.New("native: missing 'transfer_id' parameter")
+	}
+	log := appctx.GetLogger(ctx).With().Str("transfer_id", params.TransferID).Logger()
+
+	t, err := j.store.get(params.TransferID)
+	if err != nil {
+		return nil, err
+	}
+	if txEndStatuses[t.Status] {
+		// cancelled before the run started, or a redelivery of a finished run
+		log.Info().Str("status", t.Status.String()).Msg("native: transfer already in end state")
+		return nil, nil
+	}
+	var ended bool
+	if _, err := j.store.update(t.TransferID, func(t *transfer) {
+		// cancelled since it was read
+		if ended = txEndStatuses[t.Status]; ended {
+			return
+		}
+		t.Status = datatx.Status_STATUS_TRANSFER_IN_PROGRESS
+		t.startClock(time.Now())
+	}); err != nil {
+		return nil, err
+	}
+	if ended {
+		log.Info().Msg("native: transfer cancelled")
+		return nil, nil
+	}
+
+	stats, err := j.transfer(ctx, t)
+	if _, uerr := j.store.update(t.TransferID, func(t *transfer) {
+		t.stopClock(time.Now())
+	}); uerr != nil {
+		return nil, uerr
+	}
+	if err == nil {
+		// the transfer may be cancelled while its last file is transferred
+		var cancelled bool
+		if _, uerr := j.store.update(t.TransferID, func(t *transfer) {
+			if cancelled = t.Status == datatx.Status_STATUS_TRANSFER_CANCELLED; cancelled {
+				return
+			}
+			t.Status = datatx.Status_STATUS_TRANSFER_COMPLETE
+			t.Error = ""
+		}); uerr != nil {
+			return nil, uerr
+		}
+		if cancelled {
+			err = errCancelled
+		}
+	}
+	switch {
+	case err == nil:
+		log.Info().Int("files", stats.files).Int64("bytes", stats.bytes).Msg("native: transfer complete")
+		return rjobs.Params{"files": stats.files, "bytes": stats.bytes}, nil
+
+	case errors.Is(err, errCancelled):
+		log.Info().Msg("native: transfer cancelled")
+		return nil, nil
+
+	case ctx.Err() != nil:
+		// shutdown or run cancellation: the framework decides what comes next
+		return nil, err
+	}
+
+	var failed, cancelled bool
+	t, uerr := j.store.update(t.TransferID, func(t *transfer) {
+		if cancelled = t.Status == datatx.Status_STATUS_TRANSFER_CANCELLED; cancelled {
+			return
+		}
+		t.Attempts++
+		t.Error = err.Error()
+		if permanent(err) || t.Attempts >= j.c.MaxAttempts {
+			t.Status = datatx.Status_STATUS_TRANSFER_FAILED
+			failed = true
+		}
+	})
+	if uerr != nil {
+		return nil, uerr
+	}
+	if cancelled {
+		log.Info().Err(err).Msg("native: transfer cancelled")
+		return nil, nil
+	}
+	if failed {
+		log.Error().Err(err).Int("attempts", t.Attempts).Msg("native: transfer failed")
+		return nil, nil
+	}
+	return nil, err
+}
+
+// permanent tells whether a new attempt of the transfer would fail as well.
+func permanent(err error) bool {
+	switch errors.Cause(err).(type) {
+	case errtypes.IsNotFound, errtypes.IsPermissionDenied, errtypes.IsBadRequest:
+		return true
+	}
+	return false
+}
+
+type transferStats struct {
+	files int
+	bytes int64
+}
+
+type sourceFile struct {
+	rel  string
+	size int64
+}
+
+func (j *job) transfer(ctx context.Context, t *transfer) (*transferStats, error) {
+	src, err := parseTarget(t.SrcTargetURI)
+	if err != nil {
+		return nil, errtypes.BadRequest(err.Error())
+	}
+	dst, err := parseTarget(t.DestTargetURI)
+	if err != nil {
+		return nil, errtypes.BadRequest(err.Error())
+	}
+	dstRoot := path.Join("/", j.c.WebDAVNamespace, strings.TrimPrefix(dst.path, j.c.WebDAVPrefix))
+
+	gw, err := pool.GetGatewayServiceClient(pool.Endpoint(j.c.GatewaySvc))
+	if err != nil {
+		return nil, err
+	}
+	gwCtx := metadata.AppendToOutgoingContext(ctx, appctx.TokenHeader, dst.token)
+
+	dirs, files, err := j.list(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dirs {
+		p, err := destination(dstRoot, d)
+		if err != nil {
+			return nil, err
+		}
+		if err := createContainer(gwCtx, gw, p); err != nil {
+			return nil, err
+		}
+	}
+
+	var total uint64
+	for _, f := range files {
+		total += uint64(f.size)
+	}
+	if _, err := j.store.update(t.TransferID, func(t *transfer) {
+		t.FilesTotal = uint64(len(files))
+		t.BytesTotal = total
+	}); err != nil {
+		return nil, err
+	}
+
+	completed := make(map[string]bool, len(t.Completed))
+	for _, f := range t.Completed {
+		completed[f] = true
+	}
+	stats := &transferStats{files: len(t.Completed)}
+	for _, f := range files {
+		if completed[f.rel] {
+			continue
+		}
+		if err := j.checkCancelled(ctx, t.TransferID); err != nil {
+			return nil, err
+		}
+
+		p, err := destination(dstRoot, f.rel)
+		if err != nil {
+			return nil, err
+		}
+		n, err := j.copyFile(gwCtx, gw, src, f.rel, p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "native: error transferring %q", f.rel)
+		}
+		if err := j.store.complete(t.TransferID, f.rel, n); err != nil {
+			return nil, err
+		}
+		stats.files++
+		stats.bytes += n
+	}
+	return stats, nil
+}
+
+// destination returns the destination path of a file or directory of the
+// source, given its path relative to the source root. The path must stay
+// below the destination root, which an empty relative path refers to.
+func destination(dstRoot, rel string) (string, error) {
+	if rel == "" {
+		return dstRoot, nil
+	}
+	p := path.Join(dstRoot, rel)
+	if !strings.HasPrefix(p, strings.TrimSuffix(dstRoot, "/")+"/") {
+		return "", errtypes.BadRequest("native: destination outside of the target: " + rel)
+	}
+	return p, nil
+}
+
+func (j *job) checkCancelled(ctx context.Context, transferID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	t, err := j.store.get(transferID)
+	if err != nil {
+		return err
+	}
+	if t.Status == datatx.Status_STATUS_TRANSFER_CANCELLED {
+		return errCancelled
+	}
+	return nil
+}
+
+func (j *job) authorize(r *http.Request, token string) {
+	if token == "" {
+		return
+	}
+	if j.c.AuthHeader == "x-access-token" {
+		r.Header.Set(appctx.TokenHeader, token)
+		return
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+}
+
+// list returns the directories and the files of the source, relative to
+// its root and sorted, so that runs walk the files in the same order.
+// If the source is a file, a single file with an empty relative path is
+// returned.
+func (j *job) list(src *target) ([]string, []sourceFile, error) {
+	// Preemptive auth, as gowebdav's default auto-auth buffers request bodies;
+	// the token is set by the interceptor.
+	dav := gowebdav.NewAuthClient(src.url, gowebdav.NewPreemptiveAuth(&gowebdav.BasicAuth{}))
+	dav.SetTransport(j.tr)
+	dav.SetTimeout(time.Duration(j.c.Timeout) * time.Second)
+	dav.SetInterceptor(func(method string, r *http.Request) {
+		j.authorize(r, src.token)
+	})
+
+	root := path.Join("/", src.path)
+	info, err := dav.Stat(root)
+	if err != nil {
+		return nil, nil, davError(err, root)
+	}
+	if !info.IsDir() {
+		return nil, []sourceFile{{rel: "", size: info.Size()}}, nil
+	}
+
+	dirs := []string{""}
+	var files []sourceFile
+	for i := 0; i < len(dirs); i++ {
+		entries, err := dav.ReadDir(path.Join(root, dirs[i]))
+		if err != nil {
+			return nil, nil, davError(err, path.Join(root, dirs[i]))
+		}
+		for _, e := range entries {
+			if !validName(e.Name()) {
+				return nil, nil, errtypes.BadRequest(fmt.Sprintf("native: invalid name %q in the source", e.Name()))
+			}
+			rel := path.Join(dirs[i], e.Name())
+			if e.IsDir() {
+				dirs = append(dirs, rel)
+			} else {
+				files = append(files, sourceFile{rel: rel, size: e.Size()})
+			}
+		}
+	}
+	sort.Strings(dirs)
+	sort.Slice(files, func(a, b int) bool { return files[a].rel < files[b].rel })
+	return dirs, files, nil
+}
+
+// validName tells whether a name listed by the source is a plain file name,
+// which cannot escape the directory it is listed in.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.Contains(name, "/")
+}
+
+func davError(err error, p string) error {
+	switch {
+	case gowebdav.IsErrNotFound(err):
+		return errtypes.NotFound(p)
+	case gowebdav.IsErrCode(err, http.StatusUnauthorized), gowebdav.IsErrCode(err, http.StatusForbidden):
+		return errtypes.PermissionDenied(p)
+	}
+	return errors.Wrap(err, "native: error listing the source")
+}
+
+func createContainer(ctx context.Context, gw gateway.GatewayAPIClient, p string) error {
+	res, err := gw.CreateContainer(ctx, &provider.CreateContainerRequest{
+		Ref: &provider.Reference{Path: p},
+	})
+	switch {
+	case err != nil:
+		return err
+	case res.Status.Code == rpc.Code_CODE_OK, res.Status.Code == rpc.Code_CODE_ALREADY_EXISTS:
+		return nil
+	case res.Status.Code == rpc.Code_CODE_PERMISSION_DENIED:
+		return errtypes.PermissionDenied(p)
+	}
+	return errtypes.InternalError(res.Status.Message)
+}
+
+// copyFile downloads a file from the source and uploads it to the given
+// destination path, verifying its checksum against the ones advertised by
+// the source and by the destination. It returns the number of bytes copied.
+func (j *job) copyFile(ctx context.Context, gw gateway.GatewayAPIClient, src *target, rel, dst string) (int64, error) {
+	u, err := url.JoinPath(src.url, src.path, rel)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	j.authorize(req, src.token)
+	res, err := j.client.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "error downloading from the source")
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return 0, errtypes.NotFound(rel)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return 0, errtypes.PermissionDenied(rel)
+	default:
+		return 0, errtypes.InternalError(fmt.Sprintf("source responded %s", res.Status))
+	}
+
+	initRes, err := gw.InitiateFileUpload(ctx, &provider.InitiateFileUploadRequest{
+		Ref: &provider.Reference{Path: dst},
+	})
+	switch {
+	case err != nil:
+		return 0, err
+	case initRes.Status.Code == rpc.Code_CODE_PERMISSION_DENIED:
+		return 0, errtypes.PermissionDenied(dst)
+	case initRes.Status.Code != rpc.Code_CODE_OK:
+		return 0, errtypes.InternalError(initRes.Status.Message)
+	}
+	var endpoint, token string
+	for _, p := range initRes.Protocols {
+		if p.Protocol == "simple" {
+			endpoint, token = p.UploadEndpoint, p.Token
+		}
+	}
+	if endpoint == "" {
+		return 0, errtypes.InternalError("simple upload not supported")
+	}
+
+	sums := newChecksums()
+	body := &countingReader{r: io.TeeReader(newThrottledReader(ctx, res.Body, j.c.BandwidthLimit), sums)}
+	upReq, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, body)
+	if err != nil {
+		return 0, err
+	}
+	upReq.ContentLength = res.ContentLength
+	upReq.Header.Set(datagateway.TokenTransportHeader, token)
+	upRes, err := j.client.Do(upReq)
+	if err != nil {
+		return 0, errors.Wrap(err, "error uploading to the destination")
+	}
+	defer upRes.Body.Close()
+	if upRes.StatusCode != http.StatusOK && upRes.StatusCode != http.StatusCreated {
+		return 0, errtypes.InternalError(fmt.Sprintf("destination responded %s", upRes.Status))
+	}
+
+	if err := sums.verify(res.Header.Get("OC-Checksum")); err != nil {
+		return 0, errors.Wrap(err, "source checksum mismatch")
+	}
+	statRes, err := gw.Stat(ctx, &provider.StatRequest{Ref: &provider.Reference{Path: dst}})
+	switch {
+	case err != nil:
+		return 0, err
+	case statRes.Status.Code != rpc.Code_CODE_OK:
+		return 0, errtypes.InternalError(statRes.Status.Message)
+	}
+	if c := statRes.Info.GetChecksum(); c != nil {
+		if err := sums.verify(checksumTypeName(c.Type) + ":" + c.Sum); err != nil {
+			return 0, errors.Wrap(err, "destination checksum mismatch")
+		}
+	}
+	return body.n, nil
+}
+
+// checksums computes the checksums of a file in the formats used by the
+// storages, to verify it against the ones advertised by either end.
+type checksums map[string]hash.Hash
+
+func newChecksums() checksums {
+	return checksums{
+		"adler32": adler32.New(),
+		"md5":     md5.New(),
+		"sha1":    sha1.New(),
+	}
+}
+
+func (c checksums) Write(p []byte) (int, error) {
+	for _, h := range c {
+		h.Write(p)
+	}
+	return len(p), nil
+}
+
+// verify checks the computed checksums against one in the `type:sum` form
+// of the OC-Checksum header. Unknown or empty checksums are not verified.
+func (c checksums) verify(checksum string) error {
+	t, sum, ok := strings.Cut(checksum, ":")
+	if !ok || sum == "" {
+		return nil
+	}
+	h, ok := c[strings.ToLower(t)]
+	if !ok {
+		return nil
+	}
+	if computed := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(computed, sum) {
+		return errors.Errorf("%s: expected %s, got %s", strings.ToLower(t), sum, computed)
+	}
+	return nil
+}
+
+func checksumTypeName(t provider.ResourceChecksumType) string {
+	switch t {
+	case provider.ResourceChecksumType_RESOURCE_CHECKSUM_TYPE_ADLER32:
+		return "adler32"
+	case provider.ResourceChecksumType_RESOURCE_CHECKSUM_TYPE_MD5:
+		return "md5"
+	case provider.ResourceChecksumType_RESOURCE_CHECKSUM_TYPE_SHA1:
+		return "sha1"
+	}
+	return ""
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// throttledReader caps the throughput of the underlying reader to limit
+// bytes per second.
+type throttledReader struct {
+	ctx   context.Context
+	r     io.Reader
+	limit int64
+	start time.Time
+	n     int64
+}
+
+func newThrottledReader(ctx context.Context, r io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return r
+	}
+	return &throttledReader{ctx: ctx, r: r, limit: limit, start: time.Now()}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > t.limit {
+		p = p[:t.limit]
+	}
+	n, err := t.r.Read(p)
+	t.n += int64(n)
+
+	// wait until the bytes read so far fit in the allowed rate
+	expected := time.Duration(float64(t.n) / float64(t.limit) * float64(time.Second))
+	if wait := expected - time.Since(t.start); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-t.ctx.Done():
+			return n, t.ctx.Err()
+		}
+	}
+	return n, err
+}
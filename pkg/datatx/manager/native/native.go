@@ -0,0 +1,281 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package native implements a datatx driver that performs the transfers
+// itself, without relying on an external service. Transfers are executed as
+// durable rjobs runs, which pull the data from the source WebDAV endpoint and
+// upload it to the destination storage through the gateway.
+package native
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	txdriver "github.com/cs3org/reva/v3/pkg/datatx"
+	registry "github.com/cs3org/reva/v3/pkg/datatx/manager/registry"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/rjobs"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// JobName is the name of the on-demand job performing native transfers.
+const JobName = "datatx.native"
+
+func init() {
+	registry.Register("native", New)
+	if err := rjobs.RegisterOnDemand(JobName, NewJob); err != nil {
+		panic(err)
+	}
+}
+
+// config is shared by the driver and the job: both must point to the same
+// transfers file.
+type config struct {
+	// File is where the state of the transfers is persisted.
+	File       string `mapstructure:"file"`
+	GatewaySvc string `mapstructure:"gatewaysvc"`
+	// AuthHeader selects how the source token is sent: as a bearer token
+	// (the default) or in the `x-access-token` header.
+	AuthHeader string `mapstructure:"auth_header"`
+	// WebDAVPrefix is stripped from the destination path to get the path of
+	// the destination in the storage, prefixed by WebDAVNamespace.
+	WebDAVPrefix    string `mapstructure:"webdav_prefix"`
+	WebDAVNamespace string `mapstructure:"webdav_namespace"`
+	// BandwidthLimit caps the throughput of a transfer, in bytes per second.
+	// Zero means unlimited.
+	BandwidthLimit int64 `mapstructure:"bandwidth_limit"`
+	// MaxAttempts is the number of failed runs after which a transfer is
+	// marked as failed instead of being retried.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// Timeout of the requests to the source, in seconds. Zero means no timeout.
+	Timeout  int  `mapstructure:"timeout"`
+	Insecure bool `mapstructure:"insecure"`
+}
+
+func (c *config) ApplyDefaults() {
+	if c.File == "" {
+		c.File = "/var/tmp/reva/datatx-native.json"
+	}
+	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
+	if c.AuthHeader == "" {
+		c.AuthHeader = "bearer"
+	}
+	if c.WebDAVPrefix == "" {
+		c.WebDAVPrefix = "/remote.php/webdav"
+	}
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = 5
+	}
+}
+
+type driver struct {
+	c     *config
+	store *store
+}
+
+// txEndStatuses are the final statuses of a transfer.
+var txEndStatuses = map[datatx.Status]bool{
+	datatx.Status_STATUS_INVALID:               true,
+	datatx.Status_STATUS_DESTINATION_NOT_FOUND: true,
+	datatx.Status_STATUS_TRANSFER_COMPLETE:     true,
+	datatx.Status_STATUS_TRANSFER_FAILED:       true,
+	datatx.Status_STATUS_TRANSFER_CANCELLED:    true,
+	datatx.Status_STATUS_TRANSFER_EXPIRED:      true,
+}
+
+// New returns a new native datatx driver.
+func New(ctx context.Context, m map[string]any) (txdriver.Manager, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+	s, err := newStore(c.File)
+	if err != nil {
+		return nil, err
+	}
+	return &driver{c: &c, store: s}, nil
+}
+
+func txInfo(t *transfer) *datatx.TxInfo {
+	info := &datatx.TxInfo{
+		Id:     &datatx.TxId{OpaqueId: t.TransferID},
+		Status: t.Status,
+		Ctime:  &typespb.Timestamp{Seconds: t.Ctime},
+	}
+	// the progress only holds numbers, encoding it cannot fail
+	_ = txdriver.SetProgress(info, t.progress(time.Now()))
+	return info
+}
+
+func invalidTxInfo(transferID string) *datatx.TxInfo {
+	return &datatx.TxInfo{
+		Id:     &datatx.TxId{OpaqueId: transferID},
+		Status: datatx.Status_STATUS_INVALID,
+	}
+}
+
+// CreateTransfer creates a transfer and enqueues the job performing it.
+// Specified target URIs are of form scheme://userinfo@host:port?name={path}
+func (d *driver) CreateTransfer(ctx context.Context, srcTargetURI string, dstTargetURI string) (*datatx.TxInfo, error) {
+	for _, u := range []string{srcTargetURI, dstTargetURI} {
+		if _, err := parseTarget(u); err != nil {
+			return invalidTxInfo(""), err
+		}
+	}
+
+	t := &transfer{
+		TransferID:    uuid.New().String(),
+		Status:        datatx.Status_STATUS_TRANSFER_NEW,
+		SrcTargetURI:  srcTargetURI,
+		DestTargetURI: dstTargetURI,
+		Ctime:         uint64(time.Now().Unix()),
+	}
+	if err := d.store.put(t); err != nil {
+		return invalidTxInfo(t.TransferID), err
+	}
+	return d.enqueue(ctx, t)
+}
+
+// enqueue submits a run of the transfer and records it.
+func (d *driver) enqueue(ctx context.Context, t *transfer) (*datatx.TxInfo, error) {
+	runner := rjobs.Default()
+	if runner == nil {
+		t.Status = datatx.Status_STATUS_TRANSFER_FAILED
+		t.Error = "jobs service is not enabled"
+		_ = d.store.put(t)
+		return txInfo(t), errors.New("native: jobs service is not enabled")
+	}
+
+	var opts []rjobs.EnqueueOption
+	if u, ok := appctx.ContextGetUser(ctx); ok {
+		opts = append(opts, rjobs.WithOwner(u.Username))
+	}
+	runID, err := runner.Enqueue(ctx, JobName, rjobs.Params{"transfer_id": t.TransferID}, opts...)
+	if err != nil {
+		t.Status = datatx.Status_STATUS_TRANSFER_FAILED
+		t.Error = err.Error()
+		_ = d.store.put(t)
+		return txInfo(t), errors.Wrap(err, "native: error enqueuing transfer")
+	}
+
+	t, err = d.store.update(t.TransferID, func(t *transfer) {
+		t.RunID = string(runID)
+	})
+	if err != nil {
+		return invalidTxInfo(t.TransferID), err
+	}
+	appctx.GetLogger(ctx).Info().Str("transfer_id", t.TransferID).Str("run", string(runID)).Msg("native: transfer enqueued")
+	return txInfo(t), nil
+}
+
+// GetTransferStatus returns the status of the transfer with the specified id.
+func (d *driver) GetTransferStatus(ctx context.Context, transferID string) (*datatx.TxInfo, error) {
+	t, err := d.store.get(transferID)
+	if err != nil {
+		return invalidTxInfo(transferID), err
+	}
+	return txInfo(t), nil
+}
+
+// CancelTransfer cancels the transfer with the specified id. The run
+// performing it stops after the file being transferred.
+func (d *driver) CancelTransfer(ctx context.Context, transferID string) (*datatx.TxInfo, error) {
+	t, err := d.store.get(transferID)
+	if err != nil {
+		return invalidTxInfo(transferID), err
+	}
+	if txEndStatuses[t.Status] {
+		return txInfo(t), errtypes.BadRequest("native: transfer already in end state")
+	}
+
+	t, err = d.store.update(transferID, func(t *transfer) {
+		t.Status = datatx.Status_STATUS_TRANSFER_CANCELLED
+	})
+	if err != nil {
+		return invalidTxInfo(transferID), err
+	}
+
+	if runner := rjobs.Default(); runner != nil && t.RunID != "" {
+		if _, err := runner.Cancel(ctx, rjobs.RunID(t.RunID)); err != nil {
+			// the run checks the status of the transfer between files anyway
+			appctx.GetLogger(ctx).Warn().Err(err).Str("transfer_id", transferID).Msg("native: error cancelling transfer run")
+		}
+	}
+	return txInfo(t), nil
+}
+
+// RetryTransfer retries the transfer with the specified id, resuming after
+// the files already transferred. Note that tokens must still be valid.
+func (d *driver) RetryTransfer(ctx context.Context, transferID string) (*datatx.TxInfo, error) {
+	t, err := d.store.get(transferID)
+	if err != nil {
+		return invalidTxInfo(transferID), err
+	}
+	if !txEndStatuses[t.Status] || t.Status == datatx.Status_STATUS_TRANSFER_COMPLETE {
+		return txInfo(t), errtypes.BadRequest("native: only failed or cancelled transfers can be retried")
+	}
+
+	t, err = d.store.update(transferID, func(t *transfer) {
+		t.Status = datatx.Status_STATUS_TRANSFER_NEW
+		t.Attempts = 0
+		t.Error = ""
+	})
+	if err != nil {
+		return invalidTxInfo(transferID), err
+	}
+	return d.enqueue(ctx, t)
+}
+
+// target is a parsed target URI.
+type target struct {
+	// url is the WebDAV url of the target, without userinfo.
+	url   string
+	path  string
+	token string
+}
+
+// parseTarget parses a target URI of form scheme://userinfo@host:port/prefix?name={path},
+// where the userinfo is the token to access the target.
+func parseTarget(targetURI string) (*target, error) {
+	if targetURI == "" {
+		return nil, errtypes.BadRequest("native: target is an empty uri")
+	}
+	u, err := url.Parse(targetURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "native: error parsing target uri")
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errtypes.BadRequest("native: target uri must be absolute: " + targetURI)
+	}
+
+	t := &target{path: u.Query().Get("name")}
+	if u.User != nil {
+		t.token = u.User.String()
+	}
+	u.User = nil
+	u.RawQuery = ""
+	t.url = u.String()
+	return t, nil
+}
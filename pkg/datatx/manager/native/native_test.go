@@ -0,0 +1,210 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package native
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/rjobs"
+)
+
+func TestParseTarget(t *testing.T) {
+	tg, err := parseTarget("https://token@example.org/prefix?name=/remote.php/webdav/dest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tg.url != "https://example.org/prefix" || tg.path != "/remote.php/webdav/dest" || tg.token != "token" {
+		t.Fatalf("parseTarget() = %+v", tg)
+	}
+
+	for _, u := range []string{"", "relative/path", "://bad"} {
+		if _, err := parseTarget(u); err == nil {
+			t.Errorf("parseTarget(%q) expected an error", u)
+		}
+	}
+}
+
+func TestChecksumsVerify(t *testing.T) {
+	sums := newChecksums()
+	if _, err := io.Copy(sums, bytes.NewBufferString("hello world")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		checksum string
+		wantErr  bool
+	}{
+		{checksum: "SHA1:2aae6c35c94fcfb415dbe95f408b9ce91ee846ed"},
+		{checksum: "md5:5eb63bbbe01eeed093cb22bb8f5acdc3"},
+		{checksum: "ADLER32:1a0b045d"},
+		{checksum: "sha1:0000000000000000000000000000000000000000", wantErr: true},
+		{checksum: ""},
+		{checksum: "crc32:00000000"},
+	}
+	for _, tt := range tests {
+		err := sums.verify(tt.checksum)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("verify(%q) error = %v, wantErr %v", tt.checksum, err, tt.wantErr)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "transfers.json")
+	s, err := newStore(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the driver and the job of a process share the store
+	if other, err := newStore(file); err != nil || other != s {
+		t.Fatalf("newStore() = %p, %v, want the shared store %p", other, err, s)
+	}
+
+	if _, err := s.get("missing"); err == nil {
+		t.Fatal("expected an error for a missing transfer")
+	}
+	if err := s.put(&transfer{TransferID: "tx", Status: datatx.Status_STATUS_TRANSFER_NEW}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.update("tx", func(t *transfer) {
+		t.Status = datatx.Status_STATUS_TRANSFER_IN_PROGRESS
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// a cancellation is not overwritten by the files completed afterwards
+	if _, err := s.update("tx", func(t *transfer) {
+		t.Status = datatx.Status_STATUS_TRANSFER_CANCELLED
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"a/b.txt", "a/c.txt"} {
+		if err := s.complete("tx", f, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := s.get("tx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != datatx.Status_STATUS_TRANSFER_CANCELLED || len(got.Completed) != 2 || got.Completed[1] != "a/c.txt" || got.BytesTransferred != 20 {
+		t.Fatalf("get() = %+v", got)
+	}
+}
+
+func TestTransferProgress(t *testing.T) {
+	tx := &transfer{Completed: []string{"a"}, FilesTotal: 4, BytesTotal: 1000}
+	now := time.Now()
+	if p := tx.progress(now); p != nil {
+		t.Fatalf("progress of a transfer that never ran = %+v", p)
+	}
+
+	// a first run moved 200 bytes in 2s, the ongoing one 100 bytes in 1s
+	tx.startClock(now)
+	tx.stopClock(now.Add(2 * time.Second))
+	tx.startClock(now.Add(10 * time.Second))
+	tx.BytesTransferred = 300
+	p := tx.progress(now.Add(11 * time.Second))
+	if p.Throughput != 100 || p.ETA != 7 {
+		t.Errorf("throughput = %v, eta = %v, want 100 and 7", p.Throughput, p.ETA)
+	}
+	if p.FilesTransferred != 1 || p.FilesTotal != 4 || p.BytesTotal != 1000 {
+		t.Errorf("progress = %+v", p)
+	}
+}
+
+func TestRunSkipsCancelledTransfer(t *testing.T) {
+	j, err := NewJob(context.Background(), map[string]any{
+		"file": filepath.Join(t.TempDir(), "transfers.json"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := j.(*job).store
+	if err := s.put(&transfer{TransferID: "tx", Status: datatx.Status_STATUS_TRANSFER_CANCELLED}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := j.Run(context.Background(), rjobs.Params{"transfer_id": "tx"}); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	got, _ := s.get("tx")
+	if got.Status != datatx.Status_STATUS_TRANSFER_CANCELLED {
+		t.Fatalf("status = %v, want %v", got.Status, datatx.Status_STATUS_TRANSFER_CANCELLED)
+	}
+}
+
+func TestDestination(t *testing.T) {
+	for _, name := range []string{"", ".", "..", "a/b", "../etc"} {
+		if validName(name) {
+			t.Fatalf("validName(%q) = true", name)
+		}
+	}
+	if !validName("file..txt") {
+		t.Fatal("validName() must accept plain names")
+	}
+
+	if p, err := destination("/home/dest", ""); err != nil || p != "/home/dest" {
+		t.Fatalf("destination() = %q, %v", p, err)
+	}
+	if p, err := destination("/home/dest", "a/b.txt"); err != nil || p != "/home/dest/a/b.txt" {
+		t.Fatalf("destination() = %q, %v", p, err)
+	}
+	for _, rel := range []string{"..", "../dest2/x", "a/../../x"} {
+		if _, err := destination("/home/dest", rel); err == nil {
+			t.Fatalf("destination(%q) must fail", rel)
+		} else if !permanent(err) {
+			t.Fatalf("destination(%q) must fail the transfer, got %v", rel, err)
+		}
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	if !permanent(errtypes.NotFound("file")) {
+		t.Error("not found errors must be permanent")
+	}
+	if permanent(errtypes.InternalError("boom")) {
+		t.Error("internal errors must be retried")
+	}
+}
+
+func TestThrottledReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 200)
+	r := newThrottledReader(context.Background(), bytes.NewReader(data), 1000)
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Fatalf("read %d bytes, want %d", n, len(data))
+	}
+	// 200 bytes at 1000 bytes/s take at least 200ms
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("throttled read took %v, expected at least 150ms", elapsed)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package native
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	txdriver "github.com/cs3org/reva/v3/pkg/datatx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/pkg/errors"
+)
+
+// transfer is the persisted state of a native transfer. It is shared by the
+// driver, which creates, cancels and reports transfers, and by the job that
+// performs them, possibly in another process of the same host.
+type transfer struct {
+	TransferID    string        `json:"transfer_id"`
+	RunID         string        `json:"run_id"`
+	Status        datatx.Status `json:"status"`
+	SrcTargetURI  string        `json:"src_target_uri"`
+	DestTargetURI string        `json:"dest_target_uri"`
+	Ctime         uint64        `json:"ctime"`
+	// Completed lists the paths, relative to the source, of the files already
+	// transferred and verified, so that a new run resumes after them, and
+	// BytesTransferred counts their bytes. Both are kept in the journal of
+	// the transfer rather than in the transfers file, see store.complete.
+	Completed        []string `json:"-"`
+	BytesTransferred uint64   `json:"-"`
+	// Attempts counts the runs that failed, to give up after max_attempts.
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+	// FilesTotal and BytesTotal describe the source, as listed by the last
+	// run.
+	FilesTotal uint64 `json:"files_total"`
+	BytesTotal uint64 `json:"bytes_total"`
+	// Elapsed is the time spent by the runs that ended, and RunStarted the
+	// start of the ongoing one, to measure the throughput.
+	Elapsed    time.Duration `json:"elapsed"`
+	RunStarted time.Time     `json:"run_started"`
+}
+
+func (t *transfer) startClock(now time.Time) {
+	t.RunStarted = now
+}
+
+func (t *transfer) stopClock(now time.Time) {
+	if !t.RunStarted.IsZero() {
+		t.Elapsed += now.Sub(t.RunStarted)
+		t.RunStarted = time.Time{}
+	}
+}
+
+// progress returns the progress of the transfer, or nil if it never ran.
+func (t *transfer) progress(now time.Time) *txdriver.Progress {
+	elapsed := t.Elapsed
+	if !t.RunStarted.IsZero() {
+		elapsed += now.Sub(t.RunStarted)
+	}
+	if elapsed == 0 {
+		return nil
+	}
+	return txdriver.NewProgress(t.BytesTransferred, t.BytesTotal, uint64(len(t.Completed)), t.FilesTotal, elapsed)
+}
+
+// store persists the transfers in a json file. The file is read again on
+// every access, as it is shared with the jobs running the transfers: the
+// accesses are serialized by the mutex of the store, which is shared by the
+// driver and the job of a process, and by a lock on the file across the
+// processes. The files completed by a run are appended to a journal per
+// transfer, so that the transfers file is not rewritten after every file.
+type store struct {
+	sync.Mutex
+	file string
+}
+
+var (
+	storesMu sync.Mutex
+	stores   = map[string]*store{}
+)
+
+// newStore returns the store of the given file, shared by all its users in
+// the process.
+func newStore(file string) (*store, error) {
+	file = filepath.Clean(file)
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	if s, ok := stores[file]; ok {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, errors.Wrap(err, "native: error creating the transfers directory")
+	}
+	if err := os.MkdirAll(file+".journal", 0700); err != nil {
+		return nil, errors.Wrap(err, "native: error creating the journals directory")
+	}
+	s := &store{file: file}
+	stores[file] = s
+	return s, nil
+}
+
+// locked runs fn holding the mutex of the store and the lock of its file.
+func (s *store) locked(fn func() error) error {
+	s.Lock()
+	defer s.Unlock()
+
+	f, err := os.OpenFile(s.file+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return errors.Wrap(err, "native: error opening the transfers lock")
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return errors.Wrap(err, "native: error locking the transfers file")
+	}
+	defer func() { _ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN) }()
+	return fn()
+}
+
+func (s *store) load() (map[string]*transfer, error) {
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]*transfer{}, nil
+		}
+		return nil, errors.Wrap(err, "native: error reading the transfers file")
+	}
+	transfers := map[string]*transfer{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &transfers); err != nil {
+			return nil, errors.Wrap(err, "native: error decoding the transfers file")
+		}
+	}
+	return transfers, nil
+}
+
+func (s *store) save(transfers map[string]*transfer) error {
+	data, err := json.Marshal(transfers)
+	if err != nil {
+		return errors.Wrap(err, "native: error encoding the transfers")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), filepath.Base(s.file)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "native: error writing the transfers file")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "native: error writing the transfers file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "native: error writing the transfers file")
+	}
+	return os.Rename(tmp.Name(), s.file)
+}
+
+// journalEntry is a line of the journal of a transfer.
+type journalEntry struct {
+	Path string `json:"path"`
+	Size uint64 `json:"size"`
+}
+
+func (s *store) journal(id string) string {
+	return filepath.Join(s.file+".journal", id)
+}
+
+// readJournal fills the files completed by the transfer from its journal.
+func (s *store) readJournal(t *transfer) error {
+	f, err := os.Open(s.journal(t.TransferID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "native: error reading the transfer journal")
+	}
+	defer f.Close()
+
+	t.Completed, t.BytesTransferred = nil, 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e journalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// a line torn by a crash, the file is transferred again
+			continue
+		}
+		t.Completed = append(t.Completed, e.Path)
+		t.BytesTransferred += e.Size
+	}
+	return errors.Wrap(sc.Err(), "native: error reading the transfer journal")
+}
+
+// get returns the transfer with the given id.
+func (s *store) get(id string) (*transfer, error) {
+	var t *transfer
+	err := s.locked(func() error {
+		transfers, err := s.load()
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if t, ok = transfers[id]; !ok {
+			return errtypes.NotFound("native: transfer " + id)
+		}
+		return s.readJournal(t)
+	})
+	return t, err
+}
+
+// put stores the given transfer.
+func (s *store) put(t *transfer) error {
+	return s.locked(func() error {
+		transfers, err := s.load()
+		if err != nil {
+			return err
+		}
+		transfers[t.TransferID] = t
+		return s.save(transfers)
+	})
+}
+
+// update applies fn to the stored transfer with the given id and persists
+// the result, so that concurrent updates of other fields are not lost.
+func (s *store) update(id string, fn func(t *transfer)) (*transfer, error) {
+	var t *transfer
+	err := s.locked(func() error {
+		transfers, err := s.load()
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if t, ok = transfers[id]; !ok {
+			return errtypes.NotFound("native: transfer " + id)
+		}
+		if err := s.readJournal(t); err != nil {
+			return err
+		}
+		fn(t)
+		return s.save(transfers)
+	})
+	return t, err
+}
+
+// complete records a file transferred by the transfer with the given id,
+// appending it to the journal of the transfer.
+func (s *store) complete(id, rel string, size int64) error {
+	return s.locked(func() error {
+		line, err := json.Marshal(journalEntry{Path: rel, Size: uint64(size)})
+		if err != nil {
+			return errors.Wrap(err, "native: error encoding the transfer journal")
+		}
+		f, err := os.OpenFile(s.journal(id), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return errors.Wrap(err, "native: error opening the transfer journal")
+		}
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			f.Close()
+			return errors.Wrap(err, "native: error writing the transfer journal")
+		}
+		return f.Close()
+	})
+}
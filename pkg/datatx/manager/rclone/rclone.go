@@ -533,6 +533,14 @@ func (driver *rclone) startJob(ctx context.Context, transferID string, srcRemote
 				break
 			}
 
+			// the stats are only informative: failing to get them does not
+			// affect the transfer
+			if progress, err := driver.jobStats(jobID); err != nil {
+				logger.Warn().Err(err).Msgf("rclone driver: error getting the stats of transfer job %v", jobID)
+			} else {
+				job.Progress = progress
+			}
+
 			if resData.Error != "" {
 				logger.Error().Err(err).Msgf("rclone driver: rclone responded with error: %v", resData.Error)
 				job.TransferStatus = datatx.Status_STATUS_TRANSFER_FAILED
@@ -597,11 +605,15 @@ func (driver *rclone) GetTransferStatus(ctx context.Context, transferID string)
 		}, err
 	}
 	cTime, _ := strconv.ParseInt(job.Ctime, 10, 64)
-	return &datatx.TxInfo{
+	txInfo := &datatx.TxInfo{
 		Id:     &datatx.TxId{OpaqueId: transferID},
 		Status: job.TransferStatus,
 		Ctime:  &typespb.Timestamp{Seconds: uint64(cTime)},
-	}, nil
+	}
+	if err := txdriver.SetProgress(txInfo, job.Progress); err != nil {
+		return txInfo, err
+	}
+	return txInfo, nil
 }
 
 // CancelTransfer cancels the transfer with the specified transfer id.
@@ -842,6 +854,73 @@ func (driver *rclone) remotePathIsFolder(remote string, remotePath string, remot
 	return true, nil
 }
 
+// jobStats returns the progress of the job with the given id, harvested
+// from the stats rclone keeps for the group of the job.
+func (driver *rclone) jobStats(jobID int64) (*txdriver.Progress, error) {
+	type rcloneStatsReqJSON struct {
+		Group string `json:"group"`
+	}
+	data, err := json.Marshal(&rcloneStatsReqJSON{Group: fmt.Sprintf("job/%d", jobID)})
+	if err != nil {
+		return nil, errors.Wrap(err, "rclone driver: error marshalling rclone req data")
+	}
+
+	statsMethod := "/core/stats"
+
+	u, err := url.Parse(driver.config.Endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "rclone driver: error parsing driver endpoint")
+	}
+	u.Path = path.Join(u.Path, statsMethod)
+	requestURL := u.String()
+
+	req, err := http.NewRequest(http.MethodPost, requestURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "rclone driver: error framing post request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(driver.config.AuthUser, driver.config.AuthPass)
+
+	res, err := driver.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "rclone driver: error sending post request")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var errorResData rcloneHTTPErrorRes
+		if err = json.NewDecoder(res.Body).Decode(&errorResData); err != nil {
+			return nil, errors.Wrap(err, "rclone driver: error decoding response data")
+		}
+		return nil, errors.Wrap(errors.Errorf("status: %v, error: %v", errorResData.Status, errorResData.Error), "rclone driver: rclone request responded with error")
+	}
+
+	type rcloneStatsResJSON struct {
+		Bytes          uint64   `json:"bytes"`
+		TotalBytes     uint64   `json:"totalBytes"`
+		Transfers      uint64   `json:"transfers"`
+		TotalTransfers uint64   `json:"totalTransfers"`
+		Speed          float64  `json:"speed"`
+		ETA            *float64 `json:"eta"` // null when unknown
+	}
+	var resData rcloneStatsResJSON
+	if err = json.NewDecoder(res.Body).Decode(&resData); err != nil {
+		return nil, errors.Wrap(err, "rclone driver: error decoding response data")
+	}
+
+	p := &txdriver.Progress{
+		BytesTransferred: resData.Bytes,
+		BytesTotal:       resData.TotalBytes,
+		FilesTransferred: resData.Transfers,
+		FilesTotal:       resData.TotalTransfers,
+		Throughput:       resData.Speed,
+	}
+	if resData.ETA != nil && *resData.ETA > 0 {
+		p.ETA = uint64(*resData.ETA)
+	}
+	return p, nil
+}
+
 func (driver *rclone) extractEndpointInfo(ctx context.Context, targetURL string) (*endpoint, error) {
 	if targetURL == "" {
 		return nil, errtypes.BadRequest("datatx service: ref target is an empty uri")
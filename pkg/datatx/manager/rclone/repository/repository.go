@@ -20,6 +20,7 @@ package repository
 
 import (
 	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	txdriver "github.com/cs3org/reva/v3/pkg/datatx"
 )
 
 // Job represents transfer job.
@@ -34,6 +35,8 @@ type Job struct {
 	DestRemote     string
 	DestPath       string
 	Ctime          string
+	// Progress is the last progress harvested from the job stats.
+	Progress *txdriver.Progress
 }
 
 // Repository the interface that any storage driver should implement.
@@ -0,0 +1,98 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package datatx
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/pkg/errors"
+)
+
+// progressOpaqueKey is the key of the TxInfo opaque carrying the progress.
+const progressOpaqueKey = "progress"
+
+// Progress holds the counters of a transfer.
+type Progress struct {
+	BytesTransferred uint64 `json:"bytes_transferred"`
+	BytesTotal       uint64 `json:"bytes_total"`
+	FilesTransferred uint64 `json:"files_transferred"`
+	FilesTotal       uint64 `json:"files_total"`
+	// Throughput is the average throughput in bytes per second.
+	Throughput float64 `json:"throughput"`
+	// ETA is the estimated time to completion in seconds, 0 when unknown.
+	ETA uint64 `json:"eta"`
+}
+
+// NewProgress returns the progress of a transfer that moved the given
+// amounts in the elapsed time, estimating its throughput and ETA.
+func NewProgress(bytesTransferred, bytesTotal, filesTransferred, filesTotal uint64, elapsed time.Duration) *Progress {
+	p := &Progress{
+		BytesTransferred: bytesTransferred,
+		BytesTotal:       bytesTotal,
+		FilesTransferred: filesTransferred,
+		FilesTotal:       filesTotal,
+	}
+	if elapsed > 0 {
+		p.Throughput = float64(bytesTransferred) / elapsed.Seconds()
+	}
+	if p.Throughput > 0 && bytesTotal > bytesTransferred {
+		p.ETA = uint64(math.Ceil(float64(bytesTotal-bytesTransferred) / p.Throughput))
+	}
+	return p
+}
+
+// SetProgress stores the progress in the opaque of the TxInfo.
+func SetProgress(info *datatx.TxInfo, p *Progress) error {
+	if p == nil {
+		return nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return errors.Wrap(err, "datatx: error encoding progress")
+	}
+	if info.Opaque == nil {
+		info.Opaque = &types.Opaque{}
+	}
+	if info.Opaque.Map == nil {
+		info.Opaque.Map = map[string]*types.OpaqueEntry{}
+	}
+	info.Opaque.Map[progressOpaqueKey] = &types.OpaqueEntry{Decoder: "json", Value: b}
+	return nil
+}
+
+// GetProgress returns the progress stored in the opaque of the TxInfo,
+// or nil if the driver did not report any.
+func GetProgress(info *datatx.TxInfo) (*Progress, error) {
+	e, ok := info.GetOpaque().GetMap()[progressOpaqueKey]
+	if !ok {
+		return nil, nil
+	}
+	if e.Decoder != "json" {
+		return nil, errors.New("datatx: unsupported progress decoder " + e.Decoder)
+	}
+	var p Progress
+	if err := json.Unmarshal(e.Value, &p); err != nil {
+		return nil, errors.Wrap(err, "datatx: error decoding progress")
+	}
+	return &p, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package datatx
+
+import (
+	"testing"
+	"time"
+
+	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+)
+
+func TestNewProgress(t *testing.T) {
+	tests := []struct {
+		name       string
+		done       uint64
+		total      uint64
+		elapsed    time.Duration
+		throughput float64
+		eta        uint64
+	}{
+		{name: "halfway", done: 500, total: 1000, elapsed: 5 * time.Second, throughput: 100, eta: 5},
+		{name: "rounds eta up", done: 300, total: 1000, elapsed: 2 * time.Second, throughput: 150, eta: 5},
+		{name: "complete", done: 1000, total: 1000, elapsed: 10 * time.Second, throughput: 100, eta: 0},
+		{name: "not started", done: 0, total: 1000, elapsed: 0, throughput: 0, eta: 0},
+		{name: "unknown total", done: 100, total: 0, elapsed: time.Second, throughput: 100, eta: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProgress(tt.done, tt.total, 1, 2, tt.elapsed)
+			if p.Throughput != tt.throughput {
+				t.Errorf("throughput = %v, want %v", p.Throughput, tt.throughput)
+			}
+			if p.ETA != tt.eta {
+				t.Errorf("eta = %v, want %v", p.ETA, tt.eta)
+			}
+		})
+	}
+}
+
+func TestProgressOpaque(t *testing.T) {
+	info := &datatx.TxInfo{}
+	if p, err := GetProgress(info); err != nil || p != nil {
+		t.Fatalf("got %v, %v, want no progress", p, err)
+	}
+
+	want := NewProgress(10, 20, 1, 2, time.Second)
+	if err := SetProgress(info, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetProgress(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -130,6 +130,8 @@ func (m *mgr) ListTransfers(filters []*txv1beta.ListTransfersRequest_Filter, use
 					DestTargetURI: transfer.DestTargetURI,
 					ShareID:       transfer.ShareID,
 					UserID:        transfer.UserID,
+					Status:        transfer.Status,
+					Progress:      transfer.Progress,
 				})
 			} else {
 				for _, f := range filters {
@@ -141,6 +143,8 @@ func (m *mgr) ListTransfers(filters []*txv1beta.ListTransfersRequest_Filter, use
 								DestTargetURI: transfer.DestTargetURI,
 								ShareID:       transfer.ShareID,
 								UserID:        transfer.UserID,
+								Status:        transfer.Status,
+								Progress:      transfer.Progress,
 							})
 						}
 					}
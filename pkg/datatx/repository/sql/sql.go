@@ -0,0 +1,224 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"fmt"
+
+	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	txv1beta "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	"github.com/cs3org/reva/v3/cmd/revad/pkg/config"
+	"github.com/cs3org/reva/v3/pkg/datatx"
+	"github.com/cs3org/reva/v3/pkg/datatx/repository/registry"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func init() {
+	registry.Register("sql", New)
+}
+
+type Config struct {
+	config.Database `mapstructure:",squash"`
+}
+
+func (c *Config) ApplyDefaults() {
+	c.Database = sharedconf.GetDBInfo(c.Database)
+}
+
+type mgr struct {
+	c  *Config
+	db *gorm.DB
+}
+
+// Transfer is the row of a transfer, with the last status and progress
+// reported by the driver.
+type Transfer struct {
+	gorm.Model
+	TxID             string `gorm:"size:255;uniqueIndex:i_tx_id"`
+	SrcTargetURI     string
+	DestTargetURI    string
+	ShareID          string `gorm:"size:255;index:i_share_id"`
+	UserIdp          string `gorm:"size:255"`
+	UserOpaqueID     string `gorm:"size:255;index:i_user_opaque_id"`
+	UserType         int32
+	Status           int32
+	HasProgress      bool
+	BytesTransferred uint64
+	BytesTotal       uint64
+	FilesTransferred uint64
+	FilesTotal       uint64
+	Throughput       float64
+	ETA              uint64
+}
+
+// New returns a sql storage driver.
+func New(ctx context.Context, m map[string]any) (datatx.Repository, error) {
+	var c Config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+	c.ApplyDefaults()
+
+	var db *gorm.DB
+	var err error
+	switch c.Engine {
+	case "sqlite":
+		db, err = gorm.Open(sqlite.Open(c.DBName), &gorm.Config{})
+	default: // default is mysql
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
+		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "datatx repository sql driver: error connecting to the database using engine "+c.Engine)
+	}
+
+	if err := db.AutoMigrate(&Transfer{}); err != nil {
+		return nil, errors.Wrap(err, "datatx repository sql driver: error migrating the transfer schema")
+	}
+
+	return &mgr{
+		c:  &c,
+		db: db,
+	}, nil
+}
+
+func (m *mgr) StoreTransfer(transfer *datatx.Transfer) error {
+	res := m.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tx_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"src_target_uri", "dest_target_uri", "share_id",
+			"user_idp", "user_opaque_id", "user_type", "status", "has_progress",
+			"bytes_transferred", "bytes_total", "files_transferred", "files_total",
+			"throughput", "eta", "updated_at",
+		}),
+	}).Create(toModel(transfer))
+	if res.Error != nil {
+		return errors.Wrap(res.Error, "datatx repository sql driver: error storing transfer")
+	}
+	return nil
+}
+
+func (m *mgr) DeleteTransfer(transfer *datatx.Transfer) error {
+	// the row is removed for good, a soft-deleted row would collide
+	// with the unique index when storing the transfer again
+	res := m.db.Unscoped().Where("tx_id = ?", transfer.TxID).Delete(&Transfer{})
+	if res.Error != nil {
+		return errors.Wrap(res.Error, "datatx repository sql driver: error deleting transfer")
+	}
+	return nil
+}
+
+func (m *mgr) GetTransfer(txID string) (*datatx.Transfer, error) {
+	var t Transfer
+	if err := m.db.Where("tx_id = ?", txID).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errtypes.NotFound("datatx repository sql driver: transfer " + txID)
+		}
+		return nil, errors.Wrap(err, "datatx repository sql driver: error getting transfer")
+	}
+	return fromModel(&t), nil
+}
+
+func (m *mgr) ListTransfers(filters []*txv1beta.ListTransfersRequest_Filter, userID *userv1beta1.UserId) ([]*datatx.Transfer, error) {
+	if userID == nil {
+		return nil, errors.New("datatx repository sql driver: error listing transfers, userID must be provided")
+	}
+	query := m.db.Model(&Transfer{}).Where("user_opaque_id = ?", userID.OpaqueId)
+
+	var shareIDs []string
+	for _, f := range filters {
+		if f.Type == txv1beta.ListTransfersRequest_Filter_TYPE_SHARE_ID {
+			shareIDs = append(shareIDs, f.GetShareId().GetOpaqueId())
+		}
+	}
+	if len(filters) > 0 {
+		// as in the json driver, only the share id filters select transfers
+		if len(shareIDs) == 0 {
+			return nil, nil
+		}
+		query = query.Where("share_id IN ?", shareIDs)
+	}
+
+	var rows []Transfer
+	if err := query.Order("id").Find(&rows).Error; err != nil {
+		return nil, errors.Wrap(err, "datatx repository sql driver: error listing transfers")
+	}
+	transfers := make([]*datatx.Transfer, 0, len(rows))
+	for i := range rows {
+		transfers = append(transfers, fromModel(&rows[i]))
+	}
+	return transfers, nil
+}
+
+func toModel(t *datatx.Transfer) *Transfer {
+	row := &Transfer{
+		TxID:          t.TxID,
+		SrcTargetURI:  t.SrcTargetURI,
+		DestTargetURI: t.DestTargetURI,
+		ShareID:       t.ShareID,
+		UserIdp:       t.UserID.GetIdp(),
+		UserOpaqueID:  t.UserID.GetOpaqueId(),
+		UserType:      int32(t.UserID.GetType()),
+		Status:        int32(t.Status),
+	}
+	if p := t.Progress; p != nil {
+		row.HasProgress = true
+		row.BytesTransferred = p.BytesTransferred
+		row.BytesTotal = p.BytesTotal
+		row.FilesTransferred = p.FilesTransferred
+		row.FilesTotal = p.FilesTotal
+		row.Throughput = p.Throughput
+		row.ETA = p.ETA
+	}
+	return row
+}
+
+func fromModel(row *Transfer) *datatx.Transfer {
+	t := &datatx.Transfer{
+		TxID:          row.TxID,
+		SrcTargetURI:  row.SrcTargetURI,
+		DestTargetURI: row.DestTargetURI,
+		ShareID:       row.ShareID,
+		UserID: &userv1beta1.UserId{
+			Idp:      row.UserIdp,
+			OpaqueId: row.UserOpaqueID,
+			Type:     userv1beta1.UserType(row.UserType),
+		},
+		Status: txv1beta.Status(row.Status),
+	}
+	if row.HasProgress {
+		t.Progress = &datatx.Progress{
+			BytesTransferred: row.BytesTransferred,
+			BytesTotal:       row.BytesTotal,
+			FilesTransferred: row.FilesTransferred,
+			FilesTotal:       row.FilesTotal,
+			Throughput:       row.Throughput,
+			ETA:              row.ETA,
+		}
+	}
+	return t
+}
@@ -0,0 +1,116 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	txv1beta "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/datatx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+)
+
+func newTestRepository(t *testing.T) datatx.Repository {
+	t.Helper()
+	r, err := New(context.Background(), map[string]any{
+		"db_engine": "sqlite",
+		"db_name":   filepath.Join(t.TempDir(), "datatx.db"),
+	})
+	if err != nil {
+		t.Fatalf("creating repository: %v", err)
+	}
+	return r
+}
+
+func TestTransfers(t *testing.T) {
+	r := newTestRepository(t)
+	einstein := &userv1beta1.UserId{Idp: "cernbox.cern.ch", OpaqueId: "einstein"}
+	marie := &userv1beta1.UserId{Idp: "cernbox.cern.ch", OpaqueId: "marie"}
+
+	for _, tx := range []*datatx.Transfer{
+		{TxID: "tx-1", ShareID: "share-1", UserID: einstein, Status: txv1beta.Status_STATUS_TRANSFER_NEW},
+		{TxID: "tx-2", ShareID: "share-2", UserID: einstein, Status: txv1beta.Status_STATUS_TRANSFER_NEW},
+		{TxID: "tx-3", ShareID: "share-1", UserID: marie, Status: txv1beta.Status_STATUS_TRANSFER_NEW},
+	} {
+		if err := r.StoreTransfer(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// storing again updates status and progress
+	progress := &datatx.Progress{BytesTransferred: 512, BytesTotal: 1024, FilesTransferred: 1, FilesTotal: 2, Throughput: 256, ETA: 2}
+	if err := r.StoreTransfer(&datatx.Transfer{
+		TxID: "tx-1", ShareID: "share-1", UserID: einstein,
+		Status: txv1beta.Status_STATUS_TRANSFER_IN_PROGRESS, Progress: progress,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.GetTransfer("tx-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != txv1beta.Status_STATUS_TRANSFER_IN_PROGRESS {
+		t.Errorf("status = %v, want in progress", got.Status)
+	}
+	if got.Progress == nil || *got.Progress != *progress {
+		t.Errorf("progress = %+v, want %+v", got.Progress, progress)
+	}
+	if got.UserID.Idp != einstein.Idp || got.UserID.OpaqueId != einstein.OpaqueId {
+		t.Errorf("user = %v, want %v", got.UserID, einstein)
+	}
+
+	list, err := r.ListTransfers(nil, einstein)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("got %d transfers, want 2", len(list))
+	}
+	if list[1].Progress != nil {
+		t.Errorf("unexpected progress for %s: %+v", list[1].TxID, list[1].Progress)
+	}
+
+	list, err = r.ListTransfers([]*txv1beta.ListTransfersRequest_Filter{{
+		Type: txv1beta.ListTransfersRequest_Filter_TYPE_SHARE_ID,
+		Term: &txv1beta.ListTransfersRequest_Filter_ShareId{ShareId: &ocm.ShareId{OpaqueId: "share-1"}},
+	}}, einstein)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].TxID != "tx-1" {
+		t.Errorf("got %v, want tx-1 only", list)
+	}
+
+	if err := r.DeleteTransfer(&datatx.Transfer{TxID: "tx-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetTransfer("tx-1"); err == nil {
+		t.Error("expected an error getting a deleted transfer")
+	} else if _, ok := err.(errtypes.IsNotFound); !ok {
+		t.Errorf("got %v, want not found", err)
+	}
+	// a deleted transfer can be stored again
+	if err := r.StoreTransfer(&datatx.Transfer{TxID: "tx-1", UserID: einstein}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package digest defines the queue of the notifications waiting to be sent
+// to their recipients in a periodic digest.
+package digest
+
+import (
+	"context"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/notification/preference"
+)
+
+// Item is a notification waiting to be sent in a digest.
+type Item struct {
+	Template string
+	Ref      string
+	Subject  string
+	Time     time.Time
+	Data     map[string]any
+}
+
+// Manager is the interface digest drivers have to implement. The queue is
+// shared by all the replicas of the notifications service, so that a
+// notification is sent in a single digest.
+type Manager interface {
+	// Add queues notifications for the digest of the given frequency of a recipient.
+	Add(ctx context.Context, f preference.Frequency, recipient string, items ...*Item) error
+	// Take removes and returns the notifications queued for the digests of
+	// the given frequency, by recipient.
+	Take(ctx context.Context, f preference.Frequency) (map[string][]*Item, error)
+}
@@ -0,0 +1,160 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cs3org/reva/v3/cmd/revad/pkg/config"
+	"github.com/cs3org/reva/v3/pkg/notification/digest"
+	"github.com/cs3org/reva/v3/pkg/notification/digest/registry"
+	"github.com/cs3org/reva/v3/pkg/notification/preference"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func init() {
+	registry.Register("sql", New)
+}
+
+type Config struct {
+	config.Database `mapstructure:",squash"`
+}
+
+func (c *Config) ApplyDefaults() {
+	c.Database = sharedconf.GetDBInfo(c.Database)
+}
+
+type mgr struct {
+	db *gorm.DB
+	// lock tells whether the engine supports locking the rows being taken
+	lock bool
+}
+
+// DigestItem is the database model of a notification waiting for a digest.
+type DigestItem struct {
+	ID        uint   `gorm:"primarykey"`
+	Frequency string `gorm:"size:32;index:i_frequency"`
+	Recipient string `gorm:"size:320"`
+	Template  string `gorm:"size:320"`
+	Ref       string `gorm:"size:3072"`
+	Subject   string
+	Time      time.Time
+	Data      string
+}
+
+// New returns an instance of the sql digest driver.
+func New(ctx context.Context, m map[string]any) (digest.Manager, error) {
+	var c Config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	var db *gorm.DB
+	var err error
+	switch c.Engine {
+	case "sqlite":
+		db, err = gorm.Open(sqlite.Open(c.DBName), &gorm.Config{})
+	default: // default is mysql
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
+		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to the notification digest database using engine "+c.Engine)
+	}
+
+	if err := db.AutoMigrate(&DigestItem{}); err != nil {
+		return nil, errors.Wrap(err, "failed to migrate the DigestItem schema")
+	}
+
+	return &mgr{db: db, lock: c.Engine != "sqlite"}, nil
+}
+
+func (m *mgr) Add(ctx context.Context, f preference.Frequency, recipient string, items ...*digest.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+	rows := make([]*DigestItem, 0, len(items))
+	for _, i := range items {
+		data, err := json.Marshal(i.Data)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, &DigestItem{
+			Frequency: string(f),
+			Recipient: recipient,
+			Template:  i.Template,
+			Ref:       i.Ref,
+			Subject:   i.Subject,
+			Time:      i.Time,
+			Data:      string(data),
+		})
+	}
+	return m.db.WithContext(ctx).Create(rows).Error
+}
+
+func (m *mgr) Take(ctx context.Context, f preference.Frequency) (map[string][]*digest.Item, error) {
+	var rows []*DigestItem
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("frequency = ?", string(f)).Order("id")
+		if m.lock {
+			// the replicas sending the digests at the same
+			// time must not take the same notifications
+			query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		if err := query.Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		ids := make([]uint, 0, len(rows))
+		for _, r := range rows {
+			ids = append(ids, r.ID)
+		}
+		return tx.Delete(&DigestItem{}, ids).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[string][]*digest.Item)
+	for _, r := range rows {
+		var data map[string]any
+		if err := json.Unmarshal([]byte(r.Data), &data); err != nil {
+			return nil, err
+		}
+		items[r.Recipient] = append(items[r.Recipient], &digest.Item{
+			Template: r.Template,
+			Ref:      r.Ref,
+			Subject:  r.Subject,
+			Time:     r.Time,
+			Data:     data,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/notification/digest"
+	"github.com/cs3org/reva/v3/pkg/notification/preference"
+)
+
+func TestDigestQueue(t *testing.T) {
+	ctx := context.Background()
+	dbName := filepath.Join(t.TempDir(), "digest.sqlite")
+	conf := map[string]any{"db_engine": "sqlite", "db_name": dbName}
+	mgr, err := New(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0).UTC()
+	if err := mgr.Add(ctx, preference.FrequencyDaily, "einstein@example.org",
+		&digest.Item{Template: "share-create-mail", Ref: "1", Subject: "first", Time: now, Data: map[string]any{"path": "/a"}},
+		&digest.Item{Template: "share-create-mail", Ref: "2", Subject: "second", Time: now},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Add(ctx, preference.FrequencyHourly, "marie@example.org", &digest.Item{Subject: "hourly", Time: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the queue survives a restart
+	mgr, err = New(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	daily, err := mgr.Take(ctx, preference.FrequencyDaily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := daily["einstein@example.org"]
+	if len(daily) != 1 || len(items) != 2 || items[0].Subject != "first" || items[1].Subject != "second" {
+		t.Fatalf("unexpected daily digests %+v", daily)
+	}
+	if items[0].Data["path"] != "/a" || !items[0].Time.Equal(now) {
+		t.Fatalf("unexpected item %+v", items[0])
+	}
+
+	// taken notifications are not sent again
+	if daily, _ := mgr.Take(ctx, preference.FrequencyDaily); len(daily) != 0 {
+		t.Fatalf("expected the daily digests to be taken, got %+v", daily)
+	}
+	if hourly, _ := mgr.Take(ctx, preference.FrequencyHourly); len(hourly["marie@example.org"]) != 1 {
+		t.Fatalf("unexpected hourly digests %+v", hourly)
+	}
+}
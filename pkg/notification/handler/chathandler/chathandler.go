@@ -0,0 +1,146 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package chathandler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/notification/handler"
+	"github.com/cs3org/reva/v3/pkg/notification/handler/registry"
+	"github.com/cs3org/reva/v3/pkg/notification/handler/webhookhandler"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+func init() {
+	registry.Register("chat", New)
+}
+
+// ChatHandler is the notification handler posting the notifications to the
+// incoming webhooks of Mattermost, Slack and compatible chat services.
+type ChatHandler struct {
+	conf   *config
+	log    *zerolog.Logger
+	poster *webhookhandler.Poster
+}
+
+type config struct {
+	URL          string `docs:";The URL of the incoming webhook."                                          mapstructure:"url"           validate:"required"`
+	Format       string `docs:"mattermost;The markup of the messages, either mattermost or slack."         mapstructure:"format"`
+	Channel      string `docs:";The channel to post to. If empty, the default one of the webhook is used." mapstructure:"channel"`
+	Username     string `docs:"Reva;The name the messages are posted with."                                mapstructure:"username"`
+	IconURL      string `docs:";The URL of the icon the messages are posted with."                         mapstructure:"icon_url"`
+	Timeout      int    `docs:"10;Timeout in seconds of a request."                                       mapstructure:"timeout"`
+	MaxRetries   int    `docs:"3;Number of times a failed request is retried."                            mapstructure:"max_retries"`
+	RetryBackoff int    `docs:"1;Seconds to wait before the first retry, doubled at each further retry." mapstructure:"retry_backoff"`
+	Insecure     bool   `docs:"false;Whether to skip the verification of the TLS certificate."            mapstructure:"insecure"`
+}
+
+func (c *config) ApplyDefaults() {
+	if c.Format == "" {
+		c.Format = "mattermost"
+	}
+	if c.Username == "" {
+		c.Username = "Reva"
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 10
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.RetryBackoff == 0 {
+		c.RetryBackoff = 1
+	}
+}
+
+type payload struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+	IconURL  string `json:"icon_url,omitempty"`
+}
+
+// New returns a new chat handler.
+func New(ctx context.Context, m map[string]any) (handler.Handler, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+	if c.Format != "mattermost" && c.Format != "slack" {
+		return nil, fmt.Errorf("chat: unknown format %s", c.Format)
+	}
+
+	return &ChatHandler{
+		conf:   &c,
+		log:    appctx.GetLogger(ctx),
+		poster: webhookhandler.NewPoster(time.Duration(c.Timeout)*time.Second, c.Insecure, c.MaxRetries, time.Duration(c.RetryBackoff)*time.Second),
+	}, nil
+}
+
+// Send posts a notification. The recipient is ignored, as the messages
+// are posted to a channel.
+func (h *ChatHandler) Send(sender, recipient, subject, body string) error {
+	return h.SendMessage(&handler.Message{Subject: subject, Body: body})
+}
+
+// SendMessage posts a notification once to the channel, whatever the
+// number of its recipients. The templates of the notifications sent
+// to chats are expected to render plain text or markdown.
+func (h *ChatHandler) SendMessage(m *handler.Message) error {
+	b, err := json.Marshal(&payload{
+		Text:     h.text(m.Subject, m.Body),
+		Channel:  h.conf.Channel,
+		Username: h.conf.Username,
+		IconURL:  h.conf.IconURL,
+	})
+	if err != nil {
+		return errors.Wrap(err, "chat: error encoding message")
+	}
+
+	if err := h.poster.Post(context.Background(), h.conf.URL, http.Header{}, b); err != nil {
+		return err
+	}
+	h.log.Debug().Str("ref", m.Ref).Msg("chat: notification delivered")
+	return nil
+}
+
+func (h *ChatHandler) text(subject, body string) string {
+	subject = strings.TrimSpace(subject)
+	body = strings.TrimSpace(body)
+	if subject == "" {
+		return body
+	}
+	if h.conf.Format == "slack" {
+		subject = "*" + subject + "*"
+	} else {
+		subject = "**" + subject + "**"
+	}
+	if body == "" {
+		return subject
+	}
+	return subject + "\n" + body
+}
@@ -0,0 +1,109 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package chathandler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cs3org/reva/v3/pkg/notification/handler"
+)
+
+func TestSendMessage(t *testing.T) {
+	tests := []struct {
+		format string
+		text   string
+	}{
+		{"mattermost", "**Share created**\nalice shared /home/docs with you"},
+		{"slack", "*Share created*\nalice shared /home/docs with you"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				var p payload
+				if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+					t.Errorf("error decoding payload: %v", err)
+				}
+				if p.Text != tt.text || p.Channel != "town-square" || p.Username != "Reva" {
+					t.Errorf("unexpected payload %+v", p)
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			h, err := New(context.Background(), map[string]any{
+				"url":     srv.URL,
+				"format":  tt.format,
+				"channel": "town-square",
+			})
+			if err != nil {
+				t.Fatalf("New returned error: %v", err)
+			}
+
+			// a single post whatever the number of recipients
+			err = h.(handler.StructuredHandler).SendMessage(&handler.Message{
+				Subject:    " Share created ",
+				Body:       "alice shared /home/docs with you\n",
+				Recipients: []string{"einstein@example.org", "marie@example.org"},
+			})
+			if err != nil {
+				t.Fatalf("SendMessage returned error: %v", err)
+			}
+			if calls.Load() != 1 {
+				t.Errorf("expected one request, got %d", calls.Load())
+			}
+		})
+	}
+}
+
+func TestSendMessageError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	h, err := New(context.Background(), map[string]any{"url": srv.URL})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if err := h.Send("", "", "Share created", "body"); err == nil {
+		t.Fatal("expected the refused post to fail")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected the permanent failure not to be retried, got %d requests", calls.Load())
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(context.Background(), map[string]any{"url": "http://localhost", "format": "teams"}); err == nil {
+		t.Error("expected an unknown format to be refused")
+	}
+	if _, err := New(context.Background(), map[string]any{}); err == nil {
+		t.Error("expected the url to be required")
+	}
+}
@@ -18,7 +18,33 @@
 
 package handler
 
+import "time"
+
 // Handler is the interface notification handlers have to implement.
 type Handler interface {
 	Send(sender, recipient, subject, body string) error
 }
+
+// Message is a notification with the data of its trigger, for the handlers
+// that do not only deliver a rendered subject and body.
+type Message struct {
+	// Template is the name of the template of the notification.
+	Template string `json:"template"`
+	// Ref is the reference of the notification.
+	Ref        string    `json:"ref"`
+	Sender     string    `json:"sender,omitempty"`
+	Recipients []string  `json:"recipients"`
+	Subject    string    `json:"subject"`
+	Body       string    `json:"body"`
+	Time       time.Time `json:"time"`
+	// Data is the template data of the trigger.
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// StructuredHandler is implemented by the handlers receiving the whole
+// notification at once instead of a rendered message per recipient.
+type StructuredHandler interface {
+	Handler
+	// SendMessage delivers the notification.
+	SendMessage(m *Message) error
+}
@@ -20,6 +20,8 @@ package loader
 
 import (
 	// Load notification handlers.
+	_ "github.com/cs3org/reva/v3/pkg/notification/handler/chathandler"
 	_ "github.com/cs3org/reva/v3/pkg/notification/handler/emailhandler"
+	_ "github.com/cs3org/reva/v3/pkg/notification/handler/webhookhandler"
 	// Add your own here.
 )
@@ -39,25 +39,35 @@ func Register(name string, f NewHandlerFunc) {
 }
 
 // InitHandlers initializes the notification handlers with the configuration
-// and the log from a service.
+// and the log from a service. The configuration is keyed by the name the
+// templates refer to the handler with, which is also the name the handler
+// is registered with unless the `type` option is set. This allows to
+// configure, for example, several webhooks for different templates.
 func InitHandlers(ctx context.Context, handlerConf map[string]map[string]any) map[string]handler.Handler {
 	handlers := make(map[string]handler.Handler)
 	hCount := 0
 
 	log := appctx.GetLogger(ctx)
-	for n, f := range NewHandlerFuncs {
-		if c, ok := handlerConf[n]; ok {
-			l := log.With().Str("service", n).Logger()
-			ctx := appctx.WithLogger(ctx, &l)
-			nh, err := f(ctx, c)
-			if err != nil {
-				log.Err(err).Msgf("error initializing notification handler %s", n)
-			}
-			handlers[n] = nh
-			hCount++
-		} else {
-			log.Warn().Msgf("missing config for notification handler %s", n)
+	for n, c := range handlerConf {
+		t := n
+		if v, ok := c["type"].(string); ok && v != "" {
+			t = v
 		}
+		f, ok := NewHandlerFuncs[t]
+		if !ok {
+			log.Warn().Msgf("unknown type %s for notification handler %s", t, n)
+			continue
+		}
+
+		l := log.With().Str("service", n).Logger()
+		ctx := appctx.WithLogger(ctx, &l)
+		nh, err := f(ctx, c)
+		if err != nil {
+			log.Err(err).Msgf("error initializing notification handler %s", n)
+			continue
+		}
+		handlers[n] = nh
+		hCount++
 	}
 	log.Info().Msgf("%d handlers initialized", hCount)
 
@@ -0,0 +1,209 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package webhookhandler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/notification/handler"
+	"github.com/cs3org/reva/v3/pkg/notification/handler/registry"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 signature of the timestamp
+	// and of the body of a request, as "sha256=<hex digest>".
+	SignatureHeader = "X-Reva-Signature"
+	// TimestampHeader carries the unix time the request was signed at,
+	// for the receivers to reject replayed requests.
+	TimestampHeader = "X-Reva-Timestamp"
+)
+
+func init() {
+	registry.Register("webhook", New)
+}
+
+// WebhookHandler is the notification handler posting the notifications
+// as JSON documents to a webhook.
+type WebhookHandler struct {
+	conf   *config
+	log    *zerolog.Logger
+	poster *Poster
+}
+
+type config struct {
+	URL          string            `docs:";The URL of the webhook."                                                   mapstructure:"url"            validate:"required"`
+	Secret       string            `docs:";The secret the requests are signed with. If empty, they are not signed."   mapstructure:"secret"`
+	Headers      map[string]string `docs:";Additional headers sent with the requests."                                mapstructure:"headers"`
+	Timeout      int               `docs:"10;Timeout in seconds of a request."                                       mapstructure:"timeout"`
+	MaxRetries   int               `docs:"3;Number of times a failed request is retried."                            mapstructure:"max_retries"`
+	RetryBackoff int               `docs:"1;Seconds to wait before the first retry, doubled at each further retry." mapstructure:"retry_backoff"`
+	Insecure     bool              `docs:"false;Whether to skip the verification of the TLS certificate."            mapstructure:"insecure"`
+}
+
+func (c *config) ApplyDefaults() {
+	if c.Timeout == 0 {
+		c.Timeout = 10
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.RetryBackoff == 0 {
+		c.RetryBackoff = 1
+	}
+}
+
+// New returns a new webhook handler.
+func New(ctx context.Context, m map[string]any) (handler.Handler, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	return &WebhookHandler{
+		conf:   &c,
+		log:    appctx.GetLogger(ctx),
+		poster: NewPoster(time.Duration(c.Timeout)*time.Second, c.Insecure, c.MaxRetries, time.Duration(c.RetryBackoff)*time.Second),
+	}, nil
+}
+
+// Send posts a notification for a single recipient.
+func (h *WebhookHandler) Send(sender, recipient, subject, body string) error {
+	return h.SendMessage(&handler.Message{
+		Sender:     sender,
+		Recipients: []string{recipient},
+		Subject:    subject,
+		Body:       body,
+		Time:       time.Now(),
+	})
+}
+
+// SendMessage posts the notification with the data of its trigger.
+func (h *WebhookHandler) SendMessage(m *handler.Message) error {
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return errors.Wrap(err, "webhook: error encoding notification")
+	}
+
+	header := make(http.Header)
+	for k, v := range h.conf.Headers {
+		header.Set(k, v)
+	}
+	if h.conf.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		header.Set(TimestampHeader, ts)
+		header.Set(SignatureHeader, Sign(h.conf.Secret, ts, payload))
+	}
+
+	if err := h.poster.Post(context.Background(), h.conf.URL, header, payload); err != nil {
+		return err
+	}
+	h.log.Debug().Str("ref", m.Ref).Msg("webhook: notification delivered")
+	return nil
+}
+
+// Sign returns the signature of a request body sent at the given timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Poster posts JSON documents, retrying with an exponential backoff
+// when the server cannot be reached or fails with a temporary error.
+type Poster struct {
+	client     *http.Client
+	maxRetries int
+	backoff    time.Duration
+}
+
+// NewPoster returns a new Poster.
+func NewPoster(timeout time.Duration, insecure bool, maxRetries int, backoff time.Duration) *Poster {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if insecure {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &Poster{
+		client:     &http.Client{Timeout: timeout, Transport: tr},
+		maxRetries: maxRetries,
+		backoff:    backoff,
+	}
+}
+
+// Post sends the body to the url.
+func (p *Poster) Post(ctx context.Context, url string, header http.Header, body []byte) error {
+	backoff := p.backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = p.post(ctx, url, header, body)
+		if err == nil || !retry || attempt >= p.maxRetries {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+// post sends a single request, returning whether it can be retried if it failed.
+func (p *Poster) post(ctx context.Context, url string, header http.Header, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "webhook: error creating request")
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return true, errors.Wrap(err, "webhook: error sending request")
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("webhook: unexpected response status %s", res.Status)
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500, err
+}
@@ -0,0 +1,111 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package webhookhandler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cs3org/reva/v3/pkg/notification/handler"
+)
+
+func TestSendMessage(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+
+		if got := r.Header.Get(SignatureHeader); got != Sign("s3cr3t", r.Header.Get(TimestampHeader), body) {
+			t.Errorf("wrong signature %q", got)
+		}
+		if r.Header.Get("X-Custom") != "value" {
+			t.Errorf("missing custom header")
+		}
+
+		var m handler.Message
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Errorf("error decoding payload: %v", err)
+		}
+		if m.Template != "share-created" || len(m.Recipients) != 2 || m.Data["path"] != "/home/docs" {
+			t.Errorf("unexpected payload %+v", m)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	h, err := New(context.Background(), map[string]any{
+		"url":     srv.URL,
+		"secret":  "s3cr3t",
+		"headers": map[string]string{"X-Custom": "value"},
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	err = h.(handler.StructuredHandler).SendMessage(&handler.Message{
+		Template:   "share-created",
+		Recipients: []string{"einstein@example.org", "marie@example.org"},
+		Data:       map[string]any{"path": "/home/docs"},
+	})
+	if err != nil {
+		t.Fatalf("SendMessage returned error: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected one request, got %d", calls.Load())
+	}
+}
+
+func TestPostRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		calls    int32
+		fails    bool
+	}{
+		{"success", []int{http.StatusOK}, 1, false},
+		{"temporary failure", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, 3, false},
+		{"permanent failure", []int{http.StatusBadRequest}, 1, true},
+		{"retries exhausted", []int{500, 500, 500, 500}, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := calls.Add(1)
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			p := NewPoster(0, false, 2, 0)
+			err := p.Post(context.Background(), srv.URL, http.Header{}, []byte("{}"))
+			if (err != nil) != tt.fails {
+				t.Errorf("got error %v, expected failure %t", err, tt.fails)
+			}
+			if calls.Load() != tt.calls {
+				t.Errorf("got %d requests, expected %d", calls.Load(), tt.calls)
+			}
+		})
+	}
+}
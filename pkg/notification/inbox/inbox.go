@@ -0,0 +1,60 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package inbox defines the per-user inbox of the notifications,
+// displayed by the clients in the product.
+package inbox
+
+import (
+	"context"
+	"time"
+)
+
+// Notification is an entry of the inbox of a user.
+type Notification struct {
+	ID uint
+	// Recipient is the identity the notification is addressed to,
+	// either the username or the mail address of the user.
+	Recipient  string
+	App        string
+	ObjectType string
+	ObjectID   string
+	Subject    string
+	Message    string
+	Link       string
+	Read       bool
+	CreatedAt  time.Time
+}
+
+// Manager is the interface inbox drivers have to implement. The methods
+// reading or modifying the inbox of a user get all the identities of the
+// user, as the notifications are addressed by username or by mail address.
+type Manager interface {
+	// Add stores a notification in the inbox of its recipient.
+	Add(ctx context.Context, n *Notification) error
+	// List returns the notifications of a user, most recent first.
+	List(ctx context.Context, recipients []string, unreadOnly bool) ([]*Notification, error)
+	// Get returns a notification of a user.
+	Get(ctx context.Context, recipients []string, id uint) (*Notification, error)
+	// MarkRead marks a notification of a user as read.
+	MarkRead(ctx context.Context, recipients []string, id uint) error
+	// Delete deletes a notification of a user.
+	Delete(ctx context.Context, recipients []string, id uint) error
+	// DeleteAll deletes all the notifications of a user.
+	DeleteAll(ctx context.Context, recipients []string) error
+}
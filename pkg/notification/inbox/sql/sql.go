@@ -0,0 +1,188 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cs3org/reva/v3/cmd/revad/pkg/config"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/notification/inbox"
+	"github.com/cs3org/reva/v3/pkg/notification/inbox/registry"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registry.Register("sql", New)
+}
+
+type Config struct {
+	config.Database `mapstructure:",squash"`
+}
+
+func (c *Config) ApplyDefaults() {
+	c.Database = sharedconf.GetDBInfo(c.Database)
+}
+
+type mgr struct {
+	db *gorm.DB
+}
+
+// InboxNotification is the database model of a notification of the inbox.
+type InboxNotification struct {
+	ID         uint      `gorm:"primarykey"`
+	CreatedAt  time.Time `gorm:"index:i_created_at"`
+	Recipient  string    `gorm:"size:320;index:i_recipient"`
+	App        string    `gorm:"size:255"`
+	ObjectType string    `gorm:"size:255"`
+	ObjectID   string    `gorm:"size:3072"`
+	Subject    string
+	Message    string
+	Link       string
+	Read       bool
+}
+
+// New returns an instance of the sql inbox driver.
+func New(ctx context.Context, m map[string]any) (inbox.Manager, error) {
+	var c Config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	var db *gorm.DB
+	var err error
+	switch c.Engine {
+	case "sqlite":
+		db, err = gorm.Open(sqlite.Open(c.DBName), &gorm.Config{})
+	default: // default is mysql
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
+		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to the notification inbox database using engine "+c.Engine)
+	}
+
+	if err := db.AutoMigrate(&InboxNotification{}); err != nil {
+		return nil, errors.Wrap(err, "failed to migrate the InboxNotification schema")
+	}
+
+	return &mgr{db: db}, nil
+}
+
+func (m *mgr) Add(ctx context.Context, n *inbox.Notification) error {
+	row := &InboxNotification{
+		CreatedAt:  n.CreatedAt,
+		Recipient:  n.Recipient,
+		App:        n.App,
+		ObjectType: n.ObjectType,
+		ObjectID:   n.ObjectID,
+		Subject:    n.Subject,
+		Message:    n.Message,
+		Link:       n.Link,
+	}
+	if err := m.db.WithContext(ctx).Create(row).Error; err != nil {
+		return err
+	}
+	n.ID = row.ID
+	n.CreatedAt = row.CreatedAt
+	return nil
+}
+
+func (m *mgr) List(ctx context.Context, recipients []string, unreadOnly bool) ([]*inbox.Notification, error) {
+	query := m.db.WithContext(ctx).Where("recipient IN ?", recipients)
+	if unreadOnly {
+		query = query.Where("`read` = ?", false)
+	}
+
+	var rows []*InboxNotification
+	if err := query.Order("created_at DESC, id DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	notifications := make([]*inbox.Notification, 0, len(rows))
+	for _, r := range rows {
+		notifications = append(notifications, convert(r))
+	}
+	return notifications, nil
+}
+
+func (m *mgr) Get(ctx context.Context, recipients []string, id uint) (*inbox.Notification, error) {
+	var row InboxNotification
+	err := m.db.WithContext(ctx).Where("recipient IN ?", recipients).First(&row, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errtypes.NotFound(fmt.Sprintf("notification %d", id))
+		}
+		return nil, err
+	}
+	return convert(&row), nil
+}
+
+func (m *mgr) MarkRead(ctx context.Context, recipients []string, id uint) error {
+	res := m.db.WithContext(ctx).Model(&InboxNotification{}).
+		Where("id = ? AND recipient IN ?", id, recipients).
+		Update("read", true)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		// the notification may already be read
+		if _, err := m.Get(ctx, recipients, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mgr) Delete(ctx context.Context, recipients []string, id uint) error {
+	res := m.db.WithContext(ctx).Where("recipient IN ?", recipients).Delete(&InboxNotification{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errtypes.NotFound(fmt.Sprintf("notification %d", id))
+	}
+	return nil
+}
+
+func (m *mgr) DeleteAll(ctx context.Context, recipients []string) error {
+	return m.db.WithContext(ctx).Where("recipient IN ?", recipients).Delete(&InboxNotification{}).Error
+}
+
+func convert(r *InboxNotification) *inbox.Notification {
+	return &inbox.Notification{
+		ID:         r.ID,
+		Recipient:  r.Recipient,
+		App:        r.App,
+		ObjectType: r.ObjectType,
+		ObjectID:   r.ObjectID,
+		Subject:    r.Subject,
+		Message:    r.Message,
+		Link:       r.Link,
+		Read:       r.Read,
+		CreatedAt:  r.CreatedAt,
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/notification/inbox"
+)
+
+func newTestManager(t *testing.T) inbox.Manager {
+	t.Helper()
+	mgr, err := New(context.Background(), map[string]any{
+		"db_engine": "sqlite",
+		"db_name":   filepath.Join(t.TempDir(), "inbox.sqlite"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mgr
+}
+
+func TestInbox(t *testing.T) {
+	ctx := context.Background()
+	mgr := newTestManager(t)
+	einstein := []string{"einstein", "einstein@example.org"}
+	marie := []string{"marie", "marie@example.org"}
+
+	for _, n := range []*inbox.Notification{
+		{Recipient: "einstein@example.org", App: "reva", ObjectType: "share-create", Subject: "first"},
+		{Recipient: "einstein", App: "reva", ObjectType: "share-create", Subject: "second"},
+		{Recipient: "marie@example.org", App: "reva", ObjectType: "share-create", Subject: "third"},
+	} {
+		if err := mgr.Add(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+		if n.ID == 0 {
+			t.Fatal("expected the notification to get an id")
+		}
+	}
+
+	list, err := mgr.List(ctx, einstein, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Subject != "second" || list[1].Subject != "first" {
+		t.Fatalf("unexpected notifications %+v", list)
+	}
+
+	first := list[1].ID
+	if _, err := mgr.Get(ctx, marie, first); !isNotFound(err) {
+		t.Fatalf("expected not found getting a notification of another user, got %v", err)
+	}
+	if err := mgr.MarkRead(ctx, marie, first); !isNotFound(err) {
+		t.Fatalf("expected not found marking a notification of another user, got %v", err)
+	}
+	if err := mgr.MarkRead(ctx, einstein, first); err != nil {
+		t.Fatal(err)
+	}
+	// marking twice is not an error
+	if err := mgr.MarkRead(ctx, einstein, first); err != nil {
+		t.Fatal(err)
+	}
+	n, err := mgr.Get(ctx, einstein, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !n.Read {
+		t.Fatal("expected the notification to be read")
+	}
+
+	unread, err := mgr.List(ctx, einstein, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unread) != 1 || unread[0].Subject != "second" {
+		t.Fatalf("unexpected unread notifications %+v", unread)
+	}
+
+	if err := mgr.Delete(ctx, marie, first); !isNotFound(err) {
+		t.Fatalf("expected not found deleting a notification of another user, got %v", err)
+	}
+	if err := mgr.Delete(ctx, einstein, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.DeleteAll(ctx, einstein); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := mgr.List(ctx, einstein, false); len(list) != 0 {
+		t.Fatalf("expected no notifications, got %+v", list)
+	}
+	if list, _ := mgr.List(ctx, marie, false); len(list) != 1 {
+		t.Fatalf("expected the notifications of other users to be kept, got %+v", list)
+	}
+}
+
+func isNotFound(err error) bool {
+	_, ok := err.(errtypes.NotFound)
+	return ok
+}
@@ -20,7 +20,9 @@ package notification
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/cs3org/reva/v3/pkg/notification/handler"
 	"github.com/cs3org/reva/v3/pkg/notification/template"
 )
 
@@ -30,6 +32,9 @@ type Notification struct {
 	Template     template.Template
 	Ref          string
 	Recipients   []string
+	// Users optionally maps the recipients to the ids of their users,
+	// used to look up the notification preferences of the recipients.
+	Users map[string]string
 }
 
 // Manager is the interface notification storage managers have to implement.
@@ -76,6 +81,19 @@ func (n *Notification) Send(sender string, templateData map[string]any) error {
 		return err
 	}
 
+	if h, ok := n.Template.Handler.(handler.StructuredHandler); ok {
+		return h.SendMessage(&handler.Message{
+			Template:   n.TemplateName,
+			Ref:        n.Ref,
+			Sender:     sender,
+			Recipients: n.Recipients,
+			Subject:    subject,
+			Body:       body,
+			Time:       time.Now(),
+			Data:       templateData,
+		})
+	}
+
 	for _, recipient := range n.Recipients {
 		err := n.Template.Handler.Send(sender, recipient, subject, body)
 		if err != nil {
@@ -19,15 +19,16 @@
 package notificationhelper
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 
+	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/cs3org/reva/v3/pkg/notification"
 	"github.com/cs3org/reva/v3/pkg/notification/template"
+	"github.com/cs3org/reva/v3/pkg/notification/transport"
+	"github.com/cs3org/reva/v3/pkg/notification/transport/registry"
 	"github.com/cs3org/reva/v3/pkg/notification/trigger"
-	"github.com/cs3org/reva/v3/pkg/notification/utils"
 	"github.com/mitchellh/mapstructure"
-	"github.com/nats-io/nats.go"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 )
@@ -37,21 +38,22 @@ type NotificationHelper struct {
 	Name string
 	Conf *Config
 	Log  *zerolog.Logger
-	nc   *nats.Conn
-	js   nats.JetStreamContext
-	kv   nats.KeyValue
+	tr   transport.Transport
 }
 
 // Config contains the configuration for the Notification Helper.
 type Config struct {
-	NatsAddress string         `docs:";The NATS server address."                          mapstructure:"nats_address"`
-	NatsToken   string         `docs:";The token to authenticate against the NATS server" mapstructure:"nats_token"`
-	NatsStream  string         `docs:"reva-notifications;The notifications NATS stream."  mapstructure:"nats_stream"`
-	Templates   map[string]any `docs:"nil;Notification templates for the service."        mapstructure:"templates"`
+	Transport   string                    `docs:"nats;The transport the notification messages are sent through."                                    mapstructure:"transport"`
+	Transports  map[string]map[string]any `docs:"nil;Settings for the different transports."                                                        mapstructure:"transports"`
+	NatsAddress string                    `docs:";The NATS server address, used if the nats transport is not configured."                           mapstructure:"nats_address"`
+	NatsToken   string                    `docs:";The token to authenticate against the NATS server, used if the nats transport is not configured." mapstructure:"nats_token"`
+	NatsStream  string                    `docs:"reva-notifications;The notifications NATS stream, used if the nats transport is not configured."   mapstructure:"nats_stream"`
+	Templates   map[string]any            `docs:"nil;Notification templates for the service."                                                       mapstructure:"templates"`
 }
 
 func defaultConfig() *Config {
 	return &Config{
+		Transport:  "nats",
 		NatsStream: "reva-notifications",
 	}
 }
@@ -78,7 +80,7 @@ func New(name string, m map[string]any, log *zerolog.Logger) (*NotificationHelpe
 	}
 
 	if err := nh.connect(); err != nil {
-		err = errors.Wrap(err, "connecting to nats failed, notifications will be disabled")
+		err = errors.Wrap(err, "connecting to the transport failed, notifications will be disabled")
 		return nil, err
 	}
 
@@ -88,49 +90,34 @@ func New(name string, m map[string]any, log *zerolog.Logger) (*NotificationHelpe
 }
 
 func (nh *NotificationHelper) connect() error {
-	nc, err := utils.ConnectToNats(nh.Conf.NatsAddress, nh.Conf.NatsToken, *nh.Log)
-	if err != nil {
-		return err
-	}
-	nh.nc = nc
-
-	js, err := nh.nc.JetStream(nats.PublishAsyncMaxPending(256))
-	if err != nil {
-		return errors.Wrap(err, "jetstream initialization failed")
-	}
-	stream, _ := js.StreamInfo(nh.Conf.NatsStream)
-	if stream != nil {
-		if _, err := js.AddStream(&nats.StreamConfig{
-			Name: nh.Conf.NatsStream,
-			Subjects: []string{
-				fmt.Sprintf("%s.notification", nh.Conf.NatsStream),
-				fmt.Sprintf("%s.trigger", nh.Conf.NatsStream),
-			},
-		}); err != nil {
-			return errors.Wrap(err, "nats stream creation failed")
+	if nh.Conf.Transports == nil {
+		nh.Conf.Transports = map[string]map[string]any{}
+	}
+	if _, ok := nh.Conf.Transports["nats"]; !ok {
+		nh.Conf.Transports["nats"] = map[string]any{
+			"address": nh.Conf.NatsAddress,
+			"token":   nh.Conf.NatsToken,
+			"prefix":  nh.Conf.NatsStream,
 		}
 	}
-	nh.js = js
 
-	bucketName := fmt.Sprintf("%s-template", nh.Conf.NatsStream)
-	kv, err := nh.js.CreateKeyValue(&nats.KeyValueConfig{
-		Bucket: bucketName,
-	})
+	ctx := appctx.WithLogger(context.Background(), nh.Log)
+	tr, err := registry.New(ctx, nh.Conf.Transport, nh.Conf.Transports)
 	if err != nil {
-		return errors.Wrap(err, "template store creation failed, probably because nats server is unreachable")
+		return err
 	}
-	nh.kv = kv
+	nh.tr = tr
 	return nil
 }
 
 // Stop stops the notification helper.
 func (nh *NotificationHelper) Stop() {
-	if nh.nc == nil {
-		// service didn't connect yet to nat server
+	if nh.tr == nil {
+		// service didn't connect yet to the transport
 		return
 	}
-	if err := nh.nc.Drain(); err != nil {
-		nh.Log.Error().Err(err).Msg("error drainning connection")
+	if err := nh.tr.Close(); err != nil {
+		nh.Log.Error().Err(err).Msg("error closing the transport")
 	}
 }
 
@@ -168,7 +155,7 @@ func (nh *NotificationHelper) registerTemplates(ts map[string]any) {
 }
 
 func (nh *NotificationHelper) registerTemplate(rr *template.RegistrationRequest) {
-	if nh.kv == nil {
+	if nh.tr == nil {
 		nh.Log.Info().Msgf("template registration skipped, helper is misconfigured")
 		return
 	}
@@ -179,7 +166,7 @@ func (nh *NotificationHelper) registerTemplate(rr *template.RegistrationRequest)
 	}
 
 	go func() {
-		_, err := nh.kv.Put(rr.Name, tb)
+		err := nh.tr.PutTemplate(rr.Name, tb)
 		if err != nil {
 			nh.Log.Error().Err(err).Msgf("template registration publish failed")
 			return
@@ -191,7 +178,7 @@ func (nh *NotificationHelper) registerTemplate(rr *template.RegistrationRequest)
 // RegisterNotification registers a notification in the notification service.
 func (nh *NotificationHelper) RegisterNotification(n *notification.Notification) {
 	nh.Log.Debug().Msgf("Registering notification %s", n.Ref)
-	if nh.js == nil {
+	if nh.tr == nil {
 		nh.Log.Info().Msgf("notification registration skipped, helper is misconfigured")
 		return
 	}
@@ -202,10 +189,8 @@ func (nh *NotificationHelper) RegisterNotification(n *notification.Notification)
 		return
 	}
 
-	notificationSubject := fmt.Sprintf("%s.notification-register", nh.Conf.NatsStream)
-
 	go func() {
-		_, err := nh.js.Publish(notificationSubject, nb)
+		err := nh.tr.Publish(transport.SubjectRegister, nb)
 		if err != nil {
 			nh.Log.Error().Err(err).Msgf("notification registration publish failed")
 			return
@@ -216,15 +201,13 @@ func (nh *NotificationHelper) RegisterNotification(n *notification.Notification)
 
 // UnregisterNotification unregisters a notification in the notification service.
 func (nh *NotificationHelper) UnregisterNotification(ref string) {
-	if nh.js == nil {
+	if nh.tr == nil {
 		nh.Log.Info().Msgf("notification unregistration skipped, notification helper is misconfigured")
 		return
 	}
 
-	notificationSubject := fmt.Sprintf("%s.notification-unregister", nh.Conf.NatsStream)
-
 	go func() {
-		_, err := nh.js.Publish(notificationSubject, []byte(ref))
+		err := nh.tr.Publish(transport.SubjectUnregister, []byte(ref))
 		if err != nil {
 			nh.Log.Error().Err(err).Msgf("notification unregistration publish failed")
 			return
@@ -235,7 +218,7 @@ func (nh *NotificationHelper) UnregisterNotification(ref string) {
 
 // TriggerNotification sends a notification trigger to the notifications service.
 func (nh *NotificationHelper) TriggerNotification(tr *trigger.Trigger) {
-	if nh.js == nil {
+	if nh.tr == nil {
 		nh.Log.Info().Msgf("notification trigger skipped, notification helper is misconfigured")
 		return
 	}
@@ -246,10 +229,8 @@ func (nh *NotificationHelper) TriggerNotification(tr *trigger.Trigger) {
 		return
 	}
 
-	triggerSubject := fmt.Sprintf("%s.trigger", nh.Conf.NatsStream)
-
 	go func() {
-		_, err := nh.js.Publish(triggerSubject, trb)
+		err := nh.tr.Publish(transport.SubjectTrigger, trb)
 		if err != nil {
 			nh.Log.Error().Err(err).Msgf("notification trigger publish failed")
 			return
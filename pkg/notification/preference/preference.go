@@ -0,0 +1,126 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package preference defines the notification preferences of the users,
+// stored through the preferences service in the notifications namespace.
+package preference
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Namespace is the preferences namespace of the notification preferences.
+const Namespace = "notifications"
+
+// DefaultKey is the key of the preference applied to the event types
+// the user did not set a preference for.
+const DefaultKey = "default"
+
+// Channel is a channel notifications are delivered through.
+type Channel string
+
+const (
+	// ChannelEmail delivers the notifications through the handler of their template, usually by email.
+	ChannelEmail Channel = "email"
+	// ChannelInApp stores the notifications in the in-app inbox of the user.
+	ChannelInApp Channel = "inapp"
+	// ChannelNone disables the notifications.
+	ChannelNone Channel = "none"
+)
+
+// Frequency is the frequency notifications are delivered by email.
+type Frequency string
+
+const (
+	// FrequencyInstant delivers the notifications as soon as they are triggered.
+	FrequencyInstant Frequency = "instant"
+	// FrequencyHourly delivers the notifications in a digest every hour.
+	FrequencyHourly Frequency = "hourly"
+	// FrequencyDaily delivers the notifications in a digest every day.
+	FrequencyDaily Frequency = "daily"
+)
+
+// Preference is the notification preference of a user for an event type,
+// i.e. a notification template. It is stored as JSON, for example
+// {"channels": ["email", "inapp"], "frequency": "daily"}.
+type Preference struct {
+	Channels  []Channel `json:"channels"            mapstructure:"channels"`
+	Frequency Frequency `json:"frequency,omitempty" mapstructure:"frequency"`
+}
+
+// Parse parses and validates a preference.
+func Parse(v string) (*Preference, error) {
+	var p Preference
+	if err := json.Unmarshal([]byte(v), &p); err != nil {
+		return nil, errors.Wrap(err, "error decoding notification preference")
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate checks that the preference only contains known channels and frequencies.
+func (p *Preference) Validate() error {
+	for _, c := range p.Channels {
+		switch c {
+		case ChannelEmail, ChannelInApp, ChannelNone:
+		default:
+			return fmt.Errorf("unknown notification channel %q", c)
+		}
+	}
+	switch p.Frequency {
+	case "", FrequencyInstant, FrequencyHourly, FrequencyDaily:
+	default:
+		return fmt.Errorf("unknown notification frequency %q", p.Frequency)
+	}
+	return nil
+}
+
+// Has returns whether notifications are delivered through the given channel.
+func (p *Preference) Has(c Channel) bool {
+	if slices.Contains(p.Channels, ChannelNone) {
+		return false
+	}
+	return slices.Contains(p.Channels, c)
+}
+
+// Digest returns whether email notifications are batched in a digest.
+func (p *Preference) Digest() bool {
+	return p.Frequency == FrequencyHourly || p.Frequency == FrequencyDaily
+}
+
+// NextDigest returns the time after now at which the digests of the
+// given frequency are sent. Daily digests are sent at the given hour.
+func NextDigest(f Frequency, now time.Time, hour int) time.Time {
+	switch f {
+	case FrequencyDaily:
+		next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	default:
+		return now.Truncate(time.Hour).Add(time.Hour)
+	}
+}
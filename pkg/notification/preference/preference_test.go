@@ -0,0 +1,82 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package preference
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value   string
+		email   bool
+		inApp   bool
+		digest  bool
+		invalid bool
+	}{
+		{value: `{"channels": ["email"]}`, email: true},
+		{value: `{"channels": ["email", "inapp"], "frequency": "daily"}`, email: true, inApp: true, digest: true},
+		{value: `{"channels": ["inapp"], "frequency": "instant"}`, inApp: true},
+		{value: `{"channels": ["email", "none"], "frequency": "hourly"}`, digest: true},
+		{value: `{"channels": []}`},
+		{value: `{"channels": ["sms"]}`, invalid: true},
+		{value: `{"channels": ["email"], "frequency": "weekly"}`, invalid: true},
+		{value: `email`, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			p, err := Parse(tt.value)
+			if tt.invalid {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Has(ChannelEmail) != tt.email || p.Has(ChannelInApp) != tt.inApp || p.Digest() != tt.digest {
+				t.Fatalf("unexpected preference %+v", p)
+			}
+		})
+	}
+}
+
+func TestNextDigest(t *testing.T) {
+	loc := time.UTC
+	tests := []struct {
+		frequency Frequency
+		now       time.Time
+		expected  time.Time
+	}{
+		{FrequencyHourly, time.Date(2024, 3, 1, 10, 20, 0, 0, loc), time.Date(2024, 3, 1, 11, 0, 0, 0, loc)},
+		{FrequencyHourly, time.Date(2024, 3, 1, 23, 0, 0, 0, loc), time.Date(2024, 3, 2, 0, 0, 0, 0, loc)},
+		{FrequencyDaily, time.Date(2024, 3, 1, 7, 59, 0, 0, loc), time.Date(2024, 3, 1, 8, 0, 0, 0, loc)},
+		{FrequencyDaily, time.Date(2024, 3, 1, 8, 0, 0, 0, loc), time.Date(2024, 3, 2, 8, 0, 0, 0, loc)},
+		{FrequencyDaily, time.Date(2024, 2, 29, 19, 0, 0, 0, loc), time.Date(2024, 3, 1, 8, 0, 0, 0, loc)},
+	}
+
+	for _, tt := range tests {
+		if got := NextDigest(tt.frequency, tt.now, 8); !got.Equal(tt.expected) {
+			t.Errorf("NextDigest(%s, %s) = %s, expected %s", tt.frequency, tt.now, got, tt.expected)
+		}
+	}
+}
@@ -21,6 +21,7 @@ package registry
 import (
 	"encoding/json"
 	"fmt"
+	"sync"
 
 	"github.com/cs3org/reva/v3/pkg/notification/handler"
 	"github.com/cs3org/reva/v3/pkg/notification/template"
@@ -29,6 +30,7 @@ import (
 
 // Registry provides with means for dynamically registering notification templates.
 type Registry struct {
+	mu    sync.RWMutex
 	store map[string]template.Template
 }
 
@@ -55,12 +57,23 @@ func (r *Registry) Put(tb []byte, hs map[string]handler.Handler) (string, error)
 		return name, errors.Wrapf(err, "template %s registration failed", name)
 	}
 
+	r.mu.Lock()
 	r.store[t.Name] = *t
+	r.mu.Unlock()
 	return t.Name, nil
 }
 
+// Delete removes a handler from the registry.
+func (r *Registry) Delete(n string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.store, n)
+}
+
 // Get retrieves a handler from the registry.
 func (r *Registry) Get(n string) (*template.Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	if t, ok := r.store[n]; ok {
 		return &t, nil
 	}
@@ -0,0 +1,242 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/notification/transport"
+	"github.com/cs3org/reva/v3/pkg/notification/transport/registry"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+)
+
+func init() {
+	registry.Register("memory", New)
+}
+
+// Config is the configuration of the in-process transport.
+type Config struct {
+	Bus        string `docs:"default;The name of the bus, the transports of the same process sharing a bus exchange their messages." mapstructure:"bus"`
+	MaxPending int    `docs:"10000;The maximum number of messages waiting on a subject, further messages are rejected until they are consumed." mapstructure:"max_pending"`
+}
+
+// ApplyDefaults applies the default values to the configuration.
+func (c *Config) ApplyDefaults() {
+	if c.Bus == "" {
+		c.Bus = "default"
+	}
+	if c.MaxPending == 0 {
+		c.MaxPending = 10000
+	}
+}
+
+var (
+	busesMu sync.Mutex
+	buses   = map[string]*bus{}
+)
+
+// bus is shared by the transports of a process using the same bus name.
+type bus struct {
+	mu         sync.Mutex
+	maxPending int
+	templates  map[string][]byte
+	watchers   map[*mailbox]struct{}
+	subjects   map[string]*mailbox
+}
+
+// getBus returns the bus of the given name, created with the given maximum
+// of pending messages per mailbox if it does not exist yet.
+func getBus(name string, maxPending int) *bus {
+	busesMu.Lock()
+	defer busesMu.Unlock()
+	b, ok := buses[name]
+	if !ok {
+		b = &bus{
+			maxPending: maxPending,
+			templates:  map[string][]byte{},
+			watchers:   map[*mailbox]struct{}{},
+			subjects:   map[string]*mailbox{},
+		}
+		buses[name] = b
+	}
+	return b
+}
+
+// message is a message of a mailbox. The messages of the template watchers
+// carry the name of the template.
+type message struct {
+	name string
+	data []byte
+}
+
+// mailbox is a bounded queue of messages.
+type mailbox struct {
+	mu     sync.Mutex
+	max    int
+	msgs   []message
+	notify chan struct{}
+}
+
+func newMailbox(max int) *mailbox {
+	return &mailbox{max: max, notify: make(chan struct{}, 1)}
+}
+
+// put queues a message, unless the mailbox is full.
+func (m *mailbox) put(msg message) bool {
+	m.mu.Lock()
+	if len(m.msgs) >= m.max {
+		m.mu.Unlock()
+		return false
+	}
+	m.msgs = append(m.msgs, msg)
+	m.mu.Unlock()
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (m *mailbox) pop() (message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.msgs) == 0 {
+		return message{}, false
+	}
+	msg := m.msgs[0]
+	m.msgs = m.msgs[1:]
+	return msg, true
+}
+
+// consume calls the handler with the messages of the mailbox until done is closed.
+func (m *mailbox) consume(handler func(message), done <-chan struct{}) {
+	for {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			msg, ok := m.pop()
+			if !ok {
+				break
+			}
+			handler(msg)
+		}
+		select {
+		case <-done:
+			return
+		case <-m.notify:
+		}
+	}
+}
+
+type memoryTransport struct {
+	bus  *bus
+	done chan struct{}
+	once sync.Once
+}
+
+// New returns a transport exchanging the messages in memory, between
+// the services of a single process.
+func New(ctx context.Context, m map[string]any) (transport.Transport, error) {
+	var c Config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+	return &memoryTransport{
+		bus:  getBus(c.Bus, c.MaxPending),
+		done: make(chan struct{}),
+	}, nil
+}
+
+func (t *memoryTransport) PutTemplate(name string, data []byte) error {
+	t.bus.mu.Lock()
+	defer t.bus.mu.Unlock()
+	t.bus.templates[name] = data
+	t.notifyWatchers(message{name: name, data: data})
+	return nil
+}
+
+func (t *memoryTransport) DeleteTemplate(name string) error {
+	t.bus.mu.Lock()
+	defer t.bus.mu.Unlock()
+	delete(t.bus.templates, name)
+	t.notifyWatchers(message{name: name})
+	return nil
+}
+
+// notifyWatchers sends a template update to the watchers. A watcher lagging
+// behind by more than the maximum of pending messages misses the update.
+func (t *memoryTransport) notifyWatchers(msg message) {
+	for w := range t.bus.watchers {
+		_ = w.put(msg)
+	}
+}
+
+func (t *memoryTransport) WatchTemplates(handler func(name string, data []byte)) error {
+	t.bus.mu.Lock()
+	w := newMailbox(max(t.bus.maxPending, len(t.bus.templates)))
+	for name, data := range t.bus.templates {
+		_ = w.put(message{name: name, data: data})
+	}
+	t.bus.watchers[w] = struct{}{}
+	t.bus.mu.Unlock()
+
+	go func() {
+		w.consume(func(msg message) { handler(msg.name, msg.data) }, t.done)
+		t.bus.mu.Lock()
+		delete(t.bus.watchers, w)
+		t.bus.mu.Unlock()
+	}()
+	return nil
+}
+
+// Publish queues a message on a subject. The messages of a subject nobody
+// consumes are kept up to the maximum of pending messages, after which they
+// are rejected.
+func (t *memoryTransport) Publish(subject string, data []byte) error {
+	if !t.mailbox(subject).put(message{data: data}) {
+		return errtypes.InsufficientStorage("too many pending messages on subject " + subject)
+	}
+	return nil
+}
+
+func (t *memoryTransport) Subscribe(subject string, handler func(data []byte)) error {
+	go t.mailbox(subject).consume(func(msg message) { handler(msg.data) }, t.done)
+	return nil
+}
+
+func (t *memoryTransport) mailbox(subject string) *mailbox {
+	t.bus.mu.Lock()
+	defer t.bus.mu.Unlock()
+	m, ok := t.bus.subjects[subject]
+	if !ok {
+		m = newMailbox(t.bus.maxPending)
+		t.bus.subjects[subject] = m
+	}
+	return m
+}
+
+func (t *memoryTransport) Close() error {
+	t.once.Do(func() { close(t.done) })
+	return nil
+}
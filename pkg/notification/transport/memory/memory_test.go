@@ -0,0 +1,120 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, ch <-chan []byte) string {
+	t.Helper()
+	select {
+	case data := <-ch:
+		return string(data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for a message")
+	}
+	return ""
+}
+
+func TestMessages(t *testing.T) {
+	ctx := context.Background()
+	producer, err := New(ctx, map[string]any{"bus": t.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer, err := New(ctx, map[string]any{"bus": t.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+
+	// messages published before the subscription are kept
+	_ = producer.Publish("trigger", []byte("first"))
+
+	triggers := make(chan []byte, 10)
+	if err := consumer.Subscribe("trigger", func(data []byte) { triggers <- data }); err != nil {
+		t.Fatal(err)
+	}
+	_ = producer.Publish("trigger", []byte("second"))
+	_ = producer.Publish("other", []byte("ignored"))
+
+	if got := receive(t, triggers); got != "first" {
+		t.Fatalf("expected first message, got %q", got)
+	}
+	if got := receive(t, triggers); got != "second" {
+		t.Fatalf("expected second message, got %q", got)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	ctx := context.Background()
+	producer, _ := New(ctx, map[string]any{"bus": t.Name()})
+	consumer, _ := New(ctx, map[string]any{"bus": t.Name()})
+	defer consumer.Close()
+
+	_ = producer.PutTemplate("a", []byte("template a"))
+
+	templates := make(chan []byte, 10)
+	if err := consumer.WatchTemplates(func(name string, data []byte) { templates <- []byte(name + ":" + string(data)) }); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, templates); got != "a:template a" {
+		t.Fatalf("expected the stored template, got %q", got)
+	}
+
+	_ = producer.PutTemplate("b", []byte("template b"))
+	if got := receive(t, templates); got != "b:template b" {
+		t.Fatalf("expected the new template, got %q", got)
+	}
+
+	_ = producer.DeleteTemplate("a")
+	if got := receive(t, templates); got != "a:" {
+		t.Fatalf("expected an empty value for the deleted template, got %q", got)
+	}
+}
+
+func TestMaxPending(t *testing.T) {
+	ctx := context.Background()
+	producer, _ := New(ctx, map[string]any{"bus": t.Name(), "max_pending": 2})
+	consumer, _ := New(ctx, map[string]any{"bus": t.Name()})
+	defer consumer.Close()
+
+	_ = producer.Publish("trigger", []byte("first"))
+	_ = producer.Publish("trigger", []byte("second"))
+	if err := producer.Publish("trigger", []byte("third")); err == nil {
+		t.Fatal("expected a message over the maximum of pending messages to be rejected")
+	}
+
+	triggers := make(chan []byte, 10)
+	if err := consumer.Subscribe("trigger", func(data []byte) { triggers <- data }); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, triggers); got != "first" {
+		t.Fatalf("expected first message, got %q", got)
+	}
+	if got := receive(t, triggers); got != "second" {
+		t.Fatalf("expected second message, got %q", got)
+	}
+	if err := producer.Publish("trigger", []byte("third")); err != nil {
+		t.Fatalf("expected the consumed messages to free the mailbox: %v", err)
+	}
+}
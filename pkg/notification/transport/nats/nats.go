@@ -0,0 +1,179 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package nats
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/notification/transport"
+	"github.com/cs3org/reva/v3/pkg/notification/transport/registry"
+	"github.com/cs3org/reva/v3/pkg/notification/utils"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.Register("nats", New)
+}
+
+// Config is the configuration of the NATS transport.
+type Config struct {
+	Address string `docs:";The NATS server address."                           mapstructure:"address"`
+	Token   string `docs:";The token to authenticate against the NATS server." mapstructure:"token"`
+	Prefix  string `docs:"reva-notifications;The prefix of the NATS streams."  mapstructure:"prefix"`
+}
+
+// ApplyDefaults applies the default values to the configuration.
+func (c *Config) ApplyDefaults() {
+	if c.Prefix == "" {
+		c.Prefix = "reva-notifications"
+	}
+}
+
+type natsTransport struct {
+	c  *Config
+	nc *nats.Conn
+	js nats.JetStreamContext
+	kv nats.KeyValue
+
+	mu       sync.Mutex
+	watchers []nats.KeyWatcher
+}
+
+// New returns a transport over NATS JetStream, storing the
+// templates in a key-value bucket and the messages in streams.
+func New(ctx context.Context, m map[string]any) (transport.Transport, error) {
+	var c Config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	log := appctx.GetLogger(ctx)
+	nc, err := utils.ConnectToNats(c.Address, c.Token, *log)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := nc.JetStream(nats.PublishAsyncMaxPending(256))
+	if err != nil {
+		nc.Close()
+		return nil, errors.Wrap(err, "jetstream initialization failed")
+	}
+
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{
+		Bucket: fmt.Sprintf("%s-template", c.Prefix),
+	})
+	if err != nil {
+		nc.Close()
+		return nil, errors.Wrap(err, "template store creation failed, probably because nats server is unreachable")
+	}
+
+	return &natsTransport{
+		c:  &c,
+		nc: nc,
+		js: js,
+		kv: kv,
+	}, nil
+}
+
+func (t *natsTransport) PutTemplate(name string, data []byte) error {
+	_, err := t.kv.Put(name, data)
+	return err
+}
+
+func (t *natsTransport) DeleteTemplate(name string) error {
+	return t.kv.Purge(name)
+}
+
+func (t *natsTransport) WatchTemplates(handler func(name string, data []byte)) error {
+	w, err := t.kv.WatchAll()
+	if err != nil {
+		return errors.Wrap(err, "template store watch failed")
+	}
+
+	t.mu.Lock()
+	t.watchers = append(t.watchers, w)
+	t.mu.Unlock()
+
+	go func() {
+		for msg := range w.Updates() {
+			// a nil entry marks the end of the initial values
+			if msg == nil {
+				continue
+			}
+			if msg.Operation() != nats.KeyValuePut {
+				handler(msg.Key(), nil)
+				continue
+			}
+			handler(msg.Key(), msg.Value())
+		}
+	}()
+
+	return nil
+}
+
+func (t *natsTransport) Publish(subject string, data []byte) error {
+	_, err := t.js.Publish(fmt.Sprintf("%s.%s", t.c.Prefix, subject), data)
+	return err
+}
+
+func (t *natsTransport) Subscribe(subject string, handler func(data []byte)) error {
+	streamName := fmt.Sprintf("%s-%s", t.c.Prefix, subject)
+	consumerName := fmt.Sprintf("%s-consumer-%s", t.c.Prefix, subject)
+	subjectName := fmt.Sprintf("%s.%s", t.c.Prefix, subject)
+	deliverySubjectName := fmt.Sprintf("%s-delivery.%s", t.c.Prefix, subject)
+
+	// Creates a NATS stream with given name if it does not exist already
+	if _, err := t.js.AddStream(&nats.StreamConfig{
+		Name:     streamName,
+		Subjects: []string{subjectName},
+	}); err != nil {
+		return errors.Wrapf(err, "nats %s stream creation failed", subject)
+	}
+
+	// Adds a consumer with the given name to the JetStream context
+	if _, err := t.js.AddConsumer(streamName, &nats.ConsumerConfig{
+		Durable:        consumerName,
+		DeliverSubject: deliverySubjectName,
+	}); err != nil {
+		return errors.Wrapf(err, "nats %s consumer creation failed", subject)
+	}
+
+	// Subscribes the JetStream context to the consumer we just created
+	_, err := t.js.Subscribe("", func(msg *nats.Msg) { handler(msg.Data) }, nats.Bind(streamName, consumerName))
+	if err != nil {
+		return errors.Wrapf(err, "nats subscription to consumer %s failed", consumerName)
+	}
+
+	return nil
+}
+
+func (t *natsTransport) Close() error {
+	t.mu.Lock()
+	for _, w := range t.watchers {
+		_ = w.Stop()
+	}
+	t.watchers = nil
+	t.mu.Unlock()
+	return t.nc.Drain()
+}
@@ -0,0 +1,204 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cs3org/reva/v3/cmd/revad/pkg/config"
+	"github.com/cs3org/reva/v3/pkg/notification/transport"
+	"github.com/cs3org/reva/v3/pkg/notification/transport/registry"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func init() {
+	registry.Register("sql", New)
+}
+
+// Config is the configuration of the SQL outbox transport.
+type Config struct {
+	config.Database `mapstructure:",squash"`
+	PollInterval    int `docs:"2;The interval in seconds between two polls of the outbox." mapstructure:"poll_interval"`
+	BatchSize       int `docs:"100;The maximum number of messages handled per poll."       mapstructure:"batch_size"`
+}
+
+// ApplyDefaults applies the default values to the configuration.
+func (c *Config) ApplyDefaults() {
+	c.Database = sharedconf.GetDBInfo(c.Database)
+	if c.PollInterval == 0 {
+		c.PollInterval = 2
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = 100
+	}
+}
+
+// NotificationOutbox is a message waiting to be consumed.
+type NotificationOutbox struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	Subject   string `gorm:"size:255;index:i_subject"`
+	Data      []byte
+}
+
+// NotificationTransportTemplate is a template registration.
+type NotificationTransportTemplate struct {
+	Name      string `gorm:"primaryKey;size:255"`
+	UpdatedAt time.Time
+	Data      []byte
+}
+
+type sqlTransport struct {
+	c    *Config
+	db   *gorm.DB
+	done chan struct{}
+	once sync.Once
+}
+
+// New returns a transport storing the messages in an outbox table, polled
+// by the notifications service. A single notifications service must consume
+// the outbox, as the messages are deleted once handled.
+func New(ctx context.Context, m map[string]any) (transport.Transport, error) {
+	var c Config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	var db *gorm.DB
+	var err error
+	switch c.Engine {
+	case "sqlite":
+		db, err = gorm.Open(sqlite.Open(c.DBName), &gorm.Config{})
+	default: // default is mysql
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
+		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to the notification outbox database using engine "+c.Engine)
+	}
+
+	if err := db.AutoMigrate(&NotificationOutbox{}, &NotificationTransportTemplate{}); err != nil {
+		return nil, errors.Wrap(err, "failed to migrate the notification outbox schema")
+	}
+
+	return &sqlTransport{
+		c:    &c,
+		db:   db,
+		done: make(chan struct{}),
+	}, nil
+}
+
+func (t *sqlTransport) PutTemplate(name string, data []byte) error {
+	return t.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"data", "updated_at"}),
+	}).Create(&NotificationTransportTemplate{Name: name, Data: data}).Error
+}
+
+func (t *sqlTransport) DeleteTemplate(name string) error {
+	return t.db.Delete(&NotificationTransportTemplate{Name: name}).Error
+}
+
+func (t *sqlTransport) WatchTemplates(handler func(name string, data []byte)) error {
+	seen := map[string][]byte{}
+	poll := func() error {
+		var templates []*NotificationTransportTemplate
+		if err := t.db.Find(&templates).Error; err != nil {
+			return err
+		}
+		current := make(map[string]struct{}, len(templates))
+		for _, tpl := range templates {
+			current[tpl.Name] = struct{}{}
+			if old, ok := seen[tpl.Name]; ok && bytes.Equal(old, tpl.Data) {
+				continue
+			}
+			seen[tpl.Name] = tpl.Data
+			handler(tpl.Name, tpl.Data)
+		}
+		for name := range seen {
+			if _, ok := current[name]; !ok {
+				delete(seen, name)
+				handler(name, nil)
+			}
+		}
+		return nil
+	}
+
+	if err := poll(); err != nil {
+		return errors.Wrap(err, "error reading the notification templates")
+	}
+	go t.poll(func() { _ = poll() })
+	return nil
+}
+
+func (t *sqlTransport) Publish(subject string, data []byte) error {
+	return t.db.Create(&NotificationOutbox{Subject: subject, Data: data}).Error
+}
+
+func (t *sqlTransport) Subscribe(subject string, handler func(data []byte)) error {
+	go t.poll(func() {
+		for {
+			var msgs []*NotificationOutbox
+			if err := t.db.Where("subject = ?", subject).Order("id").Limit(t.c.BatchSize).Find(&msgs).Error; err != nil || len(msgs) == 0 {
+				return
+			}
+			ids := make([]uint, 0, len(msgs))
+			for _, msg := range msgs {
+				handler(msg.Data)
+				ids = append(ids, msg.ID)
+			}
+			if err := t.db.Delete(&NotificationOutbox{}, ids).Error; err != nil {
+				return
+			}
+			if len(msgs) < t.c.BatchSize {
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// poll runs f right away and then every poll interval, until the transport is closed.
+func (t *sqlTransport) poll(f func()) {
+	ticker := time.NewTicker(time.Duration(t.c.PollInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		f()
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *sqlTransport) Close() error {
+	t.once.Do(func() { close(t.done) })
+	return nil
+}
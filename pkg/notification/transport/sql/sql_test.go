@@ -0,0 +1,110 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/notification/transport"
+)
+
+func newTestTransport(t *testing.T, dbName string) transport.Transport {
+	t.Helper()
+	tr, err := New(context.Background(), map[string]any{
+		"db_engine":     "sqlite",
+		"db_name":       dbName,
+		"poll_interval": 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tr.Close() })
+	return tr
+}
+
+func receive(t *testing.T, ch <-chan []byte) string {
+	t.Helper()
+	select {
+	case data := <-ch:
+		return string(data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for a message")
+	}
+	return ""
+}
+
+func TestOutbox(t *testing.T) {
+	dbName := filepath.Join(t.TempDir(), "outbox.sqlite")
+	producer := newTestTransport(t, dbName)
+	consumer := newTestTransport(t, dbName)
+
+	_ = producer.Publish("trigger", []byte("first"))
+	_ = producer.Publish("other", []byte("ignored"))
+
+	triggers := make(chan []byte, 10)
+	if err := consumer.Subscribe("trigger", func(data []byte) { triggers <- data }); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, triggers); got != "first" {
+		t.Fatalf("expected first message, got %q", got)
+	}
+
+	_ = producer.Publish("trigger", []byte("second"))
+	if got := receive(t, triggers); got != "second" {
+		t.Fatalf("expected second message, got %q", got)
+	}
+
+	// handled messages are removed from the outbox
+	select {
+	case data := <-triggers:
+		t.Fatalf("unexpected message %q", data)
+	case <-time.After(1500 * time.Millisecond):
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	dbName := filepath.Join(t.TempDir(), "outbox.sqlite")
+	producer := newTestTransport(t, dbName)
+	consumer := newTestTransport(t, dbName)
+
+	_ = producer.PutTemplate("a", []byte("template a"))
+
+	templates := make(chan []byte, 10)
+	if err := consumer.WatchTemplates(func(name string, data []byte) { templates <- []byte(name + ":" + string(data)) }); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, templates); got != "a:template a" {
+		t.Fatalf("expected the stored template, got %q", got)
+	}
+
+	if err := producer.PutTemplate("a", []byte("template a v2")); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, templates); got != "a:template a v2" {
+		t.Fatalf("expected the updated template, got %q", got)
+	}
+
+	_ = producer.DeleteTemplate("a")
+	if got := receive(t, templates); got != "a:" {
+		t.Fatalf("expected an empty value for the deleted template, got %q", got)
+	}
+}
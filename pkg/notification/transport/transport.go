@@ -0,0 +1,51 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package transport defines how the notification messages flow between the
+// services producing them and the notifications service.
+package transport
+
+// Subjects of the messages published by the services to the notifications service.
+const (
+	SubjectRegister   = "notification-register"
+	SubjectUnregister = "notification-unregister"
+	SubjectTrigger    = "trigger"
+)
+
+// Transport carries the notification templates, registrations and triggers.
+// The templates are kept in a key-value store, where a template is stored
+// under its name, while registrations and triggers are messages consumed by
+// the notifications service.
+type Transport interface {
+	// PutTemplate stores a template registration.
+	PutTemplate(name string, data []byte) error
+	// DeleteTemplate deletes a template registration.
+	DeleteTemplate(name string) error
+	// WatchTemplates calls the handler with every stored template and then
+	// with every later update, until the transport is closed. The data of a
+	// deleted template is nil.
+	WatchTemplates(handler func(name string, data []byte)) error
+	// Publish publishes a message on a subject.
+	Publish(subject string, data []byte) error
+	// Subscribe calls the handler with every message published on the
+	// subject, including the ones published before the subscription,
+	// until the transport is closed.
+	Subscribe(subject string, handler func(data []byte)) error
+	// Close releases the resources of the transport.
+	Close() error
+}
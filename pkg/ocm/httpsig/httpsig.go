@@ -0,0 +1,550 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package httpsig implements HTTP Message Signatures (RFC 9421) as used by
+// OCM servers to authenticate server-to-server requests, together with the
+// Content-Digest header (RFC 9530) that binds the signature to the body.
+package httpsig
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ReneKroon/ttlcache/v2"
+	"github.com/cs3org/reva/v3/pkg/clientip"
+	"github.com/pkg/errors"
+)
+
+// Signature algorithms from the HTTP Signature Algorithms registry.
+const (
+	AlgRSAPSSSHA512    = "rsa-pss-sha512"
+	AlgRSAv15SHA256    = "rsa-v1_5-sha256"
+	AlgECDSAP256SHA256 = "ecdsa-p256-sha256"
+	AlgEd25519         = "ed25519"
+)
+
+const (
+	label = "sig1"
+
+	// DefaultMaxAge is how long a signature is accepted after its creation.
+	DefaultMaxAge = 5 * time.Minute
+)
+
+// ErrNotSigned is returned by Verify when the request carries no signature.
+var ErrNotSigned = errors.New("httpsig: request is not signed")
+
+// Signer signs outgoing requests with a private key.
+type Signer struct {
+	keyID string
+	key   crypto.Signer
+	alg   string
+}
+
+// NewSigner returns a signer using the given key, published under keyID.
+func NewSigner(keyID string, key crypto.Signer) (*Signer, error) {
+	alg, err := algorithmFor(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{keyID: keyID, key: key, alg: alg}, nil
+}
+
+// NewSignerFromFile loads a PEM encoded private key from path and returns
+// a signer for it.
+func NewSignerFromFile(keyID, path string) (*Signer, error) {
+	key, err := LoadPrivateKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigner(keyID, key)
+}
+
+// KeyID returns the identifier of the signing key.
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// PublicKeyPEM returns the PEM encoding of the signer's public key.
+func (s *Signer) PublicKeyPEM() (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(s.key.Public())
+	if err != nil {
+		return "", errors.Wrap(err, "httpsig: error encoding public key")
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// Sign adds the Content-Digest, Signature-Input and Signature headers to
+// req. body must be the exact payload sent with the request.
+func (s *Signer) Sign(req *http.Request, body []byte) error {
+	components := []string{"@method", "@target-uri"}
+	if len(body) > 0 {
+		req.Header.Set("Content-Digest", ContentDigest(body))
+		components = append(components, "content-digest")
+	}
+	if req.Header.Get("Content-Type") != "" {
+		components = append(components, "content-type")
+	}
+
+	// the nonce keeps identical requests signed within the same second apart
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "httpsig: error generating nonce")
+	}
+	params := &signatureParams{
+		components: components,
+		created:    time.Now().Unix(),
+		keyID:      s.keyID,
+		alg:        s.alg,
+		nonce:      base64.RawURLEncoding.EncodeToString(nonce),
+	}
+	base, err := signatureBase(req, targetURI(req), params)
+	if err != nil {
+		return err
+	}
+	sig, err := sign(s.key, s.alg, base)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature-Input", label+"="+params.String())
+	req.Header.Set("Signature", label+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+// KeyResolver returns the public key identified by keyID.
+type KeyResolver func(ctx context.Context, keyID string) (crypto.PublicKey, error)
+
+// Verify checks the signature of an incoming request against the key
+// returned by resolve and returns the identifier of that key. body must be
+// the payload read from the request. ErrNotSigned is returned when the
+// request carries no signature at all.
+func Verify(r *http.Request, body []byte, resolve KeyResolver, maxAge time.Duration) (string, error) {
+	keyID, _, err := verifyRequest(r, body, resolve, maxAge)
+	return keyID, err
+}
+
+// ReplayCache remembers the signatures verified while they are valid, so
+// that a request cannot be replayed within the validity of its signature.
+type ReplayCache struct {
+	mu   sync.Mutex
+	seen *ttlcache.Cache
+}
+
+// NewReplayCache returns an empty replay cache.
+func NewReplayCache() *ReplayCache {
+	seen := ttlcache.NewCache()
+	seen.SkipTTLExtensionOnHit(true)
+	return &ReplayCache{seen: seen}
+}
+
+// Verify verifies the request like the package level Verify, and rejects
+// a signature that was already verified within its validity.
+func (c *ReplayCache) Verify(r *http.Request, body []byte, resolve KeyResolver, maxAge time.Duration) (string, error) {
+	keyID, base, err := verifyRequest(r, body, resolve, maxAge)
+	if err != nil {
+		return "", err
+	}
+	if maxAge == 0 {
+		maxAge = DefaultMaxAge
+	}
+
+	// the signature base covers the creation time, the nonce if any and the
+	// signed components, while the signature itself may be re-encoded
+	sum := sha256.Sum256(base)
+	key := base64.StdEncoding.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.seen.Get(key); err == nil {
+		return "", errors.New("httpsig: replayed signature")
+	}
+	// a signature is accepted up to maxAge before and after its creation
+	_ = c.seen.SetWithTTL(key, struct{}{}, 2*maxAge)
+	return keyID, nil
+}
+
+// Close releases the resources of the cache.
+func (c *ReplayCache) Close() error {
+	return c.seen.Close()
+}
+
+func verifyRequest(r *http.Request, body []byte, resolve KeyResolver, maxAge time.Duration) (string, []byte, error) {
+	input, signature := r.Header.Get("Signature-Input"), r.Header.Get("Signature")
+	if input == "" && signature == "" {
+		return "", nil, ErrNotSigned
+	}
+
+	lbl, params, err := parseSignatureInput(input)
+	if err != nil {
+		return "", nil, err
+	}
+	sig, err := parseSignature(signature, lbl)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !params.covers("@method") || !params.covers("@target-uri") {
+		return "", nil, errors.New("httpsig: signature must cover @method and @target-uri")
+	}
+	if len(body) > 0 {
+		if !params.covers("content-digest") {
+			return "", nil, errors.New("httpsig: signature must cover content-digest")
+		}
+		if err := verifyContentDigest(r.Header.Get("Content-Digest"), body); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if maxAge == 0 {
+		maxAge = DefaultMaxAge
+	}
+	now := time.Now()
+	if params.created == 0 {
+		return "", nil, errors.New("httpsig: signature has no creation time")
+	}
+	created := time.Unix(params.created, 0)
+	if created.After(now.Add(maxAge)) || now.Sub(created) > maxAge {
+		return "", nil, errors.New("httpsig: signature creation time out of range")
+	}
+	if params.expires != 0 && now.After(time.Unix(params.expires, 0)) {
+		return "", nil, errors.New("httpsig: signature expired")
+	}
+	if params.keyID == "" {
+		return "", nil, errors.New("httpsig: signature has no keyid")
+	}
+
+	pub, err := resolve(r.Context(), params.keyID)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "httpsig: error resolving key "+params.keyID)
+	}
+	alg := params.alg
+	if alg == "" {
+		if alg, err = algorithmFor(pub); err != nil {
+			return "", nil, err
+		}
+	}
+
+	base, err := signatureBase(r, requestTargetURI(r), params)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := verify(pub, alg, base, sig); err != nil {
+		return "", nil, err
+	}
+	return params.keyID, base, nil
+}
+
+// ContentDigest returns the sha-256 Content-Digest header value for body.
+func ContentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+func verifyContentDigest(header string, body []byte) error {
+	if header == "" {
+		return errors.New("httpsig: missing Content-Digest header")
+	}
+	for _, member := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok {
+			continue
+		}
+		var sum []byte
+		switch strings.ToLower(name) {
+		case "sha-256":
+			s := sha256.Sum256(body)
+			sum = s[:]
+		case "sha-512":
+			s := sha512.Sum512(body)
+			sum = s[:]
+		default:
+			continue
+		}
+		expected, err := decodeByteSequence(value)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(sum, expected) != 1 {
+			return errors.New("httpsig: content digest mismatch")
+		}
+		return nil
+	}
+	return errors.New("httpsig: no supported digest in Content-Digest header")
+}
+
+// LoadPrivateKey reads a PEM encoded RSA, ECDSA P-256 or Ed25519 private
+// key from path.
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "httpsig: error reading private key")
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("httpsig: no PEM data found in " + path)
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if s, ok := key.(crypto.Signer); ok {
+			return s, nil
+		}
+		return nil, errors.New("httpsig: unsupported private key type")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("httpsig: unsupported private key format in " + path)
+}
+
+// ParsePublicKeyPEM parses a PEM encoded public key, as published in the
+// OCM discovery document.
+func ParsePublicKeyPEM(s string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("httpsig: invalid public key PEM")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "httpsig: error parsing public key")
+	}
+	if _, err := algorithmFor(pub); err != nil {
+		return nil, err
+	}
+	return pub, nil
+}
+
+func algorithmFor(pub crypto.PublicKey) (string, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return AlgRSAPSSSHA512, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return "", errors.New("httpsig: only P-256 ECDSA keys are supported")
+		}
+		return AlgECDSAP256SHA256, nil
+	case ed25519.PublicKey:
+		return AlgEd25519, nil
+	}
+	return "", errors.New("httpsig: unsupported key type")
+}
+
+func sign(key crypto.Signer, alg string, base []byte) ([]byte, error) {
+	switch alg {
+	case AlgRSAPSSSHA512:
+		h := sha512.Sum512(base)
+		return key.Sign(rand.Reader, h[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512})
+	case AlgECDSAP256SHA256:
+		k, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("httpsig: key does not match algorithm " + alg)
+		}
+		h := sha256.Sum256(base)
+		r, s, err := ecdsa.Sign(rand.Reader, k, h[:])
+		if err != nil {
+			return nil, err
+		}
+		// RFC 9421 uses the fixed-size concatenation of r and s
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	case AlgEd25519:
+		return key.Sign(rand.Reader, base, crypto.Hash(0))
+	}
+	return nil, errors.New("httpsig: unsupported algorithm " + alg)
+}
+
+func verify(pub crypto.PublicKey, alg string, base, sig []byte) error {
+	var ok bool
+	switch alg {
+	case AlgRSAPSSSHA512:
+		k, isRSA := pub.(*rsa.PublicKey)
+		if isRSA {
+			h := sha512.Sum512(base)
+			ok = rsa.VerifyPSS(k, crypto.SHA512, h[:], sig, nil) == nil
+		}
+	case AlgRSAv15SHA256:
+		k, isRSA := pub.(*rsa.PublicKey)
+		if isRSA {
+			h := sha256.Sum256(base)
+			ok = rsa.VerifyPKCS1v15(k, crypto.SHA256, h[:], sig) == nil
+		}
+	case AlgECDSAP256SHA256:
+		k, isEC := pub.(*ecdsa.PublicKey)
+		if isEC && len(sig) == 64 {
+			h := sha256.Sum256(base)
+			r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+			ok = ecdsa.Verify(k, h[:], r, s)
+		}
+	case AlgEd25519:
+		k, isEd := pub.(ed25519.PublicKey)
+		if isEd {
+			ok = ed25519.Verify(k, base, sig)
+		}
+	default:
+		return errors.New("httpsig: unsupported algorithm " + alg)
+	}
+	if !ok {
+		return errors.New("httpsig: invalid signature")
+	}
+	return nil
+}
+
+// signatureParams are the parameters of a single signature, as carried in
+// the Signature-Input header.
+type signatureParams struct {
+	components []string
+	created    int64
+	expires    int64
+	keyID      string
+	alg        string
+	nonce      string
+	// raw is the serialization received from the peer, which is used
+	// verbatim in the signature base when verifying.
+	raw string
+}
+
+func (p *signatureParams) covers(component string) bool {
+	for _, c := range p.components {
+		if c == component {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *signatureParams) String() string {
+	if p.raw != "" {
+		return p.raw
+	}
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, c := range p.components {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strconv.Quote(c))
+	}
+	b.WriteByte(')')
+	if p.created != 0 {
+		fmt.Fprintf(&b, ";created=%d", p.created)
+	}
+	if p.expires != 0 {
+		fmt.Fprintf(&b, ";expires=%d", p.expires)
+	}
+	if p.nonce != "" {
+		fmt.Fprintf(&b, ";nonce=%q", p.nonce)
+	}
+	if p.keyID != "" {
+		fmt.Fprintf(&b, ";keyid=%q", p.keyID)
+	}
+	if p.alg != "" {
+		fmt.Fprintf(&b, ";alg=%q", p.alg)
+	}
+	return b.String()
+}
+
+// signatureBase builds the signature base of RFC 9421 section 2.5.
+func signatureBase(r *http.Request, target string, p *signatureParams) ([]byte, error) {
+	var b bytes.Buffer
+	for _, c := range p.components {
+		var value string
+		switch c {
+		case "@method":
+			value = r.Method
+		case "@target-uri":
+			value = target
+		case "@authority":
+			value = strings.ToLower(r.Host)
+			if r.Host == "" && r.URL != nil {
+				value = strings.ToLower(r.URL.Host)
+			}
+		case "@path":
+			value = r.URL.EscapedPath()
+		case "@query":
+			value = "?" + r.URL.RawQuery
+		default:
+			if strings.HasPrefix(c, "@") {
+				return nil, errors.New("httpsig: unsupported derived component " + c)
+			}
+			values := r.Header.Values(c)
+			if len(values) == 0 {
+				return nil, errors.New("httpsig: covered header missing: " + c)
+			}
+			for i := range values {
+				values[i] = strings.TrimSpace(values[i])
+			}
+			value = strings.Join(values, ", ")
+		}
+		fmt.Fprintf(&b, "%q: %s\n", c, value)
+	}
+	fmt.Fprintf(&b, "%q: %s", "@signature-params", p.String())
+	return b.Bytes(), nil
+}
+
+// targetURI returns the target URI of an outgoing request.
+func targetURI(r *http.Request) string {
+	return r.URL.String()
+}
+
+// requestTargetURI reconstructs the target URI of an incoming request as
+// seen by the client, taking trusted reverse proxies into account. The
+// original request URI is used, as routing may have rewritten the URL path.
+func requestTargetURI(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+	if clientip.IsFromTrustedProxy(r) {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+		}
+		if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+			host = strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	return scheme + "://" + host + uri
+}
@@ -0,0 +1,235 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package httpsig
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const keyID = "https://cloud.example.org/ocm#signature"
+
+func newKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]crypto.Signer{
+		AlgRSAPSSSHA512:    rsaKey,
+		AlgECDSAP256SHA256: ecKey,
+		AlgEd25519:         edKey,
+	}
+}
+
+// signedRequest signs an outgoing request and returns it as received by a
+// server, i.e. with a relative URL and the original request URI.
+func signedRequest(t *testing.T, s *Signer, body string) *http.Request {
+	t.Helper()
+	out, err := http.NewRequest(http.MethodPost, "https://remote.example.org/ocm/shares?x=1", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Header.Set("Content-Type", "application/json")
+	if err := s.Sign(out, []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+
+	in := httptest.NewRequest(http.MethodPost, "/ocm/shares?x=1", strings.NewReader(body))
+	in.Host = "remote.example.org"
+	in.Header = out.Header.Clone()
+	// received through a reverse proxy terminating TLS on the same host
+	in.RemoteAddr = "127.0.0.1:4242"
+	in.Header.Set("X-Forwarded-Proto", "https")
+	return in
+}
+
+func resolverFor(s *Signer) KeyResolver {
+	return func(ctx context.Context, id string) (crypto.PublicKey, error) {
+		if id != s.KeyID() {
+			return nil, errors.New("unknown key")
+		}
+		pem, err := s.PublicKeyPEM()
+		if err != nil {
+			return nil, err
+		}
+		return ParsePublicKeyPEM(pem)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for alg, key := range newKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			s, err := NewSigner(keyID, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := `{"shareWith":"einstein@cernbox.cern.ch"}`
+			r := signedRequest(t, s, body)
+
+			if !strings.Contains(r.Header.Get("Signature-Input"), `alg="`+alg+`"`) {
+				t.Fatalf("unexpected Signature-Input %s", r.Header.Get("Signature-Input"))
+			}
+			id, err := Verify(r, []byte(body), resolverFor(s), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != keyID {
+				t.Fatalf("expected key id %s, got %s", keyID, id)
+			}
+		})
+	}
+}
+
+func TestVerifyFailures(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	s, _ := NewSigner(keyID, edKey)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	other, _ := NewSigner(keyID, otherKey)
+	body := `{"a":1}`
+
+	tests := map[string]func() (*http.Request, []byte, KeyResolver){
+		"tampered body": func() (*http.Request, []byte, KeyResolver) {
+			return signedRequest(t, s, body), []byte(`{"a":2}`), resolverFor(s)
+		},
+		"wrong key": func() (*http.Request, []byte, KeyResolver) {
+			return signedRequest(t, s, body), []byte(body), resolverFor(other)
+		},
+		"different target": func() (*http.Request, []byte, KeyResolver) {
+			r := signedRequest(t, s, body)
+			r.RequestURI = "/ocm/notifications"
+			return r, []byte(body), resolverFor(s)
+		},
+		"different method": func() (*http.Request, []byte, KeyResolver) {
+			r := signedRequest(t, s, body)
+			r.Method = http.MethodPut
+			return r, []byte(body), resolverFor(s)
+		},
+		"mangled signature": func() (*http.Request, []byte, KeyResolver) {
+			r := signedRequest(t, s, body)
+			r.Header.Set("Signature", "sig1=:AAAA:")
+			return r, []byte(body), resolverFor(s)
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, b, resolve := tt()
+			if _, err := Verify(r, b, resolve, 0); err == nil {
+				t.Fatal("expected verification to fail")
+			}
+		})
+	}
+}
+
+func TestVerifyForwardedByUntrustedClient(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	s, _ := NewSigner(keyID, edKey)
+	body := `{"a":1}`
+
+	r := signedRequest(t, s, body)
+	r.RemoteAddr = "192.0.2.1:4242"
+	if _, err := Verify(r, []byte(body), resolverFor(s), 0); err == nil {
+		t.Fatal("expected the forwarding headers of an untrusted client to be ignored")
+	}
+}
+
+func TestReplayCache(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	s, _ := NewSigner(keyID, edKey)
+	body := `{"a":1}`
+	c := NewReplayCache()
+	defer c.Close()
+
+	r := signedRequest(t, s, body)
+	if _, err := c.Verify(r, []byte(body), resolverFor(s), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Verify(r, []byte(body), resolverFor(s), 0); err == nil {
+		t.Fatal("expected a replayed signature to be rejected")
+	}
+	if _, err := c.Verify(signedRequest(t, s, body), []byte(body), resolverFor(s), 0); err != nil {
+		t.Fatalf("expected another signature to be accepted: %v", err)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	s, _ := NewSigner(keyID, edKey)
+	r := signedRequest(t, s, "")
+
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := Verify(r, nil, resolverFor(s), time.Millisecond); err == nil {
+		t.Fatal("expected stale signature to be rejected")
+	}
+}
+
+func TestVerifyNotSigned(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/ocm/shares", nil)
+	if _, err := Verify(r, nil, nil, 0); err != ErrNotSigned {
+		t.Fatalf("expected ErrNotSigned, got %v", err)
+	}
+}
+
+func TestParseSignatureInput(t *testing.T) {
+	header := `other=("@method");created=1, sig1=("@method" "@target-uri" "content-digest");created=1618884473;keyid="a,b;c";alg="ed25519"`
+	lbl, p, err := parseSignatureInput(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lbl != "sig1" {
+		t.Fatalf("expected sig1, got %s", lbl)
+	}
+	if len(p.components) != 3 || p.created != 1618884473 || p.keyID != "a,b;c" || p.alg != "ed25519" {
+		t.Fatalf("unexpected params %+v", p)
+	}
+	if p.String() != `("@method" "@target-uri" "content-digest");created=1618884473;keyid="a,b;c";alg="ed25519"` {
+		t.Fatalf("unexpected serialization %s", p.String())
+	}
+}
+
+func TestContentDigest(t *testing.T) {
+	// example from RFC 9530 section 2
+	const expected = "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"
+	if d := ContentDigest([]byte(`{"hello": "world"}`)); d != expected {
+		t.Fatalf("expected %s, got %s", expected, d)
+	}
+	if err := verifyContentDigest(expected, []byte(`{"hello": "world"}`)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package httpsig
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// This file contains a minimal parser for the structured field
+// dictionaries (RFC 8941) carried in the Signature-Input and Signature
+// headers.
+
+// splitDictionary splits a dictionary header in its members, keyed by
+// label and in the order of appearance. Commas inside quoted strings and
+// inner lists are not treated as separators.
+func splitDictionary(header string) ([]string, map[string]string, error) {
+	var labels []string
+	members := map[string]string{}
+
+	var inString, escaped bool
+	depth, start := 0, 0
+	add := func(member string) error {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			return nil
+		}
+		name, value, ok := strings.Cut(member, "=")
+		if !ok {
+			return errors.New("httpsig: malformed dictionary member " + member)
+		}
+		name = strings.TrimSpace(name)
+		if _, dup := members[name]; !dup {
+			labels = append(labels, name)
+		}
+		members[name] = strings.TrimSpace(value)
+		return nil
+	}
+
+	for i := 0; i < len(header); i++ {
+		c := header[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			if err := add(header[start:i]); err != nil {
+				return nil, nil, err
+			}
+			start = i + 1
+		}
+	}
+	if inString || depth != 0 {
+		return nil, nil, errors.New("httpsig: malformed dictionary")
+	}
+	if err := add(header[start:]); err != nil {
+		return nil, nil, err
+	}
+	return labels, members, nil
+}
+
+// parseSignatureInput returns the label and parameters of the signature to
+// verify. When several signatures are present, the one labeled like ours is
+// preferred, otherwise the first one is used.
+func parseSignatureInput(header string) (string, *signatureParams, error) {
+	labels, members, err := splitDictionary(header)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(labels) == 0 {
+		return "", nil, errors.New("httpsig: empty Signature-Input header")
+	}
+	lbl := labels[0]
+	if _, ok := members[label]; ok {
+		lbl = label
+	}
+	p, err := parseParams(members[lbl])
+	if err != nil {
+		return "", nil, err
+	}
+	return lbl, p, nil
+}
+
+func parseParams(value string) (*signatureParams, error) {
+	if !strings.HasPrefix(value, "(") {
+		return nil, errors.New("httpsig: signature parameters must be an inner list")
+	}
+	end := strings.IndexByte(value, ')')
+	if end < 0 {
+		return nil, errors.New("httpsig: unterminated inner list")
+	}
+
+	p := &signatureParams{raw: value}
+	for _, item := range strings.Fields(value[1:end]) {
+		c, err := strconv.Unquote(item)
+		if err != nil {
+			return nil, errors.New("httpsig: invalid component identifier " + item)
+		}
+		p.components = append(p.components, strings.ToLower(c))
+	}
+
+	for _, param := range splitParams(value[end+1:]) {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+		name, v, _ := strings.Cut(param, "=")
+		var err error
+		switch name {
+		case "created":
+			p.created, err = strconv.ParseInt(v, 10, 64)
+		case "expires":
+			p.expires, err = strconv.ParseInt(v, 10, 64)
+		case "keyid":
+			p.keyID, err = strconv.Unquote(v)
+		case "alg":
+			p.alg, err = strconv.Unquote(v)
+		case "nonce":
+			p.nonce, err = strconv.Unquote(v)
+		}
+		if err != nil {
+			return nil, errors.New("httpsig: invalid signature parameter " + param)
+		}
+	}
+	return p, nil
+}
+
+// splitParams splits a parameter list at the semicolons that are not part
+// of a quoted string.
+func splitParams(s string) []string {
+	var params []string
+	var inString, escaped bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case c == ';' && !inString:
+			params = append(params, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(params, s[start:])
+}
+
+// parseSignature returns the signature with the given label.
+func parseSignature(header, lbl string) ([]byte, error) {
+	_, members, err := splitDictionary(header)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := members[lbl]
+	if !ok {
+		return nil, errors.New("httpsig: no signature labeled " + lbl)
+	}
+	return decodeByteSequence(value)
+}
+
+func decodeByteSequence(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+		return nil, errors.New("httpsig: invalid byte sequence")
+	}
+	b, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+	if err != nil {
+		return nil, errors.Wrap(err, "httpsig: invalid byte sequence")
+	}
+	return b, nil
+}
@@ -517,25 +517,35 @@ func (m *mgr) ListShares(ctx context.Context, user *userpb.User, filters []*ocm.
 
 	for _, share := range m.model.Shares {
 		if utils.UserEqual(user.Id, share.Owner) || utils.UserEqual(user.Id, share.Creator) {
-			// no filter we return earlier
-			if len(filters) == 0 {
+			if matchesFilters(share, filters) {
 				ss = append(ss, share)
-			} else {
-				// check filters
-				// TODO(labkode): add the rest of filters.
-				for _, f := range filters {
-					if f.Type == ocm.ListOCMSharesRequest_Filter_TYPE_RESOURCE_ID {
-						if utils.ResourceIDEqual(share.ResourceId, f.GetResourceId()) {
-							ss = append(ss, share)
-						}
-					}
-				}
 			}
 		}
 	}
 	return ss, nil
 }
 
+// matchesFilters tells whether the share matches any of the filters.
+// TODO(labkode): add the rest of filters.
+func matchesFilters(share *ocm.Share, filters []*ocm.ListOCMSharesRequest_Filter) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, f := range filters {
+		switch f.Type {
+		case ocm.ListOCMSharesRequest_Filter_TYPE_RESOURCE_ID:
+			if utils.ResourceIDEqual(share.ResourceId, f.GetResourceId()) {
+				return true
+			}
+		case ocm.ListOCMSharesRequest_Filter_TYPE_OWNER:
+			if utils.UserEqual(share.Owner, f.GetOwner()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (m *mgr) StoreReceivedShare(ctx context.Context, share *ocm.ReceivedShare) (*ocm.ReceivedShare, error) {
 	m.Lock()
 	defer m.Unlock()
@@ -638,3 +648,66 @@ func (m *mgr) UpdateReceivedShare(ctx context.Context, user *userpb.User, share
 func (m *mgr) GetEmbeddedPayload(ctx context.Context, user *userpb.User, share *ocm.ReceivedShare) (string, error) {
 	return "", errtypes.NotSupported("operation not supported")
 }
+
+func (m *mgr) ListReceivedSharesByRemoteID(ctx context.Context, remoteShareID string) ([]*ocm.ReceivedShare, error) {
+	var rss []*ocm.ReceivedShare
+	m.Lock()
+	defer m.Unlock()
+
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+
+	for _, share := range m.model.ReceivedShares {
+		if share.RemoteShareId == remoteShareID {
+			rss = append(rss, cloneReceivedShare(share))
+		}
+	}
+	return rss, nil
+}
+
+func (m *mgr) DeleteReceivedShare(ctx context.Context, id *ocm.ShareId) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if err := m.load(); err != nil {
+		return err
+	}
+
+	if _, ok := m.model.ReceivedShares[id.OpaqueId]; !ok {
+		return share.ErrShareNotFound
+	}
+	delete(m.model.ReceivedShares, id.OpaqueId)
+	return m.save()
+}
+
+func (m *mgr) UpdateReceivedSharePermissions(ctx context.Context, id *ocm.ShareId, perms *ocm.SharePermissions) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if err := m.load(); err != nil {
+		return err
+	}
+
+	rs, ok := m.model.ReceivedShares[id.OpaqueId]
+	if !ok {
+		return share.ErrShareNotFound
+	}
+	var found bool
+	for _, p := range rs.Protocols {
+		if dav := p.GetWebdavOptions(); dav != nil {
+			dav.Permissions = perms
+			found = true
+		}
+	}
+	if !found {
+		return errtypes.NotSupported("received share has no webdav protocol")
+	}
+
+	now := time.Now().UnixNano()
+	rs.Mtime = &typespb.Timestamp{
+		Seconds: uint64(now / 1000000000),
+		Nanos:   uint32(now % 1000000000),
+	}
+	return m.save()
+}
@@ -341,3 +341,47 @@ func TestUpdateShareRejectsNewRequirementsOnLegacyShare(t *testing.T) {
 		t.Fatal("expected error when adding requirements to a legacy share")
 	}
 }
+
+func TestListSharesFilters(t *testing.T) {
+	mgr, cleanup := setup(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	user := testUser()
+	if _, err := mgr.StoreShare(ctx, testShare("tok1", legacyMethods())); err != nil {
+		t.Fatal(err)
+	}
+
+	ownerFilter := func(id *userpb.UserId) *ocm.ListOCMSharesRequest_Filter {
+		return &ocm.ListOCMSharesRequest_Filter{
+			Type: ocm.ListOCMSharesRequest_Filter_TYPE_OWNER,
+			Term: &ocm.ListOCMSharesRequest_Filter_Owner{Owner: id},
+		}
+	}
+	resourceFilter := &ocm.ListOCMSharesRequest_Filter{
+		Type: ocm.ListOCMSharesRequest_Filter_TYPE_RESOURCE_ID,
+		Term: &ocm.ListOCMSharesRequest_Filter_ResourceId{ResourceId: testResourceID()},
+	}
+
+	tests := []struct {
+		name    string
+		filters []*ocm.ListOCMSharesRequest_Filter
+		want    int
+	}{
+		{"no filter", nil, 1},
+		{"owner", []*ocm.ListOCMSharesRequest_Filter{ownerFilter(user.Id)}, 1},
+		{"other owner", []*ocm.ListOCMSharesRequest_Filter{ownerFilter(&userpb.UserId{OpaqueId: "other"})}, 0},
+		{"owner and resource", []*ocm.ListOCMSharesRequest_Filter{ownerFilter(user.Id), resourceFilter}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, err := mgr.ListShares(ctx, user, tt.filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(shares) != tt.want {
+				t.Fatalf("got %d shares, want %d", len(shares), tt.want)
+			}
+		})
+	}
+}
@@ -69,6 +69,20 @@ type Repository interface {
 	// embedded protocol. It is used to drive the background transfer of embedded
 	// share contents.
 	GetEmbeddedPayload(ctx context.Context, user *userpb.User, share *ocm.ReceivedShare) (string, error)
+
+	// ListReceivedSharesByRemoteID returns the received shares, along with their
+	// protocols, that the remote server identifies with the given id. It does not
+	// filter by user and is meant to process notifications from remote servers.
+	ListReceivedSharesByRemoteID(ctx context.Context, remoteShareID string) ([]*ocm.ReceivedShare, error)
+
+	// DeleteReceivedShare deletes the received share with the given id,
+	// following a notification from the remote server that unshared it.
+	DeleteReceivedShare(ctx context.Context, id *ocm.ShareId) error
+
+	// UpdateReceivedSharePermissions updates the permissions of the webdav
+	// protocol of the received share with the given id, following a
+	// notification from the remote server that changed them.
+	UpdateReceivedSharePermissions(ctx context.Context, id *ocm.ShareId, perms *ocm.SharePermissions) error
 }
 
 // ResourceIDFilter is an abstraction for creating filter by resource id.
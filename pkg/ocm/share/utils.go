@@ -19,6 +19,8 @@
 package share
 
 import (
+	"crypto/subtle"
+
 	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
 	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
@@ -129,3 +131,27 @@ func NewWebappAccessMethod(perms *provider.ResourcePermissions, reqs []string, a
 		},
 	}
 }
+
+// HasSharedSecret tells whether the given secret is the shared secret of
+// one of the protocols of a received share. Secrets are compared in constant
+// time, as they authenticate notifications sent by remote servers.
+func HasSharedSecret(s *ocm.ReceivedShare, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	for _, p := range s.Protocols {
+		var stored string
+		switch t := p.Term.(type) {
+		case *ocm.Protocol_WebdavOptions:
+			stored = t.WebdavOptions.SharedSecret
+		case *ocm.Protocol_WebappOptions:
+			stored = t.WebappOptions.SharedSecret
+		default:
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(secret)) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -70,3 +70,21 @@ func TestGetRoleTreatsWebappReadAsViewer(t *testing.T) {
 		t.Fatalf("GetRole() roleStr = %q, want %q", roleStr, "viewer")
 	}
 }
+
+func TestHasSharedSecret(t *testing.T) {
+	share := &ocm.ReceivedShare{
+		Protocols: []*ocm.Protocol{
+			ocmshare.NewWebDAVProtocol("https://example.org/webdav", "secret", nil, nil, nil),
+		},
+	}
+
+	if !ocmshare.HasSharedSecret(share, "secret") {
+		t.Fatal("HasSharedSecret() = false for the protocol secret")
+	}
+	if ocmshare.HasSharedSecret(share, "other") {
+		t.Fatal("HasSharedSecret() = true for a wrong secret")
+	}
+	if ocmshare.HasSharedSecret(share, "") {
+		t.Fatal("HasSharedSecret() = true for an empty secret")
+	}
+}
@@ -76,6 +76,8 @@ type config struct {
 	GatewaySVC        string `mapstructure:"gatewaysvc"`
 	OCMClientTimeout  int    `mapstructure:"ocm_timeout"`
 	OCMClientInsecure bool   `mapstructure:"ocm_insecure"`
+	OCMSigningKey     string `mapstructure:"ocm_signing_key"`
+	OCMSigningKeyID   string `mapstructure:"ocm_signing_key_id"`
 }
 
 func (c *config) ApplyDefaults() {
@@ -101,12 +103,19 @@ func New(ctx context.Context, m map[string]any) (storage.FS, error) {
 	disco := ttlcache.NewCache()
 	_ = disco.SetTTL(5 * time.Minute)
 
+	ocmClient := ocmd.NewClient(time.Duration(c.OCMClientTimeout)*time.Second, c.OCMClientInsecure)
+	if c.OCMSigningKey != "" {
+		if err := ocmClient.EnableSigning(c.OCMSigningKeyID, c.OCMSigningKey); err != nil {
+			return nil, err
+		}
+	}
+
 	d := &driver{
 		c:              &c,
 		gateway:        gateway,
 		ccache:         ttlcache.NewCache(),
 		discoveryCache: disco,
-		ocmClient:      ocmd.NewClient(time.Duration(c.OCMClientTimeout)*time.Second, c.OCMClientInsecure),
+		ocmClient:      ocmClient,
 	}
 	return d, nil
 }
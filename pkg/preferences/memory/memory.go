@@ -20,6 +20,7 @@ package memory
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/cs3org/reva/v3/pkg/appctx"
@@ -34,46 +35,143 @@ func init() {
 
 type mgr struct {
 	sync.RWMutex
-	keys map[string]map[string]string
+	// keys of every user, by namespace
+	keys map[string]map[string]map[string]string
 }
 
 // New returns an instance of the in-memory preferences manager.
 func New(ctx context.Context, m map[string]any) (preferences.Manager, error) {
-	return &mgr{keys: make(map[string]map[string]string)}, nil
+	return &mgr{keys: make(map[string]map[string]map[string]string)}, nil
 }
 
-func (m *mgr) SetKey(ctx context.Context, key, namespace, value string) error {
+func getUserKey(ctx context.Context) (string, error) {
 	u, ok := appctx.ContextGetUser(ctx)
 	if !ok {
-		return errtypes.UserRequired("preferences: error getting user from ctx")
+		return "", errtypes.UserRequired("preferences: error getting user from ctx")
+	}
+	return u.Id.OpaqueId, nil
+}
+
+func (m *mgr) SetKey(ctx context.Context, key, namespace, value string) error {
+	return m.SetKeys(ctx, map[string]string{key: value}, namespace)
+}
+
+func (m *mgr) GetKey(ctx context.Context, key, namespace string) (string, error) {
+	userKey, err := getUserKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	m.RLock()
+	defer m.RUnlock()
+
+	if value, ok := m.keys[userKey][namespace][key]; ok {
+		return value, nil
+	}
+	return "", errtypes.NotFound("preferences: key not found")
+}
+
+func (m *mgr) ListKeys(ctx context.Context, namespace string) ([]*preferences.Entry, error) {
+	userKey, err := getUserKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.RLock()
+	defer m.RUnlock()
+
+	entries := []*preferences.Entry{}
+	for ns, keys := range m.keys[userKey] {
+		if namespace != "" && ns != namespace {
+			continue
+		}
+		for k, v := range keys {
+			entries = append(entries, &preferences.Entry{Namespace: ns, Key: k, Value: v})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Namespace != entries[j].Namespace {
+			return entries[i].Namespace < entries[j].Namespace
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	return entries, nil
+}
+
+func (m *mgr) DeleteKey(ctx context.Context, key, namespace string) error {
+	userKey, err := getUserKey(ctx)
+	if err != nil {
+		return err
 	}
 	m.Lock()
 	defer m.Unlock()
 
-	userKey := u.Id.OpaqueId
-
+	keys := m.keys[userKey][namespace]
+	if _, ok := keys[key]; !ok {
+		return errtypes.NotFound("preferences: key not found")
+	}
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(m.keys[userKey], namespace)
+	}
 	if len(m.keys[userKey]) == 0 {
-		m.keys[userKey] = map[string]string{key: value}
-	} else {
-		m.keys[userKey][key] = value
+		delete(m.keys, userKey)
 	}
 	return nil
 }
 
-func (m *mgr) GetKey(ctx context.Context, key, namespace string) (string, error) {
-	u, ok := appctx.ContextGetUser(ctx)
-	if !ok {
-		return "", errtypes.UserRequired("preferences: error getting user from ctx")
+func (m *mgr) GetKeys(ctx context.Context, keys []string, namespace string) (map[string]string, error) {
+	userKey, err := getUserKey(ctx)
+	if err != nil {
+		return nil, err
 	}
 	m.RLock()
 	defer m.RUnlock()
 
-	userKey := u.Id.OpaqueId
-
-	if len(m.keys[userKey]) != 0 {
-		if value, ok := m.keys[userKey][key]; ok {
-			return value, nil
+	values := make(map[string]string, len(keys))
+	for _, k := range keys {
+		if v, ok := m.keys[userKey][namespace][k]; ok {
+			values[k] = v
 		}
 	}
-	return "", errtypes.NotFound("preferences: key not found")
+	return values, nil
+}
+
+func (m *mgr) SetKeys(ctx context.Context, values map[string]string, namespace string) error {
+	userKey, err := getUserKey(ctx)
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	if m.keys[userKey] == nil {
+		m.keys[userKey] = make(map[string]map[string]string)
+	}
+	if m.keys[userKey][namespace] == nil {
+		m.keys[userKey][namespace] = make(map[string]string)
+	}
+	for k, v := range values {
+		m.keys[userKey][namespace][k] = v
+	}
+	return nil
+}
+
+func (m *mgr) CompareAndSwapKey(ctx context.Context, key, namespace, old, value string) (bool, error) {
+	userKey, err := getUserKey(ctx)
+	if err != nil {
+		return false, err
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	if m.keys[userKey][namespace][key] != old {
+		return false, nil
+	}
+	if m.keys[userKey] == nil {
+		m.keys[userKey] = make(map[string]map[string]string)
+	}
+	if m.keys[userKey][namespace] == nil {
+		m.keys[userKey][namespace] = make(map[string]string)
+	}
+	m.keys[userKey][namespace][key] = value
+	return true, nil
 }
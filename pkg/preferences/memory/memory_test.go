@@ -0,0 +1,119 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package memory
+
+import (
+	"context"
+	"testing"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+)
+
+func userCtx(id string) context.Context {
+	return appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: id}})
+}
+
+func TestNamespaces(t *testing.T) {
+	m, _ := New(context.Background(), nil)
+	ctx := userCtx("einstein")
+
+	if err := m.SetKey(ctx, "lang", "core", "en"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetKey(ctx, "lang", "app", "de"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := m.GetKey(ctx, "lang", "core"); err != nil || v != "en" {
+		t.Fatalf("expected en, got %q (%v)", v, err)
+	}
+	if v, err := m.GetKey(ctx, "lang", "app"); err != nil || v != "de" {
+		t.Fatalf("expected de, got %q (%v)", v, err)
+	}
+	if _, err := m.GetKey(userCtx("marie"), "lang", "core"); err == nil {
+		t.Fatal("expected keys to be private to the user")
+	}
+}
+
+func TestListAndDelete(t *testing.T) {
+	m, _ := New(context.Background(), nil)
+	ctx := userCtx("einstein")
+
+	if err := m.SetKeys(ctx, map[string]string{"b": "2", "a": "1"}, "ns1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetKey(ctx, "c", "ns2", "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := m.ListKeys(ctx, "ns1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Key != "a" || entries[1].Key != "b" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	all, err := m.ListKeys(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[2].Namespace != "ns2" {
+		t.Fatalf("unexpected entries %+v", all)
+	}
+
+	values, err := m.GetKeys(ctx, []string{"a", "missing"}, "ns1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 1 || values["a"] != "1" {
+		t.Fatalf("unexpected values %v", values)
+	}
+
+	if err := m.DeleteKey(ctx, "a", "ns1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetKey(ctx, "a", "ns1"); err == nil {
+		t.Fatal("expected key to be deleted")
+	}
+	err = m.DeleteKey(ctx, "a", "ns1")
+	if _, ok := err.(errtypes.IsNotFound); !ok {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	m, _ := New(context.Background(), nil)
+	ctx := userCtx("einstein")
+
+	if ok, err := m.CompareAndSwapKey(ctx, "k", "ns", "", "1"); err != nil || !ok {
+		t.Fatalf("expected a missing key to be set, got %v (%v)", ok, err)
+	}
+	if ok, _ := m.CompareAndSwapKey(ctx, "k", "ns", "", "2"); ok {
+		t.Fatal("expected a set key not to be swapped from the empty value")
+	}
+	if ok, _ := m.CompareAndSwapKey(ctx, "k", "ns", "1", "2"); !ok {
+		t.Fatal("expected the key to be swapped")
+	}
+	if v, _ := m.GetKey(ctx, "k", "ns"); v != "2" {
+		t.Fatalf("expected 2, got %q", v)
+	}
+}
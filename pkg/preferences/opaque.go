@@ -0,0 +1,175 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package preferences
+
+import (
+	"encoding/json"
+
+	preferencespb "github.com/cs3org/go-cs3apis/cs3/preferences/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+)
+
+// The CS3 preferences API only defines SetKey and GetKey: the listing, the
+// bulk operations and the deletion travel in the opaque of their requests and
+// responses.
+const (
+	// ListOpaqueKey in a GetKeyRequest asks for all the preferences of the
+	// namespace of the key, or of all the namespaces if empty. They are
+	// returned in the response under the same key.
+	ListOpaqueKey = "list"
+	// KeysOpaqueKey in a GetKeyRequest asks for the values of several keys of
+	// the namespace of the key.
+	KeysOpaqueKey = "keys"
+	// ValuesOpaqueKey carries the values of several keys of a namespace, to be
+	// set in a SetKeyRequest, and as returned for KeysOpaqueKey in a
+	// GetKeyResponse.
+	ValuesOpaqueKey = "values"
+	// DeleteOpaqueKey in a SetKeyRequest asks for the deletion of the key.
+	DeleteOpaqueKey = "delete"
+)
+
+// NewListKeysRequest returns a request listing the preferences of a namespace,
+// or of all the namespaces if empty.
+func NewListKeysRequest(namespace string) *preferencespb.GetKeyRequest {
+	return &preferencespb.GetKeyRequest{
+		Key:    &preferencespb.PreferenceKey{Namespace: namespace},
+		Opaque: opaque(ListOpaqueKey, "plain", []byte("true")),
+	}
+}
+
+// NewGetKeysRequest returns a request getting several keys of a namespace.
+func NewGetKeysRequest(keys []string, namespace string) (*preferencespb.GetKeyRequest, error) {
+	v, err := json.Marshal(keys)
+	if err != nil {
+		return nil, err
+	}
+	return &preferencespb.GetKeyRequest{
+		Key:    &preferencespb.PreferenceKey{Namespace: namespace},
+		Opaque: opaque(KeysOpaqueKey, "json", v),
+	}, nil
+}
+
+// NewSetKeysRequest returns a request setting several keys of a namespace.
+func NewSetKeysRequest(values map[string]string, namespace string) (*preferencespb.SetKeyRequest, error) {
+	v, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return &preferencespb.SetKeyRequest{
+		Key:    &preferencespb.PreferenceKey{Namespace: namespace},
+		Opaque: opaque(ValuesOpaqueKey, "json", v),
+	}, nil
+}
+
+// NewDeleteKeyRequest returns a request deleting a key of a namespace.
+func NewDeleteKeyRequest(key, namespace string) *preferencespb.SetKeyRequest {
+	return &preferencespb.SetKeyRequest{
+		Key:    &preferencespb.PreferenceKey{Namespace: namespace, Key: key},
+		Opaque: opaque(DeleteOpaqueKey, "plain", []byte("true")),
+	}
+}
+
+// IsListRequest tells whether a GetKeyRequest lists the preferences.
+func IsListRequest(req *preferencespb.GetKeyRequest) bool {
+	_, ok := req.Opaque.GetMap()[ListOpaqueKey]
+	return ok
+}
+
+// IsDeleteRequest tells whether a SetKeyRequest deletes the key.
+func IsDeleteRequest(req *preferencespb.SetKeyRequest) bool {
+	_, ok := req.Opaque.GetMap()[DeleteOpaqueKey]
+	return ok
+}
+
+// RequestedKeys returns the keys asked for in a GetKeyRequest, nil if none.
+func RequestedKeys(req *preferencespb.GetKeyRequest) ([]string, error) {
+	var keys []string
+	if err := decode(req.Opaque, KeysOpaqueKey, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RequestedValues returns the values to be set in a SetKeyRequest, nil if none.
+func RequestedValues(req *preferencespb.SetKeyRequest) (map[string]string, error) {
+	var values map[string]string
+	if err := decode(req.Opaque, ValuesOpaqueKey, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// EntriesOpaque returns the opaque carrying a list of preferences.
+func EntriesOpaque(entries []*Entry) (*types.Opaque, error) {
+	v, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	return opaque(ListOpaqueKey, "json", v), nil
+}
+
+// ValuesOpaque returns the opaque carrying the values of several keys.
+func ValuesOpaque(values map[string]string) (*types.Opaque, error) {
+	v, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return opaque(ValuesOpaqueKey, "json", v), nil
+}
+
+// ListedEntries returns the preferences listed in a GetKeyResponse.
+func ListedEntries(res *preferencespb.GetKeyResponse) ([]*Entry, error) {
+	var entries []*Entry
+	if err := decode(res.Opaque, ListOpaqueKey, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ReturnedValues returns the values of several keys returned in a GetKeyResponse.
+func ReturnedValues(res *preferencespb.GetKeyResponse) (map[string]string, error) {
+	values := map[string]string{}
+	if err := decode(res.Opaque, ValuesOpaqueKey, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func opaque(key, decoder string, value []byte) *types.Opaque {
+	return &types.Opaque{
+		Map: map[string]*types.OpaqueEntry{
+			key: {Decoder: decoder, Value: value},
+		},
+	}
+}
+
+func decode(o *types.Opaque, key string, v any) error {
+	e, ok := o.GetMap()[key]
+	if !ok {
+		return nil
+	}
+	if e.Decoder != "json" {
+		return errtypes.BadRequest("preferences: unexpected decoder " + e.Decoder + " for " + key)
+	}
+	if err := json.Unmarshal(e.Value, v); err != nil {
+		return errtypes.BadRequest("preferences: invalid " + key + ": " + err.Error())
+	}
+	return nil
+}
@@ -20,12 +20,46 @@ package preferences
 
 import (
 	"context"
+	"slices"
 )
 
+// TOTPNamespace holds the second factor enrollment of the users.
+const TOTPNamespace = "totp"
+
+// ReservedNamespaces hold the preferences managed by reva itself, which the
+// users can neither read nor change through the preferences APIs.
+var ReservedNamespaces = []string{TOTPNamespace}
+
+// IsReserved reports whether the namespace is one of the ReservedNamespaces.
+func IsReserved(namespace string) bool {
+	return slices.Contains(ReservedNamespaces, namespace)
+}
+
+// Entry is a preference of a user.
+type Entry struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+}
+
 // Manager defines an interface for a preferences manager.
 type Manager interface {
 	// SetKey sets a key under a specified namespace.
 	SetKey(ctx context.Context, key, namespace, value string) error
 	// GetKey returns the value for a combination of key and namespace, if set.
 	GetKey(ctx context.Context, key, namespace string) (string, error)
+	// ListKeys returns the preferences set under a namespace,
+	// or under all the namespaces if the namespace is empty.
+	ListKeys(ctx context.Context, namespace string) ([]*Entry, error)
+	// DeleteKey removes a key from a namespace.
+	DeleteKey(ctx context.Context, key, namespace string) error
+	// GetKeys returns the values of several keys of a namespace.
+	// The keys that are not set are left out.
+	GetKeys(ctx context.Context, keys []string, namespace string) (map[string]string, error)
+	// SetKeys sets several keys under a namespace at once.
+	SetKeys(ctx context.Context, values map[string]string, namespace string) error
+	// CompareAndSwapKey sets a key under a namespace only if its current value
+	// is old, a key that is not set having the empty value. It reports whether
+	// the key was set.
+	CompareAndSwapKey(ctx context.Context, key, namespace, old, value string) (bool, error)
 }
@@ -141,9 +141,147 @@ func (m *mgr) GetKey(ctx context.Context, key, namespace string) (string, error)
 	log.Debug().Err(res.Error).Msgf("[Preferences] Fetched %s=%s in namespace %s for user %s", key, fetchedPreference.ConfigValue, namespace, user.Id.OpaqueId)
 
 	if res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return "", errtypes.NotFound("preferences: key not found")
+		}
 		log.Error().Err(res.Error).Msg("Preferences GetKey: database error")
 		return "", res.Error
 	}
 
 	return fetchedPreference.ConfigValue, nil
 }
+
+func (m *mgr) ListKeys(ctx context.Context, namespace string) ([]*preferences.Entry, error) {
+	user, ok := appctx.ContextGetUser(ctx)
+	if !ok {
+		return nil, errtypes.UserRequired("preferences: error getting user from ctx")
+	}
+	query := m.db.Model(&Preference{}).
+		Where("user_id = ?", user.Id.OpaqueId)
+	if namespace != "" {
+		query = query.Where("namespace = ?", namespace)
+	}
+
+	var fetched []Preference
+	if err := query.Order("namespace, config_key").Find(&fetched).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]*preferences.Entry, 0, len(fetched))
+	for _, p := range fetched {
+		entries = append(entries, &preferences.Entry{Namespace: p.Namespace, Key: p.ConfigKey, Value: p.ConfigValue})
+	}
+	return entries, nil
+}
+
+func (m *mgr) DeleteKey(ctx context.Context, key, namespace string) error {
+	log := appctx.GetLogger(ctx)
+
+	user, ok := appctx.ContextGetUser(ctx)
+	if !ok {
+		return errtypes.UserRequired("preferences: error getting user from ctx")
+	}
+	log.Debug().Msgf("[Preferences] Deleting %s in namespace %s for user %s", key, namespace, user.Id.OpaqueId)
+
+	// the rows are removed for good, a soft-deleted row would collide
+	// with the unique index when setting the key again
+	res := m.db.Unscoped().
+		Where("user_id = ?", user.Id.OpaqueId).
+		Where("namespace = ?", namespace).
+		Where("config_key = ?", key).
+		Delete(&Preference{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errtypes.NotFound("preferences: key not found")
+	}
+	return nil
+}
+
+func (m *mgr) GetKeys(ctx context.Context, keys []string, namespace string) (map[string]string, error) {
+	user, ok := appctx.ContextGetUser(ctx)
+	if !ok {
+		return nil, errtypes.UserRequired("preferences: error getting user from ctx")
+	}
+
+	values := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	var fetched []Preference
+	err := m.db.Model(&Preference{}).
+		Where("user_id = ?", user.Id.OpaqueId).
+		Where("namespace = ?", namespace).
+		Where("config_key IN ?", keys).
+		Find(&fetched).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range fetched {
+		values[p.ConfigKey] = p.ConfigValue
+	}
+	return values, nil
+}
+
+func (m *mgr) SetKeys(ctx context.Context, values map[string]string, namespace string) error {
+	user, ok := appctx.ContextGetUser(ctx)
+	if !ok {
+		return errtypes.UserRequired("preferences: error getting user from ctx")
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	prefs := make([]*Preference, 0, len(values))
+	for k, v := range values {
+		prefs = append(prefs, &Preference{
+			UserId:      user.Id.OpaqueId,
+			Namespace:   namespace,
+			ConfigKey:   k,
+			ConfigValue: v,
+		})
+	}
+	return m.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "user_id"},
+			{Name: "namespace"},
+			{Name: "config_key"},
+		},
+		DoUpdates: clause.AssignmentColumns([]string{"config_value", "updated_at"}),
+	}).Create(prefs).Error
+}
+
+func (m *mgr) CompareAndSwapKey(ctx context.Context, key, namespace, old, value string) (bool, error) {
+	user, ok := appctx.ContextGetUser(ctx)
+	if !ok {
+		return false, errtypes.UserRequired("preferences: error getting user from ctx")
+	}
+
+	res := m.db.Model(&Preference{}).
+		Where("user_id = ?", user.Id.OpaqueId).
+		Where("namespace = ?", namespace).
+		Where("config_key = ?", key).
+		Where("config_value = ?", old).
+		Update("config_value", value)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 || old != "" {
+		return res.RowsAffected > 0, nil
+	}
+
+	// a key that is not set has the empty value: it is created, unless
+	// another writer created it meanwhile
+	res = m.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&Preference{
+		UserId:      user.Id.OpaqueId,
+		Namespace:   namespace,
+		ConfigKey:   key,
+		ConfigValue: value,
+	})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
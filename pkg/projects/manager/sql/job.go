@@ -0,0 +1,622 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	grouppb "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpcv1beta1 "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	registry "github.com/cs3org/go-cs3apis/cs3/storage/registry/v1beta1"
+	"github.com/cs3org/reva/v3/internal/http/services/datagateway"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/httpclient"
+	"github.com/cs3org/reva/v3/pkg/permissions"
+	"github.com/cs3org/reva/v3/pkg/projects"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/v3/pkg/rjobs"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// LifecycleJob is the name of the on-demand job completing the creation, the
+// archiving and the restore of the projects.
+const LifecycleJob = "projects.lifecycle"
+
+func init() {
+	if err := rjobs.RegisterOnDemand(LifecycleJob, NewLifecycleJob); err != nil {
+		panic(err)
+	}
+}
+
+// lifecycleParams are the parameters of a run of the lifecycle job.
+type lifecycleParams struct {
+	ProjectID string          `mapstructure:"project_id"`
+	Action    projects.Action `mapstructure:"action"`
+}
+
+type lifecycleJob struct {
+	m      *ProjectsManager
+	client *httpclient.Client
+}
+
+// NewLifecycleJob returns the lifecycle job, configured as the sql projects
+// catalogue, whose database connections it shares.
+func NewLifecycleJob(ctx context.Context, m map[string]any) (rjobs.Job, error) {
+	mgr, err := Open(m)
+	if err != nil {
+		return nil, err
+	}
+	if mgr.c.Lifecycle.ServiceAccount == "" {
+		return nil, errors.New("sql: the lifecycle job requires a service account")
+	}
+
+	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: mgr.c.Lifecycle.Insecure}}
+	return &lifecycleJob{
+		m:      mgr,
+		client: httpclient.New(httpclient.RoundTripper(tr)),
+	}, nil
+}
+
+// copyStats counts what a run copied, and what it removed from the copy
+// of a previous run as it was removed from the source meanwhile.
+type copyStats struct {
+	files  int
+	bytes  int64
+	pruned int
+}
+
+// Run completes a step of the lifecycle of a project and moves the project
+// to its next status. The data are moved by the storage when the source and
+// the destination share a storage provider. Otherwise they are copied before
+// the source is removed and the files already copied are skipped, so a
+// failed run can be retried.
+func (j *lifecycleJob) Run(ctx context.Context, p rjobs.Params) (rjobs.Params, error) {
+	var params lifecycleParams
+	if err := mapstructure.Decode(map[string]any(p), &params); err != nil {
+		return nil, errors.Wrap(err, "sql: decoding params failed")
+	}
+	t, ok := transitions[params.Action]
+	if !ok || params.ProjectID == "" || params.Action == projects.ActionReject {
+		return nil, errors.Errorf("sql: invalid lifecycle params %+v", params)
+	}
+	log := appctx.GetLogger(ctx).With().Str("project_id", params.ProjectID).Str("action", string(params.Action)).Logger()
+
+	project := &Project{}
+	res := j.m.db.Where("id = ?", params.ProjectID).First(project)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		log.Warn().Msg("sql: the project is gone")
+		return nil, nil
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if project.Status != t.to {
+		// a redelivery of a completed run
+		log.Info().Str("status", project.Status.AsString()).Msg("sql: the step of the project is already completed")
+		return nil, nil
+	}
+
+	gw, err := pool.GetGatewayServiceClient(pool.Endpoint(j.m.c.GatewaySvc))
+	if err != nil {
+		return nil, err
+	}
+	svcCtx, _, err := impersonate(ctx, gw, j.m.c.Lifecycle.ServiceAccount, j.m.c.Lifecycle.MachineSecret)
+	if err != nil {
+		return nil, errors.Wrap(err, "sql: cannot impersonate the service account")
+	}
+
+	var (
+		next    projects.ProjectStatus
+		stats   copyStats
+		updates map[string]any
+	)
+	switch params.Action {
+	case projects.ActionApprove:
+		next = projects.ProjectStatusActive
+		storageID, err := j.create(svcCtx, gw, project)
+		if err != nil {
+			return nil, err
+		}
+		updates = map[string]any{"storage_id": storageID}
+	case projects.ActionArchive:
+		next = projects.ProjectStatusArchived
+		archivePath := j.m.archivePath(project)
+		if err := j.waitReadOnly(ctx, project); err != nil {
+			return nil, err
+		}
+		if err := j.move(svcCtx, gw, project.Path, archivePath, &stats); err != nil {
+			return nil, err
+		}
+		updates = map[string]any{"archive_path": archivePath, "archived_at": time.Now()}
+	case projects.ActionRestore:
+		next = projects.ProjectStatusActive
+		if err := j.waitReadOnly(ctx, project); err != nil {
+			return nil, err
+		}
+		if err := j.move(svcCtx, gw, project.ArchivePath, project.Path, &stats); err != nil {
+			return nil, err
+		}
+		updates = map[string]any{"archive_path": "", "archived_at": nil}
+	}
+
+	updates["status"] = next
+	if res := j.m.db.Model(&Project{}).Where("id = ? AND status = ?", project.ID, t.to).Updates(updates); res.Error != nil {
+		return nil, res.Error
+	}
+	if err := j.m.recordTransition(ctx, project, t.to, next, "", ""); err != nil {
+		return nil, err
+	}
+	log.Info().Int("files", stats.files).Int64("bytes", stats.bytes).Int("pruned", stats.pruned).Msg("sql: project step completed")
+	return rjobs.Params{"status": next.AsString(), "files": stats.files, "bytes": stats.bytes, "pruned": stats.pruned}, nil
+}
+
+// waitReadOnly waits until the writes to the project are blocked by the
+// readonlyprojects interceptors, which cache the list of the read-only
+// projects for up to their refresh interval.
+func (j *lifecycleJob) waitReadOnly(ctx context.Context, p *Project) error {
+	wait := time.Until(p.UpdatedAt.Add(time.Duration(j.m.c.Lifecycle.ReadOnlyDelay) * time.Second))
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// create creates the folder of a project, grants it to its owner and to its
+// e-groups, and returns the id of the storage it lives in.
+func (j *lifecycleJob) create(ctx context.Context, gw gateway.GatewayAPIClient, project *Project) (string, error) {
+	p := project.Path
+	if err := createContainer(ctx, gw, path.Dir(p)); err != nil {
+		return "", err
+	}
+	if err := createContainer(ctx, gw, p); err != nil {
+		return "", err
+	}
+
+	grants, err := projectGrants(ctx, gw, project)
+	if err != nil {
+		return "", err
+	}
+	sp, ref, err := j.storageProvider(ctx, gw, p)
+	if err != nil {
+		return "", err
+	}
+	for _, g := range grants {
+		if err := addGrant(ctx, sp, ref, g); err != nil {
+			return "", errors.Wrap(err, "error granting "+p)
+		}
+	}
+	return ref.ResourceId.StorageId, nil
+}
+
+// projectGrants returns the grants of the owner and of the e-groups of the
+// project, with the permissions the catalogue gives to their members.
+func projectGrants(ctx context.Context, gw gateway.GatewayAPIClient, p *Project) ([]*provider.Grant, error) {
+	res, err := gw.GetUser(ctx, &userpb.GetUserRequest{
+		UserId:                 &userpb.UserId{OpaqueId: p.Owner},
+		SkipFetchingUserGroups: true,
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case res.Status.Code != rpcv1beta1.Code_CODE_OK:
+		return nil, errtypes.InternalError("error getting the owner " + p.Owner + ": " + res.Status.Message)
+	}
+	grants := []*provider.Grant{{
+		Grantee: &provider.Grantee{
+			Type: provider.GranteeType_GRANTEE_TYPE_USER,
+			Id:   &provider.Grantee_UserId{UserId: res.User.Id},
+		},
+		Permissions: permissions.NewManagerRole().CS3ResourcePermissions(),
+	}}
+
+	for _, g := range []struct {
+		name string
+		role *permissions.Role
+	}{
+		{name: p.Readers, role: permissions.NewViewerRole()},
+		{name: p.Writers, role: permissions.NewEditorRole()},
+		{name: p.Admins, role: permissions.NewManagerRole()},
+	} {
+		if g.name == "" {
+			continue
+		}
+		res, err := gw.GetGroupByClaim(ctx, &grouppb.GetGroupByClaimRequest{
+			Claim:               "group_name",
+			Value:               g.name,
+			SkipFetchingMembers: true,
+		})
+		switch {
+		case err != nil:
+			return nil, err
+		case res.Status.Code != rpcv1beta1.Code_CODE_OK:
+			return nil, errtypes.InternalError("error getting the e-group " + g.name + ": " + res.Status.Message)
+		}
+		grants = append(grants, &provider.Grant{
+			Grantee: &provider.Grantee{
+				Type: provider.GranteeType_GRANTEE_TYPE_GROUP,
+				Id:   &provider.Grantee_GroupId{GroupId: res.Group.Id},
+			},
+			Permissions: g.role.CS3ResourcePermissions(),
+		})
+	}
+	return grants, nil
+}
+
+// maxCopyPasses is how many times the tree of a project is copied over
+// within a run when it keeps changing meanwhile.
+const maxCopyPasses = 3
+
+// move moves the tree at src to dst. The storage moves it when both share a
+// storage provider, keeping the ids of the resources and hence the shares,
+// the versions and the metadata attached to them. Across storage providers,
+// the tree is copied then src is removed: the copies get new ids, so the
+// shares and the links of the project are orphaned and its versions, its
+// recycle bin and its metadata are lost, while the grants of the folders
+// are re-created. A tree found at dst only was moved by a previous run. The
+// tree is copied again if its etag changed during the copy, and src is
+// only removed once a copy of an unchanged tree completed.
+func (j *lifecycleJob) move(ctx context.Context, gw gateway.GatewayAPIClient, src, dst string, stats *copyStats) error {
+	info, err := stat(ctx, gw, src)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		if dstInfo, err := stat(ctx, gw, dst); err != nil || dstInfo == nil {
+			return errtypes.NotFound(src)
+		}
+		return nil
+	}
+
+	if err := createContainer(ctx, gw, path.Dir(dst)); err != nil {
+		return err
+	}
+	res, err := gw.Move(ctx, &provider.MoveRequest{
+		Source:      &provider.Reference{Path: src},
+		Destination: &provider.Reference{Path: dst},
+	})
+	switch {
+	case err != nil:
+		return err
+	case res.Status.Code == rpcv1beta1.Code_CODE_OK:
+		return nil
+	case res.Status.Code != rpcv1beta1.Code_CODE_UNIMPLEMENTED:
+		// the gateway only moves within a storage provider
+		return errtypes.InternalError("error moving " + src + ": " + res.Status.Message)
+	}
+	appctx.GetLogger(ctx).Warn().Str("src", src).Str("dst", dst).Msg("sql: copying the project across storage providers, its shares and versions are not kept")
+
+	for pass := 1; ; pass++ {
+		if err := j.copyTree(ctx, gw, src, dst, stats); err != nil {
+			return err
+		}
+		after, err := stat(ctx, gw, src)
+		if err != nil {
+			return err
+		}
+		if after == nil {
+			return errtypes.InternalError(src + " disappeared while being copied")
+		}
+		if after.Etag == info.Etag {
+			break
+		}
+		if pass == maxCopyPasses {
+			return errtypes.InternalError(src + " kept changing while being copied")
+		}
+		info = after
+	}
+	return remove(ctx, gw, src)
+}
+
+// copyTree copies the tree at src to dst, along with the grants of its
+// folders, and removes from dst what is no longer in src.
+func (j *lifecycleJob) copyTree(ctx context.Context, gw gateway.GatewayAPIClient, src, dst string, stats *copyStats) error {
+	if err := createContainer(ctx, gw, dst); err != nil {
+		return err
+	}
+	if err := j.copyGrants(ctx, gw, src, dst); err != nil {
+		return err
+	}
+	infos, err := listContainer(ctx, gw, src)
+	if err != nil {
+		return err
+	}
+	copies, err := listContainer(ctx, gw, dst)
+	if err != nil {
+		return err
+	}
+
+	// the copies of a previous run whose source was removed since, or
+	// replaced by a resource of another type, are pruned
+	sources := make(map[string]provider.ResourceType, len(infos))
+	for _, info := range infos {
+		sources[path.Base(info.Path)] = info.Type
+	}
+	existing := make(map[string]*provider.ResourceInfo, len(copies))
+	for _, info := range copies {
+		name := path.Base(info.Path)
+		if t, ok := sources[name]; ok && t == info.Type {
+			existing[name] = info
+			continue
+		}
+		if err := remove(ctx, gw, info.Path); err != nil {
+			return err
+		}
+		stats.pruned++
+	}
+
+	for _, info := range infos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := path.Base(info.Path)
+		target := path.Join(dst, name)
+		if info.Type == provider.ResourceType_RESOURCE_TYPE_CONTAINER {
+			if err := j.copyTree(ctx, gw, info.Path, target, stats); err != nil {
+				return err
+			}
+			continue
+		}
+		if upToDate(info, existing[name]) {
+			continue
+		}
+		if err := j.copyFile(ctx, gw, info.Path, target); err != nil {
+			return errors.Wrap(err, "error copying "+info.Path)
+		}
+		stats.files++
+		stats.bytes += int64(info.Size)
+	}
+	return nil
+}
+
+// copyGrants re-creates the grants of the folder at src on the folder at
+// dst.
+func (j *lifecycleJob) copyGrants(ctx context.Context, gw gateway.GatewayAPIClient, src, dst string) error {
+	sp, ref, err := j.storageProvider(ctx, gw, src)
+	if err != nil {
+		return err
+	}
+	res, err := sp.ListGrants(ctx, &provider.ListGrantsRequest{Ref: ref})
+	switch {
+	case err != nil:
+		return err
+	case res.Status.Code != rpcv1beta1.Code_CODE_OK:
+		return errtypes.InternalError("error listing the grants of " + src + ": " + res.Status.Message)
+	case len(res.Grants) == 0:
+		return nil
+	}
+
+	dstSP, dstRef, err := j.storageProvider(ctx, gw, dst)
+	if err != nil {
+		return err
+	}
+	for _, g := range res.Grants {
+		if err := addGrant(ctx, dstSP, dstRef, g); err != nil {
+			return errors.Wrap(err, "error copying the grants of "+src)
+		}
+	}
+	return nil
+}
+
+// storageProvider returns the storage provider of the resource at the given
+// path along with a reference to the resource, as the gateway does not
+// expose the grants.
+func (j *lifecycleJob) storageProvider(ctx context.Context, gw gateway.GatewayAPIClient, p string) (provider.ProviderAPIClient, *provider.Reference, error) {
+	info, err := stat(ctx, gw, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info == nil {
+		return nil, nil, errtypes.NotFound(p)
+	}
+	ref := &provider.Reference{ResourceId: info.Id}
+
+	reg, err := pool.GetStorageRegistryClient(pool.Endpoint(j.m.c.Lifecycle.StorageRegistrySvc))
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := reg.GetStorageProviders(ctx, &registry.GetStorageProvidersRequest{Ref: ref})
+	switch {
+	case err != nil:
+		return nil, nil, err
+	case res.Status.Code != rpcv1beta1.Code_CODE_OK || len(res.Providers) == 0:
+		return nil, nil, errtypes.InternalError("no storage provider found for " + p + ": " + res.Status.Message)
+	}
+	sp, err := pool.GetStorageProviderServiceClient(pool.Endpoint(res.Providers[0].Address))
+	if err != nil {
+		return nil, nil, err
+	}
+	return sp, ref, nil
+}
+
+// addGrant adds the grant on the resource, or updates it if the grantee
+// already has one.
+func addGrant(ctx context.Context, sp provider.ProviderAPIClient, ref *provider.Reference, g *provider.Grant) error {
+	res, err := sp.AddGrant(ctx, &provider.AddGrantRequest{Ref: ref, Grant: g})
+	switch {
+	case err != nil:
+		return err
+	case res.Status.Code == rpcv1beta1.Code_CODE_OK:
+		return nil
+	case res.Status.Code != rpcv1beta1.Code_CODE_ALREADY_EXISTS:
+		return errtypes.InternalError("error adding a grant: " + res.Status.Message)
+	}
+	upd, err := sp.UpdateGrant(ctx, &provider.UpdateGrantRequest{Ref: ref, Grant: g})
+	switch {
+	case err != nil:
+		return err
+	case upd.Status.Code != rpcv1beta1.Code_CODE_OK:
+		return errtypes.InternalError("error updating a grant: " + upd.Status.Message)
+	}
+	return nil
+}
+
+// upToDate tells whether the copy of a file made by a previous run is still
+// current: it has the same checksum if both storages report one of the same
+// type, or else the same size and was written after the last change of the
+// source.
+func upToDate(src, dst *provider.ResourceInfo) bool {
+	if dst == nil || dst.Size != src.Size {
+		return false
+	}
+	if sum := src.GetChecksum(); sum.GetSum() != "" && sum.GetType() == dst.GetChecksum().GetType() {
+		return sum.GetSum() == dst.GetChecksum().GetSum()
+	}
+	return dst.GetMtime().GetSeconds() > src.GetMtime().GetSeconds()
+}
+
+// copyFile streams a file through the data gateway.
+func (j *lifecycleJob) copyFile(ctx context.Context, gw gateway.GatewayAPIClient, src, dst string) error {
+	downRes, err := gw.InitiateFileDownload(ctx, &provider.InitiateFileDownloadRequest{
+		Ref: &provider.Reference{Path: src},
+	})
+	switch {
+	case err != nil:
+		return err
+	case downRes.Status.Code != rpcv1beta1.Code_CODE_OK:
+		return errtypes.InternalError(downRes.Status.Message)
+	}
+	var downEndpoint, downToken string
+	for _, p := range downRes.Protocols {
+		if p.Protocol == "simple" {
+			downEndpoint, downToken = p.DownloadEndpoint, p.Token
+		}
+	}
+	if downEndpoint == "" {
+		return errtypes.InternalError("simple download not supported")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(datagateway.TokenTransportHeader, downToken)
+	res, err := j.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error downloading")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return errtypes.InternalError(fmt.Sprintf("download responded %s", res.Status))
+	}
+
+	upRes, err := gw.InitiateFileUpload(ctx, &provider.InitiateFileUploadRequest{
+		Ref: &provider.Reference{Path: dst},
+	})
+	switch {
+	case err != nil:
+		return err
+	case upRes.Status.Code != rpcv1beta1.Code_CODE_OK:
+		return errtypes.InternalError(upRes.Status.Message)
+	}
+	var upEndpoint, upToken string
+	for _, p := range upRes.Protocols {
+		if p.Protocol == "simple" {
+			upEndpoint, upToken = p.UploadEndpoint, p.Token
+		}
+	}
+	if upEndpoint == "" {
+		return errtypes.InternalError("simple upload not supported")
+	}
+
+	upReq, err := http.NewRequestWithContext(ctx, http.MethodPut, upEndpoint, res.Body)
+	if err != nil {
+		return err
+	}
+	upReq.ContentLength = res.ContentLength
+	upReq.Header.Set(datagateway.TokenTransportHeader, upToken)
+	putRes, err := j.client.Do(upReq)
+	if err != nil {
+		return errors.Wrap(err, "error uploading")
+	}
+	defer putRes.Body.Close()
+	if putRes.StatusCode != http.StatusOK && putRes.StatusCode != http.StatusCreated {
+		return errtypes.InternalError(fmt.Sprintf("upload responded %s", putRes.Status))
+	}
+	return nil
+}
+
+func createContainer(ctx context.Context, gw gateway.GatewayAPIClient, p string) error {
+	res, err := gw.CreateContainer(ctx, &provider.CreateContainerRequest{
+		Ref: &provider.Reference{Path: p},
+	})
+	switch {
+	case err != nil:
+		return err
+	case res.Status.Code == rpcv1beta1.Code_CODE_OK, res.Status.Code == rpcv1beta1.Code_CODE_ALREADY_EXISTS:
+		return nil
+	case res.Status.Code == rpcv1beta1.Code_CODE_PERMISSION_DENIED:
+		return errtypes.PermissionDenied(p)
+	}
+	return errtypes.InternalError("error creating " + p + ": " + res.Status.Message)
+}
+
+func listContainer(ctx context.Context, gw gateway.GatewayAPIClient, p string) ([]*provider.ResourceInfo, error) {
+	res, err := gw.ListContainer(ctx, &provider.ListContainerRequest{Ref: &provider.Reference{Path: p}})
+	switch {
+	case err != nil:
+		return nil, err
+	case res.Status.Code != rpcv1beta1.Code_CODE_OK:
+		return nil, errtypes.InternalError("error listing " + p + ": " + res.Status.Message)
+	}
+	return res.Infos, nil
+}
+
+// remove removes the resource at the given path, if any.
+func remove(ctx context.Context, gw gateway.GatewayAPIClient, p string) error {
+	res, err := gw.Delete(ctx, &provider.DeleteRequest{Ref: &provider.Reference{Path: p}})
+	switch {
+	case err != nil:
+		return err
+	case res.Status.Code != rpcv1beta1.Code_CODE_OK && res.Status.Code != rpcv1beta1.Code_CODE_NOT_FOUND:
+		return errtypes.InternalError("error removing " + p + ": " + res.Status.Message)
+	}
+	return nil
+}
+
+// stat returns the resource at the given path, or nil if there is none.
+func stat(ctx context.Context, gw gateway.GatewayAPIClient, p string) (*provider.ResourceInfo, error) {
+	res, err := gw.Stat(ctx, &provider.StatRequest{Ref: &provider.Reference{Path: p}})
+	switch {
+	case err != nil:
+		return nil, err
+	case res.Status.Code == rpcv1beta1.Code_CODE_NOT_FOUND:
+		return nil, nil
+	case res.Status.Code != rpcv1beta1.Code_CODE_OK:
+		return nil, errtypes.InternalError("error statting " + p + ": " + res.Status.Message)
+	}
+	return res.Info, nil
+}
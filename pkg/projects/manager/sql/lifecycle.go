@@ -0,0 +1,347 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strconv"
+	"time"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/permissions"
+	"github.com/cs3org/reva/v3/pkg/projects"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/status"
+	"github.com/cs3org/reva/v3/pkg/rjobs"
+	"github.com/cs3org/reva/v3/pkg/spaces"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// LifecycleConfig configures the creation, the archiving and the restore of
+// the projects. The steps taking time run as durable runs of the
+// projects.lifecycle job, so the jobs service must run in the same process as
+// the catalogue, with the job configured with the same database.
+type LifecycleConfig struct {
+	ProjectsRoot       string `docs:"/eos/project;Root of the projects, created under <root>/<first letter>/<name>."                 mapstructure:"projects_root"`
+	ArchiveRoot        string `docs:";Root of the archive the data of the archived projects is moved to. Archiving is disabled if empty." mapstructure:"archive_root"`
+	GroupPrefix        string `docs:"cernbox-project-;Prefix of the readers, writers and admins e-groups of the requested projects."   mapstructure:"group_prefix"`
+	ServiceAccount     string `docs:";User the projects.lifecycle job acts as, allowed to write and to grant in the projects and in the archive." mapstructure:"service_account"`
+	MachineSecret      string `docs:";Machine secret used to act as the service account."                                                 mapstructure:"machine_secret"`
+	Insecure           bool   `docs:"false;Whether to skip the verification of the certificates of the data gateway."                 mapstructure:"insecure"`
+	ReadOnlyDelay      int    `docs:"60;Seconds to wait after a project becomes read-only before moving its data, at least the refresh_interval of the readonlyprojects interceptors." mapstructure:"read_only_delay"`
+	StorageRegistrySvc string `docs:";Address of the storage registry, used to find the storage providers the grants of the projects are added to. Defaults to the gateway." mapstructure:"storage_registry_svc"`
+}
+
+// ApplyDefaults applies the default values to the lifecycle configuration.
+func (c *LifecycleConfig) ApplyDefaults() {
+	if c.ProjectsRoot == "" {
+		c.ProjectsRoot = "/eos/project"
+	}
+	if c.GroupPrefix == "" {
+		c.GroupPrefix = "cernbox-project-"
+	}
+	if c.ReadOnlyDelay == 0 {
+		c.ReadOnlyDelay = 60
+	}
+}
+
+// ProjectTransition records a change of the status of a project. The
+// transitions are kept when a project is forgotten.
+type ProjectTransition struct {
+	ID         uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	ProjectID  uint                   `gorm:"index:i_transition_project_id"`
+	Project    string                 `gorm:"size:255"`
+	FromStatus projects.ProjectStatus `gorm:"size:50"`
+	ToStatus   projects.ProjectStatus `gorm:"size:50"`
+	Actor      string                 `gorm:"size:255"`
+	Comment    string
+	RunID      string `gorm:"size:255"`
+}
+
+var projectNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// projectPath returns where a project with the given name is created.
+func (m *ProjectsManager) projectPath(name string) string {
+	return path.Join(m.c.Lifecycle.ProjectsRoot, name[:1], name)
+}
+
+// archivePath returns where the data of an archived project is kept.
+func (m *ProjectsManager) archivePath(p *Project) string {
+	return path.Join(m.c.Lifecycle.ArchiveRoot, p.Name+"-"+strconv.FormatUint(uint64(p.ID), 10))
+}
+
+func actor(ctx context.Context) string {
+	if u, ok := appctx.ContextGetUser(ctx); ok {
+		return u.Username
+	}
+	return ""
+}
+
+func (m *ProjectsManager) recordTransition(ctx context.Context, p *Project, from, to projects.ProjectStatus, comment, runID string) error {
+	t := &ProjectTransition{
+		ProjectID:  p.ID,
+		Project:    p.Name,
+		FromStatus: from,
+		ToStatus:   to,
+		Actor:      actor(ctx),
+		Comment:    comment,
+		RunID:      runID,
+	}
+	if res := m.db.Create(t); res.Error != nil {
+		appctx.GetLogger(ctx).Error().Err(res.Error).Str("project", p.Name).Msg("sql: error recording the transition of the project")
+		return res.Error
+	}
+	appctx.GetLogger(ctx).Info().Str("project", p.Name).Str("from", from.AsString()).Str("to", to.AsString()).Str("actor", t.Actor).Msg("sql: project transition")
+	return nil
+}
+
+// ListTransitions returns the transitions of the projects with the given
+// ids, oldest first.
+func (m *ProjectsManager) ListTransitions(ctx context.Context, ids ...uint) (map[uint][]*projects.Transition, error) {
+	var ts []*ProjectTransition
+	if res := m.db.Where("project_id IN ?", ids).Order("id").Find(&ts); res.Error != nil {
+		return nil, res.Error
+	}
+	transitions := make(map[uint][]*projects.Transition, len(ids))
+	for _, t := range ts {
+		transitions[t.ProjectID] = append(transitions[t.ProjectID], &projects.Transition{
+			Project: t.Project,
+			From:    t.FromStatus,
+			To:      t.ToStatus,
+			Actor:   t.Actor,
+			Comment: t.Comment,
+			RunID:   t.RunID,
+			Time:    t.CreatedAt,
+		})
+	}
+	return transitions, nil
+}
+
+// Roots returns the folders the projects and their archives live in.
+func (m *ProjectsManager) Roots() []string {
+	var roots []string
+	for _, root := range []string{m.c.Lifecycle.ProjectsRoot, m.c.Lifecycle.ArchiveRoot} {
+		if root != "" {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// ListReadOnlyProjects returns the projects whose data are being moved,
+// which must not be written to until the move is over.
+func (m *ProjectsManager) ListReadOnlyProjects(ctx context.Context) ([]*Project, error) {
+	var fetchedProjects []*Project
+	if res := m.db.Where("status IN ?", []projects.ProjectStatus{projects.ProjectStatusArchiving, projects.ProjectStatusRestoring}).Find(&fetchedProjects); res.Error != nil {
+		return nil, res.Error
+	}
+	return fetchedProjects, nil
+}
+
+// CreateStorageSpace records the request of a new project by the user, to be
+// approved by the administrators. The requester becomes the owner of the
+// project.
+func (m *ProjectsManager) CreateStorageSpace(ctx context.Context, req *provider.CreateStorageSpaceRequest) (*provider.CreateStorageSpaceResponse, error) {
+	user, ok := appctx.ContextGetUser(ctx)
+	if !ok {
+		return &provider.CreateStorageSpaceResponse{
+			Status: status.NewUnauthenticated(ctx, nil, "must provide a user for requesting a project"),
+		}, nil
+	}
+	if req.Type != "" && req.Type != spaces.SpaceTypeProject.AsString() {
+		return &provider.CreateStorageSpaceResponse{
+			Status: status.NewInvalid(ctx, "only projects can be requested"),
+		}, nil
+	}
+	if !projectNameRegex.MatchString(req.Name) {
+		return &provider.CreateStorageSpaceResponse{
+			Status: status.NewInvalid(ctx, "the name of a project must be made of lowercase letters, digits, dashes and underscores"),
+		}, nil
+	}
+
+	// the archived projects keep their name, and their space id
+	// until they are restored
+	groups := m.c.Lifecycle.GroupPrefix + req.Name
+	projectPath := m.projectPath(req.Name)
+	spaceID := spaces.EncodeSpaceID(projectPath)
+	var count int64
+	if res := m.db.Unscoped().Model(&Project{}).Where("name = ? OR space_id = ?", req.Name, spaceID).Count(&count); res.Error != nil {
+		return nil, res.Error
+	}
+	if count > 0 {
+		return &provider.CreateStorageSpaceResponse{
+			Status: status.NewAlreadyExists(ctx, nil, "a project named "+req.Name+" already exists or is archived"),
+		}, nil
+	}
+
+	project := &Project{
+		SpaceID:                 spaceID,
+		Path:                    projectPath,
+		Name:                    req.Name,
+		Status:                  projects.ProjectStatusPending,
+		Owner:                   user.Id.OpaqueId,
+		Readers:                 groups + "-readers",
+		Writers:                 groups + "-writers",
+		Admins:                  groups + "-admins",
+		Description:             projects.PlainOpaqueValue(req.Opaque, projects.DescriptionOpaqueKey),
+		UserProvidedDescription: projects.PlainOpaqueValue(req.Opaque, projects.CommentOpaqueKey),
+		InitialCapacityBytes:    req.GetQuota().GetQuotaMaxBytes(),
+	}
+	if res := m.db.Create(project); res.Error != nil {
+		appctx.GetLogger(ctx).Error().Err(res.Error).Str("project", req.Name).Msg("CreateStorageSpace: database error")
+		return nil, res.Error
+	}
+	if err := m.recordTransition(ctx, project, "", projects.ProjectStatusPending, project.UserProvidedDescription, ""); err != nil {
+		return nil, err
+	}
+
+	space := projectToStorageSpace(project, permissions.NewManagerRole().CS3ResourcePermissions())
+	projects.SetStatus(space, project.Status)
+	return &provider.CreateStorageSpaceResponse{
+		Status:       status.NewOK(ctx),
+		StorageSpace: space,
+	}, nil
+}
+
+// transitions maps the actions to the status they apply to and to the one
+// they lead to.
+var transitions = map[projects.Action]struct{ from, to projects.ProjectStatus }{
+	projects.ActionApprove: {projects.ProjectStatusPending, projects.ProjectStatusCreating},
+	projects.ActionReject:  {projects.ProjectStatusPending, projects.ProjectStatusRejected},
+	projects.ActionArchive: {projects.ProjectStatusActive, projects.ProjectStatusArchiving},
+	projects.ActionRestore: {projects.ProjectStatusArchived, projects.ProjectStatusRestoring},
+}
+
+// takeAction takes a step of the lifecycle of a project on behalf of an
+// administrator. A rejected project is forgotten straight away, the other
+// steps are completed by a run of the projects.lifecycle job.
+func (m *ProjectsManager) takeAction(ctx context.Context, name string, action projects.Action, comment string) (*provider.UpdateStorageSpaceResponse, error) {
+	user, ok := appctx.ContextGetUser(ctx)
+	if !ok {
+		return &provider.UpdateStorageSpaceResponse{
+			Status: status.NewUnauthenticated(ctx, nil, "must provide a user for changing the status of a project"),
+		}, nil
+	}
+	if !m.isAdmin(user) {
+		return &provider.UpdateStorageSpaceResponse{
+			Status: status.NewPermissionDenied(ctx, nil, "only the administrators can change the status of a project"),
+		}, nil
+	}
+	t, ok := transitions[action]
+	if !ok {
+		return &provider.UpdateStorageSpaceResponse{
+			Status: status.NewInvalid(ctx, "unknown action "+string(action)),
+		}, nil
+	}
+	if action == projects.ActionArchive && m.c.Lifecycle.ArchiveRoot == "" {
+		return &provider.UpdateStorageSpaceResponse{
+			Status: status.NewFailedPrecondition(ctx, nil, "no archive is configured"),
+		}, nil
+	}
+
+	p := &Project{}
+	res := m.db.Where("name = ? AND status = ?", name, t.from).Order("id desc").First(p)
+	if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		return &provider.UpdateStorageSpaceResponse{
+			Status: status.NewFailedPrecondition(ctx, nil, "no "+t.from.AsString()+" project named "+name),
+		}, nil
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	if action == projects.ActionReject {
+		if res := m.db.Unscoped().Delete(p); res.Error != nil {
+			return nil, res.Error
+		}
+		if err := m.recordTransition(ctx, p, t.from, t.to, comment, ""); err != nil {
+			return nil, err
+		}
+		p.Status = t.to
+		return m.actionResponse(ctx, p), nil
+	}
+
+	if action == projects.ActionRestore {
+		var count int64
+		if res := m.db.Model(&Project{}).Where("name = ? AND archived_at IS NULL", name).Count(&count); res.Error != nil {
+			return nil, res.Error
+		}
+		if count > 0 {
+			return &provider.UpdateStorageSpaceResponse{
+				Status: status.NewAlreadyExists(ctx, nil, "a project named "+name+" already exists"),
+			}, nil
+		}
+	}
+
+	// the status guards against concurrent steps on the same project
+	res = m.db.Model(&Project{}).Where("id = ? AND status = ?", p.ID, t.from).Update("status", t.to)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return &provider.UpdateStorageSpaceResponse{
+			Status: status.NewConflict(ctx, nil, "the status of the project "+name+" changed meanwhile"),
+		}, nil
+	}
+
+	runID, err := m.enqueue(ctx, p, action)
+	if err != nil {
+		if res := m.db.Model(&Project{}).Where("id = ?", p.ID).Update("status", t.from); res.Error != nil {
+			appctx.GetLogger(ctx).Error().Err(res.Error).Str("project", name).Msg("sql: error reverting the status of the project")
+		}
+		return &provider.UpdateStorageSpaceResponse{
+			Status: status.NewInternal(ctx, err, "error enqueuing the "+string(action)+" of the project"),
+		}, nil
+	}
+	if err := m.recordTransition(ctx, p, t.from, t.to, comment, string(runID)); err != nil {
+		return nil, err
+	}
+	m.cache.Remove(cacheKey)
+
+	p.Status = t.to
+	return m.actionResponse(ctx, p), nil
+}
+
+func (m *ProjectsManager) actionResponse(ctx context.Context, p *Project) *provider.UpdateStorageSpaceResponse {
+	perms, _ := projectBelongsToUser(appctx.ContextMustGetUser(ctx), p)
+	space := projectToStorageSpace(p, perms)
+	projects.SetStatus(space, p.Status)
+	return &provider.UpdateStorageSpaceResponse{
+		Status:       status.NewOK(ctx),
+		StorageSpace: space,
+	}
+}
+
+// enqueue starts a run of the projects.lifecycle job completing the given
+// step. At most one step of a project runs at a time.
+func (m *ProjectsManager) enqueue(ctx context.Context, p *Project, action projects.Action) (rjobs.RunID, error) {
+	runner := rjobs.Default()
+	if runner == nil {
+		return "", errors.New("sql: jobs service is not enabled")
+	}
+	id := strconv.FormatUint(uint64(p.ID), 10)
+	return runner.Enqueue(ctx, LifecycleJob, rjobs.Params{
+		"project_id": id,
+		"action":     string(action),
+	}, rjobs.Unique(LifecycleJob+":"+id), rjobs.WithOwner(actor(ctx)))
+}
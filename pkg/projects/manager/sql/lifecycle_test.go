@@ -0,0 +1,174 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpcv1beta1 "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/projects"
+)
+
+func TestCreateStorageSpace(t *testing.T) {
+	m := newQuotaManager(t)
+	alice := appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "alice"}, Username: "alice"})
+
+	res, err := m.CreateStorageSpace(alice, projects.NewProjectRequest("physics", "Physics data", 1000, "analysis of the runs"))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_OK {
+		t.Fatalf("error requesting a project: %v %v", res, err)
+	}
+	if st := projects.Status(res.StorageSpace); st != projects.ProjectStatusPending {
+		t.Fatalf("expected the project to be pending, got %q", st)
+	}
+
+	p, err := m.GetProject(alice, "physics")
+	if err != nil {
+		t.Fatalf("error getting the project: %v", err)
+	}
+	if p.Path != "/eos/project/p/physics" || p.Owner != "alice" || p.Admins != "cernbox-project-physics-admins" ||
+		p.Description != "Physics data" || p.UserProvidedDescription != "analysis of the runs" || p.InitialCapacityBytes != 1000 {
+		t.Fatalf("unexpected project %+v", p)
+	}
+
+	res, err = m.CreateStorageSpace(alice, projects.NewProjectRequest("physics", "", 0, ""))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_ALREADY_EXISTS {
+		t.Fatalf("expected the duplicate to be refused, got %v %v", res, err)
+	}
+
+	// an archived project keeps its name and its space id
+	if res := m.db.Model(&Project{}).Where("name = ?", "physics").Update("archived_at", time.Now()); res.Error != nil {
+		t.Fatalf("error archiving the project: %v", res.Error)
+	}
+	res, err = m.CreateStorageSpace(alice, projects.NewProjectRequest("physics", "", 0, ""))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_ALREADY_EXISTS {
+		t.Fatalf("expected the name of the archived project to be refused, got %v %v", res, err)
+	}
+
+	res, err = m.CreateStorageSpace(alice, projects.NewProjectRequest("../etc", "", 0, ""))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_INVALID_ARGUMENT {
+		t.Fatalf("expected the name to be refused, got %v %v", res, err)
+	}
+}
+
+func TestTakeAction(t *testing.T) {
+	m := newQuotaManager(t)
+	alice := appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "alice"}, Username: "alice"})
+	admin := appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "root"}, Username: "root", Groups: []string{"service-admins"}})
+
+	for _, name := range []string{"physics", "chemistry"} {
+		if res, err := m.CreateStorageSpace(alice, projects.NewProjectRequest(name, "", 0, "")); err != nil || res.Status.Code != rpcv1beta1.Code_CODE_OK {
+			t.Fatalf("error requesting project %s: %v %v", name, res, err)
+		}
+	}
+
+	res, err := m.UpdateStorageSpace(alice, projects.NewActionRequest("physics", projects.ActionApprove, ""))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_PERMISSION_DENIED {
+		t.Fatalf("expected the approval to be denied, got %v %v", res, err)
+	}
+
+	// without a jobs service the approval cannot be completed
+	res, err = m.UpdateStorageSpace(admin, projects.NewActionRequest("physics", projects.ActionApprove, ""))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_INTERNAL {
+		t.Fatalf("expected the approval to fail, got %v %v", res, err)
+	}
+	if p, err := m.GetProject(admin, "physics"); err != nil || p.Status != projects.ProjectStatusPending {
+		t.Fatalf("expected the project to be pending again, got %+v %v", p, err)
+	}
+
+	res, err = m.UpdateStorageSpace(admin, projects.NewActionRequest("chemistry", projects.ActionReject, "out of scope"))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_OK {
+		t.Fatalf("error rejecting the project: %v %v", res, err)
+	}
+	if st := projects.Status(res.StorageSpace); st != projects.ProjectStatusRejected {
+		t.Fatalf("expected the project to be rejected, got %q", st)
+	}
+	var p Project
+	if res := m.db.Unscoped().Where("name = ?", "chemistry").Find(&p); res.Error != nil || res.RowsAffected != 0 {
+		t.Fatalf("expected the rejected project to be deleted, got %+v %v", p, res.Error)
+	}
+
+	res, err = m.UpdateStorageSpace(admin, projects.NewActionRequest("small", projects.ActionArchive, ""))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_FAILED_PRECONDITION {
+		t.Fatalf("expected the archiving to need an archive, got %v %v", res, err)
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	m := newQuotaManager(t)
+	alice := appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "alice"}, Username: "alice"})
+
+	res, err := m.CreateStorageSpace(alice, projects.NewProjectRequest("physics", "", 0, "analysis"))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_OK {
+		t.Fatalf("error requesting a project: %v %v", res, err)
+	}
+	if err := m.UpdateProjectStatus(alice, "small", projects.ProjectStatusArchived); err != nil {
+		t.Fatalf("error updating the status: %v", err)
+	}
+
+	physics, _ := m.GetProject(alice, "physics")
+	var small Project
+	if res := m.db.Where("name = ?", "small").First(&small); res.Error != nil {
+		t.Fatalf("error getting the project: %v", res.Error)
+	}
+
+	transitions, err := m.ListTransitions(alice, physics.ID, small.ID)
+	if err != nil {
+		t.Fatalf("error listing the transitions: %v", err)
+	}
+	if ts := transitions[physics.ID]; len(ts) != 1 || ts[0].From != "" || ts[0].To != projects.ProjectStatusPending ||
+		ts[0].Actor != "alice" || ts[0].Comment != "analysis" || ts[0].Project != "physics" {
+		t.Fatalf("unexpected transitions of the requested project: %+v", ts)
+	}
+	if ts := transitions[small.ID]; len(ts) != 1 || ts[0].From != projects.ProjectStatusActive || ts[0].To != projects.ProjectStatusArchived {
+		t.Fatalf("unexpected transitions of the archived project: %+v", ts)
+	}
+}
+
+func TestUpToDate(t *testing.T) {
+	file := func(size uint64, mtime uint64, sum string) *provider.ResourceInfo {
+		info := &provider.ResourceInfo{Size: size, Mtime: &types.Timestamp{Seconds: mtime}}
+		if sum != "" {
+			info.Checksum = &provider.ResourceChecksum{Type: provider.ResourceChecksumType_RESOURCE_CHECKSUM_TYPE_ADLER32, Sum: sum}
+		}
+		return info
+	}
+
+	for _, tt := range []struct {
+		name     string
+		src, dst *provider.ResourceInfo
+		expected bool
+	}{
+		{"missing", file(10, 100, ""), nil, false},
+		{"other size", file(10, 100, ""), file(11, 200, ""), false},
+		{"same checksum", file(10, 100, "abc"), file(10, 50, "abc"), true},
+		{"other checksum", file(10, 100, "abc"), file(10, 200, "def"), false},
+		{"written after", file(10, 100, ""), file(10, 200, ""), true},
+		{"written before", file(10, 100, ""), file(10, 100, ""), false},
+	} {
+		if got := upToDate(tt.src, tt.dst); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpcv1beta1 "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/projects"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/status"
+	eosclient "github.com/cs3org/reva/v3/pkg/storage/fs/eos/client"
+	eosbinary "github.com/cs3org/reva/v3/pkg/storage/fs/eos/client/binary"
+	"github.com/cs3org/reva/v3/pkg/utils"
+)
+
+// StorageConfig configures the EOS instance the quota targets of the
+// projects are applied to.
+type StorageConfig struct {
+	MasterURL      string `docs:";URL of the EOS MGM the quota targets are set on. The targets are only recorded in the catalogue if empty." mapstructure:"master_url"`
+	EosBinary      string `docs:"/usr/bin/eos;Location of the eos binary."                                                                    mapstructure:"eos_binary"`
+	UseKeytab      bool   `docs:"false;Whether to authenticate to EOS with a keytab."                                                         mapstructure:"use_keytab"`
+	Keytab         string `docs:";Location of the EOS keytab."                                                                                mapstructure:"keytab"`
+	SingleUsername string `docs:";Username used to connect to EOS."                                                                           mapstructure:"single_username"`
+	MaxFiles       uint64 `docs:"1000000;Number of files allowed in a project, set along with its quota target."                             mapstructure:"max_files"`
+}
+
+// ApplyDefaults applies the default values to the storage configuration.
+func (c *StorageConfig) ApplyDefaults() {
+	if c.MaxFiles == 0 {
+		c.MaxFiles = 1000000
+	}
+}
+
+// quotaSetter sets the quota of a node of the storage.
+type quotaSetter interface {
+	SetQuota(ctx context.Context, user eosclient.Authorization, rootAuth eosclient.Authorization, info *eosclient.SetQuotaInfo) error
+}
+
+// newQuotaSetter returns the client setting the quota targets on the
+// configured EOS instance, or nil if none is configured.
+func newQuotaSetter(c *StorageConfig) (quotaSetter, error) {
+	if c.MasterURL == "" {
+		return nil, nil
+	}
+	return eosbinary.New(&eosbinary.Options{
+		URL:            c.MasterURL,
+		EosBinary:      c.EosBinary,
+		UseKeytab:      c.UseKeytab,
+		Keytab:         c.Keytab,
+		SingleUsername: c.SingleUsername,
+	})
+}
+
+// applyQuota sets the quota target of the project as the project quota of
+// its space on the storage, if a storage is configured.
+func (m *ProjectsManager) applyQuota(ctx context.Context, p *Project, quota uint64) error {
+	if m.quota == nil {
+		return nil
+	}
+	role := eosclient.Role{UID: "0", GID: eosclient.ProjectQuotaGID}
+	root := eosclient.Authorization{Role: eosclient.Role{UID: "0", GID: "0"}}
+	return m.quota.SetQuota(ctx, eosclient.Authorization{Role: role}, root, &eosclient.SetQuotaInfo{
+		UID:       role.UID,
+		GID:       role.GID,
+		QuotaNode: p.Path,
+		MaxBytes:  quota,
+		MaxFiles:  m.c.Storage.MaxFiles,
+	})
+}
+
+// quota returns the quota the usage of the project is reported against: the
+// target assigned by the administrators if any, else the quota last reported
+// by the storage.
+func (p *Project) quota() uint64 {
+	if p.QuotaMaxBytes > 0 {
+		return p.QuotaMaxBytes
+	}
+	return p.StorageQuotaBytes
+}
+
+// isAdmin tells whether the user administrates all the projects.
+func (m *ProjectsManager) isAdmin(user *userpb.User) bool {
+	return utils.IsAdmin(user, m.c.Admins, m.c.AdminGroups)
+}
+
+// isProjectAdmin tells whether the user administrates the given project.
+func isProjectAdmin(user *userpb.User, p *Project) bool {
+	return user.Id.OpaqueId == p.Owner || slices.Contains(user.Groups, p.Admins)
+}
+
+// updateQuotaTarget assigns the quota target of a project when called by an
+// administrator, clearing any pending change request, and records a change
+// request when called by an admin of the project. The target is set on the
+// storage first, when one is configured, so that the catalogue never records
+// a quota that is not enforced.
+func (m *ProjectsManager) updateQuotaTarget(ctx context.Context, name string, quota uint64) (*provider.UpdateStorageSpaceResponse, error) {
+	log := appctx.GetLogger(ctx)
+	user, ok := appctx.ContextGetUser(ctx)
+	if !ok {
+		return &provider.UpdateStorageSpaceResponse{
+			Status: status.NewUnauthenticated(ctx, nil, "must provide a user for updating the quota of a project"),
+		}, nil
+	}
+	if quota == 0 {
+		return &provider.UpdateStorageSpaceResponse{
+			Status: status.NewInvalid(ctx, "the quota of a project must be positive"),
+		}, nil
+	}
+
+	p, err := m.GetProject(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates map[string]any
+	switch {
+	case m.isAdmin(user):
+		updates = map[string]any{
+			"quota_max_bytes":        quota,
+			"requested_quota_bytes":  0,
+			"quota_requested_by":     "",
+			"quota_requested_at":     nil,
+			"quota_warned_threshold": 0,
+		}
+	case isProjectAdmin(user, p):
+		updates = map[string]any{
+			"requested_quota_bytes": quota,
+			"quota_requested_by":    user.Username,
+			"quota_requested_at":    time.Now(),
+		}
+	default:
+		return &provider.UpdateStorageSpaceResponse{
+			Status: status.NewPermissionDenied(ctx, nil, "only the admins of a project can change its quota"),
+		}, nil
+	}
+
+	if _, ok := updates["quota_max_bytes"]; ok {
+		if err := m.applyQuota(ctx, p, quota); err != nil {
+			log.Error().Err(err).Str("project", name).Uint64("quota", quota).Msg("UpdateStorageSpace: error setting the quota on the storage")
+			return &provider.UpdateStorageSpaceResponse{
+				Status: status.NewInternal(ctx, "error setting the quota of the project on the storage"),
+			}, nil
+		}
+	}
+
+	if res := m.db.Model(&Project{}).Where("id = ?", p.ID).Updates(updates); res.Error != nil {
+		log.Error().Err(res.Error).Str("project", name).Msg("UpdateStorageSpace: database error")
+		return nil, res.Error
+	}
+	if _, ok := updates["quota_max_bytes"]; ok {
+		log.Info().Str("project", name).Uint64("quota", quota).Str("user", user.Username).Msg("Assigned project quota target")
+	} else {
+		log.Info().Str("project", name).Uint64("quota", quota).Str("user", user.Username).Msg("Requested project quota change")
+	}
+	m.cache.Remove(cacheKey)
+
+	if p, err = m.GetProject(ctx, name); err != nil {
+		return nil, err
+	}
+	// the administrators do not necessarily have access to the project
+	perms, _ := projectBelongsToUser(user, p)
+
+	return &provider.UpdateStorageSpaceResponse{
+		Status: &rpcv1beta1.Status{
+			Code: rpcv1beta1.Code_CODE_OK,
+		},
+		StorageSpace: projectToStorageSpace(p, perms),
+	}, nil
+}
+
+// listAllStorageSpaces lists all the projects in the given status, or in any
+// status if empty, for the administrators, sorted by decreasing usage, along
+// with their status and their transitions.
+func (m *ProjectsManager) listAllStorageSpaces(ctx context.Context, user *userpb.User, st projects.ProjectStatus) (*provider.ListStorageSpacesResponse, error) {
+	if !m.isAdmin(user) {
+		return &provider.ListStorageSpacesResponse{
+			Status: status.NewPermissionDenied(ctx, nil, "only the administrators can list all the projects"),
+		}, nil
+	}
+
+	projs, err := m.ListProjectsByUsage(ctx, st)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(projs))
+	for _, p := range projs {
+		ids = append(ids, p.ID)
+	}
+	transitions, err := m.ListTransitions(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	spaces := make([]*provider.StorageSpace, 0, len(projs))
+	for _, p := range projs {
+		perms, _ := projectBelongsToUser(user, p)
+		space := projectToStorageSpace(p, perms)
+		projects.SetStatus(space, p.Status)
+		if err := projects.SetTransitions(space, transitions[p.ID]); err != nil {
+			return nil, err
+		}
+		spaces = append(spaces, space)
+	}
+	return &provider.ListStorageSpacesResponse{
+		Status: &rpcv1beta1.Status{
+			Code: rpcv1beta1.Code_CODE_OK,
+		},
+		StorageSpaces: spaces,
+	}, nil
+}
+
+// ListProjectsByUsage returns the projects in the given status, or all of
+// them if empty, sorted by decreasing usage as last collected by the usage
+// job. To be used only by administrative tools.
+func (m *ProjectsManager) ListProjectsByUsage(ctx context.Context, st projects.ProjectStatus) ([]*Project, error) {
+	var fetchedProjects []*Project
+	query := m.db.Model(&Project{})
+	if st != "" {
+		query = query.Where("status = ?", st)
+	}
+	if res := query.Order("used_bytes desc").Order("name").Find(&fetchedProjects); res.Error != nil {
+		return nil, res.Error
+	}
+	return fetchedProjects, nil
+}
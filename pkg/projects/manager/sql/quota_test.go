@@ -0,0 +1,222 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpcv1beta1 "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/projects"
+	eosclient "github.com/cs3org/reva/v3/pkg/storage/fs/eos/client"
+	"github.com/pkg/errors"
+)
+
+type fakeQuotaSetter struct {
+	quotas map[string]uint64
+	fail   bool
+}
+
+func (f *fakeQuotaSetter) SetQuota(_ context.Context, user eosclient.Authorization, _ eosclient.Authorization, info *eosclient.SetQuotaInfo) error {
+	if f.fail {
+		return errors.New("eos unavailable")
+	}
+	if user.Role.GID != eosclient.ProjectQuotaGID {
+		return errors.New("expected a project quota")
+	}
+	f.quotas[info.QuotaNode] = info.MaxBytes
+	return nil
+}
+
+func newQuotaManager(t *testing.T) *ProjectsManager {
+	t.Helper()
+	catalogue, err := New(context.Background(), map[string]any{
+		"db_engine":    "sqlite",
+		"db_name":      filepath.Join(t.TempDir(), "projects.sqlite"),
+		"admin_groups": []string{"service-admins"},
+	})
+	if err != nil {
+		t.Fatalf("error creating the projects manager: %v", err)
+	}
+	m := catalogue.(*ProjectsManager)
+	for _, p := range []*Project{
+		{SpaceID: "1", Name: "small", Path: "/eos/project/s/small", Owner: "alice", Admins: "small-admins", Status: projects.ProjectStatusActive},
+		{SpaceID: "2", Name: "large", Path: "/eos/project/l/large", Owner: "bob", Admins: "large-admins", Status: projects.ProjectStatusActive},
+	} {
+		if res := m.db.Create(p); res.Error != nil {
+			t.Fatalf("error creating project %s: %v", p.Name, res.Error)
+		}
+	}
+	return m
+}
+
+func quotaRequest(name string, quota uint64) *provider.UpdateStorageSpaceRequest {
+	return &provider.UpdateStorageSpaceRequest{
+		StorageSpace: &provider.StorageSpace{
+			Id:    &provider.StorageSpaceId{OpaqueId: name},
+			Name:  name,
+			Quota: &provider.Quota{QuotaMaxBytes: quota},
+		},
+	}
+}
+
+func TestUpdateQuota(t *testing.T) {
+	m := newQuotaManager(t)
+	admin := appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "root"}, Username: "root", Groups: []string{"service-admins"}})
+	projectAdmin := appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "carol"}, Username: "carol", Groups: []string{"small-admins"}})
+	stranger := appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "dave"}, Username: "dave"})
+
+	res, err := m.UpdateStorageSpace(stranger, quotaRequest("small", 1000))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_PERMISSION_DENIED {
+		t.Fatalf("expected the change to be denied, got %v %v", res, err)
+	}
+
+	res, err = m.UpdateStorageSpace(projectAdmin, quotaRequest("small", 1000))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_OK {
+		t.Fatalf("error requesting a quota change: %v %v", res, err)
+	}
+	if q, ok := projects.RequestedQuota(res.StorageSpace); !ok || q != 1000 {
+		t.Fatalf("expected a pending request of 1000 bytes, got %d %t", q, ok)
+	}
+	p, _ := m.GetProject(projectAdmin, "small")
+	if p.QuotaMaxBytes != 0 || p.QuotaRequestedBy != "carol" {
+		t.Fatalf("the request must not change the quota: %+v", p)
+	}
+
+	res, err = m.UpdateStorageSpace(admin, quotaRequest("small", 2000))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_OK {
+		t.Fatalf("error assigning the quota: %v %v", res, err)
+	}
+	if _, ok := projects.RequestedQuota(res.StorageSpace); ok {
+		t.Fatal("the assignment must clear the pending request")
+	}
+	p, _ = m.GetProject(admin, "small")
+	if p.QuotaMaxBytes != 2000 || p.RequestedQuotaBytes != 0 {
+		t.Fatalf("expected a quota of 2000 bytes, got %+v", p)
+	}
+
+	res, err = m.UpdateStorageSpace(admin, quotaRequest("small", 0))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_INVALID_ARGUMENT {
+		t.Fatalf("expected an empty quota to be refused, got %v %v", res, err)
+	}
+}
+
+func TestUpdateQuotaOnStorage(t *testing.T) {
+	m := newQuotaManager(t)
+	storage := &fakeQuotaSetter{quotas: map[string]uint64{}}
+	m.quota = storage
+	admin := appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "root"}, Username: "root", Groups: []string{"service-admins"}})
+	projectAdmin := appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "carol"}, Username: "carol", Groups: []string{"small-admins"}})
+
+	if res, err := m.UpdateStorageSpace(projectAdmin, quotaRequest("small", 1000)); err != nil || res.Status.Code != rpcv1beta1.Code_CODE_OK {
+		t.Fatalf("error requesting a quota change: %v %v", res, err)
+	}
+	if len(storage.quotas) != 0 {
+		t.Fatalf("a request must not change the quota on the storage: %v", storage.quotas)
+	}
+
+	if res, err := m.UpdateStorageSpace(admin, quotaRequest("small", 2000)); err != nil || res.Status.Code != rpcv1beta1.Code_CODE_OK {
+		t.Fatalf("error assigning the quota: %v %v", res, err)
+	}
+	if q := storage.quotas["/eos/project/s/small"]; q != 2000 {
+		t.Fatalf("expected a quota of 2000 bytes on the storage, got %d", q)
+	}
+
+	storage.fail = true
+	res, err := m.UpdateStorageSpace(admin, quotaRequest("small", 3000))
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_INTERNAL {
+		t.Fatalf("expected the assignment to fail, got %v %v", res, err)
+	}
+	if p, _ := m.GetProject(admin, "small"); p.QuotaMaxBytes != 2000 {
+		t.Fatalf("a failed assignment must not be recorded, got %d", p.QuotaMaxBytes)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	m := newQuotaManager(t)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		used, total uint64
+		warn        int
+	}{
+		{used: 50, total: 100, warn: 0},
+		{used: 85, total: 100, warn: 80},
+		{used: 88, total: 100, warn: 0},
+		{used: 95, total: 100, warn: 90},
+		{used: 70, total: 100, warn: 0},
+		{used: 100, total: 100, warn: 100},
+		{used: 100, total: 0, warn: 0},
+	} {
+		p, err := m.GetProject(ctx, "large")
+		if err != nil {
+			t.Fatal(err)
+		}
+		warn, err := m.recordUsage(ctx, p, tt.used, tt.total)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if warn != tt.warn {
+			t.Errorf("usage %d/%d: expected a warning at %d%%, got %d%%", tt.used, tt.total, tt.warn, warn)
+		}
+	}
+
+	// the assigned quota takes precedence over the one of the storage
+	p, _ := m.GetProject(ctx, "small")
+	p.QuotaMaxBytes = 1000
+	if res := m.db.Save(p); res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if warn, _ := m.recordUsage(ctx, p, 900, 10000); warn != 90 {
+		t.Errorf("expected a warning at 90%%, got %d%%", warn)
+	}
+}
+
+func TestListAllProjects(t *testing.T) {
+	m := newQuotaManager(t)
+	admin := appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "root"}, Username: "root", Groups: []string{"service-admins"}})
+	user := appctx.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "alice"}, Username: "alice"})
+
+	for name, used := range map[string]uint64{"small": 10, "large": 500} {
+		p, _ := m.GetProject(admin, name)
+		if _, err := m.recordUsage(admin, p, used, 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := m.ListStorageSpaces(user, projects.NewListAllRequest(projects.ProjectStatusActive), projects.ProjectStatusActive)
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_PERMISSION_DENIED {
+		t.Fatalf("expected the listing to be denied, got %v %v", res, err)
+	}
+
+	res, err = m.ListStorageSpaces(admin, projects.NewListAllRequest(projects.ProjectStatusActive), projects.ProjectStatusActive)
+	if err != nil || res.Status.Code != rpcv1beta1.Code_CODE_OK {
+		t.Fatalf("error listing all the projects: %v %v", res, err)
+	}
+	if len(res.StorageSpaces) != 2 || res.StorageSpaces[0].Name != "large" || res.StorageSpaces[1].Name != "small" {
+		t.Fatalf("expected the projects sorted by usage, got %v", res.StorageSpaces)
+	}
+	if q := res.StorageSpaces[0].Quota; q == nil || q.QuotaMaxBytes != 1000 || q.RemainingBytes != 500 {
+		t.Fatalf("expected the collected usage, got %v", q)
+	}
+}
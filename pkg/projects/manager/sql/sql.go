@@ -20,9 +20,11 @@ package sql
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ReneKroon/ttlcache/v2"
@@ -31,6 +33,7 @@ import (
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	"github.com/cs3org/reva/v3/cmd/revad/pkg/config"
 	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/notification/notificationhelper"
 	"github.com/cs3org/reva/v3/pkg/permissions"
 	"github.com/cs3org/reva/v3/pkg/projects"
 	"github.com/cs3org/reva/v3/pkg/projects/manager/registry"
@@ -55,12 +58,25 @@ type Config struct {
 	// CacheTTL (seconds) determines how long the list of projects will be stored in a cache
 	// before a new database query is executed. The default, 0, corresponds to 60 seconds.
 	CacheTTL int `mapstructure:"cache_ttl"`
+	// Admins and AdminGroups are the users allowed to assign the quota of the
+	// projects and to list all of them. The admins of a project can only
+	// request a change of its quota.
+	Admins      []string `mapstructure:"admins"`
+	AdminGroups []string `mapstructure:"admin_groups"`
+	// GatewaySvc is used by the usage job to query the quota of the projects.
+	GatewaySvc string          `mapstructure:"gatewaysvc"`
+	Usage      UsageConfig     `mapstructure:"usage"`
+	Lifecycle  LifecycleConfig `mapstructure:"lifecycle"`
+	// Storage is the EOS instance the quota targets are applied to.
+	Storage StorageConfig `mapstructure:"storage"`
 }
 
 type ProjectsManager struct {
 	c     *Config
 	db    *gorm.DB
 	cache *ttlcache.Cache
+	nh    *notificationhelper.NotificationHelper
+	quota quotaSetter
 }
 
 const cacheKey = "projects/projectsListCache"
@@ -91,6 +107,8 @@ type Project struct {
 	ThumbnailPath string
 	// Set if the project is archived, i.e. not available to users in this state
 	ArchivedAt datatypes.NullTime `gorm:"uniqueIndex:i_name_archived_at"`
+	// Where the data of the archived project are kept
+	ArchivePath string
 	// Comma-seperated list of arbitrary capabilities of the project
 	Capabilities string
 
@@ -108,15 +126,92 @@ type Project struct {
 	BackupJobId string
 	// Initially requested capacity
 	InitialCapacityBytes uint64
+
+	// Quota target assigned by the administrators, 0 if only set in the
+	// storage. It is set on the storage when assigned.
+	QuotaMaxBytes uint64
+	// Quota asked for by the pending change request, 0 if none
+	RequestedQuotaBytes uint64
+	QuotaRequestedBy    string
+	QuotaRequestedAt    datatypes.NullTime
+	// Usage collected by the usage job
+	UsedBytes         uint64 `gorm:"index:idx_used_bytes"`
+	StorageQuotaBytes uint64
+	UsageUpdatedAt    datatypes.NullTime
+	// Highest usage threshold (percentage of the quota) the admins
+	// of the project were warned about
+	QuotaWarnedThreshold int
 }
 
+// managers are the catalogues of the process, by configuration, shared by
+// the components opening the catalogue, e.g. the interceptors and the jobs.
+var (
+	managersMu sync.Mutex
+	managers   = map[string]*ProjectsManager{}
+)
+
+// New returns the sql projects catalogue of the service owning it, which
+// registers the usage job when enabled. The other components sharing the
+// catalogue use Open.
 func New(ctx context.Context, m map[string]any) (projects.Catalogue, error) {
+	c, key, err := decodeConfig(m)
+	if err != nil {
+		return nil, err
+	}
+	mgr, err := newManager(c)
+	if err != nil {
+		return nil, err
+	}
+	managersMu.Lock()
+	managers[key] = mgr
+	managersMu.Unlock()
+
+	if c.Usage.Enabled {
+		if err := mgr.registerUsageJob(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return mgr, nil
+}
+
+// Open returns the sql projects catalogue for the given configuration,
+// reusing the one already built in the process for the same configuration,
+// so that its database connections are shared. It never registers the
+// usage job.
+func Open(m map[string]any) (*ProjectsManager, error) {
+	c, key, err := decodeConfig(m)
+	if err != nil {
+		return nil, err
+	}
+	managersMu.Lock()
+	defer managersMu.Unlock()
+	if mgr, ok := managers[key]; ok {
+		return mgr, nil
+	}
+	mgr, err := newManager(c)
+	if err != nil {
+		return nil, err
+	}
+	managers[key] = mgr
+	return mgr, nil
+}
+
+// decodeConfig decodes the configuration of the catalogue, along with the
+// key it is shared under.
+func decodeConfig(m map[string]any) (*Config, string, error) {
 	var c Config
 	if err := cfg.Decode(m, &c); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	c.ApplyDefaults()
+	key, err := json.Marshal(c)
+	if err != nil {
+		return nil, "", err
+	}
+	return &c, string(key), nil
+}
 
+func newManager(c *Config) (*ProjectsManager, error) {
 	var db *gorm.DB
 	var err error
 	switch c.Engine {
@@ -134,7 +229,7 @@ func New(ctx context.Context, m map[string]any) (projects.Catalogue, error) {
 	}
 
 	// Migrate schemas
-	err = db.AutoMigrate(&Project{})
+	err = db.AutoMigrate(&Project{}, &ProjectTransition{})
 
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to mgirate Project schema")
@@ -147,16 +242,27 @@ func New(ctx context.Context, m map[string]any) (projects.Catalogue, error) {
 	cache.SetTTL(time.Duration(c.CacheTTL))
 	// Even if we get a hit, of course we just want to refresh every 60 seconds
 	cache.SkipTTLExtensionOnHit(true)
-	mgr := &ProjectsManager{
-		c:     &c,
+	quota, err := newQuotaSetter(&c.Storage)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create the EOS client for the project quotas")
+	}
+	return &ProjectsManager{
+		c:     c,
 		db:    db,
 		cache: cache,
-	}
-	return mgr, nil
+		quota: quota,
+	}, nil
 }
 
 func (c *Config) ApplyDefaults() {
 	c.Database = sharedconf.GetDBInfo(c.Database)
+	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
+	c.Usage.ApplyDefaults()
+	c.Lifecycle.ApplyDefaults()
+	if c.Lifecycle.StorageRegistrySvc == "" {
+		c.Lifecycle.StorageRegistrySvc = c.GatewaySvc
+	}
+	c.Storage.ApplyDefaults()
 }
 
 func (m *ProjectsManager) ListStorageSpaces(ctx context.Context, req *provider.ListStorageSpacesRequest, status projects.ProjectStatus) (*provider.ListStorageSpacesResponse, error) {
@@ -170,13 +276,22 @@ func (m *ProjectsManager) ListStorageSpaces(ctx context.Context, req *provider.L
 		}, nil
 	}
 
+	if projects.IsListAllRequest(req) {
+		return m.listAllStorageSpaces(ctx, user, status)
+	}
+
 	var fetchedProjects []*Project
 	// If there is a filter other than SpaceType, we don't cache
 	shouldCache := !containsDriverLevelFilters(req.Filters)
 	if res, err := m.cache.Get(cacheKey); shouldCache && err == nil && res != nil {
 		fetchedProjects = res.([]*Project)
 	} else {
-		query := m.db.Model(&Project{}).Where("status = ?", status)
+		// the projects being archived stay readable
+		statuses := []projects.ProjectStatus{status}
+		if status == projects.ProjectStatusActive {
+			statuses = append(statuses, projects.ProjectStatusArchiving)
+		}
+		query := m.db.Model(&Project{}).Where("status IN ?", statuses)
 		query = m.appendFiltersToQuery(ctx, query, req.Filters)
 
 		res := query.Find(&fetchedProjects)
@@ -192,6 +307,9 @@ func (m *ProjectsManager) ListStorageSpaces(ctx context.Context, req *provider.L
 	projs := []*provider.StorageSpace{}
 	for _, p := range fetchedProjects {
 		if perms, ok := projectBelongsToUser(user, p); ok {
+			if p.Status == projects.ProjectStatusArchiving {
+				perms = permissions.NewViewerRole().CS3ResourcePermissions()
+			}
 			projs = append(projs, projectToStorageSpace(p, perms))
 		}
 	}
@@ -233,6 +351,14 @@ func (m *ProjectsManager) UpdateStorageSpace(ctx context.Context, req *provider.
 	}
 	log.Debug().Any("space", req.StorageSpace).Any("update", req.Field).Msg("Updating storage space")
 
+	if action := projects.PlainOpaqueValue(req.Opaque, projects.ActionOpaqueKey); action != "" {
+		return m.takeAction(ctx, req.StorageSpace.Name, projects.Action(action), projects.PlainOpaqueValue(req.Opaque, projects.CommentOpaqueKey))
+	}
+
+	if req.Field == nil && req.StorageSpace.Quota != nil {
+		return m.updateQuotaTarget(ctx, req.StorageSpace.Name, req.StorageSpace.Quota.QuotaMaxBytes)
+	}
+
 	if req.Field == nil {
 		return &provider.UpdateStorageSpaceResponse{
 			Status: &rpcv1beta1.Status{
@@ -281,10 +407,6 @@ func (m *ProjectsManager) UpdateStorageSpace(ctx context.Context, req *provider.
 	}, nil
 }
 
-func (m *ProjectsManager) CreateStorageSpace(ctx context.Context, req *provider.CreateStorageSpaceRequest) (*provider.CreateStorageSpaceResponse, error) {
-	return nil, errors.New("Unsupported")
-}
-
 func (m *ProjectsManager) DeleteStorageSpace(ctx context.Context, req *provider.DeleteStorageSpaceRequest) (*provider.DeleteStorageSpaceResponse, error) {
 	return nil, errors.New("Unsupported")
 }
@@ -316,6 +438,11 @@ func (m *ProjectsManager) UpdateProjectStatus(ctx context.Context, name string,
 		updates["archived_at"] = time.Now()
 	}
 
+	var current []*Project
+	if res := m.db.Where("name = ?", name).Find(&current); res.Error != nil {
+		return res.Error
+	}
+
 	res := m.db.Model(&Project{}).
 		Where("name = ?", name).
 		Updates(updates)
@@ -329,10 +456,37 @@ func (m *ProjectsManager) UpdateProjectStatus(ctx context.Context, name string,
 		return fmt.Errorf("no project found with name %s", name)
 	}
 
+	for _, p := range current {
+		if err := m.recordTransition(ctx, p, p.Status, status, "manual update", ""); err != nil {
+			return err
+		}
+	}
+
 	log.Info().Str("project", name).Str("status", status.AsString()).Msg("Updated project status")
 	return nil
 }
 
+// TransferProjectOwnership hands a project over to a new owner,
+// e.g. when the owner leaves. To be used only by administrative tools.
+func (m *ProjectsManager) TransferProjectOwnership(ctx context.Context, name string, newOwner string) error {
+	if newOwner == "" {
+		return errors.New("Must pass a non-empty owner")
+	}
+
+	res := m.db.Model(&Project{}).
+		Where("name = ?", name).
+		Update("owner", newOwner)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("no project found with name %s", name)
+	}
+
+	appctx.GetLogger(ctx).Info().Str("project", name).Str("owner", newOwner).Msg("Transferred project ownership")
+	return nil
+}
+
 // To be used only by cernboxcop.
 func (m *ProjectsManager) ListAllProjects(ctx context.Context, status projects.ProjectStatus, owner string) ([]*Project, error) {
 	var fetchedProjects []*Project
@@ -370,7 +524,7 @@ func projectBelongsToUser(user *userpb.User, p *Project) (*provider.ResourcePerm
 }
 
 func projectToStorageSpace(p *Project, perms *provider.ResourcePermissions) *provider.StorageSpace {
-	return &provider.StorageSpace{
+	space := &provider.StorageSpace{
 		Id: &provider.StorageSpaceId{
 			OpaqueId: spaces.EncodeStorageSpaceID(p.StorageID, p.SpaceID),
 		},
@@ -390,6 +544,16 @@ func projectToStorageSpace(p *Project, perms *provider.ResourcePermissions) *pro
 		ReadmeId:      p.ReadmePath,
 		PermissionSet: perms,
 	}
+	if quota := p.quota(); quota > 0 && p.UsageUpdatedAt.Valid {
+		space.Quota = &provider.Quota{
+			QuotaMaxBytes:  quota,
+			RemainingBytes: quota - min(p.UsedBytes, quota),
+		}
+	}
+	if p.RequestedQuotaBytes > 0 {
+		projects.SetRequestedQuota(space, p.RequestedQuotaBytes)
+	}
+	return space
 }
 
 func (m *ProjectsManager) appendFiltersToQuery(ctx context.Context, query *gorm.DB, filters []*provider.ListStorageSpacesRequest_Filter) *gorm.DB {
@@ -22,6 +22,7 @@ import (
 	"context"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -299,3 +300,33 @@ func TestListProjects(t *testing.T) {
 		})
 	}
 }
+
+func TestOpen(t *testing.T) {
+	cfg := map[string]any{
+		"db_engine": "sqlite",
+		"db_name":   filepath.Join(t.TempDir(), "projects.sqlite"),
+		"usage":     map[string]any{"enabled": true},
+	}
+	if _, err := New(context.Background(), cfg); err != nil {
+		t.Fatalf("error creating the catalogue: %v", err)
+	}
+	owner, err := New(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("a second catalogue enabling the usage job must not fail: %v", err)
+	}
+
+	shared, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("error opening the catalogue: %v", err)
+	}
+	again, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("error opening the catalogue: %v", err)
+	}
+	if shared != again {
+		t.Fatal("the catalogue must be shared by the components opening it")
+	}
+	if shared != owner {
+		t.Fatal("the catalogue must be the one created by its owner")
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	grouppb "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpcv1beta1 "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/notification"
+	"github.com/cs3org/reva/v3/pkg/notification/notificationhelper"
+	"github.com/cs3org/reva/v3/pkg/notification/trigger"
+	"github.com/cs3org/reva/v3/pkg/projects"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/v3/pkg/rjobs"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+const usageJob = "projects.usage"
+
+// UsageConfig configures the periodic job collecting the usage of the
+// projects and warning their admins when the usage crosses a threshold.
+type UsageConfig struct {
+	Enabled       bool           `docs:"false;Whether to periodically collect the usage of the projects."                                   mapstructure:"enabled"`
+	Schedule      string         `docs:"@hourly;Schedule of the usage job."                                                                 mapstructure:"schedule"`
+	Thresholds    []int          `docs:"[80, 90, 100];Percentages of the quota whose crossing is notified to the admins of the project."   mapstructure:"thresholds"`
+	WarnTemplate  string         `docs:"project-quota-warning;Name of the notification template used for the warnings."                    mapstructure:"warn_template"`
+	MachineSecret string         `docs:";Machine secret used to query the quota on behalf of the owners of the projects."                  mapstructure:"machine_secret"`
+	Notifications map[string]any `docs:";Notification helper configuration, used to send the warnings. Warnings are disabled if empty."    mapstructure:"notifications"`
+}
+
+// ApplyDefaults applies the default values to the usage configuration.
+func (c *UsageConfig) ApplyDefaults() {
+	if c.Schedule == "" {
+		c.Schedule = "@hourly"
+	}
+	if len(c.Thresholds) == 0 {
+		c.Thresholds = []int{80, 90, 100}
+	}
+	slices.Sort(c.Thresholds)
+	if c.WarnTemplate == "" {
+		c.WarnTemplate = "project-quota-warning"
+	}
+}
+
+// usageCollector is the catalogue the usage job was registered for: a
+// process runs the job once, whichever catalogues enable it.
+var (
+	usageMu        sync.Mutex
+	usageCollector *ProjectsManager
+)
+
+func (m *ProjectsManager) registerUsageJob(ctx context.Context) error {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	if usageCollector != nil {
+		appctx.GetLogger(ctx).Warn().Msg("sql: the project usage job is already registered by another catalogue")
+		return nil
+	}
+
+	if len(m.c.Usage.Notifications) > 0 {
+		nh, err := notificationhelper.New(usageJob, m.c.Usage.Notifications, appctx.GetLogger(ctx))
+		if err != nil {
+			appctx.GetLogger(ctx).Error().Err(err).Msg("sql: project quota warnings are disabled")
+		} else {
+			m.nh = nh
+		}
+	}
+	if err := rjobs.RegisterPeriodic(rjobs.Periodic{
+		Name:     usageJob,
+		Schedule: m.c.Usage.Schedule,
+		Scope:    rjobs.ScopeLeader,
+		Run:      m.collectUsage,
+		Jitter:   time.Minute,
+	}); err != nil {
+		return err
+	}
+	usageCollector = m
+	return nil
+}
+
+// collectUsage records the usage of the active projects, as reported by
+// the storage on behalf of their owners, and warns the admins of the
+// projects whose usage crossed a threshold.
+func (m *ProjectsManager) collectUsage(ctx context.Context) error {
+	log := appctx.GetLogger(ctx)
+
+	gw, err := pool.GetGatewayServiceClient(pool.Endpoint(m.c.GatewaySvc))
+	if err != nil {
+		return err
+	}
+
+	projs, err := m.ListAllProjects(ctx, projects.ProjectStatusActive, "")
+	if err != nil {
+		return err
+	}
+	var failed int
+	for _, p := range projs {
+		ownerCtx, owner, err := impersonate(ctx, gw, p.Owner, m.c.Usage.MachineSecret)
+		if err != nil {
+			log.Error().Err(err).Str("project", p.Name).Msg("sql: cannot impersonate the owner of the project")
+			failed++
+			continue
+		}
+		res, err := gw.GetQuota(ownerCtx, &gateway.GetQuotaRequest{
+			Ref: &provider.Reference{Path: p.Path},
+		})
+		switch {
+		case err != nil:
+			log.Error().Err(err).Str("project", p.Name).Msg("sql: error getting the quota of the project")
+			failed++
+			continue
+		case res.Status.Code != rpcv1beta1.Code_CODE_OK:
+			log.Error().Str("project", p.Name).Str("status", res.Status.Code.String()).Msg("sql: error getting the quota of the project: " + res.Status.Message)
+			failed++
+			continue
+		}
+
+		threshold, err := m.recordUsage(ctx, p, res.UsedBytes, res.TotalBytes)
+		if err != nil {
+			return err
+		}
+		if threshold > 0 && m.nh != nil {
+			m.warnAdmins(ctx, gw, p, owner, threshold)
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("sql: the usage of %d of %d projects could not be collected", failed, len(projs))
+	}
+	return nil
+}
+
+// recordUsage stores the usage of a project and returns the threshold its
+// admins have to be warned about, if any: the highest one crossed, when
+// higher than the last one they were warned about. Once the usage goes back
+// below a threshold, crossing it again is notified again.
+func (m *ProjectsManager) recordUsage(ctx context.Context, p *Project, used, total uint64) (int, error) {
+	p.UsedBytes = used
+	p.StorageQuotaBytes = total
+	crossed := crossedThreshold(m.c.Usage.Thresholds, used, p.quota())
+
+	warn := 0
+	if crossed > p.QuotaWarnedThreshold {
+		warn = crossed
+	}
+	updates := map[string]any{
+		"used_bytes":             used,
+		"storage_quota_bytes":    total,
+		"usage_updated_at":       time.Now(),
+		"quota_warned_threshold": crossed,
+	}
+	if res := m.db.Model(&Project{}).Where("id = ?", p.ID).Updates(updates); res.Error != nil {
+		appctx.GetLogger(ctx).Error().Err(res.Error).Str("project", p.Name).Msg("sql: error recording the usage of the project")
+		return 0, res.Error
+	}
+	p.QuotaWarnedThreshold = crossed
+	return warn, nil
+}
+
+// crossedThreshold returns the highest of the sorted thresholds crossed by
+// the usage, or 0.
+func crossedThreshold(thresholds []int, used, quota uint64) int {
+	if quota == 0 {
+		return 0
+	}
+	crossed := 0
+	for _, t := range thresholds {
+		if used*100 >= uint64(t)*quota {
+			crossed = t
+		}
+	}
+	return crossed
+}
+
+// warnAdmins notifies the owner and the admins e-group of a project that its
+// usage crossed the given threshold.
+func (m *ProjectsManager) warnAdmins(ctx context.Context, gw gateway.GatewayAPIClient, p *Project, owner *userpb.User, threshold int) {
+	log := appctx.GetLogger(ctx)
+
+	recipients := []string{}
+	users := map[string]string{}
+	if owner.Mail != "" {
+		recipients = append(recipients, owner.Mail)
+		users[owner.Mail] = p.Owner
+	}
+	if p.Admins != "" {
+		res, err := gw.GetGroupByClaim(ctx, &grouppb.GetGroupByClaimRequest{
+			Claim:               "group_name",
+			Value:               p.Admins,
+			SkipFetchingMembers: true,
+		})
+		switch {
+		case err != nil:
+			log.Error().Err(err).Str("project", p.Name).Msg("sql: error getting the admins group of the project")
+		case res.Status.Code != rpcv1beta1.Code_CODE_OK:
+			log.Error().Str("project", p.Name).Str("status", res.Status.Code.String()).Msg("sql: error getting the admins group of the project: " + res.Status.Message)
+		case res.Group.Mail != "" && res.Group.Mail != owner.Mail:
+			recipients = append(recipients, res.Group.Mail)
+		}
+	}
+	if len(recipients) == 0 {
+		log.Warn().Str("project", p.Name).Msg("sql: no one to warn about the usage of the project")
+		return
+	}
+
+	ref := fmt.Sprintf("project-quota-%s-%d", p.Name, threshold)
+	m.nh.TriggerNotification(&trigger.Trigger{
+		Notification: &notification.Notification{
+			TemplateName: m.c.Usage.WarnTemplate,
+			Ref:          ref,
+			Recipients:   recipients,
+			Users:        users,
+		},
+		Ref: ref,
+		TemplateData: map[string]any{
+			"project":   p.Name,
+			"path":      p.Path,
+			"threshold": threshold,
+			"used":      p.UsedBytes,
+			"quota":     p.quota(),
+		},
+	})
+	log.Info().Str("project", p.Name).Int("threshold", threshold).Msg("sql: warned the admins about the usage of the project")
+}
+
+// impersonate returns a context authenticated as the given user through the
+// machine authentication, along with the user.
+func impersonate(ctx context.Context, gw gateway.GatewayAPIClient, userID, machineSecret string) (context.Context, *userpb.User, error) {
+	authRes, err := gw.Authenticate(ctx, &gateway.AuthenticateRequest{
+		Type:         "machine",
+		ClientId:     userID,
+		ClientSecret: machineSecret,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if authRes.Status.Code != rpcv1beta1.Code_CODE_OK {
+		return nil, nil, errors.New(authRes.Status.Message)
+	}
+
+	userCtx := appctx.ContextSetToken(context.Background(), authRes.Token)
+	userCtx = metadata.AppendToOutgoingContext(userCtx, appctx.TokenHeader, authRes.Token)
+	userCtx = appctx.ContextSetUser(userCtx, authRes.User)
+	userCtx = appctx.WithLogger(userCtx, appctx.GetLogger(ctx))
+	return userCtx, authRes.User, nil
+}
@@ -20,21 +20,58 @@ package projects
 
 import (
 	"context"
+	"encoding/json"
+	"strconv"
+	"time"
 
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 )
 
 type ProjectStatus string
 
 const (
+	// ProjectStatusPending is the status of a requested project, waiting for
+	// the approval of the administrators.
+	ProjectStatusPending   ProjectStatus = "pending"
 	ProjectStatusCreating  ProjectStatus = "creating"
 	ProjectStatusActive    ProjectStatus = "active"
 	ProjectStatusArchiving ProjectStatus = "archiving"
 	ProjectStatusArchived  ProjectStatus = "archived"
+	ProjectStatusRestoring ProjectStatus = "restoring"
+	// ProjectStatusRejected is the final status of a rejected request: the
+	// project is forgotten, only its transitions remain.
+	ProjectStatusRejected ProjectStatus = "rejected"
 )
 
 func (ps ProjectStatus) AsString() string { return string(ps) }
 
+// Action is a step of the lifecycle of a project, taken by the
+// administrators.
+type Action string
+
+const (
+	// ActionApprove approves a pending project, which is then created.
+	ActionApprove Action = "approve"
+	// ActionReject rejects a pending project, which is then forgotten.
+	ActionReject Action = "reject"
+	// ActionArchive moves the data of an active project to the archive.
+	ActionArchive Action = "archive"
+	// ActionRestore moves the data of an archived project back in place.
+	ActionRestore Action = "restore"
+)
+
+// Transition records a change of the status of a project.
+type Transition struct {
+	Project string        `json:"project"`
+	From    ProjectStatus `json:"from"`
+	To      ProjectStatus `json:"to"`
+	Actor   string        `json:"actor,omitempty"`
+	Comment string        `json:"comment,omitempty"`
+	RunID   string        `json:"run_id,omitempty"`
+	Time    time.Time     `json:"time"`
+}
+
 // Catalogue is the interface that stores the project spaces.
 type Catalogue interface {
 	CreateStorageSpace(ctx context.Context, req *provider.CreateStorageSpaceRequest) (*provider.CreateStorageSpaceResponse, error)
@@ -42,3 +79,156 @@ type Catalogue interface {
 	UpdateStorageSpace(ctx context.Context, req *provider.UpdateStorageSpaceRequest) (*provider.UpdateStorageSpaceResponse, error)
 	DeleteStorageSpace(ctx context.Context, req *provider.DeleteStorageSpaceRequest) (*provider.DeleteStorageSpaceResponse, error)
 }
+
+// The CS3 spaces API knows nothing of the listing of all the projects, of
+// the quota change requests nor of the lifecycle of the projects: they travel
+// in the opaque of the requests and of the storage spaces.
+const (
+	// ListAllOpaqueKey in a ListStorageSpacesRequest asks for all the projects,
+	// sorted by decreasing usage. Only the administrators are allowed to.
+	ListAllOpaqueKey = "all"
+	// StatusOpaqueKey restricts a ListAllOpaqueKey request to the projects in
+	// the given status, and carries the status of the listed projects.
+	StatusOpaqueKey = "status"
+	// TransitionsOpaqueKey carries the transitions of the projects listed
+	// with ListAllOpaqueKey, as JSON.
+	TransitionsOpaqueKey = "transitions"
+	// RequestedQuotaOpaqueKey in a project carries the quota in bytes asked
+	// for by its pending change request.
+	RequestedQuotaOpaqueKey = "requested_quota"
+	// ActionOpaqueKey in an UpdateStorageSpaceRequest asks for a step of the
+	// lifecycle of the project.
+	ActionOpaqueKey = "action"
+	// CommentOpaqueKey in an UpdateStorageSpaceRequest or in a
+	// CreateStorageSpaceRequest is recorded with the transition.
+	CommentOpaqueKey = "comment"
+	// DescriptionOpaqueKey in a CreateStorageSpaceRequest carries the
+	// description of the requested project.
+	DescriptionOpaqueKey = "description"
+)
+
+func plainOpaque(kv ...string) *types.Opaque {
+	o := &types.Opaque{Map: map[string]*types.OpaqueEntry{}}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] != "" {
+			o.Map[kv[i]] = &types.OpaqueEntry{Decoder: "plain", Value: []byte(kv[i+1])}
+		}
+	}
+	return o
+}
+
+// PlainOpaqueValue returns the value of a plain entry of an opaque.
+func PlainOpaqueValue(o *types.Opaque, key string) string {
+	if e, ok := o.GetMap()[key]; ok && e.Decoder == "plain" {
+		return string(e.Value)
+	}
+	return ""
+}
+
+func setOpaque(space *provider.StorageSpace, key, decoder string, value []byte) {
+	if space.Opaque == nil {
+		space.Opaque = &types.Opaque{}
+	}
+	if space.Opaque.Map == nil {
+		space.Opaque.Map = map[string]*types.OpaqueEntry{}
+	}
+	space.Opaque.Map[key] = &types.OpaqueEntry{Decoder: decoder, Value: value}
+}
+
+// NewListAllRequest returns a request listing all the projects in the given
+// status, or in any status if empty.
+func NewListAllRequest(status ProjectStatus) *provider.ListStorageSpacesRequest {
+	return &provider.ListStorageSpacesRequest{
+		Opaque: plainOpaque(ListAllOpaqueKey, "true", StatusOpaqueKey, status.AsString()),
+	}
+}
+
+// IsListAllRequest tells whether the request asks for all the projects.
+func IsListAllRequest(req *provider.ListStorageSpacesRequest) bool {
+	return PlainOpaqueValue(req.GetOpaque(), ListAllOpaqueKey) == "true"
+}
+
+// ListAllStatus returns the status the projects listed by the request are
+// restricted to, empty for all.
+func ListAllStatus(req *provider.ListStorageSpacesRequest) ProjectStatus {
+	return ProjectStatus(PlainOpaqueValue(req.GetOpaque(), StatusOpaqueKey))
+}
+
+// NewActionRequest returns a request taking a step of the lifecycle of the
+// project with the given name.
+func NewActionRequest(name string, action Action, comment string) *provider.UpdateStorageSpaceRequest {
+	return &provider.UpdateStorageSpaceRequest{
+		Opaque: plainOpaque(ActionOpaqueKey, string(action), CommentOpaqueKey, comment),
+		StorageSpace: &provider.StorageSpace{
+			Id:   &provider.StorageSpaceId{OpaqueId: name},
+			Name: name,
+		},
+	}
+}
+
+// NewProjectRequest returns a request asking for a new project, to be
+// approved by the administrators.
+func NewProjectRequest(name, description string, quota uint64, comment string) *provider.CreateStorageSpaceRequest {
+	req := &provider.CreateStorageSpaceRequest{
+		Opaque: plainOpaque(DescriptionOpaqueKey, description, CommentOpaqueKey, comment),
+		Type:   "project",
+		Name:   name,
+	}
+	if quota > 0 {
+		req.Quota = &provider.Quota{QuotaMaxBytes: quota}
+	}
+	return req
+}
+
+// Status returns the status of a project.
+func Status(space *provider.StorageSpace) ProjectStatus {
+	return ProjectStatus(PlainOpaqueValue(space.GetOpaque(), StatusOpaqueKey))
+}
+
+// SetStatus records the status of a project.
+func SetStatus(space *provider.StorageSpace, status ProjectStatus) {
+	setOpaque(space, StatusOpaqueKey, "plain", []byte(status))
+}
+
+// Transitions returns the transitions of a project, if they were listed.
+func Transitions(space *provider.StorageSpace) ([]*Transition, error) {
+	e, ok := space.GetOpaque().GetMap()[TransitionsOpaqueKey]
+	if !ok {
+		return nil, nil
+	}
+	var ts []*Transition
+	if err := json.Unmarshal(e.Value, &ts); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// SetTransitions records the transitions of a project.
+func SetTransitions(space *provider.StorageSpace, ts []*Transition) error {
+	v, err := json.Marshal(ts)
+	if err != nil {
+		return err
+	}
+	setOpaque(space, TransitionsOpaqueKey, "json", v)
+	return nil
+}
+
+// RequestedQuota returns the quota asked for by the pending change request
+// of a project, and whether there is one.
+func RequestedQuota(space *provider.StorageSpace) (uint64, bool) {
+	e, ok := space.GetOpaque().GetMap()[RequestedQuotaOpaqueKey]
+	if !ok {
+		return 0, false
+	}
+	q, err := strconv.ParseUint(string(e.Value), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return q, true
+}
+
+// SetRequestedQuota records in a project the quota asked for by its pending
+// change request.
+func SetRequestedQuota(space *provider.StorageSpace, quota uint64) {
+	setOpaque(space, RequestedQuotaOpaqueKey, "plain", []byte(strconv.FormatUint(quota, 10)))
+}
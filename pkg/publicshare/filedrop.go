@@ -0,0 +1,178 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package publicshare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+)
+
+const (
+	// FileDropOpaqueKey is the key of the opaque entry carrying the FileDrop
+	// settings of a public share, in the shares and in the create and update
+	// requests.
+	FileDropOpaqueKey = "file_drop"
+	// CountUploadOpaqueKey marks a GetPublicShareRequest as the start of an
+	// upload through a file-drop link, to be recorded against its quotas.
+	// The value is the size of the upload in bytes.
+	CountUploadOpaqueKey = "count_upload"
+
+	// UploaderNameOpaqueKey and UploaderEmailOpaqueKey carry the name and
+	// email the uploader optionally gives when uploading through a file-drop
+	// link, in the opaque of the InitiateFileUploadRequest.
+	UploaderNameOpaqueKey  = "file_drop_uploader_name"
+	UploaderEmailOpaqueKey = "file_drop_uploader_email"
+	// UploadSessionOpaqueKey optionally identifies the upload session an
+	// upload through a file-drop link belongs to, so that the files uploaded
+	// together land in the same subfolder.
+	UploadSessionOpaqueKey = "file_drop_session"
+	// UploadPathOpaqueKey carries, in the opaque of the
+	// InitiateFileUploadResponse, the path the upload has been redirected to.
+	UploadPathOpaqueKey = "file_drop_path"
+
+	// UploaderNameMetadataKey and UploaderEmailMetadataKey are the arbitrary
+	// metadata keys recording the uploader of a file dropped through a link.
+	UploaderNameMetadataKey  = "reva.filedrop.uploader.name"
+	UploaderEmailMetadataKey = "reva.filedrop.uploader.email"
+)
+
+// FileDrop configures a public share with upload-only permissions as a file drop:
+// uploads never overwrite existing files, can be grouped in a subfolder per
+// upload session, and are limited in number and size.
+type FileDrop struct {
+	// Enabled turns the file-drop mode on.
+	Enabled bool `json:"enabled,omitempty"`
+	// SubfolderPerSession places the files of each upload session
+	// in their own timestamped subfolder.
+	SubfolderPerSession bool `json:"subfolder_per_session,omitempty"`
+	// MaxFileSize is the maximum size of a single upload in bytes, 0 meaning unlimited.
+	MaxFileSize uint64 `json:"max_file_size,omitempty"`
+	// MaxUploads is the number of uploads allowed through the link, 0 meaning unlimited.
+	MaxUploads int `json:"max_uploads,omitempty"`
+	// MaxBytes is the total size allowed for the uploads through the link, 0 meaning unlimited.
+	MaxBytes uint64 `json:"max_bytes,omitempty"`
+	// Uploads and UploadedBytes account the uploads done so far. They are
+	// maintained by the managers and ignored in the requests.
+	Uploads       int    `json:"uploads,omitempty"`
+	UploadedBytes uint64 `json:"uploaded_bytes,omitempty"`
+}
+
+// FileDropManager is implemented by the managers supporting file-drop public shares.
+type FileDropManager interface {
+	// SetFileDrop replaces the file-drop settings of a public share, keeping its counters.
+	SetFileDrop(ctx context.Context, u *user.User, ref *link.PublicShareReference, fd *FileDrop) (*link.PublicShare, error)
+	// CountUpload atomically records an upload of the given size through a
+	// public share. It fails with errtypes.InsufficientStorage when the upload
+	// would exceed the quotas of the share.
+	CountUpload(ctx context.Context, ref *link.PublicShareReference, size uint64) (*link.PublicShare, error)
+}
+
+// Validate checks the file-drop settings for consistency.
+func (fd *FileDrop) Validate() error {
+	if fd.MaxUploads < 0 {
+		return errtypes.BadRequest("the maximum number of uploads cannot be negative")
+	}
+	if fd.MaxFileSize > 0 && fd.MaxBytes > 0 && fd.MaxFileSize > fd.MaxBytes {
+		return errtypes.BadRequest("the maximum file size cannot exceed the maximum total size")
+	}
+	return nil
+}
+
+// IsEnabled tells whether the file-drop mode is on.
+func (fd *FileDrop) IsEnabled() bool {
+	return fd != nil && fd.Enabled
+}
+
+// HasQuota tells whether the uploads are limited in number or total size,
+// and need to be accounted.
+func (fd *FileDrop) HasQuota() bool {
+	return fd != nil && (fd.MaxUploads > 0 || fd.MaxBytes > 0)
+}
+
+// LimitsSize tells whether the size of the uploads is limited, so that an
+// upload of unknown size cannot be allowed.
+func (fd *FileDrop) LimitsSize() bool {
+	return fd != nil && (fd.MaxFileSize > 0 || fd.MaxBytes > 0)
+}
+
+// Allows checks whether an upload of the given size fits in the quotas.
+func (fd *FileDrop) Allows(size uint64) error {
+	switch {
+	case fd == nil:
+		return nil
+	case fd.MaxFileSize > 0 && size > fd.MaxFileSize:
+		return errtypes.InsufficientStorage(fmt.Sprintf("the upload exceeds the maximum file size of %d bytes", fd.MaxFileSize))
+	case fd.MaxUploads > 0 && fd.Uploads >= fd.MaxUploads:
+		return errtypes.InsufficientStorage("the maximum number of uploads has been reached")
+	case fd.MaxBytes > 0 && fd.UploadedBytes+size > fd.MaxBytes:
+		return errtypes.InsufficientStorage("the upload exceeds the space left on the link")
+	}
+	return nil
+}
+
+// AlternativeName returns the n-th alternative of a file name to avoid a
+// conflict, as in `report (2).pdf`. The first alternative is the name itself.
+func AlternativeName(name string, n int) string {
+	if n <= 1 {
+		return name
+	}
+	ext := path.Ext(name)
+	if ext == name {
+		// dot files have no extension
+		ext = ""
+	}
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+}
+
+// GetFileDrop reads the file-drop settings from an opaque, returning nil if there are none.
+func GetFileDrop(o *types.Opaque) (*FileDrop, error) {
+	e, ok := o.GetMap()[FileDropOpaqueKey]
+	if !ok {
+		return nil, nil
+	}
+	var fd FileDrop
+	if err := json.Unmarshal(e.Value, &fd); err != nil {
+		return nil, errtypes.BadRequest("invalid file drop settings: " + err.Error())
+	}
+	return &fd, nil
+}
+
+// SetFileDrop stores the file-drop settings in an opaque, allocating it when nil.
+func SetFileDrop(o *types.Opaque, fd *FileDrop) (*types.Opaque, error) {
+	v, err := json.Marshal(fd)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		o = &types.Opaque{}
+	}
+	if o.Map == nil {
+		o.Map = map[string]*types.OpaqueEntry{}
+	}
+	o.Map[FileDropOpaqueKey] = &types.OpaqueEntry{Decoder: "json", Value: v}
+	return o, nil
+}
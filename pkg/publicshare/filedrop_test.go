@@ -0,0 +1,83 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package publicshare
+
+import (
+	"testing"
+
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+)
+
+func TestAlternativeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        int
+		expected string
+	}{
+		{"report.pdf", 1, "report.pdf"},
+		{"report.pdf", 2, "report (2).pdf"},
+		{"archive.tar.gz", 3, "archive.tar (3).gz"},
+		{"README", 2, "README (2)"},
+		{".bashrc", 2, ".bashrc (2)"},
+	}
+	for _, tt := range tests {
+		if got := AlternativeName(tt.name, tt.n); got != tt.expected {
+			t.Errorf("AlternativeName(%q, %d) = %q, expected %q", tt.name, tt.n, got, tt.expected)
+		}
+	}
+}
+
+func TestFileDropAllows(t *testing.T) {
+	fd := &FileDrop{Enabled: true, MaxFileSize: 100, MaxUploads: 2, MaxBytes: 150, Uploads: 1, UploadedBytes: 100}
+
+	if err := fd.Allows(50); err != nil {
+		t.Errorf("Expected an upload within the quotas to be allowed, got %v", err)
+	}
+	for _, size := range []uint64{120, 60} {
+		if _, ok := fd.Allows(size).(errtypes.InsufficientStorage); !ok {
+			t.Errorf("Expected an upload of %d bytes to be refused", size)
+		}
+	}
+
+	fd.Uploads = 2
+	if err := fd.Allows(0); err == nil {
+		t.Error("Expected an upload past the maximum number of uploads to be refused")
+	}
+
+	var none *FileDrop
+	if err := none.Allows(1 << 40); err != nil {
+		t.Errorf("Expected no quota without file-drop settings, got %v", err)
+	}
+}
+
+func TestFileDropLimitsSize(t *testing.T) {
+	for _, tt := range []struct {
+		fd       *FileDrop
+		expected bool
+	}{
+		{nil, false},
+		{&FileDrop{Enabled: true, MaxUploads: 2}, false},
+		{&FileDrop{Enabled: true, MaxFileSize: 100}, true},
+		{&FileDrop{Enabled: true, MaxBytes: 100}, true},
+	} {
+		if got := tt.fd.LimitsSize(); got != tt.expected {
+			t.Errorf("%+v: got %t, expected %t", tt.fd, got, tt.expected)
+		}
+	}
+}
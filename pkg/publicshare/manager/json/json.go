@@ -538,6 +538,138 @@ func (m *manager) GetPublicShareByToken(ctx context.Context, token string, auth
 	return nil, errtypes.NotFound(fmt.Sprintf("share with token: `%v` not found", token))
 }
 
+// SetRestrictions replaces the download limit and client restrictions of a share, keeping its download counter.
+func (m *manager) SetRestrictions(ctx context.Context, u *user.User, ref *link.PublicShareReference, r *publicshare.Restrictions) (*link.PublicShare, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return m.modify(ref, func(ps *link.PublicShare, _ map[string]any) (bool, error) {
+		current, err := publicshare.GetRestrictions(ps.Opaque)
+		if err != nil {
+			return false, err
+		}
+		updated := *r
+		updated.Downloads = 0
+		if current != nil {
+			updated.Downloads = current.Downloads
+		}
+		ps.Opaque, err = publicshare.SetRestrictions(ps.Opaque, &updated)
+		return false, err
+	})
+}
+
+// CountDownload records a download through a share limited in downloads. The
+// transfers counted are kept in the entry of the share until they expire, so
+// that each is counted once.
+func (m *manager) CountDownload(ctx context.Context, ref *link.PublicShareReference, transferID string, expires time.Time) (*link.PublicShare, error) {
+	return m.modify(ref, func(ps *link.PublicShare, data map[string]any) (bool, error) {
+		r, err := publicshare.GetRestrictions(ps.Opaque)
+		if err != nil || r == nil {
+			return false, err
+		}
+
+		counted, _ := data["counted_downloads"].(map[string]any)
+		if counted == nil {
+			counted = map[string]any{}
+		}
+		now := time.Now().Unix()
+		for id, exp := range counted {
+			if e, ok := exp.(float64); !ok || int64(e) < now {
+				delete(counted, id)
+			}
+		}
+		if _, ok := counted[transferID]; ok {
+			return false, nil
+		}
+
+		if r.Exhausted() {
+			return false, errtypes.PermissionDenied("json: download limit reached for share " + ps.Token)
+		}
+		r.Downloads++
+		counted[transferID] = float64(expires.Unix())
+		data["counted_downloads"] = counted
+		ps.Opaque, err = publicshare.SetRestrictions(ps.Opaque, r)
+		return r.RevokeWhenExhausted && r.Exhausted(), err
+	})
+}
+
+// SetFileDrop replaces the file-drop settings of a share, keeping its upload counters.
+func (m *manager) SetFileDrop(ctx context.Context, u *user.User, ref *link.PublicShareReference, fd *publicshare.FileDrop) (*link.PublicShare, error) {
+	if err := fd.Validate(); err != nil {
+		return nil, err
+	}
+	return m.modify(ref, func(ps *link.PublicShare, _ map[string]any) (bool, error) {
+		current, err := publicshare.GetFileDrop(ps.Opaque)
+		if err != nil {
+			return false, err
+		}
+		updated := *fd
+		updated.Uploads, updated.UploadedBytes = 0, 0
+		if current != nil {
+			updated.Uploads, updated.UploadedBytes = current.Uploads, current.UploadedBytes
+		}
+		ps.Opaque, err = publicshare.SetFileDrop(ps.Opaque, &updated)
+		return false, err
+	})
+}
+
+// CountUpload records an upload through a file-drop share.
+func (m *manager) CountUpload(ctx context.Context, ref *link.PublicShareReference, size uint64) (*link.PublicShare, error) {
+	return m.modify(ref, func(ps *link.PublicShare, _ map[string]any) (bool, error) {
+		fd, err := publicshare.GetFileDrop(ps.Opaque)
+		if err != nil || fd == nil {
+			return false, err
+		}
+		if err := fd.Allows(size); err != nil {
+			return false, err
+		}
+		fd.Uploads++
+		fd.UploadedBytes += size
+		ps.Opaque, err = publicshare.SetFileDrop(ps.Opaque, fd)
+		return false, err
+	})
+}
+
+// modify applies fn to the referenced share and its database entry while
+// holding the lock, then persists them, or removes them if fn says so.
+func (m *manager) modify(ref *link.PublicShareReference, fn func(*link.PublicShare, map[string]any) (bool, error)) (*link.PublicShare, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	db, err := m.readDB()
+	if err != nil {
+		return nil, err
+	}
+
+	for id, v := range db {
+		data := v.(map[string]any)
+		var ps link.PublicShare
+		if err := utils.UnmarshalJSONToProtoV1([]byte(data["share"].(string)), &ps); err != nil {
+			return nil, err
+		}
+		if ps.Id.GetOpaqueId() != ref.GetId().GetOpaqueId() && (ref.GetToken() == "" || ps.Token != ref.GetToken()) {
+			continue
+		}
+
+		remove, err := fn(&ps, data)
+		if err != nil {
+			return nil, err
+		}
+		if remove {
+			delete(db, id)
+		} else {
+			encShare, err := utils.MarshalProtoV1ToJSON(&ps)
+			if err != nil {
+				return nil, err
+			}
+			data["share"] = string(encShare)
+		}
+		return &ps, m.writeDB(db)
+	}
+
+	return nil, errtypes.NotFound(ref.String())
+}
+
 func (m *manager) readDB() (map[string]any, error) {
 	db := map[string]any{}
 	readBytes, err := os.ReadFile(m.file)
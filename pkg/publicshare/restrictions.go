@@ -0,0 +1,138 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package publicshare
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"time"
+
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/clientip"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/utils"
+)
+
+const (
+	// RestrictionsOpaqueKey is the key of the opaque entry carrying the
+	// Restrictions of a public share, in the shares and in the create and
+	// update requests.
+	RestrictionsOpaqueKey = "link_restrictions"
+	// CountDownloadOpaqueKey carries, in a GetPublicShareByTokenRequest, the
+	// transfer token of a download through a link served by the data gateway,
+	// to be recorded once against the download limit of the link and checked
+	// against its allowed networks.
+	CountDownloadOpaqueKey = "count_download"
+)
+
+// Restrictions limit the use of a public share beyond its password and expiration.
+type Restrictions struct {
+	// MaxDownloads is the number of downloads allowed through the link,
+	// 0 meaning unlimited.
+	MaxDownloads int `json:"max_downloads,omitempty"`
+	// Downloads is the number of downloads done so far. It is maintained
+	// by the managers and ignored in the requests.
+	Downloads int `json:"downloads,omitempty"`
+	// RevokeWhenExhausted removes the link once MaxDownloads is reached.
+	RevokeWhenExhausted bool `json:"revoke_when_exhausted,omitempty"`
+	// AllowedNetworks restricts the clients using the link to the given
+	// networks, in CIDR notation. If empty, all the clients are allowed.
+	AllowedNetworks []string `json:"allowed_networks,omitempty"`
+}
+
+// RestrictionsManager is implemented by the managers supporting restrictions on public shares.
+type RestrictionsManager interface {
+	// SetRestrictions replaces the restrictions of a public share, keeping its download counter.
+	SetRestrictions(ctx context.Context, u *user.User, ref *link.PublicShareReference, r *Restrictions) (*link.PublicShare, error)
+	// CountDownload atomically records a download through a public share. The
+	// transfer is recorded with the counter until it expires, and a transfer
+	// already recorded is not counted again, whichever replica serves it. It
+	// fails with errtypes.PermissionDenied when the download limit is already
+	// reached, and revokes the share when the last allowed download is recorded
+	// and the share is configured so.
+	CountDownload(ctx context.Context, ref *link.PublicShareReference, transferID string, expires time.Time) (*link.PublicShare, error)
+}
+
+// Validate checks the restrictions for consistency.
+func (r *Restrictions) Validate() error {
+	if r.MaxDownloads < 0 {
+		return errtypes.BadRequest("the maximum number of downloads cannot be negative")
+	}
+	for _, n := range r.AllowedNetworks {
+		if _, _, err := net.ParseCIDR(n); err != nil && net.ParseIP(n) == nil {
+			return errtypes.BadRequest("invalid network " + n)
+		}
+	}
+	return nil
+}
+
+// IsZero tells whether the restrictions do not restrict anything.
+func (r *Restrictions) IsZero() bool {
+	return r == nil || (r.MaxDownloads == 0 && len(r.AllowedNetworks) == 0)
+}
+
+// Exhausted tells whether no download is left.
+func (r *Restrictions) Exhausted() bool {
+	return r != nil && r.MaxDownloads > 0 && r.Downloads >= r.MaxDownloads
+}
+
+// AllowsClient tells whether the client that originated the request in ctx
+// may use the share. The client IP is set by the HTTP auth middleware and
+// forwarded along the gRPC calls: without it a network restriction cannot be
+// enforced, and the access is refused.
+func (r *Restrictions) AllowsClient(ctx context.Context) bool {
+	if r == nil || len(r.AllowedNetworks) == 0 {
+		return true
+	}
+	ip := clientip.FromContext(ctx)
+	return ip != "" && utils.IPInNetworks(ip, r.AllowedNetworks)
+}
+
+// GetRestrictions reads the restrictions from an opaque, returning nil if there are none.
+func GetRestrictions(o *types.Opaque) (*Restrictions, error) {
+	e, ok := o.GetMap()[RestrictionsOpaqueKey]
+	if !ok {
+		return nil, nil
+	}
+	var r Restrictions
+	if err := json.Unmarshal(e.Value, &r); err != nil {
+		return nil, errtypes.BadRequest("invalid link restrictions: " + err.Error())
+	}
+	return &r, nil
+}
+
+// SetRestrictions stores the restrictions in an opaque, allocating it when nil.
+// Zero restrictions are stored as well, so that requests can lift them.
+func SetRestrictions(o *types.Opaque, r *Restrictions) (*types.Opaque, error) {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		o = &types.Opaque{}
+	}
+	if o.Map == nil {
+		o.Map = map[string]*types.OpaqueEntry{}
+	}
+	o.Map[RestrictionsOpaqueKey] = &types.OpaqueEntry{Decoder: "json", Value: v}
+	return o, nil
+}
@@ -0,0 +1,314 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package ratelimit implements token bucket rate limiting and the progressive
+// lockout of clients failing to authenticate, shared by the HTTP and gRPC
+// ratelimit interceptors.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Request describes a request to be checked against the limits.
+type Request struct {
+	// User is the username the request is made for, if known.
+	User string
+	// IP is the address of the client.
+	IP string
+	// Route is the HTTP path or the gRPC method of the request.
+	Route string
+}
+
+// Rule is a token bucket limit applied to the requests matching its routes.
+type Rule struct {
+	// By lists the properties of the request the buckets are keyed by,
+	// among "user", "ip" and "route". A request lacking one of them,
+	// for example an anonymous request for a rule keyed by user,
+	// is not limited by the rule.
+	By []string `mapstructure:"by"`
+	// Routes restricts the rule to the HTTP paths or gRPC methods starting
+	// with one of the given prefixes. If a rule keyed by route has routes,
+	// the matching prefix is used as key, otherwise the full route.
+	Routes []string `mapstructure:"routes"`
+	// Rate is the number of requests per second refilling the bucket.
+	Rate float64 `mapstructure:"rate"`
+	// Burst is the size of the bucket.
+	Burst int `mapstructure:"burst"`
+}
+
+// Config holds the configuration of a Limiter.
+type Config struct {
+	// Driver is the store of the counters, either "memory" or "redis".
+	// Use redis to share the counters among multiple replicas.
+	Driver        string `mapstructure:"driver"`
+	RedisAddress  string `mapstructure:"redis_address"`
+	RedisUsername string `mapstructure:"redis_username"`
+	RedisPassword string `mapstructure:"redis_password"`
+	RedisPrefix   string `mapstructure:"redis_prefix"`
+
+	Rules []*Rule `mapstructure:"rules"`
+
+	// LockoutThreshold is the number of failed authentications of a
+	// client after which it is locked out. The failures are counted per
+	// account and client, or per client for the anonymous requests.
+	LockoutThreshold int `mapstructure:"lockout_threshold"`
+	// AccountLockoutThreshold is the number of failed authentications of
+	// an account, from any client, after which the account is locked out.
+	AccountLockoutThreshold int `mapstructure:"account_lockout_threshold"`
+	// LockoutDuration is the duration in seconds of the first lockout,
+	// doubled at each further failure.
+	LockoutDuration int `mapstructure:"lockout_duration"`
+	// LockoutMaxDuration caps the duration in seconds of a lockout.
+	LockoutMaxDuration int `mapstructure:"lockout_max_duration"`
+	// FailureWindow is the time in seconds after which the failed
+	// authentications of a client are forgotten.
+	FailureWindow int `mapstructure:"failure_window"`
+}
+
+// ApplyDefaults applies the default configuration.
+func (c *Config) ApplyDefaults() {
+	if c.Driver == "" {
+		c.Driver = "memory"
+	}
+	if c.RedisAddress == "" {
+		c.RedisAddress = "localhost:6379"
+	}
+	if c.RedisPrefix == "" {
+		c.RedisPrefix = "reva:ratelimit:"
+	}
+	if len(c.Rules) == 0 {
+		c.Rules = []*Rule{{By: []string{"ip"}, Rate: 50, Burst: 100}}
+	}
+	for _, r := range c.Rules {
+		if r.Burst == 0 {
+			r.Burst = int(math.Max(1, math.Ceil(r.Rate)))
+		}
+	}
+	if c.LockoutThreshold == 0 {
+		c.LockoutThreshold = 5
+	}
+	if c.AccountLockoutThreshold == 0 {
+		c.AccountLockoutThreshold = 20
+	}
+	if c.LockoutDuration == 0 {
+		c.LockoutDuration = 30
+	}
+	if c.LockoutMaxDuration == 0 {
+		c.LockoutMaxDuration = 3600
+	}
+	if c.FailureWindow == 0 {
+		c.FailureWindow = 3600
+	}
+}
+
+// Store keeps the state of the buckets and of the failed authentications.
+type Store interface {
+	// Take removes a token from the bucket identified by key, refilled at
+	// rate tokens per second up to burst tokens. If the bucket is empty,
+	// it returns how long to wait for the next token.
+	Take(ctx context.Context, key string, rate float64, burst int) (time.Duration, error)
+	// AddFailure records a failed authentication for key, forgotten after
+	// ttl, and returns the number of failures currently recorded.
+	AddFailure(ctx context.Context, key string, ttl time.Duration) (int, error)
+	// Failures returns the number of failures recorded for key and the
+	// time of the last one.
+	Failures(ctx context.Context, key string) (int, time.Time, error)
+	// ResetFailures forgets the failures recorded for key.
+	ResetFailures(ctx context.Context, key string) error
+}
+
+// Limiter applies the rate limits and the lockouts to the requests.
+type Limiter struct {
+	c     *Config
+	store Store
+	now   func() time.Time
+}
+
+// New returns a Limiter using the store configured in c.
+func New(c *Config) (*Limiter, error) {
+	var store Store
+	switch c.Driver {
+	case "memory":
+		store = NewMemoryStore()
+	case "redis":
+		store = NewRedisStore(c.RedisAddress, c.RedisUsername, c.RedisPassword, c.RedisPrefix)
+	default:
+		return nil, errors.New("ratelimit: unknown driver " + c.Driver)
+	}
+	return NewWithStore(c, store)
+}
+
+// NewWithStore returns a Limiter keeping its state in the given store.
+func NewWithStore(c *Config, store Store) (*Limiter, error) {
+	for _, r := range c.Rules {
+		if r.Rate <= 0 {
+			return nil, errors.New("ratelimit: the rate of a rule must be positive")
+		}
+		for _, by := range r.By {
+			if by != "user" && by != "ip" && by != "route" {
+				return nil, errors.New("ratelimit: unknown rule key " + by)
+			}
+		}
+	}
+	return &Limiter{c: c, store: store, now: time.Now}, nil
+}
+
+// Allow checks whether the request can be served. If the client is locked
+// out or exceeded a limit, it returns how long the client has to wait.
+func (l *Limiter) Allow(ctx context.Context, req *Request) (time.Duration, error) {
+	if wait, err := l.lockedOut(ctx, req); err != nil || wait > 0 {
+		return wait, err
+	}
+
+	var wait time.Duration
+	for i, r := range l.c.Rules {
+		key, ok := r.key(req)
+		if !ok {
+			continue
+		}
+		w, err := l.store.Take(ctx, fmt.Sprintf("bucket:%d:%s", i, key), r.Rate, r.Burst)
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, w)
+	}
+	return wait, nil
+}
+
+// Failed records a failed authentication of the client of the request,
+// against the account and against the account from this client. The failures
+// of the anonymous requests are counted per client, and not counted at all
+// if the address of the client is unknown, not to lock out all of them at once.
+func (l *Limiter) Failed(ctx context.Context, req *Request) error {
+	for _, c := range l.failureCounters(req) {
+		if _, err := l.store.AddFailure(ctx, c.key, time.Duration(l.c.FailureWindow)*time.Second); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Succeeded forgets the failed authentications of the account of the
+// request. The failures of the anonymous requests are never forgotten before
+// the end of the failure window, as a success with some credentials tells
+// nothing about the attempts with others.
+func (l *Limiter) Succeeded(ctx context.Context, req *Request) error {
+	if req.User == "" {
+		return nil
+	}
+	for _, c := range l.failureCounters(req) {
+		if err := l.store.ResetFailures(ctx, c.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Limiter) lockedOut(ctx context.Context, req *Request) (time.Duration, error) {
+	var wait time.Duration
+	for _, c := range l.failureCounters(req) {
+		n, last, err := l.store.Failures(ctx, c.key)
+		if err != nil {
+			return 0, err
+		}
+		if n < c.threshold {
+			continue
+		}
+		until := last.Add(l.lockoutDuration(n, c.threshold))
+		wait = max(wait, until.Sub(l.now()))
+	}
+	return wait, nil
+}
+
+// lockoutDuration returns how long a client is locked out after n failures,
+// doubling at each failure over the threshold.
+func (l *Limiter) lockoutDuration(n, threshold int) time.Duration {
+	maxDuration := time.Duration(l.c.LockoutMaxDuration) * time.Second
+	d := time.Duration(l.c.LockoutDuration) * time.Second
+	for i := threshold; i < n && d < maxDuration; i++ {
+		d *= 2
+	}
+	return min(d, maxDuration)
+}
+
+// failureCounter is a count of failed authentications and the number of
+// failures locking out the requests it applies to.
+type failureCounter struct {
+	key       string
+	threshold int
+}
+
+// failureCounters returns the counters the failures of a request are
+// recorded against.
+func (l *Limiter) failureCounters(req *Request) []failureCounter {
+	switch {
+	case req.User != "":
+		counters := []failureCounter{{key: "failures:user:" + req.User, threshold: l.c.AccountLockoutThreshold}}
+		if req.IP != "" {
+			counters = append(counters, failureCounter{key: "failures:user:" + req.User + ":ip:" + req.IP, threshold: l.c.LockoutThreshold})
+		}
+		return counters
+	case req.IP != "":
+		return []failureCounter{{key: "failures:ip:" + req.IP, threshold: l.c.LockoutThreshold}}
+	}
+	return nil
+}
+
+func (r *Rule) key(req *Request) (string, bool) {
+	route, ok := r.matchRoute(req.Route)
+	if !ok {
+		return "", false
+	}
+
+	parts := make([]string, 0, len(r.By))
+	for _, by := range r.By {
+		var v string
+		switch by {
+		case "user":
+			v = req.User
+		case "ip":
+			v = req.IP
+		case "route":
+			v = route
+		}
+		if v == "" {
+			return "", false
+		}
+		parts = append(parts, v)
+	}
+	return strings.Join(parts, ":"), true
+}
+
+func (r *Rule) matchRoute(route string) (string, bool) {
+	if len(r.Routes) == 0 {
+		return route, true
+	}
+	for _, prefix := range r.Routes {
+		if strings.HasPrefix(route, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
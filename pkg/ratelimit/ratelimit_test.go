@@ -0,0 +1,229 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(t *testing.T, c *Config) (*Limiter, *clock) {
+	t.Helper()
+	c.ApplyDefaults()
+	clk := &clock{t: time.Unix(1700000000, 0)}
+	store := NewMemoryStore().(*memoryStore)
+	store.now = clk.now
+	l, err := NewWithStore(c, store)
+	if err != nil {
+		t.Fatalf("NewWithStore returned error: %v", err)
+	}
+	l.now = clk.now
+	return l, clk
+}
+
+func TestTokenBucket(t *testing.T) {
+	l, clk := newTestLimiter(t, &Config{
+		Rules: []*Rule{{By: []string{"ip"}, Rate: 1, Burst: 2}},
+	})
+	ctx := context.Background()
+	req := &Request{IP: "192.0.2.1", Route: "/remote.php/dav"}
+
+	for i := 0; i < 2; i++ {
+		if wait, err := l.Allow(ctx, req); err != nil || wait != 0 {
+			t.Fatalf("request %d: got wait %v, err %v, expected to be allowed", i, wait, err)
+		}
+	}
+	wait, err := l.Allow(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait != time.Second {
+		t.Errorf("got wait %v, expected 1s", wait)
+	}
+
+	if wait, _ := l.Allow(ctx, &Request{IP: "192.0.2.2", Route: "/remote.php/dav"}); wait != 0 {
+		t.Errorf("expected another client not to be limited, got wait %v", wait)
+	}
+
+	clk.advance(2 * time.Second)
+	if wait, _ := l.Allow(ctx, req); wait != 0 {
+		t.Errorf("expected the bucket to be refilled, got wait %v", wait)
+	}
+}
+
+func TestRuleKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     *Rule
+		req      *Request
+		key      string
+		expected bool
+	}{
+		{"ip", &Rule{By: []string{"ip"}}, &Request{IP: "192.0.2.1", Route: "/ocs"}, "192.0.2.1", true},
+		{"anonymous user", &Rule{By: []string{"user"}}, &Request{IP: "192.0.2.1"}, "", false},
+		{"user and ip", &Rule{By: []string{"user", "ip"}}, &Request{User: "einstein", IP: "192.0.2.1"}, "einstein:192.0.2.1", true},
+		{"matching route", &Rule{By: []string{"route"}, Routes: []string{"/ocs", "/remote.php"}}, &Request{Route: "/remote.php/dav/files"}, "/remote.php", true},
+		{"other route", &Rule{By: []string{"ip"}, Routes: []string{"/ocs"}}, &Request{IP: "192.0.2.1", Route: "/remote.php"}, "", false},
+		{"full route", &Rule{By: []string{"route"}}, &Request{Route: "/cs3.gateway.v1beta1.GatewayAPI/Authenticate"}, "/cs3.gateway.v1beta1.GatewayAPI/Authenticate", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := tt.rule.key(tt.req)
+			if ok != tt.expected || key != tt.key {
+				t.Errorf("got (%q, %t), expected (%q, %t)", key, ok, tt.key, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLockout(t *testing.T) {
+	l, clk := newTestLimiter(t, &Config{
+		LockoutThreshold:   3,
+		LockoutDuration:    10,
+		LockoutMaxDuration: 30,
+	})
+	ctx := context.Background()
+	req := &Request{User: "einstein", IP: "192.0.2.1"}
+
+	fail := func() {
+		t.Helper()
+		if err := l.Failed(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fail()
+	fail()
+	if wait, _ := l.Allow(ctx, req); wait != 0 {
+		t.Fatalf("expected no lockout below the threshold, got wait %v", wait)
+	}
+
+	fail()
+	if wait, _ := l.Allow(ctx, req); wait != 10*time.Second {
+		t.Fatalf("got wait %v, expected 10s", wait)
+	}
+
+	clk.advance(10 * time.Second)
+	if wait, _ := l.Allow(ctx, req); wait != 0 {
+		t.Fatalf("expected the lockout to be over, got wait %v", wait)
+	}
+
+	fail()
+	if wait, _ := l.Allow(ctx, req); wait != 20*time.Second {
+		t.Fatalf("got wait %v, expected the lockout to double to 20s", wait)
+	}
+
+	clk.advance(20 * time.Second)
+	fail()
+	if wait, _ := l.Allow(ctx, req); wait != 30*time.Second {
+		t.Fatalf("got wait %v, expected the lockout to be capped to 30s", wait)
+	}
+
+	if err := l.Succeeded(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := l.Allow(ctx, req); wait != 0 {
+		t.Fatalf("expected a successful login to reset the lockout, got wait %v", wait)
+	}
+}
+
+func TestFailureWindow(t *testing.T) {
+	l, clk := newTestLimiter(t, &Config{LockoutThreshold: 2, FailureWindow: 60})
+	ctx := context.Background()
+	req := &Request{IP: "192.0.2.1"}
+
+	_ = l.Failed(ctx, req)
+	clk.advance(2 * time.Minute)
+	_ = l.Failed(ctx, req)
+	if wait, _ := l.Allow(ctx, req); wait != 0 {
+		t.Fatalf("expected the old failure to be forgotten, got wait %v", wait)
+	}
+}
+
+func TestUnknownClientNotLockedOut(t *testing.T) {
+	l, _ := newTestLimiter(t, &Config{LockoutThreshold: 1})
+	ctx := context.Background()
+
+	_ = l.Failed(ctx, &Request{})
+	if wait, _ := l.Allow(ctx, &Request{}); wait != 0 {
+		t.Fatalf("expected the anonymous clients of unknown address not to be locked out, got wait %v", wait)
+	}
+}
+
+func TestSuccessOnOtherAccountKeepsLockout(t *testing.T) {
+	l, _ := newTestLimiter(t, &Config{LockoutThreshold: 2})
+	ctx := context.Background()
+	victim := &Request{User: "einstein", IP: "192.0.2.1"}
+	own := &Request{User: "mallory", IP: "192.0.2.1"}
+
+	_ = l.Failed(ctx, victim)
+	_ = l.Succeeded(ctx, own)
+	_ = l.Failed(ctx, victim)
+	if wait, _ := l.Allow(ctx, victim); wait == 0 {
+		t.Fatal("expected a success on another account not to reset the failures")
+	}
+	if wait, _ := l.Allow(ctx, own); wait != 0 {
+		t.Fatalf("expected the other account not to be locked out, got wait %v", wait)
+	}
+
+	// nor does a success reset the failures of the anonymous requests
+	anonymous := &Request{IP: "192.0.2.2"}
+	_ = l.Failed(ctx, anonymous)
+	_ = l.Succeeded(ctx, anonymous)
+	_ = l.Failed(ctx, anonymous)
+	if wait, _ := l.Allow(ctx, anonymous); wait == 0 {
+		t.Fatal("expected the anonymous failures to be kept")
+	}
+}
+
+func TestAccountLockout(t *testing.T) {
+	l, _ := newTestLimiter(t, &Config{LockoutThreshold: 10, AccountLockoutThreshold: 3})
+	ctx := context.Background()
+
+	// an account attacked from many clients is locked out
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		_ = l.Failed(ctx, &Request{User: "einstein", IP: ip})
+	}
+	if wait, _ := l.Allow(ctx, &Request{User: "einstein", IP: "192.0.2.4"}); wait == 0 {
+		t.Fatal("expected the account to be locked out")
+	}
+	if wait, _ := l.Allow(ctx, &Request{User: "marie", IP: "192.0.2.1"}); wait != 0 {
+		t.Fatalf("expected the other accounts not to be locked out, got wait %v", wait)
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	for _, r := range []*Rule{
+		{By: []string{"ip"}, Rate: 0},
+		{By: []string{"host"}, Rate: 1},
+	} {
+		c := &Config{Rules: []*Rule{r}}
+		c.ApplyDefaults()
+		if _, err := NewWithStore(c, NewMemoryStore()); err == nil {
+			t.Errorf("expected rule %+v to be rejected", r)
+		}
+	}
+}
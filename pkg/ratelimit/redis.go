@@ -0,0 +1,158 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// takeScript atomically refills and takes a token from a bucket, using the
+// clock of the redis server so that the replicas agree on the elapsed time.
+// It returns the time to wait in seconds as a string, as redis truncates
+// the numbers returned by scripts to integers.
+var takeScript = redis.NewScript(1, `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(b[1]) or burst
+local last = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - last) * rate)
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+else
+  wait = (1 - tokens) / rate
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', tostring(now))
+redis.call('EXPIRE', KEYS[1], math.ceil(burst / rate) + 1)
+return tostring(wait)
+`)
+
+type redisStore struct {
+	pool   *redis.Pool
+	prefix string
+}
+
+// NewRedisStore returns a Store keeping the counters in redis,
+// to share them among multiple replicas.
+func NewRedisStore(address, username, password, prefix string) Store {
+	pool := &redis.Pool{
+		MaxIdle:     50,
+		MaxActive:   1000,
+		IdleTimeout: 240 * time.Second,
+
+		Dial: func() (redis.Conn, error) {
+			var opts []redis.DialOption
+			if username != "" {
+				opts = append(opts, redis.DialUsername(username))
+			}
+			if password != "" {
+				opts = append(opts, redis.DialPassword(password))
+			}
+			return redis.Dial("tcp", address, opts...)
+		},
+
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+	return &redisStore{pool: pool, prefix: prefix}
+}
+
+func (s *redisStore) Take(ctx context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	res, err := redis.String(takeScript.Do(conn, s.prefix+key, rate, burst))
+	if err != nil {
+		return 0, err
+	}
+	wait, err := strconv.ParseFloat(res, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait * float64(time.Second)), nil
+}
+
+func (s *redisStore) AddFailure(ctx context.Context, key string, ttl time.Duration) (int, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	key = s.prefix + key
+	if err := conn.Send("MULTI"); err != nil {
+		return 0, err
+	}
+	_ = conn.Send("HINCRBY", key, "count", 1)
+	_ = conn.Send("HSET", key, "last", time.Now().Unix())
+	_ = conn.Send("EXPIRE", key, int(ttl.Seconds()))
+	res, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, err
+	}
+	return redis.Int(res[0], nil)
+}
+
+func (s *redisStore) Failures(ctx context.Context, key string) (int, time.Time, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer conn.Close()
+
+	res, err := redis.Values(conn.Do("HMGET", s.prefix+key, "count", "last"))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if res[0] == nil || res[1] == nil {
+		return 0, time.Time{}, nil
+	}
+	count, err := redis.Int(res[0], nil)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	last, err := redis.Int64(res[1], nil)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return count, time.Unix(last, 0), nil
+}
+
+func (s *redisStore) ResetFailures(ctx context.Context, key string) error {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("DEL", s.prefix+key)
+	return err
+}
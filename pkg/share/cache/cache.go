@@ -45,3 +45,6 @@ type ResourceInfoCache = GenericCache[*provider.ResourceInfo]
 // Space cache
 // We don't need to warm up this one
 type SpaceInfoCache = GenericCache[*provider.StorageSpace]
+
+// Thumbnail cache
+type ThumbnailCache = GenericCache[[]byte]
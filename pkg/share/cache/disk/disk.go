@@ -0,0 +1,197 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package disk
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/share/cache"
+	"github.com/cs3org/reva/v3/pkg/share/cache/registry"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	registry.Register("disk_thumbnail", New)
+}
+
+// every entry starts with its expiration, in unix nanoseconds
+// (zero for entries that do not expire), followed by the value.
+const headerSize = 8
+
+type config struct {
+	Root          string `docs:"/var/tmp/reva/thumbnails;The directory keeping the cached entries." mapstructure:"root"`
+	PurgeInterval int    `docs:"3600;Seconds between the removals of the expired entries."          mapstructure:"purge_interval"`
+}
+
+func (c *config) ApplyDefaults() {
+	if c.Root == "" {
+		c.Root = "/var/tmp/reva/thumbnails"
+	}
+	if c.PurgeInterval == 0 {
+		c.PurgeInterval = 3600
+	}
+}
+
+type manager struct {
+	root string
+	done chan struct{}
+	once sync.Once
+}
+
+// New returns a cache of byte slices keeping every entry in a file of the local disk.
+func New(m map[string]any) (cache.ThumbnailCache, error) {
+	var c config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(c.Root, 0700); err != nil {
+		return nil, err
+	}
+
+	mgr := &manager{root: c.Root, done: make(chan struct{})}
+	go mgr.purgeEvery(time.Duration(c.PurgeInterval) * time.Second)
+	return mgr, nil
+}
+
+// purgeEvery removes the expired entries every interval, until the cache is closed.
+func (m *manager) purgeEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case now := <-ticker.C:
+			m.purge(now)
+		}
+	}
+}
+
+// Close stops the removal of the expired entries.
+func (m *manager) Close() error {
+	m.once.Do(func() { close(m.done) })
+	return nil
+}
+
+func (m *manager) path(key string) string {
+	h := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(h[:])
+	return filepath.Join(m.root, name[:2], name)
+}
+
+func (m *manager) Get(key string) ([]byte, error) {
+	p := m.path(key)
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errtypes.NotFound(key)
+		}
+		return nil, err
+	}
+	if len(data) < headerSize || expired(data, time.Now()) {
+		_ = os.Remove(p)
+		return nil, errtypes.NotFound(key)
+	}
+	return data[headerSize:], nil
+}
+
+func (m *manager) GetKeys(keys []string) ([][]byte, error) {
+	vals := make([][]byte, len(keys))
+	for i, key := range keys {
+		if v, err := m.Get(key); err == nil {
+			vals[i] = v
+		}
+	}
+	return vals, nil
+}
+
+func (m *manager) Set(key string, val []byte) error {
+	return m.set(key, val, 0)
+}
+
+func (m *manager) SetWithExpire(key string, val []byte, expiration time.Duration) error {
+	return m.set(key, val, time.Now().Add(expiration).UnixNano())
+}
+
+func (m *manager) set(key string, val []byte, expiresAt int64) error {
+	p := m.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+
+	// write to a temporary file first, readers never see partial entries
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	header := make([]byte, headerSize)
+	binary.BigEndian.PutUint64(header, uint64(expiresAt))
+	if _, err := tmp.Write(header); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(val); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func expired(data []byte, now time.Time) bool {
+	expiresAt := int64(binary.BigEndian.Uint64(data[:headerSize]))
+	return expiresAt != 0 && expiresAt < now.UnixNano()
+}
+
+// purge removes the expired entries.
+func (m *manager) purge(now time.Time) {
+	err := filepath.WalkDir(m.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return nil
+		}
+		header := make([]byte, headerSize)
+		_, err = io.ReadFull(f, header)
+		f.Close()
+		if err != nil || expired(header, now) {
+			_ = os.Remove(p)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("root", m.root).Msg("error purging the disk cache")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package disk
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDiskCache(t *testing.T) {
+	c, err := New(map[string]any{"root": t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := c.(*manager)
+	defer m.Close()
+
+	if _, err := m.Get("missing"); err == nil {
+		t.Fatal("expected an error for a missing key")
+	}
+
+	if err := m.Set("forever", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetWithExpire("expiring", []byte("other"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	vals, err := m.GetKeys([]string{"forever", "missing", "expiring"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(vals[0], []byte("value")) || vals[1] != nil || !bytes.Equal(vals[2], []byte("other")) {
+		t.Fatalf("unexpected values %q", vals)
+	}
+
+	m.purge(time.Now().Add(time.Hour))
+	if _, err := m.Get("expiring"); err == nil {
+		t.Fatal("expected the expired entry to be purged")
+	}
+	if v, err := m.Get("forever"); err != nil || !bytes.Equal(v, []byte("value")) {
+		t.Fatalf("Get(forever) = %q, %v", v, err)
+	}
+}
+
+func TestDiskCacheClose(t *testing.T) {
+	c, err := New(map[string]any{"root": t.TempDir(), "purge_interval": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := c.(*manager)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("closing twice must not fail: %v", err)
+	}
+	select {
+	case <-m.done:
+	default:
+		t.Fatal("expected the purge to be stopped")
+	}
+}
@@ -20,6 +20,7 @@ package loader
 
 import (
 	// Load share cache drivers.
+	_ "github.com/cs3org/reva/v3/pkg/share/cache/disk"
 	_ "github.com/cs3org/reva/v3/pkg/share/cache/memory"
 	_ "github.com/cs3org/reva/v3/pkg/share/cache/redis"
 	// Add your own here.
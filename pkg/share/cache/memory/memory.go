@@ -31,6 +31,7 @@ import (
 func init() {
 	registry.Register("memory", New[*provider.ResourceInfo])
 	registry.Register("memory_space", New[*provider.StorageSpace])
+	registry.Register("memory_thumbnail", New[[]byte])
 
 }
 
@@ -33,6 +33,7 @@ import (
 func init() {
 	registry.Register("redis", New[*provider.ResourceInfo])
 	registry.Register("redis_space", New[*provider.StorageSpace])
+	registry.Register("redis_thumbnail", New[[]byte])
 }
 
 type config struct {
@@ -0,0 +1,83 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package expiration implements the expiration policy of the shares and
+// public links, enforced by the sharing APIs when shares are created.
+package expiration
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+)
+
+// Policy is the expiration policy of a kind of shares. Expiration dates
+// are computed from the end of the current day, as clients only let the
+// users pick a day.
+type Policy struct {
+	// DefaultDays is the number of days after which a share without an
+	// explicit expiration date expires. Zero means no default.
+	DefaultDays int `docs:"0;Number of days after which shares expire by default. 0 means no default." mapstructure:"default_days"`
+	// MaxDays is the maximum number of days a share can be valid for.
+	// When set, every share gets an expiration date. Zero means no maximum.
+	MaxDays int `docs:"0;Maximum number of days shares can be valid for. 0 means no maximum."         mapstructure:"max_days"`
+}
+
+// Enabled returns whether the policy sets or limits any expiration.
+func (p Policy) Enabled() bool {
+	return p.DefaultDays > 0 || p.MaxDays > 0
+}
+
+// Max returns the latest expiration date allowed by the policy, if any.
+func (p Policy) Max(now time.Time) (time.Time, bool) {
+	if p.MaxDays <= 0 {
+		return time.Time{}, false
+	}
+	return endOfDay(now).AddDate(0, 0, p.MaxDays), true
+}
+
+// Apply returns the expiration date of a new share given the requested one,
+// which is nil if the user did not request any. It returns a BadRequest error
+// if the requested date is later than the maximum allowed.
+func (p Policy) Apply(now time.Time, requested *time.Time) (*time.Time, error) {
+	max, hasMax := p.Max(now)
+	if requested == nil {
+		switch {
+		case p.DefaultDays > 0:
+			t := endOfDay(now).AddDate(0, 0, p.DefaultDays)
+			if hasMax && t.After(max) {
+				t = max
+			}
+			return &t, nil
+		case hasMax:
+			return &max, nil
+		default:
+			return nil, nil
+		}
+	}
+
+	if hasMax && requested.After(max) {
+		return nil, errtypes.BadRequest(fmt.Sprintf("expiration date exceeds the maximum of %d days", p.MaxDays))
+	}
+	return requested, nil
+}
+
+func endOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 59, 0, t.Location())
+}
@@ -0,0 +1,72 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package expiration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+)
+
+func TestApply(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	day := func(d int) *time.Time {
+		t := time.Date(2024, 3, d, 23, 59, 59, 0, time.UTC)
+		return &t
+	}
+
+	tests := []struct {
+		description string
+		policy      Policy
+		requested   *time.Time
+		expected    *time.Time
+		badRequest  bool
+	}{
+		{description: "no policy, no expiration", policy: Policy{}},
+		{description: "no policy keeps the requested expiration", policy: Policy{}, requested: day(25), expected: day(25)},
+		{description: "default applied", policy: Policy{DefaultDays: 7}, expected: day(8)},
+		{description: "default capped by the maximum", policy: Policy{DefaultDays: 30, MaxDays: 10}, expected: day(11)},
+		{description: "maximum applied when no default", policy: Policy{MaxDays: 10}, expected: day(11)},
+		{description: "requested within the maximum", policy: Policy{DefaultDays: 7, MaxDays: 10}, requested: day(5), expected: day(5)},
+		{description: "requested at the maximum", policy: Policy{MaxDays: 10}, requested: day(11), expected: day(11)},
+		{description: "requested beyond the maximum", policy: Policy{MaxDays: 10}, requested: day(12), badRequest: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			got, err := tt.policy.Apply(now, tt.requested)
+			if tt.badRequest {
+				if _, ok := err.(errtypes.BadRequest); !ok {
+					t.Fatalf("expected a bad request error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.expected == nil && got != nil:
+				t.Fatalf("expected no expiration, got %s", got)
+			case tt.expected != nil && (got == nil || !got.Equal(*tt.expected)):
+				t.Fatalf("expected expiration %s, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -22,8 +22,9 @@ const (
 
 type Config struct {
 	config.Database      `mapstructure:",squash"`
-	GatewaySvc           string `mapstructure:"gatewaysvc"`
-	LinkPasswordHashCost int    `mapstructure:"password_hash_cost"`
+	GatewaySvc           string           `mapstructure:"gatewaysvc"`
+	LinkPasswordHashCost int              `mapstructure:"password_hash_cost"`
+	Expiration           ExpirationConfig `mapstructure:"expiration"`
 }
 
 func init() {
@@ -40,6 +41,7 @@ func (c *Config) ApplyDefaults() {
 	if c.LinkPasswordHashCost < 11 {
 		c.LinkPasswordHashCost = 11
 	}
+	c.Expiration.ApplyDefaults()
 }
 
 func getDb(c Config) (*gorm.DB, error) {
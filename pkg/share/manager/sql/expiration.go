@@ -0,0 +1,295 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/notification"
+	"github.com/cs3org/reva/v3/pkg/notification/notificationhelper"
+	"github.com/cs3org/reva/v3/pkg/notification/trigger"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/v3/pkg/rjobs"
+	"github.com/cs3org/reva/v3/pkg/share/manager/sql/model"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+	"gorm.io/gorm"
+)
+
+const (
+	shareExpirationJob = "share.expiration"
+	linkExpirationJob  = "link.expiration"
+)
+
+// ExpirationConfig configures the periodic job removing the expired shares
+// and warning their initiators before they expire.
+type ExpirationConfig struct {
+	Enabled       bool           `docs:"false;Whether to periodically remove the expired shares."                                        mapstructure:"enabled"`
+	Schedule      string         `docs:"@hourly;Schedule of the expiration job."                                                         mapstructure:"schedule"`
+	WarnDays      int            `docs:"7;Number of days before the expiration the initiators of the shares are warned."                 mapstructure:"warn_days"`
+	WarnTemplate  string         `docs:"share-expiration-warning;Name of the notification template used for the warnings."              mapstructure:"warn_template"`
+	MachineSecret string         `docs:";Machine secret used to act on behalf of the initiators of the shares."                          mapstructure:"machine_secret"`
+	Notifications map[string]any `docs:";Notification helper configuration, used to send the warnings. Warnings are disabled if empty." mapstructure:"notifications"`
+}
+
+// ApplyDefaults applies the default values to the expiration configuration.
+func (c *ExpirationConfig) ApplyDefaults() {
+	if c.Schedule == "" {
+		c.Schedule = "@hourly"
+	}
+	if c.WarnDays == 0 {
+		c.WarnDays = 7
+	}
+	if c.WarnTemplate == "" {
+		c.WarnTemplate = "share-expiration-warning"
+	}
+}
+
+// expirer holds what the expiration jobs of the user shares and of the
+// public links have in common.
+type expirer struct {
+	c          *ExpirationConfig
+	gatewaySvc string
+	db         *gorm.DB
+	nh         *notificationhelper.NotificationHelper
+}
+
+func newExpirer(ctx context.Context, name string, c *Config, db *gorm.DB) *expirer {
+	e := &expirer{
+		c:          &c.Expiration,
+		gatewaySvc: c.GatewaySvc,
+		db:         db,
+	}
+	if len(c.Expiration.Notifications) > 0 {
+		nh, err := notificationhelper.New(name, c.Expiration.Notifications, appctx.GetLogger(ctx))
+		if err != nil {
+			appctx.GetLogger(ctx).Error().Err(err).Msg("sql: expiration warnings are disabled")
+		} else {
+			e.nh = nh
+		}
+	}
+	return e
+}
+
+func (e *expirer) register(name string, run func(ctx context.Context) error) error {
+	return rjobs.RegisterPeriodic(rjobs.Periodic{
+		Name:     name,
+		Schedule: e.c.Schedule,
+		Scope:    rjobs.ScopeLeader,
+		Run:      run,
+		Jitter:   time.Minute,
+	})
+}
+
+// impersonate returns a context authenticated as the given user, along with
+// the mail address of the user.
+func (e *expirer) impersonate(ctx context.Context, userID string) (context.Context, string, error) {
+	userCtx, u, err := impersonate(ctx, e.gatewaySvc, e.c.MachineSecret, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	return userCtx, u.Mail, nil
+}
+
+// warn notifies the initiator of a share about its upcoming expiration.
+func (e *expirer) warn(ctx context.Context, kind, id string, s *model.ProtoShare, recipient string) error {
+	_, mail, err := e.impersonate(ctx, s.UIDInitiator)
+	if err != nil {
+		return err
+	}
+	if mail == "" {
+		return errors.Errorf("user %s has no mail address", s.UIDInitiator)
+	}
+
+	ref := fmt.Sprintf("%s-expiration-%s", kind, id)
+	e.nh.TriggerNotification(&trigger.Trigger{
+		Notification: &notification.Notification{
+			TemplateName: e.c.WarnTemplate,
+			Ref:          ref,
+			Recipients:   []string{mail},
+			Users:        map[string]string{mail: s.UIDInitiator},
+		},
+		Ref: ref,
+		TemplateData: map[string]any{
+			"kind":       kind,
+			"path":       s.InitialPath,
+			"base":       filepath.Base(s.InitialPath),
+			"isFolder":   s.ItemType == model.ItemTypeFolder,
+			"recipient":  recipient,
+			"expiration": s.Expiration.V.Format(time.DateOnly),
+		},
+	})
+	return nil
+}
+
+func (e *expirer) warnWindow(now time.Time) time.Time {
+	return now.AddDate(0, 0, e.c.WarnDays)
+}
+
+func (m *ShareMgr) registerExpirationJob(ctx context.Context) error {
+	m.expirer = newExpirer(ctx, shareExpirationJob, m.c, m.db)
+	return m.expirer.register(shareExpirationJob, m.expireShares)
+}
+
+// expireShares removes the expired user shares and warns the initiators of
+// the shares that are about to expire. The shares are removed through the
+// gateway on behalf of their initiators, so that their storage grants are
+// removed and the grants of the enclosing and nested shares are reapplied.
+func (m *ShareMgr) expireShares(ctx context.Context) error {
+	log := appctx.GetLogger(ctx)
+	now := time.Now()
+
+	gw, err := pool.GetGatewayServiceClient(pool.Endpoint(m.c.GatewaySvc))
+	if err != nil {
+		return err
+	}
+
+	var expired []model.Share
+	if res := m.db.Where("expiration IS NOT NULL AND expiration <= ?", now).Find(&expired); res.Error != nil {
+		return res.Error
+	}
+	var failed int
+	for _, s := range expired {
+		id := strconv.Itoa(int(s.Id))
+		userCtx, _, err := m.expirer.impersonate(ctx, s.UIDInitiator)
+		if err != nil {
+			log.Error().Err(err).Str("share", id).Msg("sql: cannot impersonate the initiator of the expired share")
+			failed++
+			continue
+		}
+		res, err := gw.RemoveShare(userCtx, &collaboration.RemoveShareRequest{
+			Ref: &collaboration.ShareReference{
+				Spec: &collaboration.ShareReference_Id{Id: &collaboration.ShareId{OpaqueId: id}},
+			},
+		})
+		switch {
+		case err != nil:
+			log.Error().Err(err).Str("share", id).Msg("sql: error removing expired share")
+			failed++
+		case res.Status.Code != rpc.Code_CODE_OK:
+			log.Error().Str("share", id).Str("status", res.Status.Code.String()).Msg("sql: error removing expired share: " + res.Status.Message)
+			failed++
+		default:
+			log.Info().Str("share", id).Msg("sql: removed expired share")
+		}
+	}
+
+	if m.expirer.nh == nil {
+		return expireErr(failed, len(expired))
+	}
+	var expiring []model.Share
+	if res := m.db.Where("expiration > ? AND expiration <= ? AND expiry_notified = ?", now, m.expirer.warnWindow(now), false).Find(&expiring); res.Error != nil {
+		return res.Error
+	}
+	for _, s := range expiring {
+		id := strconv.Itoa(int(s.Id))
+		if err := m.expirer.warn(ctx, "share", id, &s.ProtoShare, s.ShareWith); err != nil {
+			log.Error().Err(err).Str("share", id).Msg("sql: error warning about expiring share")
+			continue
+		}
+		if res := m.db.Model(&model.Share{}).Where("id = ?", s.Id).Update("expiry_notified", true); res.Error != nil {
+			return res.Error
+		}
+	}
+
+	return expireErr(failed, len(expired))
+}
+
+func expireErr(failed, total int) error {
+	if failed > 0 {
+		return errors.Errorf("sql: %d of %d expired shares could not be removed", failed, total)
+	}
+	return nil
+}
+
+func (m *PublicShareMgr) registerExpirationJob(ctx context.Context) error {
+	m.expirer = newExpirer(ctx, linkExpirationJob, m.c, m.db)
+	return m.expirer.register(linkExpirationJob, m.expireLinks)
+}
+
+// expireLinks removes the expired public links and warns the initiators of
+// the links that are about to expire. Public links have no storage grants,
+// so they are removed straight from the database.
+func (m *PublicShareMgr) expireLinks(ctx context.Context) error {
+	log := appctx.GetLogger(ctx)
+	now := time.Now()
+
+	res := m.db.Where("expiration IS NOT NULL AND expiration <= ?", now).Delete(&model.PublicLink{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		log.Info().Int64("count", res.RowsAffected).Msg("sql: removed expired public links")
+	}
+
+	if m.expirer.nh == nil {
+		return nil
+	}
+	var expiring []model.PublicLink
+	if res := m.db.Where("expiration > ? AND expiration <= ? AND expiry_notified = ?", now, m.expirer.warnWindow(now), false).Find(&expiring); res.Error != nil {
+		return res.Error
+	}
+	for _, l := range expiring {
+		id := strconv.Itoa(int(l.Id))
+		if err := m.expirer.warn(ctx, "link", id, &l.ProtoShare, l.LinkName); err != nil {
+			log.Error().Err(err).Str("link", id).Msg("sql: error warning about expiring public link")
+			continue
+		}
+		if res := m.db.Model(&model.PublicLink{}).Where("id = ?", l.Id).Update("expiry_notified", true); res.Error != nil {
+			return res.Error
+		}
+	}
+	return nil
+}
+
+// impersonate returns a context authenticated as the given user through the
+// machine authentication, to act on behalf of the user in background jobs.
+func impersonate(ctx context.Context, gatewaySvc, machineSecret, userID string) (context.Context, *userpb.User, error) {
+	gw, err := pool.GetGatewayServiceClient(pool.Endpoint(gatewaySvc))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authRes, err := gw.Authenticate(ctx, &gateway.AuthenticateRequest{
+		Type:         "machine",
+		ClientId:     userID,
+		ClientSecret: machineSecret,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if authRes.Status.Code != rpc.Code_CODE_OK {
+		return nil, nil, errors.New(authRes.Status.Message)
+	}
+
+	userCtx := appctx.ContextSetToken(context.Background(), authRes.Token)
+	userCtx = metadata.AppendToOutgoingContext(userCtx, appctx.TokenHeader, authRes.Token)
+	userCtx = appctx.ContextSetUser(userCtx, authRes.User)
+	userCtx = appctx.WithLogger(userCtx, appctx.GetLogger(ctx))
+	return userCtx, authRes.User, nil
+}
@@ -20,6 +20,7 @@ package model
 
 import (
 	"strconv"
+	"strings"
 	"time"
 
 	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
@@ -29,6 +30,7 @@ import (
 	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	conversions "github.com/cs3org/reva/v3/pkg/cbox/utils"
 	"github.com/cs3org/reva/v3/pkg/permissions"
+	"github.com/cs3org/reva/v3/pkg/publicshare"
 
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
@@ -135,6 +137,9 @@ type ProtoShare struct {
 	Orphan       bool               `gorm:"index"`
 	Expiration   datatypes.NullTime `gorm:"index"`
 	SpaceID      string             `gorm:"index"`
+	// ExpiryNotified records whether the initiator was warned about the
+	// upcoming expiration. It is reset when the expiration is updated.
+	ExpiryNotified bool
 }
 
 // Share is a regular share between users or groups. The unique index ensures that there
@@ -166,6 +171,28 @@ type PublicLink struct {
 	NotifyUploadsExtraRecipients string
 	Password                     string `gorm:"size:255"`
 	LinkName                     string `gorm:"size:512"` // Users can give a name to a share
+	// Download limit and client restrictions, see publicshare.Restrictions
+	MaxDownloads        int
+	Downloads           int
+	RevokeWhenExhausted bool
+	AllowedNetworks     string `gorm:"size:1024"` // comma separated list of CIDRs
+	// File-drop settings and quotas, see publicshare.FileDrop
+	FileDrop           bool
+	FileDropSubfolders bool
+	MaxFileSize        uint64
+	MaxUploads         int
+	MaxUploadBytes     uint64
+	Uploads            int
+	UploadedBytes      uint64
+}
+
+// PublicLinkDownload records a transfer counted against the download limit of a
+// link, so that it is counted once whichever replica serves it. It is kept until
+// the transfer token expires.
+type PublicLinkDownload struct {
+	LinkID     uint      `gorm:"primaryKey;autoIncrement:false"`
+	TransferID string    `gorm:"primaryKey;size:255"`
+	ExpiresAt  time.Time `gorm:"index"`
 }
 
 // ShareState represents the state of a share for a specific recipient.
@@ -339,7 +366,46 @@ func (p *PublicLink) AsCS3PublicShare() *link.PublicShare {
 		Quicklink:                    p.Quicklink,
 		NotifyUploads:                p.NotifyUploads,
 		NotifyUploadsExtraRecipients: p.NotifyUploadsExtraRecipients,
+		Opaque:                       p.opaque(),
+	}
+}
+
+// Restrictions returns the download limit and client restrictions of the link.
+func (p *PublicLink) Restrictions() *publicshare.Restrictions {
+	r := &publicshare.Restrictions{
+		MaxDownloads:        p.MaxDownloads,
+		Downloads:           p.Downloads,
+		RevokeWhenExhausted: p.RevokeWhenExhausted,
+	}
+	if p.AllowedNetworks != "" {
+		r.AllowedNetworks = strings.Split(p.AllowedNetworks, ",")
+	}
+	return r
+}
+
+// FileDropSettings returns the file-drop settings and quotas of the link.
+func (p *PublicLink) FileDropSettings() *publicshare.FileDrop {
+	return &publicshare.FileDrop{
+		Enabled:             p.FileDrop,
+		SubfolderPerSession: p.FileDropSubfolders,
+		MaxFileSize:         p.MaxFileSize,
+		MaxUploads:          p.MaxUploads,
+		MaxBytes:            p.MaxUploadBytes,
+		Uploads:             p.Uploads,
+		UploadedBytes:       p.UploadedBytes,
+	}
+}
+
+// opaque carries the restrictions and file-drop settings of the link, when set.
+func (p *PublicLink) opaque() *types.Opaque {
+	var o *types.Opaque
+	if r := p.Restrictions(); !r.IsZero() || r.Downloads != 0 {
+		o, _ = publicshare.SetRestrictions(o, r)
+	}
+	if fd := p.FileDropSettings(); fd.IsEnabled() || fd.Uploads != 0 {
+		o, _ = publicshare.SetFileDrop(o, fd)
 	}
+	return o
 }
 
 func defaultLinkDisplayName(displayName string, quickLink bool) string {
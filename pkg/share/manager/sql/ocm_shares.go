@@ -28,6 +28,7 @@ import (
 
 	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/v3/pkg/appctx"
 	"github.com/cs3org/reva/v3/pkg/errtypes"
@@ -481,6 +482,69 @@ func (m *mgr) UpdateReceivedShare(ctx context.Context, user *userpb.User, s *ocm
 	return updatedShare, nil
 }
 
+func (m *mgr) ListReceivedSharesByRemoteID(ctx context.Context, remoteShareID string) ([]*ocm.ReceivedShare, error) {
+	var receivedShareModels []model.OcmReceivedShare
+	if err := m.db.WithContext(ctx).Where("remote_share_id = ?", remoteShareID).Find(&receivedShareModels).Error; err != nil {
+		return nil, err
+	}
+	shares := []*ocm.ReceivedShare{}
+	var ids []any
+	for _, s := range receivedShareModels {
+		shares = append(shares, convertToCS3OCMReceivedShare(&s, nil))
+		ids = append(ids, s.ID)
+	}
+	p, err := m.getProtocolsByIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, share := range shares {
+		if protocols, ok := p[share.Id.OpaqueId]; ok {
+			share.Protocols = protocols
+		}
+	}
+	return shares, nil
+}
+
+func (m *mgr) DeleteReceivedShare(ctx context.Context, id *ocm.ShareId) error {
+	shareID, err := strconv.Atoi(id.OpaqueId)
+	if err != nil {
+		return errtypes.BadRequest("invalid share ID")
+	}
+
+	result := m.db.WithContext(ctx).
+		Where("id = ?", shareID).
+		Delete(&model.OcmReceivedShare{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return share.ErrShareNotFound
+	}
+	return nil
+}
+
+func (m *mgr) UpdateReceivedSharePermissions(ctx context.Context, id *ocm.ShareId, perms *ocm.SharePermissions) error {
+	shareID, err := strconv.Atoi(id.OpaqueId)
+	if err != nil {
+		return errtypes.BadRequest("invalid share ID")
+	}
+
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.OcmReceivedShareProtocol{}).
+			Where("ocm_received_share_id = ? AND type = ?", shareID, model.WebDAVProtocol).
+			Update("permissions", int(permissions.OCSFromCS3Permission(perms.GetPermissions())))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return share.ErrShareNotFound
+		}
+		return tx.Model(&model.OcmReceivedShare{}).
+			Where("id = ?", shareID).
+			Update("mtime", time.Now().Unix()).Error
+	})
+}
+
 func (m *mgr) translateUpdateFieldMask(share *ocm.ReceivedShare, fieldMask *field_mask.FieldMask) (map[string]any, *ocm.ReceivedShare, error) {
 	updates := make(map[string]any)
 	newShare := proto.Clone(share).(*ocm.ReceivedShare)
@@ -639,6 +703,47 @@ func (m *mgr) deleteByKey(ctx context.Context, user *userpb.User, key *ocm.Share
 	return nil
 }
 
+// TransferShare transfers an OCM share to a new initiator.
+func (m *mgr) TransferShare(ctx context.Context, id *ocm.ShareId, newInitiator string) error {
+	if newInitiator == "" {
+		return errors.New("Must pass a non-nil initiator")
+	}
+
+	res := m.db.WithContext(ctx).Model(&model.OcmShare{}).
+		Where("id = ?", id.OpaqueId).
+		Update("initiator", newInitiator)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return share.ErrShareNotFound
+	}
+	return nil
+}
+
+// MoveShare moves an OCM share to a new location, also updating its owner. It is the responsibility of the caller to ensure that `newOwner`
+// corresponds to the owner of `newLocation`
+func (m *mgr) MoveShare(ctx context.Context, id *ocm.ShareId, newLocation *provider.ResourceId, newOwner string) error {
+	if newOwner == "" {
+		return errors.New("Must pass a non-nil owner")
+	}
+
+	if newLocation.OpaqueId == "" || newLocation.StorageId == "" {
+		return errors.New("Must pass a non-nil location")
+	}
+
+	res := m.db.WithContext(ctx).Model(&model.OcmShare{}).
+		Where("id = ?", id.OpaqueId).
+		Updates(map[string]any{"owner": newOwner, "inode": newLocation.OpaqueId, "instance": newLocation.StorageId})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return share.ErrShareNotFound
+	}
+	return nil
+}
+
 func (m *mgr) queriesUpdatesOnShare(ctx context.Context, id *ocm.ShareId, f ...*ocm.UpdateOCMShareRequest_UpdateField) (map[string]any, []func(*gorm.DB) error, error) {
 	var updates map[string]any
 	var accessMethodUpdates []func(*gorm.DB) error
@@ -915,3 +915,52 @@ func TestUpdateReceivedOCMShareHidden(t *testing.T) {
 		t.Fatal("expected share to not be hidden after unhide")
 	}
 }
+
+func TestReceivedShareNotifications(t *testing.T) {
+	mgr, err, teardown := setupSuiteOcmShares(t)
+	defer teardown(t)
+
+	userctx := getUserContext("sharee1")
+	user, _ := appctx.ContextGetUser(userctx)
+	grantee := getUserOcmShareGrantee("sharee1")
+
+	stored, err := mgr.StoreReceivedShare(userctx, getOCMReceivedShare(user, grantee, "file", "viewer", "remote-notif-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shares, err := mgr.ListReceivedSharesByRemoteID(userctx, "remote-notif-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 1 || shares[0].Id.OpaqueId != stored.Id.OpaqueId {
+		t.Fatalf("ListReceivedSharesByRemoteID: got %v, want share %s", shares, stored.Id.OpaqueId)
+	}
+	if !share.HasSharedSecret(shares[0], "sharedsecret") {
+		t.Fatal("expected the protocols of the received share to be returned")
+	}
+
+	perms := &ocm.SharePermissions{Permissions: permissions.NewEditorRole().CS3ResourcePermissions()}
+	if err := mgr.UpdateReceivedSharePermissions(userctx, stored.Id, perms); err != nil {
+		t.Fatal(err)
+	}
+	ref := &ocm.ShareReference{Spec: &ocm.ShareReference_Id{Id: stored.Id}}
+	retrieved, err := mgr.GetReceivedShare(userctx, user, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wdav := findWebDAVProtocol(retrieved.Protocols)
+	if wdav == nil || !wdav.Permissions.Permissions.InitiateFileUpload {
+		t.Fatalf("expected editor permissions after the update, got %v", wdav)
+	}
+
+	if err := mgr.DeleteReceivedShare(userctx, stored.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.GetReceivedShare(userctx, user, ref); err == nil {
+		t.Fatal("expected the received share to be deleted")
+	}
+	if err := mgr.DeleteReceivedShare(userctx, stored.Id); err != share.ErrShareNotFound {
+		t.Fatalf("DeleteReceivedShare on a deleted share: got %v, want %v", err, share.ErrShareNotFound)
+	}
+}
@@ -40,14 +40,16 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	// Provides mysql drivers.
 	_ "github.com/go-sql-driver/mysql"
 )
 
 type PublicShareMgr struct {
-	c  *Config
-	db *gorm.DB
+	c       *Config
+	db      *gorm.DB
+	expirer *expirer
 }
 
 type ExpiryRange struct {
@@ -68,7 +70,7 @@ func NewPublicShareManager(ctx context.Context, m map[string]any) (publicshare.M
 	}
 
 	// Migrate schemas
-	err = db.AutoMigrate(&model.PublicLink{})
+	err = db.AutoMigrate(&model.PublicLink{}, &model.PublicLinkDownload{})
 	if err != nil {
 		return nil, err
 	}
@@ -77,6 +79,11 @@ func NewPublicShareManager(ctx context.Context, m map[string]any) (publicshare.M
 		c:  &c,
 		db: db,
 	}
+	if c.Expiration.Enabled {
+		if err := mgr.registerExpirationJob(ctx); err != nil {
+			return nil, errors.Wrap(err, "sql: error registering the expiration job")
+		}
+	}
 	return mgr, nil
 }
 
@@ -166,11 +173,11 @@ func (m *PublicShareMgr) UpdatePublicShare(ctx context.Context, u *user.User, re
 		if req.Update.GetGrant().Expiration == nil {
 			res = m.db.Model(&publiclink).
 				Where("id = ?", publiclink.Id).
-				Update("expiration", nil)
+				Updates(map[string]any{"expiration": nil, "expiry_notified": false})
 		} else {
 			res = m.db.Model(&publiclink).
 				Where("id = ?", publiclink.Id).
-				Update("expiration", time.Unix(int64(req.Update.GetGrant().Expiration.Seconds), 0))
+				Updates(map[string]any{"expiration": time.Unix(int64(req.Update.GetGrant().Expiration.Seconds), 0), "expiry_notified": false})
 		}
 
 	case link.UpdatePublicShareRequest_Update_TYPE_PASSWORD:
@@ -211,6 +218,146 @@ func (m *PublicShareMgr) UpdatePublicShare(ctx context.Context, u *user.User, re
 	return m.GetPublicShare(ctx, u, req.Ref, true)
 }
 
+// SetRestrictions replaces the download limit and client restrictions of a link, keeping its download counter.
+func (m *PublicShareMgr) SetRestrictions(ctx context.Context, u *user.User, ref *link.PublicShareReference, r *publicshare.Restrictions) (*link.PublicShare, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	publiclink, err := m.getEmptyPublicLink(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	res := m.db.Model(&publiclink).
+		Where("id = ?", publiclink.Id).
+		Updates(map[string]any{
+			"max_downloads":         r.MaxDownloads,
+			"revoke_when_exhausted": r.RevokeWhenExhausted,
+			"allowed_networks":      strings.Join(r.AllowedNetworks, ","),
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return m.GetPublicShare(ctx, u, ref, false)
+}
+
+// CountDownload records a download through a link. The transfer is recorded
+// together with the counter, in one transaction, and the counter is incremented
+// in a single statement conditioned on the limit, so that concurrent downloads
+// cannot exceed it and a transfer is counted once across the replicas.
+func (m *PublicShareMgr) CountDownload(ctx context.Context, ref *link.PublicShareReference, transferID string, expires time.Time) (*link.PublicShare, error) {
+	publiclink, err := m.getEmptyPublicLink(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	counted, limited := true, false
+	err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.PublicLinkDownload{
+			LinkID:     publiclink.Id,
+			TransferID: transferID,
+			ExpiresAt:  expires,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// the transfer was already counted
+			counted = false
+			return nil
+		}
+
+		res = tx.Model(&model.PublicLink{}).
+			Where("id = ? AND (max_downloads = 0 OR downloads < max_downloads)", publiclink.Id).
+			Update("downloads", gorm.Expr("downloads + 1"))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// roll back the record of the transfer
+			limited = true
+			return errtypes.PermissionDenied("download limit reached")
+		}
+		return tx.Where("expires_at < ?", time.Now()).Delete(&model.PublicLinkDownload{}).Error
+	})
+	if err != nil && !limited {
+		return nil, err
+	}
+
+	ln, err := m.getLinkByID(ctx, &link.PublicShareId{OpaqueId: strconv.Itoa(int(publiclink.Id))}, false)
+	if err != nil {
+		return nil, err
+	}
+	if limited {
+		return nil, errtypes.PermissionDenied("download limit reached for link " + ln.Token)
+	}
+
+	if counted && ln.RevokeWhenExhausted && ln.Restrictions().Exhausted() {
+		if err := m.db.WithContext(ctx).Where("id = ?", ln.Id).Delete(&model.PublicLink{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return ln.AsCS3PublicShare(), nil
+}
+
+// SetFileDrop replaces the file-drop settings of a link, keeping its upload counters.
+func (m *PublicShareMgr) SetFileDrop(ctx context.Context, u *user.User, ref *link.PublicShareReference, fd *publicshare.FileDrop) (*link.PublicShare, error) {
+	if err := fd.Validate(); err != nil {
+		return nil, err
+	}
+	publiclink, err := m.getEmptyPublicLink(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	res := m.db.Model(&publiclink).
+		Where("id = ?", publiclink.Id).
+		Updates(map[string]any{
+			"file_drop":            fd.Enabled,
+			"file_drop_subfolders": fd.SubfolderPerSession,
+			"max_file_size":        fd.MaxFileSize,
+			"max_uploads":          fd.MaxUploads,
+			"max_upload_bytes":     fd.MaxBytes,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return m.GetPublicShare(ctx, u, ref, false)
+}
+
+// CountUpload records an upload through a file-drop link. As for the downloads,
+// the counters are incremented in a single statement conditioned on the quotas.
+func (m *PublicShareMgr) CountUpload(ctx context.Context, ref *link.PublicShareReference, size uint64) (*link.PublicShare, error) {
+	publiclink, err := m.getEmptyPublicLink(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	res := m.db.WithContext(ctx).Model(&model.PublicLink{}).
+		Where("id = ? AND (max_file_size = 0 OR max_file_size >= ?)", publiclink.Id, size).
+		Where("max_uploads = 0 OR uploads < max_uploads").
+		Where("max_upload_bytes = 0 OR uploaded_bytes + ? <= max_upload_bytes", size).
+		Updates(map[string]any{
+			"uploads":        gorm.Expr("uploads + 1"),
+			"uploaded_bytes": gorm.Expr("uploaded_bytes + ?", size),
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	ln, err := m.getLinkByID(ctx, &link.PublicShareId{OpaqueId: strconv.Itoa(int(publiclink.Id))}, false)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		if err := ln.FileDropSettings().Allows(size); err != nil {
+			return nil, err
+		}
+		return nil, errtypes.InsufficientStorage("upload quota reached for link " + ln.Token)
+	}
+	return ln.AsCS3PublicShare(), nil
+}
+
 func (m *PublicShareMgr) MarkAsOrphaned(ctx context.Context, ref *link.PublicShareReference) error {
 	publicLink, err := m.getEmptyPublicLink(ctx, ref)
 	if err != nil {
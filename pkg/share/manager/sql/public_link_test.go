@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"os"
+	"strconv"
 	"testing"
 	"time"
 
@@ -325,3 +326,177 @@ func TestListPublicSharesWithFilters(t *testing.T) {
 		t.Errorf("Expected share ID %s, got %s", share.Id.OpaqueId, shares[0].Id.OpaqueId)
 	}
 }
+
+func TestPublicShareDownloadLimit(t *testing.T) {
+	mgr, err, teardown := setupSuiteLinks(t)
+	defer teardown(t)
+
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	userctx := getUserContext("123456")
+	user, _ := appctx.ContextGetUser(userctx)
+	file := getRandomFile(user)
+
+	share, err := mgr.CreatePublicShare(userctx, nil, file, getTestPublicLinkGrant(""), "test description", false, false, "")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	ref := &link.PublicShareReference{Spec: &link.PublicShareReference_Token{Token: share.Token}}
+
+	rm := mgr.(publicshare.RestrictionsManager)
+	if _, err := rm.SetRestrictions(userctx, nil, ref, &publicshare.Restrictions{AllowedNetworks: []string{"not-a-network"}}); err == nil {
+		t.Error("Expected an invalid network to be refused")
+	}
+
+	updated, err := rm.SetRestrictions(userctx, nil, ref, &publicshare.Restrictions{
+		MaxDownloads:        2,
+		RevokeWhenExhausted: true,
+		AllowedNetworks:     []string{"10.0.0.0/8", "192.168.1.1"},
+	})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	r, err := publicshare.GetRestrictions(updated.Opaque)
+	if err != nil || r == nil {
+		t.Fatalf("Expected restrictions in the share, got %v (%v)", r, err)
+	}
+	if r.MaxDownloads != 2 || len(r.AllowedNetworks) != 2 {
+		t.Errorf("Unexpected restrictions %+v", r)
+	}
+
+	expires := time.Now().Add(time.Minute)
+	for i := 1; i <= 2; i++ {
+		counted, err := rm.CountDownload(userctx, ref, "transfer-"+strconv.Itoa(i), expires)
+		if err != nil {
+			t.Fatalf("Download %d refused: %v", i, err)
+		}
+		if r, _ := publicshare.GetRestrictions(counted.Opaque); r.Downloads != i {
+			t.Errorf("Expected %d downloads, got %d", i, r.Downloads)
+		}
+	}
+
+	// the link was revoked with the last allowed download
+	if _, err := mgr.GetPublicShareByToken(userctx, share.Token, nil, false); err == nil {
+		t.Error("Expected the exhausted link to be revoked")
+	}
+}
+
+func TestPublicShareDownloadLimitWithoutRevocation(t *testing.T) {
+	mgr, err, teardown := setupSuiteLinks(t)
+	defer teardown(t)
+
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	userctx := getUserContext("123456")
+	user, _ := appctx.ContextGetUser(userctx)
+	file := getRandomFile(user)
+
+	share, err := mgr.CreatePublicShare(userctx, nil, file, getTestPublicLinkGrant(""), "test description", false, false, "")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	ref := &link.PublicShareReference{Spec: &link.PublicShareReference_Id{Id: share.Id}}
+
+	rm := mgr.(publicshare.RestrictionsManager)
+	if _, err := rm.SetRestrictions(userctx, nil, ref, &publicshare.Restrictions{MaxDownloads: 1}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expires := time.Now().Add(time.Minute)
+	if _, err := rm.CountDownload(userctx, ref, "first", expires); err != nil {
+		t.Fatalf("First download refused: %v", err)
+	}
+
+	// the same transfer, resumed through another replica, is not counted again
+	replica, err := NewPublicShareManager(context.Background(), map[string]any{
+		"db_engine": "sqlite",
+		"db_name":   "test_db.sqlite",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []publicshare.RestrictionsManager{rm, replica.(publicshare.RestrictionsManager)} {
+		counted, err := m.CountDownload(userctx, ref, "first", expires)
+		if err != nil {
+			t.Fatalf("Resumed download refused: %v", err)
+		}
+		if r, _ := publicshare.GetRestrictions(counted.Opaque); r.Downloads != 1 {
+			t.Errorf("Expected the transfer to be counted once, got %d downloads", r.Downloads)
+		}
+	}
+
+	if _, err := rm.CountDownload(userctx, ref, "second", expires); err == nil {
+		t.Error("Expected the second download to be refused")
+	}
+	if _, err := mgr.GetPublicShareByToken(userctx, share.Token, nil, false); err != nil {
+		t.Errorf("Expected the link to be kept, got %v", err)
+	}
+}
+
+func TestPublicShareFileDropQuotas(t *testing.T) {
+	mgr, err, teardown := setupSuiteLinks(t)
+	defer teardown(t)
+
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	userctx := getUserContext("123456")
+	user, _ := appctx.ContextGetUser(userctx)
+	file := getRandomFile(user)
+
+	share, err := mgr.CreatePublicShare(userctx, nil, file, getTestPublicLinkGrant(""), "test description", false, false, "")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	ref := &link.PublicShareReference{Spec: &link.PublicShareReference_Token{Token: share.Token}}
+
+	fm := mgr.(publicshare.FileDropManager)
+	updated, err := fm.SetFileDrop(userctx, nil, ref, &publicshare.FileDrop{
+		Enabled:     true,
+		MaxFileSize: 100,
+		MaxUploads:  3,
+		MaxBytes:    150,
+	})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if fd, _ := publicshare.GetFileDrop(updated.Opaque); !fd.IsEnabled() || fd.MaxUploads != 3 {
+		t.Errorf("Unexpected file drop settings %+v", fd)
+	}
+
+	if _, err := fm.CountUpload(userctx, ref, 120); err == nil {
+		t.Error("Expected an upload larger than the maximum file size to be refused")
+	}
+	if _, err := fm.CountUpload(userctx, ref, 100); err != nil {
+		t.Fatalf("Upload refused: %v", err)
+	}
+	if _, err := fm.CountUpload(userctx, ref, 60); err == nil {
+		t.Error("Expected an upload exceeding the total size to be refused")
+	}
+	if _, err := fm.CountUpload(userctx, ref, 50); err != nil {
+		t.Fatalf("Upload refused: %v", err)
+	}
+	counted, err := fm.CountUpload(userctx, ref, 0)
+	if err != nil {
+		t.Fatalf("Upload refused: %v", err)
+	}
+	if fd, _ := publicshare.GetFileDrop(counted.Opaque); fd.Uploads != 3 || fd.UploadedBytes != 150 {
+		t.Errorf("Unexpected counters %+v", fd)
+	}
+	if _, err := fm.CountUpload(userctx, ref, 0); err == nil {
+		t.Error("Expected the upload past the maximum number of uploads to be refused")
+	}
+}
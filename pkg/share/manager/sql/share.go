@@ -52,8 +52,9 @@ import (
 )
 
 type ShareMgr struct {
-	c  *Config
-	db *gorm.DB
+	c       *Config
+	db      *gorm.DB
+	expirer *expirer
 }
 
 func NewShareManager(ctx context.Context, m map[string]any) (revashare.Manager, error) {
@@ -78,6 +79,11 @@ func NewShareManager(ctx context.Context, m map[string]any) (revashare.Manager,
 		c:  &c,
 		db: db,
 	}
+	if c.Expiration.Enabled {
+		if err := mgr.registerExpirationJob(ctx); err != nil {
+			return nil, errors.Wrap(err, "sql: error registering the expiration job")
+		}
+	}
 	return mgr, nil
 }
 
@@ -189,12 +195,12 @@ func (m *ShareMgr) UpdateShare(ctx context.Context, ref *collaboration.ShareRefe
 	case *collaboration.UpdateShareRequest_UpdateField_Expiration:
 		expiration := req.Field.GetExpiration()
 		if expiration == nil {
-			res := m.db.Model(&share).Where("id = ?", share.Id).Update("expiration", nil)
+			res := m.db.Model(&share).Where("id = ?", share.Id).Updates(map[string]any{"expiration": nil, "expiry_notified": false})
 			if res.Error != nil {
 				return nil, res.Error
 			}
 		} else {
-			res := m.db.Model(&share).Where("id = ?", share.Id).Update("expiration", time.Unix(int64(expiration.Seconds), 0))
+			res := m.db.Model(&share).Where("id = ?", share.Id).Updates(map[string]any{"expiration": time.Unix(int64(expiration.Seconds), 0), "expiry_notified": false})
 			if res.Error != nil {
 				return nil, res.Error
 			}
@@ -0,0 +1,456 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	registry "github.com/cs3org/go-cs3apis/cs3/storage/registry/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/permissions"
+	projectssql "github.com/cs3org/reva/v3/pkg/projects/manager/sql"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/v3/pkg/rjobs"
+	"github.com/cs3org/reva/v3/pkg/share/manager/sql/model"
+	"github.com/cs3org/reva/v3/pkg/sharedconf"
+	"github.com/cs3org/reva/v3/pkg/storage/utils/grants"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+// OwnershipTransferJob is the name of the on-demand job handing the shares,
+// public links, OCM shares and projects of a user over to a successor,
+// typically when the user leaves.
+const OwnershipTransferJob = "share.ownership-transfer"
+
+func init() {
+	if err := rjobs.RegisterOnDemand(OwnershipTransferJob, NewOwnershipTransferJob); err != nil {
+		panic(err)
+	}
+}
+
+type transferConfig struct {
+	Config `mapstructure:",squash"`
+	// MachineSecret is used to resolve the resources on behalf of the users.
+	MachineSecret string `mapstructure:"machine_secret"`
+	// StorageRegistrySvc is used to find the storage providers the grants
+	// of the shares are re-added to.
+	StorageRegistrySvc string `mapstructure:"storage_registry_svc"`
+	// Projects is the configuration of the sql projects catalogue, whose
+	// projects owned by the user are transferred as well. Optional.
+	Projects map[string]any `mapstructure:"projects"`
+}
+
+func (c *transferConfig) ApplyDefaults() {
+	c.Config.ApplyDefaults()
+	if c.StorageRegistrySvc == "" {
+		c.StorageRegistrySvc = sharedconf.GetGatewaySVC("")
+	}
+}
+
+// transferParams are the parameters of an ownership transfer run.
+type transferParams struct {
+	// User is the id of the user whose shares are transferred. Required.
+	User string `mapstructure:"user"`
+	// Successor is the id of the user the shares are transferred to. Required.
+	Successor string `mapstructure:"successor"`
+	// SourcePath and TargetPath map the data of the user, once moved by the
+	// administrators, to its new location in the storage of the successor.
+	// The shares of the resources owned by the user are re-pointed to the
+	// resources found at the mapped paths. Without them, only the shares of
+	// resources owned by somebody else, e.g. a project, can be transferred.
+	SourcePath string `mapstructure:"source_path"`
+	TargetPath string `mapstructure:"target_path"`
+	// DryRun reports what would be transferred without changing anything.
+	DryRun bool `mapstructure:"dry_run"`
+}
+
+// TransferFailure describes an item that could not be transferred.
+type TransferFailure struct {
+	Kind   string `json:"kind"   mapstructure:"kind"`
+	ID     string `json:"id"     mapstructure:"id"`
+	Path   string `json:"path"   mapstructure:"path"`
+	Reason string `json:"reason" mapstructure:"reason"`
+}
+
+// TransferReport is the result of an ownership transfer run.
+type TransferReport struct {
+	DryRun    bool              `json:"dry_run"    mapstructure:"dry_run"`
+	Shares    int               `json:"shares"     mapstructure:"shares"`
+	Links     int               `json:"links"      mapstructure:"links"`
+	OCMShares int               `json:"ocm_shares" mapstructure:"ocm_shares"`
+	Projects  int               `json:"projects"   mapstructure:"projects"`
+	Failures  []TransferFailure `json:"failures"   mapstructure:"failures"`
+}
+
+func (r *TransferReport) fail(kind, id, path string, err error) {
+	r.Failures = append(r.Failures, TransferFailure{Kind: kind, ID: id, Path: path, Reason: err.Error()})
+}
+
+type ownershipTransferJob struct {
+	c        *transferConfig
+	shares   *ShareMgr
+	links    *PublicShareMgr
+	ocm      *mgr
+	projects *projectssql.ProjectsManager
+}
+
+// NewOwnershipTransferJob returns the ownership transfer job, configured with
+// the database of the sql share managers.
+func NewOwnershipTransferJob(ctx context.Context, m map[string]any) (rjobs.Job, error) {
+	var c transferConfig
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	db, err := getDb(c.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &ownershipTransferJob{
+		c:      &c,
+		shares: &ShareMgr{c: &c.Config, db: db},
+		links:  &PublicShareMgr{c: &c.Config, db: db},
+		ocm:    &mgr{c: &c.Config, db: db},
+	}
+	if len(c.Projects) > 0 {
+		catalogue, err := projectssql.Open(c.Projects)
+		if err != nil {
+			return nil, errors.Wrap(err, "sql: error opening the projects catalogue")
+		}
+		j.projects = catalogue
+	}
+	return j, nil
+}
+
+// Run transfers everything the user initiated or owns to the successor. Items
+// that cannot be transferred are listed in the report rather than failing the
+// run, as a new attempt would not fare any better; the run is idempotent, so
+// it can be enqueued again once the causes are fixed.
+func (j *ownershipTransferJob) Run(ctx context.Context, p rjobs.Params) (rjobs.Params, error) {
+	var params transferParams
+	if err := mapstructure.Decode(map[string]any(p), &params); err != nil {
+		return nil, errors.Wrap(err, "sql: decoding params failed")
+	}
+	if params.User == "" || params.Successor == "" {
+		return nil, errors.New("sql: missing 'user' or 'successor' parameter")
+	}
+	if (params.SourcePath == "") != (params.TargetPath == "") {
+		return nil, errors.New("sql: 'source_path' and 'target_path' must be given together")
+	}
+
+	t := &transfer{job: j, params: &params, report: &TransferReport{DryRun: params.DryRun}}
+	for _, step := range []func(context.Context) error{t.shares, t.links, t.ocmShares, t.projects} {
+		if err := step(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	appctx.GetLogger(ctx).Info().
+		Str("user", params.User).
+		Str("successor", params.Successor).
+		Bool("dry_run", params.DryRun).
+		Int("failures", len(t.report.Failures)).
+		Msg("sql: ownership transfer done")
+
+	var res rjobs.Params
+	if err := mapstructure.Decode(t.report, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// transfer is the state of a single ownership transfer run.
+type transfer struct {
+	job    *ownershipTransferJob
+	params *transferParams
+	report *TransferReport
+
+	userCtx, successorCtx context.Context
+}
+
+// rehome returns the resource the data of the user at the given resource
+// was moved to in the storage of the successor, following the path mapping.
+// The initial path of the resource is only used when its current path cannot
+// be resolved, and may be empty if it is not known.
+func (t *transfer) rehome(ctx context.Context, instance, inode, initialPath string) (*provider.ResourceId, string, error) {
+	if t.params.SourcePath == "" {
+		return nil, initialPath, errors.New("the resource is owned by the user and no path mapping was given")
+	}
+
+	gw, err := pool.GetGatewayServiceClient(pool.Endpoint(t.job.c.GatewaySvc))
+	if err != nil {
+		return nil, initialPath, err
+	}
+
+	if t.userCtx == nil {
+		if t.userCtx, _, err = impersonate(ctx, t.job.c.GatewaySvc, t.job.c.MachineSecret, t.params.User); err != nil {
+			appctx.GetLogger(ctx).Warn().Err(err).Msg("sql: cannot impersonate the user, falling back to the initial paths")
+			t.userCtx = context.Background()
+		}
+	}
+	if t.successorCtx == nil {
+		if t.successorCtx, _, err = impersonate(ctx, t.job.c.GatewaySvc, t.job.c.MachineSecret, t.params.Successor); err != nil {
+			return nil, initialPath, errors.Wrap(err, "cannot impersonate the successor")
+		}
+	}
+
+	// the resource may have been moved since it was shared, so we resolve
+	// its current path as long as it is still reachable, by the user or,
+	// once the data was moved, by the successor
+	p := initialPath
+	for _, userCtx := range []context.Context{t.userCtx, t.successorCtx} {
+		if _, ok := appctx.ContextGetUser(userCtx); !ok {
+			continue
+		}
+		res, err := gw.GetPath(userCtx, &provider.GetPathRequest{ResourceId: &provider.ResourceId{StorageId: instance, OpaqueId: inode}})
+		if err == nil && res.Status.Code == rpc.Code_CODE_OK {
+			p = res.Path
+			break
+		}
+	}
+	if p == "" {
+		return nil, p, errors.New("the path of the resource cannot be resolved")
+	}
+
+	var target string
+	if rel, ok := relativeTo(p, t.params.SourcePath); ok {
+		target = path.Join(t.params.TargetPath, rel)
+	} else if _, ok := relativeTo(p, t.params.TargetPath); ok {
+		target = p
+	} else {
+		return nil, p, errors.Errorf("the resource is outside of %s", t.params.SourcePath)
+	}
+
+	res, err := gw.Stat(t.successorCtx, &provider.StatRequest{Ref: &provider.Reference{Path: target}})
+	if err != nil {
+		return nil, p, err
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		return nil, p, errors.Errorf("the resource was not found at %s: %s", target, res.Status.Message)
+	}
+	return res.Info.Id, p, nil
+}
+
+func relativeTo(p, base string) (string, bool) {
+	base = strings.TrimSuffix(base, "/")
+	if p != base && !strings.HasPrefix(p, base+"/") {
+		return "", false
+	}
+	return strings.TrimPrefix(p, base), true
+}
+
+func (t *transfer) shares(ctx context.Context) error {
+	var shares []model.Share
+	res := t.job.shares.db.Where("uid_owner = ? OR uid_initiator = ?", t.params.User, t.params.User).Find(&shares)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	for _, s := range shares {
+		id := strconv.Itoa(int(s.Id))
+		ref := &collaboration.ShareReference{Spec: &collaboration.ShareReference_Id{Id: &collaboration.ShareId{OpaqueId: id}}}
+
+		var newLocation *provider.ResourceId
+		if s.UIDOwner == t.params.User {
+			loc, p, err := t.rehome(ctx, s.Instance, s.Inode, s.InitialPath)
+			if err != nil {
+				t.report.fail("share", id, p, err)
+				continue
+			}
+			newLocation = loc
+		}
+		if !t.params.DryRun {
+			if newLocation != nil {
+				// the grant is added first, as the share is not rehomed
+				// again by a later run once moved
+				if err := t.grant(ctx, s, newLocation); err != nil {
+					t.report.fail("share", id, s.InitialPath, err)
+					continue
+				}
+				if err := t.job.shares.MoveShare(ctx, ref, newLocation, t.params.Successor); err != nil {
+					t.report.fail("share", id, s.InitialPath, err)
+					continue
+				}
+			}
+			if s.UIDInitiator == t.params.User {
+				if err := t.job.shares.TransferShare(ctx, ref, t.params.Successor); err != nil {
+					t.report.fail("share", id, s.InitialPath, err)
+					continue
+				}
+			}
+		}
+		t.report.Shares++
+	}
+	return nil
+}
+
+// grant adds the storage grant of the share on the resource it was moved to,
+// on behalf of the successor, who owns the resource.
+func (t *transfer) grant(ctx context.Context, s model.Share, id *provider.ResourceId) error {
+	ref := &provider.Reference{ResourceId: id}
+	reg, err := pool.GetStorageRegistryClient(pool.Endpoint(t.job.c.StorageRegistrySvc))
+	if err != nil {
+		return err
+	}
+	providers, err := reg.GetStorageProviders(t.successorCtx, &registry.GetStorageProvidersRequest{Ref: ref})
+	if err != nil {
+		return err
+	}
+	if providers.Status.Code != rpc.Code_CODE_OK || len(providers.Providers) == 0 {
+		return errors.Errorf("no storage provider found for the resource: %s", providers.Status.Message)
+	}
+	sp, err := pool.GetStorageProviderServiceClient(pool.Endpoint(providers.Providers[0].Address))
+	if err != nil {
+		return err
+	}
+
+	grantee := t.job.shares.getGrantee(ctx, s)
+	perms := permissions.OcsPermissions(s.Permissions).AsCS3Permissions()
+	var st *rpc.Status
+	if grants.PermissionsEqual(perms, &provider.ResourcePermissions{}) {
+		res, err := sp.DenyGrant(t.successorCtx, &provider.DenyGrantRequest{Ref: ref, Grantee: grantee})
+		if err != nil {
+			return err
+		}
+		st = res.Status
+	} else {
+		res, err := sp.AddGrant(t.successorCtx, &provider.AddGrantRequest{Ref: ref, Grant: &provider.Grant{Grantee: grantee, Permissions: perms}})
+		if err != nil {
+			return err
+		}
+		st = res.Status
+	}
+	if st.Code != rpc.Code_CODE_OK {
+		return errors.Errorf("error adding the grant: %s", st.Message)
+	}
+	return nil
+}
+
+// links transfers the public links. Unlike the shares, they are resolved
+// by the share manager and carry no storage grant to re-add.
+func (t *transfer) links(ctx context.Context) error {
+	var links []model.PublicLink
+	res := t.job.links.db.Where("uid_owner = ? OR uid_initiator = ?", t.params.User, t.params.User).Find(&links)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	for _, l := range links {
+		id := strconv.Itoa(int(l.Id))
+		ref := &link.PublicShareReference{Spec: &link.PublicShareReference_Id{Id: &link.PublicShareId{OpaqueId: id}}}
+
+		var newLocation *provider.ResourceId
+		if l.UIDOwner == t.params.User {
+			loc, p, err := t.rehome(ctx, l.Instance, l.Inode, l.InitialPath)
+			if err != nil {
+				t.report.fail("link", id, p, err)
+				continue
+			}
+			newLocation = loc
+		}
+		if !t.params.DryRun {
+			if newLocation != nil {
+				if err := t.job.links.MovePublicShare(ctx, ref, newLocation, t.params.Successor); err != nil {
+					t.report.fail("link", id, l.InitialPath, err)
+					continue
+				}
+			}
+			if l.UIDInitiator == t.params.User {
+				if err := t.job.links.TransferPublicShare(ctx, ref, t.params.Successor); err != nil {
+					t.report.fail("link", id, l.InitialPath, err)
+					continue
+				}
+			}
+		}
+		t.report.Links++
+	}
+	return nil
+}
+
+func (t *transfer) ocmShares(ctx context.Context) error {
+	var shares []model.OcmShare
+	res := t.job.ocm.db.Where("owner = ? OR initiator = ?", t.params.User, t.params.User).Find(&shares)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	for _, s := range shares {
+		id := &ocm.ShareId{OpaqueId: strconv.Itoa(int(s.Id))}
+
+		var newLocation *provider.ResourceId
+		if s.Owner == t.params.User {
+			// the name of an OCM share is not a path, so the path is
+			// only resolved from the resource
+			loc, p, err := t.rehome(ctx, s.Instance, s.Inode, "")
+			if err != nil {
+				t.report.fail("ocm_share", id.OpaqueId, p, err)
+				continue
+			}
+			newLocation = loc
+		}
+		if !t.params.DryRun {
+			if newLocation != nil {
+				if err := t.job.ocm.MoveShare(ctx, id, newLocation, t.params.Successor); err != nil {
+					t.report.fail("ocm_share", id.OpaqueId, s.Name, err)
+					continue
+				}
+			}
+			if s.Initiator == t.params.User {
+				if err := t.job.ocm.TransferShare(ctx, id, t.params.Successor); err != nil {
+					t.report.fail("ocm_share", id.OpaqueId, s.Name, err)
+					continue
+				}
+			}
+		}
+		t.report.OCMShares++
+	}
+	return nil
+}
+
+func (t *transfer) projects(ctx context.Context) error {
+	if t.job.projects == nil {
+		return nil
+	}
+
+	projects, err := t.job.projects.ListAllProjects(ctx, "", t.params.User)
+	if err != nil {
+		return err
+	}
+	for _, p := range projects {
+		if !t.params.DryRun {
+			if err := t.job.projects.TransferProjectOwnership(ctx, p.Name, t.params.Successor); err != nil {
+				t.report.fail("project", p.Name, p.Path, err)
+				continue
+			}
+		}
+		t.report.Projects++
+	}
+	return nil
+}
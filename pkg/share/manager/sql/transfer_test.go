@@ -0,0 +1,132 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/rjobs"
+	"github.com/cs3org/reva/v3/pkg/share/manager/sql/model"
+	"github.com/mitchellh/mapstructure"
+)
+
+func TestOwnershipTransfer(t *testing.T) {
+	ctx := context.Background()
+	dbName := "test_transfer.sqlite"
+	defer os.Remove(dbName)
+	cfg := map[string]any{
+		"db_engine": "sqlite",
+		"db_name":   dbName,
+	}
+
+	mgr, err := NewShareManager(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaverCtx := getUserContext("leaver")
+	leaver, _ := appctx.ContextGetUser(leaverCtx)
+
+	// a share of a project file, initiated by the leaving user
+	projectFile := getRandomFile(&userpb.User{Id: &userpb.UserId{OpaqueId: "project-b"}})
+	projectShare, err := mgr.Share(leaverCtx, projectFile, getUserShareGrant("1000", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a share of a file in the home of the leaving user
+	homeFile := &provider.ResourceInfo{
+		Id:    &provider.ResourceId{StorageId: "user", OpaqueId: "1234"},
+		Type:  provider.ResourceType_RESOURCE_TYPE_FILE,
+		Path:  "/eos/user/l/leaver/myfile",
+		Owner: leaver.Id,
+	}
+	homeShare, err := mgr.Share(leaverCtx, homeFile, getUserShareGrant("1000", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := NewOwnershipTransferJob(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(dryRun bool) TransferReport {
+		res, err := job.Run(ctx, rjobs.Params{"user": "leaver", "successor": "successor", "dry_run": dryRun})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report TransferReport
+		if err := mapstructure.Decode(map[string]any(res), &report); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	initiator := func(id string) string {
+		var s model.Share
+		if err := mgr.(*ShareMgr).db.First(&s, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		return s.UIDInitiator
+	}
+
+	report := run(true)
+	if report.Shares != 1 || len(report.Failures) != 1 || report.Failures[0].ID != homeShare.Id.OpaqueId {
+		t.Fatalf("unexpected dry run report: %+v", report)
+	}
+	if got := initiator(projectShare.Id.OpaqueId); got != "leaver" {
+		t.Fatalf("dry run changed the initiator to %s", got)
+	}
+
+	report = run(false)
+	if report.Shares != 1 || len(report.Failures) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if got := initiator(projectShare.Id.OpaqueId); got != "successor" {
+		t.Fatalf("expected the successor as initiator, got %s", got)
+	}
+	if got := initiator(homeShare.Id.OpaqueId); got != "leaver" {
+		t.Fatalf("the share of the home file should not be transferred without a path mapping, got %s", got)
+	}
+
+	// the run is idempotent: only what could not be transferred is left
+	report = run(false)
+	if report.Shares != 0 || len(report.Failures) != 1 {
+		t.Fatalf("unexpected report of the second run: %+v", report)
+	}
+}
+
+func TestOwnershipTransferParams(t *testing.T) {
+	job := &ownershipTransferJob{c: &transferConfig{}}
+	for _, p := range []rjobs.Params{
+		{"user": "leaver"},
+		{"successor": "successor"},
+		{"user": "leaver", "successor": "successor", "source_path": "/eos/user/l/leaver"},
+	} {
+		if _, err := job.Run(context.Background(), p); err == nil {
+			t.Fatalf("expected an error for params %v", p)
+		}
+	}
+}
@@ -67,6 +67,11 @@ func GetBlockedUsers() []string {
 	return sharedConf.BlockedUsers
 }
 
+// GetTrustedProxies returns the addresses of the trusted proxies.
+func GetTrustedProxies() []string {
+	return sharedConf.TrustedProxies
+}
+
 func GetDBInfo(in config.Database) config.Database {
 	c := in
 
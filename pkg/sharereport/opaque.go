@@ -0,0 +1,128 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sharereport
+
+import (
+	"context"
+	"encoding/json"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// The CS3 APIs do not define a share report: it is asked to the gateway in
+// the opaque of a ListSharesRequest, and returned in the opaque of the
+// response.
+const (
+	// ReportOpaqueKey in a ListSharesRequest asks for the report of the
+	// reference it carries, returned in the response under the same key.
+	ReportOpaqueKey = "share_report"
+	// OptionsOpaqueKey carries the options of the report.
+	OptionsOpaqueKey = "share_report_options"
+)
+
+// NewReportRequest returns a request asking the gateway for the share
+// report of the subtree rooted at ref.
+func NewReportRequest(ref *provider.Reference, opts Options) (*collaboration.ListSharesRequest, error) {
+	r, err := utils.MarshalProtoV1ToJSON(ref)
+	if err != nil {
+		return nil, err
+	}
+	o, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &collaboration.ListSharesRequest{
+		Opaque: &types.Opaque{
+			Map: map[string]*types.OpaqueEntry{
+				ReportOpaqueKey:  {Decoder: "json", Value: r},
+				OptionsOpaqueKey: {Decoder: "json", Value: o},
+			},
+		},
+	}, nil
+}
+
+// IsReportRequest tells whether a ListSharesRequest asks for a share report.
+func IsReportRequest(req *collaboration.ListSharesRequest) bool {
+	_, ok := req.Opaque.GetMap()[ReportOpaqueKey]
+	return ok
+}
+
+// RequestedReport returns the root and the options of the report asked for
+// in a ListSharesRequest.
+func RequestedReport(req *collaboration.ListSharesRequest) (*provider.Reference, Options, error) {
+	var opts Options
+	e := req.Opaque.GetMap()[ReportOpaqueKey]
+	if e == nil || e.Decoder != "json" {
+		return nil, opts, errtypes.BadRequest("sharereport: missing or invalid " + ReportOpaqueKey)
+	}
+	ref := &provider.Reference{}
+	if err := utils.UnmarshalJSONToProtoV1(e.Value, ref); err != nil {
+		return nil, opts, errtypes.BadRequest("sharereport: invalid " + ReportOpaqueKey + ": " + err.Error())
+	}
+	if o := req.Opaque.GetMap()[OptionsOpaqueKey]; o != nil {
+		if err := json.Unmarshal(o.Value, &opts); err != nil {
+			return nil, opts, errtypes.BadRequest("sharereport: invalid " + OptionsOpaqueKey + ": " + err.Error())
+		}
+	}
+	return ref, opts, nil
+}
+
+// ReportOpaque returns the opaque carrying a report.
+func ReportOpaque(r *Report) (*types.Opaque, error) {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Opaque{
+		Map: map[string]*types.OpaqueEntry{
+			ReportOpaqueKey: {Decoder: "json", Value: v},
+		},
+	}, nil
+}
+
+// Fetch asks the gateway for the share report of the subtree rooted at
+// ref, built on behalf of the user in ctx.
+func Fetch(ctx context.Context, gw gateway.GatewayAPIClient, ref *provider.Reference, opts Options) (*Report, error) {
+	req, err := NewReportRequest(ref, opts)
+	if err != nil {
+		return nil, err
+	}
+	res, err := gw.ListShares(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "sharereport: error calling ListShares")
+	}
+	if err := statusErr(res.Status, ref.String()); err != nil {
+		return nil, err
+	}
+	e := res.Opaque.GetMap()[ReportOpaqueKey]
+	if e == nil {
+		return nil, errtypes.NotSupported("sharereport: the gateway does not build share reports")
+	}
+	r := &Report{}
+	if err := json.Unmarshal(e.Value, r); err != nil {
+		return nil, errors.Wrap(err, "sharereport: error decoding the report")
+	}
+	return r, nil
+}
@@ -0,0 +1,584 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package sharereport answers the question "who has access to this folder":
+// it collects the user shares, group shares, public links and OCM shares
+// applying to a subtree and resolves the effective permission level of every
+// grantee, following the hierarchy rules of the sharehierarchy package.
+package sharereport
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alitto/pond/v2"
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	grouppb "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/permissions"
+	"github.com/cs3org/reva/v3/pkg/share"
+	"github.com/cs3org/reva/v3/pkg/sharehierarchy"
+	"github.com/pkg/errors"
+)
+
+// Type is the kind of grant an entry or an access row comes from.
+type Type string
+
+const (
+	// TypeUser is a share with a single user.
+	TypeUser Type = "user"
+	// TypeGroup is a share with a group.
+	TypeGroup Type = "group"
+	// TypeLink is a public link.
+	TypeLink Type = "link"
+	// TypeOCM is a share with a user on a federated instance.
+	TypeOCM Type = "ocm"
+)
+
+// Options tunes how a report is built.
+type Options struct {
+	// ExpandGroups resolves the members of every group share, so that
+	// the access table lists the users rather than the groups.
+	ExpandGroups bool `json:"expand_groups"`
+}
+
+// locateConcurrency is the number of paths of shared resources resolved
+// at once.
+const locateConcurrency = 20
+
+// Entry is a single share, link or OCM share found on the subtree.
+type Entry struct {
+	Path       string     `json:"path"`
+	ShareID    string     `json:"share_id"`
+	Type       Type       `json:"type"`
+	Grantee    string     `json:"grantee"`
+	Role       string     `json:"role"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	// Inherited is set for grants on an ancestor of the report root.
+	Inherited bool     `json:"inherited"`
+	Members   []string `json:"members,omitempty"`
+
+	level sharehierarchy.PermLevel
+}
+
+// Access is the effective permission level of a principal on a path.
+type Access struct {
+	Path      string `json:"path"`
+	Principal string `json:"principal"`
+	Type      Type   `json:"type"`
+	// Level is one of R, RW or D (denied).
+	Level string `json:"level"`
+	// Via lists the shares granting the access.
+	Via []string `json:"via"`
+}
+
+// Report is the result of Build.
+type Report struct {
+	Root        string    `json:"root"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Entries     []*Entry  `json:"entries"`
+	Access      []*Access `json:"access"`
+}
+
+// Build computes the share report for the subtree rooted at ref, using the
+// identity of the user in ctx. The caller must be allowed to list the grants
+// of the root, otherwise errtypes.PermissionDenied is returned.
+func Build(ctx context.Context, gw gateway.GatewayAPIClient, ref *provider.Reference, opts Options) (*Report, error) {
+	statRes, err := gw.Stat(ctx, &provider.StatRequest{Ref: ref})
+	if err != nil {
+		return nil, errors.Wrap(err, "sharereport: error calling Stat")
+	}
+	if err := statusErr(statRes.Status, ref.String()); err != nil {
+		return nil, err
+	}
+	info := statRes.Info
+	if info.PermissionSet == nil || !info.PermissionSet.ListGrants {
+		return nil, errtypes.PermissionDenied("sharereport: not allowed to list the grants of " + info.Path)
+	}
+	if info.Id == nil || info.Id.SpaceId == "" {
+		return nil, errtypes.BadRequest("sharereport: " + info.Path + " does not belong to a storage space")
+	}
+
+	b := &builder{
+		gw:        gw,
+		opts:      opts,
+		root:      info.Path,
+		space:     info.Id,
+		users:     map[string]string{},
+		groups:    map[string][]string{},
+		locations: map[string]location{},
+	}
+	if err := b.collectShares(ctx); err != nil {
+		return nil, err
+	}
+	if err := b.collectLinks(ctx, info.Owner); err != nil {
+		return nil, err
+	}
+	if err := b.collectOCMShares(ctx, info.Owner); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(b.entries, func(i, j int) bool {
+		if b.entries[i].Path != b.entries[j].Path {
+			return b.entries[i].Path < b.entries[j].Path
+		}
+		return b.entries[i].ShareID < b.entries[j].ShareID
+	})
+
+	return &Report{
+		Root:        b.root,
+		GeneratedAt: time.Now().UTC(),
+		Entries:     b.entries,
+		Access:      resolve(b.root, b.entries),
+	}, nil
+}
+
+type builder struct {
+	gw      gateway.GatewayAPIClient
+	opts    Options
+	root    string
+	space   *provider.ResourceId
+	entries []*Entry
+
+	// caches of resolved usernames, group members and paths of the
+	// shared resources
+	users     map[string]string
+	groups    map[string][]string
+	locations map[string]location
+}
+
+// location is where a shared resource lies relative to the report root.
+type location struct {
+	path      string
+	inherited bool
+	relevant  bool
+}
+
+func (b *builder) collectShares(ctx context.Context) error {
+	res, err := b.gw.ListShares(ctx, &collaboration.ListSharesRequest{
+		Filters: []*collaboration.Filter{share.SpaceIDFilter(b.space.SpaceId)},
+	})
+	if err != nil {
+		return errors.Wrap(err, "sharereport: error calling ListShares")
+	}
+	if err := statusErr(res.Status, b.root); err != nil {
+		return err
+	}
+
+	ids := make([]*provider.ResourceId, 0, len(res.Shares))
+	for _, s := range res.Shares {
+		ids = append(ids, s.ResourceId)
+	}
+	b.locateAll(ctx, ids)
+
+	for _, s := range res.Shares {
+		p, inherited, ok := b.locate(s.ResourceId)
+		if !ok {
+			continue
+		}
+		perms := s.GetPermissions().GetPermissions()
+		e := &Entry{
+			Path:       p,
+			ShareID:    s.GetId().GetOpaqueId(),
+			Role:       roleName(perms),
+			Expiration: timestamp(s.Expiration),
+			Inherited:  inherited,
+			level:      sharehierarchy.PermLevelFromCS3(perms),
+		}
+		switch s.Grantee.Type {
+		case provider.GranteeType_GRANTEE_TYPE_USER:
+			e.Type = TypeUser
+			e.Grantee = b.username(ctx, s.Grantee.GetUserId())
+		case provider.GranteeType_GRANTEE_TYPE_GROUP:
+			e.Type = TypeGroup
+			e.Grantee = s.Grantee.GetGroupId().GetOpaqueId()
+			if b.opts.ExpandGroups {
+				if e.Members, err = b.members(ctx, s.Grantee.GetGroupId()); err != nil {
+					return err
+				}
+			}
+		default:
+			continue
+		}
+		b.entries = append(b.entries, e)
+	}
+	return nil
+}
+
+func (b *builder) collectLinks(ctx context.Context, owner *userpb.UserId) error {
+	res, err := b.gw.ListPublicShares(ctx, &link.ListPublicSharesRequest{
+		Filters: []*link.ListPublicSharesRequest_Filter{{
+			Type: link.ListPublicSharesRequest_Filter_TYPE_OWNER,
+			Term: &link.ListPublicSharesRequest_Filter_Owner{Owner: owner},
+		}},
+	})
+	if err != nil {
+		return errors.Wrap(err, "sharereport: error calling ListPublicShares")
+	}
+	if err := statusErr(res.Status, b.root); err != nil {
+		return err
+	}
+
+	var ids []*provider.ResourceId
+	for _, l := range res.Share {
+		if b.sameSpace(l.ResourceId) {
+			ids = append(ids, l.ResourceId)
+		}
+	}
+	b.locateAll(ctx, ids)
+
+	for _, l := range res.Share {
+		if !b.sameSpace(l.ResourceId) {
+			continue
+		}
+		p, inherited, ok := b.locate(l.ResourceId)
+		if !ok {
+			continue
+		}
+		perms := l.GetPermissions().GetPermissions()
+		grantee := l.DisplayName
+		if grantee == "" {
+			grantee = l.Token
+		}
+		b.entries = append(b.entries, &Entry{
+			Path:       p,
+			ShareID:    l.GetId().GetOpaqueId(),
+			Type:       TypeLink,
+			Grantee:    grantee,
+			Role:       roleName(perms),
+			Expiration: timestamp(l.Expiration),
+			Inherited:  inherited,
+			level:      sharehierarchy.PermLevelFromCS3(perms),
+		})
+	}
+	return nil
+}
+
+func (b *builder) collectOCMShares(ctx context.Context, owner *userpb.UserId) error {
+	res, err := b.gw.ListOCMShares(ctx, &ocm.ListOCMSharesRequest{
+		Filters: []*ocm.ListOCMSharesRequest_Filter{{
+			Type: ocm.ListOCMSharesRequest_Filter_TYPE_OWNER,
+			Term: &ocm.ListOCMSharesRequest_Filter_Owner{Owner: owner},
+		}},
+	})
+	if err != nil {
+		return errors.Wrap(err, "sharereport: error calling ListOCMShares")
+	}
+	// OCM may not be enabled on this deployment
+	if res.Status.Code == rpc.Code_CODE_UNIMPLEMENTED {
+		return nil
+	}
+	if err := statusErr(res.Status, b.root); err != nil {
+		return err
+	}
+
+	var ids []*provider.ResourceId
+	for _, s := range res.Shares {
+		if b.sameSpace(s.ResourceId) {
+			ids = append(ids, s.ResourceId)
+		}
+	}
+	b.locateAll(ctx, ids)
+
+	for _, s := range res.Shares {
+		if !b.sameSpace(s.ResourceId) {
+			continue
+		}
+		p, inherited, ok := b.locate(s.ResourceId)
+		if !ok {
+			continue
+		}
+		perms := ocmPermissions(s)
+		uid := s.GetGrantee().GetUserId()
+		b.entries = append(b.entries, &Entry{
+			Path:       p,
+			ShareID:    s.GetId().GetOpaqueId(),
+			Type:       TypeOCM,
+			Grantee:    uid.GetOpaqueId() + "@" + uid.GetIdp(),
+			Role:       roleName(perms),
+			Expiration: timestamp(s.Expiration),
+			Inherited:  inherited,
+			level:      sharehierarchy.PermLevelFromCS3(perms),
+		})
+	}
+	return nil
+}
+
+// locateAll resolves the paths of the shared resources not resolved yet,
+// once per resource and concurrently, as a resource may carry several
+// shares and a report many resources.
+func (b *builder) locateAll(ctx context.Context, ids []*provider.ResourceId) {
+	todo := map[string]*provider.ResourceId{}
+	for _, id := range ids {
+		key := locationKey(id)
+		if _, ok := b.locations[key]; !ok {
+			todo[key] = id
+		}
+	}
+	if len(todo) == 0 {
+		return
+	}
+
+	var mu sync.Mutex
+	pool := pond.NewPool(locateConcurrency)
+	for key, id := range todo {
+		pool.Submit(func() {
+			l := b.resolveLocation(ctx, id)
+			mu.Lock()
+			b.locations[key] = l
+			mu.Unlock()
+		})
+	}
+	pool.StopAndWait()
+}
+
+// locate returns the path of a shared resource resolved by locateAll and
+// tells whether it is relevant to the report: either within the subtree,
+// or an ancestor of its root.
+func (b *builder) locate(id *provider.ResourceId) (string, bool, bool) {
+	l := b.locations[locationKey(id)]
+	return l.path, l.inherited, l.relevant
+}
+
+func locationKey(id *provider.ResourceId) string {
+	return id.GetStorageId() + "!" + id.GetOpaqueId()
+}
+
+func (b *builder) resolveLocation(ctx context.Context, id *provider.ResourceId) location {
+	res, err := b.gw.GetPath(ctx, &provider.GetPathRequest{ResourceId: id})
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+		// orphaned shares are skipped rather than failing the whole report
+		appctx.GetLogger(ctx).Warn().Err(err).Interface("resource", id).Msg("sharereport: cannot resolve path of shared resource, skipping")
+		return location{}
+	}
+	switch {
+	case isWithin(b.root, res.Path):
+		return location{path: res.Path, relevant: true}
+	case isWithin(res.Path, b.root):
+		return location{path: res.Path, inherited: true, relevant: true}
+	default:
+		return location{}
+	}
+}
+
+func (b *builder) sameSpace(id *provider.ResourceId) bool {
+	if id == nil || id.StorageId != b.space.StorageId {
+		return false
+	}
+	return id.SpaceId == "" || id.SpaceId == b.space.SpaceId
+}
+
+func (b *builder) username(ctx context.Context, id *userpb.UserId) string {
+	if name, ok := b.users[id.GetOpaqueId()]; ok {
+		return name
+	}
+	name := id.GetOpaqueId()
+	res, err := b.gw.GetUser(ctx, &userpb.GetUserRequest{UserId: id, SkipFetchingUserGroups: true})
+	if err == nil && res.Status.Code == rpc.Code_CODE_OK && res.User.Username != "" {
+		name = res.User.Username
+	}
+	b.users[id.GetOpaqueId()] = name
+	return name
+}
+
+func (b *builder) members(ctx context.Context, id *grouppb.GroupId) ([]string, error) {
+	if m, ok := b.groups[id.GetOpaqueId()]; ok {
+		return m, nil
+	}
+	res, err := b.gw.GetMembers(ctx, &grouppb.GetMembersRequest{GroupId: id})
+	if err != nil {
+		return nil, errors.Wrap(err, "sharereport: error calling GetMembers")
+	}
+	if err := statusErr(res.Status, id.GetOpaqueId()); err != nil {
+		return nil, err
+	}
+	m := make([]string, 0, len(res.Members))
+	for _, u := range res.Members {
+		m = append(m, b.username(ctx, u))
+	}
+	sort.Strings(m)
+	b.groups[id.GetOpaqueId()] = m
+	return m, nil
+}
+
+type principal struct {
+	name string
+	typ  Type
+}
+
+func (e *Entry) principals() []principal {
+	if e.Type == TypeGroup && len(e.Members) > 0 {
+		p := make([]principal, 0, len(e.Members))
+		for _, m := range e.Members {
+			p = append(p, principal{name: m, typ: TypeUser})
+		}
+		return p
+	}
+	return []principal{{name: e.Grantee, typ: e.Type}}
+}
+
+// resolve computes the effective access of every principal on the root and
+// on every path within the subtree carrying a grant. As in the share
+// hierarchy, the grant closest to a path wins; grants at the same depth are
+// combined by keeping the most powerful level, a denial being the strongest.
+func resolve(root string, entries []*Entry) []*Access {
+	points := map[string]struct{}{root: {}}
+	for _, e := range entries {
+		if isWithin(root, e.Path) {
+			points[e.Path] = struct{}{}
+		}
+	}
+
+	var access []*Access
+	for p := range points {
+		best := map[principal]*Access{}
+		depth := map[principal]int{}
+		level := map[principal]sharehierarchy.PermLevel{}
+
+		for _, e := range entries {
+			if !isWithin(e.Path, p) {
+				continue
+			}
+			d := len(e.Path)
+			for _, pr := range e.principals() {
+				a, ok := best[pr]
+				switch {
+				case !ok || d > depth[pr] || (d == depth[pr] && e.level > level[pr]):
+					best[pr] = &Access{Path: p, Principal: pr.name, Type: pr.typ, Level: e.level.String(), Via: []string{e.ShareID}}
+					depth[pr], level[pr] = d, e.level
+				case d == depth[pr] && e.level == level[pr]:
+					a.Via = append(a.Via, e.ShareID)
+				}
+			}
+		}
+		for _, a := range best {
+			access = append(access, a)
+		}
+	}
+
+	sort.Slice(access, func(i, j int) bool {
+		if access[i].Path != access[j].Path {
+			return access[i].Path < access[j].Path
+		}
+		if access[i].Type != access[j].Type {
+			return access[i].Type < access[j].Type
+		}
+		return access[i].Principal < access[j].Principal
+	})
+	return access
+}
+
+// WriteCSV writes the entries of the report as CSV, with a header row.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"path", "share_id", "type", "grantee", "role", "expiration", "inherited", "members"}); err != nil {
+		return err
+	}
+	for _, e := range r.Entries {
+		var exp string
+		if e.Expiration != nil {
+			exp = e.Expiration.Format(time.RFC3339)
+		}
+		if err := cw.Write([]string{e.Path, e.ShareID, string(e.Type), e.Grantee, e.Role, exp, strconv.FormatBool(e.Inherited), strings.Join(e.Members, ";")}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteAccessCSV writes the effective access table of the report as CSV, with a header row.
+func (r *Report) WriteAccessCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"path", "principal", "type", "level", "via"}); err != nil {
+		return err
+	}
+	for _, a := range r.Access {
+		if err := cw.Write([]string{a.Path, a.Principal, string(a.Type), a.Level, strings.Join(a.Via, ";")}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// isWithin tells whether p is base itself or one of its descendants.
+func isWithin(base, p string) bool {
+	rel, err := filepath.Rel(base, p)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, "../"))
+}
+
+func roleName(p *provider.ResourcePermissions) string {
+	if p == nil {
+		return permissions.RoleUnknown
+	}
+	return permissions.RoleFromResourcePermissions(p).Name
+}
+
+func ocmPermissions(s *ocm.Share) *provider.ResourcePermissions {
+	for _, m := range s.AccessMethods {
+		if p := m.GetWebdavOptions().GetPermissions(); p != nil {
+			return p
+		}
+		if p := m.GetWebappOptions().GetPermissions(); p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+func timestamp(t *types.Timestamp) *time.Time {
+	if t == nil || t.Seconds == 0 {
+		return nil
+	}
+	ts := time.Unix(int64(t.Seconds), int64(t.Nanos)).UTC()
+	return &ts
+}
+
+func statusErr(s *rpc.Status, ref string) error {
+	switch s.GetCode() {
+	case rpc.Code_CODE_OK:
+		return nil
+	case rpc.Code_CODE_NOT_FOUND:
+		return errtypes.NotFound(ref)
+	case rpc.Code_CODE_PERMISSION_DENIED:
+		return errtypes.PermissionDenied(ref)
+	case rpc.Code_CODE_INVALID_ARGUMENT:
+		return errtypes.BadRequest(s.GetMessage())
+	default:
+		return errtypes.InternalError("sharereport: " + s.GetMessage())
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sharereport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	grouppb "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/sharehierarchy"
+	"google.golang.org/grpc"
+)
+
+func TestResolve(t *testing.T) {
+	entries := []*Entry{
+		{Path: "/eos/project/a", ShareID: "1", Type: TypeUser, Grantee: "alice", Inherited: true, level: sharehierarchy.PermRead},
+		{Path: "/eos/project/a/b", ShareID: "2", Type: TypeGroup, Grantee: "team", Members: []string{"alice", "bob"}, level: sharehierarchy.PermRW},
+		{Path: "/eos/project/a/b/c", ShareID: "3", Type: TypeUser, Grantee: "bob", level: sharehierarchy.PermDeny},
+		{Path: "/eos/project/a/b/c", ShareID: "4", Type: TypeLink, Grantee: "tok", level: sharehierarchy.PermRead},
+		{Path: "/eos/project/a/bc", ShareID: "5", Type: TypeOCM, Grantee: "carol@remote", level: sharehierarchy.PermRead},
+	}
+
+	got := resolve("/eos/project/a/b", entries)
+	want := []*Access{
+		{Path: "/eos/project/a/b", Principal: "alice", Type: TypeUser, Level: "RW", Via: []string{"2"}},
+		{Path: "/eos/project/a/b", Principal: "bob", Type: TypeUser, Level: "RW", Via: []string{"2"}},
+		{Path: "/eos/project/a/b/c", Principal: "tok", Type: TypeLink, Level: "R", Via: []string{"4"}},
+		{Path: "/eos/project/a/b/c", Principal: "alice", Type: TypeUser, Level: "RW", Via: []string{"2"}},
+		{Path: "/eos/project/a/b/c", Principal: "bob", Type: TypeUser, Level: "D", Via: []string{"3"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected access table:\n got: %s\nwant: %s", dump(got), dump(want))
+	}
+}
+
+func TestResolveSameDepth(t *testing.T) {
+	entries := []*Entry{
+		{Path: "/a", ShareID: "1", Type: TypeUser, Grantee: "alice", level: sharehierarchy.PermRead},
+		{Path: "/a", ShareID: "2", Type: TypeGroup, Grantee: "g1", Members: []string{"alice"}, level: sharehierarchy.PermRW},
+		{Path: "/a", ShareID: "3", Type: TypeGroup, Grantee: "g2", Members: []string{"alice"}, level: sharehierarchy.PermRW},
+	}
+
+	got := resolve("/a", entries)
+	want := []*Access{
+		{Path: "/a", Principal: "alice", Type: TypeUser, Level: "RW", Via: []string{"2", "3"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected access table:\n got: %s\nwant: %s", dump(got), dump(want))
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	tests := []struct {
+		base, p string
+		want    bool
+	}{
+		{"/a", "/a", true},
+		{"/a", "/a/b", true},
+		{"/a", "/ab", false},
+		{"/a/b", "/a", false},
+		{"/a", "/b/..a", false},
+		{"/a", "/a/..b", true},
+	}
+	for _, tt := range tests {
+		if got := isWithin(tt.base, tt.p); got != tt.want {
+			t.Errorf("isWithin(%q, %q) = %v, want %v", tt.base, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	r := &Report{
+		Entries: []*Entry{
+			{Path: "/a,b", ShareID: "1", Type: TypeGroup, Grantee: "team", Role: "viewer", Members: []string{"alice", "bob"}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := r.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "path,share_id,type,grantee,role,expiration,inherited,members\n\"/a,b\",1,group,team,viewer,,false,alice;bob\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
+
+var (
+	viewer = &provider.ResourcePermissions{Stat: true, GetPath: true, ListContainer: true, InitiateFileDownload: true}
+	editor = &provider.ResourcePermissions{Stat: true, GetPath: true, ListContainer: true, InitiateFileDownload: true, InitiateFileUpload: true, CreateContainer: true}
+)
+
+// fakeGateway serves a project folder with shares on the folder, on its
+// parent, on an item below it and elsewhere in the space.
+type fakeGateway struct {
+	gateway.GatewayAPIClient
+	listGrants bool
+	getPaths   atomic.Int32
+}
+
+func okStatus() *rpc.Status { return &rpc.Status{Code: rpc.Code_CODE_OK} }
+
+func resID(id string) *provider.ResourceId {
+	return &provider.ResourceId{StorageId: "eos", SpaceId: "physics", OpaqueId: id}
+}
+
+func (g *fakeGateway) Stat(ctx context.Context, in *provider.StatRequest, opts ...grpc.CallOption) (*provider.StatResponse, error) {
+	return &provider.StatResponse{Status: okStatus(), Info: &provider.ResourceInfo{
+		Id:            resID("data"),
+		Path:          "/eos/project/p/physics/data",
+		Owner:         &userpb.UserId{OpaqueId: "owner"},
+		PermissionSet: &provider.ResourcePermissions{ListGrants: g.listGrants},
+	}}, nil
+}
+
+func (g *fakeGateway) GetPath(ctx context.Context, in *provider.GetPathRequest, opts ...grpc.CallOption) (*provider.GetPathResponse, error) {
+	g.getPaths.Add(1)
+	paths := map[string]string{
+		"project": "/eos/project/p/physics",
+		"data":    "/eos/project/p/physics/data",
+		"a":       "/eos/project/p/physics/data/a",
+		"other":   "/eos/project/p/physics/other",
+	}
+	p, found := paths[in.ResourceId.OpaqueId]
+	if !found {
+		return &provider.GetPathResponse{Status: &rpc.Status{Code: rpc.Code_CODE_NOT_FOUND}}, nil
+	}
+	return &provider.GetPathResponse{Status: okStatus(), Path: p}, nil
+}
+
+func userShare(id, res, user string, perms *provider.ResourcePermissions) *collaboration.Share {
+	return &collaboration.Share{
+		Id:          &collaboration.ShareId{OpaqueId: id},
+		ResourceId:  resID(res),
+		Grantee:     &provider.Grantee{Type: provider.GranteeType_GRANTEE_TYPE_USER, Id: &provider.Grantee_UserId{UserId: &userpb.UserId{OpaqueId: user}}},
+		Permissions: &collaboration.SharePermissions{Permissions: perms},
+	}
+}
+
+func (g *fakeGateway) ListShares(ctx context.Context, in *collaboration.ListSharesRequest, opts ...grpc.CallOption) (*collaboration.ListSharesResponse, error) {
+	group := &collaboration.Share{
+		Id:          &collaboration.ShareId{OpaqueId: "2"},
+		ResourceId:  resID("a"),
+		Grantee:     &provider.Grantee{Type: provider.GranteeType_GRANTEE_TYPE_GROUP, Id: &provider.Grantee_GroupId{GroupId: &grouppb.GroupId{OpaqueId: "physics-team"}}},
+		Permissions: &collaboration.SharePermissions{Permissions: editor},
+	}
+	return &collaboration.ListSharesResponse{Status: okStatus(), Shares: []*collaboration.Share{
+		userShare("1", "a", "1001", viewer),
+		group,
+		userShare("3", "project", "1001", editor),
+		userShare("4", "other", "1001", editor),
+		userShare("5", "gone", "1001", editor),
+	}}, nil
+}
+
+func (g *fakeGateway) ListPublicShares(ctx context.Context, in *link.ListPublicSharesRequest, opts ...grpc.CallOption) (*link.ListPublicSharesResponse, error) {
+	return &link.ListPublicSharesResponse{Status: okStatus(), Share: []*link.PublicShare{{
+		Id:          &link.PublicShareId{OpaqueId: "l1"},
+		ResourceId:  resID("a"),
+		Token:       "tok",
+		Permissions: &link.PublicSharePermissions{Permissions: viewer},
+	}}}, nil
+}
+
+func (g *fakeGateway) ListOCMShares(ctx context.Context, in *ocm.ListOCMSharesRequest, opts ...grpc.CallOption) (*ocm.ListOCMSharesResponse, error) {
+	return &ocm.ListOCMSharesResponse{Status: okStatus(), Shares: []*ocm.Share{{
+		Id:            &ocm.ShareId{OpaqueId: "o1"},
+		ResourceId:    resID("data"),
+		Grantee:       &provider.Grantee{Type: provider.GranteeType_GRANTEE_TYPE_USER, Id: &provider.Grantee_UserId{UserId: &userpb.UserId{OpaqueId: "carol", Idp: "remote.org"}}},
+		AccessMethods: []*ocm.AccessMethod{{Term: &ocm.AccessMethod_WebdavOptions{WebdavOptions: &ocm.WebDAVAccessMethod{Permissions: viewer}}}},
+	}}}, nil
+}
+
+func (g *fakeGateway) GetUser(ctx context.Context, in *userpb.GetUserRequest, opts ...grpc.CallOption) (*userpb.GetUserResponse, error) {
+	names := map[string]string{"1001": "einstein", "1002": "marie"}
+	return &userpb.GetUserResponse{Status: okStatus(), User: &userpb.User{Id: in.UserId, Username: names[in.UserId.OpaqueId]}}, nil
+}
+
+func (g *fakeGateway) GetMembers(ctx context.Context, in *grouppb.GetMembersRequest, opts ...grpc.CallOption) (*grouppb.GetMembersResponse, error) {
+	return &grouppb.GetMembersResponse{Status: okStatus(), Members: []*userpb.UserId{{OpaqueId: "1002"}}}, nil
+}
+
+func TestBuild(t *testing.T) {
+	gw := &fakeGateway{listGrants: true}
+	r, err := Build(context.Background(), gw, &provider.Reference{Path: "/eos/project/p/physics/data"}, Options{ExpandGroups: true})
+	if err != nil {
+		t.Fatalf("Build returned error: %v", err)
+	}
+
+	var entries []string
+	for _, e := range r.Entries {
+		entries = append(entries, fmt.Sprintf("%s:%s:%s:%s:%s:%t", e.Path, e.ShareID, e.Type, e.Grantee, strings.Join(e.Members, "|"), e.Inherited))
+	}
+	wantEntries := []string{
+		"/eos/project/p/physics:3:user:einstein::true",
+		"/eos/project/p/physics/data:o1:ocm:carol@remote.org::false",
+		"/eos/project/p/physics/data/a:1:user:einstein::false",
+		"/eos/project/p/physics/data/a:2:group:physics-team:marie:false",
+		"/eos/project/p/physics/data/a:l1:link:tok::false",
+	}
+	if !reflect.DeepEqual(entries, wantEntries) {
+		t.Fatalf("unexpected entries:\n got: %v\nwant: %v", entries, wantEntries)
+	}
+
+	wantAccess := []*Access{
+		{Path: "/eos/project/p/physics/data", Principal: "carol@remote.org", Type: TypeOCM, Level: "R", Via: []string{"o1"}},
+		{Path: "/eos/project/p/physics/data", Principal: "einstein", Type: TypeUser, Level: "RW", Via: []string{"3"}},
+		{Path: "/eos/project/p/physics/data/a", Principal: "tok", Type: TypeLink, Level: "R", Via: []string{"l1"}},
+		{Path: "/eos/project/p/physics/data/a", Principal: "carol@remote.org", Type: TypeOCM, Level: "R", Via: []string{"o1"}},
+		{Path: "/eos/project/p/physics/data/a", Principal: "einstein", Type: TypeUser, Level: "R", Via: []string{"1"}},
+		{Path: "/eos/project/p/physics/data/a", Principal: "marie", Type: TypeUser, Level: "RW", Via: []string{"2"}},
+	}
+	if !reflect.DeepEqual(r.Access, wantAccess) {
+		t.Fatalf("unexpected access table:\n got: %s\nwant: %s", dump(r.Access), dump(wantAccess))
+	}
+
+	// a, project, other, gone and data are resolved once each
+	if n := gw.getPaths.Load(); n != 5 {
+		t.Errorf("expected 5 GetPath calls, got %d", n)
+	}
+}
+
+func TestBuildDenied(t *testing.T) {
+	_, err := Build(context.Background(), &fakeGateway{}, &provider.Reference{Path: "/eos/project/p/physics/data"}, Options{})
+	if _, ok := err.(errtypes.IsPermissionDenied); !ok {
+		t.Fatalf("expected a permission denied error, got %v", err)
+	}
+}
+
+func TestReportRequest(t *testing.T) {
+	req, err := NewReportRequest(&provider.Reference{Path: "/eos/project/p/physics"}, Options{ExpandGroups: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsReportRequest(req) || IsReportRequest(&collaboration.ListSharesRequest{}) {
+		t.Fatal("the report request is not recognized")
+	}
+	ref, opts, err := RequestedReport(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Path != "/eos/project/p/physics" || !opts.ExpandGroups {
+		t.Fatalf("unexpected request %v %+v", ref, opts)
+	}
+}
+
+func dump(a []*Access) string {
+	var s []string
+	for _, x := range a {
+		s = append(s, x.Path+":"+x.Principal+":"+string(x.Type)+":"+x.Level+":"+strings.Join(x.Via, "|"))
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,187 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package thumbnails
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+)
+
+// Output formats of the thumbnails. Only the encoders of the standard library
+// are used: there is no webp output format.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+)
+
+// formatMimeTypes maps the output formats to their mime type.
+var formatMimeTypes = map[string]string{
+	FormatJPEG: "image/jpeg",
+	FormatPNG:  "image/png",
+	FormatGIF:  "image/gif",
+}
+
+// normalizeFormat returns the canonical name of a format, the default
+// one for the source mime type if empty.
+func normalizeFormat(format, mimeType string) (string, error) {
+	switch format {
+	case "":
+		if mimeType == "image/jpeg" {
+			return FormatJPEG, nil
+		}
+		return FormatPNG, nil
+	case "jpg", FormatJPEG:
+		return FormatJPEG, nil
+	case FormatPNG, FormatGIF:
+		return format, nil
+	}
+	return "", errtypes.BadRequest("thumbnails: unknown format " + format)
+}
+
+// targetSize returns the size of the thumbnail of an image of the given
+// bounds: it fits in the requested box when keeping the aspect ratio,
+// without enlarging the image, or fills it otherwise.
+func targetSize(bounds image.Rectangle, width, height int, preserveAspect bool) (int, int) {
+	w, h := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 {
+		// a single dimension is given, the other one follows the aspect ratio
+		if width <= 0 {
+			width = max(1, w*height/h)
+		} else {
+			height = max(1, h*width/w)
+		}
+		if preserveAspect && width > w {
+			return w, h
+		}
+		return width, height
+	}
+	if !preserveAspect {
+		return width, height
+	}
+
+	if w <= width && h <= height {
+		return w, h
+	}
+	// scale by the smaller ratio, comparing w/width and h/height
+	if w*height > h*width {
+		return width, max(1, h*width/w)
+	}
+	return max(1, w*height/h), height
+}
+
+// cropRect returns the centered region of the given bounds having the aspect
+// ratio of the given size.
+func cropRect(b image.Rectangle, width, height int) image.Rectangle {
+	w, h := b.Dx(), b.Dy()
+	cw, ch := w, h
+	if w*height > h*width {
+		cw = max(1, h*width/height)
+	} else {
+		ch = max(1, w*height/width)
+	}
+	x0 := b.Min.X + (w-cw)/2
+	y0 := b.Min.Y + (h-ch)/2
+	return image.Rect(x0, y0, x0+cw, y0+ch)
+}
+
+// resize scales the region b of the image to the given size, averaging the
+// source pixels covered by every target pixel (box filter). The source is
+// converted to RGBA one row at a time, so that no copy of the whole image
+// is made.
+func resize(src image.Image, b image.Rectangle, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sw, sh := b.Dx(), b.Dy()
+	row := image.NewRGBA(image.Rect(0, 0, sw, 1))
+	sums := make([]uint32, 4*width)
+	counts := make([]uint32, width)
+
+	for y := 0; y < height; y++ {
+		sy0 := y * sh / height
+		sy1 := max(sy0+1, (y+1)*sh/height)
+		clear(sums)
+		clear(counts)
+		for sy := sy0; sy < sy1; sy++ {
+			draw.Draw(row, row.Bounds(), src, image.Pt(b.Min.X, b.Min.Y+sy), draw.Src)
+			for x := 0; x < width; x++ {
+				sx0 := x * sw / width
+				sx1 := max(sx0+1, (x+1)*sw/width)
+				for off := 4 * sx0; off < 4*sx1; off += 4 {
+					sums[4*x] += uint32(row.Pix[off])
+					sums[4*x+1] += uint32(row.Pix[off+1])
+					sums[4*x+2] += uint32(row.Pix[off+2])
+					sums[4*x+3] += uint32(row.Pix[off+3])
+					counts[x]++
+				}
+			}
+		}
+
+		off := dst.PixOffset(0, y)
+		for x := 0; x < width; x++ {
+			n := counts[x]
+			dst.Pix[off] = uint8(sums[4*x] / n)
+			dst.Pix[off+1] = uint8(sums[4*x+1] / n)
+			dst.Pix[off+2] = uint8(sums[4*x+2] / n)
+			dst.Pix[off+3] = uint8(sums[4*x+3] / n)
+			off += 4
+		}
+	}
+	return dst
+}
+
+// scale returns the thumbnail of the image for the given request.
+func scale(img image.Image, req *Request) *image.RGBA {
+	b := img.Bounds()
+	width, height := targetSize(b, req.Width, req.Height, req.PreserveAspect)
+	if !req.PreserveAspect {
+		b = cropRect(b, width, height)
+	}
+	if rgba, ok := img.(*image.RGBA); ok && b.Dx() == width && b.Dy() == height {
+		return rgba.SubImage(b).(*image.RGBA)
+	}
+	return resize(img, b, width, height)
+}
+
+func encode(img *image.RGBA, format string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatJPEG:
+		// jpeg has no transparency, flatten the image on a white background
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+		err = jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality})
+	case FormatGIF:
+		err = gif.Encode(&buf, img, nil)
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,386 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package thumbnails generates the previews of the files: resized
+// images, and the rendering of the first page of the text files.
+package thumbnails
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"image"
+	_ "image/gif"  // decode gif images
+	_ "image/jpeg" // decode jpeg images
+	_ "image/png"  // decode png images
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/internal/http/services/datagateway"
+	"github.com/cs3org/reva/v3/pkg/appctx"
+	"github.com/cs3org/reva/v3/pkg/auth/scope"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	"github.com/cs3org/reva/v3/pkg/httpclient"
+	"github.com/cs3org/reva/v3/pkg/publicshare"
+	"github.com/cs3org/reva/v3/pkg/rgrpc/status"
+	"github.com/cs3org/reva/v3/pkg/share/cache"
+	cachereg "github.com/cs3org/reva/v3/pkg/share/cache/registry"
+	"github.com/cs3org/reva/v3/pkg/spaces"
+	"github.com/cs3org/reva/v3/pkg/utils/cfg"
+	"github.com/pkg/errors"
+)
+
+// imageMimeTypes are the image types that can be decoded.
+var imageMimeTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Config holds the configuration of the thumbnails.
+type Config struct {
+	CacheDriver  string                    `docs:"memory_thumbnail;The share cache driver keeping the thumbnails: memory_thumbnail, redis_thumbnail or disk_thumbnail." mapstructure:"cache_type"`
+	CacheDrivers map[string]map[string]any `mapstructure:"caches"`
+	CacheTTL     int                       `docs:"86400;Seconds the thumbnails are kept in the cache."                                                               mapstructure:"cache_ttl"`
+	MaxDimension int                       `docs:"1920;The largest width or height of a thumbnail."                                                                  mapstructure:"max_dimension"`
+	MaxInputSize uint64                    `docs:"52428800;Files larger than this number of bytes have no thumbnail."                                                mapstructure:"max_input_size"`
+	MaxPixels    int                       `docs:"50000000;Images having more pixels have no thumbnail."                                                             mapstructure:"max_pixels"`
+	Concurrency  int                       `docs:"4;The number of thumbnails generated at the same time, the others wait."                                           mapstructure:"concurrency"`
+	Quality      int                       `docs:"80;The quality of the jpeg thumbnails, from 1 to 100."                                                             mapstructure:"quality"`
+	Timeout      int64                     `docs:"60;Seconds allowed to download a file."                                                                            mapstructure:"timeout"`
+	Insecure     bool                      `docs:"false;Whether to skip certificate checks when downloading the files."                                              mapstructure:"insecure"`
+}
+
+func (c *Config) ApplyDefaults() {
+	if c.CacheDriver == "" {
+		c.CacheDriver = "memory_thumbnail"
+	}
+	if c.CacheTTL == 0 {
+		c.CacheTTL = 86400
+	}
+	if c.MaxDimension == 0 {
+		c.MaxDimension = 1920
+	}
+	if c.MaxInputSize == 0 {
+		c.MaxInputSize = 50 * 1024 * 1024
+	}
+	if c.MaxPixels == 0 {
+		c.MaxPixels = 50000000
+	}
+	if c.Concurrency == 0 {
+		c.Concurrency = 4
+	}
+	if c.Quality == 0 {
+		c.Quality = 80
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 60
+	}
+}
+
+// Request describes a thumbnail.
+type Request struct {
+	// Width and Height of the thumbnail. When one of them is zero,
+	// it is computed from the other one keeping the aspect ratio.
+	Width, Height int
+	// Format of the thumbnail, by default jpeg for jpeg
+	// images and png otherwise.
+	Format string
+	// PreserveAspect fits the image in the requested size. Otherwise
+	// the image fills the size, being cropped around its center.
+	PreserveAspect bool
+}
+
+// ParseRequest returns the thumbnail asked for with the query parameters
+// x and y for the size (32 by default), a for preserving the aspect ratio
+// (1 or true) and format.
+func ParseRequest(q url.Values) (*Request, error) {
+	req := &Request{Width: 32, Height: 32, Format: q.Get("format")}
+	for param, v := range map[string]*int{"x": &req.Width, "y": &req.Height} {
+		if q.Get(param) == "" {
+			continue
+		}
+		n, err := strconv.Atoi(q.Get(param))
+		if err != nil || n < 0 {
+			return nil, errtypes.BadRequest("thumbnails: invalid " + param)
+		}
+		*v = n
+	}
+	switch q.Get("a") {
+	case "1", "true":
+		req.PreserveAspect = true
+	}
+	return req, nil
+}
+
+// Thumbnail is a generated thumbnail.
+type Thumbnail struct {
+	Data     []byte
+	MimeType string
+	// ETag changes with the version of the resource and the
+	// properties of the thumbnail.
+	ETag string
+}
+
+// Manager generates the thumbnails of the files fetched
+// through the gateway, and caches them.
+type Manager struct {
+	c      *Config
+	cache  cache.ThumbnailCache
+	client *httpclient.Client
+	// slots bounds the thumbnails generated at the same time, as a decoded
+	// image can take a lot of memory.
+	slots chan struct{}
+}
+
+// New returns a new thumbnails manager.
+func New(m map[string]any) (*Manager, error) {
+	var c Config
+	if err := cfg.Decode(m, &c); err != nil {
+		return nil, err
+	}
+
+	factory, err := cachereg.GetCacheFunc[cache.ThumbnailCache](c.CacheDriver)
+	if err != nil {
+		return nil, err
+	}
+	thumbCache, err := factory(c.CacheDrivers[c.CacheDriver])
+	if err != nil {
+		return nil, errors.Wrap(err, "thumbnails: error creating the cache")
+	}
+
+	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: c.Insecure}}
+	return &Manager{
+		c:     &c,
+		cache: thumbCache,
+		slots: make(chan struct{}, c.Concurrency),
+		client: httpclient.New(
+			httpclient.Timeout(time.Duration(c.Timeout)*time.Second),
+			httpclient.RoundTripper(tr),
+		),
+	}, nil
+}
+
+// Close releases the cache of the thumbnails, if it holds any resource.
+func (m *Manager) Close() error {
+	if c, ok := m.cache.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Supported tells whether files of the given mime type can have a thumbnail.
+func Supported(mimeType string) bool {
+	return imageMimeTypes[mimeType] || strings.HasPrefix(mimeType, "text/")
+}
+
+// HasThumbnail tells whether the resource can have a thumbnail.
+func (m *Manager) HasThumbnail(info *provider.ResourceInfo) bool {
+	return info.Type == provider.ResourceType_RESOURCE_TYPE_FILE &&
+		info.Size <= m.c.MaxInputSize &&
+		Supported(info.MimeType)
+}
+
+// Get returns the thumbnail of a resource, from the cache if generated
+// already for the current version of the resource.
+func (m *Manager) Get(ctx context.Context, client gateway.GatewayAPIClient, info *provider.ResourceInfo, req *Request) (*Thumbnail, error) {
+	if !m.HasThumbnail(info) {
+		return nil, errtypes.NotSupported("thumbnails: no thumbnail for " + info.MimeType)
+	}
+	format, err := normalizeFormat(req.Format, info.MimeType)
+	if err != nil {
+		return nil, err
+	}
+	if req.Width <= 0 && req.Height <= 0 {
+		return nil, errtypes.BadRequest("thumbnails: missing width and height")
+	}
+	r := *req
+	r.Format = format
+	r.Width = min(r.Width, m.c.MaxDimension)
+	r.Height = min(r.Height, m.c.MaxDimension)
+
+	log := appctx.GetLogger(ctx)
+	key := cacheKey(info, &r)
+	etag := fmt.Sprintf("%x", sha256.Sum256([]byte(key)))[:32]
+	if data, err := m.cache.Get(key); err == nil && len(data) != 0 {
+		return &Thumbnail{Data: data, MimeType: formatMimeTypes[format], ETag: etag}, nil
+	}
+
+	// generating a thumbnail downloads the whole file, which would count
+	// against the limit of a link limited in downloads
+	if downloadLimited(ctx) {
+		return nil, errtypes.NotFound("thumbnails: no thumbnail through a link limited in downloads")
+	}
+
+	select {
+	case m.slots <- struct{}{}:
+		defer func() { <-m.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	body, err := m.download(ctx, client, info)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	t, err := m.generate(io.LimitReader(body, int64(m.c.MaxInputSize)), info.MimeType, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.cache.SetWithExpire(key, t.Data, time.Duration(m.c.CacheTTL)*time.Second); err != nil {
+		log.Warn().Err(err).Msg("thumbnails: error caching thumbnail")
+	}
+	t.ETag = etag
+	return t, nil
+}
+
+// Write writes a thumbnail in the response, or not modified
+// if the client has the same one already.
+func Write(w http.ResponseWriter, r *http.Request, t *Thumbnail) {
+	etag := `"` + t.ETag + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", t.MimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(t.Data)))
+	_, _ = w.Write(t.Data)
+}
+
+// HTTPStatus returns the HTTP status code for an error getting a thumbnail.
+func HTTPStatus(err error) int {
+	switch err.(type) {
+	case errtypes.IsBadRequest:
+		return http.StatusBadRequest
+	case errtypes.IsNotSupported, errtypes.IsNotFound:
+		// there is no thumbnail for the file
+		return http.StatusNotFound
+	case errtypes.IsPermissionDenied:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// cacheKey identifies a thumbnail of a version of a resource: a new etag
+// makes the thumbnails of the previous versions unreachable.
+func cacheKey(info *provider.ResourceInfo, req *Request) string {
+	return fmt.Sprintf("thumbnail:%s:%s:%dx%d:%t:%s", spaces.EncodeToStringifiedResourceID(info.Id), info.Etag,
+		req.Width, req.Height, req.PreserveAspect, req.Format)
+}
+
+// downloadLimited tells whether the request in ctx comes through a public
+// link limited in downloads.
+func downloadLimited(ctx context.Context) bool {
+	scopes, ok := appctx.ContextGetScopes(ctx)
+	if !ok {
+		return false
+	}
+	shares, err := scope.GetPublicSharesFromScopes(scopes)
+	if err != nil {
+		return false
+	}
+	for _, s := range shares {
+		if r, err := publicshare.GetRestrictions(s.Opaque); err != nil || (r != nil && r.MaxDownloads > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) download(ctx context.Context, client gateway.GatewayAPIClient, info *provider.ResourceInfo) (io.ReadCloser, error) {
+	res, err := client.InitiateFileDownload(ctx, &provider.InitiateFileDownloadRequest{
+		Ref: &provider.Reference{ResourceId: info.Id},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "thumbnails: error initiating download")
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		return nil, status.NewErrorFromCode(res.Status.Code, "thumbnails")
+	}
+
+	var ep, token string
+	for _, p := range res.Protocols {
+		if p.Protocol == "simple" {
+			ep, token = p.DownloadEndpoint, p.Token
+		}
+	}
+	if ep == "" {
+		return nil, errtypes.InternalError("thumbnails: no simple download protocol")
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, ep, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set(datagateway.TokenTransportHeader, token)
+
+	httpRes, err := m.client.Do(httpReq)
+	if err != nil {
+		return nil, errors.Wrap(err, "thumbnails: error downloading file")
+	}
+	if httpRes.StatusCode != http.StatusOK {
+		httpRes.Body.Close()
+		return nil, errtypes.InternalError(fmt.Sprintf("thumbnails: download returned %d", httpRes.StatusCode))
+	}
+	return httpRes.Body, nil
+}
+
+// generate returns the thumbnail of the content of a file of the given mime type.
+func (m *Manager) generate(r io.Reader, mimeType string, req *Request) (*Thumbnail, error) {
+	var img image.Image
+	if imageMimeTypes[mimeType] {
+		// refuse the images taking too much memory once decoded, reading
+		// only their header, which is then replayed to decode them
+		var header bytes.Buffer
+		conf, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+		if err != nil {
+			return nil, errtypes.BadRequest("thumbnails: error decoding image: " + err.Error())
+		}
+		if conf.Width*conf.Height > m.c.MaxPixels {
+			return nil, errtypes.NotSupported("thumbnails: image too large")
+		}
+		if img, _, err = image.Decode(io.MultiReader(&header, r)); err != nil {
+			return nil, errtypes.BadRequest("thumbnails: error decoding image: " + err.Error())
+		}
+	} else {
+		var err error
+		if img, err = renderText(r); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := encode(scale(img, req), req.Format, m.c.Quality)
+	if err != nil {
+		return nil, errors.Wrap(err, "thumbnails: error encoding thumbnail")
+	}
+	return &Thumbnail{Data: data, MimeType: formatMimeTypes[req.Format]}, nil
+}
@@ -0,0 +1,262 @@
+// Copyright 2018-2024 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package thumbnails
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v3/pkg/errtypes"
+	_ "github.com/cs3org/reva/v3/pkg/share/cache/memory"
+	"google.golang.org/grpc"
+)
+
+func testImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0x80, 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFont(t *testing.T) {
+	if len(font) != '~'-' '+1 {
+		t.Fatalf("font has %d glyphs, want %d", len(font), '~'-' '+1)
+	}
+}
+
+func TestTargetSize(t *testing.T) {
+	tests := []struct {
+		w, h, width, height int
+		preserveAspect      bool
+		wantW, wantH        int
+	}{
+		{w: 400, h: 200, width: 100, height: 100, preserveAspect: true, wantW: 100, wantH: 50},
+		{w: 200, h: 400, width: 100, height: 100, preserveAspect: true, wantW: 50, wantH: 100},
+		{w: 50, h: 20, width: 100, height: 100, preserveAspect: true, wantW: 50, wantH: 20},
+		{w: 400, h: 200, width: 100, height: 0, preserveAspect: true, wantW: 100, wantH: 50},
+		{w: 400, h: 200, width: 100, height: 100, wantW: 100, wantH: 100},
+	}
+	for _, tt := range tests {
+		w, h := targetSize(image.Rect(0, 0, tt.w, tt.h), tt.width, tt.height, tt.preserveAspect)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("targetSize(%dx%d, %dx%d, %t) = %dx%d, want %dx%d", tt.w, tt.h, tt.width, tt.height, tt.preserveAspect, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	m, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		mimeType string
+		content  []byte
+		req      *Request
+		wantType string
+		wantW    int
+		wantH    int
+	}{
+		{
+			name:     "fit image",
+			mimeType: "image/png",
+			content:  testImage(t, 200, 100),
+			req:      &Request{Width: 32, Height: 32, Format: FormatPNG, PreserveAspect: true},
+			wantType: "image/png",
+			wantW:    32,
+			wantH:    16,
+		},
+		{
+			name:     "cropped image as jpeg",
+			mimeType: "image/png",
+			content:  testImage(t, 200, 100),
+			req:      &Request{Width: 32, Height: 32, Format: FormatJPEG},
+			wantType: "image/jpeg",
+			wantW:    32,
+			wantH:    32,
+		},
+		{
+			name:     "text",
+			mimeType: "text/plain",
+			content:  []byte(strings.Repeat("Hello, World!\tThe quick brown fox jumps over the lazy dog.\n", 200)),
+			req:      &Request{Width: 0, Height: 128, Format: FormatPNG, PreserveAspect: true},
+			wantType: "image/png",
+			wantW:    128 * pageWidth / pageHeight,
+			wantH:    128,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th, err := m.generate(bytes.NewReader(tt.content), tt.mimeType, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if th.MimeType != tt.wantType {
+				t.Errorf("mime type = %s, want %s", th.MimeType, tt.wantType)
+			}
+			conf, format, err := image.DecodeConfig(bytes.NewReader(th.Data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if "image/"+format != tt.wantType || conf.Width != tt.wantW || conf.Height != tt.wantH {
+				t.Errorf("thumbnail is a %s of %dx%d, want %s of %dx%d", format, conf.Width, conf.Height, tt.wantType, tt.wantW, tt.wantH)
+			}
+		})
+	}
+
+	if _, err := m.generate(bytes.NewReader([]byte("not an image")), "image/jpeg", &Request{Width: 10, Format: FormatJPEG}); err == nil {
+		t.Error("expected an error for an invalid image")
+	}
+}
+
+func TestParseRequest(t *testing.T) {
+	req, err := ParseRequest(url.Values{"x": {"64"}, "a": {"1"}, "format": {"png"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *req != (Request{Width: 64, Height: 32, Format: "png", PreserveAspect: true}) {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if _, err := ParseRequest(url.Values{"y": {"big"}}); err == nil {
+		t.Error("expected an error for an invalid size")
+	}
+}
+
+type downloadGateway struct {
+	gateway.GatewayAPIClient
+	endpoint string
+}
+
+func (g *downloadGateway) InitiateFileDownload(ctx context.Context, req *provider.InitiateFileDownloadRequest, opts ...grpc.CallOption) (*gateway.InitiateFileDownloadResponse, error) {
+	return &gateway.InitiateFileDownloadResponse{
+		Status: &rpc.Status{Code: rpc.Code_CODE_OK},
+		Protocols: []*gateway.FileDownloadProtocol{
+			{Protocol: "simple", DownloadEndpoint: g.endpoint},
+		},
+	}, nil
+}
+
+func TestGet(t *testing.T) {
+	content := testImage(t, 64, 64)
+	downloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+
+	m, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := &downloadGateway{endpoint: srv.URL}
+	info := &provider.ResourceInfo{
+		Id:       &provider.ResourceId{StorageId: "storage", SpaceId: "space", OpaqueId: "file"},
+		Type:     provider.ResourceType_RESOURCE_TYPE_FILE,
+		MimeType: "image/jpeg",
+		Etag:     "etag-1",
+		Size:     uint64(len(content)),
+	}
+	req := &Request{Width: 16, Height: 16, PreserveAspect: true}
+
+	var etag string
+	for i := 0; i < 2; i++ {
+		th, err := m.Get(context.Background(), gw, info, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if th.MimeType != "image/jpeg" {
+			t.Fatalf("mime type = %s, want the default image/jpeg", th.MimeType)
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(th.Data)); err != nil {
+			t.Fatal(err)
+		}
+		if etag != "" && th.ETag != etag {
+			t.Errorf("etag changed from %s to %s", etag, th.ETag)
+		}
+		etag = th.ETag
+	}
+	if downloads != 1 {
+		t.Errorf("file downloaded %d times, want the cached thumbnail", downloads)
+	}
+
+	// a new version of the file has a new thumbnail
+	info.Etag = "etag-2"
+	th, err := m.Get(context.Background(), gw, info, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th.ETag == etag {
+		t.Error("expected a new etag for the new version")
+	}
+	if downloads != 2 {
+		t.Errorf("file downloaded %d times, want a new thumbnail for the new version", downloads)
+	}
+
+	// webp is not an output format
+	if _, err := m.Get(context.Background(), gw, info, &Request{Width: 16, Format: "webp"}); err == nil {
+		t.Error("expected an error for webp thumbnails")
+	} else if _, ok := err.(errtypes.IsBadRequest); !ok {
+		t.Errorf("error = %v, want bad request", err)
+	}
+
+	// the generation waits for a free slot
+	for range cap(m.slots) {
+		m.slots <- struct{}{}
+	}
+	info.Etag = "etag-3"
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := m.Get(ctx, gw, info, req); err == nil {
+		t.Error("expected the generation to wait for a free slot")
+	}
+	if downloads != 2 {
+		t.Errorf("file downloaded %d times, want no download without a free slot", downloads)
+	}
+
+	// folders have no thumbnail
+	folder := &provider.ResourceInfo{Type: provider.ResourceType_RESOURCE_TYPE_CONTAINER, MimeType: "httpd/unix-directory"}
+	if m.HasThumbnail(folder) {
+		t.Error("folders have no thumbnail")
+	}
+}
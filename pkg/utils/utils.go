@@ -29,6 +29,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -102,6 +103,28 @@ func GetClientIP(r *http.Request) (string, error) {
 	return clientIP, nil
 }
 
+// IPInNetworks reports whether ip belongs to one of the given networks,
+// expressed in CIDR notation or as single addresses.
+func IPInNetworks(ip string, networks []string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, n := range networks {
+		_, network, err := net.ParseCIDR(n)
+		if err != nil {
+			if allowed := net.ParseIP(n); allowed != nil && allowed.Equal(addr) {
+				return true
+			}
+			continue
+		}
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // ToSnakeCase converts a CamelCase string to a snake_case string.
 func ToSnakeCase(str string) string {
 	snake := matchFirstCap.ReplaceAllString(str, "${1}_${2}")
@@ -442,6 +465,23 @@ func IsExternalUser(u *userpb.User) bool {
 		u.Id.Type == userpb.UserType_USER_TYPE_GUEST
 }
 
+// IsAdmin returns true if the user is one of the given admins
+// or belongs to one of the given admin groups.
+func IsAdmin(u *userpb.User, admins, adminGroups []string) bool {
+	if u == nil {
+		return false
+	}
+	if slices.Contains(admins, u.Username) {
+		return true
+	}
+	for _, g := range u.Groups {
+		if slices.Contains(adminGroups, g) {
+			return true
+		}
+	}
+	return false
+}
+
 // PrintOCMUserId returns a composed user id for federated users good for display purposes
 func PrintOCMUserId(u *userpb.UserId) string {
 	opaque := u.OpaqueId
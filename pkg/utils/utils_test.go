@@ -21,6 +21,7 @@ package utils
 import (
 	"testing"
 
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 )
 
@@ -45,6 +46,26 @@ func TestSkip(t *testing.T) {
 		})
 	}
 }
+func TestIsAdmin(t *testing.T) {
+	admins, groups := []string{"root"}, []string{"service-admins"}
+	for _, tt := range []struct {
+		name string
+		user *userpb.User
+		out  bool
+	}{
+		{"admin", &userpb.User{Username: "root"}, true},
+		{"admin group", &userpb.User{Username: "alice", Groups: []string{"physics", "service-admins"}}, true},
+		{"other user", &userpb.User{Username: "alice", Groups: []string{"physics"}}, false},
+		{"no user", nil, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if r := IsAdmin(tt.user, admins, groups); r != tt.out {
+				t.Errorf("expected %v, got %v", tt.out, r)
+			}
+		})
+	}
+}
+
 func TestIsRelativeReference(t *testing.T) {
 	tests := []struct {
 		ref      *provider.Reference